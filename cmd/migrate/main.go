@@ -13,7 +13,13 @@ import (
 func main() {
 	var (
 		configPath = flag.String("config", "app.toml", "Path to config file")
-		action     = flag.String("action", "up", "Migration action: up, down, status, reset")
+		action     = flag.String("action", "up", "Migration action: up, down, goto, status, reset, create, seed")
+		version    = flag.String("version", "", "Target version for goto; 0 rolls back everything")
+		steps      = flag.Int("steps", 1, "Number of migrations down rolls back")
+		dryRun     = flag.Bool("dry-run", false, "Print the goto or down plan without executing it")
+		skipSum    = flag.Bool("skip-checksum", false, "Run up even if applied migration files were edited")
+		module     = flag.String("module", "", "Module directory of the migration to create, such as sensor_data")
+		descr      = flag.String("description", "", "Description of the migration to create")
 	)
 	flag.Parse()
 
@@ -23,6 +29,14 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	// Creating a file must work before any database exists
+	if *action == "create" {
+		if err := createMigration(cfg, *module, *descr); err != nil {
+			log.Fatalf("Failed to create migration: %v", err)
+		}
+		return
+	}
+
 	// Connect to database
 	db, err := database.NewConnection(&cfg.Database)
 	if err != nil {
@@ -31,7 +45,10 @@ func main() {
 	defer db.Close()
 
 	// Create migration manager
-	migrationManager := database.NewMigrationManager(db.DB)
+	migrationManager := database.NewMigrationManager(db.DB, cfg.Database.MigrationsDir)
+	migrationManager.SetDryRun(*dryRun)
+	migrationManager.SetSkipChecksum(*skipSum)
+	migrationManager.SetLockTimeout(cfg.Database.MigrationLockTimeout)
 
 	// Execute action
 	switch *action {
@@ -42,15 +59,33 @@ func main() {
 		fmt.Println("✅ Migrations completed successfully")
 
 	case "down":
-		if err := migrationManager.Rollback(); err != nil {
+		if err := migrationManager.RollbackSteps(*steps); err != nil {
 			log.Fatalf("Failed to rollback migration: %v", err)
 		}
-		fmt.Println("✅ Migration rolled back successfully")
+		if !*dryRun {
+			fmt.Println("✅ Migration rolled back successfully")
+		}
+
+	case "goto":
+		if *version == "" {
+			log.Fatalf("-version is required for goto")
+		}
+		if err := migrationManager.MigrateTo(*version); err != nil {
+			log.Fatalf("Failed to migrate to version %s: %v", *version, err)
+		}
+		if !*dryRun {
+			fmt.Printf("✅ Migrated to version %s successfully\n", *version)
+		}
 
 	case "status":
-		if err := showMigrationStatus(db); err != nil {
+		pending, err := showMigrationStatus(migrationManager)
+		if err != nil {
 			log.Fatalf("Failed to show migration status: %v", err)
 		}
+		// A non-zero exit lets deploys gate on an up-to-date schema
+		if pending > 0 {
+			os.Exit(1)
+		}
 
 	case "reset":
 		if err := resetDatabase(db, cfg); err != nil {
@@ -58,42 +93,89 @@ func main() {
 		}
 		fmt.Println("✅ Database reset successfully")
 
+	case "seed":
+		if err := database.Seed(db.DB); err != nil {
+			log.Fatalf("Failed to seed database: %v", err)
+		}
+		fmt.Println("✅ Seed data inserted successfully")
+
 	default:
 		fmt.Printf("Unknown action: %s\n", *action)
-		fmt.Println("Available actions: up, down, status, reset")
+		fmt.Println("Available actions: up, down, goto, status, reset, create, seed")
 		os.Exit(1)
 	}
 }
 
-// showMigrationStatus displays current migration status
-func showMigrationStatus(db *database.DB) error {
+// createMigration writes a new migration file template. The database is
+// consulted when reachable so applied versions whose files are gone are not
+// reused.
+func createMigration(cfg *config.Config, module, description string) error {
+	if module == "" || description == "" {
+		return fmt.Errorf("-module and -description are required")
+	}
+
+	var migrationManager *database.MigrationManager
+	db, err := database.NewConnection(&cfg.Database)
+	if err != nil {
+		log.Printf("Warning: %v; numbering from the migration files only", err)
+		migrationManager = database.NewMigrationManager(nil, cfg.Database.MigrationsDir)
+	} else {
+		defer db.Close()
+		migrationManager = database.NewMigrationManager(db.DB, cfg.Database.MigrationsDir)
+	}
+
+	if err := migrationManager.CreateMigrationFile(module, description); err != nil {
+		return err
+	}
+
+	fmt.Println("✅ Migration file created successfully")
+	return nil
+}
+
+// showMigrationStatus displays current migration status and returns how
+// many migrations are pending
+func showMigrationStatus(migrationManager *database.MigrationManager) (int, error) {
 	fmt.Println("📊 Migration Status:")
 	fmt.Println("==================")
 
-	migrationManager := database.NewMigrationManager(db.DB)
 	status, err := migrationManager.GetMigrationStatus()
 	if err != nil {
-		return fmt.Errorf("failed to get migration status: %w", err)
+		return 0, fmt.Errorf("failed to get migration status: %w", err)
 	}
 
 	if len(status) == 0 {
-		fmt.Println("No migrations executed yet")
-		return nil
+		fmt.Println("No migrations found")
+		return 0, nil
 	}
 
-	fmt.Printf("%-8s %-15s %-40s %-20s\n", "Version", "Module", "Description", "Executed At")
-	fmt.Println(strings.Repeat("-", 85))
+	fmt.Printf("%-8s %-15s %-40s %-20s %-10s\n", "Version", "Module", "Description", "Executed At", "File")
+	fmt.Println(strings.Repeat("-", 96))
 
+	applied, pending, problems := 0, 0, 0
 	for _, migration := range status {
-		fmt.Printf("%-8s %-15s %-40s %-20s\n",
-			migration["version"],
-			migration["module"],
-			migration["description"],
-			migration["executed_at"].(string)[:19])
+		executedAt := "PENDING"
+		if migration.Applied {
+			executedAt = migration.ExecutedAt.Format("2006-01-02 15:04:05")
+			applied++
+		} else {
+			pending++
+		}
+		fmt.Printf("%-8s %-15s %-40s %-20s %-10s\n",
+			migration.Version,
+			migration.Module,
+			migration.Description,
+			executedAt,
+			migration.FileStatus)
+		if migration.FileStatus == "edited" || migration.FileStatus == "missing" {
+			problems++
+		}
 	}
 
-	fmt.Printf("\nTotal: %d migrations executed\n", len(status))
-	return nil
+	fmt.Printf("\nTotal: %d migrations executed, %d pending\n", applied, pending)
+	if problems > 0 {
+		fmt.Printf("⚠️  %d applied migrations have an edited or missing file\n", problems)
+	}
+	return pending, nil
 }
 
 // resetDatabase drops all tables and re-runs migrations
@@ -152,7 +234,7 @@ func resetDatabase(db *database.DB, cfg *config.Config) error {
 
 	// Re-run migrations
 	fmt.Println("🔄 Re-running migrations...")
-	migrationManager := database.NewMigrationManager(db.DB)
+	migrationManager := database.NewMigrationManager(db.DB, cfg.Database.MigrationsDir)
 	if err := migrationManager.RunMigrations(); err != nil {
 		return fmt.Errorf("failed to re-run migrations: %w", err)
 	}
@@ -15,6 +15,13 @@ type MQTTConfig struct {
 	Password string `toml:"password"`
 	ClientID string `toml:"client_id"`
 	QoS      byte   `toml:"qos"`
+
+	// ConnectAttempts is how many times to try reaching the broker on
+	// startup; 0 uses 10
+	ConnectAttempts int `toml:"connect_attempts"`
+	// ConnectBackoff is the wait before the first retry, doubling on each
+	// attempt up to 30 seconds; 0 uses 1 second
+	ConnectBackoff time.Duration `toml:"connect_backoff"`
 }
 
 // Config holds all configuration for the application
@@ -24,7 +31,75 @@ type Config struct {
 	JWT       JWTConfig       `toml:"jwt"`
 	App       AppConfig       `toml:"app"`
 	RateLimit RateLimitConfig `toml:"rate_limit"`
+	CORS      CORSConfig      `toml:"cors"`
 	MQTT      MQTTConfig      `toml:"mqtt"`
+	Sensors   SensorsConfig   `toml:"sensors"`
+
+	SensorHealth SensorHealthConfig `toml:"sensor_health"`
+}
+
+// SensorsConfig holds sensor data configuration
+type SensorsConfig struct {
+	// ReadingRetentionDays is how long readings are kept unless their sensor
+	// type overrides it; 0 keeps them forever
+	ReadingRetentionDays int `toml:"reading_retention_days"`
+	// MaxReadingDeleteDays bounds the time window of a reading deletion; 0 uses 7 days
+	MaxReadingDeleteDays int `toml:"max_reading_delete_days"`
+	// OnlineThresholdMinutes is how recent the last reading of an online sensor
+	// without an expected interval is; 0 uses 30 minutes
+	OnlineThresholdMinutes int `toml:"online_threshold_minutes"`
+	// AutoProvision creates pending sensors for unknown devices reporting over
+	// MQTT instead of dropping their readings
+	AutoProvision bool `toml:"auto_provision"`
+	// ProvisionSensorType names the sensor type of auto-provisioned sensors;
+	// required with AutoProvision
+	ProvisionSensorType string `toml:"provision_sensor_type"`
+	// AllowUnauthenticatedIngest accepts readings on the public endpoints
+	// without a device token; unset allows them while devices migrate
+	AllowUnauthenticatedIngest *bool `toml:"allow_unauthenticated_ingest"`
+	// MaxBulkReadings bounds the readings of a bulk batch; 0 uses 10000
+	MaxBulkReadings int `toml:"max_bulk_readings"`
+	// CommandTimeoutSeconds is how long devices have to acknowledge a
+	// command before it is marked timed out; 0 uses 300 seconds
+	CommandTimeoutSeconds int `toml:"command_timeout_seconds"`
+	// DashboardCacheSeconds is how long dashboard and health results are
+	// cached; 0 uses 20 seconds and a negative value disables caching
+	DashboardCacheSeconds int `toml:"dashboard_cache_seconds"`
+}
+
+// SensorHealthConfig weights the sensor health checks. Unset values keep
+// their defaults; a penalty of 0 disables its check.
+type SensorHealthConfig struct {
+	// OfflinePenalty applies to sensors without a reading within their
+	// online threshold; defaults to 30
+	OfflinePenalty *int `toml:"offline_penalty"`
+	// CriticalBatteryPenalty applies below CriticalBatteryLevel percent;
+	// defaults to 25 below 20
+	CriticalBatteryLevel   *int `toml:"critical_battery_level"`
+	CriticalBatteryPenalty *int `toml:"critical_battery_penalty"`
+	// LowBatteryPenalty applies below LowBatteryLevel percent; defaults to
+	// 10 below 50
+	LowBatteryLevel   *int `toml:"low_battery_level"`
+	LowBatteryPenalty *int `toml:"low_battery_penalty"`
+	// PoorQualityPenalty applies when the latest reading's quality is below
+	// PoorQualityLevel; defaults to 15 below 80
+	PoorQualityLevel   *int `toml:"poor_quality_level"`
+	PoorQualityPenalty *int `toml:"poor_quality_penalty"`
+	// StaleReadingPenalty applies when the last reading is older than
+	// StaleReadingMinutes; defaults to 15 after 120 minutes
+	StaleReadingMinutes *int `toml:"stale_reading_minutes"`
+	StaleReadingPenalty *int `toml:"stale_reading_penalty"`
+	// NoReadingsPenalty applies to sensors that never reported; defaults to 20
+	NoReadingsPenalty *int `toml:"no_readings_penalty"`
+	// AlertScore lists sensors scoring below it as dashboard alerts;
+	// defaults to 80
+	AlertScore *int `toml:"alert_score"`
+}
+
+// UnauthenticatedIngestAllowed reports whether readings without a device
+// token are accepted, which is the default
+func (c SensorsConfig) UnauthenticatedIngestAllowed() bool {
+	return c.AllowUnauthenticatedIngest == nil || *c.AllowUnauthenticatedIngest
 }
 
 // ServerConfig holds server configuration
@@ -34,26 +109,52 @@ type ServerConfig struct {
 	ReadTimeout  time.Duration `toml:"read_timeout"`
 	WriteTimeout time.Duration `toml:"write_timeout"`
 	IdleTimeout  time.Duration `toml:"idle_timeout"`
+	// TrustedProxies are the IPs or CIDRs of reverse proxies whose
+	// X-Forwarded-For and X-Real-IP headers identify the client
+	TrustedProxies []string `toml:"trusted_proxies"`
 }
 
 // DatabaseConfig holds database configuration
 type DatabaseConfig struct {
-	Host            string        `toml:"host"`
-	Port            int           `toml:"port"`
-	User            string        `toml:"user"`
-	Password        string        `toml:"password"`
-	DBName          string        `toml:"dbname"`
-	SSLMode         string        `toml:"sslmode"`
+	Host     string `toml:"host"`
+	Port     int    `toml:"port"`
+	User     string `toml:"user"`
+	Password string `toml:"password"`
+	DBName   string `toml:"dbname"`
+	SSLMode  string `toml:"sslmode"`
+	// MaxOpenConns must be 0 (unlimited) or at least 2: migrations hold
+	// their lock on one connection while running on another
 	MaxOpenConns    int           `toml:"max_open_conns"`
 	MaxIdleConns    int           `toml:"max_idle_conns"`
 	ConnMaxLifetime time.Duration `toml:"conn_max_lifetime"`
+
+	// StatementTimeoutSeconds aborts statements running longer than this;
+	// 0 uses 30 seconds and a negative value disables the timeout
+	StatementTimeoutSeconds int `toml:"statement_timeout_seconds"`
+	// MigrationsDir reads migrations from this directory instead of the
+	// files built into the binary, for development
+	MigrationsDir string `toml:"migrations_dir"`
+	// SkipMigrationChecksum starts even when applied migration files were
+	// edited since they ran
+	SkipMigrationChecksum bool `toml:"skip_migration_checksum"`
+	// MigrationLockTimeout is how long an instance waits for another one
+	// to finish migrating; 0 uses 5 minutes
+	MigrationLockTimeout time.Duration `toml:"migration_lock_timeout"`
+	// ConnectAttempts is how many times to try reaching the database on
+	// startup; 0 uses 10
+	ConnectAttempts int `toml:"connect_attempts"`
+	// ConnectBackoff is the wait before the first retry, doubling on each
+	// attempt up to 30 seconds; 0 uses 1 second
+	ConnectBackoff time.Duration `toml:"connect_backoff"`
 }
 
 // JWTConfig holds JWT configuration
 type JWTConfig struct {
-	Secret             string `toml:"secret"`
-	ExpireHours        int    `toml:"expire_hours"`
-	RefreshExpireHours int    `toml:"refresh_expire_hours"`
+	Secret                 string   `toml:"secret"`
+	ExpireHours            int      `toml:"expire_hours"`
+	RefreshExpireHours     int      `toml:"refresh_expire_hours"`
+	PrivateKeyPath         string   `toml:"private_key_path"`
+	PreviousPublicKeyPaths []string `toml:"previous_public_key_paths"`
 }
 
 // AppConfig holds application configuration
@@ -61,12 +162,87 @@ type AppConfig struct {
 	Environment string `toml:"environment"`
 	LogLevel    string `toml:"log_level"`
 	BCryptCost  int    `toml:"bcrypt_cost"`
+	// EncryptionKey protects TOTP secrets at rest and is required
+	EncryptionKey string `toml:"encryption_key"`
+	// RegistrationMode is "open" (default) or "invite_only"
+	RegistrationMode string `toml:"registration_mode"`
+	// SkipEmailVerification lets new accounts log in unverified; for development only
+	SkipEmailVerification bool `toml:"skip_email_verification"`
+	// LogFormat is "json" or "text"; unset uses json in production and
+	// text otherwise
+	LogFormat string `toml:"log_format"`
+	// RequestLogSkipPaths are not logged per request, such as the health
+	// probes
+	RequestLogSkipPaths []string `toml:"request_log_skip_paths"`
+	// APIDocs serves Swagger UI for the OpenAPI document at /api/docs. The
+	// page loads Swagger UI from the unpkg CDN, so browsers need access to it.
+	APIDocs bool `toml:"api_docs"`
+
+	PasswordPolicy PasswordPolicyConfig `toml:"password_policy"`
+	AuthCache      AuthCacheConfig      `toml:"auth_cache"`
 }
 
-// RateLimitConfig holds rate limiting configuration
+// AuthCacheConfig holds caching settings for token and permission checks
+type AuthCacheConfig struct {
+	// Disabled makes every request hit the database so permission changes apply instantly
+	Disabled bool          `toml:"disabled"`
+	TTL      time.Duration `toml:"ttl"`
+}
+
+// PasswordPolicyConfig holds password strength rules
+type PasswordPolicyConfig struct {
+	MinLength     int      `toml:"min_length"`
+	RequireUpper  bool     `toml:"require_upper"`
+	RequireLower  bool     `toml:"require_lower"`
+	RequireDigit  bool     `toml:"require_digit"`
+	RequireSymbol bool     `toml:"require_symbol"`
+	RejectEmail   bool     `toml:"reject_email"`
+	DenyList      []string `toml:"deny_list"`
+}
+
+// CORSConfig holds cross-origin request settings. Without the section any
+// origin is allowed.
+type CORSConfig struct {
+	// AllowedOrigins lists exact origins, "*" for any origin, or patterns
+	// with one "*" such as "https://*.example.com"
+	AllowedOrigins []string `toml:"allowed_origins"`
+	// AllowedMethods defaults to the methods the API uses
+	AllowedMethods []string `toml:"allowed_methods"`
+	// AllowedHeaders defaults to the request headers the API reads
+	AllowedHeaders   []string `toml:"allowed_headers"`
+	AllowCredentials bool     `toml:"allow_credentials"`
+	// MaxAgeSeconds is how long browsers cache preflight results
+	MaxAgeSeconds int `toml:"max_age_seconds"`
+}
+
+// RateLimitConfig holds rate limiting configuration. API requests are
+// limited per user when authenticated and per client IP otherwise; a rate
+// of 0 does not limit.
 type RateLimitConfig struct {
 	RequestsPerMinute int `toml:"requests_per_minute"`
 	Burst             int `toml:"burst"`
+	// Routes overrides the limit of routes by their pattern, such as
+	// "POST /api/auth/login"
+	Routes map[string]RouteRateLimitConfig `toml:"routes"`
+
+	Ingest IngestRateLimitConfig `toml:"ingest"`
+}
+
+// RouteRateLimitConfig limits the requests to one route
+type RouteRateLimitConfig struct {
+	RequestsPerMinute int `toml:"requests_per_minute"`
+	Burst             int `toml:"burst"`
+}
+
+// IngestRateLimitConfig limits how fast each sensor can send readings over
+// HTTP and MQTT; sensors can override the rate
+type IngestRateLimitConfig struct {
+	// ReadingsPerMinute is the rate of sensors without an override; 0 only
+	// limits sensors with an override
+	ReadingsPerMinute int `toml:"readings_per_minute"`
+	// Burst is how many readings a sensor can send at once; at least one
+	// second of its rate is allowed
+	Burst int `toml:"burst"`
 }
 
 // Load loads configuration from TOML file
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"time"
 	"user-management/config"
 
 	_ "github.com/lib/pq"
@@ -19,6 +20,16 @@ const (
 	UserManagementSchema = "user_management"
 )
 
+// DefaultStatementTimeoutSeconds bounds statements unless configured otherwise
+const DefaultStatementTimeoutSeconds = 30
+
+// Connection retry defaults, used unless configured otherwise
+const (
+	DefaultConnectAttempts = 10
+	DefaultConnectBackoff  = time.Second
+	maxConnectBackoff      = 30 * time.Second
+)
+
 // NewConnection creates a new database connection
 func NewConnection(cfg *config.DatabaseConfig) (*DB, error) {
 	// Build connection string
@@ -27,6 +38,15 @@ func NewConnection(cfg *config.DatabaseConfig) (*DB, error) {
 		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBName, cfg.SSLMode,
 	)
 
+	// Unknown keys are sent to the server as session settings
+	statementTimeout := cfg.StatementTimeoutSeconds
+	if statementTimeout == 0 {
+		statementTimeout = DefaultStatementTimeoutSeconds
+	}
+	if statementTimeout > 0 {
+		connStr += fmt.Sprintf(" statement_timeout=%d", statementTimeout*1000)
+	}
+
 	// Open database connection
 	db, err := sql.Open("postgres", connStr)
 	if err != nil {
@@ -38,9 +58,10 @@ func NewConnection(cfg *config.DatabaseConfig) (*DB, error) {
 	db.SetMaxIdleConns(cfg.MaxIdleConns)
 	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
 
-	// Test connection
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+	// Test connection, waiting for a database that is still starting
+	if err := pingWithRetry(db, cfg); err != nil {
+		db.Close()
+		return nil, err
 	}
 
 	log.Printf("Successfully connected to database %s:%d", cfg.Host, cfg.Port)
@@ -48,6 +69,39 @@ func NewConnection(cfg *config.DatabaseConfig) (*DB, error) {
 	return &DB{db}, nil
 }
 
+// pingWithRetry pings the database until it answers, backing off
+// exponentially between attempts
+func pingWithRetry(db *sql.DB, cfg *config.DatabaseConfig) error {
+	attempts := cfg.ConnectAttempts
+	if attempts <= 0 {
+		attempts = DefaultConnectAttempts
+	}
+	backoff := cfg.ConnectBackoff
+	if backoff <= 0 {
+		backoff = DefaultConnectBackoff
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = db.Ping(); err == nil {
+			return nil
+		}
+
+		log.Printf("Database %s:%d not reachable (attempt %d/%d): %v",
+			cfg.Host, cfg.Port, attempt, attempts, err)
+		if attempt < attempts {
+			log.Printf("Retrying database connection in %s", backoff)
+			time.Sleep(backoff)
+			backoff *= 2
+			if backoff > maxConnectBackoff {
+				backoff = maxConnectBackoff
+			}
+		}
+	}
+
+	return fmt.Errorf("failed to ping database after %d attempts: %w", attempts, err)
+}
+
 // MustConnect creates database connection or panics
 func MustConnect(cfg *config.DatabaseConfig) *DB {
 	db, err := NewConnection(cfg)
@@ -62,8 +116,12 @@ func (db *DB) Close() error {
 	return db.DB.Close()
 }
 
-// RunMigrations runs all database migrations
-func (db *DB) RunMigrations() error {
-	migrationManager := NewMigrationManager(db.DB)
+// RunMigrations runs all database migrations, reading them from the
+// configured directory when set and from the files built into the binary
+// otherwise
+func (db *DB) RunMigrations(cfg *config.DatabaseConfig) error {
+	migrationManager := NewMigrationManager(db.DB, cfg.MigrationsDir)
+	migrationManager.SetSkipChecksum(cfg.SkipMigrationChecksum)
+	migrationManager.SetLockTimeout(cfg.MigrationLockTimeout)
 	return migrationManager.RunMigrations()
 }
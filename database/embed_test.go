@@ -0,0 +1,60 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestEmbeddedMigrations loads the migrations built into the binary from a
+// working directory without a database/migrations directory
+func TestEmbeddedMigrations(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	migrations, err := NewMigrationManager(nil, "").loadMigrationsFromFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	versions := make(map[string]string, len(migrations))
+	for _, migration := range migrations {
+		if migration.UpSQL == "" {
+			t.Errorf("%s has no UP section", migration.FilePath)
+		}
+		if other, ok := versions[migration.Version]; ok {
+			t.Errorf("%s and %s share version %s", other, migration.FilePath, migration.Version)
+		}
+		versions[migration.Version] = migration.FilePath
+	}
+}
+
+// TestMigrationsDirOverride reads migrations from the configured directory
+// instead of the embedded files
+func TestMigrationsDirOverride(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sensor_data"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	content := "-- Migration: 001_create_things.sql\n-- UP\nCREATE TABLE things (id INTEGER);\n\n-- DOWN\nDROP TABLE things;\n"
+	if err := os.WriteFile(filepath.Join(dir, "sensor_data", "001_create_things.sql"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	migrations, err := NewMigrationManager(nil, dir).loadMigrationsFromFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 1 {
+		t.Fatalf("got %d migrations, want 1", len(migrations))
+	}
+	if got := migrations[0]; got.Version != "001" || got.Module != "sensor_data" || got.Description != "create things" {
+		t.Errorf("got version %q, module %q, description %q", got.Version, got.Module, got.Description)
+	}
+}
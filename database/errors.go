@@ -0,0 +1,30 @@
+package database
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// PostgreSQL error codes repositories map to their own errors
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+)
+
+// IsUniqueViolation reports whether err is a PostgreSQL unique constraint
+// violation, however it was wrapped
+func IsUniqueViolation(err error) bool {
+	return hasCode(err, uniqueViolation)
+}
+
+// IsForeignKeyViolation reports whether err is a PostgreSQL foreign key
+// violation, however it was wrapped
+func IsForeignKeyViolation(err error) bool {
+	return hasCode(err, foreignKeyViolation)
+}
+
+func hasCode(err error, code pq.ErrorCode) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == code
+}
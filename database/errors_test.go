@@ -0,0 +1,38 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestConstraintViolations(t *testing.T) {
+	unique := fmt.Errorf("failed to create user: %w", &pq.Error{Code: "23505"})
+	foreignKey := fmt.Errorf("failed to assign role: %w", &pq.Error{Code: "23503"})
+
+	tests := []struct {
+		name       string
+		err        error
+		unique     bool
+		foreignKey bool
+	}{
+		{"unique violation", unique, true, false},
+		{"foreign key violation", foreignKey, false, true},
+		{"other database error", &pq.Error{Code: "23502"}, false, false},
+		{"duplicate key message only", errors.New("pq: duplicate key value violates unique constraint"), false, false},
+		{"nil", nil, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsUniqueViolation(tt.err); got != tt.unique {
+				t.Errorf("IsUniqueViolation = %v, want %v", got, tt.unique)
+			}
+			if got := IsForeignKeyViolation(tt.err); got != tt.foreignKey {
+				t.Errorf("IsForeignKeyViolation = %v, want %v", got, tt.foreignKey)
+			}
+		})
+	}
+}
@@ -1,15 +1,22 @@
 package database
 
 import (
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io/fs"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Migration represents a database migration
@@ -20,24 +27,137 @@ type Migration struct {
 	UpSQL       string
 	DownSQL     string
 	FilePath    string
+	// Checksum is the SHA-256 of UpSQL, recorded to detect applied
+	// migrations whose file was edited afterwards
+	Checksum string
+	// NoTransaction runs the statements one by one outside a transaction,
+	// which CREATE INDEX CONCURRENTLY requires
+	NoTransaction bool
 }
 
+// noTransactionMarker marks a migration file to run outside a transaction
+const noTransactionMarker = "-- NO TRANSACTION"
+
+// defaultMigrationsDir is where migration files live in the source tree
+const defaultMigrationsDir = "database/migrations"
+
+// Migration lock settings
+const (
+	// migrationLockKey is the advisory lock key instances hold while
+	// migrating, so only one of them applies migrations at a time
+	migrationLockKey = 7_310_462_158
+	// DefaultMigrationLockTimeout is how long to wait for another instance
+	// to finish migrating unless configured otherwise
+	DefaultMigrationLockTimeout = 5 * time.Minute
+	// migrationLockPollInterval is how often a waiting instance retries
+	migrationLockPollInterval = time.Second
+)
+
+// embeddedMigrations holds the migration files built into the binary
+//
+//go:embed migrations
+var embeddedMigrations embed.FS
+
 // MigrationManager handles database migrations
 type MigrationManager struct {
-	db            *sql.DB
+	db *sql.DB
+	// migrations holds the migration files, one directory per module
+	migrations fs.FS
+	// migrationsDir is where CreateMigrationFile writes new files
 	migrationsDir string
+	// dryRun logs migration plans instead of executing them
+	dryRun bool
+	// skipChecksum runs migrations even when applied files were edited
+	skipChecksum bool
+	// lockTimeout bounds the wait for another instance's migration lock
+	lockTimeout time.Duration
 }
 
-// NewMigrationManager creates a new migration manager
-func NewMigrationManager(db *sql.DB) *MigrationManager {
+// NewMigrationManager creates a new migration manager. Migrations are read
+// from dir when it is set, which lets development pick up new files
+// without a rebuild, and from the files embedded in the binary otherwise.
+func NewMigrationManager(db *sql.DB, dir string) *MigrationManager {
+	if dir != "" {
+		return &MigrationManager{
+			db:            db,
+			migrations:    os.DirFS(dir),
+			migrationsDir: dir,
+			lockTimeout:   DefaultMigrationLockTimeout,
+		}
+	}
+
+	migrations, err := fs.Sub(embeddedMigrations, "migrations")
+	if err != nil {
+		// The embedded directory always exists
+		panic(err)
+	}
+
 	return &MigrationManager{
 		db:            db,
-		migrationsDir: "database/migrations",
+		migrations:    migrations,
+		migrationsDir: defaultMigrationsDir,
+		lockTimeout:   DefaultMigrationLockTimeout,
+	}
+}
+
+// SetLockTimeout sets how long to wait for another instance holding the
+// migration lock; a non-positive timeout keeps the default
+func (m *MigrationManager) SetLockTimeout(timeout time.Duration) {
+	if timeout > 0 {
+		m.lockTimeout = timeout
+	}
+}
+
+// withLock runs fn while holding the migration advisory lock. The lock is
+// held on its own connection, so the pool must allow more than one.
+func (m *MigrationManager) withLock(fn func() error) error {
+	// With a single connection fn would wait forever for the one the lock holds
+	if m.db.Stats().MaxOpenConnections == 1 {
+		return errors.New("migrations need max_open_conns of at least 2: the migration lock holds one connection")
+	}
+
+	ctx := context.Background()
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection for migration lock: %w", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(m.lockTimeout)
+	for waiting := false; ; waiting = true {
+		var acquired bool
+		if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", migrationLockKey).Scan(&acquired); err != nil {
+			return fmt.Errorf("failed to take migration lock: %w", err)
+		}
+		if acquired {
+			break
+		}
+
+		if !waiting {
+			log.Printf("Another instance is running migrations, waiting up to %v for it to finish...", m.lockTimeout)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %v waiting for the migration lock", m.lockTimeout)
+		}
+		time.Sleep(migrationLockPollInterval)
 	}
+	defer func() {
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", migrationLockKey); err != nil {
+			log.Printf("Warning: failed to release migration lock: %v", err)
+		}
+	}()
+
+	return fn()
 }
 
-// RunMigrations executes all pending migrations
+// RunMigrations executes all pending migrations. Concurrent instances take
+// turns; the later ones find the migrations already applied.
 func (m *MigrationManager) RunMigrations() error {
+	return m.withLock(m.runMigrations)
+}
+
+// runMigrations executes pending migrations while holding the lock
+func (m *MigrationManager) runMigrations() error {
 	// Create migrations table if not exists
 	if err := m.createMigrationsTable(); err != nil {
 		return fmt.Errorf("failed to create migrations table: %w", err)
@@ -49,12 +169,11 @@ func (m *MigrationManager) RunMigrations() error {
 		return fmt.Errorf("failed to load migrations: %w", err)
 	}
 
-	// Sort by version
-	sort.Slice(migrations, func(i, j int) bool {
-		vi, _ := strconv.Atoi(migrations[i].Version)
-		vj, _ := strconv.Atoi(migrations[j].Version)
-		return vi < vj
-	})
+	sortMigrations(migrations)
+
+	if err := m.verifyChecksums(migrations); err != nil {
+		return err
+	}
 
 	// Execute pending migrations
 	for _, migration := range migrations {
@@ -67,6 +186,15 @@ func (m *MigrationManager) RunMigrations() error {
 	return nil
 }
 
+// sortMigrations sorts migrations by version
+func sortMigrations(migrations []Migration) {
+	sort.Slice(migrations, func(i, j int) bool {
+		vi, _ := strconv.Atoi(migrations[i].Version)
+		vj, _ := strconv.Atoi(migrations[j].Version)
+		return vi < vj
+	})
+}
+
 // createMigrationsTable creates the migrations tracking table
 func (m *MigrationManager) createMigrationsTable() error {
 	// Create table with new structure
@@ -76,7 +204,8 @@ func (m *MigrationManager) createMigrationsTable() error {
 		description TEXT,
 		module VARCHAR(100),
 		file_path VARCHAR(500),
-		executed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		executed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		checksum VARCHAR(64)
 	)`
 
 	if _, err := m.db.Exec(query); err != nil {
@@ -130,26 +259,20 @@ func (m *MigrationManager) migrateMigrationsTable() error {
 		log.Println("Migration table structure updated successfully")
 	}
 
+	// Checksums were added later; older records start without one
+	if _, err := m.db.Exec("ALTER TABLE public.migrations ADD COLUMN IF NOT EXISTS checksum VARCHAR(64)"); err != nil {
+		return fmt.Errorf("failed to add checksum column: %w", err)
+	}
+
 	return nil
 }
 
-// loadMigrationsFromFiles reads migration files from filesystem
+// loadMigrationsFromFiles reads the migration files
 func (m *MigrationManager) loadMigrationsFromFiles() ([]Migration, error) {
 	var migrations []Migration
 
-	// Check if migrations directory exists
-	if _, err := os.Stat(m.migrationsDir); os.IsNotExist(err) {
-		log.Printf("Migrations directory %s does not exist, creating it...", m.migrationsDir)
-		if err := m.createMigrationDirectories(); err != nil {
-			return nil, fmt.Errorf("failed to create migration directories: %w", err)
-		}
-		if err := m.createDefaultMigrationFiles(); err != nil {
-			return nil, fmt.Errorf("failed to create default migration files: %w", err)
-		}
-	}
-
 	// Walk through migration directories
-	err := filepath.WalkDir(m.migrationsDir, func(path string, d fs.DirEntry, err error) error {
+	err := fs.WalkDir(m.migrations, ".", func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
@@ -177,19 +300,24 @@ func (m *MigrationManager) loadMigrationsFromFiles() ([]Migration, error) {
 		return nil, fmt.Errorf("failed to walk migration directory: %w", err)
 	}
 
+	// Finding nothing means a wrong directory, not an empty schema
+	if len(migrations) == 0 {
+		return nil, errors.New("no migration files found")
+	}
+
 	return migrations, nil
 }
 
 // parseMigrationFile parses a single migration file
 func (m *MigrationManager) parseMigrationFile(filePath string) (Migration, error) {
 	// Read file content
-	content, err := os.ReadFile(filePath)
+	content, err := fs.ReadFile(m.migrations, filePath)
 	if err != nil {
 		return Migration{}, fmt.Errorf("failed to read file: %w", err)
 	}
 
 	// Extract version and description from filename
-	filename := filepath.Base(filePath)
+	filename := path.Base(filePath)
 	parts := strings.SplitN(filename, "_", 2)
 	if len(parts) < 2 {
 		return Migration{}, fmt.Errorf("invalid migration filename format: %s", filename)
@@ -200,22 +328,165 @@ func (m *MigrationManager) parseMigrationFile(filePath string) (Migration, error
 	description = strings.ReplaceAll(description, "_", " ")
 
 	// Extract module from directory path
-	dir := filepath.Dir(filePath)
-	module := filepath.Base(dir)
+	module := path.Base(path.Dir(filePath))
 
 	// Split content into UP and DOWN sections
 	upSQL, downSQL := m.splitMigrationContent(string(content))
 
 	return Migration{
-		Version:     version,
-		Description: description,
-		Module:      module,
-		UpSQL:       upSQL,
-		DownSQL:     downSQL,
-		FilePath:    filePath,
+		Version:       version,
+		Description:   description,
+		Module:        module,
+		UpSQL:         upSQL,
+		DownSQL:       downSQL,
+		FilePath:      filePath,
+		Checksum:      checksum(upSQL),
+		NoTransaction: hasNoTransactionMarker(string(content)),
 	}, nil
 }
 
+// checksum returns the hex SHA-256 of a migration's SQL
+func checksum(sqlText string) string {
+	sum := sha256.Sum256([]byte(sqlText))
+	return hex.EncodeToString(sum[:])
+}
+
+// SetSkipChecksum lets RunMigrations continue when applied migration files
+// no longer match their recorded checksums
+func (m *MigrationManager) SetSkipChecksum(skip bool) {
+	m.skipChecksum = skip
+}
+
+// verifyChecksums compares the recorded checksums of applied migrations
+// with their files and fails when any file was edited. Records from before
+// checksums were kept adopt the checksum of the current file.
+func (m *MigrationManager) verifyChecksums(migrations []Migration) error {
+	recorded, err := m.recordedChecksums()
+	if err != nil {
+		return err
+	}
+
+	var mismatched []string
+	for _, migration := range migrations {
+		sum, ok := recorded[migration.Version]
+		if !ok {
+			continue
+		}
+		if !sum.Valid {
+			if _, err := m.db.Exec("UPDATE public.migrations SET checksum = $1 WHERE version = $2",
+				migration.Checksum, migration.Version); err != nil {
+				return fmt.Errorf("failed to record checksum of migration %s: %w", migration.Version, err)
+			}
+			continue
+		}
+		if sum.String != migration.Checksum {
+			mismatched = append(mismatched, migration.Version)
+		}
+	}
+
+	if len(mismatched) == 0 {
+		return nil
+	}
+	if m.skipChecksum {
+		log.Printf("Warning: applied migrations were edited since they ran: %s", strings.Join(mismatched, ", "))
+		return nil
+	}
+	return fmt.Errorf("applied migrations were edited since they ran: %s; restore the files or skip the check with skip_migration_checksum",
+		strings.Join(mismatched, ", "))
+}
+
+// recordedChecksums returns the checksum of every applied migration by version
+func (m *MigrationManager) recordedChecksums() (map[string]sql.NullString, error) {
+	rows, err := m.db.Query("SELECT version, checksum FROM public.migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query migration checksums: %w", err)
+	}
+	defer rows.Close()
+
+	recorded := make(map[string]sql.NullString)
+	for rows.Next() {
+		var version string
+		var sum sql.NullString
+		if err := rows.Scan(&version, &sum); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		recorded[version] = sum
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query migration checksums: %w", err)
+	}
+
+	return recorded, nil
+}
+
+// hasNoTransactionMarker checks if a line of the migration is the
+// noTransactionMarker
+func hasNoTransactionMarker(content string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		if strings.TrimSpace(line) == noTransactionMarker {
+			return true
+		}
+	}
+	return false
+}
+
+// splitStatements splits SQL into statements at lines ending with a semicolon
+func splitStatements(sqlText string) []string {
+	var statements []string
+	var current []string
+
+	for _, line := range strings.Split(sqlText, "\n") {
+		current = append(current, line)
+		if strings.HasSuffix(strings.TrimSpace(line), ";") {
+			statements = append(statements, strings.Join(current, "\n"))
+			current = nil
+		}
+	}
+	if rest := strings.Join(current, "\n"); strings.TrimSpace(rest) != "" {
+		statements = append(statements, rest)
+	}
+
+	return statements
+}
+
+// execWithoutTransaction runs each statement on its own. A failure leaves
+// the earlier statements applied, so such migrations must be rerunnable.
+// The statements share one connection with the statement timeout lifted.
+func (m *MigrationManager) execWithoutTransaction(sqlText string) error {
+	ctx := context.Background()
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SET statement_timeout = 0"); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, "RESET statement_timeout")
+
+	for _, statement := range splitStatements(sqlText) {
+		if isCommentOnly(statement) {
+			continue
+		}
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isCommentOnly checks if a statement holds nothing but comments and blank lines
+func isCommentOnly(statement string) bool {
+	for _, line := range strings.Split(statement, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" && !strings.HasPrefix(trimmed, "--") {
+			return false
+		}
+	}
+	return true
+}
+
 // splitMigrationContent splits migration content into UP and DOWN sections
 func (m *MigrationManager) splitMigrationContent(content string) (string, string) {
 	lines := strings.Split(content, "\n")
@@ -260,6 +531,27 @@ func (m *MigrationManager) executeMigration(migration Migration) error {
 		return nil
 	}
 
+	return m.applyMigration(migration)
+}
+
+// applyMigration runs the UP section of a migration and records it
+func (m *MigrationManager) applyMigration(migration Migration) error {
+	if migration.NoTransaction {
+		if err := m.execWithoutTransaction(migration.UpSQL); err != nil {
+			return fmt.Errorf("failed to execute migration SQL: %w", err)
+		}
+
+		if _, err := m.db.Exec(
+			"INSERT INTO public.migrations (version, description, module, file_path, checksum) VALUES ($1, $2, $3, $4, $5)",
+			migration.Version, migration.Description, migration.Module, migration.FilePath, migration.Checksum,
+		); err != nil {
+			return fmt.Errorf("failed to record migration: %w", err)
+		}
+
+		log.Printf("Migration %s executed successfully: %s [%s]", migration.Version, migration.Description, migration.Module)
+		return nil
+	}
+
 	// Start transaction
 	tx, err := m.db.Begin()
 	if err != nil {
@@ -267,6 +559,11 @@ func (m *MigrationManager) executeMigration(migration Migration) error {
 	}
 	defer tx.Rollback()
 
+	// Migrations may run longer than the statement timeout
+	if _, err := tx.Exec("SET LOCAL statement_timeout = 0"); err != nil {
+		return fmt.Errorf("failed to lift statement timeout: %w", err)
+	}
+
 	// Execute migration
 	if strings.TrimSpace(migration.UpSQL) != "" {
 		if _, err := tx.Exec(migration.UpSQL); err != nil {
@@ -276,8 +573,8 @@ func (m *MigrationManager) executeMigration(migration Migration) error {
 
 	// Record migration
 	if _, err := tx.Exec(
-		"INSERT INTO public.migrations (version, description, module, file_path) VALUES ($1, $2, $3, $4)",
-		migration.Version, migration.Description, migration.Module, migration.FilePath,
+		"INSERT INTO public.migrations (version, description, module, file_path, checksum) VALUES ($1, $2, $3, $4, $5)",
+		migration.Version, migration.Description, migration.Module, migration.FilePath, migration.Checksum,
 	); err != nil {
 		return fmt.Errorf("failed to record migration: %w", err)
 	}
@@ -291,29 +588,19 @@ func (m *MigrationManager) executeMigration(migration Migration) error {
 	return nil
 }
 
-// Rollback rolls back the last migration
-func (m *MigrationManager) Rollback() error {
-	// Get last migration
-	var version, description, module, filePath string
-	err := m.db.QueryRow(`
-		SELECT version, description, module, file_path 
-		FROM public.migrations 
-		ORDER BY executed_at DESC 
-		LIMIT 1
-	`).Scan(&version, &description, &module, &filePath)
+// revertMigration runs the DOWN section of a migration and removes its record
+func (m *MigrationManager) revertMigration(migration Migration) error {
+	if migration.NoTransaction {
+		if err := m.execWithoutTransaction(migration.DownSQL); err != nil {
+			return fmt.Errorf("failed to execute rollback SQL: %w", err)
+		}
 
-	if err == sql.ErrNoRows {
-		log.Println("No migrations to rollback")
-		return nil
-	}
-	if err != nil {
-		return fmt.Errorf("failed to get last migration: %w", err)
-	}
+		if _, err := m.db.Exec("DELETE FROM public.migrations WHERE version = $1", migration.Version); err != nil {
+			return fmt.Errorf("failed to remove migration record: %w", err)
+		}
 
-	// Load migration file to get DOWN SQL
-	migration, err := m.parseMigrationFile(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to parse migration file for rollback: %w", err)
+		log.Printf("Migration %s rolled back successfully: %s [%s]", migration.Version, migration.Description, migration.Module)
+		return nil
 	}
 
 	// Execute rollback
@@ -323,6 +610,10 @@ func (m *MigrationManager) Rollback() error {
 	}
 	defer tx.Rollback()
 
+	if _, err := tx.Exec("SET LOCAL statement_timeout = 0"); err != nil {
+		return fmt.Errorf("failed to lift statement timeout: %w", err)
+	}
+
 	// Execute down migration
 	if strings.TrimSpace(migration.DownSQL) != "" {
 		if _, err := tx.Exec(migration.DownSQL); err != nil {
@@ -331,7 +622,7 @@ func (m *MigrationManager) Rollback() error {
 	}
 
 	// Remove migration record
-	if _, err := tx.Exec("DELETE FROM public.migrations WHERE version = $1", version); err != nil {
+	if _, err := tx.Exec("DELETE FROM public.migrations WHERE version = $1", migration.Version); err != nil {
 		return fmt.Errorf("failed to remove migration record: %w", err)
 	}
 
@@ -339,74 +630,327 @@ func (m *MigrationManager) Rollback() error {
 		return fmt.Errorf("failed to commit rollback: %w", err)
 	}
 
-	log.Printf("Migration %s rolled back successfully: %s [%s]", version, description, module)
+	log.Printf("Migration %s rolled back successfully: %s [%s]", migration.Version, migration.Description, migration.Module)
 	return nil
 }
 
-// GetMigrationStatus returns current migration status
-func (m *MigrationManager) GetMigrationStatus() ([]map[string]interface{}, error) {
-	rows, err := m.db.Query(`
-		SELECT version, description, module, executed_at 
-		FROM public.migrations 
-		ORDER BY version ASC
-	`)
+// Rollback rolls back the last migration
+func (m *MigrationManager) Rollback() error {
+	return m.RollbackSteps(1)
+}
+
+// MigrationStep is one migration to apply or roll back
+type MigrationStep struct {
+	Migration Migration
+	// Down rolls the migration back instead of applying it
+	Down bool
+}
+
+// String describes the step for printing a plan
+func (s MigrationStep) String() string {
+	direction := "up"
+	if s.Down {
+		direction = "down"
+	}
+	return fmt.Sprintf("%-4s %s %s [%s]", direction, s.Migration.Version, s.Migration.Description, s.Migration.Module)
+}
+
+// SetDryRun makes MigrateTo and RollbackSteps log their plan without
+// executing it
+func (m *MigrationManager) SetDryRun(dryRun bool) {
+	m.dryRun = dryRun
+}
+
+// MigrateTo applies or rolls back migrations until version is the latest
+// applied one. Version "0" rolls back every migration.
+func (m *MigrationManager) MigrateTo(version string) error {
+	return m.withPlanLock(func() error {
+		steps, err := m.PlanMigrateTo(version)
+		if err != nil {
+			return err
+		}
+		return m.executePlan(steps)
+	})
+}
+
+// RollbackSteps rolls back the last n applied migrations, newest first
+func (m *MigrationManager) RollbackSteps(n int) error {
+	return m.withPlanLock(func() error {
+		steps, err := m.PlanRollback(n)
+		if err != nil {
+			return err
+		}
+		return m.executePlan(steps)
+	})
+}
+
+// withPlanLock runs fn under the migration lock unless this is a dry run,
+// which changes nothing
+func (m *MigrationManager) withPlanLock(fn func() error) error {
+	if m.dryRun {
+		return fn()
+	}
+	return m.withLock(fn)
+}
+
+// PlanMigrateTo computes the steps MigrateTo takes: applied migrations
+// newer than version are rolled back newest first, then pending ones up to
+// version are applied oldest first
+func (m *MigrationManager) PlanMigrateTo(version string) ([]MigrationStep, error) {
+	target, err := strconv.Atoi(version)
+	if err != nil || target < 0 {
+		return nil, fmt.Errorf("invalid migration version %q", version)
+	}
+
+	migrations, applied, err := m.loadPlanState()
 	if err != nil {
-		return nil, fmt.Errorf("failed to query migrations: %w", err)
+		return nil, err
+	}
+
+	var steps []MigrationStep
+	for _, v := range applied {
+		if v <= target {
+			break
+		}
+		step, err := rollbackStep(migrations, v)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+
+	found := target == 0
+	isApplied := make(map[int]bool, len(applied))
+	for _, v := range applied {
+		isApplied[v] = true
+	}
+	for _, migration := range migrations {
+		v, _ := strconv.Atoi(migration.Version)
+		if v == target {
+			found = true
+		}
+		if v <= target && !isApplied[v] {
+			steps = append(steps, MigrationStep{Migration: migration})
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("migration version %s not found", version)
+	}
+
+	return steps, nil
+}
+
+// PlanRollback computes the steps RollbackSteps takes
+func (m *MigrationManager) PlanRollback(n int) ([]MigrationStep, error) {
+	if n < 1 {
+		return nil, errors.New("steps must be at least 1")
+	}
+
+	migrations, applied, err := m.loadPlanState()
+	if err != nil {
+		return nil, err
+	}
+	if n > len(applied) {
+		return nil, fmt.Errorf("cannot roll back %d migrations, only %d are applied", n, len(applied))
+	}
+
+	steps := make([]MigrationStep, 0, n)
+	for _, v := range applied[:n] {
+		step, err := rollbackStep(migrations, v)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+
+	return steps, nil
+}
+
+// rollbackStep finds the file of an applied version to roll it back
+func rollbackStep(migrations []Migration, version int) (MigrationStep, error) {
+	for _, migration := range migrations {
+		if v, _ := strconv.Atoi(migration.Version); v == version {
+			return MigrationStep{Migration: migration, Down: true}, nil
+		}
+	}
+	return MigrationStep{}, fmt.Errorf("migration %03d is applied but has no file to roll it back", version)
+}
+
+// loadPlanState loads the migration files sorted by version and the
+// applied versions, newest first
+func (m *MigrationManager) loadPlanState() ([]Migration, []int, error) {
+	if err := m.createMigrationsTable(); err != nil {
+		return nil, nil, fmt.Errorf("failed to create migrations table: %w", err)
+	}
+
+	migrations, err := m.loadMigrationsFromFiles()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+	sortMigrations(migrations)
+
+	rows, err := m.db.Query("SELECT CAST(version AS INTEGER) FROM public.migrations ORDER BY 1 DESC")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query migrations: %w", err)
 	}
 	defer rows.Close()
 
-	var status []map[string]interface{}
+	var applied []int
 	for rows.Next() {
-		var version, description, module, executedAt string
-		if err := rows.Scan(&version, &description, &module, &executedAt); err != nil {
-			return nil, fmt.Errorf("failed to scan row: %w", err)
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan row: %w", err)
 		}
-
-		status = append(status, map[string]interface{}{
-			"version":     version,
-			"description": description,
-			"module":      module,
-			"executed_at": executedAt,
-		})
+		applied = append(applied, version)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to query migrations: %w", err)
 	}
 
-	return status, nil
+	return migrations, applied, nil
 }
 
-// createMigrationDirectories creates the migration directory structure
-func (m *MigrationManager) createMigrationDirectories() error {
-	dirs := []string{
-		filepath.Join(m.migrationsDir, "user_management"),
-		filepath.Join(m.migrationsDir, "sensor_data"),
-		filepath.Join(m.migrationsDir, "cross_module"),
+// executePlan logs the steps and runs them one by one, each in its own
+// transaction unless the migration opts out. Nothing runs in dry-run mode.
+func (m *MigrationManager) executePlan(steps []MigrationStep) error {
+	if len(steps) == 0 {
+		log.Println("Nothing to migrate")
+		return nil
+	}
+
+	log.Printf("Migration plan (%d steps):", len(steps))
+	for _, step := range steps {
+		log.Printf("  %s", step)
+	}
+	if m.dryRun {
+		log.Println("Dry run, nothing executed")
+		return nil
 	}
 
-	for _, dir := range dirs {
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	for _, step := range steps {
+		var err error
+		if step.Down {
+			err = m.revertMigration(step.Migration)
+		} else {
+			err = m.applyMigration(step.Migration)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to execute step %q: %w", step, err)
 		}
 	}
 
 	return nil
 }
 
-// createDefaultMigrationFiles creates default migration files if they don't exist
-func (m *MigrationManager) createDefaultMigrationFiles() error {
-	log.Println("Creating default migration files...")
+// MigrationStatus describes one migration known to the database, the
+// migration files, or both
+type MigrationStatus struct {
+	Version     string
+	Description string
+	Module      string
+	// FilePath is empty when an applied migration's file is gone
+	FilePath string
+	Applied  bool
+	// ExecutedAt is the zero time for pending migrations
+	ExecutedAt time.Time
+	// FileStatus is "ok", "edited" when the file no longer matches the
+	// recorded checksum, "missing" when the file is gone, "unverified" when
+	// no checksum was recorded, or "pending" when it has not been applied
+	FileStatus string
+}
 
-	// This method would create the actual .sql files
-	// For now, we'll just create empty directories and let user create files manually
-	log.Println("Migration directories created. Please add your .sql migration files.")
-	log.Println("Expected structure:")
-	log.Println("  database/migrations/user_management/001_create_schema.sql")
-	log.Println("  database/migrations/sensor_data/008_create_schema.sql")
-	log.Println("  etc...")
+// GetMigrationStatus returns the applied migrations merged with the pending
+// ones found in the migration files, ordered by version
+func (m *MigrationManager) GetMigrationStatus() ([]MigrationStatus, error) {
+	if err := m.createMigrationsTable(); err != nil {
+		return nil, fmt.Errorf("failed to create migrations table: %w", err)
+	}
 
-	return nil
+	migrations, err := m.loadMigrationsFromFiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+	files := make(map[string]Migration, len(migrations))
+	for _, migration := range migrations {
+		files[migration.Version] = migration
+	}
+
+	rows, err := m.db.Query(`
+		SELECT version, description, module, executed_at, checksum
+		FROM public.migrations 
+		ORDER BY version ASC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query migrations: %w", err)
+	}
+	defer rows.Close()
+
+	var status []MigrationStatus
+	applied := make(map[string]bool)
+	for rows.Next() {
+		var s MigrationStatus
+		var sum sql.NullString
+		if err := rows.Scan(&s.Version, &s.Description, &s.Module, &s.ExecutedAt, &sum); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		s.Applied = true
+		applied[s.Version] = true
+
+		s.FileStatus = "ok"
+		file, ok := files[s.Version]
+		switch {
+		case !ok:
+			s.FileStatus = "missing"
+		case !sum.Valid:
+			s.FileStatus = "unverified"
+		case sum.String != file.Checksum:
+			s.FileStatus = "edited"
+		}
+		if ok {
+			s.FilePath = file.FilePath
+		}
+
+		status = append(status, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	for _, migration := range migrations {
+		if applied[migration.Version] {
+			continue
+		}
+		status = append(status, MigrationStatus{
+			Version:     migration.Version,
+			Description: migration.Description,
+			Module:      migration.Module,
+			FilePath:    migration.FilePath,
+			FileStatus:  "pending",
+		})
+	}
+
+	sort.SliceStable(status, func(i, j int) bool {
+		vi, _ := strconv.Atoi(status[i].Version)
+		vj, _ := strconv.Atoi(status[j].Version)
+		return vi < vj
+	})
+
+	return status, nil
 }
 
-// CreateMigrationFile creates a new migration file template
+// CreateMigrationFile creates a new migration file template in the module's
+// directory under the migrations directory on disk
 func (m *MigrationManager) CreateMigrationFile(module, description string) error {
+	description = strings.Join(strings.Fields(description), " ")
+	if description == "" {
+		return errors.New("description is required")
+	}
+
+	moduleDir := filepath.Join(m.migrationsDir, module)
+	if info, err := os.Stat(moduleDir); err != nil || !info.IsDir() || module != filepath.Base(module) {
+		return fmt.Errorf("unknown migration module %q: no directory %s", module, moduleDir)
+	}
+
 	// Get next version number
 	nextVersion, err := m.getNextVersion()
 	if err != nil {
@@ -414,8 +958,9 @@ func (m *MigrationManager) CreateMigrationFile(module, description string) error
 	}
 
 	// Create filename
-	filename := fmt.Sprintf("%03d_%s.sql", nextVersion, strings.ReplaceAll(description, " ", "_"))
-	filePath := filepath.Join(m.migrationsDir, module, filename)
+	name := strings.ToLower(strings.ReplaceAll(description, " ", "_"))
+	filename := fmt.Sprintf("%03d_%s.sql", nextVersion, name)
+	filePath := filepath.Join(moduleDir, filename)
 
 	// Create file content template
 	template := fmt.Sprintf(`-- Migration: %s
@@ -431,22 +976,67 @@ func (m *MigrationManager) CreateMigrationFile(module, description string) error
 
 `, filename, module, description)
 
-	// Write file
-	if err := os.WriteFile(filePath, []byte(template), 0644); err != nil {
+	// Write file, never replacing an existing one
+	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
 		return fmt.Errorf("failed to create migration file: %w", err)
 	}
+	if _, err := file.WriteString(template); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write migration file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write migration file: %w", err)
+	}
 
 	log.Printf("Migration file created: %s", filePath)
 	return nil
 }
 
-// getNextVersion returns the next available version number
+// getNextVersion returns the next available version number. Versions are
+// shared by all modules, so it follows the highest one among the files on
+// disk and, when there is a database, the applied migrations.
 func (m *MigrationManager) getNextVersion() (int, error) {
 	var maxVersion int
-	err := m.db.QueryRow("SELECT COALESCE(MAX(CAST(version AS INTEGER)), 0) FROM public.migrations").Scan(&maxVersion)
-	if err != nil && err != sql.ErrNoRows {
+	err := filepath.WalkDir(m.migrationsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".sql") {
+			return nil
+		}
+
+		version, err := strconv.Atoi(strings.SplitN(d.Name(), "_", 2)[0])
+		if err == nil && version > maxVersion {
+			maxVersion = version
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to read migration directory: %w", err)
+	}
+
+	if m.db == nil {
+		return maxVersion + 1, nil
+	}
+
+	// The migrations table does not exist before the first run
+	var tableExists bool
+	if err := m.db.QueryRow("SELECT to_regclass('public.migrations') IS NOT NULL").Scan(&tableExists); err != nil {
+		return 0, fmt.Errorf("failed to check migrations table: %w", err)
+	}
+	if !tableExists {
+		return maxVersion + 1, nil
+	}
+
+	var appliedVersion int
+	err = m.db.QueryRow("SELECT COALESCE(MAX(CAST(version AS INTEGER)), 0) FROM public.migrations").Scan(&appliedVersion)
+	if err != nil {
 		return 0, fmt.Errorf("failed to get max version: %w", err)
 	}
+	if appliedVersion > maxVersion {
+		maxVersion = appliedVersion
+	}
 
 	return maxVersion + 1, nil
 }
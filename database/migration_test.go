@@ -0,0 +1,95 @@
+package database_test
+
+import (
+	"sync"
+	"testing"
+	"user-management/database"
+	"user-management/database/dbtest"
+)
+
+// TestRunEmbeddedMigrations applies the embedded migrations to an empty
+// database; running them again is a no-op
+func TestRunEmbeddedMigrations(t *testing.T) {
+	db := dbtest.New(t)
+	manager := database.NewMigrationManager(db, "")
+
+	if err := manager.RunMigrations(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, table := range []string{"user_management.users", "sensor_data.sensors", "sensor_data.sensor_readings"} {
+		var exists bool
+		if err := db.QueryRow("SELECT to_regclass($1) IS NOT NULL", table).Scan(&exists); err != nil {
+			t.Fatal(err)
+		}
+		if !exists {
+			t.Errorf("table %s was not created", table)
+		}
+	}
+
+	var applied int
+	if err := db.QueryRow("SELECT COUNT(*) FROM public.migrations").Scan(&applied); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := manager.RunMigrations(); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+
+	var reapplied int
+	if err := db.QueryRow("SELECT COUNT(*) FROM public.migrations").Scan(&reapplied); err != nil {
+		t.Fatal(err)
+	}
+	if reapplied != applied {
+		t.Errorf("second run recorded %d migrations, want %d", reapplied, applied)
+	}
+}
+
+// TestConcurrentMigrations runs two managers at once, as two instances
+// starting together would; the lock makes them take turns
+func TestConcurrentMigrations(t *testing.T) {
+	dbURL := dbtest.URL(t)
+
+	managers := []*database.MigrationManager{
+		database.NewMigrationManager(dbtest.Open(t, dbURL), ""),
+		database.NewMigrationManager(dbtest.Open(t, dbURL), ""),
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(managers))
+	for i, manager := range managers {
+		wg.Add(1)
+		go func(i int, manager *database.MigrationManager) {
+			defer wg.Done()
+			errs[i] = manager.RunMigrations()
+		}(i, manager)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("manager %d: %v", i, err)
+		}
+	}
+
+	statuses, err := managers[0].GetMigrationStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if !status.Applied {
+			t.Errorf("migration %s was not applied", status.Version)
+		}
+	}
+}
+
+// TestMigrationsNeedTwoConnections rejects a single-connection pool instead
+// of waiting forever for the connection the lock holds
+func TestMigrationsNeedTwoConnections(t *testing.T) {
+	db := dbtest.New(t)
+	db.SetMaxOpenConns(1)
+
+	if err := database.NewMigrationManager(db, "").RunMigrations(); err == nil {
+		t.Fatal("expected an error with max_open_conns of 1")
+	}
+}
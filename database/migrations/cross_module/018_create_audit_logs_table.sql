@@ -0,0 +1,22 @@
+-- Migration: 018_create_audit_logs_table.sql
+-- Module: cross_module
+-- Description: Create audit_logs table for privileged operations
+
+-- UP
+CREATE TABLE IF NOT EXISTS user_management.audit_logs (
+    id BIGSERIAL PRIMARY KEY,
+    actor_id INTEGER REFERENCES user_management.users(id) ON DELETE SET NULL,
+    action VARCHAR(100) NOT NULL,
+    resource_type VARCHAR(50) NOT NULL,
+    resource_id VARCHAR(100),
+    changes JSONB,
+    ip_address VARCHAR(45),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_actor ON user_management.audit_logs(actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_resource ON user_management.audit_logs(resource_type, resource_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created ON user_management.audit_logs(created_at DESC);
+
+-- DOWN
+DROP TABLE IF EXISTS user_management.audit_logs CASCADE;
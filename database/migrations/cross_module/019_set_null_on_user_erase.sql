@@ -0,0 +1,25 @@
+-- Migration: 019_set_null_on_user_erase.sql
+-- Module: cross_module
+-- Description: Keep sensors and role assignments when the referenced user is erased
+
+-- UP
+ALTER TABLE sensor_data.sensors DROP CONSTRAINT IF EXISTS sensors_created_by_fkey;
+ALTER TABLE sensor_data.sensors
+    ADD CONSTRAINT sensors_created_by_fkey FOREIGN KEY (created_by)
+    REFERENCES user_management.users(id) ON DELETE SET NULL;
+
+ALTER TABLE user_management.user_roles DROP CONSTRAINT IF EXISTS user_roles_assigned_by_fkey;
+ALTER TABLE user_management.user_roles
+    ADD CONSTRAINT user_roles_assigned_by_fkey FOREIGN KEY (assigned_by)
+    REFERENCES user_management.users(id) ON DELETE SET NULL;
+
+-- DOWN
+ALTER TABLE user_management.user_roles DROP CONSTRAINT IF EXISTS user_roles_assigned_by_fkey;
+ALTER TABLE user_management.user_roles
+    ADD CONSTRAINT user_roles_assigned_by_fkey FOREIGN KEY (assigned_by)
+    REFERENCES user_management.users(id);
+
+ALTER TABLE sensor_data.sensors DROP CONSTRAINT IF EXISTS sensors_created_by_fkey;
+ALTER TABLE sensor_data.sensors
+    ADD CONSTRAINT sensors_created_by_fkey FOREIGN KEY (created_by)
+    REFERENCES user_management.users(id);
@@ -0,0 +1,60 @@
+-- Migration: 023_add_organizations.sql
+-- Module: cross_module
+-- Description: Add organizations and scope users, sensors and locations to them
+
+-- UP
+CREATE TABLE IF NOT EXISTS user_management.organizations (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) UNIQUE NOT NULL,
+    is_active BOOLEAN DEFAULT true,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Existing data moves into the default organization, which keeps ID 1
+INSERT INTO user_management.organizations (id, name) VALUES (1, 'Default')
+ON CONFLICT DO NOTHING;
+SELECT setval(
+    pg_get_serial_sequence('user_management.organizations', 'id'),
+    GREATEST((SELECT MAX(id) FROM user_management.organizations), 1)
+);
+
+ALTER TABLE user_management.users
+    ADD COLUMN IF NOT EXISTS organization_id INTEGER NOT NULL DEFAULT 1
+    REFERENCES user_management.organizations(id);
+ALTER TABLE sensor_data.sensors
+    ADD COLUMN IF NOT EXISTS organization_id INTEGER NOT NULL DEFAULT 1
+    REFERENCES user_management.organizations(id);
+ALTER TABLE sensor_data.locations
+    ADD COLUMN IF NOT EXISTS organization_id INTEGER NOT NULL DEFAULT 1
+    REFERENCES user_management.organizations(id);
+
+CREATE INDEX IF NOT EXISTS idx_users_organization_id ON user_management.users(organization_id);
+CREATE INDEX IF NOT EXISTS idx_sensors_organization_id ON sensor_data.sensors(organization_id);
+CREATE INDEX IF NOT EXISTS idx_locations_organization_id ON sensor_data.locations(organization_id);
+
+-- Super admins can access every organization
+INSERT INTO user_management.roles (name, description) VALUES
+    ('super_admin', 'Administrator with access to all organizations')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO user_management.role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM user_management.roles r, user_management.permissions p
+WHERE r.name = 'super_admin' AND p.name = 'all:manage'
+ON CONFLICT DO NOTHING;
+
+-- DOWN
+DELETE FROM user_management.role_permissions WHERE role_id IN (
+    SELECT id FROM user_management.roles WHERE name = 'super_admin'
+);
+DELETE FROM user_management.user_roles WHERE role_id IN (
+    SELECT id FROM user_management.roles WHERE name = 'super_admin'
+);
+DELETE FROM user_management.roles WHERE name = 'super_admin';
+
+ALTER TABLE sensor_data.locations DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE sensor_data.sensors DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE user_management.users DROP COLUMN IF EXISTS organization_id;
+
+DROP TABLE IF EXISTS user_management.organizations;
@@ -0,0 +1,33 @@
+-- Migration: 030_create_sensor_access_table.sql
+-- Module: cross_module
+-- Description: Grant users or roles read or write access to sensors and locations
+
+-- UP
+-- A grant covers a single sensor or every sensor at a location, and is given
+-- to a single user or to everyone holding a role. Sensors without any grant
+-- stay visible to every user with the sensors read permission.
+CREATE TABLE IF NOT EXISTS sensor_data.sensor_access (
+    id SERIAL PRIMARY KEY,
+    sensor_id INTEGER REFERENCES sensor_data.sensors(id) ON DELETE CASCADE,
+    location_id INTEGER REFERENCES sensor_data.locations(id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES user_management.users(id) ON DELETE CASCADE,
+    role_id INTEGER REFERENCES user_management.roles(id) ON DELETE CASCADE,
+    access_level VARCHAR(10) NOT NULL CHECK (access_level IN ('read', 'write')),
+    granted_by INTEGER REFERENCES user_management.users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((sensor_id IS NULL) <> (location_id IS NULL)),
+    CHECK ((user_id IS NULL) <> (role_id IS NULL))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sensor_access_unique ON sensor_data.sensor_access(
+    COALESCE(sensor_id, 0), COALESCE(location_id, 0), COALESCE(user_id, 0), COALESCE(role_id, 0)
+);
+CREATE INDEX IF NOT EXISTS idx_sensor_access_sensor_id ON sensor_data.sensor_access(sensor_id);
+CREATE INDEX IF NOT EXISTS idx_sensor_access_location_id ON sensor_data.sensor_access(location_id);
+CREATE INDEX IF NOT EXISTS idx_sensor_access_user_id ON sensor_data.sensor_access(user_id);
+CREATE INDEX IF NOT EXISTS idx_sensor_access_role_id ON sensor_data.sensor_access(role_id);
+CREATE INDEX IF NOT EXISTS idx_sensors_created_by ON sensor_data.sensors(created_by);
+
+-- DOWN
+DROP INDEX IF EXISTS sensor_data.idx_sensors_created_by;
+DROP TABLE IF EXISTS sensor_data.sensor_access;
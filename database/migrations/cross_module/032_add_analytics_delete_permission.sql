@@ -0,0 +1,20 @@
+-- Migration: 032_add_analytics_delete_permission.sql
+-- Module: cross_module
+-- Description: Add the permission to purge old sensor readings
+
+-- UP
+INSERT INTO user_management.permissions (name, description, resource, action) VALUES
+    ('analytics:delete', 'Purge old sensor readings', 'analytics', 'delete')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO user_management.role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM user_management.roles r, user_management.permissions p
+WHERE r.name = 'admin' AND p.name = 'analytics:delete'
+ON CONFLICT DO NOTHING;
+
+-- DOWN
+DELETE FROM user_management.role_permissions WHERE permission_id IN (
+    SELECT id FROM user_management.permissions WHERE name = 'analytics:delete'
+);
+DELETE FROM user_management.permissions WHERE name = 'analytics:delete';
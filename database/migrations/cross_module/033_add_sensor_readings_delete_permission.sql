@@ -0,0 +1,20 @@
+-- Migration: 033_add_sensor_readings_delete_permission.sql
+-- Module: cross_module
+-- Description: Add the permission to delete sensor readings in a time window
+
+-- UP
+INSERT INTO user_management.permissions (name, description, resource, action) VALUES
+    ('sensor_readings:delete', 'Delete sensor readings', 'sensor_readings', 'delete')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO user_management.role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM user_management.roles r, user_management.permissions p
+WHERE r.name = 'admin' AND p.name = 'sensor_readings:delete'
+ON CONFLICT DO NOTHING;
+
+-- DOWN
+DELETE FROM user_management.role_permissions WHERE permission_id IN (
+    SELECT id FROM user_management.permissions WHERE name = 'sensor_readings:delete'
+);
+DELETE FROM user_management.permissions WHERE name = 'sensor_readings:delete';
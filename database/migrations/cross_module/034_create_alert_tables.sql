@@ -0,0 +1,76 @@
+-- Migration: 034_create_alert_tables.sql
+-- Module: cross_module
+-- Description: Create threshold alert rules and the alerts they trigger
+
+-- UP
+-- A rule watches a single sensor or every sensor of a type within its
+-- organization. gt and lt compare against threshold, outside_range against
+-- min_value and max_value.
+CREATE TABLE IF NOT EXISTS sensor_data.alert_rules (
+    id SERIAL PRIMARY KEY,
+    organization_id INTEGER NOT NULL REFERENCES user_management.organizations(id),
+    sensor_id INTEGER REFERENCES sensor_data.sensors(id) ON DELETE CASCADE,
+    sensor_type_id INTEGER REFERENCES sensor_data.sensor_types(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    condition VARCHAR(20) NOT NULL CHECK (condition IN ('gt', 'lt', 'outside_range')),
+    threshold DECIMAL(10,4),
+    min_value DECIMAL(10,4),
+    max_value DECIMAL(10,4),
+    consecutive_readings INTEGER NOT NULL DEFAULT 1 CHECK (consecutive_readings > 0),
+    enabled BOOLEAN DEFAULT true,
+    created_by INTEGER REFERENCES user_management.users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((sensor_id IS NULL) <> (sensor_type_id IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_rules_organization_id ON sensor_data.alert_rules(organization_id);
+CREATE INDEX IF NOT EXISTS idx_alert_rules_sensor_id ON sensor_data.alert_rules(sensor_id);
+CREATE INDEX IF NOT EXISTS idx_alert_rules_sensor_type_id ON sensor_data.alert_rules(sensor_type_id);
+
+CREATE TABLE IF NOT EXISTS sensor_data.alerts (
+    id BIGSERIAL PRIMARY KEY,
+    rule_id INTEGER NOT NULL REFERENCES sensor_data.alert_rules(id) ON DELETE CASCADE,
+    sensor_id INTEGER NOT NULL REFERENCES sensor_data.sensors(id) ON DELETE CASCADE,
+    organization_id INTEGER NOT NULL REFERENCES user_management.organizations(id),
+    state VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (state IN ('open', 'acknowledged', 'resolved')),
+    value DECIMAL(10,4) NOT NULL,
+    message TEXT,
+    triggered_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    acknowledged_at TIMESTAMP,
+    acknowledged_by INTEGER REFERENCES user_management.users(id) ON DELETE SET NULL,
+    resolved_at TIMESTAMP
+);
+
+-- At most one unresolved alert per rule and sensor
+CREATE UNIQUE INDEX IF NOT EXISTS idx_alerts_unresolved ON sensor_data.alerts(rule_id, sensor_id)
+    WHERE state <> 'resolved';
+CREATE INDEX IF NOT EXISTS idx_alerts_organization_id ON sensor_data.alerts(organization_id);
+CREATE INDEX IF NOT EXISTS idx_alerts_sensor_id ON sensor_data.alerts(sensor_id);
+CREATE INDEX IF NOT EXISTS idx_alerts_state ON sensor_data.alerts(state);
+CREATE INDEX IF NOT EXISTS idx_alerts_triggered_at ON sensor_data.alerts(triggered_at);
+
+INSERT INTO user_management.permissions (name, description, resource, action) VALUES
+    ('alerts:read', 'Read alerts and alert rules', 'alerts', 'read'),
+    ('alerts:write', 'Manage alert rules and acknowledge alerts', 'alerts', 'write')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO user_management.role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM user_management.roles r, user_management.permissions p
+WHERE r.name = 'admin' AND p.resource = 'alerts'
+ON CONFLICT DO NOTHING;
+
+INSERT INTO user_management.role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM user_management.roles r, user_management.permissions p
+WHERE r.name = 'user' AND p.name = 'alerts:read'
+ON CONFLICT DO NOTHING;
+
+-- DOWN
+DELETE FROM user_management.role_permissions WHERE permission_id IN (
+    SELECT id FROM user_management.permissions WHERE resource = 'alerts'
+);
+DELETE FROM user_management.permissions WHERE resource = 'alerts';
+DROP TABLE IF EXISTS sensor_data.alerts;
+DROP TABLE IF EXISTS sensor_data.alert_rules;
@@ -0,0 +1,38 @@
+-- Migration: 035_create_webhook_tables.sql
+-- Module: cross_module
+-- Description: Create webhooks notified of sensor events and their delivery log
+
+-- UP
+CREATE TABLE IF NOT EXISTS sensor_data.webhooks (
+    id SERIAL PRIMARY KEY,
+    organization_id INTEGER NOT NULL REFERENCES user_management.organizations(id),
+    url VARCHAR(2048) NOT NULL,
+    -- Deliveries are signed with the secret, so it is stored as issued
+    secret VARCHAR(128) NOT NULL,
+    event_types TEXT[] NOT NULL,
+    enabled BOOLEAN DEFAULT true,
+    created_by INTEGER REFERENCES user_management.users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhooks_organization_id ON sensor_data.webhooks(organization_id);
+
+CREATE TABLE IF NOT EXISTS sensor_data.webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    webhook_id INTEGER NOT NULL REFERENCES sensor_data.webhooks(id) ON DELETE CASCADE,
+    event_type VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    response_status INTEGER,
+    error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON sensor_data.webhook_deliveries(webhook_id, created_at);
+
+-- DOWN
+DROP TABLE IF EXISTS sensor_data.webhook_deliveries;
+DROP TABLE IF EXISTS sensor_data.webhooks;
@@ -0,0 +1,28 @@
+-- Migration: 039_create_sensor_groups_tables.sql
+-- Module: cross_module
+-- Description: Create sensor groups spanning locations and their members
+
+-- UP
+CREATE TABLE IF NOT EXISTS sensor_data.sensor_groups (
+    id SERIAL PRIMARY KEY,
+    organization_id INTEGER NOT NULL REFERENCES user_management.organizations(id),
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_by INTEGER REFERENCES user_management.users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (organization_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS sensor_data.sensor_group_members (
+    group_id INTEGER NOT NULL REFERENCES sensor_data.sensor_groups(id) ON DELETE CASCADE,
+    sensor_id INTEGER NOT NULL REFERENCES sensor_data.sensors(id) ON DELETE CASCADE,
+    added_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (group_id, sensor_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sensor_group_members_sensor ON sensor_data.sensor_group_members(sensor_id);
+
+-- DOWN
+DROP TABLE IF EXISTS sensor_data.sensor_group_members;
+DROP TABLE IF EXISTS sensor_data.sensor_groups;
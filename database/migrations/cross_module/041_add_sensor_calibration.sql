@@ -0,0 +1,33 @@
+-- Migration: 041_add_sensor_calibration.sql
+-- Module: cross_module
+-- Description: Calibrate sensor values with an offset and scale and keep the raw values
+
+-- UP
+-- Readings store the corrected value raw_value * calibration_scale + calibration_offset
+ALTER TABLE sensor_data.sensors
+    ADD COLUMN IF NOT EXISTS calibration_offset DOUBLE PRECISION NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS calibration_scale DOUBLE PRECISION NOT NULL DEFAULT 1 CHECK (calibration_scale <> 0);
+
+-- Readings stored before calibration support have no raw value; it equals value
+ALTER TABLE sensor_data.sensor_readings
+    ADD COLUMN IF NOT EXISTS raw_value DECIMAL(15,4);
+
+CREATE TABLE IF NOT EXISTS sensor_data.sensor_calibrations (
+    id BIGSERIAL PRIMARY KEY,
+    sensor_id INTEGER NOT NULL REFERENCES sensor_data.sensors(id) ON DELETE CASCADE,
+    calibration_offset DOUBLE PRECISION NOT NULL,
+    calibration_scale DOUBLE PRECISION NOT NULL,
+    previous_offset DOUBLE PRECISION NOT NULL,
+    previous_scale DOUBLE PRECISION NOT NULL,
+    changed_by INTEGER REFERENCES user_management.users(id) ON DELETE SET NULL,
+    changed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_sensor_calibrations_sensor ON sensor_data.sensor_calibrations(sensor_id, changed_at DESC);
+
+-- DOWN
+DROP TABLE IF EXISTS sensor_data.sensor_calibrations;
+ALTER TABLE sensor_data.sensor_readings DROP COLUMN IF EXISTS raw_value;
+ALTER TABLE sensor_data.sensors
+    DROP COLUMN IF EXISTS calibration_scale,
+    DROP COLUMN IF EXISTS calibration_offset;
@@ -0,0 +1,20 @@
+-- Migration: 047_create_sensor_commands_table.sql
+-- Module: cross_module
+-- Description: Record the commands operators send to devices over MQTT
+
+-- UP
+CREATE TABLE IF NOT EXISTS sensor_data.sensor_commands (
+    id BIGSERIAL PRIMARY KEY,
+    sensor_id INTEGER NOT NULL REFERENCES sensor_data.sensors(id) ON DELETE CASCADE,
+    command_type VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed')),
+    sent_by INTEGER REFERENCES user_management.users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_sensor_commands_sensor ON sensor_data.sensor_commands(sensor_id, created_at DESC);
+
+-- DOWN
+DROP TABLE IF EXISTS sensor_data.sensor_commands;
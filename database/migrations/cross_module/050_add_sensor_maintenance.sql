@@ -0,0 +1,23 @@
+-- Migration: 050_add_sensor_maintenance.sql
+-- Module: cross_module
+-- Description: Put sensors in maintenance so they are not reported offline or unhealthy
+
+-- UP
+-- Sensors are in maintenance until maintenance_until passes
+ALTER TABLE sensor_data.sensors
+    ADD COLUMN IF NOT EXISTS maintenance_until TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS sensor_data.sensor_maintenance_log (
+    id BIGSERIAL PRIMARY KEY,
+    sensor_id INTEGER NOT NULL REFERENCES sensor_data.sensors(id) ON DELETE CASCADE,
+    maintenance_until TIMESTAMP,
+    reason TEXT NOT NULL DEFAULT '',
+    set_by INTEGER REFERENCES user_management.users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_sensor_maintenance_log_sensor ON sensor_data.sensor_maintenance_log(sensor_id, created_at DESC);
+
+-- DOWN
+DROP TABLE IF EXISTS sensor_data.sensor_maintenance_log;
+ALTER TABLE sensor_data.sensors DROP COLUMN IF EXISTS maintenance_until;
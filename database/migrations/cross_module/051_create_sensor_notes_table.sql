@@ -0,0 +1,17 @@
+-- Migration: 051_create_sensor_notes_table.sql
+-- Module: cross_module
+-- Description: Let technicians leave notes on sensors
+
+-- UP
+CREATE TABLE IF NOT EXISTS sensor_data.sensor_notes (
+    id BIGSERIAL PRIMARY KEY,
+    sensor_id INTEGER NOT NULL REFERENCES sensor_data.sensors(id) ON DELETE CASCADE,
+    author_id INTEGER REFERENCES user_management.users(id) ON DELETE SET NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_sensor_notes_sensor ON sensor_data.sensor_notes(sensor_id, created_at DESC);
+
+-- DOWN
+DROP TABLE IF EXISTS sensor_data.sensor_notes;
@@ -0,0 +1,19 @@
+-- Migration: 053_add_audit_log_organization.sql
+-- Module: cross_module
+-- Description: Scope audit log entries to the organization of their actor
+
+-- UP
+ALTER TABLE user_management.audit_logs
+    ADD COLUMN IF NOT EXISTS organization_id INTEGER
+    REFERENCES user_management.organizations(id);
+
+UPDATE user_management.audit_logs a
+SET organization_id = u.organization_id
+FROM user_management.users u
+WHERE a.actor_id = u.id AND a.organization_id IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_organization_id ON user_management.audit_logs(organization_id);
+
+-- DOWN
+DROP INDEX IF EXISTS user_management.idx_audit_logs_organization_id;
+ALTER TABLE user_management.audit_logs DROP COLUMN IF EXISTS organization_id;
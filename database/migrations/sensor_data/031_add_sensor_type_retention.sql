@@ -0,0 +1,11 @@
+-- Migration: 031_add_sensor_type_retention.sql
+-- Module: sensor_data
+-- Description: Let sensor types override how long their readings are kept
+
+-- UP
+-- NULL keeps readings for the configured default retention
+ALTER TABLE sensor_data.sensor_types
+    ADD COLUMN IF NOT EXISTS retention_days INTEGER CHECK (retention_days > 0);
+
+-- DOWN
+ALTER TABLE sensor_data.sensor_types DROP COLUMN IF EXISTS retention_days;
@@ -0,0 +1,17 @@
+-- Migration: 036_create_sensor_status_events_table.sql
+-- Module: sensor_data
+-- Description: Record sensors going offline and coming back online
+
+-- UP
+CREATE TABLE IF NOT EXISTS sensor_data.sensor_status_events (
+    id BIGSERIAL PRIMARY KEY,
+    sensor_id INTEGER NOT NULL REFERENCES sensor_data.sensors(id) ON DELETE CASCADE,
+    status VARCHAR(10) NOT NULL CHECK (status IN ('online', 'offline')),
+    last_reading_at TIMESTAMP,
+    occurred_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_sensor_status_events_sensor ON sensor_data.sensor_status_events(sensor_id, occurred_at DESC);
+
+-- DOWN
+DROP TABLE IF EXISTS sensor_data.sensor_status_events;
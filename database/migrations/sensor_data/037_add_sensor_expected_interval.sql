@@ -0,0 +1,10 @@
+-- Migration: 037_add_sensor_expected_interval.sql
+-- Module: sensor_data
+-- Description: Let each sensor declare how often it reports readings
+
+-- UP
+ALTER TABLE sensor_data.sensors
+    ADD COLUMN IF NOT EXISTS expected_interval_seconds INTEGER CHECK (expected_interval_seconds > 0);
+
+-- DOWN
+ALTER TABLE sensor_data.sensors DROP COLUMN IF EXISTS expected_interval_seconds;
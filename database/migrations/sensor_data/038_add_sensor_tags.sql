@@ -0,0 +1,13 @@
+-- Migration: 038_add_sensor_tags.sql
+-- Module: sensor_data
+-- Description: Tag sensors independently of their location and type
+
+-- UP
+ALTER TABLE sensor_data.sensors
+    ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx_sensors_tags ON sensor_data.sensors USING GIN (tags);
+
+-- DOWN
+DROP INDEX IF EXISTS sensor_data.idx_sensors_tags;
+ALTER TABLE sensor_data.sensors DROP COLUMN IF EXISTS tags;
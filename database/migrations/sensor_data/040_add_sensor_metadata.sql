@@ -0,0 +1,10 @@
+-- Migration: 040_add_sensor_metadata.sql
+-- Module: sensor_data
+-- Description: Store arbitrary JSON metadata such as install notes and asset tags on sensors
+
+-- UP
+ALTER TABLE sensor_data.sensors
+    ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}';
+
+-- DOWN
+ALTER TABLE sensor_data.sensors DROP COLUMN IF EXISTS metadata;
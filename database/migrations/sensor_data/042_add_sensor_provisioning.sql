@@ -0,0 +1,15 @@
+-- Migration: 042_add_sensor_provisioning.sql
+-- Module: sensor_data
+-- Description: Track sensors auto-provisioned from unknown devices until an admin approves them
+
+-- UP
+ALTER TABLE sensor_data.sensors
+    ADD COLUMN IF NOT EXISTS is_provisioned BOOLEAN NOT NULL DEFAULT true;
+
+-- Pending sensors are few, so the lists excluding them use a partial index
+CREATE INDEX IF NOT EXISTS idx_sensors_pending ON sensor_data.sensors(created_at)
+    WHERE is_provisioned = false;
+
+-- DOWN
+DROP INDEX IF EXISTS sensor_data.idx_sensors_pending;
+ALTER TABLE sensor_data.sensors DROP COLUMN IF EXISTS is_provisioned;
@@ -0,0 +1,10 @@
+-- Migration: 043_add_sensor_device_tokens.sql
+-- Module: sensor_data
+-- Description: Store hashed per-device tokens authenticating the public readings endpoints
+
+-- UP
+ALTER TABLE sensor_data.sensors
+    ADD COLUMN IF NOT EXISTS device_token_hash VARCHAR(64);
+
+-- DOWN
+ALTER TABLE sensor_data.sensors DROP COLUMN IF EXISTS device_token_hash;
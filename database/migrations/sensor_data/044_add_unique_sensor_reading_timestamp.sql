@@ -0,0 +1,18 @@
+-- Migration: 044_add_unique_sensor_reading_timestamp.sql
+-- Module: sensor_data
+-- Description: Reject duplicate readings of a sensor at the same timestamp so retried posts are stored once
+-- NO TRANSACTION
+
+-- UP
+-- Keep the first copy of readings stored more than once
+DELETE FROM sensor_data.sensor_readings a
+USING sensor_data.sensor_readings b
+WHERE a.sensor_id = b.sensor_id AND a.timestamp = b.timestamp AND a.id > b.id;
+
+-- Built concurrently because the table is large; a failed build leaves an
+-- invalid index that must be dropped before rerunning
+CREATE UNIQUE INDEX CONCURRENTLY IF NOT EXISTS idx_sensor_readings_sensor_timestamp_unique
+    ON sensor_data.sensor_readings(sensor_id, timestamp);
+
+-- DOWN
+DROP INDEX CONCURRENTLY IF EXISTS sensor_data.idx_sensor_readings_sensor_timestamp_unique;
@@ -0,0 +1,10 @@
+-- Migration: 045_add_sensor_ingest_rate.sql
+-- Module: sensor_data
+-- Description: Let high-frequency sensors override the configured ingest rate limit
+
+-- UP
+ALTER TABLE sensor_data.sensors
+    ADD COLUMN IF NOT EXISTS ingest_rate_per_minute INTEGER CHECK (ingest_rate_per_minute > 0);
+
+-- DOWN
+ALTER TABLE sensor_data.sensors DROP COLUMN IF EXISTS ingest_rate_per_minute;
@@ -0,0 +1,21 @@
+-- Migration: 046_add_sensor_type_formatting.sql
+-- Module: sensor_data
+-- Description: Store how sensor type values are formatted instead of hardcoding it per type name
+
+-- UP
+ALTER TABLE sensor_data.sensor_types
+    ADD COLUMN IF NOT EXISTS decimal_places INTEGER NOT NULL DEFAULT 2 CHECK (decimal_places BETWEEN 0 AND 6),
+    ADD COLUMN IF NOT EXISTS value_labels JSONB;
+
+-- Keep the formatting the built-in types had
+UPDATE sensor_data.sensor_types SET decimal_places = 1 WHERE name IN ('temperature', 'pressure');
+UPDATE sensor_data.sensor_types SET decimal_places = 0 WHERE name = 'humidity';
+UPDATE sensor_data.sensor_types
+SET decimal_places = 0,
+    value_labels = '{"0": "No motion", "1": "Motion detected"}'
+WHERE name = 'motion';
+
+-- DOWN
+ALTER TABLE sensor_data.sensor_types
+    DROP COLUMN IF EXISTS value_labels,
+    DROP COLUMN IF EXISTS decimal_places;
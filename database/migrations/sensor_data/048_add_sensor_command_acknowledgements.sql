@@ -0,0 +1,29 @@
+-- Migration: 048_add_sensor_command_acknowledgements.sql
+-- Module: sensor_data
+-- Description: Track device acknowledgements of commands and commands that time out
+
+-- UP
+-- Devices echo command_uuid in their acknowledgement; older commands have none
+ALTER TABLE sensor_data.sensor_commands
+    ADD COLUMN IF NOT EXISTS command_uuid UUID,
+    ADD COLUMN IF NOT EXISTS response JSONB,
+    ADD COLUMN IF NOT EXISTS acknowledged_at TIMESTAMP,
+    ALTER COLUMN status TYPE VARCHAR(20),
+    DROP CONSTRAINT IF EXISTS sensor_commands_status_check,
+    ADD CONSTRAINT sensor_commands_status_check
+        CHECK (status IN ('pending', 'sent', 'acknowledged', 'failed', 'timed_out'));
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sensor_commands_uuid ON sensor_data.sensor_commands(command_uuid);
+CREATE INDEX IF NOT EXISTS idx_sensor_commands_sent ON sensor_data.sensor_commands(sent_at) WHERE status = 'sent';
+
+-- DOWN
+DROP INDEX IF EXISTS sensor_data.idx_sensor_commands_sent;
+DROP INDEX IF EXISTS sensor_data.idx_sensor_commands_uuid;
+UPDATE sensor_data.sensor_commands SET status = 'sent' WHERE status IN ('acknowledged', 'timed_out');
+ALTER TABLE sensor_data.sensor_commands
+    DROP CONSTRAINT IF EXISTS sensor_commands_status_check,
+    ADD CONSTRAINT sensor_commands_status_check CHECK (status IN ('pending', 'sent', 'failed')),
+    ALTER COLUMN status TYPE VARCHAR(10),
+    DROP COLUMN IF EXISTS acknowledged_at,
+    DROP COLUMN IF EXISTS response,
+    DROP COLUMN IF EXISTS command_uuid;
@@ -0,0 +1,17 @@
+-- Migration: 049_create_sensor_firmware_history_table.sql
+-- Module: sensor_data
+-- Description: Record the firmware version changes of sensors
+
+-- UP
+CREATE TABLE IF NOT EXISTS sensor_data.sensor_firmware_history (
+    id BIGSERIAL PRIMARY KEY,
+    sensor_id INTEGER NOT NULL REFERENCES sensor_data.sensors(id) ON DELETE CASCADE,
+    previous_version VARCHAR(50),
+    firmware_version VARCHAR(50),
+    changed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_sensor_firmware_history_sensor ON sensor_data.sensor_firmware_history(sensor_id, changed_at DESC);
+
+-- DOWN
+DROP TABLE IF EXISTS sensor_data.sensor_firmware_history;
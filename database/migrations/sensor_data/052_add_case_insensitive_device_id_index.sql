@@ -0,0 +1,10 @@
+-- Migration: 052_add_case_insensitive_device_id_index.sql
+-- Module: sensor_data
+-- Description: Make sensor device IDs unique regardless of case
+
+-- UP
+-- Fails while device IDs differing only in case exist; rename one of each pair first
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sensors_device_id_upper ON sensor_data.sensors(UPPER(device_id));
+
+-- DOWN
+DROP INDEX IF EXISTS sensor_data.idx_sensors_device_id_upper;
@@ -0,0 +1,19 @@
+-- Migration: 016_create_password_resets_table.sql
+-- Module: user_management
+-- Description: Create password_resets table
+
+-- UP
+CREATE TABLE IF NOT EXISTS user_management.password_resets (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES user_management.users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_resets_user ON user_management.password_resets(user_id);
+CREATE INDEX IF NOT EXISTS idx_password_resets_expires ON user_management.password_resets(expires_at);
+
+-- DOWN
+DROP TABLE IF EXISTS user_management.password_resets CASCADE;
@@ -0,0 +1,23 @@
+-- Migration: 017_create_login_history_table.sql
+-- Module: user_management
+-- Description: Add last_login_at to users and create login_history table
+
+-- UP
+ALTER TABLE user_management.users ADD COLUMN IF NOT EXISTS last_login_at TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS user_management.login_history (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES user_management.users(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    success BOOLEAN NOT NULL,
+    failure_reason VARCHAR(100),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_history_user_time ON user_management.login_history(user_id, created_at DESC);
+
+-- DOWN
+DROP TABLE IF EXISTS user_management.login_history CASCADE;
+ALTER TABLE user_management.users DROP COLUMN IF EXISTS last_login_at;
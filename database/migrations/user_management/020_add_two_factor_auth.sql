@@ -0,0 +1,22 @@
+-- Migration: 020_add_two_factor_auth.sql
+-- Module: user_management
+-- Description: Add TOTP two-factor authentication columns and recovery codes table
+
+-- UP
+ALTER TABLE user_management.users ADD COLUMN IF NOT EXISTS totp_secret TEXT;
+ALTER TABLE user_management.users ADD COLUMN IF NOT EXISTS is_2fa_enabled BOOLEAN DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS user_management.recovery_codes (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES user_management.users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user ON user_management.recovery_codes(user_id);
+
+-- DOWN
+DROP TABLE IF EXISTS user_management.recovery_codes CASCADE;
+ALTER TABLE user_management.users DROP COLUMN IF EXISTS is_2fa_enabled;
+ALTER TABLE user_management.users DROP COLUMN IF EXISTS totp_secret;
@@ -0,0 +1,22 @@
+-- Migration: 021_create_refresh_tokens_table.sql
+-- Module: user_management
+-- Description: Create refresh_tokens table for server-side sessions
+
+-- UP
+CREATE TABLE IF NOT EXISTS user_management.refresh_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES user_management.users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    expires_at TIMESTAMP NOT NULL,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user ON user_management.refresh_tokens(user_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_hash ON user_management.refresh_tokens(token_hash);
+
+-- DOWN
+DROP TABLE IF EXISTS user_management.refresh_tokens CASCADE;
@@ -0,0 +1,22 @@
+-- Migration: 022_add_wildcard_permission.sql
+-- Module: user_management
+-- Description: Add wildcard all:manage permission and grant it to admin role
+
+-- UP
+-- A '*' resource or action matches any resource or action
+INSERT INTO user_management.permissions (name, description, resource, action) VALUES
+    ('all:manage', 'Full access to every resource and action', '*', '*')
+ON CONFLICT (name) DO NOTHING;
+
+-- Assign wildcard permission to admin role
+INSERT INTO user_management.role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM user_management.roles r, user_management.permissions p
+WHERE r.name = 'admin' AND p.name = 'all:manage'
+ON CONFLICT DO NOTHING;
+
+-- DOWN
+DELETE FROM user_management.role_permissions WHERE permission_id IN (
+    SELECT id FROM user_management.permissions WHERE name = 'all:manage'
+);
+DELETE FROM user_management.permissions WHERE name = 'all:manage';
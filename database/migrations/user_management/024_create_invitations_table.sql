@@ -0,0 +1,22 @@
+-- Migration: 024_create_invitations_table.sql
+-- Module: user_management
+-- Description: Create invitations table for invite-only registration
+
+-- UP
+CREATE TABLE IF NOT EXISTS user_management.invitations (
+    id SERIAL PRIMARY KEY,
+    email VARCHAR(255) NOT NULL,
+    organization_id INTEGER NOT NULL REFERENCES user_management.organizations(id),
+    role_ids INTEGER[] NOT NULL DEFAULT '{}',
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    invited_by INTEGER REFERENCES user_management.users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP NOT NULL,
+    accepted_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_invitations_email ON user_management.invitations(email);
+CREATE INDEX IF NOT EXISTS idx_invitations_expires ON user_management.invitations(expires_at);
+
+-- DOWN
+DROP TABLE IF EXISTS user_management.invitations CASCADE;
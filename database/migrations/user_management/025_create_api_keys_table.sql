@@ -0,0 +1,22 @@
+-- Migration: 025_create_api_keys_table.sql
+-- Module: user_management
+-- Description: Create api_keys table for machine-to-machine access
+
+-- UP
+CREATE TABLE IF NOT EXISTS user_management.api_keys (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES user_management.users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    key_prefix VARCHAR(16) NOT NULL,
+    key_hash VARCHAR(64) UNIQUE NOT NULL,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_user ON user_management.api_keys(user_id);
+
+-- DOWN
+DROP TABLE IF EXISTS user_management.api_keys CASCADE;
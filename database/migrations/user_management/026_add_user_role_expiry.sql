@@ -0,0 +1,13 @@
+-- Migration: 026_add_user_role_expiry.sql
+-- Module: user_management
+-- Description: Add optional expiry to role assignments
+
+-- UP
+ALTER TABLE user_management.user_roles ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_user_roles_expires ON user_management.user_roles(expires_at)
+    WHERE expires_at IS NOT NULL;
+
+-- DOWN
+DROP INDEX IF EXISTS user_management.idx_user_roles_expires;
+ALTER TABLE user_management.user_roles DROP COLUMN IF EXISTS expires_at;
@@ -0,0 +1,24 @@
+-- Migration: 027_add_email_verification.sql
+-- Module: user_management
+-- Description: Add email verification column and email_verifications table
+
+-- UP
+ALTER TABLE user_management.users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP;
+
+-- Accounts that existed before verification was introduced stay usable
+UPDATE user_management.users SET email_verified_at = created_at WHERE email_verified_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS user_management.email_verifications (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES user_management.users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_verifications_user ON user_management.email_verifications(user_id);
+
+-- DOWN
+DROP TABLE IF EXISTS user_management.email_verifications CASCADE;
+ALTER TABLE user_management.users DROP COLUMN IF EXISTS email_verified_at;
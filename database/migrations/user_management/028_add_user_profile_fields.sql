@@ -0,0 +1,15 @@
+-- Migration: 028_add_user_profile_fields.sql
+-- Module: user_management
+-- Description: Add phone, timezone, locale and avatar URL profile fields to users
+
+-- UP
+ALTER TABLE user_management.users ADD COLUMN IF NOT EXISTS phone VARCHAR(16) NOT NULL DEFAULT '';
+ALTER TABLE user_management.users ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE user_management.users ADD COLUMN IF NOT EXISTS locale VARCHAR(35) NOT NULL DEFAULT '';
+ALTER TABLE user_management.users ADD COLUMN IF NOT EXISTS avatar_url VARCHAR(2048) NOT NULL DEFAULT '';
+
+-- DOWN
+ALTER TABLE user_management.users DROP COLUMN IF EXISTS avatar_url;
+ALTER TABLE user_management.users DROP COLUMN IF EXISTS locale;
+ALTER TABLE user_management.users DROP COLUMN IF EXISTS timezone;
+ALTER TABLE user_management.users DROP COLUMN IF EXISTS phone;
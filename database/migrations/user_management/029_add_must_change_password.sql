@@ -0,0 +1,9 @@
+-- Migration: 029_add_must_change_password.sql
+-- Module: user_management
+-- Description: Add flag forcing a password change on next login
+
+-- UP
+ALTER TABLE user_management.users ADD COLUMN IF NOT EXISTS must_change_password BOOLEAN NOT NULL DEFAULT false;
+
+-- DOWN
+ALTER TABLE user_management.users DROP COLUMN IF EXISTS must_change_password;
@@ -0,0 +1,9 @@
+-- Migration: 054_add_totp_last_step.sql
+-- Module: user_management
+-- Description: Track the last accepted TOTP time step so codes cannot be replayed
+
+-- UP
+ALTER TABLE user_management.users ADD COLUMN IF NOT EXISTS totp_last_step BIGINT;
+
+-- DOWN
+ALTER TABLE user_management.users DROP COLUMN IF EXISTS totp_last_step;
@@ -0,0 +1,9 @@
+-- Migration: 055_normalize_invitation_emails.sql
+-- Module: user_management
+-- Description: Store invitation emails lower case like user emails
+
+-- UP
+UPDATE user_management.invitations SET email = LOWER(TRIM(email)) WHERE email <> LOWER(TRIM(email));
+
+-- DOWN
+-- The original case is not kept; nothing to undo
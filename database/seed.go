@@ -0,0 +1,143 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// Baseline role names; registration assigns seedUserRole to new accounts
+const (
+	seedAdminRole = "admin"
+	seedUserRole  = "user"
+)
+
+type seedRole struct {
+	name        string
+	description string
+}
+
+type seedPermission struct {
+	resource    string
+	action      string
+	description string
+	// userRole grants the permission to regular users as well as admins
+	userRole bool
+}
+
+type seedSensorType struct {
+	name        string
+	description string
+	unit        string
+	minValue    float64
+	maxValue    float64
+}
+
+var seedRoles = []seedRole{
+	{seedAdminRole, "System administrator with full access"},
+	{seedUserRole, "Regular user with limited access"},
+}
+
+// seedPermissions is the permission matrix the routes check with
+// RequirePermission
+var seedPermissions = []seedPermission{
+	{"sensors", "read", "Read sensor data", true},
+	{"sensors", "write", "Create and update sensors", false},
+	{"sensors", "delete", "Delete sensors", false},
+	{"sensor_readings", "read", "Read sensor readings", true},
+	{"sensor_readings", "write", "Create sensor readings", false},
+	{"sensor_readings", "delete", "Delete sensor readings", false},
+	{"analytics", "read", "Access analytics data", false},
+	{"analytics", "write", "Manage analytics data", false},
+	{"analytics", "delete", "Purge old sensor readings", false},
+	{"alerts", "read", "Read alerts and alert rules", true},
+	{"alerts", "write", "Manage alert rules and acknowledge alerts", false},
+	{"alerts", "delete", "Delete alert rules", false},
+}
+
+var seedSensorTypes = []seedSensorType{
+	{"temperature", "Temperature sensor", "°C", -50, 100},
+	{"humidity", "Humidity sensor", "%", 0, 100},
+	{"pressure", "Pressure sensor", "hPa", 800, 1200},
+	{"light", "Light intensity sensor", "lux", 0, 100000},
+	{"motion", "Motion detection sensor", "boolean", 0, 1},
+	{"co2", "Carbon dioxide sensor", "ppm", 0, 5000},
+	{"voltage", "Voltage sensor", "V", 0, 50},
+	{"current", "Current sensor", "A", 0, 100},
+	{"ph", "pH level sensor", "pH", 0, 14},
+	{"conductivity", "Electrical conductivity sensor", "µS/cm", 0, 10000},
+	{"flow", "Flow rate sensor", "L/min", 0, 1000},
+	{"level", "Water level sensor", "cm", 0, 500},
+}
+
+// Seed inserts the baseline roles, permissions, role grants and sensor types.
+// Existing rows are left untouched, so it is safe to run repeatedly.
+func Seed(db *sql.DB) error {
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, role := range seedRoles {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO user_management.roles (name, description)
+			VALUES ($1, $2)
+			ON CONFLICT (name) DO NOTHING`,
+			role.name, role.description,
+		); err != nil {
+			return fmt.Errorf("failed to seed role %s: %w", role.name, err)
+		}
+	}
+
+	for _, perm := range seedPermissions {
+		name := perm.resource + ":" + perm.action
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO user_management.permissions (name, description, resource, action)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (name) DO NOTHING`,
+			name, perm.description, perm.resource, perm.action,
+		); err != nil {
+			return fmt.Errorf("failed to seed permission %s: %w", name, err)
+		}
+
+		roles := []string{seedAdminRole}
+		if perm.userRole {
+			roles = append(roles, seedUserRole)
+		}
+		for _, role := range roles {
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO user_management.role_permissions (role_id, permission_id)
+				SELECT r.id, p.id
+				FROM user_management.roles r, user_management.permissions p
+				WHERE r.name = $1 AND p.name = $2
+				ON CONFLICT DO NOTHING`,
+				role, name,
+			); err != nil {
+				return fmt.Errorf("failed to grant %s to role %s: %w", name, role, err)
+			}
+		}
+	}
+
+	for _, st := range seedSensorTypes {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO sensor_data.sensor_types (name, description, unit, min_value, max_value)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (name) DO NOTHING`,
+			st.name, st.description, st.unit, st.minValue, st.maxValue,
+		); err != nil {
+			return fmt.Errorf("failed to seed sensor type %s: %w", st.name, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit seed data: %w", err)
+	}
+
+	log.Printf("Seeded %d roles, %d permissions and %d sensor types",
+		len(seedRoles), len(seedPermissions), len(seedSensorTypes))
+	return nil
+}
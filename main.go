@@ -2,19 +2,29 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 	"user-management/config"
 	"user-management/database"
+	"user-management/pkg/alert"
+	"user-management/pkg/audit"
+	"user-management/pkg/events"
+	"user-management/pkg/health"
 	"user-management/pkg/mqtt"
 	"user-management/pkg/sensor"
 	"user-management/pkg/user"
+	"user-management/pkg/webhook"
 	"user-management/shared/middleware"
+	"user-management/shared/openapi"
+	"user-management/shared/response"
 )
 
 func main() {
@@ -26,16 +36,94 @@ func main() {
 	defer db.Close()
 
 	// Run migrations
-	if err := db.RunMigrations(); err != nil {
+	if err := db.RunMigrations(&cfg.Database); err != nil {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
 
 	// Initialize services
 	userRepo := user.NewRepository(db.DB)
-	userService := user.NewService(userRepo, cfg.JWT.Secret, cfg.JWT.ExpireHours)
+
+	// Auth cache shared by the auth middleware and the user service, which invalidates it
+	var authCache *user.AuthCache
+	if !cfg.App.AuthCache.Disabled {
+		authCache = user.NewAuthCache(cfg.App.AuthCache.TTL)
+	}
+
+	userService, err := user.NewService(userRepo, user.NewLogMailer(), user.Config{
+		JWTSecret:           cfg.JWT.Secret,
+		JWTExpiryHours:      cfg.JWT.ExpireHours,
+		RefreshExpiryHours:  cfg.JWT.RefreshExpireHours,
+		JWTPrivateKeyPath:   cfg.JWT.PrivateKeyPath,
+		JWTPreviousKeyPaths: cfg.JWT.PreviousPublicKeyPaths,
+		EncryptionKey:       cfg.App.EncryptionKey,
+		BCryptCost:          cfg.App.BCryptCost,
+		PasswordPolicy: user.PasswordPolicy{
+			MinLength:     cfg.App.PasswordPolicy.MinLength,
+			RequireUpper:  cfg.App.PasswordPolicy.RequireUpper,
+			RequireLower:  cfg.App.PasswordPolicy.RequireLower,
+			RequireDigit:  cfg.App.PasswordPolicy.RequireDigit,
+			RequireSymbol: cfg.App.PasswordPolicy.RequireSymbol,
+			RejectEmail:   cfg.App.PasswordPolicy.RejectEmail,
+			DenyList:      cfg.App.PasswordPolicy.DenyList,
+		},
+		AuthCache:             authCache,
+		RegistrationMode:      cfg.App.RegistrationMode,
+		SkipEmailVerification: cfg.App.SkipEmailVerification,
+	})
+	if err != nil {
+		log.Fatalf("Failed to initialize user service: %v", err)
+	}
+
+	// Deliver sensor and alert events to the organizations' webhooks
+	webhookDispatcher := webhook.NewDispatcher(webhook.NewRepository(db.DB))
+	webhookDispatcher.Start()
+	defer webhookDispatcher.Stop()
+
+	// Sensor and alert events go to webhooks and to event stream clients
+	eventBus := events.NewBus(webhookDispatcher)
+
+	// Alert rules are evaluated whenever sensors receive readings
+	alertService := alert.NewService(alert.NewRepository(db.DB), eventBus)
+
+	if cfg.Sensors.AutoProvision && cfg.Sensors.ProvisionSensorType == "" {
+		log.Fatal("sensors.provision_sensor_type is required when sensors.auto_provision is enabled")
+	}
+
+	healthPolicy := sensorHealthPolicy(cfg.SensorHealth)
+	if err := healthPolicy.Validate(); err != nil {
+		log.Fatalf("Invalid sensor_health configuration: %v", err)
+	}
 
 	sensorRepo := sensor.NewRepository(db.DB)
-	sensorService := sensor.NewService(sensorRepo)
+	sensorService := sensor.NewService(sensorRepo, sensor.Config{
+		MaxReadingDeleteDays:       cfg.Sensors.MaxReadingDeleteDays,
+		Evaluator:                  alertService,
+		Notifier:                   eventBus,
+		OnlineThresholdMinutes:     cfg.Sensors.OnlineThresholdMinutes,
+		AutoProvision:              cfg.Sensors.AutoProvision,
+		ProvisionSensorType:        cfg.Sensors.ProvisionSensorType,
+		AllowUnauthenticatedIngest: cfg.Sensors.UnauthenticatedIngestAllowed(),
+		MaxBulkReadings:            cfg.Sensors.MaxBulkReadings,
+		IngestRatePerMinute:        cfg.RateLimit.Ingest.ReadingsPerMinute,
+		IngestBurst:                cfg.RateLimit.Ingest.Burst,
+		DashboardCacheSeconds:      cfg.Sensors.DashboardCacheSeconds,
+		Health:                     &healthPolicy,
+	})
+
+	// Purge sensor readings past their retention once a day
+	retentionWorker := sensor.NewRetentionWorker(sensorService, cfg.Sensors.ReadingRetentionDays)
+	retentionWorker.Start()
+	defer retentionWorker.Stop()
+
+	// Record sensors going offline and coming back online
+	statusWorker := sensor.NewStatusWorker(sensorService)
+	statusWorker.Start()
+	defer statusWorker.Stop()
+
+	// Time out device commands that are never acknowledged
+	commandTimeoutWorker := sensor.NewCommandTimeoutWorker(sensorService, cfg.Sensors.CommandTimeoutSeconds)
+	commandTimeoutWorker.Start()
+	defer commandTimeoutWorker.Stop()
 
 	// Initialize MQTT broker
 	mqttConfig := &mqtt.Config{
@@ -45,10 +133,16 @@ func main() {
 		Password: cfg.MQTT.Password,
 		ClientID: cfg.MQTT.ClientID,
 		QoS:      cfg.MQTT.QoS,
+
+		ConnectAttempts: cfg.MQTT.ConnectAttempts,
+		ConnectBackoff:  cfg.MQTT.ConnectBackoff,
 	}
 
 	mqttBroker := mqtt.NewMQTTBroker(mqttConfig, sensorService)
 
+	// Operators send commands to devices through the broker
+	sensorService.SetCommandPublisher(mqttBroker)
+
 	// Start MQTT broker
 	if err := mqttBroker.Start(); err != nil {
 		log.Printf("Warning: Failed to start MQTT broker: %v", err)
@@ -58,15 +152,23 @@ func main() {
 		defer mqttBroker.Stop()
 	}
 
+	// Client IPs come from forwarding headers only behind trusted proxies
+	if err := middleware.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatalf("Invalid server configuration: %v", err)
+	}
+
 	// Setup HTTP server
 	server := &http.Server{
 		Addr:         fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port),
-		Handler:      setupRoutes(db, cfg, userService, sensorService),
+		Handler:      setupRoutes(db, cfg, userService, sensorService, alertService, eventBus, authCache, mqttBroker),
 		ReadTimeout:  cfg.Server.ReadTimeout,
 		WriteTimeout: cfg.Server.WriteTimeout,
 		IdleTimeout:  cfg.Server.IdleTimeout,
 	}
 
+	// Shutdown does not wait for event streams, so end them when it starts
+	server.RegisterOnShutdown(eventBus.Close)
+
 	// Start server in goroutine
 	go func() {
 		log.Printf("Server starting on %s", server.Addr)
@@ -94,25 +196,78 @@ func main() {
 }
 
 // setupRoutes configures HTTP routes
-func setupRoutes(db *database.DB, cfg *config.Config, userService user.Service, sensorService sensor.Service) http.Handler {
-	mux := http.NewServeMux()
+func setupRoutes(db *database.DB, cfg *config.Config, userService user.Service, sensorService sensor.Service, alertService alert.Service, eventBus *events.Bus, authCache *user.AuthCache, mqttBroker *mqtt.MQTTBroker) http.Handler {
+	serveMux := http.NewServeMux()
+	// Routes are registered through a recorder so the OpenAPI document can
+	// be checked against every served pattern
+	mux := openapi.NewRecorder(serveMux)
+
+	// Audit recorder shared by handlers performing privileged operations
+	auditService := audit.NewService(audit.NewRepository(db.DB))
+
+	// Create auth service adapter shared by all handlers
+	authService := user.NewAuthServiceAdapter(userService, authCache)
+	authMW := middleware.NewAuthMiddleware(authService)
+
+	// Error codes clients can rely on in the code field of error responses
+	response.RegisterErrorCodes(user.ErrorCodes...)
+	response.RegisterErrorCodes(sensor.ErrorCodes...)
+	response.RegisterErrorCodes(alert.ErrorCodes...)
+	response.RegisterErrorCodes(webhook.ErrorCodes...)
 
 	// Create handlers with the services passed from main
-	userHandler := user.NewHandler(userService)
+	handlers := apiHandlers{
+		health:  health.NewHandler(db.DB, mqttBroker),
+		user:    user.NewHandler(userService, authMW, auditService),
+		sensor:  sensor.NewHandler(sensorService, authMW, auditService),
+		alert:   alert.NewHandler(alertService, sensorService, authMW, auditService),
+		webhook: webhook.NewHandler(webhook.NewService(webhook.NewRepository(db.DB)), authMW, auditService),
+		audit:   audit.NewHandler(auditService, authMW),
+		events:  events.NewHandler(eventBus, sensorService, authMW),
+	}
 
-	// Create auth service adapter for sensor handler
-	authService := user.NewAuthServiceAdapter(userService)
-	sensorHandler := sensor.NewHandler(sensorService, middleware.NewAuthMiddleware(authService))
+	// OpenAPI document of every route, built once registration is done
+	var spec []byte
+	operations := registerRoutes(mux, handlers, cfg.App.APIDocs, &spec)
+	spec = buildOpenAPISpec(operations, mux.Patterns())
 
-	// Health check endpoint
-	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"status":"healthy","timestamp":"` + time.Now().Format(time.RFC3339) + `"}`))
-	})
+	// Apply middleware chain
+	corsOptions := middleware.CORSOptions{
+		AllowedOrigins:   cfg.CORS.AllowedOrigins,
+		AllowedMethods:   cfg.CORS.AllowedMethods,
+		AllowedHeaders:   cfg.CORS.AllowedHeaders,
+		AllowCredentials: cfg.CORS.AllowCredentials,
+		MaxAge:           cfg.CORS.MaxAgeSeconds,
+	}
+	handler := middleware.RouteErrors(serveMux)
+	handler = newRateLimiter(cfg.RateLimit, authMW).Limit(serveMux)(handler)
+	handler = middleware.CORS(corsOptions, serveMux)(handler)
+	handler = middleware.Logging(newLogger(cfg.App), cfg.App.RequestLogSkipPaths)(handler)
+	handler = middleware.RequestID(handler)
+
+	return handler
+}
+
+// apiHandlers are the domain handlers whose routes registerRoutes registers
+type apiHandlers struct {
+	health  *health.Handler
+	user    *user.Handler
+	sensor  *sensor.Handler
+	alert   *alert.Handler
+	webhook *webhook.Handler
+	audit   *audit.Handler
+	events  *events.Handler
+}
+
+// registerRoutes registers every API route on mux and returns the
+// operations documenting them. GET /api/openapi.json serves *spec, which is
+// set once the document is built.
+func registerRoutes(mux *openapi.Recorder, handlers apiHandlers, apiDocs bool, spec *[]byte) []openapi.Operation {
+	// Liveness and readiness probes
+	handlers.health.RegisterRoutes(mux)
 
 	// API info endpoint
-	mux.HandleFunc("GET /api/", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("GET /api/{$}", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(`{
@@ -122,61 +277,336 @@ func setupRoutes(db *database.DB, cfg *config.Config, userService user.Service,
 			"endpoints": {
 				"auth": {
 					"register": "POST /api/auth/register",
+					"register_invite": "POST /api/auth/register/invite",
+					"verify_email": "POST /api/auth/verify-email",
+					"resend_verification": "POST /api/auth/verify-email/resend",
 					"login": "POST /api/auth/login",
+					"refresh": "POST /api/auth/refresh",
+					"sessions": "GET /api/auth/sessions",
+					"revoke_session": "DELETE /api/auth/sessions/{id}",
+					"create_api_key": "POST /api/auth/api-keys",
+					"api_keys": "GET /api/auth/api-keys",
+					"revoke_api_key": "DELETE /api/auth/api-keys/{id}",
+					"two_factor_setup": "POST /api/auth/2fa/setup",
+					"two_factor_verify": "POST /api/auth/2fa/verify",
+					"two_factor_login": "POST /api/auth/2fa/login",
+					"forced_password_change": "POST /api/auth/password/forced-change",
 					"profile": "GET /api/auth/profile",
 					"update_profile": "PUT /api/auth/profile",
-					"permissions": "GET /api/auth/permissions"
+					"change_password": "PUT /api/auth/password",
+					"password_reset_request": "POST /api/auth/password-reset/request",
+					"password_reset_confirm": "POST /api/auth/password-reset/confirm",
+					"permissions": "GET /api/auth/permissions",
+					"jwks": "GET /.well-known/jwks.json"
 				},
 				"users": {
 					"list": "GET /api/users",
 					"get": "GET /api/users/{id}",
+					"get_by_email": "GET /api/users/by-email?email=",
+					"stats": "GET /api/users/stats?from=&to=",
+					"invite": "POST /api/users/invitations",
 					"update": "PUT /api/users/{id}",
 					"deactivate": "DELETE /api/users/{id}",
-					"roles": "GET /api/users/{id}/roles"
+					"activate": "POST /api/users/{id}/activate",
+					"erase": "POST /api/users/{id}/erase",
+					"disable_2fa": "DELETE /api/users/{id}/2fa",
+					"roles": "GET /api/users/{id}/roles",
+					"logins": "GET /api/users/{id}/logins",
+					"move_organization": "PUT /api/users/{id}/organization"
+				},
+				"organizations": {
+					"list": "GET /api/organizations",
+					"create": "POST /api/organizations"
 				},
 				"roles": {
 					"list": "GET /api/roles",
+					"create": "POST /api/roles",
+					"update": "PUT /api/roles/{id}",
+					"delete": "DELETE /api/roles/{id}",
+					"add_permission": "POST /api/roles/{id}/permissions",
+					"remove_permission": "DELETE /api/roles/{id}/permissions/{permission_id}",
 					"assign": "POST /api/users/roles",
+					"bulk_assign": "POST /api/roles/{id}/users",
 					"remove": "DELETE /api/users/roles"
 				},
+				"permissions": {
+					"list": "GET /api/permissions"
+				},
 				"sensors": {
 					"dashboard": "GET /api/sensors/dashboard",
 					"list": "GET /api/sensors",
+					"tags": "GET /api/sensors/tags",
+					"firmware_report": "GET /api/sensors/firmware-report",
+					"events": "GET /api/sensors/events",
 					"get": "GET /api/sensors/{id}",
 					"get_by_device": "GET /api/sensors/device/{device_id}",
 					"create": "POST /api/sensors",
 					"update": "PUT /api/sensors/{id}",
 					"delete": "DELETE /api/sensors/{id}",
-					"health": "GET /api/sensors/health"
+					"purge": "DELETE /api/sensors/{id}?purge=true",
+					"activate": "POST /api/sensors/{id}/activate",
+					"clone": "POST /api/sensors/{id}/clone",
+					"calibrate": "PUT /api/sensors/{id}/calibration",
+					"calibrations": "GET /api/sensors/{id}/calibrations",
+					"firmware_history": "GET /api/sensors/{id}/firmware-history",
+					"maintenance": "PUT /api/sensors/{id}/maintenance",
+					"notes": "GET /api/sensors/{id}/notes",
+					"create_note": "POST /api/sensors/{id}/notes",
+					"delete_note": "DELETE /api/sensors/{id}/notes/{note_id}",
+					"commands": "POST /api/sensors/{id}/commands",
+					"command_history": "GET /api/sensors/{id}/commands",
+					"status_history": "GET /api/sensors/{id}/status-history",
+					"health": "GET /api/sensors/health?min_score=&max_score=&issue=&location_id=&sort=health_score",
+					"pending": "GET /api/sensors/pending",
+					"approve": "POST /api/sensors/{id}/approve",
+					"rotate_token": "POST /api/sensors/{id}/rotate-token"
+				},
+				"sensor_access": {
+					"list": "GET /api/sensors/access",
+					"grant": "POST /api/sensors/access",
+					"revoke": "DELETE /api/sensors/access/{id}"
 				},
 				"sensor_data": {
 					"create_reading": "POST /api/sensors/readings",
 					"create_bulk": "POST /api/sensors/readings/bulk",
 					"get_readings": "GET /api/sensors/readings",
-					"statistics": "GET /api/sensors/statistics"
+					"get_reading": "GET /api/sensors/readings/{id}",
+					"update_reading": "PATCH /api/sensors/readings/{id}",
+					"delete_readings": "DELETE /api/sensors/{id}/readings",
+					"aggregate_readings": "GET /api/sensors/{id}/readings/aggregate",
+					"availability": "GET /api/sensors/{id}/availability",
+					"anomalies": "GET /api/sensors/{id}/anomalies",
+					"gaps": "GET /api/sensors/{id}/gaps",
+					"purge_readings": "POST /api/sensors/readings/purge",
+					"statistics": "GET /api/sensors/statistics",
+					"daily_statistics": "GET /api/sensors/{id}/statistics/daily"
 				},
 				"locations": {
 					"list": "GET /api/locations",
 					"get": "GET /api/locations/{id}",
 					"create": "POST /api/locations",
 					"update": "PUT /api/locations/{id}",
-					"summary": "GET /api/locations/sensors"
+					"delete": "DELETE /api/locations/{id}",
+					"summary": "GET /api/locations/sensors",
+					"nearby": "GET /api/locations/nearby",
+					"assign_sensors": "POST /api/locations/{id}/assign-sensors"
+				},
+				"sensor_groups": {
+					"list": "GET /api/sensor-groups",
+					"get": "GET /api/sensor-groups/{id}",
+					"create": "POST /api/sensor-groups",
+					"update": "PUT /api/sensor-groups/{id}",
+					"delete": "DELETE /api/sensor-groups/{id}",
+					"members": "POST /api/sensor-groups/{id}/sensors",
+					"summary": "GET /api/sensor-groups/{id}/summary"
 				},
 				"sensor_types": {
 					"list": "GET /api/sensor-types",
-					"get": "GET /api/sensor-types/{id}"
+					"get": "GET /api/sensor-types/{id}",
+					"create": "POST /api/sensor-types",
+					"update": "PUT /api/sensor-types/{id}",
+					"delete": "DELETE /api/sensor-types/{id}"
+				},
+				"alerts": {
+					"list": "GET /api/alerts",
+					"acknowledge": "POST /api/alerts/{id}/ack",
+					"rules": "GET /api/alerts/rules",
+					"get_rule": "GET /api/alerts/rules/{id}",
+					"create_rule": "POST /api/alerts/rules",
+					"update_rule": "PUT /api/alerts/rules/{id}",
+					"delete_rule": "DELETE /api/alerts/rules/{id}"
+				},
+				"webhooks": {
+					"list": "GET /api/webhooks",
+					"get": "GET /api/webhooks/{id}",
+					"create": "POST /api/webhooks",
+					"update": "PUT /api/webhooks/{id}",
+					"delete": "DELETE /api/webhooks/{id}",
+					"deliveries": "GET /api/webhooks/{id}/deliveries"
+				},
+				"audit_logs": {
+					"list": "GET /api/audit-logs"
+				},
+				"errors": {
+					"codes": "GET /api/errors"
+				},
+				"docs": {
+					"openapi": "GET /api/openapi.json",
+					"ui": "GET /api/docs"
 				}
 			}
 		}`))
 	})
 
+	mux.HandleFunc("GET /api/errors", func(w http.ResponseWriter, r *http.Request) {
+		response.Success(w, "Error codes retrieved successfully", response.ErrorCodes())
+	})
+
 	// Register domain routes
-	userHandler.RegisterRoutes(mux)
-	sensorHandler.RegisterRoutes(mux)
+	handlers.user.RegisterRoutes(mux)
+	handlers.sensor.RegisterRoutes(mux)
+	handlers.alert.RegisterRoutes(mux)
+	handlers.webhook.RegisterRoutes(mux)
+	handlers.audit.RegisterRoutes(mux)
+	handlers.events.RegisterRoutes(mux)
 
-	// Apply middleware chain
-	handler := middleware.CORS(mux)
-	handler = middleware.Logging(handler)
+	mux.HandleFunc("GET /api/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(*spec)
+	})
+	operations := append([]openapi.Operation(nil), apiOperations...)
+	if apiDocs {
+		mux.HandleFunc("GET /api/docs", serveAPIDocs)
+		operations = append(operations, apiDocsOperation)
+	}
+	for _, ops := range [][]openapi.Operation{
+		health.Operations, user.Operations, sensor.Operations, alert.Operations,
+		webhook.Operations, audit.Operations, events.Operations,
+	} {
+		operations = append(operations, ops...)
+	}
+	return operations
+}
 
-	return handler
+// apiOperations documents the routes registered in registerRoutes
+var apiOperations = []openapi.Operation{
+	{Pattern: "GET /api/{$}", Tag: "api", Summary: "Describe the API", Raw: true},
+	{Pattern: "GET /api/errors", Tag: "api", Summary: "List the codes of error responses", Response: []response.ErrorCode{}},
+	{Pattern: "GET /api/openapi.json", Tag: "api", Summary: "Get the OpenAPI document", Raw: true},
+}
+
+// apiDocsOperation documents the Swagger UI, which is only served when
+// api_docs is enabled
+var apiDocsOperation = openapi.Operation{
+	Pattern: "GET /api/docs", Tag: "api", Summary: "Browse the API documentation", Raw: true, ContentType: "text/html",
+}
+
+// apiInfo describes the API in the OpenAPI document
+var apiInfo = openapi.Info{
+	Title:       "IoT User Management API",
+	Version:     "1.0.0",
+	Description: "User management and sensor data API",
+}
+
+// buildOpenAPISpec encodes the OpenAPI document of operations. Registered
+// patterns missing from operations are still listed but logged, so new
+// routes are documented before they ship; TestOpenAPIDocumentsEveryRoute
+// fails on them.
+func buildOpenAPISpec(operations []openapi.Operation, patterns []string) []byte {
+	doc, undocumented := openapi.Build(apiInfo, operations, patterns)
+	if len(undocumented) > 0 {
+		log.Printf("Warning: routes missing from the OpenAPI document: %s", strings.Join(undocumented, ", "))
+	}
+
+	spec, err := json.Marshal(doc)
+	if err != nil {
+		log.Fatalf("Failed to encode OpenAPI document: %v", err)
+	}
+	return spec
+}
+
+// apiDocsPage renders the OpenAPI document with Swagger UI. The Swagger UI
+// assets are not embedded: the browser loads them from the unpkg CDN.
+const apiDocsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="utf-8">
+	<title>IoT User Management API</title>
+	<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+	<div id="swagger-ui"></div>
+	<script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+	<script>
+		window.ui = SwaggerUIBundle({ url: "/api/openapi.json", dom_id: "#swagger-ui" });
+	</script>
+</body>
+</html>
+`
+
+// serveAPIDocs handles GET /api/docs
+func serveAPIDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(apiDocsPage))
+}
+
+// newRateLimiter creates the API rate limiter from the rate_limit section
+func newRateLimiter(cfg config.RateLimitConfig, authMW *middleware.AuthMiddleware) *middleware.RateLimiter {
+	routes := make(map[string]middleware.RateLimit, len(cfg.Routes))
+	for pattern, route := range cfg.Routes {
+		routes[pattern] = middleware.RateLimit{
+			RequestsPerMinute: route.RequestsPerMinute,
+			Burst:             route.Burst,
+		}
+	}
+
+	limit := middleware.RateLimit{
+		RequestsPerMinute: cfg.RequestsPerMinute,
+		Burst:             cfg.Burst,
+	}
+	return middleware.NewRateLimiter(limit, routes, authMW)
+}
+
+// newLogger creates the structured logger for request logs. The level comes
+// from log_level and the format from log_format, defaulting to JSON in
+// production.
+func newLogger(cfg config.AppConfig) *slog.Logger {
+	var level slog.Level
+	switch strings.ToLower(cfg.LogLevel) {
+	case "debug":
+		level = slog.LevelDebug
+	case "warn", "warning":
+		level = slog.LevelWarn
+	case "error":
+		level = slog.LevelError
+	default:
+		level = slog.LevelInfo
+	}
+
+	format := strings.ToLower(cfg.LogFormat)
+	if format == "" {
+		format = "text"
+		if cfg.Environment == "production" {
+			format = "json"
+		}
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	if format == "json" {
+		return slog.New(slog.NewJSONHandler(os.Stdout, opts))
+	}
+	return slog.New(slog.NewTextHandler(os.Stdout, opts))
+}
+
+// sensorHealthPolicy applies the configured health check weights over the
+// defaults
+func sensorHealthPolicy(cfg config.SensorHealthConfig) sensor.HealthPolicy {
+	policy := sensor.DefaultHealthPolicy()
+
+	overrides := []struct {
+		value  *int
+		target *int
+	}{
+		{cfg.OfflinePenalty, &policy.OfflinePenalty},
+		{cfg.CriticalBatteryLevel, &policy.CriticalBatteryLevel},
+		{cfg.CriticalBatteryPenalty, &policy.CriticalBatteryPenalty},
+		{cfg.LowBatteryLevel, &policy.LowBatteryLevel},
+		{cfg.LowBatteryPenalty, &policy.LowBatteryPenalty},
+		{cfg.PoorQualityLevel, &policy.PoorQualityLevel},
+		{cfg.PoorQualityPenalty, &policy.PoorQualityPenalty},
+		{cfg.StaleReadingPenalty, &policy.StaleReadingPenalty},
+		{cfg.NoReadingsPenalty, &policy.NoReadingsPenalty},
+		{cfg.AlertScore, &policy.AlertScore},
+	}
+	for _, override := range overrides {
+		if override.value != nil {
+			*override.target = *override.value
+		}
+	}
+
+	if cfg.StaleReadingMinutes != nil {
+		policy.StaleReadingAge = time.Duration(*cfg.StaleReadingMinutes) * time.Minute
+	}
+
+	return policy
 }
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"user-management/pkg/alert"
+	"user-management/pkg/audit"
+	"user-management/pkg/events"
+	"user-management/pkg/health"
+	"user-management/pkg/sensor"
+	"user-management/pkg/user"
+	"user-management/pkg/webhook"
+	"user-management/shared/middleware"
+	"user-management/shared/openapi"
+)
+
+// TestOpenAPIDocumentsEveryRoute fails when a route is registered without
+// an operation documenting it
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	// Registering routes calls no services, so the handlers need none
+	authMW := middleware.NewAuthMiddleware(nil)
+	handlers := apiHandlers{
+		health:  health.NewHandler(nil, nil),
+		user:    user.NewHandler(nil, authMW, nil),
+		sensor:  sensor.NewHandler(nil, authMW, nil),
+		alert:   alert.NewHandler(nil, nil, authMW, nil),
+		webhook: webhook.NewHandler(nil, authMW, nil),
+		audit:   audit.NewHandler(nil, authMW),
+		events:  events.NewHandler(nil, nil, authMW),
+	}
+
+	for _, apiDocs := range []bool{false, true} {
+		mux := openapi.NewRecorder(http.NewServeMux())
+		var spec []byte
+		operations := registerRoutes(mux, handlers, apiDocs, &spec)
+
+		patterns := mux.Patterns()
+		if len(patterns) == 0 {
+			t.Fatal("no routes registered")
+		}
+
+		_, undocumented := openapi.Build(apiInfo, operations, patterns)
+		for _, pattern := range undocumented {
+			t.Errorf("api_docs=%v: route %q is missing from the OpenAPI document", apiDocs, pattern)
+		}
+	}
+}
@@ -0,0 +1,383 @@
+package alert
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	"user-management/pkg/audit"
+	"user-management/shared/interfaces"
+	"user-management/shared/middleware"
+	"user-management/shared/response"
+)
+
+// Handler handles HTTP requests for alert rules and alerts
+type Handler struct {
+	service Service
+	sensors interfaces.SensorAccessResolver
+	authMW  *middleware.AuthMiddleware
+	audit   audit.Recorder
+}
+
+// NewHandler creates a new alert handler; sensors limits users to the
+// alerts of the sensors they can read
+func NewHandler(service Service, sensors interfaces.SensorAccessResolver, authMW *middleware.AuthMiddleware, recorder audit.Recorder) *Handler {
+	return &Handler{
+		service: service,
+		sensors: sensors,
+		authMW:  authMW,
+		audit:   recorder,
+	}
+}
+
+// RegisterRoutes registers all alert routes
+func (h *Handler) RegisterRoutes(mux interfaces.Router) {
+	// Alert rules
+	mux.Handle("GET /api/alerts/rules", h.authMW.Authenticate(h.authMW.RequirePermission("alerts", "read")(http.HandlerFunc(h.ListRules))))
+	mux.Handle("GET /api/alerts/rules/{id}", h.authMW.Authenticate(h.authMW.RequirePermission("alerts", "read")(http.HandlerFunc(h.GetRule))))
+	mux.Handle("POST /api/alerts/rules", h.authMW.Authenticate(h.authMW.RequirePermission("alerts", "write")(http.HandlerFunc(h.CreateRule))))
+	mux.Handle("PUT /api/alerts/rules/{id}", h.authMW.Authenticate(h.authMW.RequirePermission("alerts", "write")(http.HandlerFunc(h.UpdateRule))))
+	mux.Handle("DELETE /api/alerts/rules/{id}", h.authMW.Authenticate(h.authMW.RequirePermission("alerts", "write")(http.HandlerFunc(h.DeleteRule))))
+
+	// Alerts
+	mux.Handle("GET /api/alerts", h.authMW.Authenticate(h.authMW.RequirePermission("alerts", "read")(http.HandlerFunc(h.ListAlerts))))
+	mux.Handle("POST /api/alerts/{id}/ack", h.authMW.Authenticate(h.authMW.RequirePermission("alerts", "write")(http.HandlerFunc(h.AcknowledgeAlert))))
+}
+
+// scopedService returns the service limited to the organization of the
+// authenticated user and the sensors they can read; super admins are not
+// limited
+func (h *Handler) scopedService(r *http.Request) (Service, error) {
+	scope, ok := middleware.GetScopeFromContext(r.Context())
+	if !ok {
+		// Fail closed: a restricted scope without organization matches nothing
+		scope = interfaces.Scope{Restricted: true}
+	}
+	scoped := h.service.WithScope(scope)
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		// Fail closed: without a user no sensor is readable
+		return scoped.WithSensors(nil), nil
+	}
+
+	sensorIDs, restricted, err := h.sensors.ReadableSensorIDs(r.Context(), user)
+	if err != nil {
+		return nil, err
+	}
+	if restricted {
+		scoped = scoped.WithSensors(sensorIDs)
+	}
+	return scoped, nil
+}
+
+// CreateRule handles alert rule creation
+func (h *Handler) CreateRule(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		response.Unauthorized(w, "User not found in context")
+		return
+	}
+
+	var req CreateRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	scoped, err := h.scopedService(r)
+	if err != nil {
+		response.InternalServerError(w, "Failed to resolve sensor access", err)
+		return
+	}
+
+	rule, err := scoped.CreateRule(&req, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidRuleTarget), errors.Is(err, ErrInvalidRuleName),
+			errors.Is(err, ErrInvalidCondition), errors.Is(err, ErrThresholdRequired), errors.Is(err, ErrRangeRequired),
+			errors.Is(err, ErrInvalidConsecutive):
+			response.BadRequest(w, "Validation failed", err)
+		case errors.Is(err, ErrSensorNotFound), errors.Is(err, ErrSensorTypeNotFound):
+			response.ErrorFor(w, http.StatusNotFound, err.Error(), err)
+		default:
+			response.InternalServerError(w, "Failed to create alert rule", err)
+		}
+		return
+	}
+
+	h.audit.Record(r, audit.ActionAlertRuleCreate, audit.ResourceAlertRule, strconv.Itoa(rule.ID), req)
+
+	response.Created(w, "Alert rule created successfully", rule)
+}
+
+// GetRule handles getting an alert rule by ID
+func (h *Handler) GetRule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid alert rule ID", err)
+		return
+	}
+
+	scoped, err := h.scopedService(r)
+	if err != nil {
+		response.InternalServerError(w, "Failed to resolve sensor access", err)
+		return
+	}
+
+	rule, err := scoped.GetRule(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrRuleNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Alert rule not found", err)
+		default:
+			response.InternalServerError(w, "Failed to get alert rule", err)
+		}
+		return
+	}
+
+	response.Success(w, "Alert rule retrieved successfully", rule)
+}
+
+// ListRules handles listing alert rules, optionally of a sensor or sensor type
+func (h *Handler) ListRules(w http.ResponseWriter, r *http.Request) {
+	var filter RuleFilter
+
+	if sensorIDStr := r.URL.Query().Get("sensor_id"); sensorIDStr != "" {
+		sensorID, err := strconv.Atoi(sensorIDStr)
+		if err != nil {
+			response.BadRequest(w, "Invalid sensor ID", err)
+			return
+		}
+		filter.SensorID = &sensorID
+	}
+
+	if sensorTypeIDStr := r.URL.Query().Get("sensor_type_id"); sensorTypeIDStr != "" {
+		sensorTypeID, err := strconv.Atoi(sensorTypeIDStr)
+		if err != nil {
+			response.BadRequest(w, "Invalid sensor type ID", err)
+			return
+		}
+		filter.SensorTypeID = &sensorTypeID
+	}
+
+	if enabledStr := r.URL.Query().Get("enabled"); enabledStr != "" {
+		enabled, err := strconv.ParseBool(enabledStr)
+		if err != nil {
+			response.BadRequest(w, "Invalid enabled value", err)
+			return
+		}
+		filter.Enabled = &enabled
+	}
+
+	scoped, err := h.scopedService(r)
+	if err != nil {
+		response.InternalServerError(w, "Failed to resolve sensor access", err)
+		return
+	}
+
+	rules, err := scoped.ListRules(filter)
+	if err != nil {
+		response.InternalServerError(w, "Failed to list alert rules", err)
+		return
+	}
+
+	response.Success(w, "Alert rules retrieved successfully", rules)
+}
+
+// UpdateRule handles alert rule updates
+func (h *Handler) UpdateRule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid alert rule ID", err)
+		return
+	}
+
+	var req UpdateRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	scoped, err := h.scopedService(r)
+	if err != nil {
+		response.InternalServerError(w, "Failed to resolve sensor access", err)
+		return
+	}
+
+	rule, err := scoped.UpdateRule(id, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidRuleName), errors.Is(err, ErrInvalidCondition),
+			errors.Is(err, ErrThresholdRequired), errors.Is(err, ErrRangeRequired),
+			errors.Is(err, ErrInvalidConsecutive):
+			response.BadRequest(w, "Validation failed", err)
+		case errors.Is(err, ErrRuleNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Alert rule not found", err)
+		default:
+			response.InternalServerError(w, "Failed to update alert rule", err)
+		}
+		return
+	}
+
+	h.audit.Record(r, audit.ActionAlertRuleUpdate, audit.ResourceAlertRule, strconv.Itoa(id), req)
+
+	response.Success(w, "Alert rule updated successfully", rule)
+}
+
+// DeleteRule handles alert rule deletion
+func (h *Handler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid alert rule ID", err)
+		return
+	}
+
+	scoped, err := h.scopedService(r)
+	if err != nil {
+		response.InternalServerError(w, "Failed to resolve sensor access", err)
+		return
+	}
+
+	if err := scoped.DeleteRule(id); err != nil {
+		switch {
+		case errors.Is(err, ErrRuleNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Alert rule not found", err)
+		default:
+			response.InternalServerError(w, "Failed to delete alert rule", err)
+		}
+		return
+	}
+
+	h.audit.Record(r, audit.ActionAlertRuleDelete, audit.ResourceAlertRule, strconv.Itoa(id), nil)
+
+	response.Success(w, "Alert rule deleted successfully", nil)
+}
+
+// ListAlerts handles listing alerts with filters and pagination
+func (h *Handler) ListAlerts(w http.ResponseWriter, r *http.Request) {
+	// Parse query parameters
+	page := 1
+	perPage := 20
+
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if perPageStr := r.URL.Query().Get("per_page"); perPageStr != "" {
+		if pp, err := strconv.Atoi(perPageStr); err == nil && pp > 0 && pp <= 100 {
+			perPage = pp
+		}
+	}
+
+	query := &Query{
+		State:  r.URL.Query().Get("state"),
+		Limit:  perPage,
+		Offset: (page - 1) * perPage,
+	}
+
+	if sensorIDStr := r.URL.Query().Get("sensor_id"); sensorIDStr != "" {
+		sensorID, err := strconv.Atoi(sensorIDStr)
+		if err != nil {
+			response.BadRequest(w, "Invalid sensor ID", err)
+			return
+		}
+		query.SensorID = &sensorID
+	}
+
+	if ruleIDStr := r.URL.Query().Get("rule_id"); ruleIDStr != "" {
+		ruleID, err := strconv.Atoi(ruleIDStr)
+		if err != nil {
+			response.BadRequest(w, "Invalid alert rule ID", err)
+			return
+		}
+		query.RuleID = &ruleID
+	}
+
+	if startStr := r.URL.Query().Get("start_time"); startStr != "" {
+		startTime, err := time.Parse(time.RFC3339, startStr)
+		if err != nil {
+			response.BadRequest(w, "Invalid start_time format, use RFC3339", err)
+			return
+		}
+		query.StartTime = &startTime
+	}
+
+	if endStr := r.URL.Query().Get("end_time"); endStr != "" {
+		endTime, err := time.Parse(time.RFC3339, endStr)
+		if err != nil {
+			response.BadRequest(w, "Invalid end_time format, use RFC3339", err)
+			return
+		}
+		query.EndTime = &endTime
+	}
+
+	scoped, err := h.scopedService(r)
+	if err != nil {
+		response.InternalServerError(w, "Failed to resolve sensor access", err)
+		return
+	}
+
+	alerts, total, err := scoped.ListAlerts(query)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidState):
+			response.BadRequest(w, "Invalid state", err)
+		default:
+			response.InternalServerError(w, "Failed to list alerts", err)
+		}
+		return
+	}
+
+	// Calculate pagination meta
+	totalPages := (total + perPage - 1) / perPage
+	meta := &response.Meta{
+		Page:       page,
+		PerPage:    perPage,
+		Total:      total,
+		TotalPages: totalPages,
+	}
+
+	response.PaginatedSuccess(w, "Alerts retrieved successfully", alerts, meta)
+}
+
+// AcknowledgeAlert handles acknowledging an open alert
+func (h *Handler) AcknowledgeAlert(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		response.Unauthorized(w, "User not found in context")
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(w, "Invalid alert ID", err)
+		return
+	}
+
+	scoped, err := h.scopedService(r)
+	if err != nil {
+		response.InternalServerError(w, "Failed to resolve sensor access", err)
+		return
+	}
+
+	alert, err := scoped.AcknowledgeAlert(id, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrAlertNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Alert not found", err)
+		case errors.Is(err, ErrAlertNotOpen):
+			response.Conflict(w, "Alert is not open", err)
+		default:
+			response.InternalServerError(w, "Failed to acknowledge alert", err)
+		}
+		return
+	}
+
+	h.audit.Record(r, audit.ActionAlertAcknowledge, audit.ResourceAlert, strconv.FormatInt(id, 10), nil)
+
+	response.Success(w, "Alert acknowledged successfully", alert)
+}
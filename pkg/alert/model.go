@@ -0,0 +1,271 @@
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"user-management/shared/response"
+)
+
+// Rule conditions
+const (
+	ConditionGreaterThan  = "gt"
+	ConditionLessThan     = "lt"
+	ConditionOutsideRange = "outside_range"
+)
+
+// Alert states
+const (
+	StateOpen         = "open"
+	StateAcknowledged = "acknowledged"
+	StateResolved     = "resolved"
+)
+
+// maxConsecutiveReadings bounds how many readings a rule may require in a row
+const maxConsecutiveReadings = 100
+
+// Rule represents a threshold alert rule on a sensor or on every sensor of a type
+type Rule struct {
+	ID             int      `json:"id"`
+	OrganizationID int      `json:"organization_id"`
+	SensorID       *int     `json:"sensor_id,omitempty"`
+	SensorTypeID   *int     `json:"sensor_type_id,omitempty"`
+	Name           string   `json:"name"`
+	Condition      string   `json:"condition"`
+	Threshold      *float64 `json:"threshold,omitempty"`
+	MinValue       *float64 `json:"min_value,omitempty"`
+	MaxValue       *float64 `json:"max_value,omitempty"`
+	// ConsecutiveReadings is how many latest readings must breach the rule
+	ConsecutiveReadings int       `json:"consecutive_readings"`
+	Enabled             bool      `json:"enabled"`
+	CreatedBy           *int      `json:"created_by,omitempty"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+// Alert represents an alert triggered by a rule for a sensor
+type Alert struct {
+	ID             int64      `json:"id"`
+	RuleID         int        `json:"rule_id"`
+	SensorID       int        `json:"sensor_id"`
+	OrganizationID int        `json:"organization_id"`
+	State          string     `json:"state"`
+	Value          float64    `json:"value"`
+	Message        string     `json:"message"`
+	TriggeredAt    time.Time  `json:"triggered_at"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+	AcknowledgedBy *int       `json:"acknowledged_by,omitempty"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty"`
+}
+
+// CreateRuleRequest represents request to create an alert rule
+type CreateRuleRequest struct {
+	SensorID            *int     `json:"sensor_id,omitempty"`
+	SensorTypeID        *int     `json:"sensor_type_id,omitempty"`
+	Name                string   `json:"name"`
+	Condition           string   `json:"condition"`
+	Threshold           *float64 `json:"threshold,omitempty"`
+	MinValue            *float64 `json:"min_value,omitempty"`
+	MaxValue            *float64 `json:"max_value,omitempty"`
+	ConsecutiveReadings int      `json:"consecutive_readings"`
+	Enabled             *bool    `json:"enabled,omitempty"`
+}
+
+// UpdateRuleRequest represents request to update an alert rule. The
+// watched sensor or sensor type cannot be changed.
+type UpdateRuleRequest struct {
+	Name                *string  `json:"name,omitempty"`
+	Condition           *string  `json:"condition,omitempty"`
+	Threshold           *float64 `json:"threshold,omitempty"`
+	MinValue            *float64 `json:"min_value,omitempty"`
+	MaxValue            *float64 `json:"max_value,omitempty"`
+	ConsecutiveReadings *int     `json:"consecutive_readings,omitempty"`
+	Enabled             *bool    `json:"enabled,omitempty"`
+}
+
+// RuleFilter represents filters for listing alert rules
+type RuleFilter struct {
+	SensorID     *int
+	SensorTypeID *int
+	Enabled      *bool
+}
+
+// Query represents filters for listing alerts
+type Query struct {
+	State     string     `json:"state,omitempty"`
+	SensorID  *int       `json:"sensor_id,omitempty"`
+	RuleID    *int       `json:"rule_id,omitempty"`
+	StartTime *time.Time `json:"start_time,omitempty"`
+	EndTime   *time.Time `json:"end_time,omitempty"`
+	Limit     int        `json:"limit"`
+	Offset    int        `json:"offset"`
+}
+
+// Validation errors
+var (
+	ErrRuleNotFound       = errors.New("alert rule not found")
+	ErrAlertNotFound      = errors.New("alert not found")
+	ErrAlertNotOpen       = errors.New("only open alerts can be acknowledged")
+	ErrAlertExists        = errors.New("an unresolved alert already exists for the rule and sensor")
+	ErrSensorNotFound     = errors.New("sensor not found")
+	ErrSensorTypeNotFound = errors.New("sensor type not found")
+	ErrInvalidRuleName    = errors.New("name is required and must be at most 255 characters")
+	ErrInvalidRuleTarget  = errors.New("exactly one of sensor_id or sensor_type_id is required")
+	ErrInvalidCondition   = errors.New("condition must be gt, lt or outside_range")
+	ErrThresholdRequired  = errors.New("threshold is required for gt and lt conditions")
+	ErrRangeRequired      = errors.New("min_value and max_value are required for outside_range and min_value must be less than max_value")
+	ErrInvalidConsecutive = errors.New("consecutive_readings must be between 1 and 100")
+	ErrInvalidState       = errors.New("state must be open, acknowledged or resolved")
+)
+
+// ErrorCodes are the stable API error codes of the errors above
+var ErrorCodes = []response.ErrorCode{
+	response.Code("ALERT_RULE_NOT_FOUND", ErrRuleNotFound),
+	response.Code("ALERT_NOT_FOUND", ErrAlertNotFound),
+	response.Code("ALERT_NOT_OPEN", ErrAlertNotOpen),
+	response.Code("ALERT_EXISTS", ErrAlertExists),
+	response.Code("ALERT_SENSOR_NOT_FOUND", ErrSensorNotFound),
+	response.Code("ALERT_SENSOR_TYPE_NOT_FOUND", ErrSensorTypeNotFound),
+	response.Code("ALERT_RULE_INVALID_NAME", ErrInvalidRuleName),
+	response.Code("ALERT_RULE_INVALID_TARGET", ErrInvalidRuleTarget),
+	response.Code("ALERT_RULE_INVALID_CONDITION", ErrInvalidCondition),
+	response.Code("ALERT_RULE_THRESHOLD_REQUIRED", ErrThresholdRequired),
+	response.Code("ALERT_RULE_RANGE_REQUIRED", ErrRangeRequired),
+	response.Code("ALERT_RULE_INVALID_CONSECUTIVE", ErrInvalidConsecutive),
+	response.Code("ALERT_INVALID_STATE", ErrInvalidState),
+}
+
+// Breached checks if a value breaches the rule
+func (r *Rule) Breached(value float64) bool {
+	switch r.Condition {
+	case ConditionGreaterThan:
+		return r.Threshold != nil && value > *r.Threshold
+	case ConditionLessThan:
+		return r.Threshold != nil && value < *r.Threshold
+	case ConditionOutsideRange:
+		return (r.MinValue != nil && value < *r.MinValue) || (r.MaxValue != nil && value > *r.MaxValue)
+	}
+	return false
+}
+
+// Describe returns a human readable message for a value breaching the rule
+func (r *Rule) Describe(value float64) string {
+	switch r.Condition {
+	case ConditionGreaterThan:
+		return fmt.Sprintf("%s: value %.4g above %.4g", r.Name, value, *r.Threshold)
+	case ConditionLessThan:
+		return fmt.Sprintf("%s: value %.4g below %.4g", r.Name, value, *r.Threshold)
+	default:
+		return fmt.Sprintf("%s: value %.4g outside %.4g to %.4g", r.Name, value, *r.MinValue, *r.MaxValue)
+	}
+}
+
+// validate checks the condition and the thresholds it requires
+func (r *Rule) validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" || len(r.Name) > 255 {
+		return ErrInvalidRuleName
+	}
+
+	switch r.Condition {
+	case ConditionGreaterThan, ConditionLessThan:
+		if r.Threshold == nil {
+			return ErrThresholdRequired
+		}
+	case ConditionOutsideRange:
+		if r.MinValue == nil || r.MaxValue == nil || *r.MinValue >= *r.MaxValue {
+			return ErrRangeRequired
+		}
+	default:
+		return ErrInvalidCondition
+	}
+
+	if r.ConsecutiveReadings < 1 || r.ConsecutiveReadings > maxConsecutiveReadings {
+		return ErrInvalidConsecutive
+	}
+
+	return nil
+}
+
+// Validate validates CreateRuleRequest
+func (r *CreateRuleRequest) Validate() error {
+	if (r.SensorID == nil) == (r.SensorTypeID == nil) {
+		return ErrInvalidRuleTarget
+	}
+
+	if r.ConsecutiveReadings == 0 {
+		r.ConsecutiveReadings = 1
+	}
+
+	rule := r.toRule()
+	if err := rule.validate(); err != nil {
+		return err
+	}
+	r.Name = rule.Name
+
+	return nil
+}
+
+// toRule converts the request into a rule
+func (r *CreateRuleRequest) toRule() *Rule {
+	rule := &Rule{
+		SensorID:            r.SensorID,
+		SensorTypeID:        r.SensorTypeID,
+		Name:                r.Name,
+		Condition:           r.Condition,
+		Threshold:           r.Threshold,
+		MinValue:            r.MinValue,
+		MaxValue:            r.MaxValue,
+		ConsecutiveReadings: r.ConsecutiveReadings,
+		Enabled:             true,
+	}
+
+	if r.Enabled != nil {
+		rule.Enabled = *r.Enabled
+	}
+
+	return rule
+}
+
+// Apply applies the update to a copy of the rule and validates the result
+func (r *UpdateRuleRequest) Apply(rule *Rule) (*Rule, error) {
+	updated := *rule
+
+	if r.Name != nil {
+		updated.Name = *r.Name
+	}
+	if r.Condition != nil {
+		updated.Condition = *r.Condition
+	}
+	if r.Threshold != nil {
+		updated.Threshold = r.Threshold
+	}
+	if r.MinValue != nil {
+		updated.MinValue = r.MinValue
+	}
+	if r.MaxValue != nil {
+		updated.MaxValue = r.MaxValue
+	}
+	if r.ConsecutiveReadings != nil {
+		updated.ConsecutiveReadings = *r.ConsecutiveReadings
+	}
+	if r.Enabled != nil {
+		updated.Enabled = *r.Enabled
+	}
+
+	if err := updated.validate(); err != nil {
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+// ValidState checks if state is a known alert state
+func ValidState(state string) bool {
+	switch state {
+	case StateOpen, StateAcknowledged, StateResolved:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,23 @@
+package alert
+
+import (
+	"net/http"
+	"user-management/shared/openapi"
+)
+
+// Operations documents the alert routes in the OpenAPI document
+var Operations = []openapi.Operation{
+	{Pattern: "GET /api/alerts/rules", Tag: "alerts", Summary: "List alert rules", Access: "alerts:read",
+		Query: []string{"sensor_id", "sensor_type_id", "enabled"}, Response: []*Rule{}},
+	{Pattern: "GET /api/alerts/rules/{id}", Tag: "alerts", Summary: "Get an alert rule", Access: "alerts:read",
+		Response: &Rule{}},
+	{Pattern: "POST /api/alerts/rules", Tag: "alerts", Summary: "Create an alert rule", Access: "alerts:write",
+		Request: CreateRuleRequest{}, Response: &Rule{}, Status: http.StatusCreated},
+	{Pattern: "PUT /api/alerts/rules/{id}", Tag: "alerts", Summary: "Update an alert rule", Access: "alerts:write",
+		Request: UpdateRuleRequest{}, Response: &Rule{}},
+	{Pattern: "DELETE /api/alerts/rules/{id}", Tag: "alerts", Summary: "Delete an alert rule", Access: "alerts:write"},
+	{Pattern: "GET /api/alerts", Tag: "alerts", Summary: "List alerts", Access: "alerts:read",
+		Query: []string{"state", "sensor_id", "rule_id", "start_time", "end_time", "page", "per_page"}, Response: []*Alert{}, Paginated: true},
+	{Pattern: "POST /api/alerts/{id}/ack", Tag: "alerts", Summary: "Acknowledge an alert", Access: "alerts:write",
+		Response: &Alert{}},
+}
@@ -0,0 +1,557 @@
+package alert
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"user-management/database"
+	"user-management/shared/interfaces"
+
+	"github.com/lib/pq"
+)
+
+// Repository defines alert repository interface
+type Repository interface {
+	// Rule operations
+	CreateRule(rule *Rule) error
+	GetRuleByID(id int) (*Rule, error)
+	ListRules(filter RuleFilter) ([]*Rule, error)
+	UpdateRule(rule *Rule) error
+	DeleteRule(id int) error
+	ListEnabledRulesForSensor(sensorID int) ([]*Rule, error)
+
+	// Sensor lookups
+	SensorOrganization(sensorID int) (int, error)
+	SensorTypeExists(sensorTypeID int) (bool, error)
+	LatestValues(sensorID, limit int) ([]float64, error)
+
+	// Alert operations
+	CreateAlert(alert *Alert) error
+	GetAlertByID(id int64) (*Alert, error)
+	GetUnresolvedAlert(ruleID, sensorID int) (*Alert, error)
+	ListAlerts(query *Query) ([]*Alert, int, error)
+	AcknowledgeAlert(id int64, userID int) (*Alert, error)
+	ResolveAlert(id int64) error
+
+	// WithScope returns a repository limited to the organization in scope
+	WithScope(scope interfaces.Scope) Repository
+
+	// WithSensors returns a repository limited to the given sensors
+	WithSensors(sensorIDs []int) Repository
+}
+
+// repository implements Repository interface
+type repository struct {
+	db    *sql.DB
+	scope interfaces.Scope
+	// sensorIDs limits alerts and sensor rules to these sensors; nil is
+	// unrestricted
+	sensorIDs []int64
+}
+
+// NewRepository creates a new alert repository
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
+}
+
+// WithScope returns a copy of the repository limited to the scope
+func (r *repository) WithScope(scope interfaces.Scope) Repository {
+	return &repository{db: r.db, scope: scope, sensorIDs: r.sensorIDs}
+}
+
+// WithSensors returns a copy of the repository whose alerts and sensor
+// rules are limited to the given sensors. Sensor type rules stay visible.
+func (r *repository) WithSensors(sensorIDs []int) Repository {
+	ids := make([]int64, len(sensorIDs))
+	for i, id := range sensorIDs {
+		ids[i] = int64(id)
+	}
+	return &repository{db: r.db, scope: r.scope, sensorIDs: ids}
+}
+
+// orgFilter appends the scope's organization to args and returns an AND
+// condition on column, or "" when the repository is unrestricted
+func (r *repository) orgFilter(column string, args []interface{}) (string, []interface{}) {
+	if !r.scope.Restricted {
+		return "", args
+	}
+	args = append(args, r.scope.OrganizationID)
+	return fmt.Sprintf(" AND %s = $%d", column, len(args)), args
+}
+
+// sensorFilter appends the allowed sensors to args and returns an AND
+// condition on column, or "" when the repository is unrestricted. A NULL
+// column, as in sensor type rules, passes.
+func (r *repository) sensorFilter(column string, args []interface{}) (string, []interface{}) {
+	if r.sensorIDs == nil {
+		return "", args
+	}
+	args = append(args, pq.Array(r.sensorIDs))
+	return fmt.Sprintf(" AND (%[1]s IS NULL OR %[1]s = ANY($%[2]d))", column, len(args)), args
+}
+
+// Schema name constant
+const schema = "sensor_data"
+
+const ruleColumns = `
+	id, organization_id, sensor_id, sensor_type_id, name, condition, threshold,
+	min_value, max_value, consecutive_readings, enabled, created_by, created_at, updated_at
+`
+
+const alertColumns = `
+	id, rule_id, sensor_id, organization_id, state, value, COALESCE(message, ''),
+	triggered_at, acknowledged_at, acknowledged_by, resolved_at
+`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanRule scans a row selected with ruleColumns
+func scanRule(row rowScanner) (*Rule, error) {
+	rule := &Rule{}
+	err := row.Scan(
+		&rule.ID, &rule.OrganizationID, &rule.SensorID, &rule.SensorTypeID, &rule.Name,
+		&rule.Condition, &rule.Threshold, &rule.MinValue, &rule.MaxValue,
+		&rule.ConsecutiveReadings, &rule.Enabled, &rule.CreatedBy, &rule.CreatedAt, &rule.UpdatedAt,
+	)
+	return rule, err
+}
+
+// scanAlert scans a row selected with alertColumns
+func scanAlert(row rowScanner) (*Alert, error) {
+	alert := &Alert{}
+	err := row.Scan(
+		&alert.ID, &alert.RuleID, &alert.SensorID, &alert.OrganizationID, &alert.State,
+		&alert.Value, &alert.Message, &alert.TriggeredAt, &alert.AcknowledgedAt,
+		&alert.AcknowledgedBy, &alert.ResolvedAt,
+	)
+	return alert, err
+}
+
+// CreateRule creates a new alert rule
+func (r *repository) CreateRule(rule *Rule) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s.alert_rules (organization_id, sensor_id, sensor_type_id, name, condition,
+		                            threshold, min_value, max_value, consecutive_readings, enabled, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, created_at, updated_at
+	`, schema)
+
+	err := r.db.QueryRow(query,
+		rule.OrganizationID, rule.SensorID, rule.SensorTypeID, rule.Name, rule.Condition,
+		rule.Threshold, rule.MinValue, rule.MaxValue, rule.ConsecutiveReadings, rule.Enabled, rule.CreatedBy).
+		Scan(&rule.ID, &rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create alert rule: %w", err)
+	}
+
+	return nil
+}
+
+// GetRuleByID retrieves an alert rule by ID
+func (r *repository) GetRuleByID(id int) (*Rule, error) {
+	args := []interface{}{id}
+	orgClause, args := r.orgFilter("organization_id", args)
+	sensorClause, args := r.sensorFilter("sensor_id", args)
+
+	query := fmt.Sprintf(`
+		SELECT %s FROM %s.alert_rules WHERE id = $1%s%s
+	`, ruleColumns, schema, orgClause, sensorClause)
+
+	rule, err := scanRule(r.db.QueryRow(query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRuleNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get alert rule: %w", err)
+	}
+
+	return rule, nil
+}
+
+// ListRules retrieves the alert rules matching the filter
+func (r *repository) ListRules(filter RuleFilter) ([]*Rule, error) {
+	whereParts := []string{}
+	args := []interface{}{}
+	argIndex := 1
+
+	if filter.SensorID != nil {
+		whereParts = append(whereParts, fmt.Sprintf("sensor_id = $%d", argIndex))
+		args = append(args, *filter.SensorID)
+		argIndex++
+	}
+
+	if filter.SensorTypeID != nil {
+		whereParts = append(whereParts, fmt.Sprintf("sensor_type_id = $%d", argIndex))
+		args = append(args, *filter.SensorTypeID)
+		argIndex++
+	}
+
+	if filter.Enabled != nil {
+		whereParts = append(whereParts, fmt.Sprintf("enabled = $%d", argIndex))
+		args = append(args, *filter.Enabled)
+		argIndex++
+	}
+
+	whereClause := "true"
+	if len(whereParts) > 0 {
+		whereClause = strings.Join(whereParts, " AND ")
+	}
+	orgClause, args := r.orgFilter("organization_id", args)
+	sensorClause, args := r.sensorFilter("sensor_id", args)
+
+	query := fmt.Sprintf(`
+		SELECT %s FROM %s.alert_rules
+		WHERE %s%s%s
+		ORDER BY name, id
+	`, ruleColumns, schema, whereClause, orgClause, sensorClause)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list alert rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []*Rule{}
+	for rows.Next() {
+		rule, err := scanRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan alert rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// UpdateRule stores the editable fields of an alert rule
+func (r *repository) UpdateRule(rule *Rule) error {
+	args := []interface{}{
+		rule.ID, rule.Name, rule.Condition, rule.Threshold, rule.MinValue, rule.MaxValue,
+		rule.ConsecutiveReadings, rule.Enabled,
+	}
+	orgClause, args := r.orgFilter("organization_id", args)
+	sensorClause, args := r.sensorFilter("sensor_id", args)
+
+	query := fmt.Sprintf(`
+		UPDATE %s.alert_rules
+		SET name = $2, condition = $3, threshold = $4, min_value = $5, max_value = $6,
+		    consecutive_readings = $7, enabled = $8, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1%s%s
+		RETURNING updated_at
+	`, schema, orgClause, sensorClause)
+
+	err := r.db.QueryRow(query, args...).Scan(&rule.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrRuleNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update alert rule: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteRule deletes an alert rule together with its alerts
+func (r *repository) DeleteRule(id int) error {
+	args := []interface{}{id}
+	orgClause, args := r.orgFilter("organization_id", args)
+	sensorClause, args := r.sensorFilter("sensor_id", args)
+
+	query := fmt.Sprintf(`
+		DELETE FROM %s.alert_rules WHERE id = $1%s%s
+	`, schema, orgClause, sensorClause)
+
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to delete alert rule: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrRuleNotFound
+	}
+
+	return nil
+}
+
+// ListEnabledRulesForSensor retrieves the enabled rules of the sensor's
+// organization watching the sensor itself or its sensor type
+func (r *repository) ListEnabledRulesForSensor(sensorID int) ([]*Rule, error) {
+	query := fmt.Sprintf(`
+		SELECT %s FROM %s.alert_rules
+		WHERE enabled = true
+		  AND organization_id = (SELECT organization_id FROM %s.sensors WHERE id = $1)
+		  AND (sensor_id = $1 OR sensor_type_id = (SELECT sensor_type_id FROM %s.sensors WHERE id = $1))
+		ORDER BY id
+	`, ruleColumns, schema, schema, schema)
+
+	rows, err := r.db.Query(query, sensorID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list alert rules for sensor: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []*Rule{}
+	for rows.Next() {
+		rule, err := scanRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan alert rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// SensorOrganization returns the organization of a sensor visible in scope
+func (r *repository) SensorOrganization(sensorID int) (int, error) {
+	args := []interface{}{sensorID}
+	orgClause, args := r.orgFilter("organization_id", args)
+	sensorClause, args := r.sensorFilter("id", args)
+
+	query := fmt.Sprintf(`
+		SELECT organization_id FROM %s.sensors WHERE id = $1 AND is_active = true%s%s
+	`, schema, orgClause, sensorClause)
+
+	var organizationID int
+	err := r.db.QueryRow(query, args...).Scan(&organizationID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrSensorNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get sensor: %w", err)
+	}
+
+	return organizationID, nil
+}
+
+// SensorTypeExists checks if an active sensor type exists
+func (r *repository) SensorTypeExists(sensorTypeID int) (bool, error) {
+	query := fmt.Sprintf(`
+		SELECT EXISTS(SELECT 1 FROM %s.sensor_types WHERE id = $1 AND is_active = true)
+	`, schema)
+
+	var exists bool
+	if err := r.db.QueryRow(query, sensorTypeID).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check sensor type: %w", err)
+	}
+
+	return exists, nil
+}
+
+// LatestValues returns the values of a sensor's latest readings, newest first
+func (r *repository) LatestValues(sensorID, limit int) ([]float64, error) {
+	query := fmt.Sprintf(`
+		SELECT value FROM %s.sensor_readings
+		WHERE sensor_id = $1
+		ORDER BY timestamp DESC
+		LIMIT $2
+	`, schema)
+
+	rows, err := r.db.Query(query, sensorID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest readings: %w", err)
+	}
+	defer rows.Close()
+
+	values := []float64{}
+	for rows.Next() {
+		var value float64
+		if err := rows.Scan(&value); err != nil {
+			return nil, fmt.Errorf("failed to scan reading value: %w", err)
+		}
+		values = append(values, value)
+	}
+
+	return values, nil
+}
+
+// CreateAlert creates a new alert
+func (r *repository) CreateAlert(alert *Alert) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s.alerts (rule_id, sensor_id, organization_id, state, value, message)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, triggered_at
+	`, schema)
+
+	err := r.db.QueryRow(query,
+		alert.RuleID, alert.SensorID, alert.OrganizationID, alert.State, alert.Value, alert.Message).
+		Scan(&alert.ID, &alert.TriggeredAt)
+	if err != nil {
+		if database.IsUniqueViolation(err) {
+			return ErrAlertExists
+		}
+		return fmt.Errorf("failed to create alert: %w", err)
+	}
+
+	return nil
+}
+
+// GetAlertByID retrieves an alert by ID
+func (r *repository) GetAlertByID(id int64) (*Alert, error) {
+	args := []interface{}{id}
+	orgClause, args := r.orgFilter("organization_id", args)
+	sensorClause, args := r.sensorFilter("sensor_id", args)
+
+	query := fmt.Sprintf(`
+		SELECT %s FROM %s.alerts WHERE id = $1%s%s
+	`, alertColumns, schema, orgClause, sensorClause)
+
+	alert, err := scanAlert(r.db.QueryRow(query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAlertNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get alert: %w", err)
+	}
+
+	return alert, nil
+}
+
+// GetUnresolvedAlert retrieves the open or acknowledged alert of a rule for a sensor
+func (r *repository) GetUnresolvedAlert(ruleID, sensorID int) (*Alert, error) {
+	query := fmt.Sprintf(`
+		SELECT %s FROM %s.alerts
+		WHERE rule_id = $1 AND sensor_id = $2 AND state <> '%s'
+	`, alertColumns, schema, StateResolved)
+
+	alert, err := scanAlert(r.db.QueryRow(query, ruleID, sensorID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAlertNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get unresolved alert: %w", err)
+	}
+
+	return alert, nil
+}
+
+// ListAlerts retrieves alerts matching the query, newest first
+func (r *repository) ListAlerts(query *Query) ([]*Alert, int, error) {
+	// Build WHERE clause
+	whereParts := []string{}
+	args := []interface{}{}
+	argIndex := 1
+
+	if query.State != "" {
+		whereParts = append(whereParts, fmt.Sprintf("state = $%d", argIndex))
+		args = append(args, query.State)
+		argIndex++
+	}
+
+	if query.SensorID != nil {
+		whereParts = append(whereParts, fmt.Sprintf("sensor_id = $%d", argIndex))
+		args = append(args, *query.SensorID)
+		argIndex++
+	}
+
+	if query.RuleID != nil {
+		whereParts = append(whereParts, fmt.Sprintf("rule_id = $%d", argIndex))
+		args = append(args, *query.RuleID)
+		argIndex++
+	}
+
+	if query.StartTime != nil {
+		whereParts = append(whereParts, fmt.Sprintf("triggered_at >= $%d", argIndex))
+		args = append(args, *query.StartTime)
+		argIndex++
+	}
+
+	if query.EndTime != nil {
+		whereParts = append(whereParts, fmt.Sprintf("triggered_at <= $%d", argIndex))
+		args = append(args, *query.EndTime)
+		argIndex++
+	}
+
+	whereClause := "true"
+	if len(whereParts) > 0 {
+		whereClause = strings.Join(whereParts, " AND ")
+	}
+	orgClause, args := r.orgFilter("organization_id", args)
+	sensorClause, args := r.sensorFilter("sensor_id", args)
+	argIndex = len(args) + 1
+
+	// Get total count
+	countQuery := fmt.Sprintf(`
+		SELECT COUNT(*) FROM %s.alerts WHERE %s%s%s
+	`, schema, whereClause, orgClause, sensorClause)
+
+	var total int
+	if err := r.db.QueryRow(countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count alerts: %w", err)
+	}
+
+	// Add limit and offset to args
+	args = append(args, query.Limit, query.Offset)
+
+	listQuery := fmt.Sprintf(`
+		SELECT %s FROM %s.alerts
+		WHERE %s%s%s
+		ORDER BY triggered_at DESC, id DESC
+		LIMIT $%d OFFSET $%d
+	`, alertColumns, schema, whereClause, orgClause, sensorClause, argIndex, argIndex+1)
+
+	rows, err := r.db.Query(listQuery, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list alerts: %w", err)
+	}
+	defer rows.Close()
+
+	alerts := []*Alert{}
+	for rows.Next() {
+		alert, err := scanAlert(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan alert: %w", err)
+		}
+		alerts = append(alerts, alert)
+	}
+
+	return alerts, total, nil
+}
+
+// AcknowledgeAlert marks an open alert as acknowledged by the user
+func (r *repository) AcknowledgeAlert(id int64, userID int) (*Alert, error) {
+	args := []interface{}{id, userID}
+	orgClause, args := r.orgFilter("organization_id", args)
+	sensorClause, args := r.sensorFilter("sensor_id", args)
+
+	query := fmt.Sprintf(`
+		UPDATE %s.alerts
+		SET state = '%s', acknowledged_at = CURRENT_TIMESTAMP, acknowledged_by = $2
+		WHERE id = $1 AND state = '%s'%s%s
+		RETURNING %s
+	`, schema, StateAcknowledged, StateOpen, orgClause, sensorClause, alertColumns)
+
+	alert, err := scanAlert(r.db.QueryRow(query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAlertNotOpen
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to acknowledge alert: %w", err)
+	}
+
+	return alert, nil
+}
+
+// ResolveAlert marks an unresolved alert as resolved
+func (r *repository) ResolveAlert(id int64) error {
+	query := fmt.Sprintf(`
+		UPDATE %s.alerts
+		SET state = '%s', resolved_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND state <> '%s'
+	`, schema, StateResolved, StateResolved)
+
+	if _, err := r.db.Exec(query, id); err != nil {
+		return fmt.Errorf("failed to resolve alert: %w", err)
+	}
+
+	return nil
+}
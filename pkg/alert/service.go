@@ -0,0 +1,250 @@
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"user-management/pkg/webhook"
+	"user-management/shared/interfaces"
+)
+
+// Service defines alert service interface
+type Service interface {
+	// Rule management
+	CreateRule(req *CreateRuleRequest, createdBy int) (*Rule, error)
+	GetRule(id int) (*Rule, error)
+	ListRules(filter RuleFilter) ([]*Rule, error)
+	UpdateRule(id int, req *UpdateRuleRequest) (*Rule, error)
+	DeleteRule(id int) error
+
+	// Alerts
+	ListAlerts(query *Query) ([]*Alert, int, error)
+	AcknowledgeAlert(id int64, userID int) (*Alert, error)
+
+	// EvaluateSensor checks the rules of a sensor against its latest readings
+	EvaluateSensor(sensorID int) error
+
+	// WithScope returns a service limited to the organization in scope
+	WithScope(scope interfaces.Scope) Service
+
+	// WithSensors returns a service limited to the given sensors
+	WithSensors(sensorIDs []int) Service
+}
+
+// Notifier is told about alerts being triggered and resolved, such as the
+// webhook dispatcher
+type Notifier interface {
+	Publish(eventType string, organizationID int, data interface{})
+}
+
+// service implements Service interface
+type service struct {
+	repo     Repository
+	notifier Notifier
+	scope    interfaces.Scope
+}
+
+// NewService creates a new alert service; notifier may be nil
+func NewService(repo Repository, notifier Notifier) Service {
+	return &service{repo: repo, notifier: notifier}
+}
+
+// WithScope returns a copy of the service whose rules and alerts are
+// limited to the scope. Sensor type rules created through it belong to the
+// scope's organization.
+func (s *service) WithScope(scope interfaces.Scope) Service {
+	return &service{
+		repo:     s.repo.WithScope(scope),
+		notifier: s.notifier,
+		scope:    scope,
+	}
+}
+
+// WithSensors returns a copy of the service whose alerts and sensor rules
+// are limited to the given sensors, such as those a user can read. Sensor
+// type rules are not limited.
+func (s *service) WithSensors(sensorIDs []int) Service {
+	return &service{
+		repo:     s.repo.WithSensors(sensorIDs),
+		notifier: s.notifier,
+		scope:    s.scope,
+	}
+}
+
+// CreateRule creates an alert rule. A sensor rule belongs to the sensor's
+// organization, a sensor type rule to the organization in scope.
+func (s *service) CreateRule(req *CreateRuleRequest, createdBy int) (*Rule, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	rule := req.toRule()
+	rule.OrganizationID = s.scope.OrganizationID
+	rule.CreatedBy = &createdBy
+
+	if req.SensorID != nil {
+		organizationID, err := s.repo.SensorOrganization(*req.SensorID)
+		if err != nil {
+			return nil, err
+		}
+		rule.OrganizationID = organizationID
+	}
+
+	if req.SensorTypeID != nil {
+		exists, err := s.repo.SensorTypeExists(*req.SensorTypeID)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, ErrSensorTypeNotFound
+		}
+	}
+
+	if err := s.repo.CreateRule(rule); err != nil {
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+// GetRule retrieves an alert rule by ID
+func (s *service) GetRule(id int) (*Rule, error) {
+	return s.repo.GetRuleByID(id)
+}
+
+// ListRules retrieves alert rules matching the filter
+func (s *service) ListRules(filter RuleFilter) ([]*Rule, error) {
+	return s.repo.ListRules(filter)
+}
+
+// UpdateRule updates an alert rule
+func (s *service) UpdateRule(id int, req *UpdateRuleRequest) (*Rule, error) {
+	rule, err := s.repo.GetRuleByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := req.Apply(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.UpdateRule(updated); err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// DeleteRule deletes an alert rule and its alerts
+func (s *service) DeleteRule(id int) error {
+	return s.repo.DeleteRule(id)
+}
+
+// ListAlerts retrieves alerts matching the query
+func (s *service) ListAlerts(query *Query) ([]*Alert, int, error) {
+	if query.State != "" && !ValidState(query.State) {
+		return nil, 0, ErrInvalidState
+	}
+
+	return s.repo.ListAlerts(query)
+}
+
+// AcknowledgeAlert acknowledges an open alert
+func (s *service) AcknowledgeAlert(id int64, userID int) (*Alert, error) {
+	if _, err := s.repo.GetAlertByID(id); err != nil {
+		return nil, err
+	}
+
+	return s.repo.AcknowledgeAlert(id, userID)
+}
+
+// EvaluateSensor checks every enabled rule watching the sensor against its
+// latest readings. A rule triggers an alert once its ConsecutiveReadings
+// latest readings all breach it, and the alert is resolved as soon as the
+// latest reading no longer does.
+func (s *service) EvaluateSensor(sensorID int) error {
+	rules, err := s.repo.ListEnabledRulesForSensor(sensorID)
+	if err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+
+	needed := 1
+	for _, rule := range rules {
+		if rule.ConsecutiveReadings > needed {
+			needed = rule.ConsecutiveReadings
+		}
+	}
+
+	values, err := s.repo.LatestValues(sensorID, needed)
+	if err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	for _, rule := range rules {
+		active, err := s.repo.GetUnresolvedAlert(rule.ID, sensorID)
+		if err != nil && !errors.Is(err, ErrAlertNotFound) {
+			return err
+		}
+
+		switch {
+		case active == nil && breachedInRow(rule, values):
+			alert := &Alert{
+				RuleID:         rule.ID,
+				SensorID:       sensorID,
+				OrganizationID: rule.OrganizationID,
+				State:          StateOpen,
+				Value:          values[0],
+				Message:        rule.Describe(values[0]),
+			}
+			err := s.repo.CreateAlert(alert)
+			if errors.Is(err, ErrAlertExists) {
+				// A concurrent evaluation opened the alert already
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("rule %d: %w", rule.ID, err)
+			}
+			s.notify(webhook.EventAlertTriggered, alert)
+		case active != nil && !rule.Breached(values[0]):
+			if err := s.repo.ResolveAlert(active.ID); err != nil {
+				return fmt.Errorf("rule %d: %w", rule.ID, err)
+			}
+			resolvedAt := time.Now()
+			active.State = StateResolved
+			active.ResolvedAt = &resolvedAt
+			s.notify(webhook.EventAlertResolved, active)
+		}
+	}
+
+	return nil
+}
+
+// notify publishes an alert event when a notifier is configured
+func (s *service) notify(eventType string, alert *Alert) {
+	if s.notifier != nil {
+		s.notifier.Publish(eventType, alert.OrganizationID, alert)
+	}
+}
+
+// breachedInRow checks if the rule's ConsecutiveReadings latest values,
+// given newest first, all breach it
+func breachedInRow(rule *Rule, values []float64) bool {
+	if len(values) < rule.ConsecutiveReadings {
+		return false
+	}
+
+	for _, value := range values[:rule.ConsecutiveReadings] {
+		if !rule.Breached(value) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,110 @@
+package audit
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	"user-management/shared/interfaces"
+	"user-management/shared/middleware"
+	"user-management/shared/response"
+)
+
+// Handler handles HTTP requests for audit log operations
+type Handler struct {
+	service Service
+	authMW  *middleware.AuthMiddleware
+}
+
+// NewHandler creates a new audit handler
+func NewHandler(service Service, authMW *middleware.AuthMiddleware) *Handler {
+	return &Handler{
+		service: service,
+		authMW:  authMW,
+	}
+}
+
+// RegisterRoutes registers all audit routes
+func (h *Handler) RegisterRoutes(mux interfaces.Router) {
+	// Admin routes
+	mux.Handle("GET /api/audit-logs", h.authMW.Authenticate(h.authMW.RequireAdmin(http.HandlerFunc(h.ListLogs))))
+}
+
+// scopedService returns the service limited to the organization of the
+// authenticated user; super admins are not limited
+func (h *Handler) scopedService(r *http.Request) Service {
+	scope, ok := middleware.GetScopeFromContext(r.Context())
+	if !ok {
+		// Fail closed: a restricted scope without organization matches nothing
+		scope = interfaces.Scope{Restricted: true}
+	}
+	return h.service.WithScope(scope)
+}
+
+// ListLogs handles listing audit entries with filters and pagination
+func (h *Handler) ListLogs(w http.ResponseWriter, r *http.Request) {
+	// Parse query parameters
+	page := 1
+	perPage := 20
+
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if perPageStr := r.URL.Query().Get("per_page"); perPageStr != "" {
+		if pp, err := strconv.Atoi(perPageStr); err == nil && pp > 0 && pp <= 100 {
+			perPage = pp
+		}
+	}
+
+	query := &Query{
+		ResourceType: r.URL.Query().Get("resource_type"),
+		Limit:        perPage,
+		Offset:       (page - 1) * perPage,
+	}
+
+	if actorStr := r.URL.Query().Get("actor_id"); actorStr != "" {
+		actorID, err := strconv.Atoi(actorStr)
+		if err != nil {
+			response.BadRequest(w, "Invalid actor ID", err)
+			return
+		}
+		query.ActorID = &actorID
+	}
+
+	if startStr := r.URL.Query().Get("start_time"); startStr != "" {
+		startTime, err := time.Parse(time.RFC3339, startStr)
+		if err != nil {
+			response.BadRequest(w, "Invalid start_time format, use RFC3339", err)
+			return
+		}
+		query.StartTime = &startTime
+	}
+
+	if endStr := r.URL.Query().Get("end_time"); endStr != "" {
+		endTime, err := time.Parse(time.RFC3339, endStr)
+		if err != nil {
+			response.BadRequest(w, "Invalid end_time format, use RFC3339", err)
+			return
+		}
+		query.EndTime = &endTime
+	}
+
+	entries, total, err := h.scopedService(r).ListLogs(query)
+	if err != nil {
+		response.InternalServerError(w, "Failed to list audit logs", err)
+		return
+	}
+
+	// Calculate pagination meta
+	totalPages := (total + perPage - 1) / perPage
+	meta := &response.Meta{
+		Page:       page,
+		PerPage:    perPage,
+		Total:      total,
+		TotalPages: totalPages,
+	}
+
+	response.PaginatedSuccess(w, "Audit logs retrieved successfully", entries, meta)
+}
@@ -0,0 +1,107 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"user-management/shared/interfaces"
+	"user-management/shared/middleware"
+)
+
+// stubAuth authenticates every Bearer token as user
+type stubAuth struct {
+	user *interfaces.User
+}
+
+func (a stubAuth) GetUserFromToken(ctx context.Context, tokenString string) (*interfaces.User, error) {
+	return a.user, nil
+}
+
+func (a stubAuth) GetUserFromAPIKey(ctx context.Context, key string) (*interfaces.User, error) {
+	return nil, errors.New("api keys are not supported")
+}
+
+func (a stubAuth) HasPermission(ctx context.Context, userID int, resource, action string) (bool, error) {
+	return a.user.HasPermission(resource, action), nil
+}
+
+// listLogs requests target from the audit routes as user, returning the
+// response and the query the repository received
+func listLogs(t *testing.T, user *interfaces.User, target string) (*httptest.ResponseRecorder, *Query) {
+	t.Helper()
+
+	repo := &queryRepository{}
+	mux := http.NewServeMux()
+	NewHandler(NewService(repo), middleware.NewAuthMiddleware(stubAuth{user: user})).RegisterRoutes(mux)
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set("Authorization", "Bearer test")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec, repo.query
+}
+
+// queryRepository records the query of the last listing
+type queryRepository struct {
+	memoryRepository
+	query *Query
+}
+
+func (r *queryRepository) List(query *Query) ([]*Entry, int, error) {
+	r.query = query
+	return []*Entry{}, 0, nil
+}
+
+func (r *queryRepository) WithScope(scope interfaces.Scope) Repository {
+	return r
+}
+
+// TestListLogsRequiresAdmin only lists audit logs for admins
+func TestListLogsRequiresAdmin(t *testing.T) {
+	member := &interfaces.User{ID: 2, OrganizationID: 1, Roles: []interfaces.Role{{Name: "user", IsActive: true}}}
+
+	rec, query := listLogs(t, member, "/api/audit-logs")
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("got status %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	if query != nil {
+		t.Error("audit logs were listed for a non-admin")
+	}
+}
+
+// TestListLogsFilters passes the actor, resource type and time range
+// filters to the repository
+func TestListLogsFilters(t *testing.T) {
+	admin := &interfaces.User{ID: 1, OrganizationID: 1, Roles: []interfaces.Role{{Name: "admin", IsActive: true}}}
+
+	rec, query := listLogs(t, admin,
+		"/api/audit-logs?actor_id=7&resource_type=sensor&start_time=2024-01-01T00:00:00Z&end_time=2024-02-01T00:00:00Z&page=2&per_page=10")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+
+	if query.ActorID == nil || *query.ActorID != 7 {
+		t.Errorf("got actor %v, want 7", query.ActorID)
+	}
+	if query.ResourceType != ResourceSensor {
+		t.Errorf("got resource type %q, want %q", query.ResourceType, ResourceSensor)
+	}
+	if query.StartTime == nil || !query.StartTime.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("got start time %v", query.StartTime)
+	}
+	if query.EndTime == nil || !query.EndTime.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("got end time %v", query.EndTime)
+	}
+	if query.Limit != 10 || query.Offset != 10 {
+		t.Errorf("got limit %d offset %d, want 10 and 10", query.Limit, query.Offset)
+	}
+
+	for _, target := range []string{"/api/audit-logs?actor_id=x", "/api/audit-logs?start_time=yesterday"} {
+		if rec, _ := listLogs(t, admin, target); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: got status %d, want %d", target, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
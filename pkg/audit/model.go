@@ -0,0 +1,98 @@
+package audit
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Entry represents a single audited operation
+type Entry struct {
+	ID      int64 `json:"id"`
+	ActorID *int  `json:"actor_id,omitempty"`
+	// OrganizationID is the organization of the actor
+	OrganizationID *int            `json:"organization_id,omitempty"`
+	Action         string          `json:"action"`
+	ResourceType   string          `json:"resource_type"`
+	ResourceID     string          `json:"resource_id"`
+	Changes        json.RawMessage `json:"changes,omitempty"`
+	IPAddress      string          `json:"ip_address,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// Query represents filters for listing audit entries
+type Query struct {
+	ActorID      *int       `json:"actor_id,omitempty"`
+	ResourceType string     `json:"resource_type,omitempty"`
+	StartTime    *time.Time `json:"start_time,omitempty"`
+	EndTime      *time.Time `json:"end_time,omitempty"`
+	Limit        int        `json:"limit"`
+	Offset       int        `json:"offset"`
+}
+
+// Resource types
+const (
+	ResourceUser         = "user"
+	ResourceRole         = "role"
+	ResourceSensor       = "sensor"
+	ResourceLocation     = "location"
+	ResourceOrganization = "organization"
+	ResourceInvitation   = "invitation"
+	ResourceSensorAccess = "sensor_access"
+	ResourceSensorType   = "sensor_type"
+	ResourceAlertRule    = "alert_rule"
+	ResourceAlert        = "alert"
+	ResourceWebhook      = "webhook"
+	ResourceSensorGroup  = "sensor_group"
+)
+
+// Actions
+const (
+	ActionUserUpdate           = "user.update"
+	ActionUserDeactivate       = "user.deactivate"
+	ActionUserActivate         = "user.activate"
+	ActionUserErase            = "user.erase"
+	ActionUserDisable2FA       = "user.disable_2fa"
+	ActionUserMoveOrganization = "user.move_organization"
+	ActionUserInvite           = "user.invite"
+	ActionRoleCreate           = "role.create"
+	ActionRoleUpdate           = "role.update"
+	ActionRoleDelete           = "role.delete"
+	ActionRoleAssign           = "role.assign"
+	ActionRoleRemove           = "role.remove"
+	ActionRolePermissionAdd    = "role.permission_add"
+	ActionRolePermissionRemove = "role.permission_remove"
+	ActionSensorCreate         = "sensor.create"
+	ActionSensorClone          = "sensor.clone"
+	ActionSensorUpdate         = "sensor.update"
+	ActionSensorDelete         = "sensor.delete"
+	ActionSensorActivate       = "sensor.activate"
+	ActionSensorPurge          = "sensor.purge"
+	ActionSensorCalibrate      = "sensor.calibrate"
+	ActionSensorApprove        = "sensor.approve"
+	ActionSensorTokenRotate    = "sensor.token_rotate"
+	ActionSensorCommand        = "sensor.command"
+	ActionSensorMaintenance    = "sensor.maintenance"
+	ActionSensorNoteDelete     = "sensor.note_delete"
+	ActionReadingsPurge        = "sensor_readings.purge"
+	ActionReadingUpdate        = "sensor_readings.update"
+	ActionReadingsDelete       = "sensor_readings.delete"
+	ActionLocationDelete       = "location.delete"
+	ActionLocationAssign       = "location.assign_sensors"
+	ActionSensorTypeCreate     = "sensor_type.create"
+	ActionSensorTypeUpdate     = "sensor_type.update"
+	ActionSensorTypeDelete     = "sensor_type.delete"
+	ActionSensorAccessGrant    = "sensor.access_grant"
+	ActionSensorAccessRevoke   = "sensor.access_revoke"
+	ActionOrganizationCreate   = "organization.create"
+	ActionAlertRuleCreate      = "alert_rule.create"
+	ActionAlertRuleUpdate      = "alert_rule.update"
+	ActionAlertRuleDelete      = "alert_rule.delete"
+	ActionAlertAcknowledge     = "alert.acknowledge"
+	ActionSensorGroupCreate    = "sensor_group.create"
+	ActionSensorGroupUpdate    = "sensor_group.update"
+	ActionSensorGroupDelete    = "sensor_group.delete"
+	ActionSensorGroupMembers   = "sensor_group.members_update"
+	ActionWebhookCreate        = "webhook.create"
+	ActionWebhookUpdate        = "webhook.update"
+	ActionWebhookDelete        = "webhook.delete"
+)
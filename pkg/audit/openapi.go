@@ -0,0 +1,9 @@
+package audit
+
+import "user-management/shared/openapi"
+
+// Operations documents the audit routes in the OpenAPI document
+var Operations = []openapi.Operation{
+	{Pattern: "GET /api/audit-logs", Tag: "audit", Summary: "List audit log entries", Access: openapi.AccessAdmin,
+		Query: []string{"actor_id", "resource_type", "start_time", "end_time", "page", "per_page"}, Response: []*Entry{}, Paginated: true},
+}
@@ -0,0 +1,155 @@
+package audit
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"user-management/shared/interfaces"
+)
+
+// Repository defines audit repository interface
+type Repository interface {
+	Create(entry *Entry) error
+	List(query *Query) ([]*Entry, int, error)
+
+	// WithScope returns a repository limited to the organization in scope
+	WithScope(scope interfaces.Scope) Repository
+}
+
+// repository implements Repository interface
+type repository struct {
+	db    *sql.DB
+	scope interfaces.Scope
+}
+
+// NewRepository creates a new audit repository
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
+}
+
+// WithScope returns a copy of the repository limited to the scope
+func (r *repository) WithScope(scope interfaces.Scope) Repository {
+	return &repository{db: r.db, scope: scope}
+}
+
+// Schema name constant
+const schema = "user_management"
+
+// Create stores a new audit entry
+func (r *repository) Create(entry *Entry) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s.audit_logs (actor_id, organization_id, action, resource_type, resource_id, changes, ip_address)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`, schema)
+
+	var changes interface{}
+	if len(entry.Changes) > 0 {
+		changes = []byte(entry.Changes)
+	}
+
+	err := r.db.QueryRow(query,
+		entry.ActorID, entry.OrganizationID, entry.Action, entry.ResourceType, entry.ResourceID, changes, entry.IPAddress).
+		Scan(&entry.ID, &entry.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// List retrieves audit entries matching the query, newest first
+func (r *repository) List(query *Query) ([]*Entry, int, error) {
+	// Build WHERE clause
+	whereParts := []string{}
+	args := []interface{}{}
+	argIndex := 1
+
+	// Entries without an organization were made by no one in particular and
+	// are only listed unrestricted
+	if r.scope.Restricted {
+		whereParts = append(whereParts, fmt.Sprintf("organization_id = $%d", argIndex))
+		args = append(args, r.scope.OrganizationID)
+		argIndex++
+	}
+
+	if query.ActorID != nil {
+		whereParts = append(whereParts, fmt.Sprintf("actor_id = $%d", argIndex))
+		args = append(args, *query.ActorID)
+		argIndex++
+	}
+
+	if query.ResourceType != "" {
+		whereParts = append(whereParts, fmt.Sprintf("resource_type = $%d", argIndex))
+		args = append(args, query.ResourceType)
+		argIndex++
+	}
+
+	if query.StartTime != nil {
+		whereParts = append(whereParts, fmt.Sprintf("created_at >= $%d", argIndex))
+		args = append(args, *query.StartTime)
+		argIndex++
+	}
+
+	if query.EndTime != nil {
+		whereParts = append(whereParts, fmt.Sprintf("created_at <= $%d", argIndex))
+		args = append(args, *query.EndTime)
+		argIndex++
+	}
+
+	whereClause := ""
+	if len(whereParts) > 0 {
+		whereClause = "WHERE " + strings.Join(whereParts, " AND ")
+	}
+
+	// Get total count
+	countQuery := fmt.Sprintf(`
+		SELECT COUNT(*) FROM %s.audit_logs %s
+	`, schema, whereClause)
+
+	var total int
+	if err := r.db.QueryRow(countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count audit entries: %w", err)
+	}
+
+	// Add limit and offset to args
+	args = append(args, query.Limit, query.Offset)
+
+	listQuery := fmt.Sprintf(`
+		SELECT id, actor_id, organization_id, action, resource_type, COALESCE(resource_id, ''), changes,
+		       COALESCE(ip_address, ''), created_at
+		FROM %s.audit_logs
+		%s
+		ORDER BY created_at DESC
+		LIMIT $%d OFFSET $%d
+	`, schema, whereClause, argIndex, argIndex+1)
+
+	rows, err := r.db.Query(listQuery, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []*Entry{}
+	for rows.Next() {
+		entry := &Entry{}
+		var changes []byte
+		err := rows.Scan(
+			&entry.ID, &entry.ActorID, &entry.OrganizationID, &entry.Action, &entry.ResourceType, &entry.ResourceID,
+			&changes, &entry.IPAddress, &entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		if len(changes) > 0 {
+			entry.Changes = changes
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to list audit entries: %w", err)
+	}
+
+	return entries, total, nil
+}
@@ -0,0 +1,53 @@
+package audit
+
+import (
+	"testing"
+	"time"
+	"user-management/database/dbtest"
+	"user-management/shared/interfaces"
+)
+
+// TestListFiltersEntries lists entries by actor, resource type, time range
+// and organization
+func TestListFiltersEntries(t *testing.T) {
+	repo := NewRepository(dbtest.Migrated(t))
+
+	// The entry without an organization was made by no one in particular
+	org := interfaces.DefaultOrganizationID
+	for _, entry := range []*Entry{
+		{Action: ActionSensorDelete, ResourceType: ResourceSensor, ResourceID: "1", OrganizationID: &org},
+		{Action: ActionUserDeactivate, ResourceType: ResourceUser, ResourceID: "2", OrganizationID: &org},
+		{Action: ActionSensorCreate, ResourceType: ResourceSensor, ResourceID: "3"},
+	} {
+		if err := repo.Create(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	tests := []struct {
+		name  string
+		repo  Repository
+		query Query
+		want  int
+	}{
+		{"all", repo, Query{}, 3},
+		{"resource type", repo, Query{ResourceType: ResourceSensor}, 2},
+		{"time range", repo, Query{StartTime: &past, EndTime: &future}, 3},
+		{"after range", repo, Query{StartTime: &future}, 0},
+		{"organization", repo.WithScope(interfaces.Scope{OrganizationID: org, Restricted: true}), Query{}, 2},
+		{"other organization", repo.WithScope(interfaces.Scope{OrganizationID: org + 1, Restricted: true}), Query{}, 0},
+	}
+
+	for _, tt := range tests {
+		tt.query.Limit = 10
+		entries, total, err := tt.repo.List(&tt.query)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if total != tt.want || len(entries) != tt.want {
+			t.Errorf("%s: got %d entries of %d, want %d", tt.name, len(entries), total, tt.want)
+		}
+	}
+}
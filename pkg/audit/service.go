@@ -0,0 +1,73 @@
+package audit
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"user-management/shared/interfaces"
+	"user-management/shared/middleware"
+)
+
+// Recorder records audited operations performed through HTTP handlers
+type Recorder interface {
+	Record(r *http.Request, action, resourceType, resourceID string, changes interface{})
+}
+
+// Service defines audit service interface
+type Service interface {
+	Recorder
+	ListLogs(query *Query) ([]*Entry, int, error)
+
+	// WithScope returns a service whose listings are limited to the scope
+	WithScope(scope interfaces.Scope) Service
+}
+
+// service implements Service interface
+type service struct {
+	repo Repository
+}
+
+// NewService creates a new audit service
+func NewService(repo Repository) Service {
+	return &service{repo: repo}
+}
+
+// WithScope returns a copy of the service limited to the scope
+func (s *service) WithScope(scope interfaces.Scope) Service {
+	return &service{repo: s.repo.WithScope(scope)}
+}
+
+// Record stores an audit entry for the request's authenticated user.
+// Recording is best-effort: failures are logged and never returned to the caller.
+func (s *service) Record(r *http.Request, action, resourceType, resourceID string, changes interface{}) {
+	entry := &Entry{
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		IPAddress:    middleware.ClientIP(r),
+	}
+
+	if user, ok := middleware.GetUserFromContext(r.Context()); ok {
+		actorID, orgID := user.ID, user.OrganizationID
+		entry.ActorID = &actorID
+		entry.OrganizationID = &orgID
+	}
+
+	if changes != nil {
+		data, err := json.Marshal(changes)
+		if err != nil {
+			log.Printf("Warning: failed to encode audit changes for %s: %v", action, err)
+		} else {
+			entry.Changes = data
+		}
+	}
+
+	if err := s.repo.Create(entry); err != nil {
+		log.Printf("Warning: failed to record audit entry for %s: %v", action, err)
+	}
+}
+
+// ListLogs retrieves audit entries matching the query
+func (s *service) ListLogs(query *Query) ([]*Entry, int, error) {
+	return s.repo.List(query)
+}
@@ -0,0 +1,77 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"user-management/shared/interfaces"
+	"user-management/shared/middleware"
+)
+
+// memoryRepository keeps created entries in memory, failing with err when set
+type memoryRepository struct {
+	entries []*Entry
+	err     error
+}
+
+func (r *memoryRepository) Create(entry *Entry) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.entries = append(r.entries, entry)
+	return nil
+}
+
+func (r *memoryRepository) List(query *Query) ([]*Entry, int, error) {
+	return r.entries, len(r.entries), r.err
+}
+
+func (r *memoryRepository) WithScope(scope interfaces.Scope) Repository {
+	return r
+}
+
+// TestRecordStoresActor records the authenticated user as the actor of
+// the entry
+func TestRecordStoresActor(t *testing.T) {
+	repo := &memoryRepository{}
+	actor := &interfaces.User{ID: 7, OrganizationID: 3}
+
+	r := httptest.NewRequest("PUT", "/api/users/12/deactivate", nil)
+	r = r.WithContext(context.WithValue(r.Context(), middleware.UserContextKey, actor))
+	NewService(repo).Record(r, ActionUserDeactivate, ResourceUser, "12", map[string]bool{"is_active": false})
+
+	if len(repo.entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(repo.entries))
+	}
+	entry := repo.entries[0]
+	if entry.ActorID == nil || *entry.ActorID != actor.ID {
+		t.Errorf("got actor %v, want %d", entry.ActorID, actor.ID)
+	}
+	if entry.OrganizationID == nil || *entry.OrganizationID != actor.OrganizationID {
+		t.Errorf("got organization %v, want %d", entry.OrganizationID, actor.OrganizationID)
+	}
+	if entry.Action != ActionUserDeactivate || entry.ResourceType != ResourceUser || entry.ResourceID != "12" {
+		t.Errorf("got %s on %s %s", entry.Action, entry.ResourceType, entry.ResourceID)
+	}
+
+	var changes map[string]bool
+	if err := json.Unmarshal(entry.Changes, &changes); err != nil {
+		t.Fatal(err)
+	}
+	if active, ok := changes["is_active"]; !ok || active {
+		t.Errorf("got changes %s", entry.Changes)
+	}
+}
+
+// TestRecordIsBestEffort returns normally when the entry cannot be
+// encoded or stored
+func TestRecordIsBestEffort(t *testing.T) {
+	repo := &memoryRepository{err: errors.New("database unavailable")}
+	r := httptest.NewRequest("DELETE", "/api/sensors/5", nil)
+
+	service := NewService(repo)
+	service.Record(r, ActionSensorDelete, ResourceSensor, "5", nil)
+	service.Record(r, ActionSensorDelete, ResourceSensor, "5", map[string]interface{}{"unencodable": make(chan int)})
+}
@@ -0,0 +1,185 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// Bus tuning
+const (
+	// bufferSize is how many recent events are kept for Last-Event-ID replay
+	bufferSize = 256
+	// subscriberQueueSize is how many events a subscriber may fall behind
+	// before it is disconnected
+	subscriberQueueSize = 64
+)
+
+// ErrClosed is returned when subscribing to a closed bus
+var ErrClosed = errors.New("event bus is closed")
+
+// Event is one sensor or alert event published on the bus
+type Event struct {
+	ID             uint64 `json:"id"`
+	Type           string `json:"type"`
+	OrganizationID int    `json:"-"`
+	// SensorID is the sensor_id of the event data; every event published
+	// on the bus concerns one sensor
+	SensorID  int             `json:"-"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Publisher receives the events published on the bus, such as the webhook
+// dispatcher
+type Publisher interface {
+	Publish(eventType string, organizationID int, data interface{})
+}
+
+// Subscription receives the events published after it was created. Events
+// is closed when the bus closes or the subscriber falls too far behind.
+type Subscription struct {
+	Events <-chan *Event
+
+	events chan *Event
+	filter func(*Event) bool
+}
+
+// Bus fans events out to its publishers and to live subscribers, keeping
+// the most recent events so reconnecting subscribers can catch up
+type Bus struct {
+	publishers []Publisher
+
+	mu          sync.Mutex
+	nextID      uint64
+	buffer      []*Event
+	subscribers map[*Subscription]struct{}
+	closed      bool
+}
+
+// NewBus creates an event bus forwarding every event to the publishers
+func NewBus(publishers ...Publisher) *Bus {
+	return &Bus{
+		publishers:  publishers,
+		buffer:      make([]*Event, 0, bufferSize),
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish forwards the event to the publishers and delivers it to the
+// subscribers whose filter accepts it
+func (b *Bus) Publish(eventType string, organizationID int, data interface{}) {
+	for _, publisher := range b.publishers {
+		publisher.Publish(eventType, organizationID, data)
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Warning: failed to encode %s event: %v", eventType, err)
+		return
+	}
+
+	var ref struct {
+		SensorID int `json:"sensor_id"`
+	}
+	if err := json.Unmarshal(payload, &ref); err != nil {
+		log.Printf("Warning: failed to read sensor of %s event: %v", eventType, err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	b.nextID++
+	event := &Event{
+		ID:             b.nextID,
+		Type:           eventType,
+		OrganizationID: organizationID,
+		SensorID:       ref.SensorID,
+		CreatedAt:      time.Now().UTC(),
+		Data:           payload,
+	}
+
+	if len(b.buffer) == bufferSize {
+		copy(b.buffer, b.buffer[1:])
+		b.buffer = b.buffer[:bufferSize-1]
+	}
+	b.buffer = append(b.buffer, event)
+
+	for sub := range b.subscribers {
+		if sub.filter != nil && !sub.filter(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			// Never block publishers on a slow subscriber
+			b.remove(sub)
+		}
+	}
+}
+
+// Subscribe registers a subscriber for the events accepted by filter, a
+// nil filter accepting every event. The buffered events published after
+// lastEventID are returned for replay; a lastEventID of 0 replays nothing.
+func (b *Bus) Subscribe(lastEventID uint64, filter func(*Event) bool) ([]*Event, *Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, nil, ErrClosed
+	}
+
+	var missed []*Event
+	if lastEventID > 0 {
+		for _, event := range b.buffer {
+			if event.ID > lastEventID && (filter == nil || filter(event)) {
+				missed = append(missed, event)
+			}
+		}
+	}
+
+	events := make(chan *Event, subscriberQueueSize)
+	sub := &Subscription{
+		Events: events,
+		events: events,
+		filter: filter,
+	}
+	b.subscribers[sub] = struct{}{}
+
+	return missed, sub, nil
+}
+
+// Unsubscribe stops delivering events to the subscriber
+func (b *Bus) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.remove(sub)
+}
+
+// Close disconnects every subscriber and stops accepting new ones. Events
+// are still forwarded to the publishers.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subscribers {
+		b.remove(sub)
+	}
+}
+
+// remove closes and forgets a subscriber; callers hold b.mu
+func (b *Bus) remove(sub *Subscription) {
+	if _, ok := b.subscribers[sub]; !ok {
+		return
+	}
+	delete(b.subscribers, sub)
+	close(sub.events)
+}
@@ -0,0 +1,149 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"user-management/shared/interfaces"
+	"user-management/shared/middleware"
+	"user-management/shared/response"
+)
+
+// keepaliveInterval is how often an idle stream sends a comment so proxies
+// do not close it
+const keepaliveInterval = 30 * time.Second
+
+// Handler streams bus events to clients as server-sent events
+type Handler struct {
+	bus     *Bus
+	sensors interfaces.SensorAccessResolver
+	authMW  *middleware.AuthMiddleware
+}
+
+// NewHandler creates a new event stream handler; sensors limits users to
+// the events of the sensors they can read
+func NewHandler(bus *Bus, sensors interfaces.SensorAccessResolver, authMW *middleware.AuthMiddleware) *Handler {
+	return &Handler{
+		bus:     bus,
+		sensors: sensors,
+		authMW:  authMW,
+	}
+}
+
+// RegisterRoutes registers the event stream route
+func (h *Handler) RegisterRoutes(mux interfaces.Router) {
+	mux.Handle("GET /api/sensors/events", h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "read")(http.HandlerFunc(h.StreamEvents))))
+}
+
+// StreamEvents handles GET /api/sensors/events. Events of the sensors the
+// caller can read are sent as they are published; a Last-Event-ID header
+// first replays the buffered events the client missed. Readable sensors
+// are resolved once, so grants changed later apply on reconnect.
+func (h *Handler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	var lastEventID uint64
+	if header := r.Header.Get("Last-Event-ID"); header != "" {
+		id, err := strconv.ParseUint(header, 10, 64)
+		if err != nil {
+			response.BadRequest(w, "Invalid Last-Event-ID header", err)
+			return
+		}
+		lastEventID = id
+	}
+
+	scope, ok := middleware.GetScopeFromContext(r.Context())
+	if !ok {
+		// Fail closed: a restricted scope without organization matches nothing
+		scope = interfaces.Scope{Restricted: true}
+	}
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		response.Unauthorized(w, "User not found in context")
+		return
+	}
+
+	sensorIDs, restricted, err := h.sensors.ReadableSensorIDs(r.Context(), user)
+	if err != nil {
+		response.InternalServerError(w, "Failed to resolve sensor access", err)
+		return
+	}
+	readable := make(map[int]bool, len(sensorIDs))
+	for _, id := range sensorIDs {
+		readable[id] = true
+	}
+
+	filter := func(event *Event) bool {
+		if scope.Restricted && event.OrganizationID != scope.OrganizationID {
+			return false
+		}
+		return !restricted || readable[event.SensorID]
+	}
+
+	missed, sub, err := h.bus.Subscribe(lastEventID, filter)
+	if err != nil {
+		response.Error(w, http.StatusServiceUnavailable, "Event stream is unavailable", err)
+		return
+	}
+	defer h.bus.Unsubscribe(sub)
+
+	// The stream outlives the server's write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		response.InternalServerError(w, "Failed to start event stream", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	for _, event := range missed {
+		if err := writeEvent(w, event); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	keepalive := time.NewTicker(keepaliveInterval)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case event, ok := <-sub.Events:
+			if !ok {
+				// The bus closed or the client fell behind
+				return
+			}
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeEvent writes one event in the server-sent events format, the data
+// line holding the event as JSON
+func writeEvent(w http.ResponseWriter, event *Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
@@ -0,0 +1,9 @@
+package events
+
+import "user-management/shared/openapi"
+
+// Operations documents the event stream route in the OpenAPI document
+var Operations = []openapi.Operation{
+	{Pattern: "GET /api/sensors/events", Tag: "events", Summary: "Stream sensor events as server-sent events", Access: "sensors:read",
+		Headers: []string{"Last-Event-ID"}, Response: "", ContentType: "text/event-stream"},
+}
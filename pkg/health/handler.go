@@ -0,0 +1,136 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+	"user-management/shared/interfaces"
+	"user-management/shared/response"
+)
+
+// pingTimeout bounds the database check so a hung database fails readiness
+// instead of hanging the probe
+const pingTimeout = 2 * time.Second
+
+// Dependency status values
+const (
+	statusUp       = "up"
+	statusDown     = "down"
+	statusHealthy  = "healthy"
+	statusDegraded = "degraded"
+	statusNotReady = "unavailable"
+)
+
+// MQTTStatus reports whether the MQTT client is connected
+type MQTTStatus interface {
+	GetConnectionStatus() bool
+}
+
+// Status is the body of the health endpoints
+type Status struct {
+	Status    string                 `json:"status"`
+	Timestamp time.Time              `json:"timestamp"`
+	Checks    map[string]CheckResult `json:"checks,omitempty"`
+	// MQTTConnected is only reported by the readiness probe
+	MQTTConnected *bool `json:"mqtt_connected,omitempty"`
+}
+
+// CheckResult is the state of one dependency
+type CheckResult struct {
+	Status string `json:"status"`
+	// Critical dependencies being down make the service not ready
+	Critical  bool   `json:"critical"`
+	LatencyMS *int64 `json:"latency_ms,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Handler serves the liveness and readiness probes
+type Handler struct {
+	db   *sql.DB
+	mqtt MQTTStatus
+}
+
+// NewHandler creates a new health handler
+func NewHandler(db *sql.DB, mqtt MQTTStatus) *Handler {
+	return &Handler{
+		db:   db,
+		mqtt: mqtt,
+	}
+}
+
+// RegisterRoutes registers the health routes
+func (h *Handler) RegisterRoutes(mux interfaces.Router) {
+	mux.HandleFunc("GET /health", h.Live)
+	mux.HandleFunc("GET /health/live", h.Live)
+	mux.HandleFunc("GET /health/ready", h.Ready)
+}
+
+// Live handles GET /health/live and GET /health. It only reports that the
+// process is serving requests.
+func (h *Handler) Live(w http.ResponseWriter, r *http.Request) {
+	response.JSON(w, http.StatusOK, Status{
+		Status:    statusHealthy,
+		Timestamp: time.Now(),
+	})
+}
+
+// Ready handles GET /health/ready. The database is critical and fails
+// readiness with 503 when unreachable; the service keeps running without
+// MQTT, so a disconnected broker only degrades it.
+func (h *Handler) Ready(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]CheckResult{
+		"database": h.checkDatabase(r.Context()),
+		"mqtt":     h.checkMQTT(),
+	}
+
+	mqttConnected := checks["mqtt"].Status == statusUp
+	status := Status{
+		Status:        statusHealthy,
+		Timestamp:     time.Now(),
+		Checks:        checks,
+		MQTTConnected: &mqttConnected,
+	}
+	statusCode := http.StatusOK
+	for _, check := range checks {
+		if check.Status == statusUp {
+			continue
+		}
+		if check.Critical {
+			status.Status = statusNotReady
+			statusCode = http.StatusServiceUnavailable
+			break
+		}
+		status.Status = statusDegraded
+	}
+
+	response.JSON(w, statusCode, status)
+}
+
+// checkDatabase pings the database within pingTimeout. The probe is
+// unauthenticated, so the driver error is logged rather than returned
+func (h *Handler) checkDatabase(ctx context.Context) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := h.db.PingContext(ctx)
+	latency := time.Since(start).Milliseconds()
+
+	result := CheckResult{Status: statusUp, Critical: true, LatencyMS: &latency}
+	if err != nil {
+		log.Printf("Health check: database ping failed: %v", err)
+		result.Status = statusDown
+		result.Error = "database unreachable"
+	}
+	return result
+}
+
+// checkMQTT reports the MQTT client connection
+func (h *Handler) checkMQTT() CheckResult {
+	if h.mqtt == nil || !h.mqtt.GetConnectionStatus() {
+		return CheckResult{Status: statusDown, Error: "MQTT client not connected"}
+	}
+	return CheckResult{Status: statusUp}
+}
@@ -0,0 +1,13 @@
+package health
+
+import "user-management/shared/openapi"
+
+// Operations documents the health routes in the OpenAPI document
+var Operations = []openapi.Operation{
+	{Pattern: "GET /health", Tag: "health", Summary: "Report that the process is serving requests",
+		Response: Status{}, Raw: true},
+	{Pattern: "GET /health/live", Tag: "health", Summary: "Report that the process is serving requests",
+		Response: Status{}, Raw: true},
+	{Pattern: "GET /health/ready", Tag: "health", Summary: "Report whether dependencies are reachable; 503 when the database is not",
+		Response: Status{}, Raw: true},
+}
@@ -1,10 +1,13 @@
 package mqtt
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"user-management/pkg/sensor"
@@ -17,6 +20,9 @@ type MQTTBroker struct {
 	client        mqtt.Client
 	sensorService sensor.Service
 	config        *Config
+
+	// droppedMessages counts data messages dropped by the ingest rate limit
+	droppedMessages int64
 }
 
 // Config holds MQTT broker configuration
@@ -27,8 +33,22 @@ type Config struct {
 	Password string `toml:"password"`
 	ClientID string `toml:"client_id"`
 	QoS      byte   `toml:"qos"`
+
+	// ConnectAttempts is how many times Start tries to reach the broker;
+	// 0 uses DefaultConnectAttempts
+	ConnectAttempts int `toml:"connect_attempts"`
+	// ConnectBackoff is the wait before the first retry, doubling on each
+	// attempt; 0 uses DefaultConnectBackoff
+	ConnectBackoff time.Duration `toml:"connect_backoff"`
 }
 
+// Connection retry defaults for Start
+const (
+	DefaultConnectAttempts = 10
+	DefaultConnectBackoff  = time.Second
+	maxConnectBackoff      = 30 * time.Second
+)
+
 // SensorDataMessage represents incoming sensor data via MQTT
 type SensorDataMessage struct {
 	DeviceID  string      `json:"device_id"`
@@ -60,6 +80,13 @@ type DeviceStatusMessage struct {
 	IsOnline        bool   `json:"is_online"`
 }
 
+// CommandAckMessage represents a device's reply to a command
+type CommandAckMessage struct {
+	CommandUUID string          `json:"command_uuid"`
+	Status      string          `json:"status"`
+	Response    json.RawMessage `json:"response,omitempty"`
+}
+
 // NewMQTTBroker creates a new MQTT broker instance
 func NewMQTTBroker(config *Config, sensorService sensor.Service) *MQTTBroker {
 	broker := &MQTTBroker{
@@ -90,14 +117,38 @@ func NewMQTTBroker(config *Config, sensorService sensor.Service) *MQTTBroker {
 
 // Start connects to MQTT broker and sets up subscriptions
 func (mb *MQTTBroker) Start() error {
-	log.Println("Connecting to MQTT broker...")
+	attempts := mb.config.ConnectAttempts
+	if attempts <= 0 {
+		attempts = DefaultConnectAttempts
+	}
+	backoff := mb.config.ConnectBackoff
+	if backoff <= 0 {
+		backoff = DefaultConnectBackoff
+	}
+
+	// The broker may still be starting, as in docker-compose
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		log.Printf("Connecting to MQTT broker (attempt %d/%d)...", attempt, attempts)
+
+		token := mb.client.Connect()
+		token.Wait()
+		if err = token.Error(); err == nil {
+			log.Println("Successfully connected to MQTT broker")
+			return nil
+		}
 
-	if token := mb.client.Connect(); token.Wait() && token.Error() != nil {
-		return fmt.Errorf("failed to connect to MQTT broker: %w", token.Error())
+		if attempt < attempts {
+			log.Printf("MQTT broker not reachable: %v; retrying in %s", err, backoff)
+			time.Sleep(backoff)
+			backoff *= 2
+			if backoff > maxConnectBackoff {
+				backoff = maxConnectBackoff
+			}
+		}
 	}
 
-	log.Println("Successfully connected to MQTT broker")
-	return nil
+	return fmt.Errorf("failed to connect to MQTT broker after %d attempts: %w", attempts, err)
 }
 
 // Stop disconnects from MQTT broker
@@ -113,10 +164,11 @@ func (mb *MQTTBroker) onConnect(client mqtt.Client) {
 
 	// Subscribe to different topic patterns
 	subscriptions := map[string]mqtt.MessageHandler{
-		"sensors/+/data":      mb.handleSensorData,
-		"sensors/+/data/bulk": mb.handleBulkSensorData,
-		"sensors/+/status":    mb.handleDeviceStatus,
-		"sensors/+/heartbeat": mb.handleHeartbeat,
+		"sensors/+/data":         mb.handleSensorData,
+		"sensors/+/data/bulk":    mb.handleBulkSensorData,
+		"sensors/+/status":       mb.handleDeviceStatus,
+		"sensors/+/heartbeat":    mb.handleHeartbeat,
+		"sensors/+/commands/ack": mb.handleCommandAck,
 	}
 
 	for topic, handler := range subscriptions {
@@ -158,7 +210,11 @@ func (mb *MQTTBroker) handleSensorData(client mqtt.Client, msg mqtt.Message) {
 	}
 
 	// Process sensor reading
-	if err := mb.processSensorReading(sensorMsg); err != nil {
+	if err := mb.processSensorReading(context.Background(), sensorMsg); err != nil {
+		if errors.Is(err, sensor.ErrRateLimited) {
+			mb.dropRateLimited(deviceID)
+			return
+		}
 		log.Printf("Failed to process sensor reading from %s: %v", deviceID, err)
 		return
 	}
@@ -190,7 +246,11 @@ func (mb *MQTTBroker) handleBulkSensorData(client mqtt.Client, msg mqtt.Message)
 	}
 
 	// Process bulk readings
-	if err := mb.processBulkSensorReadings(bulkMsg); err != nil {
+	if err := mb.processBulkSensorReadings(context.Background(), bulkMsg); err != nil {
+		if errors.Is(err, sensor.ErrRateLimited) {
+			mb.dropRateLimited(deviceID)
+			return
+		}
 		log.Printf("Failed to process bulk sensor readings from %s: %v", deviceID, err)
 		return
 	}
@@ -222,7 +282,7 @@ func (mb *MQTTBroker) handleDeviceStatus(client mqtt.Client, msg mqtt.Message) {
 	}
 
 	// Process device status update
-	if err := mb.processDeviceStatus(statusMsg); err != nil {
+	if err := mb.processDeviceStatus(context.Background(), statusMsg); err != nil {
 		log.Printf("Failed to process device status from %s: %v", deviceID, err)
 		return
 	}
@@ -245,17 +305,68 @@ func (mb *MQTTBroker) handleHeartbeat(client mqtt.Client, msg mqtt.Message) {
 		IsOnline: true,
 	}
 
-	if err := mb.processDeviceStatus(statusMsg); err != nil {
+	if err := mb.processDeviceStatus(context.Background(), statusMsg); err != nil {
 		log.Printf("Failed to process heartbeat from %s: %v", deviceID, err)
 	}
 }
 
+// handleCommandAck records devices acknowledging commands
+func (mb *MQTTBroker) handleCommandAck(client mqtt.Client, msg mqtt.Message) {
+	deviceID := mb.extractDeviceIDFromTopic(msg.Topic())
+	if deviceID == "" {
+		log.Printf("Invalid topic format: %s", msg.Topic())
+		return
+	}
+
+	var ackMsg CommandAckMessage
+	if err := json.Unmarshal(msg.Payload(), &ackMsg); err != nil {
+		log.Printf("Failed to parse command ack message: %v", err)
+		return
+	}
+
+	command, err := mb.sensorService.AcknowledgeCommand(context.Background(), deviceID, &sensor.CommandAck{
+		CommandUUID: ackMsg.CommandUUID,
+		Status:      ackMsg.Status,
+		Response:    ackMsg.Response,
+	})
+	if err != nil {
+		log.Printf("Failed to process command ack %s from %s: %v", ackMsg.CommandUUID, deviceID, err)
+		return
+	}
+
+	log.Printf("Device %s replied %s to command %s", deviceID, command.Status, command.CommandUUID)
+}
+
+// dropRateLimited counts and logs a data message dropped because its
+// device is sending faster than its ingest rate limit
+func (mb *MQTTBroker) dropRateLimited(deviceID string) {
+	dropped := atomic.AddInt64(&mb.droppedMessages, 1)
+	log.Printf("Dropped rate limited message from device %s (%d dropped in total)", deviceID, dropped)
+}
+
+// sensorForReadings returns the sensor of a device sending readings,
+// provisioning a pending sensor for unknown devices when enabled
+func (mb *MQTTBroker) sensorForReadings(ctx context.Context, deviceID string) (*sensor.Sensor, error) {
+	sensorData, err := mb.sensorService.GetSensorByDeviceID(ctx, deviceID)
+	if errors.Is(err, sensor.ErrSensorNotFound) {
+		sensorData, err = mb.sensorService.ProvisionSensor(ctx, deviceID)
+		if errors.Is(err, sensor.ErrAutoProvisionDisabled) {
+			return nil, fmt.Errorf("sensor not found for device %s: %w", deviceID, sensor.ErrSensorNotFound)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sensor not found for device %s: %w", deviceID, err)
+	}
+
+	return sensorData, nil
+}
+
 // processSensorReading converts MQTT message to sensor reading and saves it
-func (mb *MQTTBroker) processSensorReading(msg SensorDataMessage) error {
+func (mb *MQTTBroker) processSensorReading(ctx context.Context, msg SensorDataMessage) error {
 	// Get sensor by device ID
-	sensorData, err := mb.sensorService.GetSensorByDeviceID(msg.DeviceID)
+	sensorData, err := mb.sensorForReadings(ctx, msg.DeviceID)
 	if err != nil {
-		return fmt.Errorf("sensor not found for device %s: %w", msg.DeviceID, err)
+		return err
 	}
 
 	// Convert metadata to JSON if provided
@@ -274,20 +385,25 @@ func (mb *MQTTBroker) processSensorReading(msg SensorDataMessage) error {
 		Metadata:  metadataJSON,
 	}
 
-	// Save sensor reading
-	_, err = mb.sensorService.CreateSensorReading(readingReq)
+	// Save sensor reading; a republished message is already stored
+	_, err = mb.sensorService.CreateSensorReading(ctx, readingReq)
+	if errors.Is(err, sensor.ErrDuplicateReading) {
+		log.Printf("Skipped duplicate reading from device %s", msg.DeviceID)
+		return nil
+	}
 	return err
 }
 
 // processBulkSensorReadings converts bulk MQTT message to sensor readings
-func (mb *MQTTBroker) processBulkSensorReadings(msg BulkSensorDataMessage) error {
+func (mb *MQTTBroker) processBulkSensorReadings(ctx context.Context, msg BulkSensorDataMessage) error {
 	// Get sensor by device ID
-	sensorData, err := mb.sensorService.GetSensorByDeviceID(msg.DeviceID)
+	sensorData, err := mb.sensorForReadings(ctx, msg.DeviceID)
 	if err != nil {
-		return fmt.Errorf("sensor not found for device %s: %w", msg.DeviceID, err)
+		return err
 	}
 
-	// Convert readings
+	// Convert readings; storing them counts the message once against the
+	// device's rate limit
 	var readings []sensor.CreateSensorReadingRequest
 	for _, reading := range msg.Readings {
 		var metadataJSON json.RawMessage
@@ -311,13 +427,21 @@ func (mb *MQTTBroker) processBulkSensorReadings(msg BulkSensorDataMessage) error
 		Readings: readings,
 	}
 
-	return mb.sensorService.CreateBulkSensorReadings(bulkReq)
+	result, err := mb.sensorService.CreateBulkSensorReadings(ctx, bulkReq)
+	if err != nil {
+		return err
+	}
+
+	if result.Duplicates > 0 {
+		log.Printf("Skipped %d duplicate readings from device %s", result.Duplicates, msg.DeviceID)
+	}
+	return nil
 }
 
 // processDeviceStatus updates device status information
-func (mb *MQTTBroker) processDeviceStatus(msg DeviceStatusMessage) error {
+func (mb *MQTTBroker) processDeviceStatus(ctx context.Context, msg DeviceStatusMessage) error {
 	// Get sensor by device ID
-	existingSensor, err := mb.sensorService.GetSensorByDeviceID(msg.DeviceID)
+	existingSensor, err := mb.sensorService.GetSensorByDeviceID(ctx, msg.DeviceID)
 	if err != nil {
 		return fmt.Errorf("sensor not found for device %s: %w", msg.DeviceID, err)
 	}
@@ -334,7 +458,7 @@ func (mb *MQTTBroker) processDeviceStatus(msg DeviceStatusMessage) error {
 	}
 
 	// Update sensor
-	_, err = mb.sensorService.UpdateSensor(existingSensor.ID, updateReq)
+	_, err = mb.sensorService.UpdateSensor(ctx, existingSensor.ID, updateReq)
 	return err
 }
 
@@ -368,6 +492,11 @@ func (mb *MQTTBroker) PublishCommand(deviceID string, command interface{}) error
 	return nil
 }
 
+// GetDroppedMessages returns how many data messages the ingest rate limit dropped
+func (mb *MQTTBroker) GetDroppedMessages() int64 {
+	return atomic.LoadInt64(&mb.droppedMessages)
+}
+
 // GetConnectionStatus returns current MQTT connection status
 func (mb *MQTTBroker) GetConnectionStatus() bool {
 	return mb.client.IsConnected()
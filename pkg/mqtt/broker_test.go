@@ -0,0 +1,74 @@
+package mqtt
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+	"user-management/database/dbtest"
+	"user-management/pkg/sensor"
+)
+
+// message is an MQTT message received on topic
+type message struct {
+	topic   string
+	payload []byte
+}
+
+func (m message) Duplicate() bool   { return false }
+func (m message) Qos() byte         { return 0 }
+func (m message) Retained() bool    { return false }
+func (m message) Topic() string     { return m.topic }
+func (m message) MessageID() uint16 { return 0 }
+func (m message) Payload() []byte   { return m.payload }
+func (m message) Ack()              {}
+
+// TestSensorDataDeviceIDIgnoresCase stores readings published under any
+// case of the device ID, in the topic or the payload, on the same sensor
+func TestSensorDataDeviceIDIgnoresCase(t *testing.T) {
+	db := dbtest.Migrated(t)
+	service := sensor.NewService(sensor.NewRepository(db), sensor.Config{})
+
+	var sensorTypeID int
+	if err := db.QueryRow("SELECT id FROM sensor_data.sensor_types WHERE name = 'temperature'").Scan(&sensorTypeID); err != nil {
+		t.Fatal(err)
+	}
+	created, err := service.CreateSensor(context.Background(), &sensor.CreateSensorRequest{
+		DeviceID:     "MQTT-001",
+		Name:         "Test sensor",
+		SensorTypeID: sensorTypeID,
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	broker := NewMQTTBroker(&Config{}, service)
+	start := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	messages := []message{
+		{"sensors/mqtt-001/data", []byte(`{"value": 21.5}`)},
+		{"sensors/Mqtt-001/data", []byte(`{"value": 22}`)},
+		{"sensors/MQTT-001/data", []byte(`{"device_id": "mqtt-001", "value": 22.5}`)},
+	}
+	for i, msg := range messages {
+		// Distinct timestamps keep the readings from being deduplicated
+		timestamp := start.Add(time.Duration(i) * time.Minute).Format(time.RFC3339)
+		msg.payload = append(msg.payload[:len(msg.payload)-1], fmt.Sprintf(`, "timestamp": %q}`, timestamp)...)
+		broker.handleSensorData(nil, msg)
+	}
+
+	var readings int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sensor_data.sensor_readings WHERE sensor_id = $1", created.ID).Scan(&readings); err != nil {
+		t.Fatal(err)
+	}
+	if readings != len(messages) {
+		t.Errorf("got %d readings, want %d", readings, len(messages))
+	}
+
+	var sensors int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sensor_data.sensors WHERE UPPER(device_id) = 'MQTT-001'").Scan(&sensors); err != nil {
+		t.Fatal(err)
+	}
+	if sensors != 1 {
+		t.Errorf("got %d sensors, want 1", sensors)
+	}
+}
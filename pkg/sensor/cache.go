@@ -0,0 +1,147 @@
+package sensor
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultDashboardCacheSeconds is how long dashboard and health results are
+// cached unless configured otherwise
+const DefaultDashboardCacheSeconds = 20
+
+// maxCacheEntries bounds the cache; expired entries are swept when it is
+// reached and the cache is reset if that is not enough
+const maxCacheEntries = 1000
+
+// CacheStatus describes where a cached result came from
+type CacheStatus struct {
+	// Hit is set when the result was computed by an earlier or concurrent
+	// request
+	Hit bool
+	// MaxAge is how long the result stays cached; 0 when caching is off
+	MaxAge time.Duration
+}
+
+// resultCache caches computed results for a short time. Concurrent
+// requests for a missing key share a single computation. A nil
+// *resultCache disables caching.
+type resultCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+// cacheEntry is a result being computed until done is closed
+type cacheEntry struct {
+	done      chan struct{}
+	value     interface{}
+	err       error
+	expiresAt time.Time
+}
+
+// newResultCache creates a cache whose results live for ttl, or nil when
+// ttl is not positive
+func newResultCache(ttl time.Duration) *resultCache {
+	if ttl <= 0 {
+		return nil
+	}
+
+	return &resultCache{
+		ttl:     ttl,
+		entries: make(map[string]*cacheEntry),
+	}
+}
+
+// get returns the cached result for key, computing it with load when it
+// is missing or expired. Failed loads are not cached.
+func (c *resultCache) get(key string, load func() (interface{}, error)) (interface{}, CacheStatus, error) {
+	if c == nil {
+		value, err := load()
+		return value, CacheStatus{}, err
+	}
+
+	c.mu.Lock()
+	if entry, ok := c.entries[key]; ok {
+		select {
+		case <-entry.done:
+			if time.Now().Before(entry.expiresAt) {
+				c.mu.Unlock()
+				return entry.value, CacheStatus{Hit: true, MaxAge: time.Until(entry.expiresAt)}, nil
+			}
+		default:
+			// Another request is computing it; share its result
+			c.mu.Unlock()
+			<-entry.done
+			if entry.err != nil {
+				return nil, CacheStatus{}, entry.err
+			}
+			return entry.value, CacheStatus{Hit: true, MaxAge: time.Until(entry.expiresAt)}, nil
+		}
+	}
+
+	if len(c.entries) >= maxCacheEntries {
+		c.sweep()
+	}
+	entry := &cacheEntry{done: make(chan struct{})}
+	c.entries[key] = entry
+	c.mu.Unlock()
+
+	entry.value, entry.err = load()
+	entry.expiresAt = time.Now().Add(c.ttl)
+	close(entry.done)
+
+	if entry.err != nil {
+		c.mu.Lock()
+		if c.entries[key] == entry {
+			delete(c.entries, key)
+		}
+		c.mu.Unlock()
+		return nil, CacheStatus{}, entry.err
+	}
+
+	return entry.value, CacheStatus{MaxAge: c.ttl}, nil
+}
+
+// sweep drops expired entries, resetting the cache if it is still full;
+// callers hold c.mu
+func (c *resultCache) sweep() {
+	now := time.Now()
+	for key, entry := range c.entries {
+		select {
+		case <-entry.done:
+			if now.After(entry.expiresAt) {
+				delete(c.entries, key)
+			}
+		default:
+		}
+	}
+	if len(c.entries) >= maxCacheEntries {
+		c.entries = make(map[string]*cacheEntry)
+	}
+}
+
+// invalidate drops every cached result. Computations in progress still
+// complete for the requests waiting on them.
+func (c *resultCache) invalidate() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]*cacheEntry)
+}
+
+// cacheKey identifies the sensors visible to the service, so results are
+// only shared between callers seeing the same sensors
+func (s *service) cacheKey(name string) string {
+	roleIDs := append([]int(nil), s.access.RoleIDs...)
+	sort.Ints(roleIDs)
+
+	return fmt.Sprintf("%s|%t:%d|%t:%d:%v", name,
+		s.scope.Restricted, s.scope.OrganizationID, s.access.Restricted, s.access.UserID, roleIDs)
+}
@@ -0,0 +1,77 @@
+package sensor
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// commandSweepInterval is how often the command timeout worker looks for
+// unacknowledged commands
+const commandSweepInterval = time.Minute
+
+// CommandTimeoutWorker periodically marks commands that devices did not
+// acknowledge within the timeout as timed out
+type CommandTimeoutWorker struct {
+	service Service
+	timeout time.Duration
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewCommandTimeoutWorker creates a command timeout worker; a timeoutSeconds
+// of 0 uses DefaultCommandTimeoutSeconds
+func NewCommandTimeoutWorker(service Service, timeoutSeconds int) *CommandTimeoutWorker {
+	if timeoutSeconds <= 0 {
+		timeoutSeconds = DefaultCommandTimeoutSeconds
+	}
+
+	return &CommandTimeoutWorker{
+		service: service,
+		timeout: time.Duration(timeoutSeconds) * time.Second,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// Start runs a sweep right away and then once per sweep interval
+func (w *CommandTimeoutWorker) Start() {
+	go func() {
+		defer close(w.done)
+
+		ticker := time.NewTicker(commandSweepInterval)
+		defer ticker.Stop()
+
+		for {
+			w.run()
+
+			select {
+			case <-ticker.C:
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the worker and waits for a running sweep to finish
+func (w *CommandTimeoutWorker) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+	<-w.done
+}
+
+func (w *CommandTimeoutWorker) run() {
+	timedOut, err := w.service.TimeOutCommands(context.Background(), w.timeout)
+	if err != nil {
+		log.Printf("Warning: sensor command timeout sweep failed: %v", err)
+		return
+	}
+	if timedOut > 0 {
+		log.Printf("Sensor command timeout sweep timed out %d commands", timedOut)
+	}
+}
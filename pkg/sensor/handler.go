@@ -2,10 +2,15 @@ package sensor
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
+	"user-management/pkg/audit"
+	"user-management/shared/interfaces"
 	"user-management/shared/middleware"
 	"user-management/shared/response"
 )
@@ -14,48 +19,162 @@ import (
 type Handler struct {
 	service Service
 	authMW  *middleware.AuthMiddleware
+	audit   audit.Recorder
 }
 
 // NewHandler creates a new sensor handler
-func NewHandler(service Service, authMW *middleware.AuthMiddleware) *Handler {
+func NewHandler(service Service, authMW *middleware.AuthMiddleware, recorder audit.Recorder) *Handler {
 	return &Handler{
 		service: service,
 		authMW:  authMW,
+		audit:   recorder,
 	}
 }
 
 // RegisterRoutes registers all sensor routes
-func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
-	// Public routes (for IoT devices to send data)
+func (h *Handler) RegisterRoutes(mux interfaces.Router) {
+	// Public routes (for IoT devices to send data, authenticated with device tokens)
 	mux.HandleFunc("POST /api/sensors/readings", h.CreateSensorReading)
 	mux.HandleFunc("POST /api/sensors/readings/bulk", h.CreateBulkSensorReadings)
 
 	// Protected routes (authentication required)
-	mux.Handle("GET /api/sensors/dashboard", h.authMW.RequirePermission("sensors", "read")(http.HandlerFunc(h.GetDashboard)))
-	mux.Handle("GET /api/sensors", h.authMW.RequirePermission("sensors", "read")(http.HandlerFunc(h.ListSensors)))
-	mux.Handle("GET /api/sensors/{id}", h.authMW.RequirePermission("sensors", "read")(http.HandlerFunc(h.GetSensor)))
-	mux.Handle("GET /api/sensors/device/{device_id}", h.authMW.RequirePermission("sensors", "read")(http.HandlerFunc(h.GetSensorByDeviceID)))
-	mux.Handle("GET /api/sensors/readings", h.authMW.RequirePermission("sensor_readings", "read")(http.HandlerFunc(h.GetSensorReadings)))
-	mux.Handle("GET /api/sensors/health", h.authMW.RequirePermission("sensors", "read")(http.HandlerFunc(h.GetSensorHealth)))
+	mux.Handle("GET /api/sensors/dashboard", h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "read")(http.HandlerFunc(h.GetDashboard))))
+	mux.Handle("GET /api/sensors", h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "read")(http.HandlerFunc(h.ListSensors))))
+	mux.Handle("GET /api/sensors/tags", h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "read")(http.HandlerFunc(h.ListSensorTags))))
+	mux.Handle("GET /api/sensors/firmware-report", h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "read")(http.HandlerFunc(h.GetFirmwareReport))))
+	mux.Handle("GET /api/sensors/{id}", h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "read")(http.HandlerFunc(h.GetSensor))))
+	mux.Handle("GET /api/sensors/device/{device_id}", h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "read")(http.HandlerFunc(h.GetSensorByDeviceID))))
+	mux.Handle("GET /api/sensors/readings", h.authMW.Authenticate(h.authMW.RequirePermission("sensor_readings", "read")(http.HandlerFunc(h.GetSensorReadings))))
+	mux.Handle("GET /api/sensors/readings/{id}", h.authMW.Authenticate(h.authMW.RequirePermission("sensor_readings", "read")(http.HandlerFunc(h.GetReading))))
+	mux.Handle("PATCH /api/sensors/readings/{id}", h.authMW.Authenticate(h.authMW.RequirePermission("sensor_readings", "write")(http.HandlerFunc(h.UpdateReadingQuality))))
+	// Registered as {collection} because "DELETE /api/sensors/{id}/readings"
+	// conflicts with "DELETE /api/sensors/access/{id}" in the mux. Unknown
+	// collections are rejected before any permission check.
+	deleteReadings := h.authMW.Authenticate(h.authMW.RequirePermission("sensor_readings", "delete")(http.HandlerFunc(h.DeleteReadings)))
+	mux.HandleFunc("DELETE /api/sensors/{id}/{collection}", func(w http.ResponseWriter, r *http.Request) {
+		switch r.PathValue("collection") {
+		case "readings":
+			deleteReadings.ServeHTTP(w, r)
+		default:
+			response.NotFound(w, "Route not found")
+		}
+	})
+	// Registered as {collection} because "GET /api/sensors/{id}/status-history"
+	// conflicts with "GET /api/sensors/readings/{id}" in the mux
+	statusHistory := h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "read")(http.HandlerFunc(h.GetStatusHistory)))
+	availability := h.authMW.Authenticate(h.authMW.RequirePermission("analytics", "read")(http.HandlerFunc(h.GetAvailability)))
+	calibrations := h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "read")(http.HandlerFunc(h.ListCalibrations)))
+	anomalies := h.authMW.Authenticate(h.authMW.RequirePermission("analytics", "read")(http.HandlerFunc(h.DetectAnomalies)))
+	gaps := h.authMW.Authenticate(h.authMW.RequirePermission("analytics", "read")(http.HandlerFunc(h.GetReadingGaps)))
+	commands := h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "read")(http.HandlerFunc(h.ListSensorCommands)))
+	firmwareHistory := h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "read")(http.HandlerFunc(h.ListFirmwareHistory)))
+	notes := h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "read")(http.HandlerFunc(h.ListSensorNotes)))
+	mux.HandleFunc("GET /api/sensors/{id}/{collection}", func(w http.ResponseWriter, r *http.Request) {
+		switch r.PathValue("collection") {
+		case "status-history":
+			statusHistory.ServeHTTP(w, r)
+		case "availability":
+			availability.ServeHTTP(w, r)
+		case "calibrations":
+			calibrations.ServeHTTP(w, r)
+		case "anomalies":
+			anomalies.ServeHTTP(w, r)
+		case "gaps":
+			gaps.ServeHTTP(w, r)
+		case "commands":
+			commands.ServeHTTP(w, r)
+		case "firmware-history":
+			firmwareHistory.ServeHTTP(w, r)
+		case "notes":
+			notes.ServeHTTP(w, r)
+		default:
+			response.NotFound(w, "Route not found")
+		}
+	})
+	mux.Handle("GET /api/sensors/health", h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "read")(http.HandlerFunc(h.GetSensorHealth))))
 
 	// Sensor management (write permissions)
-	mux.Handle("POST /api/sensors", h.authMW.RequirePermission("sensors", "write")(http.HandlerFunc(h.CreateSensor)))
-	mux.Handle("PUT /api/sensors/{id}", h.authMW.RequirePermission("sensors", "write")(http.HandlerFunc(h.UpdateSensor)))
-	mux.Handle("DELETE /api/sensors/{id}", h.authMW.RequirePermission("sensors", "delete")(http.HandlerFunc(h.DeleteSensor)))
+	mux.Handle("POST /api/sensors", h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "write")(http.HandlerFunc(h.CreateSensor))))
+	mux.Handle("PUT /api/sensors/{id}", h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "write")(http.HandlerFunc(h.UpdateSensor))))
+	mux.Handle("DELETE /api/sensors/{id}", h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "delete")(http.HandlerFunc(h.DeleteSensor))))
+	mux.Handle("POST /api/sensors/{id}/activate", h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "write")(http.HandlerFunc(h.ActivateSensor))))
+	mux.Handle("POST /api/sensors/{id}/clone", h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "write")(http.HandlerFunc(h.CloneSensor))))
+	mux.Handle("PUT /api/sensors/{id}/calibration", h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "write")(http.HandlerFunc(h.UpdateCalibration))))
+	mux.Handle("PUT /api/sensors/{id}/maintenance", h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "write")(http.HandlerFunc(h.SetMaintenance))))
+	mux.Handle("POST /api/sensors/{id}/notes", h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "write")(http.HandlerFunc(h.CreateSensorNote))))
+	mux.Handle("DELETE /api/sensors/{id}/notes/{note_id}", h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "write")(http.HandlerFunc(h.DeleteSensorNote))))
+	mux.Handle("POST /api/sensors/{id}/commands", h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "write")(http.HandlerFunc(h.SendCommand))))
+
+	// Sensor access grants (admin only)
+	mux.Handle("GET /api/sensors/access", h.authMW.Authenticate(h.authMW.RequireAdmin(http.HandlerFunc(h.ListSensorAccess))))
+	mux.Handle("POST /api/sensors/access", h.authMW.Authenticate(h.authMW.RequireAdmin(http.HandlerFunc(h.CreateSensorAccess))))
+	mux.Handle("DELETE /api/sensors/access/{id}", h.authMW.Authenticate(h.authMW.RequireAdmin(http.HandlerFunc(h.DeleteSensorAccess))))
+
+	// Auto-provisioned sensors (admin only)
+	mux.Handle("GET /api/sensors/pending", h.authMW.Authenticate(h.authMW.RequireAdmin(http.HandlerFunc(h.ListPendingSensors))))
+	mux.Handle("POST /api/sensors/{id}/approve", h.authMW.Authenticate(h.authMW.RequireAdmin(http.HandlerFunc(h.ApproveSensor))))
+
+	// Device tokens (admin only)
+	mux.Handle("POST /api/sensors/{id}/rotate-token", h.authMW.Authenticate(h.authMW.RequireAdmin(http.HandlerFunc(h.RotateDeviceToken))))
 
 	// Sensor types (read-only for most users)
-	mux.Handle("GET /api/sensor-types", h.authMW.RequirePermission("sensors", "read")(http.HandlerFunc(h.ListSensorTypes)))
-	mux.Handle("GET /api/sensor-types/{id}", h.authMW.RequirePermission("sensors", "read")(http.HandlerFunc(h.GetSensorType)))
+	mux.Handle("GET /api/sensor-types", h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "read")(http.HandlerFunc(h.ListSensorTypes))))
+	mux.Handle("GET /api/sensor-types/{id}", h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "read")(http.HandlerFunc(h.GetSensorType))))
+
+	// Sensor types are shared by every organization, so only super admins manage them
+	mux.Handle("POST /api/sensor-types", h.authMW.Authenticate(h.authMW.RequireSuperAdmin(http.HandlerFunc(h.CreateSensorType))))
+	mux.Handle("PUT /api/sensor-types/{id}", h.authMW.Authenticate(h.authMW.RequireSuperAdmin(http.HandlerFunc(h.UpdateSensorType))))
+	mux.Handle("DELETE /api/sensor-types/{id}", h.authMW.Authenticate(h.authMW.RequireSuperAdmin(http.HandlerFunc(h.DeleteSensorType))))
 
 	// Location management
-	mux.Handle("GET /api/locations", h.authMW.RequirePermission("sensors", "read")(http.HandlerFunc(h.ListLocations)))
-	mux.Handle("GET /api/locations/{id}", h.authMW.RequirePermission("sensors", "read")(http.HandlerFunc(h.GetLocation)))
-	mux.Handle("GET /api/locations/sensors", h.authMW.RequirePermission("sensors", "read")(http.HandlerFunc(h.GetLocationSummary)))
-	mux.Handle("POST /api/locations", h.authMW.RequirePermission("sensors", "write")(http.HandlerFunc(h.CreateLocation)))
-	mux.Handle("PUT /api/locations/{id}", h.authMW.RequirePermission("sensors", "write")(http.HandlerFunc(h.UpdateLocation)))
+	mux.Handle("GET /api/locations", h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "read")(http.HandlerFunc(h.ListLocations))))
+	mux.Handle("GET /api/locations/{id}", h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "read")(http.HandlerFunc(h.GetLocation))))
+	mux.Handle("GET /api/locations/sensors", h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "read")(http.HandlerFunc(h.GetLocationSummary))))
+	mux.Handle("GET /api/locations/nearby", h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "read")(http.HandlerFunc(h.ListLocationsNear))))
+	mux.Handle("POST /api/locations", h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "write")(http.HandlerFunc(h.CreateLocation))))
+	mux.Handle("PUT /api/locations/{id}", h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "write")(http.HandlerFunc(h.UpdateLocation))))
+	mux.Handle("DELETE /api/locations/{id}", h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "delete")(http.HandlerFunc(h.DeleteLocation))))
+	mux.Handle("POST /api/locations/{id}/assign-sensors", h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "write")(http.HandlerFunc(h.AssignSensorsToLocation))))
+
+	// Sensor groups
+	mux.Handle("GET /api/sensor-groups", h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "read")(http.HandlerFunc(h.ListSensorGroups))))
+	mux.Handle("GET /api/sensor-groups/{id}", h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "read")(http.HandlerFunc(h.GetSensorGroup))))
+	mux.Handle("GET /api/sensor-groups/{id}/summary", h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "read")(http.HandlerFunc(h.GetGroupSummary))))
+	mux.Handle("POST /api/sensor-groups", h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "write")(http.HandlerFunc(h.CreateSensorGroup))))
+	mux.Handle("PUT /api/sensor-groups/{id}", h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "write")(http.HandlerFunc(h.UpdateSensorGroup))))
+	mux.Handle("POST /api/sensor-groups/{id}/sensors", h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "write")(http.HandlerFunc(h.UpdateGroupMembers))))
+	mux.Handle("DELETE /api/sensor-groups/{id}", h.authMW.Authenticate(h.authMW.RequirePermission("sensors", "delete")(http.HandlerFunc(h.DeleteSensorGroup))))
 
 	// Analytics & Statistics
-	mux.Handle("GET /api/sensors/statistics", h.authMW.RequirePermission("analytics", "read")(http.HandlerFunc(h.GetSensorStatistics)))
+	mux.Handle("GET /api/sensors/statistics", h.authMW.Authenticate(h.authMW.RequirePermission("analytics", "read")(http.HandlerFunc(h.GetSensorStatistics))))
+	mux.Handle("GET /api/sensors/{id}/readings/aggregate", h.authMW.Authenticate(h.authMW.RequirePermission("sensor_readings", "read")(http.HandlerFunc(h.GetAggregatedReadings))))
+	mux.Handle("GET /api/sensors/{id}/statistics/daily", h.authMW.Authenticate(h.authMW.RequirePermission("analytics", "read")(http.HandlerFunc(h.GetDailyStatistics))))
+
+	// Data retention (admin only)
+	mux.Handle("POST /api/sensors/readings/purge", h.authMW.Authenticate(h.authMW.RequireAdmin(
+		h.authMW.RequirePermission("analytics", "delete")(http.HandlerFunc(h.PurgeReadings)))))
+}
+
+// scopedService returns the service limited to the organization of the
+// authenticated user and to the sensors the user can access; super admins
+// are not limited to an organization and admins bypass sensor grants
+func (h *Handler) scopedService(r *http.Request) Service {
+	scope, ok := middleware.GetScopeFromContext(r.Context())
+	if !ok {
+		// Fail closed: a restricted scope without organization matches nothing
+		scope = interfaces.Scope{Restricted: true}
+	}
+	return h.service.WithScope(scope).WithAccess(accessFromRequest(r))
+}
+
+// accessFromRequest returns the sensor access of the authenticated user
+func accessFromRequest(r *http.Request) Access {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		// Fail closed: a user without ID and roles only reaches ungranted sensors
+		return Access{Restricted: true}
+	}
+	return AccessFor(user)
 }
 
 // CreateSensor handles sensor creation
@@ -72,24 +191,77 @@ func (h *Handler) CreateSensor(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sensor, err := h.service.CreateSensor(&req, user.ID)
+	sensor, err := h.scopedService(r).CreateSensor(r.Context(), &req, user.ID)
 	if err != nil {
-		switch err {
-		case ErrInvalidDeviceID, ErrInvalidValue:
+		switch {
+		case errors.Is(err, ErrInvalidDeviceID), errors.Is(err, ErrInvalidValue),
+			errors.Is(err, ErrInvalidExpectedInterval), errors.Is(err, ErrInvalidIngestRate),
+			errors.Is(err, ErrInvalidTags), errors.Is(err, ErrInvalidMetadata), errors.Is(err, ErrMetadataTooLarge):
 			response.BadRequest(w, "Validation failed", err)
-		case ErrDeviceIDExists:
+		case errors.Is(err, ErrDeviceIDExists):
 			response.Conflict(w, "Device ID already exists", err)
-		case ErrSensorTypeNotFound, ErrLocationNotFound:
-			response.NotFound(w, err.Error())
+		case errors.Is(err, ErrSensorTypeNotFound), errors.Is(err, ErrLocationNotFound):
+			response.ErrorFor(w, http.StatusNotFound, err.Error(), err)
 		default:
 			response.InternalServerError(w, "Failed to create sensor", err)
 		}
 		return
 	}
 
+	h.audit.Record(r, audit.ActionSensorCreate, audit.ResourceSensor, strconv.Itoa(sensor.ID), req)
+
 	response.Created(w, "Sensor created successfully", sensor)
 }
 
+// CloneSensor handles creating a sensor from an existing sensor's configuration
+func (h *Handler) CloneSensor(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		response.Unauthorized(w, "User not found in context")
+		return
+	}
+
+	sensorID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid sensor ID", err)
+		return
+	}
+
+	var req CloneSensorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	sensor, err := h.scopedService(r).CloneSensor(r.Context(), sensorID, &req, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidDeviceID), errors.Is(err, ErrInvalidValue),
+			errors.Is(err, ErrInvalidExpectedInterval), errors.Is(err, ErrInvalidIngestRate),
+			errors.Is(err, ErrInvalidTags), errors.Is(err, ErrInvalidMetadata), errors.Is(err, ErrMetadataTooLarge):
+			response.BadRequest(w, "Validation failed", err)
+		case errors.Is(err, ErrDeviceIDExists):
+			response.Conflict(w, "Device ID already exists", err)
+		case errors.Is(err, ErrSensorNotProvisioned):
+			response.Conflict(w, "Pending sensors cannot be cloned", err)
+		case errors.Is(err, ErrSensorNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Sensor not found", err)
+		case errors.Is(err, ErrSensorTypeNotFound), errors.Is(err, ErrLocationNotFound):
+			response.ErrorFor(w, http.StatusNotFound, err.Error(), err)
+		default:
+			response.InternalServerError(w, "Failed to clone sensor", err)
+		}
+		return
+	}
+
+	h.audit.Record(r, audit.ActionSensorClone, audit.ResourceSensor, strconv.Itoa(sensor.ID), map[string]interface{}{
+		"cloned_from": sensorID,
+		"request":     req,
+	})
+
+	response.Created(w, "Sensor cloned successfully", sensor)
+}
+
 // GetSensor handles getting sensor by ID
 func (h *Handler) GetSensor(w http.ResponseWriter, r *http.Request) {
 	sensorID, err := strconv.Atoi(r.PathValue("id"))
@@ -98,20 +270,61 @@ func (h *Handler) GetSensor(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sensor, err := h.service.GetSensor(sensorID)
+	sensor, err := h.scopedService(r).GetSensor(r.Context(), sensorID)
 	if err != nil {
-		switch err {
-		case ErrSensorNotFound:
-			response.NotFound(w, "Sensor not found")
+		switch {
+		case errors.Is(err, ErrSensorNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Sensor not found", err)
 		default:
 			response.InternalServerError(w, "Failed to get sensor", err)
 		}
 		return
 	}
 
+	if unit := r.URL.Query().Get("unit"); unit != "" {
+		conversion, err := FindUnitConversion(sensor.SensorType, unit)
+		if err != nil {
+			h.unsupportedUnit(w, err)
+			return
+		}
+		if sensor.LatestReading != nil {
+			conversion.ApplyToReading(sensor.LatestReading)
+		}
+	}
+
 	response.Success(w, "Sensor retrieved successfully", sensor)
 }
 
+// unitConversion resolves the unit query parameter for a sensor. It writes
+// the error response and returns false when the unit cannot be used.
+func (h *Handler) unitConversion(w http.ResponseWriter, r *http.Request, sensorID int) (*UnitConversion, bool) {
+	conversion, err := h.scopedService(r).GetUnitConversion(r.Context(), sensorID, r.URL.Query().Get("unit"))
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrSensorNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Sensor not found", err)
+		default:
+			h.unsupportedUnit(w, err)
+		}
+		return nil, false
+	}
+
+	return conversion, true
+}
+
+// unsupportedUnit answers an unknown unit conversion with the units the
+// sensor supports
+func (h *Handler) unsupportedUnit(w http.ResponseWriter, err error) {
+	var unitErr *UnsupportedUnitError
+	if !errors.As(err, &unitErr) {
+		response.InternalServerError(w, "Failed to convert units", err)
+		return
+	}
+
+	response.ErrorWithData(w, http.StatusBadRequest, "Unsupported unit", err,
+		map[string][]string{"supported_units": unitErr.Supported})
+}
+
 // GetSensorByDeviceID handles getting sensor by device ID
 func (h *Handler) GetSensorByDeviceID(w http.ResponseWriter, r *http.Request) {
 	deviceID := r.PathValue("device_id")
@@ -120,17 +333,28 @@ func (h *Handler) GetSensorByDeviceID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sensor, err := h.service.GetSensorByDeviceID(deviceID)
+	sensor, err := h.scopedService(r).GetSensorByDeviceID(r.Context(), deviceID)
 	if err != nil {
-		switch err {
-		case ErrSensorNotFound:
-			response.NotFound(w, "Sensor not found")
+		switch {
+		case errors.Is(err, ErrSensorNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Sensor not found", err)
 		default:
 			response.InternalServerError(w, "Failed to get sensor", err)
 		}
 		return
 	}
 
+	if unit := r.URL.Query().Get("unit"); unit != "" {
+		conversion, err := FindUnitConversion(sensor.SensorType, unit)
+		if err != nil {
+			h.unsupportedUnit(w, err)
+			return
+		}
+		if sensor.LatestReading != nil {
+			conversion.ApplyToReading(sensor.LatestReading)
+		}
+	}
+
 	response.Success(w, "Sensor retrieved successfully", sensor)
 }
 
@@ -148,23 +372,30 @@ func (h *Handler) UpdateSensor(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sensor, err := h.service.UpdateSensor(sensorID, &req)
+	sensor, err := h.scopedService(r).UpdateSensor(r.Context(), sensorID, &req)
 	if err != nil {
-		switch err {
-		case ErrInvalidBattery:
+		switch {
+		case errors.Is(err, ErrInvalidBattery), errors.Is(err, ErrInvalidExpectedInterval),
+			errors.Is(err, ErrInvalidIngestRate), errors.Is(err, ErrInvalidTags), errors.Is(err, ErrInvalidMetadata),
+			errors.Is(err, ErrMetadataTooLarge):
 			response.BadRequest(w, "Validation failed", err)
-		case ErrSensorNotFound, ErrLocationNotFound:
-			response.NotFound(w, err.Error())
+		case errors.Is(err, ErrSensorNotFound), errors.Is(err, ErrLocationNotFound):
+			response.ErrorFor(w, http.StatusNotFound, err.Error(), err)
+		case errors.Is(err, ErrSensorAccessDenied):
+			response.ErrorFor(w, http.StatusForbidden, "Write access to this sensor is required", err)
 		default:
 			response.InternalServerError(w, "Failed to update sensor", err)
 		}
 		return
 	}
 
+	h.audit.Record(r, audit.ActionSensorUpdate, audit.ResourceSensor, strconv.Itoa(sensorID), req)
+
 	response.Success(w, "Sensor updated successfully", sensor)
 }
 
-// DeleteSensor handles sensor deletion
+// DeleteSensor handles sensor deletion. With purge=true the sensor and its
+// readings are removed permanently instead of being deactivated.
 func (h *Handler) DeleteSensor(w http.ResponseWriter, r *http.Request) {
 	sensorID, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
@@ -172,21 +403,99 @@ func (h *Handler) DeleteSensor(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.service.DeleteSensor(sensorID); err != nil {
-		switch err {
-		case ErrSensorNotFound:
-			response.NotFound(w, "Sensor not found")
+	if purge, _ := strconv.ParseBool(r.URL.Query().Get("purge")); purge {
+		h.purgeSensor(w, r, sensorID)
+		return
+	}
+
+	if err := h.scopedService(r).DeleteSensor(r.Context(), sensorID); err != nil {
+		switch {
+		case errors.Is(err, ErrSensorNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Sensor not found", err)
 		default:
 			response.InternalServerError(w, "Failed to delete sensor", err)
 		}
 		return
 	}
 
+	h.audit.Record(r, audit.ActionSensorDelete, audit.ResourceSensor, strconv.Itoa(sensorID), nil)
+
 	response.Success(w, "Sensor deleted successfully", nil)
 }
 
-// ListSensors handles listing sensors with pagination
-func (h *Handler) ListSensors(w http.ResponseWriter, r *http.Request) {
+// purgeSensor permanently deletes a sensor and its readings
+func (h *Handler) purgeSensor(w http.ResponseWriter, r *http.Request, sensorID int) {
+	deleted, err := h.scopedService(r).PurgeSensor(r.Context(), sensorID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrSensorNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Sensor not found", err)
+		default:
+			response.InternalServerError(w, "Failed to purge sensor", err)
+		}
+		return
+	}
+
+	h.audit.Record(r, audit.ActionSensorPurge, audit.ResourceSensor, strconv.Itoa(sensorID),
+		map[string]int64{"readings_deleted": deleted})
+
+	response.Success(w, "Sensor purged successfully", map[string]int64{
+		"readings_deleted": deleted,
+	})
+}
+
+// ActivateSensor handles restoring a deleted sensor
+func (h *Handler) ActivateSensor(w http.ResponseWriter, r *http.Request) {
+	sensorID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid sensor ID", err)
+		return
+	}
+
+	sensor, err := h.scopedService(r).ActivateSensor(r.Context(), sensorID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrSensorNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Sensor not found", err)
+		case errors.Is(err, ErrSensorAccessDenied):
+			response.ErrorFor(w, http.StatusForbidden, "Write access to this sensor is required", err)
+		default:
+			response.InternalServerError(w, "Failed to activate sensor", err)
+		}
+		return
+	}
+
+	h.audit.Record(r, audit.ActionSensorActivate, audit.ResourceSensor, strconv.Itoa(sensorID), nil)
+
+	response.Success(w, "Sensor activated successfully", sensor)
+}
+
+// RotateDeviceToken handles issuing a new device token for a sensor
+func (h *Handler) RotateDeviceToken(w http.ResponseWriter, r *http.Request) {
+	sensorID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid sensor ID", err)
+		return
+	}
+
+	sensor, err := h.scopedService(r).RotateDeviceToken(r.Context(), sensorID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrSensorNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Sensor not found", err)
+		default:
+			response.InternalServerError(w, "Failed to rotate device token", err)
+		}
+		return
+	}
+
+	h.audit.Record(r, audit.ActionSensorTokenRotate, audit.ResourceSensor, strconv.Itoa(sensorID), nil)
+
+	response.Success(w, "Device token rotated successfully", sensor)
+}
+
+// ListPendingSensors handles listing auto-provisioned sensors awaiting approval
+func (h *Handler) ListPendingSensors(w http.ResponseWriter, r *http.Request) {
 	// Parse query parameters
 	page := 1
 	perPage := 20
@@ -203,9 +512,9 @@ func (h *Handler) ListSensors(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	sensors, total, err := h.service.ListSensors(page, perPage)
+	sensors, total, err := h.scopedService(r).ListPendingSensors(r.Context(), page, perPage)
 	if err != nil {
-		response.InternalServerError(w, "Failed to list sensors", err)
+		response.InternalServerError(w, "Failed to list pending sensors", err)
 		return
 	}
 
@@ -218,327 +527,2122 @@ func (h *Handler) ListSensors(w http.ResponseWriter, r *http.Request) {
 		TotalPages: totalPages,
 	}
 
-	response.PaginatedSuccess(w, "Sensors retrieved successfully", sensors, meta)
+	response.PaginatedSuccess(w, "Pending sensors retrieved successfully", sensors, meta)
 }
 
-// CreateSensorReading handles single sensor reading creation
-func (h *Handler) CreateSensorReading(w http.ResponseWriter, r *http.Request) {
-	var req CreateSensorReadingRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		response.BadRequest(w, "Invalid request body", err)
-		return
-	}
-
-	reading, err := h.service.CreateSensorReading(&req)
+// ApproveSensor handles approving a pending sensor
+func (h *Handler) ApproveSensor(w http.ResponseWriter, r *http.Request) {
+	sensorID, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		switch err {
-		case ErrInvalidQuality, ErrInvalidValue:
-			response.BadRequest(w, "Validation failed", err)
-		case ErrSensorNotFound:
-			response.NotFound(w, "Sensor not found")
-		case ErrSensorInactive:
-			response.Forbidden(w, "Sensor is inactive")
-		default:
-			response.InternalServerError(w, "Failed to create sensor reading", err)
-		}
+		response.BadRequest(w, "Invalid sensor ID", err)
 		return
 	}
 
-	response.Created(w, "Sensor reading created successfully", reading)
-}
-
-// CreateBulkSensorReadings handles bulk sensor readings creation
-func (h *Handler) CreateBulkSensorReadings(w http.ResponseWriter, r *http.Request) {
-	var req BulkSensorReadingRequest
+	var req ApproveSensorRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		response.BadRequest(w, "Invalid request body", err)
 		return
 	}
 
-	if err := h.service.CreateBulkSensorReadings(&req); err != nil {
-		if strings.Contains(err.Error(), "validation") || strings.Contains(err.Error(), "invalid") {
+	if err := req.Validate(); err != nil {
+		response.BadRequest(w, "Validation failed", err)
+		return
+	}
+
+	sensor, err := h.scopedService(r).ApproveSensor(r.Context(), sensorID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrSensorTypeNotFound), errors.Is(err, ErrLocationNotFound),
+			errors.Is(err, ErrOrganizationNotFound):
 			response.BadRequest(w, "Validation failed", err)
-		} else if strings.Contains(err.Error(), "not found") {
-			response.NotFound(w, err.Error())
-		} else if strings.Contains(err.Error(), "inactive") {
-			response.Forbidden(w, err.Error())
-		} else {
-			response.InternalServerError(w, "Failed to create bulk sensor readings", err)
+		case errors.Is(err, ErrOrganizationSuperAdmin):
+			response.ErrorFor(w, http.StatusForbidden, err.Error(), err)
+		case errors.Is(err, ErrSensorNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Sensor not found", err)
+		case errors.Is(err, ErrSensorProvisioned):
+			response.Conflict(w, "Sensor is already provisioned", err)
+		default:
+			response.InternalServerError(w, "Failed to approve sensor", err)
 		}
 		return
 	}
 
-	response.Success(w, "Bulk sensor readings created successfully", map[string]int{
-		"count": len(req.Readings),
-	})
-}
+	h.audit.Record(r, audit.ActionSensorApprove, audit.ResourceSensor, strconv.Itoa(sensorID), req)
 
-// GetSensorReadings handles getting sensor readings with filters
-func (h *Handler) GetSensorReadings(w http.ResponseWriter, r *http.Request) {
-	query := &SensorReadingQuery{
-		Limit:  100,
-		Offset: 0,
-	}
+	response.Success(w, "Sensor approved successfully", sensor)
+}
 
+// ListSensors handles listing sensors with pagination
+func (h *Handler) ListSensors(w http.ResponseWriter, r *http.Request) {
 	// Parse query parameters
-	if sensorIDStr := r.URL.Query().Get("sensor_id"); sensorIDStr != "" {
-		if sensorID, err := strconv.Atoi(sensorIDStr); err == nil {
-			query.SensorID = &sensorID
-		}
-	}
-
-	if startTimeStr := r.URL.Query().Get("start_time"); startTimeStr != "" {
-		if startTime, err := time.Parse(time.RFC3339, startTimeStr); err == nil {
-			query.StartTime = &startTime
-		}
-	}
+	page := 1
+	perPage := 20
 
-	if endTimeStr := r.URL.Query().Get("end_time"); endTimeStr != "" {
-		if endTime, err := time.Parse(time.RFC3339, endTimeStr); err == nil {
-			query.EndTime = &endTime
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
 		}
 	}
 
-	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
-		if limit, err := strconv.Atoi(limitStr); err == nil && limit > 0 && limit <= 1000 {
-			query.Limit = limit
+	if perPageStr := r.URL.Query().Get("per_page"); perPageStr != "" {
+		if pp, err := strconv.Atoi(perPageStr); err == nil && pp > 0 && pp <= 100 {
+			perPage = pp
 		}
 	}
 
-	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
-		if offset, err := strconv.Atoi(offsetStr); err == nil && offset >= 0 {
-			query.Offset = offset
-		}
+	filter, err := parseSensorFilter(r)
+	if err != nil {
+		response.BadRequest(w, "Invalid query parameters", err)
+		return
 	}
 
-	if minQualityStr := r.URL.Query().Get("min_quality"); minQualityStr != "" {
-		if minQuality, err := strconv.Atoi(minQualityStr); err == nil && minQuality >= 0 && minQuality <= 100 {
-			query.MinQuality = &minQuality
+	// Deleted sensors are only listed for users who can restore them
+	if filter.IsActive == nil || !*filter.IsActive {
+		user, ok := middleware.GetUserFromContext(r.Context())
+		if !ok || !user.HasPermission("sensors", "write") {
+			response.Forbidden(w, "Listing inactive sensors requires sensors write permission")
+			return
 		}
 	}
 
-	readings, total, err := h.service.GetSensorReadings(query)
+	sensors, total, err := h.scopedService(r).ListSensors(r.Context(), page, perPage, filter)
 	if err != nil {
-		response.InternalServerError(w, "Failed to get sensor readings", err)
+		response.InternalServerError(w, "Failed to list sensors", err)
 		return
 	}
 
 	// Calculate pagination meta
-	totalPages := (total + query.Limit - 1) / query.Limit
+	totalPages := (total + perPage - 1) / perPage
 	meta := &response.Meta{
-		Page:       (query.Offset / query.Limit) + 1,
-		PerPage:    query.Limit,
+		Page:       page,
+		PerPage:    perPage,
 		Total:      total,
 		TotalPages: totalPages,
 	}
 
-	response.PaginatedSuccess(w, "Sensor readings retrieved successfully", readings, meta)
+	response.PaginatedSuccess(w, "Sensors retrieved successfully", sensors, meta)
 }
 
-// ListSensorTypes handles listing sensor types
-func (h *Handler) ListSensorTypes(w http.ResponseWriter, r *http.Request) {
-	sensorTypes, err := h.service.ListSensorTypes()
+// ListSensorTags handles listing the tags in use with their sensor counts
+func (h *Handler) ListSensorTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := h.scopedService(r).ListSensorTags(r.Context())
 	if err != nil {
-		response.InternalServerError(w, "Failed to list sensor types", err)
+		response.InternalServerError(w, "Failed to list sensor tags", err)
 		return
 	}
 
-	response.Success(w, "Sensor types retrieved successfully", sensorTypes)
+	response.Success(w, "Sensor tags retrieved successfully", tags)
 }
 
-// CreateLocation handles location creation
-func (h *Handler) CreateLocation(w http.ResponseWriter, r *http.Request) {
-	var req CreateLocationRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		response.BadRequest(w, "Invalid request body", err)
-		return
-	}
-
-	location, err := h.service.CreateLocation(&req)
+// GetFirmwareReport handles counting sensors per type and firmware version
+func (h *Handler) GetFirmwareReport(w http.ResponseWriter, r *http.Request) {
+	report, err := h.scopedService(r).GetFirmwareReport(r.Context())
 	if err != nil {
-		response.BadRequest(w, "Validation failed", err)
+		response.InternalServerError(w, "Failed to get firmware report", err)
 		return
 	}
 
-	response.Created(w, "Location created successfully", location)
+	response.Success(w, "Firmware report retrieved successfully", report)
 }
 
-// GetSensorType handles getting sensor type by ID
-func (h *Handler) GetSensorType(w http.ResponseWriter, r *http.Request) {
-	typeID, err := strconv.Atoi(r.PathValue("id"))
+// ListFirmwareHistory handles listing the firmware changes of a sensor
+func (h *Handler) ListFirmwareHistory(w http.ResponseWriter, r *http.Request) {
+	sensorID, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		response.BadRequest(w, "Invalid sensor type ID", err)
+		response.BadRequest(w, "Invalid sensor ID", err)
 		return
 	}
 
-	sensorType, err := h.service.GetSensorType(typeID)
+	changes, err := h.scopedService(r).ListFirmwareHistory(r.Context(), sensorID)
 	if err != nil {
-		switch err {
-		case ErrSensorTypeNotFound:
-			response.NotFound(w, "Sensor type not found")
+		switch {
+		case errors.Is(err, ErrSensorNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Sensor not found", err)
 		default:
-			response.InternalServerError(w, "Failed to get sensor type", err)
+			response.InternalServerError(w, "Failed to list sensor firmware history", err)
 		}
 		return
 	}
 
-	response.Success(w, "Sensor type retrieved successfully", sensorType)
+	response.Success(w, "Sensor firmware history retrieved successfully", changes)
 }
 
-// GetLocation handles getting location by ID
-func (h *Handler) GetLocation(w http.ResponseWriter, r *http.Request) {
-	locationID, err := strconv.Atoi(r.PathValue("id"))
-	if err != nil {
-		response.BadRequest(w, "Invalid location ID", err)
-		return
+// parseSensorFilter builds the sensor list filter from query parameters.
+// Only active sensors are listed unless is_active is false or all, or
+// include_inactive is true; newest first unless sort and order say otherwise.
+func parseSensorFilter(r *http.Request) (*SensorFilter, error) {
+	params := r.URL.Query()
+	active := true
+	filter := &SensorFilter{
+		Query:    strings.TrimSpace(params.Get("q")),
+		IsActive: &active,
+		SortBy:   "created_at",
+		SortDesc: true,
 	}
 
-	location, err := h.service.GetLocation(locationID)
-	if err != nil {
-		switch err {
-		case ErrLocationNotFound:
-			response.NotFound(w, "Location not found")
-		default:
-			response.InternalServerError(w, "Failed to get location", err)
+	if typeStr := params.Get("sensor_type_id"); typeStr != "" {
+		typeID, err := strconv.Atoi(typeStr)
+		if err != nil || typeID <= 0 {
+			return nil, errors.New("sensor_type_id must be a positive integer")
 		}
-		return
+		filter.SensorTypeID = &typeID
 	}
 
-	response.Success(w, "Location retrieved successfully", location)
-}
-
-// UpdateLocation handles location updates
-func (h *Handler) UpdateLocation(w http.ResponseWriter, r *http.Request) {
-	locationID, err := strconv.Atoi(r.PathValue("id"))
-	if err != nil {
-		response.BadRequest(w, "Invalid location ID", err)
-		return
+	if locationStr := params.Get("location_id"); locationStr != "" {
+		locationID, err := strconv.Atoi(locationStr)
+		if err != nil || locationID <= 0 {
+			return nil, errors.New("location_id must be a positive integer")
+		}
+		filter.LocationID = &locationID
 	}
 
-	var req UpdateLocationRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		response.BadRequest(w, "Invalid request body", err)
-		return
+	switch params.Get("is_active") {
+	case "", "true":
+	case "false":
+		active = false
+	case "all":
+		filter.IsActive = nil
+	default:
+		return nil, errors.New("is_active must be true, false or all")
 	}
 
-	location, err := h.service.UpdateLocation(locationID, &req)
-	if err != nil {
-		if strings.Contains(err.Error(), "validation") {
-			response.BadRequest(w, "Validation failed", err)
-		} else if err == ErrLocationNotFound {
-			response.NotFound(w, "Location not found")
-		} else {
-			response.InternalServerError(w, "Failed to update location", err)
+	if includeStr := params.Get("include_inactive"); includeStr != "" {
+		include, err := strconv.ParseBool(includeStr)
+		if err != nil {
+			return nil, errors.New("include_inactive must be true or false")
 		}
+		if include && params.Get("is_active") == "" {
+			filter.IsActive = nil
+		}
+	}
+
+	if onlineStr := params.Get("online"); onlineStr != "" {
+		online, err := strconv.ParseBool(onlineStr)
+		if err != nil {
+			return nil, errors.New("online must be true or false")
+		}
+		filter.Online = &online
+	}
+
+	if mineStr := params.Get("mine"); mineStr != "" {
+		mine, err := strconv.ParseBool(mineStr)
+		if err != nil {
+			return nil, errors.New("mine must be true or false")
+		}
+		if mine {
+			user, ok := middleware.GetUserFromContext(r.Context())
+			if !ok {
+				return nil, errors.New("mine requires an authenticated user")
+			}
+			filter.CreatedBy = &user.ID
+		}
+	}
+
+	if tag := params.Get("tag"); tag != "" {
+		filter.Tag = strings.ToLower(strings.TrimSpace(tag))
+	}
+
+	// metadata.<key>=<value> matches a top-level metadata key
+	for param, values := range params {
+		key, ok := strings.CutPrefix(param, "metadata.")
+		if !ok {
+			continue
+		}
+		if key == "" {
+			return nil, errors.New("metadata filters must name a key, as in metadata.asset_tag")
+		}
+		if filter.Metadata == nil {
+			filter.Metadata = make(map[string]string)
+		}
+		filter.Metadata[key] = values[0]
+	}
+
+	if sort := params.Get("sort"); sort != "" {
+		if _, ok := sensorSortColumns[sort]; !ok {
+			return nil, errors.New("sort must be one of name, device_id, created_at, last_reading_at, battery_level")
+		}
+		filter.SortBy = sort
+		filter.SortDesc = false
+	}
+
+	switch params.Get("order") {
+	case "":
+	case "asc":
+		filter.SortDesc = false
+	case "desc":
+		filter.SortDesc = true
+	default:
+		return nil, errors.New("order must be asc or desc")
+	}
+
+	return filter, nil
+}
+
+// parseHealthScore parses an optional health score parameter
+func parseHealthScore(value, name string) (*int, error) {
+	if value == "" {
+		return nil, nil
+	}
+	score, err := strconv.Atoi(value)
+	if err != nil || score < 0 || score > 100 {
+		return nil, fmt.Errorf("%s must be an integer between 0 and 100", name)
+	}
+	return &score, nil
+}
+
+// authenticateDevice checks the device token of a readings request against
+// the sensors written to, writing the error response when it fails
+func (h *Handler) authenticateDevice(w http.ResponseWriter, r *http.Request, sensorIDs []int) bool {
+	err := h.service.AuthenticateDevice(r.Context(), r.Header.Get(DeviceTokenHeader), sensorIDs)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, ErrDeviceTokenRequired), errors.Is(err, ErrInvalidDeviceToken):
+		response.Unauthorized(w, err.Error())
+	default:
+		response.InternalServerError(w, "Failed to authenticate device", err)
+	}
+	return false
+}
+
+// CreateSensorReading handles single sensor reading creation
+func (h *Handler) CreateSensorReading(w http.ResponseWriter, r *http.Request) {
+	var req CreateSensorReadingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	if !h.authenticateDevice(w, r, []int{req.SensorID}) {
+		return
+	}
+
+	reading, err := h.service.CreateSensorReading(r.Context(), &req)
+	if err != nil {
+		var rateErr *RateLimitError
+		if errors.As(err, &rateErr) {
+			retryAfter := int(math.Ceil(rateErr.RetryAfter.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			response.Error(w, http.StatusTooManyRequests, "Sensor is sending readings too fast", err)
+			return
+		}
+
+		switch {
+		case errors.Is(err, ErrDuplicateReading):
+			// Devices retry posts they did not see acknowledged
+			response.Success(w, "Sensor reading already recorded", nil)
+		case errors.Is(err, ErrInvalidQuality), errors.Is(err, ErrInvalidValue):
+			response.BadRequest(w, "Validation failed", err)
+		case errors.Is(err, ErrSensorNotFound):
+			response.NotFound(w, "Sensor not found")
+		case errors.Is(err, ErrSensorInactive):
+			response.Forbidden(w, "Sensor is inactive")
+		default:
+			response.InternalServerError(w, "Failed to create sensor reading", err)
+		}
+		return
+	}
+
+	response.Created(w, "Sensor reading created successfully", reading)
+}
+
+// CreateBulkSensorReadings handles bulk sensor readings creation
+func (h *Handler) CreateBulkSensorReadings(w http.ResponseWriter, r *http.Request) {
+	var req BulkSensorReadingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	sensorIDs := make([]int, len(req.Readings))
+	for i, reading := range req.Readings {
+		sensorIDs[i] = reading.SensorID
+	}
+	if !h.authenticateDevice(w, r, sensorIDs) {
+		return
+	}
+
+	result, err := h.service.CreateBulkSensorReadings(r.Context(), &req)
+	if err != nil {
+		var rateErr *RateLimitError
+		if errors.As(err, &rateErr) {
+			retryAfter := int(math.Ceil(rateErr.RetryAfter.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			response.Error(w, http.StatusTooManyRequests, "Sensor is sending readings too fast", err)
+			return
+		}
+
+		switch {
+		case errors.Is(err, ErrSensorNotFound):
+			response.ErrorFor(w, http.StatusNotFound, err.Error(), err)
+		case errors.Is(err, ErrSensorInactive):
+			response.ErrorFor(w, http.StatusForbidden, err.Error(), err)
+		case errors.Is(err, ErrNoReadings), errors.Is(err, ErrTooManyReadings), errors.Is(err, ErrInvalidReading):
+			response.BadRequest(w, "Validation failed", err)
+		default:
+			response.InternalServerError(w, "Failed to create bulk sensor readings", err)
+		}
+		return
+	}
+
+	// Partial batches answer 207 so devices look for rejected readings
+	if req.AllowPartial {
+		response.JSON(w, http.StatusMultiStatus, response.APIResponse{
+			Success: true,
+			Message: fmt.Sprintf("%d sensor readings accepted, %d rejected", result.Accepted, result.Rejected),
+			Data:    result,
+		})
+		return
+	}
+
+	response.Success(w, "Bulk sensor readings created successfully", result)
+}
+
+// GetSensorReadings handles getting sensor readings with filters
+func (h *Handler) GetSensorReadings(w http.ResponseWriter, r *http.Request) {
+	query := &SensorReadingQuery{
+		Limit:  100,
+		Offset: 0,
+	}
+
+	// Parse query parameters
+	if sensorIDStr := r.URL.Query().Get("sensor_id"); sensorIDStr != "" {
+		if sensorID, err := strconv.Atoi(sensorIDStr); err == nil {
+			query.SensorID = &sensorID
+		}
+	}
+
+	if startTimeStr := r.URL.Query().Get("start_time"); startTimeStr != "" {
+		if startTime, err := time.Parse(time.RFC3339, startTimeStr); err == nil {
+			query.StartTime = &startTime
+		}
+	}
+
+	if endTimeStr := r.URL.Query().Get("end_time"); endTimeStr != "" {
+		if endTime, err := time.Parse(time.RFC3339, endTimeStr); err == nil {
+			query.EndTime = &endTime
+		}
+	}
+
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if limit, err := strconv.Atoi(limitStr); err == nil && limit > 0 && limit <= 1000 {
+			query.Limit = limit
+		}
+	}
+
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		if offset, err := strconv.Atoi(offsetStr); err == nil && offset >= 0 {
+			query.Offset = offset
+		}
+	}
+
+	if minQualityStr := r.URL.Query().Get("min_quality"); minQualityStr != "" {
+		if minQuality, err := strconv.Atoi(minQualityStr); err == nil && minQuality >= 0 && minQuality <= 100 {
+			query.MinQuality = &minQuality
+		}
+	}
+
+	if minValueStr := r.URL.Query().Get("min_value"); minValueStr != "" {
+		if minValue, err := strconv.ParseFloat(minValueStr, 64); err == nil {
+			query.MinValue = &minValue
+		}
+	}
+
+	if maxValueStr := r.URL.Query().Get("max_value"); maxValueStr != "" {
+		if maxValue, err := strconv.ParseFloat(maxValueStr, 64); err == nil {
+			query.MaxValue = &maxValue
+		}
+	}
+
+	query.Ascending = r.URL.Query().Get("order") == "asc"
+
+	if smooth := r.URL.Query().Get("smooth"); smooth != "" {
+		window, err := ParseSmoothing(smooth)
+		if err != nil {
+			response.BadRequest(w, "Invalid smooth parameter", err)
+			return
+		}
+		query.SmoothWindow = window
+	}
+
+	// Units are converted per sensor type, so conversion needs one sensor
+	var conversion *UnitConversion
+	if r.URL.Query().Get("unit") != "" {
+		if query.SensorID == nil {
+			response.BadRequest(w, "unit requires sensor_id", nil)
+			return
+		}
+		var ok bool
+		if conversion, ok = h.unitConversion(w, r, *query.SensorID); !ok {
+			return
+		}
+	}
+
+	readings, total, err := h.scopedService(r).GetSensorReadings(r.Context(), query)
+	if err != nil {
+		response.InternalServerError(w, "Failed to get sensor readings", err)
+		return
+	}
+
+	if r.URL.Query().Get("raw") == "true" {
+		for _, reading := range readings {
+			reading.UseRawValue()
+		}
+	}
+
+	if conversion != nil {
+		for _, reading := range readings {
+			conversion.ApplyToReading(reading)
+		}
+	}
+
+	// Calculate pagination meta
+	totalPages := (total + query.Limit - 1) / query.Limit
+	meta := &response.Meta{
+		Page:       (query.Offset / query.Limit) + 1,
+		PerPage:    query.Limit,
+		Total:      total,
+		TotalPages: totalPages,
+	}
+
+	response.PaginatedSuccess(w, "Sensor readings retrieved successfully", readings, meta)
+}
+
+// GetReading handles getting a single sensor reading
+func (h *Handler) GetReading(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(w, "Invalid reading ID", err)
+		return
+	}
+
+	reading, err := h.scopedService(r).GetReadingByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrReadingNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Sensor reading not found", err)
+		default:
+			response.InternalServerError(w, "Failed to get sensor reading", err)
+		}
+		return
+	}
+
+	if r.URL.Query().Get("raw") == "true" {
+		reading.UseRawValue()
+	}
+
+	if r.URL.Query().Get("unit") != "" {
+		conversion, ok := h.unitConversion(w, r, reading.SensorID)
+		if !ok {
+			return
+		}
+		conversion.ApplyToReading(reading)
+	}
+
+	response.Success(w, "Sensor reading retrieved successfully", reading)
+}
+
+// UpdateReadingQuality handles correcting the quality and metadata of a reading
+func (h *Handler) UpdateReadingQuality(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		response.Unauthorized(w, "User not found in context")
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(w, "Invalid reading ID", err)
+		return
+	}
+
+	var req UpdateReadingQualityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	reading, err := h.scopedService(r).UpdateReadingQuality(r.Context(), id, &req, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrNoReadingChanges), errors.Is(err, ErrInvalidQuality), errors.Is(err, ErrInvalidMetadata):
+			response.BadRequest(w, "Validation failed", err)
+		case errors.Is(err, ErrReadingNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Sensor reading not found", err)
+		case errors.Is(err, ErrSensorAccessDenied):
+			response.ErrorFor(w, http.StatusForbidden, "Write access to this sensor is required", err)
+		default:
+			response.InternalServerError(w, "Failed to update sensor reading", err)
+		}
+		return
+	}
+
+	h.audit.Record(r, audit.ActionReadingUpdate, audit.ResourceSensor, strconv.Itoa(reading.SensorID), map[string]interface{}{
+		"reading_id": reading.ID,
+		"quality":    req.Quality,
+		"metadata":   req.Metadata,
+	})
+
+	response.Success(w, "Sensor reading updated successfully", reading)
+}
+
+// DeleteReadings handles deleting a sensor's readings in a time window,
+// which both start_time and end_time must bound
+func (h *Handler) DeleteReadings(w http.ResponseWriter, r *http.Request) {
+	sensorID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid sensor ID", err)
+		return
+	}
+
+	var startTime, endTime time.Time
+	if startTimeStr := r.URL.Query().Get("start_time"); startTimeStr != "" {
+		startTime, err = time.Parse(time.RFC3339, startTimeStr)
+		if err != nil {
+			response.BadRequest(w, "Invalid start_time format, use RFC3339", err)
+			return
+		}
+		startTime = startTime.UTC()
+	}
+
+	if endTimeStr := r.URL.Query().Get("end_time"); endTimeStr != "" {
+		endTime, err = time.Parse(time.RFC3339, endTimeStr)
+		if err != nil {
+			response.BadRequest(w, "Invalid end_time format, use RFC3339", err)
+			return
+		}
+		endTime = endTime.UTC()
+	}
+
+	deleted, err := h.scopedService(r).DeleteReadingsInRange(r.Context(), sensorID, startTime, endTime)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrTimeRangeRequired), errors.Is(err, ErrInvalidTimeRange),
+			errors.Is(err, ErrDeleteWindowTooBig):
+			response.BadRequest(w, "Invalid time window", err)
+		case errors.Is(err, ErrSensorNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Sensor not found", err)
+		case errors.Is(err, ErrSensorAccessDenied):
+			response.ErrorFor(w, http.StatusForbidden, "Write access to this sensor is required", err)
+		default:
+			response.InternalServerError(w, "Failed to delete sensor readings", err)
+		}
+		return
+	}
+
+	h.audit.Record(r, audit.ActionReadingsDelete, audit.ResourceSensor, strconv.Itoa(sensorID), map[string]interface{}{
+		"start_time": startTime,
+		"end_time":   endTime,
+		"readings":   deleted,
+	})
+
+	response.Success(w, "Sensor readings deleted successfully", map[string]int64{
+		"readings_deleted": deleted,
+	})
+}
+
+// GetStatusHistory handles listing the online/offline transitions of a sensor
+func (h *Handler) GetStatusHistory(w http.ResponseWriter, r *http.Request) {
+	sensorID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid sensor ID", err)
+		return
+	}
+
+	// Parse query parameters
+	page := 1
+	perPage := 20
+
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if perPageStr := r.URL.Query().Get("per_page"); perPageStr != "" {
+		if pp, err := strconv.Atoi(perPageStr); err == nil && pp > 0 && pp <= 100 {
+			perPage = pp
+		}
+	}
+
+	events, total, err := h.scopedService(r).GetStatusHistory(r.Context(), sensorID, perPage, (page-1)*perPage)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrSensorNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Sensor not found", err)
+		default:
+			response.InternalServerError(w, "Failed to get sensor status history", err)
+		}
+		return
+	}
+
+	// Calculate pagination meta
+	totalPages := (total + perPage - 1) / perPage
+	meta := &response.Meta{
+		Page:       page,
+		PerPage:    perPage,
+		Total:      total,
+		TotalPages: totalPages,
+	}
+
+	response.PaginatedSuccess(w, "Sensor status history retrieved successfully", events, meta)
+}
+
+// GetAvailability handles getting the uptime of a sensor over a time range.
+// The range defaults to the last 30 days.
+func (h *Handler) GetAvailability(w http.ResponseWriter, r *http.Request) {
+	sensorID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid sensor ID", err)
+		return
+	}
+
+	endTime := time.Now().UTC()
+	startTime := endTime.Add(-30 * 24 * time.Hour)
+
+	if startTimeStr := r.URL.Query().Get("start_time"); startTimeStr != "" {
+		startTime, err = time.Parse(time.RFC3339, startTimeStr)
+		if err != nil {
+			response.BadRequest(w, "Invalid start_time format, use RFC3339", err)
+			return
+		}
+		startTime = startTime.UTC()
+	}
+
+	if endTimeStr := r.URL.Query().Get("end_time"); endTimeStr != "" {
+		endTime, err = time.Parse(time.RFC3339, endTimeStr)
+		if err != nil {
+			response.BadRequest(w, "Invalid end_time format, use RFC3339", err)
+			return
+		}
+		endTime = endTime.UTC()
+	}
+
+	availability, err := h.scopedService(r).GetAvailability(r.Context(), sensorID, startTime, endTime)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidTimeRange), errors.Is(err, ErrAvailabilityRangeTooBig):
+			response.BadRequest(w, "Invalid time range", err)
+		case errors.Is(err, ErrSensorNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Sensor not found", err)
+		default:
+			response.InternalServerError(w, "Failed to get sensor availability", err)
+		}
+		return
+	}
+
+	response.Success(w, "Sensor availability retrieved successfully", availability)
+}
+
+// PurgeReadings handles deleting readings older than a cutoff; with
+// dry_run the readings are only counted
+func (h *Handler) PurgeReadings(w http.ResponseWriter, r *http.Request) {
+	var req PurgeReadingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	result, err := h.scopedService(r).PurgeReadings(r.Context(), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidPurgeCutoff):
+			response.BadRequest(w, "Validation failed", err)
+		case errors.Is(err, ErrSensorNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Sensor not found", err)
+		default:
+			response.InternalServerError(w, "Failed to purge sensor readings", err)
+		}
+		return
+	}
+
+	if req.DryRun {
+		response.Success(w, "Sensor readings counted successfully", result)
+		return
+	}
+
+	h.audit.Record(r, audit.ActionReadingsPurge, audit.ResourceSensor, "", map[string]interface{}{
+		"sensor_id": req.SensorID,
+		"before":    req.Before,
+		"readings":  result.Readings,
+	})
+
+	response.Success(w, "Sensor readings purged successfully", result)
+}
+
+// GetAggregatedReadings handles getting a sensor's readings grouped into
+// time buckets. The range defaults to the last 24 hours and the interval to 1h.
+func (h *Handler) GetAggregatedReadings(w http.ResponseWriter, r *http.Request) {
+	sensorID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid sensor ID", err)
+		return
+	}
+
+	params := r.URL.Query()
+	query := &ReadingAggregateQuery{
+		Interval:  time.Hour,
+		EndTime:   time.Now().UTC(),
+		Fn:        AggregateAvg,
+		StartTime: time.Now().UTC().Add(-24 * time.Hour),
+	}
+
+	if intervalStr := params.Get("interval"); intervalStr != "" {
+		interval, err := ParseAggregateInterval(intervalStr)
+		if err != nil {
+			response.BadRequest(w, "Invalid interval", err)
+			return
+		}
+		query.Interval = interval
+	}
+
+	if startTimeStr := params.Get("start_time"); startTimeStr != "" {
+		startTime, err := time.Parse(time.RFC3339, startTimeStr)
+		if err != nil {
+			response.BadRequest(w, "Invalid start_time format, use RFC3339", err)
+			return
+		}
+		query.StartTime = startTime.UTC()
+	}
+
+	if endTimeStr := params.Get("end_time"); endTimeStr != "" {
+		endTime, err := time.Parse(time.RFC3339, endTimeStr)
+		if err != nil {
+			response.BadRequest(w, "Invalid end_time format, use RFC3339", err)
+			return
+		}
+		query.EndTime = endTime.UTC()
+	}
+
+	if fn := params.Get("fn"); fn != "" {
+		query.Fn = fn
+	}
+
+	switch params.Get("fill") {
+	case "":
+	case "null":
+		query.Fill = true
+	default:
+		response.BadRequest(w, "fill must be null", nil)
+		return
+	}
+
+	buckets, err := h.scopedService(r).GetAggregatedReadings(r.Context(), sensorID, query)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidInterval), errors.Is(err, ErrInvalidAggregateFn),
+			errors.Is(err, ErrInvalidTimeRange), errors.Is(err, ErrTooManyBuckets):
+			response.BadRequest(w, "Invalid aggregation query", err)
+		case errors.Is(err, ErrSensorNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Sensor not found", err)
+		default:
+			response.InternalServerError(w, "Failed to aggregate sensor readings", err)
+		}
+		return
+	}
+
+	response.Success(w, "Sensor readings aggregated successfully", buckets)
+}
+
+// ListSensorTypes handles listing sensor types
+func (h *Handler) ListSensorTypes(w http.ResponseWriter, r *http.Request) {
+	sensorTypes, err := h.service.ListSensorTypes(r.Context())
+	if err != nil {
+		response.InternalServerError(w, "Failed to list sensor types", err)
+		return
+	}
+
+	response.Success(w, "Sensor types retrieved successfully", sensorTypes)
+}
+
+// CreateSensorType handles sensor type creation
+func (h *Handler) CreateSensorType(w http.ResponseWriter, r *http.Request) {
+	var req CreateSensorTypeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	sensorType, err := h.service.CreateSensorType(r.Context(), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidTypeName), errors.Is(err, ErrInvalidUnit), errors.Is(err, ErrInvalidValueRange),
+			errors.Is(err, ErrInvalidRetention), errors.Is(err, ErrInvalidDecimalPlaces),
+			errors.Is(err, ErrInvalidValueLabels):
+			response.BadRequest(w, "Validation failed", err)
+		case errors.Is(err, ErrSensorTypeExists):
+			response.Conflict(w, "Sensor type already exists", err)
+		default:
+			response.InternalServerError(w, "Failed to create sensor type", err)
+		}
+		return
+	}
+
+	h.audit.Record(r, audit.ActionSensorTypeCreate, audit.ResourceSensorType, strconv.Itoa(sensorType.ID), req)
+
+	response.Created(w, "Sensor type created successfully", sensorType)
+}
+
+// UpdateSensorType handles sensor type updates
+func (h *Handler) UpdateSensorType(w http.ResponseWriter, r *http.Request) {
+	typeID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid sensor type ID", err)
+		return
+	}
+
+	var req UpdateSensorTypeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	sensorType, err := h.service.UpdateSensorType(r.Context(), typeID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidTypeName), errors.Is(err, ErrInvalidUnit), errors.Is(err, ErrInvalidValueRange),
+			errors.Is(err, ErrInvalidRetention), errors.Is(err, ErrInvalidDecimalPlaces),
+			errors.Is(err, ErrInvalidValueLabels):
+			response.BadRequest(w, "Validation failed", err)
+		case errors.Is(err, ErrSensorTypeNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Sensor type not found", err)
+		case errors.Is(err, ErrSensorTypeExists):
+			response.Conflict(w, "Sensor type already exists", err)
+		default:
+			response.InternalServerError(w, "Failed to update sensor type", err)
+		}
+		return
+	}
+
+	h.audit.Record(r, audit.ActionSensorTypeUpdate, audit.ResourceSensorType, strconv.Itoa(typeID), req)
+
+	response.Success(w, "Sensor type updated successfully", sensorType)
+}
+
+// DeleteSensorType handles sensor type deactivation
+func (h *Handler) DeleteSensorType(w http.ResponseWriter, r *http.Request) {
+	typeID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid sensor type ID", err)
+		return
+	}
+
+	if err := h.service.DeleteSensorType(r.Context(), typeID); err != nil {
+		switch {
+		case errors.Is(err, ErrSensorTypeNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Sensor type not found", err)
+		default:
+			response.InternalServerError(w, "Failed to delete sensor type", err)
+		}
+		return
+	}
+
+	h.audit.Record(r, audit.ActionSensorTypeDelete, audit.ResourceSensorType, strconv.Itoa(typeID), nil)
+
+	response.Success(w, "Sensor type deleted successfully", nil)
+}
+
+// CreateLocation handles location creation
+func (h *Handler) CreateLocation(w http.ResponseWriter, r *http.Request) {
+	var req CreateLocationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	location, err := h.scopedService(r).CreateLocation(r.Context(), &req)
+	if err != nil {
+		response.BadRequest(w, "Validation failed", err)
+		return
+	}
+
+	response.Created(w, "Location created successfully", location)
+}
+
+// GetSensorType handles getting sensor type by ID
+func (h *Handler) GetSensorType(w http.ResponseWriter, r *http.Request) {
+	typeID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid sensor type ID", err)
+		return
+	}
+
+	sensorType, err := h.service.GetSensorType(r.Context(), typeID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrSensorTypeNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Sensor type not found", err)
+		default:
+			response.InternalServerError(w, "Failed to get sensor type", err)
+		}
+		return
+	}
+
+	response.Success(w, "Sensor type retrieved successfully", sensorType)
+}
+
+// GetLocation handles getting location by ID
+func (h *Handler) GetLocation(w http.ResponseWriter, r *http.Request) {
+	locationID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid location ID", err)
+		return
+	}
+
+	location, err := h.scopedService(r).GetLocation(r.Context(), locationID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrLocationNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Location not found", err)
+		default:
+			response.InternalServerError(w, "Failed to get location", err)
+		}
+		return
+	}
+
+	response.Success(w, "Location retrieved successfully", location)
+}
+
+// UpdateLocation handles location updates
+func (h *Handler) UpdateLocation(w http.ResponseWriter, r *http.Request) {
+	locationID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid location ID", err)
+		return
+	}
+
+	var req UpdateLocationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	location, err := h.scopedService(r).UpdateLocation(r.Context(), locationID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrEmptyLocationName), errors.Is(err, ErrInvalidLatitude),
+			errors.Is(err, ErrInvalidLongitude), errors.Is(err, ErrAddressTooLong):
+			response.BadRequest(w, "Validation failed", err)
+		case errors.Is(err, ErrLocationNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Location not found", err)
+		default:
+			response.InternalServerError(w, "Failed to update location", err)
+		}
+		return
+	}
+
+	response.Success(w, "Location updated successfully", location)
+}
+
+// DeleteLocation handles location deactivation. Locations with active
+// sensors are kept unless force=true, which detaches the sensors first.
+func (h *Handler) DeleteLocation(w http.ResponseWriter, r *http.Request) {
+	locationID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid location ID", err)
+		return
+	}
+
+	force, _ := strconv.ParseBool(r.URL.Query().Get("force"))
+
+	detached, err := h.scopedService(r).DeactivateLocation(r.Context(), locationID, force)
+	if err != nil {
+		var inUseErr *LocationInUseError
+		switch {
+		case errors.As(err, &inUseErr):
+			response.ErrorWithData(w, http.StatusConflict, "Location still has active sensors", err,
+				map[string]int{"active_sensors": inUseErr.ActiveSensors})
+		case errors.Is(err, ErrLocationNotFound):
+			response.NotFound(w, "Location not found")
+		default:
+			response.InternalServerError(w, "Failed to delete location", err)
+		}
+		return
+	}
+
+	h.audit.Record(r, audit.ActionLocationDelete, audit.ResourceLocation, strconv.Itoa(locationID),
+		map[string]interface{}{"force": force, "sensors_detached": detached})
+
+	response.Success(w, "Location deleted successfully", map[string]int{
+		"sensors_detached": detached,
+	})
+}
+
+// AssignSensorsToLocation handles moving sensors to a location
+func (h *Handler) AssignSensorsToLocation(w http.ResponseWriter, r *http.Request) {
+	locationID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid location ID", err)
+		return
+	}
+
+	var req AssignSensorsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	assignment, err := h.scopedService(r).AssignSensorsToLocation(r.Context(), locationID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidSensorIDs):
+			response.BadRequest(w, "Validation failed", err)
+		case errors.Is(err, ErrLocationNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Location not found", err)
+		case errors.Is(err, ErrLocationInactive):
+			response.Conflict(w, "Location is inactive", err)
+		default:
+			response.InternalServerError(w, "Failed to assign sensors to location", err)
+		}
+		return
+	}
+
+	h.audit.Record(r, audit.ActionLocationAssign, audit.ResourceLocation, strconv.Itoa(locationID),
+		map[string]interface{}{"sensor_ids": req.SensorIDs, "sensors_updated": assignment.UpdatedCount})
+
+	response.Success(w, "Sensors assigned to location successfully", assignment)
+}
+
+// ListLocations handles listing locations
+func (h *Handler) ListLocations(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "geojson" {
+		response.BadRequest(w, "format must be geojson", nil)
+		return
+	}
+
+	locations, err := h.scopedService(r).ListLocations(r.Context())
+	if err != nil {
+		response.InternalServerError(w, "Failed to list locations", err)
+		return
+	}
+
+	// GeoJSON is sent bare so maps can load the response as is
+	if format == "geojson" {
+		response.JSON(w, http.StatusOK, NewLocationFeatureCollection(locations))
+		return
+	}
+
+	response.Success(w, "Locations retrieved successfully", locations)
+}
+
+// ListLocationsNear handles listing the locations within radius_km of the
+// lat/lng point, nearest first
+func (h *Handler) ListLocationsNear(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	lat, err := strconv.ParseFloat(params.Get("lat"), 64)
+	if err != nil {
+		response.BadRequest(w, "lat parameter is required", err)
+		return
+	}
+
+	lng, err := strconv.ParseFloat(params.Get("lng"), 64)
+	if err != nil {
+		response.BadRequest(w, "lng parameter is required", err)
+		return
+	}
+
+	radiusKm := float64(DefaultNearbyRadiusKm)
+	if radiusStr := params.Get("radius_km"); radiusStr != "" {
+		radiusKm, err = strconv.ParseFloat(radiusStr, 64)
+		if err != nil {
+			response.BadRequest(w, "Invalid radius_km", err)
+			return
+		}
+	}
+
+	nearby, err := h.scopedService(r).ListLocationsNear(r.Context(), lat, lng, radiusKm)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidCoordinates), errors.Is(err, ErrInvalidRadius):
+			response.BadRequest(w, "Invalid nearby query", err)
+		default:
+			response.InternalServerError(w, "Failed to list nearby locations", err)
+		}
+		return
+	}
+
+	response.Success(w, "Nearby locations retrieved successfully", nearby)
+}
+
+// GetLocationSummary handles getting location summary with sensors
+func (h *Handler) GetLocationSummary(w http.ResponseWriter, r *http.Request) {
+	locationIDStr := r.URL.Query().Get("location_id")
+	if locationIDStr == "" {
+		response.BadRequest(w, "location_id parameter is required", nil)
+		return
+	}
+
+	locationID, err := strconv.Atoi(locationIDStr)
+	if err != nil {
+		response.BadRequest(w, "Invalid location ID", err)
+		return
+	}
+
+	summary, err := h.scopedService(r).GetLocationSummary(r.Context(), locationID)
+	if err != nil {
+		if errors.Is(err, ErrLocationNotFound) {
+			response.NotFound(w, "Location not found")
+		} else {
+			response.InternalServerError(w, "Failed to get location summary", err)
+		}
+		return
+	}
+
+	response.Success(w, "Location summary retrieved successfully", summary)
+}
+
+// GetDashboard handles getting sensor dashboard data
+func (h *Handler) GetDashboard(w http.ResponseWriter, r *http.Request) {
+	dashboard, cacheStatus, err := h.scopedService(r).GetSensorsDashboard(r.Context())
+	if err != nil {
+		response.InternalServerError(w, "Failed to get dashboard data", err)
+		return
+	}
+
+	setCacheHeaders(w, cacheStatus)
+
+	response.Success(w, "Dashboard data retrieved successfully", dashboard)
+}
+
+// GetSensorHealth handles getting sensor health status
+func (h *Handler) GetSensorHealth(w http.ResponseWriter, r *http.Request) {
+	// Parse query parameters
+	page := 1
+	perPage := 20
+
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if perPageStr := r.URL.Query().Get("per_page"); perPageStr != "" {
+		if pp, err := strconv.Atoi(perPageStr); err == nil && pp > 0 && pp <= 100 {
+			perPage = pp
+		}
+	}
+
+	filter, err := parseSensorHealthFilter(r)
+	if err != nil {
+		response.BadRequest(w, "Invalid query parameters", err)
+		return
+	}
+
+	report, total, cacheStatus, err := h.scopedService(r).GetSensorHealth(r.Context(), filter, page, perPage)
+	if err != nil {
+		response.InternalServerError(w, "Failed to get sensor health data", err)
+		return
+	}
+
+	// Calculate pagination meta
+	totalPages := (total + perPage - 1) / perPage
+	meta := &response.Meta{
+		Page:       page,
+		PerPage:    perPage,
+		Total:      total,
+		TotalPages: totalPages,
+	}
+
+	setCacheHeaders(w, cacheStatus)
+	response.PaginatedSuccess(w, "Sensor health data retrieved successfully", report, meta)
+}
+
+// parseSensorHealthFilter builds the sensor health filter from query
+// parameters; newest sensors come first unless sort is health_score, which
+// sorts the lowest scores first unless order is desc
+func parseSensorHealthFilter(r *http.Request) (*SensorHealthFilter, error) {
+	params := r.URL.Query()
+	filter := &SensorHealthFilter{
+		Issue: strings.TrimSpace(params.Get("issue")),
+	}
+
+	var err error
+	if filter.MinScore, err = parseHealthScore(params.Get("min_score"), "min_score"); err != nil {
+		return nil, err
+	}
+	if filter.MaxScore, err = parseHealthScore(params.Get("max_score"), "max_score"); err != nil {
+		return nil, err
+	}
+	if filter.MinScore != nil && filter.MaxScore != nil && *filter.MinScore > *filter.MaxScore {
+		return nil, errors.New("min_score must not exceed max_score")
+	}
+
+	if locationStr := params.Get("location_id"); locationStr != "" {
+		locationID, err := strconv.Atoi(locationStr)
+		if err != nil || locationID <= 0 {
+			return nil, errors.New("location_id must be a positive integer")
+		}
+		filter.LocationID = &locationID
+	}
+
+	switch params.Get("sort") {
+	case "":
+	case "health_score":
+		filter.SortByScore = true
+	default:
+		return nil, errors.New("sort must be health_score")
+	}
+
+	switch params.Get("order") {
+	case "", "asc":
+	case "desc":
+		filter.SortDesc = true
+	default:
+		return nil, errors.New("order must be asc or desc")
+	}
+
+	return filter, nil
+}
+
+// setCacheHeaders tells clients how long a cached result may be reused and
+// whether it was served from the cache
+func setCacheHeaders(w http.ResponseWriter, status CacheStatus) {
+	if maxAge := int(status.MaxAge.Seconds()); maxAge > 0 {
+		w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", maxAge))
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+
+	if status.Hit {
+		w.Header().Set("X-Cache", "HIT")
+	} else {
+		w.Header().Set("X-Cache", "MISS")
+	}
+}
+
+// GetSensorStatistics handles getting sensor statistics
+func (h *Handler) GetSensorStatistics(w http.ResponseWriter, r *http.Request) {
+	sensorIDStr := r.URL.Query().Get("sensor_id")
+	if sensorIDStr == "" {
+		response.BadRequest(w, "sensor_id parameter is required", nil)
+		return
+	}
+
+	sensorID, err := strconv.Atoi(sensorIDStr)
+	if err != nil {
+		response.BadRequest(w, "Invalid sensor ID", err)
+		return
+	}
+
+	startTimeStr := r.URL.Query().Get("start_time")
+	endTimeStr := r.URL.Query().Get("end_time")
+
+	if startTimeStr == "" || endTimeStr == "" {
+		response.BadRequest(w, "start_time and end_time parameters are required", nil)
+		return
+	}
+
+	startTime, err := time.Parse(time.RFC3339, startTimeStr)
+	if err != nil {
+		response.BadRequest(w, "Invalid start_time format, use RFC3339", err)
+		return
+	}
+
+	endTime, err := time.Parse(time.RFC3339, endTimeStr)
+	if err != nil {
+		response.BadRequest(w, "Invalid end_time format, use RFC3339", err)
+		return
+	}
+
+	comparePrevious := false
+	switch r.URL.Query().Get("compare") {
+	case "":
+	case "previous_period":
+		comparePrevious = true
+	default:
+		response.BadRequest(w, "compare must be previous_period", nil)
+		return
+	}
+
+	var conversion *UnitConversion
+	if r.URL.Query().Get("unit") != "" {
+		var ok bool
+		if conversion, ok = h.unitConversion(w, r, sensorID); !ok {
+			return
+		}
+	}
+
+	stats, err := h.scopedService(r).GetSensorStatistics(r.Context(), sensorID, startTime, endTime, comparePrevious)
+	if err != nil {
+		if errors.Is(err, ErrSensorNotFound) {
+			response.NotFound(w, "Sensor not found")
+		} else {
+			response.InternalServerError(w, "Failed to get sensor statistics", err)
+		}
+		return
+	}
+
+	if conversion != nil {
+		conversion.ApplyToStatistics(stats)
+	}
+
+	response.Success(w, "Sensor statistics retrieved successfully", stats)
+}
+
+// GetReadingGaps handles listing the gaps between consecutive readings of a
+// sensor longer than min_gap. The range defaults to the last 7 days and
+// min_gap to the sensor's online threshold.
+func (h *Handler) GetReadingGaps(w http.ResponseWriter, r *http.Request) {
+	sensorID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid sensor ID", err)
+		return
+	}
+
+	params := r.URL.Query()
+	endTime := time.Now().UTC()
+	startTime := endTime.Add(-7 * 24 * time.Hour)
+
+	if startTimeStr := params.Get("start_time"); startTimeStr != "" {
+		startTime, err = time.Parse(time.RFC3339, startTimeStr)
+		if err != nil {
+			response.BadRequest(w, "Invalid start_time format, use RFC3339", err)
+			return
+		}
+		startTime = startTime.UTC()
+	}
+
+	if endTimeStr := params.Get("end_time"); endTimeStr != "" {
+		endTime, err = time.Parse(time.RFC3339, endTimeStr)
+		if err != nil {
+			response.BadRequest(w, "Invalid end_time format, use RFC3339", err)
+			return
+		}
+		endTime = endTime.UTC()
+	}
+
+	var minGap time.Duration
+	if minGapStr := params.Get("min_gap"); minGapStr != "" {
+		minGap, err = time.ParseDuration(minGapStr)
+		if err != nil || minGap <= 0 {
+			response.BadRequest(w, "Invalid min_gap", ErrInvalidMinGap)
+			return
+		}
+	}
+
+	report, err := h.scopedService(r).GetReadingGaps(r.Context(), sensorID, startTime, endTime, minGap)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidTimeRange), errors.Is(err, ErrGapRangeTooBig), errors.Is(err, ErrInvalidMinGap):
+			response.BadRequest(w, "Invalid gap report query", err)
+		case errors.Is(err, ErrSensorNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Sensor not found", err)
+		default:
+			response.InternalServerError(w, "Failed to get sensor reading gaps", err)
+		}
+		return
+	}
+
+	response.Success(w, "Sensor reading gaps retrieved successfully", report)
+}
+
+// DetectAnomalies handles finding the readings of a sensor whose value is more
+// than sigma standard deviations from the mean of the time range. The range
+// defaults to the last 7 days and sigma to 3.
+func (h *Handler) DetectAnomalies(w http.ResponseWriter, r *http.Request) {
+	sensorID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid sensor ID", err)
+		return
+	}
+
+	params := r.URL.Query()
+	endTime := time.Now().UTC()
+	startTime := endTime.Add(-7 * 24 * time.Hour)
+
+	if startTimeStr := params.Get("start_time"); startTimeStr != "" {
+		startTime, err = time.Parse(time.RFC3339, startTimeStr)
+		if err != nil {
+			response.BadRequest(w, "Invalid start_time format, use RFC3339", err)
+			return
+		}
+		startTime = startTime.UTC()
+	}
+
+	if endTimeStr := params.Get("end_time"); endTimeStr != "" {
+		endTime, err = time.Parse(time.RFC3339, endTimeStr)
+		if err != nil {
+			response.BadRequest(w, "Invalid end_time format, use RFC3339", err)
+			return
+		}
+		endTime = endTime.UTC()
+	}
+
+	sigma := float64(DefaultAnomalySigma)
+	if sigmaStr := params.Get("sigma"); sigmaStr != "" {
+		sigma, err = strconv.ParseFloat(sigmaStr, 64)
+		if err != nil {
+			response.BadRequest(w, "Invalid sigma", err)
+			return
+		}
+	}
+
+	report, err := h.scopedService(r).DetectAnomalies(r.Context(), sensorID, startTime, endTime, sigma)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidTimeRange), errors.Is(err, ErrInvalidSigma):
+			response.BadRequest(w, "Invalid anomaly query", err)
+		case errors.Is(err, ErrTooFewReadings), errors.Is(err, ErrZeroVariance):
+			// The statistics are still returned to explain the outcome
+			response.ErrorWithData(w, http.StatusUnprocessableEntity, "Anomalies cannot be detected for this time range", err, report)
+		case errors.Is(err, ErrSensorNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Sensor not found", err)
+		default:
+			response.InternalServerError(w, "Failed to detect sensor anomalies", err)
+		}
+		return
+	}
+
+	response.Success(w, "Sensor anomalies detected successfully", report)
+}
+
+// GetDailyStatistics handles getting a sensor's statistics per calendar day
+// in the tz time zone. The range defaults to the last 30 days and tz to UTC.
+func (h *Handler) GetDailyStatistics(w http.ResponseWriter, r *http.Request) {
+	sensorID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid sensor ID", err)
+		return
+	}
+
+	params := r.URL.Query()
+	endTime := time.Now().UTC()
+	startTime := endTime.Add(-30 * 24 * time.Hour)
+
+	// start and end are accepted as short forms of start_time and end_time
+	startTimeStr := params.Get("start")
+	if startTimeStr == "" {
+		startTimeStr = params.Get("start_time")
+	}
+	if startTimeStr != "" {
+		startTime, err = time.Parse(time.RFC3339, startTimeStr)
+		if err != nil {
+			response.BadRequest(w, "Invalid start format, use RFC3339", err)
+			return
+		}
+		startTime = startTime.UTC()
+	}
+
+	endTimeStr := params.Get("end")
+	if endTimeStr == "" {
+		endTimeStr = params.Get("end_time")
+	}
+	if endTimeStr != "" {
+		endTime, err = time.Parse(time.RFC3339, endTimeStr)
+		if err != nil {
+			response.BadRequest(w, "Invalid end format, use RFC3339", err)
+			return
+		}
+		endTime = endTime.UTC()
+	}
+
+	timezone := "UTC"
+	if tz := params.Get("tz"); tz != "" {
+		timezone = tz
+	}
+
+	days, err := h.scopedService(r).GetDailyStatistics(r.Context(), sensorID, startTime, endTime, timezone)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidTimeRange), errors.Is(err, ErrDailyRangeTooBig),
+			errors.Is(err, ErrInvalidTimezone):
+			response.BadRequest(w, "Invalid daily statistics query", err)
+		case errors.Is(err, ErrSensorNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Sensor not found", err)
+		default:
+			response.InternalServerError(w, "Failed to get daily sensor statistics", err)
+		}
+		return
+	}
+
+	response.Success(w, "Daily sensor statistics retrieved successfully", days)
+}
+
+// CreateSensorAccess handles granting a user or role access to a sensor or location
+func (h *Handler) CreateSensorAccess(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		response.Unauthorized(w, "User not found in context")
+		return
+	}
+
+	var req CreateSensorAccessRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	access, err := h.scopedService(r).CreateSensorAccess(r.Context(), &req, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidAccessGrant), errors.Is(err, ErrInvalidAccessLevel):
+			response.BadRequest(w, "Validation failed", err)
+		case errors.Is(err, ErrSensorNotFound), errors.Is(err, ErrLocationNotFound), errors.Is(err, ErrGranteeNotFound):
+			response.ErrorFor(w, http.StatusNotFound, err.Error(), err)
+		case errors.Is(err, ErrAccessExists):
+			response.Conflict(w, "Sensor access grant already exists", err)
+		default:
+			response.InternalServerError(w, "Failed to grant sensor access", err)
+		}
+		return
+	}
+
+	h.audit.Record(r, audit.ActionSensorAccessGrant, audit.ResourceSensorAccess, strconv.Itoa(access.ID), req)
+
+	response.Created(w, "Sensor access granted successfully", access)
+}
+
+// ListSensorAccess handles listing the access grants on a sensor or location
+func (h *Handler) ListSensorAccess(w http.ResponseWriter, r *http.Request) {
+	var sensorID, locationID *int
+
+	if sensorIDStr := r.URL.Query().Get("sensor_id"); sensorIDStr != "" {
+		id, err := strconv.Atoi(sensorIDStr)
+		if err != nil {
+			response.BadRequest(w, "Invalid sensor ID", err)
+			return
+		}
+		sensorID = &id
+	}
+
+	if locationIDStr := r.URL.Query().Get("location_id"); locationIDStr != "" {
+		id, err := strconv.Atoi(locationIDStr)
+		if err != nil {
+			response.BadRequest(w, "Invalid location ID", err)
+			return
+		}
+		locationID = &id
+	}
+
+	grants, err := h.scopedService(r).ListSensorAccess(r.Context(), sensorID, locationID)
+	if err != nil {
+		response.InternalServerError(w, "Failed to list sensor access", err)
+		return
+	}
+
+	response.Success(w, "Sensor access retrieved successfully", grants)
+}
+
+// DeleteSensorAccess handles revoking a sensor access grant
+func (h *Handler) DeleteSensorAccess(w http.ResponseWriter, r *http.Request) {
+	accessID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid sensor access ID", err)
+		return
+	}
+
+	if err := h.scopedService(r).DeleteSensorAccess(r.Context(), accessID); err != nil {
+		switch {
+		case errors.Is(err, ErrAccessNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Sensor access grant not found", err)
+		default:
+			response.InternalServerError(w, "Failed to revoke sensor access", err)
+		}
+		return
+	}
+
+	h.audit.Record(r, audit.ActionSensorAccessRevoke, audit.ResourceSensorAccess, strconv.Itoa(accessID), nil)
+
+	response.Success(w, "Sensor access revoked successfully", nil)
+}
+
+// CreateSensorGroup handles sensor group creation
+func (h *Handler) CreateSensorGroup(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		response.Unauthorized(w, "User not found in context")
+		return
+	}
+
+	var req CreateSensorGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		response.BadRequest(w, "Validation failed", err)
+		return
+	}
+
+	group, err := h.scopedService(r).CreateSensorGroup(r.Context(), &req, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrGroupExists):
+			response.Conflict(w, "Sensor group already exists", err)
+		default:
+			response.InternalServerError(w, "Failed to create sensor group", err)
+		}
+		return
+	}
+
+	h.audit.Record(r, audit.ActionSensorGroupCreate, audit.ResourceSensorGroup, strconv.Itoa(group.ID), req)
+
+	response.Created(w, "Sensor group created successfully", group)
+}
+
+// GetSensorGroup handles getting sensor group by ID
+func (h *Handler) GetSensorGroup(w http.ResponseWriter, r *http.Request) {
+	groupID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid sensor group ID", err)
+		return
+	}
+
+	group, err := h.scopedService(r).GetSensorGroup(r.Context(), groupID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrGroupNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Sensor group not found", err)
+		default:
+			response.InternalServerError(w, "Failed to get sensor group", err)
+		}
+		return
+	}
+
+	response.Success(w, "Sensor group retrieved successfully", group)
+}
+
+// ListSensorGroups handles listing sensor groups
+func (h *Handler) ListSensorGroups(w http.ResponseWriter, r *http.Request) {
+	groups, err := h.scopedService(r).ListSensorGroups(r.Context())
+	if err != nil {
+		response.InternalServerError(w, "Failed to list sensor groups", err)
+		return
+	}
+
+	response.Success(w, "Sensor groups retrieved successfully", groups)
+}
+
+// UpdateSensorGroup handles sensor group updates
+func (h *Handler) UpdateSensorGroup(w http.ResponseWriter, r *http.Request) {
+	groupID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid sensor group ID", err)
+		return
+	}
+
+	var req UpdateSensorGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
 		return
 	}
 
-	response.Success(w, "Location updated successfully", location)
+	if err := req.Validate(); err != nil {
+		response.BadRequest(w, "Validation failed", err)
+		return
+	}
+
+	group, err := h.scopedService(r).UpdateSensorGroup(r.Context(), groupID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrGroupNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Sensor group not found", err)
+		case errors.Is(err, ErrGroupExists):
+			response.Conflict(w, "Sensor group already exists", err)
+		default:
+			response.InternalServerError(w, "Failed to update sensor group", err)
+		}
+		return
+	}
+
+	h.audit.Record(r, audit.ActionSensorGroupUpdate, audit.ResourceSensorGroup, strconv.Itoa(groupID), req)
+
+	response.Success(w, "Sensor group updated successfully", group)
 }
 
-// ListLocations handles listing locations
-func (h *Handler) ListLocations(w http.ResponseWriter, r *http.Request) {
-	locations, err := h.service.ListLocations()
+// DeleteSensorGroup handles sensor group deletion
+func (h *Handler) DeleteSensorGroup(w http.ResponseWriter, r *http.Request) {
+	groupID, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		response.InternalServerError(w, "Failed to list locations", err)
+		response.BadRequest(w, "Invalid sensor group ID", err)
 		return
 	}
 
-	response.Success(w, "Locations retrieved successfully", locations)
+	if err := h.scopedService(r).DeleteSensorGroup(r.Context(), groupID); err != nil {
+		switch {
+		case errors.Is(err, ErrGroupNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Sensor group not found", err)
+		default:
+			response.InternalServerError(w, "Failed to delete sensor group", err)
+		}
+		return
+	}
+
+	h.audit.Record(r, audit.ActionSensorGroupDelete, audit.ResourceSensorGroup, strconv.Itoa(groupID), nil)
+
+	response.Success(w, "Sensor group deleted successfully", nil)
 }
 
-// GetLocationSummary handles getting location summary with sensors
-func (h *Handler) GetLocationSummary(w http.ResponseWriter, r *http.Request) {
-	locationIDStr := r.URL.Query().Get("location_id")
-	if locationIDStr == "" {
-		response.BadRequest(w, "location_id parameter is required", nil)
+// UpdateGroupMembers handles adding sensors to and removing sensors from
+// a group
+func (h *Handler) UpdateGroupMembers(w http.ResponseWriter, r *http.Request) {
+	groupID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid sensor group ID", err)
 		return
 	}
 
-	locationID, err := strconv.Atoi(locationIDStr)
+	var req UpdateGroupMembersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	group, err := h.scopedService(r).UpdateGroupMembers(r.Context(), groupID, &req)
 	if err != nil {
-		response.BadRequest(w, "Invalid location ID", err)
+		switch {
+		case errors.Is(err, ErrNoMemberChanges):
+			response.BadRequest(w, "Validation failed", err)
+		case errors.Is(err, ErrGroupNotFound), errors.Is(err, ErrSensorNotFound):
+			response.ErrorFor(w, http.StatusNotFound, err.Error(), err)
+		default:
+			response.InternalServerError(w, "Failed to update sensor group members", err)
+		}
 		return
 	}
 
-	summary, err := h.service.GetLocationSummary(locationID)
+	h.audit.Record(r, audit.ActionSensorGroupMembers, audit.ResourceSensorGroup, strconv.Itoa(groupID), req)
+
+	response.Success(w, "Sensor group members updated successfully", group)
+}
+
+// GetGroupSummary handles getting sensor group summary
+func (h *Handler) GetGroupSummary(w http.ResponseWriter, r *http.Request) {
+	groupID, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		if err == ErrLocationNotFound {
-			response.NotFound(w, "Location not found")
-		} else {
-			response.InternalServerError(w, "Failed to get location summary", err)
+		response.BadRequest(w, "Invalid sensor group ID", err)
+		return
+	}
+
+	summary, err := h.scopedService(r).GetGroupSummary(r.Context(), groupID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrGroupNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Sensor group not found", err)
+		default:
+			response.InternalServerError(w, "Failed to get sensor group summary", err)
 		}
 		return
 	}
 
-	response.Success(w, "Location summary retrieved successfully", summary)
+	response.Success(w, "Sensor group summary retrieved successfully", summary)
 }
 
-// GetDashboard handles getting sensor dashboard data
-func (h *Handler) GetDashboard(w http.ResponseWriter, r *http.Request) {
-	dashboard, err := h.service.GetSensorsDashboard()
+// UpdateCalibration handles changing the calibration of a sensor
+func (h *Handler) UpdateCalibration(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		response.Unauthorized(w, "User not found in context")
+		return
+	}
+
+	sensorID, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		response.InternalServerError(w, "Failed to get dashboard data", err)
+		response.BadRequest(w, "Invalid sensor ID", err)
 		return
 	}
 
-	response.Success(w, "Dashboard data retrieved successfully", dashboard)
+	var req UpdateCalibrationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	calibration, err := h.scopedService(r).UpdateCalibration(r.Context(), sensorID, &req, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrNoCalibrationChanges), errors.Is(err, ErrInvalidCalibration):
+			response.BadRequest(w, "Validation failed", err)
+		case errors.Is(err, ErrSensorNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Sensor not found", err)
+		case errors.Is(err, ErrSensorAccessDenied):
+			response.ErrorFor(w, http.StatusForbidden, "Write access to this sensor is required", err)
+		default:
+			response.InternalServerError(w, "Failed to update sensor calibration", err)
+		}
+		return
+	}
+
+	h.audit.Record(r, audit.ActionSensorCalibrate, audit.ResourceSensor, strconv.Itoa(sensorID), map[string]interface{}{
+		"calibration_offset": calibration.Offset,
+		"calibration_scale":  calibration.Scale,
+		"previous_offset":    calibration.PreviousOffset,
+		"previous_scale":     calibration.PreviousScale,
+	})
+
+	response.Success(w, "Sensor calibration updated successfully", calibration)
 }
 
-// GetSensorHealth handles getting sensor health status
-func (h *Handler) GetSensorHealth(w http.ResponseWriter, r *http.Request) {
-	healthStatuses, err := h.service.GetSensorHealth()
+// SetMaintenance handles putting a sensor in maintenance or ending it
+func (h *Handler) SetMaintenance(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		response.Unauthorized(w, "User not found in context")
+		return
+	}
+
+	sensorID, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		response.InternalServerError(w, "Failed to get sensor health data", err)
+		response.BadRequest(w, "Invalid sensor ID", err)
+		return
+	}
+
+	var req SetMaintenanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	sensor, err := h.scopedService(r).SetMaintenance(r.Context(), sensorID, &req, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidMaintenance), errors.Is(err, ErrInvalidReason):
+			response.BadRequest(w, "Validation failed", err)
+		case errors.Is(err, ErrSensorNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Sensor not found", err)
+		case errors.Is(err, ErrSensorAccessDenied):
+			response.ErrorFor(w, http.StatusForbidden, "Write access to this sensor is required", err)
+		default:
+			response.InternalServerError(w, "Failed to update sensor maintenance", err)
+		}
 		return
 	}
 
-	response.Success(w, "Sensor health data retrieved successfully", healthStatuses)
+	h.audit.Record(r, audit.ActionSensorMaintenance, audit.ResourceSensor, strconv.Itoa(sensorID), req)
+
+	response.Success(w, "Sensor maintenance updated successfully", sensor)
 }
 
-// GetSensorStatistics handles getting sensor statistics
-func (h *Handler) GetSensorStatistics(w http.ResponseWriter, r *http.Request) {
-	sensorIDStr := r.URL.Query().Get("sensor_id")
-	if sensorIDStr == "" {
-		response.BadRequest(w, "sensor_id parameter is required", nil)
+// CreateSensorNote handles leaving a note on a sensor
+func (h *Handler) CreateSensorNote(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		response.Unauthorized(w, "User not found in context")
 		return
 	}
 
-	sensorID, err := strconv.Atoi(sensorIDStr)
+	sensorID, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
 		response.BadRequest(w, "Invalid sensor ID", err)
 		return
 	}
 
-	startTimeStr := r.URL.Query().Get("start_time")
-	endTimeStr := r.URL.Query().Get("end_time")
+	var req CreateSensorNoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
 
-	if startTimeStr == "" || endTimeStr == "" {
-		response.BadRequest(w, "start_time and end_time parameters are required", nil)
+	note, err := h.scopedService(r).CreateSensorNote(r.Context(), sensorID, &req, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidNote):
+			response.BadRequest(w, "Validation failed", err)
+		case errors.Is(err, ErrSensorNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Sensor not found", err)
+		default:
+			response.InternalServerError(w, "Failed to create sensor note", err)
+		}
 		return
 	}
 
-	startTime, err := time.Parse(time.RFC3339, startTimeStr)
+	response.Created(w, "Sensor note created successfully", note)
+}
+
+// ListSensorNotes handles listing the notes of a sensor
+func (h *Handler) ListSensorNotes(w http.ResponseWriter, r *http.Request) {
+	sensorID, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		response.BadRequest(w, "Invalid start_time format, use RFC3339", err)
+		response.BadRequest(w, "Invalid sensor ID", err)
 		return
 	}
 
-	endTime, err := time.Parse(time.RFC3339, endTimeStr)
+	page := 1
+	perPage := 20
+
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if perPageStr := r.URL.Query().Get("per_page"); perPageStr != "" {
+		if pp, err := strconv.Atoi(perPageStr); err == nil && pp > 0 && pp <= 100 {
+			perPage = pp
+		}
+	}
+
+	notes, total, err := h.scopedService(r).ListSensorNotes(r.Context(), sensorID, page, perPage)
 	if err != nil {
-		response.BadRequest(w, "Invalid end_time format, use RFC3339", err)
+		switch {
+		case errors.Is(err, ErrSensorNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Sensor not found", err)
+		default:
+			response.InternalServerError(w, "Failed to list sensor notes", err)
+		}
+		return
+	}
+
+	meta := &response.Meta{
+		Page:       page,
+		PerPage:    perPage,
+		Total:      total,
+		TotalPages: (total + perPage - 1) / perPage,
+	}
+
+	response.PaginatedSuccess(w, "Sensor notes retrieved successfully", notes, meta)
+}
+
+// DeleteSensorNote handles deleting a note of a sensor
+func (h *Handler) DeleteSensorNote(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		response.Unauthorized(w, "User not found in context")
 		return
 	}
 
-	stats, err := h.service.GetSensorStatistics(sensorID, startTime, endTime)
+	sensorID, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		if err == ErrSensorNotFound {
-			response.NotFound(w, "Sensor not found")
-		} else {
-			response.InternalServerError(w, "Failed to get sensor statistics", err)
+		response.BadRequest(w, "Invalid sensor ID", err)
+		return
+	}
+
+	noteID, err := strconv.ParseInt(r.PathValue("note_id"), 10, 64)
+	if err != nil {
+		response.BadRequest(w, "Invalid note ID", err)
+		return
+	}
+
+	if err := h.scopedService(r).DeleteSensorNote(r.Context(), sensorID, noteID, user.ID, user.IsAdmin()); err != nil {
+		switch {
+		case errors.Is(err, ErrSensorNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Sensor not found", err)
+		case errors.Is(err, ErrNoteNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Note not found", err)
+		case errors.Is(err, ErrNoteNotAuthor):
+			response.ErrorFor(w, http.StatusForbidden, err.Error(), err)
+		default:
+			response.InternalServerError(w, "Failed to delete sensor note", err)
 		}
 		return
 	}
 
-	response.Success(w, "Sensor statistics retrieved successfully", stats)
+	h.audit.Record(r, audit.ActionSensorNoteDelete, audit.ResourceSensor, strconv.Itoa(sensorID),
+		map[string]interface{}{"note_id": noteID})
+
+	response.Success(w, "Sensor note deleted successfully", nil)
+}
+
+// SendCommand handles publishing a command to a sensor's device
+func (h *Handler) SendCommand(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		response.Unauthorized(w, "User not found in context")
+		return
+	}
+
+	sensorID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid sensor ID", err)
+		return
+	}
+
+	var req SendCommandRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	command, err := h.scopedService(r).SendCommand(r.Context(), sensorID, &req, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidCommandType), errors.Is(err, ErrCommandTooLarge):
+			response.BadRequest(w, "Validation failed", err)
+		case errors.Is(err, ErrSensorNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Sensor not found", err)
+		case errors.Is(err, ErrSensorAccessDenied):
+			response.ErrorFor(w, http.StatusForbidden, "Write access to this sensor is required", err)
+		case errors.Is(err, ErrCommandsUnavailable):
+			response.Error(w, http.StatusServiceUnavailable, "Device commands are unavailable while MQTT is not connected", err)
+		default:
+			response.InternalServerError(w, "Failed to send command", err)
+		}
+		return
+	}
+
+	h.audit.Record(r, audit.ActionSensorCommand, audit.ResourceSensor, strconv.Itoa(sensorID), map[string]interface{}{
+		"command_id":   command.ID,
+		"command_type": command.CommandType,
+	})
+
+	response.Created(w, "Command sent successfully", command)
+}
+
+// ListSensorCommands handles listing the commands sent to a sensor
+func (h *Handler) ListSensorCommands(w http.ResponseWriter, r *http.Request) {
+	sensorID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid sensor ID", err)
+		return
+	}
+
+	page := 1
+	perPage := 20
+
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if perPageStr := r.URL.Query().Get("per_page"); perPageStr != "" {
+		if pp, err := strconv.Atoi(perPageStr); err == nil && pp > 0 && pp <= 100 {
+			perPage = pp
+		}
+	}
+
+	commands, total, err := h.scopedService(r).ListSensorCommands(r.Context(), sensorID, page, perPage)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrSensorNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Sensor not found", err)
+		default:
+			response.InternalServerError(w, "Failed to list sensor commands", err)
+		}
+		return
+	}
+
+	meta := &response.Meta{
+		Page:       page,
+		PerPage:    perPage,
+		Total:      total,
+		TotalPages: (total + perPage - 1) / perPage,
+	}
+
+	response.PaginatedSuccess(w, "Sensor commands retrieved successfully", commands, meta)
+}
+
+// ListCalibrations handles listing the calibration history of a sensor
+func (h *Handler) ListCalibrations(w http.ResponseWriter, r *http.Request) {
+	sensorID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid sensor ID", err)
+		return
+	}
+
+	calibrations, err := h.scopedService(r).ListCalibrations(r.Context(), sensorID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrSensorNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Sensor not found", err)
+		default:
+			response.InternalServerError(w, "Failed to list sensor calibrations", err)
+		}
+		return
+	}
+
+	response.Success(w, "Sensor calibrations retrieved successfully", calibrations)
 }
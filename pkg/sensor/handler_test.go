@@ -0,0 +1,85 @@
+package sensor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"user-management/database/dbtest"
+	"user-management/shared/interfaces"
+	"user-management/shared/middleware"
+)
+
+// stubAuth authenticates every Bearer token as user and grants every
+// permission
+type stubAuth struct {
+	user *interfaces.User
+}
+
+func (a stubAuth) GetUserFromToken(ctx context.Context, tokenString string) (*interfaces.User, error) {
+	return a.user, nil
+}
+
+func (a stubAuth) GetUserFromAPIKey(ctx context.Context, key string) (*interfaces.User, error) {
+	return nil, errors.New("api keys are not supported")
+}
+
+func (a stubAuth) HasPermission(ctx context.Context, userID int, resource, action string) (bool, error) {
+	return true, nil
+}
+
+// superAdmin is a user of the default organization who can access every
+// sensor
+var superAdmin = &interfaces.User{
+	ID:             1,
+	OrganizationID: interfaces.DefaultOrganizationID,
+	IsActive:       true,
+	Roles:          []interfaces.Role{{Name: interfaces.SuperAdminRole, IsActive: true}},
+}
+
+// newTestRouter serves the sensor routes of service to requests
+// authenticated as user
+func newTestRouter(service Service, user *interfaces.User) http.Handler {
+	mux := http.NewServeMux()
+	NewHandler(service, middleware.NewAuthMiddleware(stubAuth{user: user}), nil).RegisterRoutes(mux)
+	return mux
+}
+
+// serve sends a request with a Bearer token to router and returns the
+// recorded response
+func serve(router http.Handler, method, target string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	req.Header.Set("Authorization", "Bearer test")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+// TestGetSensorByDeviceIDRouteIgnoresCase resolves device IDs in URLs of
+// any case to the same sensor
+func TestGetSensorByDeviceIDRouteIgnoresCase(t *testing.T) {
+	db := dbtest.Migrated(t)
+	repo := NewRepository(db)
+	stored := newTestSensor(t, db, repo, "URL-001")
+	router := newTestRouter(NewService(repo, Config{}), superAdmin)
+
+	for _, deviceID := range []string{"URL-001", "url-001", "Url-001"} {
+		rec := serve(router, http.MethodGet, "/api/sensors/device/"+deviceID)
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: got status %d: %s", deviceID, rec.Code, rec.Body)
+			continue
+		}
+
+		var body struct {
+			Data Sensor `json:"data"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Data.ID != stored.ID {
+			t.Errorf("%s: got sensor %d, want %d", deviceID, body.Data.ID, stored.ID)
+		}
+	}
+}
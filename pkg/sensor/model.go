@@ -1,26 +1,56 @@
 package sensor
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
+	"user-management/shared/interfaces"
+	"user-management/shared/response"
 )
 
+// DeviceTokenHeader carries the device token on the public readings endpoints
+const DeviceTokenHeader = "X-Device-Token"
+
 // Sensor represents an IoT sensor device
 type Sensor struct {
-	ID              int            `json:"id"`
-	DeviceID        string         `json:"device_id"`
-	Name            string         `json:"name"`
-	Description     string         `json:"description"`
-	SensorTypeID    int            `json:"sensor_type_id"`
-	LocationID      *int           `json:"location_id,omitempty"`
-	IsActive        bool           `json:"is_active"`
-	LastReadingAt   *time.Time     `json:"last_reading_at,omitempty"`
-	BatteryLevel    *int           `json:"battery_level,omitempty"`
-	FirmwareVersion string         `json:"firmware_version"`
+	ID             int    `json:"id"`
+	DeviceID       string `json:"device_id"`
+	Name           string `json:"name"`
+	Description    string `json:"description"`
+	SensorTypeID   int    `json:"sensor_type_id"`
+	LocationID     *int   `json:"location_id,omitempty"`
+	OrganizationID int    `json:"organization_id"`
+	IsActive       bool   `json:"is_active"`
+	// IsProvisioned is false for sensors auto-provisioned from an unknown
+	// device until an admin approves them
+	IsProvisioned   bool       `json:"is_provisioned"`
+	LastReadingAt   *time.Time `json:"last_reading_at,omitempty"`
+	BatteryLevel    *int       `json:"battery_level,omitempty"`
+	FirmwareVersion string     `json:"firmware_version"`
+	// ExpectedIntervalSeconds is how often the sensor reports; nil uses the
+	// configured online threshold
+	ExpectedIntervalSeconds *int `json:"expected_interval_seconds,omitempty"`
+	// IngestRatePerMinute overrides the configured ingest rate limit
+	IngestRatePerMinute *int            `json:"ingest_rate_per_minute,omitempty"`
+	Tags                []string        `json:"tags"`
+	Metadata            json.RawMessage `json:"metadata"`
+	// Readings are stored as raw*CalibrationScale + CalibrationOffset
+	CalibrationOffset float64 `json:"calibration_offset"`
+	CalibrationScale  float64 `json:"calibration_scale"`
+	// MaintenanceUntil is when the sensor's maintenance window ends; sensors
+	// in maintenance are not reported offline or unhealthy
+	MaintenanceUntil *time.Time `json:"maintenance_until,omitempty"`
+	// DeviceToken is only set when the token is issued or rotated
+	DeviceToken     string         `json:"device_token,omitempty"`
+	DeviceTokenHash string         `json:"-"`
 	CreatedBy       int            `json:"created_by"`
 	CreatedAt       time.Time      `json:"created_at"`
 	UpdatedAt       time.Time      `json:"updated_at"`
@@ -31,28 +61,115 @@ type Sensor struct {
 
 // SensorType represents a type of sensor
 type SensorType struct {
-	ID          int       `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Unit        string    `json:"unit"`
-	MinValue    *float64  `json:"min_value,omitempty"`
-	MaxValue    *float64  `json:"max_value,omitempty"`
-	IsActive    bool      `json:"is_active"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          int      `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Unit        string   `json:"unit"`
+	MinValue    *float64 `json:"min_value,omitempty"`
+	MaxValue    *float64 `json:"max_value,omitempty"`
+	// RetentionDays overrides the default reading retention when set
+	RetentionDays *int `json:"retention_days,omitempty"`
+	// DecimalPlaces is the precision FormatValue formats values with
+	DecimalPlaces int `json:"decimal_places"`
+	// ValueLabels replaces values with text, such as
+	// {"0": "No motion", "1": "Motion detected"}. A value takes the label of
+	// the lowest key at or above it, or of the highest key above them all.
+	ValueLabels map[string]string `json:"value_labels,omitempty"`
+	IsActive    bool              `json:"is_active"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
 }
 
 // Location represents a physical location
 type Location struct {
-	ID          int       `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Latitude    *float64  `json:"latitude,omitempty"`
-	Longitude   *float64  `json:"longitude,omitempty"`
-	Address     string    `json:"address"`
-	IsActive    bool      `json:"is_active"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID             int       `json:"id"`
+	Name           string    `json:"name"`
+	Description    string    `json:"description"`
+	Latitude       *float64  `json:"latitude,omitempty"`
+	Longitude      *float64  `json:"longitude,omitempty"`
+	Address        string    `json:"address"`
+	OrganizationID int       `json:"organization_id"`
+	IsActive       bool      `json:"is_active"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Nearby location search limits
+const (
+	// DefaultNearbyRadiusKm is the search radius when the query does not say
+	DefaultNearbyRadiusKm = 5
+	maxNearbyRadiusKm     = 1000
+	// earthRadiusKm is the mean Earth radius used for haversine distances
+	earthRadiusKm = 6371
+)
+
+// NearbyLocation is a location within the search radius of a point
+type NearbyLocation struct {
+	*Location
+	DistanceKm  float64 `json:"distance_km"`
+	SensorCount int     `json:"sensor_count"`
+}
+
+// NearbyLocations lists the locations within the search radius, nearest
+// first; locations without coordinates cannot be placed and are only counted
+type NearbyLocations struct {
+	Locations                 []*NearbyLocation `json:"locations"`
+	SkippedWithoutCoordinates int               `json:"skipped_without_coordinates"`
+}
+
+// GeoJSONFeatureCollection is a GeoJSON FeatureCollection of locations.
+// SkippedWithoutCoordinates is a foreign member counting the locations left
+// out for lack of coordinates.
+type GeoJSONFeatureCollection struct {
+	Type                      string            `json:"type"`
+	Features                  []*GeoJSONFeature `json:"features"`
+	SkippedWithoutCoordinates int               `json:"skipped_without_coordinates"`
+}
+
+// GeoJSONFeature is a GeoJSON Feature with a point geometry
+type GeoJSONFeature struct {
+	Type       string                 `json:"type"`
+	ID         int                    `json:"id"`
+	Geometry   GeoJSONPoint           `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// GeoJSONPoint is a GeoJSON Point; coordinates are longitude, latitude
+type GeoJSONPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+// NewLocationFeatureCollection converts locations to a GeoJSON
+// FeatureCollection, skipping those without coordinates
+func NewLocationFeatureCollection(locations []*Location) *GeoJSONFeatureCollection {
+	collection := &GeoJSONFeatureCollection{
+		Type:     "FeatureCollection",
+		Features: []*GeoJSONFeature{},
+	}
+
+	for _, location := range locations {
+		if location.Latitude == nil || location.Longitude == nil {
+			collection.SkippedWithoutCoordinates++
+			continue
+		}
+
+		collection.Features = append(collection.Features, &GeoJSONFeature{
+			Type: "Feature",
+			ID:   location.ID,
+			Geometry: GeoJSONPoint{
+				Type:        "Point",
+				Coordinates: [2]float64{*location.Longitude, *location.Latitude},
+			},
+			Properties: map[string]interface{}{
+				"name":        location.Name,
+				"description": location.Description,
+				"address":     location.Address,
+			},
+		})
+	}
+
+	return collection
 }
 
 // SensorReading represents a sensor data reading
@@ -64,26 +181,113 @@ type SensorReading struct {
 	Quality   int             `json:"quality"`
 	Metadata  json.RawMessage `json:"metadata,omitempty"`
 	CreatedAt time.Time       `json:"created_at"`
+	// Smoothed is the centered moving average of the value when the query
+	// asked for smoothing
+	Smoothed *float64 `json:"smoothed,omitempty"`
+	// Unit is set when the value was converted from the sensor type's unit
+	Unit string `json:"unit,omitempty"`
+	// RawValue is the value as reported, before calibration
+	RawValue    float64  `json:"-"`
+	RawSmoothed *float64 `json:"-"`
+}
+
+// UseRawValue replaces the calibrated value with the value as reported
+func (r *SensorReading) UseRawValue() {
+	r.Value = r.RawValue
+	r.Smoothed = r.RawSmoothed
 }
 
 // CreateSensorRequest represents request to create sensor
 type CreateSensorRequest struct {
-	DeviceID        string `json:"device_id"`
-	Name            string `json:"name"`
-	Description     string `json:"description"`
-	SensorTypeID    int    `json:"sensor_type_id"`
-	LocationID      *int   `json:"location_id,omitempty"`
-	FirmwareVersion string `json:"firmware_version"`
+	DeviceID                string          `json:"device_id"`
+	Name                    string          `json:"name"`
+	Description             string          `json:"description"`
+	SensorTypeID            int             `json:"sensor_type_id"`
+	LocationID              *int            `json:"location_id,omitempty"`
+	FirmwareVersion         string          `json:"firmware_version"`
+	ExpectedIntervalSeconds *int            `json:"expected_interval_seconds,omitempty"`
+	IngestRatePerMinute     *int            `json:"ingest_rate_per_minute,omitempty"`
+	Tags                    []string        `json:"tags,omitempty"`
+	Metadata                json.RawMessage `json:"metadata,omitempty"`
 }
 
 // UpdateSensorRequest represents request to update sensor
 type UpdateSensorRequest struct {
-	Name            *string `json:"name,omitempty"`
-	Description     *string `json:"description,omitempty"`
-	LocationID      *int    `json:"location_id,omitempty"`
-	IsActive        *bool   `json:"is_active,omitempty"`
-	BatteryLevel    *int    `json:"battery_level,omitempty"`
-	FirmwareVersion *string `json:"firmware_version,omitempty"`
+	Name                    *string `json:"name,omitempty"`
+	Description             *string `json:"description,omitempty"`
+	LocationID              *int    `json:"location_id,omitempty"`
+	IsActive                *bool   `json:"is_active,omitempty"`
+	BatteryLevel            *int    `json:"battery_level,omitempty"`
+	FirmwareVersion         *string `json:"firmware_version,omitempty"`
+	ExpectedIntervalSeconds *int    `json:"expected_interval_seconds,omitempty"`
+	IngestRatePerMinute     *int    `json:"ingest_rate_per_minute,omitempty"`
+	// Tags replaces the sensor's tags when set; an empty list removes them
+	Tags []string `json:"tags,omitempty"`
+	// Metadata replaces the sensor's metadata when set
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+}
+
+// ApproveSensorRequest represents request to approve a pending sensor with
+// its real sensor type and location
+type ApproveSensorRequest struct {
+	Name         *string `json:"name,omitempty"`
+	SensorTypeID int     `json:"sensor_type_id"`
+	LocationID   *int    `json:"location_id,omitempty"`
+	// OrganizationID moves the sensor out of the default organization
+	// devices are provisioned in; only super admins can set it
+	OrganizationID *int `json:"organization_id,omitempty"`
+}
+
+// CloneSensorRequest represents request to create a sensor with the
+// configuration of an existing one
+type CloneSensorRequest struct {
+	DeviceID string `json:"device_id"`
+	// Name and LocationID default to the source sensor's
+	Name       *string `json:"name,omitempty"`
+	LocationID *int    `json:"location_id,omitempty"`
+}
+
+// CreateSensorTypeRequest represents request to create sensor type
+type CreateSensorTypeRequest struct {
+	Name          string   `json:"name"`
+	Description   string   `json:"description"`
+	Unit          string   `json:"unit"`
+	MinValue      *float64 `json:"min_value,omitempty"`
+	MaxValue      *float64 `json:"max_value,omitempty"`
+	RetentionDays *int     `json:"retention_days,omitempty"`
+	// DecimalPlaces defaults to 2
+	DecimalPlaces *int              `json:"decimal_places,omitempty"`
+	ValueLabels   map[string]string `json:"value_labels,omitempty"`
+}
+
+// UpdateSensorTypeRequest represents request to update sensor type
+type UpdateSensorTypeRequest struct {
+	Name        *string  `json:"name,omitempty"`
+	Description *string  `json:"description,omitempty"`
+	Unit        *string  `json:"unit,omitempty"`
+	MinValue    *float64 `json:"min_value,omitempty"`
+	MaxValue    *float64 `json:"max_value,omitempty"`
+	// RetentionDays of 0 removes the override
+	RetentionDays *int `json:"retention_days,omitempty"`
+	DecimalPlaces *int `json:"decimal_places,omitempty"`
+	// ValueLabels replaces the type's labels when set; an empty object
+	// removes them
+	ValueLabels map[string]string `json:"value_labels,omitempty"`
+	IsActive    *bool             `json:"is_active,omitempty"`
+}
+
+// PurgeReadingsRequest represents request to delete readings older than Before
+type PurgeReadingsRequest struct {
+	SensorID *int      `json:"sensor_id,omitempty"`
+	Before   time.Time `json:"before"`
+	// DryRun only counts the readings that would be removed
+	DryRun bool `json:"dry_run"`
+}
+
+// PurgeReadingsResult reports the readings removed by a purge
+type PurgeReadingsResult struct {
+	Readings int64 `json:"readings"`
+	DryRun   bool  `json:"dry_run"`
 }
 
 // CreateSensorReadingRequest represents request to create sensor reading
@@ -95,9 +299,39 @@ type CreateSensorReadingRequest struct {
 	Metadata  json.RawMessage `json:"metadata,omitempty"`
 }
 
+// UpdateReadingQualityRequest represents request to correct a sensor reading.
+// Value and timestamp are never editable.
+type UpdateReadingQualityRequest struct {
+	Quality *int `json:"quality,omitempty"`
+	// Metadata is merged into the existing reading metadata
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+}
+
 // BulkSensorReadingRequest represents bulk reading request
 type BulkSensorReadingRequest struct {
 	Readings []CreateSensorReadingRequest `json:"readings"`
+	// AllowPartial stores the valid readings and reports the invalid ones
+	// instead of rejecting the whole batch
+	AllowPartial bool `json:"allow_partial,omitempty"`
+}
+
+// BulkReadingResult reports how many readings of a batch were stored.
+// Accepted readings of a sensor at an already stored timestamp are skipped
+// as duplicates.
+type BulkReadingResult struct {
+	Count      int                `json:"count"`
+	Accepted   int                `json:"accepted"`
+	Rejected   int                `json:"rejected"`
+	Inserted   int                `json:"inserted"`
+	Duplicates int                `json:"duplicates"`
+	Errors     []BulkReadingError `json:"errors,omitempty"`
+}
+
+// BulkReadingError reports why a reading of a partial batch was rejected;
+// Index is its 0-based position in the batch
+type BulkReadingError struct {
+	Index  int    `json:"index"`
+	Reason string `json:"reason"`
 }
 
 // SensorReadingQuery represents query parameters for sensor readings
@@ -108,8 +342,18 @@ type SensorReadingQuery struct {
 	Limit      int        `json:"limit"`
 	Offset     int        `json:"offset"`
 	MinQuality *int       `json:"min_quality,omitempty"`
+	MinValue   *float64   `json:"min_value,omitempty"`
+	MaxValue   *float64   `json:"max_value,omitempty"`
+	// Ascending orders readings oldest first instead of newest first
+	Ascending bool `json:"ascending"`
+	// SmoothWindow is the number of readings averaged around each reading;
+	// 0 disables smoothing
+	SmoothWindow int `json:"smooth_window,omitempty"`
 }
 
+// maxSmoothWindow bounds the moving average window of a readings query
+const maxSmoothWindow = 100
+
 // SensorStatistics represents sensor data statistics
 type SensorStatistics struct {
 	SensorID      int        `json:"sensor_id"`
@@ -117,9 +361,193 @@ type SensorStatistics struct {
 	MinValue      *float64   `json:"min_value"`
 	MaxValue      *float64   `json:"max_value"`
 	AvgValue      *float64   `json:"avg_value"`
+	StdDev        *float64   `json:"stddev"`
+	Median        *float64   `json:"median"`
+	P95           *float64   `json:"p95"`
 	LastValue     *float64   `json:"last_value"`
 	LastTimestamp *time.Time `json:"last_timestamp"`
 	Period        string     `json:"period"`
+	// Unit is set when the values were converted from the sensor type's unit
+	Unit string `json:"unit,omitempty"`
+	// Comparison is set when the statistics are compared with the
+	// previous period
+	Comparison *StatisticsComparison `json:"comparison,omitempty"`
+}
+
+// StatisticsComparison compares statistics with those of the preceding
+// window of equal length. Deltas are current minus previous; they are nil
+// when either period has no readings, and percentages are also nil when the
+// previous value is zero.
+type StatisticsComparison struct {
+	PreviousStartTime time.Time         `json:"previous_start_time"`
+	PreviousEndTime   time.Time         `json:"previous_end_time"`
+	Previous          *SensorStatistics `json:"previous"`
+	CountDelta        int64             `json:"count_delta"`
+	CountDeltaPercent *float64          `json:"count_delta_percent"`
+	MinDelta          *float64          `json:"min_delta"`
+	MinDeltaPercent   *float64          `json:"min_delta_percent"`
+	MaxDelta          *float64          `json:"max_delta"`
+	MaxDeltaPercent   *float64          `json:"max_delta_percent"`
+	AvgDelta          *float64          `json:"avg_delta"`
+	AvgDeltaPercent   *float64          `json:"avg_delta_percent"`
+}
+
+// CompareStatistics compares current statistics with previous ones
+func CompareStatistics(current, previous *SensorStatistics) *StatisticsComparison {
+	comparison := &StatisticsComparison{
+		Previous:   previous,
+		CountDelta: current.Count - previous.Count,
+	}
+
+	count, prevCount := float64(current.Count), float64(previous.Count)
+	_, comparison.CountDeltaPercent = statisticDelta(&count, &prevCount)
+	comparison.MinDelta, comparison.MinDeltaPercent = statisticDelta(current.MinValue, previous.MinValue)
+	comparison.MaxDelta, comparison.MaxDeltaPercent = statisticDelta(current.MaxValue, previous.MaxValue)
+	comparison.AvgDelta, comparison.AvgDeltaPercent = statisticDelta(current.AvgValue, previous.AvgValue)
+
+	return comparison
+}
+
+// statisticDelta returns the absolute and percent change from previous to
+// current
+func statisticDelta(current, previous *float64) (*float64, *float64) {
+	if current == nil || previous == nil {
+		return nil, nil
+	}
+
+	delta := *current - *previous
+	if *previous == 0 {
+		return &delta, nil
+	}
+
+	percent := delta / math.Abs(*previous) * 100
+	return &delta, &percent
+}
+
+// Aggregation functions selecting the value of a reading bucket
+const (
+	AggregateAvg = "avg"
+	AggregateMin = "min"
+	AggregateMax = "max"
+	AggregateSum = "sum"
+)
+
+// Aggregation limits
+const (
+	minAggregateInterval = time.Minute
+	maxAggregateInterval = 24 * time.Hour
+	maxAggregateBuckets  = 5000
+)
+
+// ReadingAggregateQuery represents query parameters for bucketed readings
+type ReadingAggregateQuery struct {
+	Interval  time.Duration
+	StartTime time.Time
+	EndTime   time.Time
+	Fn        string
+	// Fill includes buckets without readings with null values
+	Fill bool
+}
+
+// ReadingBucket summarizes the readings of one interval
+type ReadingBucket struct {
+	BucketStart time.Time `json:"bucket_start"`
+	Value       *float64  `json:"value"`
+	Avg         *float64  `json:"avg"`
+	Min         *float64  `json:"min"`
+	Max         *float64  `json:"max"`
+	Sum         *float64  `json:"-"`
+	Count       int64     `json:"count"`
+}
+
+// maxAvailabilityDays bounds the time range of an availability query
+const maxAvailabilityDays = 366
+
+// maxDailyStatisticsDays bounds the time range of a daily statistics query
+const maxDailyStatisticsDays = 366
+
+// Anomaly detection limits
+const (
+	// DefaultAnomalySigma is how many standard deviations from the mean a
+	// reading must be to count as an anomaly when the query does not say
+	DefaultAnomalySigma = 3
+	maxAnomalySigma     = 10
+	// minAnomalyReadings is the fewest readings the mean and standard
+	// deviation are meaningful for
+	minAnomalyReadings = 10
+	// maxAnomalies bounds the anomalous readings returned, oldest first
+	maxAnomalies = 1000
+)
+
+// AnomalyReport lists the readings of a time range whose value is more
+// than Sigma standard deviations away from the mean of the range
+type AnomalyReport struct {
+	SensorID   int              `json:"sensor_id"`
+	StartTime  time.Time        `json:"start_time"`
+	EndTime    time.Time        `json:"end_time"`
+	Sigma      float64          `json:"sigma"`
+	Count      int64            `json:"count"`
+	Mean       *float64         `json:"mean"`
+	StdDev     *float64         `json:"stddev"`
+	LowerBound float64          `json:"lower_bound"`
+	UpperBound float64          `json:"upper_bound"`
+	Anomalies  []*SensorReading `json:"anomalies"`
+	// Truncated is set when more than maxAnomalies readings were anomalous
+	Truncated bool `json:"truncated"`
+}
+
+// DailyStatistics summarizes a sensor's readings of one calendar day in the
+// query time zone
+type DailyStatistics struct {
+	Date  string   `json:"date"`
+	Count int64    `json:"count"`
+	Min   *float64 `json:"min"`
+	Max   *float64 `json:"max"`
+	Avg   *float64 `json:"avg"`
+}
+
+// ReadingGap is a period without readings longer than the sensor's online
+// threshold
+type ReadingGap struct {
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	DurationSeconds float64   `json:"duration_seconds"`
+}
+
+// Gap report limits
+const (
+	// maxGapReportDays bounds the time range of a gap report
+	maxGapReportDays = 366
+	// maxReportedGaps bounds the gaps listed in a report, oldest first
+	maxReportedGaps = 1000
+)
+
+// GapReport lists the gaps between consecutive readings of a time range
+// longer than MinGapSeconds; GapCount and TotalGapSeconds cover every gap,
+// even when the list is truncated
+type GapReport struct {
+	SensorID        int           `json:"sensor_id"`
+	StartTime       time.Time     `json:"start_time"`
+	EndTime         time.Time     `json:"end_time"`
+	MinGapSeconds   float64       `json:"min_gap_seconds"`
+	GapCount        int           `json:"gap_count"`
+	TotalGapSeconds float64       `json:"total_gap_seconds"`
+	Gaps            []*ReadingGap `json:"gaps"`
+	Truncated       bool          `json:"truncated"`
+}
+
+// SensorAvailability reports how much of a time range a sensor was online.
+// Every gap between readings, or between the range bounds and the nearest
+// reading, longer than the gap threshold counts as downtime in full.
+type SensorAvailability struct {
+	SensorID            int         `json:"sensor_id"`
+	StartTime           time.Time   `json:"start_time"`
+	EndTime             time.Time   `json:"end_time"`
+	GapThresholdSeconds float64     `json:"gap_threshold_seconds"`
+	UptimePercent       float64     `json:"uptime_percent"`
+	DowntimeSeconds     float64     `json:"downtime_seconds"`
+	Gaps                int         `json:"gaps"`
+	LongestGap          *ReadingGap `json:"longest_gap,omitempty"`
 }
 
 // CreateLocationRequest represents request to create location
@@ -141,19 +569,486 @@ type UpdateLocationRequest struct {
 	IsActive    *bool    `json:"is_active,omitempty"`
 }
 
+// maxAssignSensors bounds the sensors moved by one assignment
+const maxAssignSensors = 1000
+
+// AssignSensorsRequest represents request to move sensors to a location
+type AssignSensorsRequest struct {
+	SensorIDs []int `json:"sensor_ids"`
+}
+
+// LocationAssignment reports the sensors moved to a location. NotFound
+// lists the IDs that do not exist or the caller cannot update, Inactive the
+// deactivated sensors, which keep their location.
+type LocationAssignment struct {
+	UpdatedCount int              `json:"updated_count"`
+	NotFound     []int            `json:"not_found"`
+	Inactive     []int            `json:"inactive"`
+	Summary      *LocationSummary `json:"location_summary,omitempty"`
+}
+
+// Access levels of a sensor access grant; write implies read
+const (
+	AccessLevelRead  = "read"
+	AccessLevelWrite = "write"
+)
+
+// SensorAccess grants a user or a role access to a sensor or to every
+// sensor at a location
+type SensorAccess struct {
+	ID          int       `json:"id"`
+	SensorID    *int      `json:"sensor_id,omitempty"`
+	LocationID  *int      `json:"location_id,omitempty"`
+	UserID      *int      `json:"user_id,omitempty"`
+	RoleID      *int      `json:"role_id,omitempty"`
+	AccessLevel string    `json:"access_level"`
+	GrantedBy   *int      `json:"granted_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// CreateSensorAccessRequest represents request to grant sensor access
+type CreateSensorAccessRequest struct {
+	SensorID    *int   `json:"sensor_id,omitempty"`
+	LocationID  *int   `json:"location_id,omitempty"`
+	UserID      *int   `json:"user_id,omitempty"`
+	RoleID      *int   `json:"role_id,omitempty"`
+	AccessLevel string `json:"access_level"`
+}
+
+// SensorFilter narrows the sensors returned by ListSensors
+type SensorFilter struct {
+	// Query matches name or device ID case-insensitively
+	Query        string `json:"q,omitempty"`
+	SensorTypeID *int   `json:"sensor_type_id,omitempty"`
+	LocationID   *int   `json:"location_id,omitempty"`
+	// IsActive defaults to active sensors; nil lists both
+	IsActive *bool `json:"is_active,omitempty"`
+	// Online matches sensors with a reading within their online threshold
+	Online *bool `json:"online,omitempty"`
+	// OnlineThreshold applies to sensors without an expected interval
+	OnlineThreshold time.Duration `json:"-"`
+	// CreatedBy limits the list to sensors created by the user
+	CreatedBy *int `json:"created_by,omitempty"`
+	// Tag matches sensors carrying the tag
+	Tag string `json:"tag,omitempty"`
+	// Metadata matches sensors whose top-level metadata keys hold the values
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Pending lists auto-provisioned sensors awaiting approval instead of
+	// provisioned ones
+	Pending  bool   `json:"-"`
+	SortBy   string `json:"sort_by"`
+	SortDesc bool   `json:"sort_desc"`
+}
+
+// SensorTag is a tag with the number of sensors carrying it
+type SensorTag struct {
+	Tag     string `json:"tag"`
+	Sensors int    `json:"sensors"`
+}
+
+// FirmwareVersionCount is the number of sensors of a type running a
+// firmware version
+type FirmwareVersionCount struct {
+	SensorTypeID    int    `json:"sensor_type_id"`
+	SensorType      string `json:"sensor_type"`
+	FirmwareVersion string `json:"firmware_version"`
+	Sensors         int    `json:"sensors"`
+}
+
+// FirmwareChange records a sensor's firmware version changing
+type FirmwareChange struct {
+	ID              int64     `json:"id"`
+	SensorID        int       `json:"sensor_id"`
+	PreviousVersion string    `json:"previous_version"`
+	FirmwareVersion string    `json:"firmware_version"`
+	ChangedAt       time.Time `json:"changed_at"`
+}
+
+// Sensor statuses recorded on transitions
+const (
+	SensorStatusOnline  = "online"
+	SensorStatusOffline = "offline"
+)
+
+// SensorStatusEvent records a sensor going offline or coming back online
+type SensorStatusEvent struct {
+	ID            int64      `json:"id"`
+	SensorID      int        `json:"sensor_id"`
+	Status        string     `json:"status"`
+	LastReadingAt *time.Time `json:"last_reading_at,omitempty"`
+	OccurredAt    time.Time  `json:"occurred_at"`
+	// Sensor details are only set on detected transitions
+	DeviceID       string `json:"device_id,omitempty"`
+	SensorName     string `json:"sensor_name,omitempty"`
+	OrganizationID int    `json:"-"`
+}
+
+// SensorBatteryEvent is published when a sensor's battery becomes critical
+type SensorBatteryEvent struct {
+	SensorID       int       `json:"sensor_id"`
+	DeviceID       string    `json:"device_id"`
+	SensorName     string    `json:"sensor_name"`
+	BatteryLevel   int       `json:"battery_level"`
+	OccurredAt     time.Time `json:"occurred_at"`
+	OrganizationID int       `json:"-"`
+}
+
+// Access identifies the user sensors are accessed for. Sensors with access
+// grants are limited to their grantees and creator; the zero Access is
+// unrestricted and is meant for admins and internal callers.
+type Access struct {
+	UserID     int
+	RoleIDs    []int
+	Restricted bool
+}
+
+// AccessFor returns the sensor access of a user; admins are unrestricted
+func AccessFor(user *interfaces.User) Access {
+	if user.IsAdmin() {
+		return Access{}
+	}
+
+	roleIDs := make([]int, 0, len(user.Roles))
+	for _, role := range user.Roles {
+		if role.IsActive {
+			roleIDs = append(roleIDs, role.ID)
+		}
+	}
+
+	return Access{UserID: user.ID, RoleIDs: roleIDs, Restricted: true}
+}
+
+// SensorGroup is a named set of sensors that may span locations
+type SensorGroup struct {
+	ID             int       `json:"id"`
+	OrganizationID int       `json:"organization_id"`
+	Name           string    `json:"name"`
+	Description    string    `json:"description"`
+	SensorCount    int       `json:"sensor_count"`
+	CreatedBy      *int      `json:"created_by,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// CreateSensorGroupRequest represents request to create sensor group
+type CreateSensorGroupRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// UpdateSensorGroupRequest represents request to update sensor group
+type UpdateSensorGroupRequest struct {
+	Name        *string `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+}
+
+// UpdateGroupMembersRequest represents request to add sensors to and
+// remove sensors from a group
+type UpdateGroupMembersRequest struct {
+	Add    []int `json:"add,omitempty"`
+	Remove []int `json:"remove,omitempty"`
+}
+
+// Statuses of a device command. Commands are pending until published and
+// sent until the device acknowledges them, reports a failure or times out.
+// A command that is never published times out as well.
+const (
+	CommandStatusPending      = "pending"
+	CommandStatusSent         = "sent"
+	CommandStatusAcknowledged = "acknowledged"
+	CommandStatusFailed       = "failed"
+	CommandStatusTimedOut     = "timed_out"
+)
+
+// DefaultCommandTimeoutSeconds is how long a device has to acknowledge a
+// command when no timeout is configured
+const DefaultCommandTimeoutSeconds = 300
+
+// maxCommandPayloadBytes caps the size of a device command payload
+const maxCommandPayloadBytes = 16 << 10
+
+// SensorCommand is a command sent to a device on its MQTT commands topic.
+// Devices acknowledge it with its CommandUUID.
+type SensorCommand struct {
+	ID          int64           `json:"id"`
+	CommandUUID string          `json:"command_uuid"`
+	SensorID    int             `json:"sensor_id"`
+	CommandType string          `json:"command_type"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	// Response is what the device sent with its acknowledgement
+	Response       json.RawMessage `json:"response,omitempty"`
+	SentBy         *int            `json:"sent_by,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	SentAt         *time.Time      `json:"sent_at,omitempty"`
+	AcknowledgedAt *time.Time      `json:"acknowledged_at,omitempty"`
+}
+
+// CommandAck is a device's reply to a command; Status is acknowledged or
+// failed
+type CommandAck struct {
+	CommandUUID string
+	Status      string
+	Response    json.RawMessage
+}
+
+// SendCommandRequest represents request to send a command to a device
+type SendCommandRequest struct {
+	CommandType string          `json:"command_type"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
+}
+
+// SensorCalibration records a change of the calibration of a sensor
+type SensorCalibration struct {
+	ID             int64     `json:"id"`
+	SensorID       int       `json:"sensor_id"`
+	Offset         float64   `json:"calibration_offset"`
+	Scale          float64   `json:"calibration_scale"`
+	PreviousOffset float64   `json:"previous_offset"`
+	PreviousScale  float64   `json:"previous_scale"`
+	ChangedBy      *int      `json:"changed_by,omitempty"`
+	ChangedAt      time.Time `json:"changed_at"`
+}
+
+// UpdateCalibrationRequest represents request to recalibrate a sensor
+type UpdateCalibrationRequest struct {
+	Offset *float64 `json:"calibration_offset,omitempty"`
+	Scale  *float64 `json:"calibration_scale,omitempty"`
+}
+
+// maxMaintenanceDays bounds how long a sensor can be put in maintenance
+const maxMaintenanceDays = 90
+
+// SetMaintenanceRequest represents request to put a sensor in maintenance
+// until a time, or to end its maintenance when Until is not set
+type SetMaintenanceRequest struct {
+	Until  *time.Time `json:"until,omitempty"`
+	Reason string     `json:"reason"`
+}
+
+// SensorMaintenance records a change of the maintenance window of a sensor
+type SensorMaintenance struct {
+	ID               int64      `json:"id"`
+	SensorID         int        `json:"sensor_id"`
+	MaintenanceUntil *time.Time `json:"maintenance_until,omitempty"`
+	Reason           string     `json:"reason"`
+	SetBy            *int       `json:"set_by,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// maxNoteLength bounds the body of a sensor note
+const maxNoteLength = 5000
+
+// SensorNote is a note left on a sensor, such as by a technician
+type SensorNote struct {
+	ID       int64 `json:"id"`
+	SensorID int   `json:"sensor_id"`
+	// AuthorID is nil once the author's account is deleted
+	AuthorID   *int      `json:"author_id,omitempty"`
+	AuthorName string    `json:"author_name"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// CreateSensorNoteRequest represents request to leave a note on a sensor
+type CreateSensorNoteRequest struct {
+	Body string `json:"body"`
+}
+
 // Domain errors
 var (
-	ErrInvalidDeviceID    = errors.New("invalid device ID format")
-	ErrDeviceIDExists     = errors.New("device ID already exists")
-	ErrSensorNotFound     = errors.New("sensor not found")
-	ErrSensorTypeNotFound = errors.New("sensor type not found")
-	ErrLocationNotFound   = errors.New("location not found")
-	ErrInvalidValue       = errors.New("sensor value out of range")
-	ErrInvalidQuality     = errors.New("quality must be between 0 and 100")
-	ErrInvalidBattery     = errors.New("battery level must be between 0 and 100")
-	ErrSensorInactive     = errors.New("sensor is inactive")
+	ErrInvalidDeviceID         = errors.New("invalid device ID format")
+	ErrDeviceIDExists          = errors.New("device ID already exists")
+	ErrSensorNotFound          = errors.New("sensor not found")
+	ErrSensorTypeNotFound      = errors.New("sensor type not found")
+	ErrLocationNotFound        = errors.New("location not found")
+	ErrLocationInactive        = errors.New("location is inactive")
+	ErrInvalidSensorIDs        = errors.New("sensor_ids must list between 1 and 1000 sensor IDs")
+	ErrInvalidValue            = errors.New("sensor value out of range")
+	ErrInvalidQuality          = errors.New("quality must be between 0 and 100")
+	ErrInvalidBattery          = errors.New("battery level must be between 0 and 100")
+	ErrSensorInactive          = errors.New("sensor is inactive")
+	ErrAccessNotFound          = errors.New("sensor access grant not found")
+	ErrAccessExists            = errors.New("sensor access grant already exists")
+	ErrInvalidAccessLevel      = errors.New("access level must be read or write")
+	ErrInvalidAccessGrant      = errors.New("grant exactly one of sensor_id or location_id to exactly one of user_id or role_id")
+	ErrSensorAccessDenied      = errors.New("sensor access denied")
+	ErrGranteeNotFound         = errors.New("user or role not found")
+	ErrSensorTypeExists        = errors.New("sensor type already exists")
+	ErrInvalidTypeName         = errors.New("sensor type name must be 2-100 characters")
+	ErrInvalidUnit             = errors.New("unit is required and must be at most 20 characters")
+	ErrInvalidValueRange       = errors.New("min_value must be less than max_value")
+	ErrInvalidRetention        = errors.New("retention_days must be positive")
+	ErrInvalidDecimalPlaces    = errors.New("decimal_places must be between 0 and 6")
+	ErrInvalidLatitude         = errors.New("latitude must be between -90 and 90")
+	ErrInvalidLongitude        = errors.New("longitude must be between -180 and 180")
+	ErrEmptyLocationName       = errors.New("name cannot be empty")
+	ErrAddressTooLong          = errors.New("address must be less than 500 characters")
+	ErrInvalidCoordinates      = errors.New("lat must be between -90 and 90 and lng between -180 and 180")
+	ErrInvalidRadius           = errors.New("radius_km must be greater than 0 and at most 1000")
+	ErrInvalidValueLabels      = errors.New("value_labels must map at most 20 numeric values to labels of 1-50 characters")
+	ErrInvalidPurgeCutoff      = errors.New("before must be a time in the past")
+	ErrInvalidInterval         = errors.New("interval must be between 1m and 1d")
+	ErrInvalidAggregateFn      = errors.New("fn must be avg, min, max or sum")
+	ErrInvalidTimeRange        = errors.New("end_time must be after start_time")
+	ErrTooManyBuckets          = errors.New("time range holds too many buckets for the interval")
+	ErrReadingNotFound         = errors.New("sensor reading not found")
+	ErrDuplicateReading        = errors.New("sensor already has a reading at this timestamp")
+	ErrNoReadingChanges        = errors.New("quality or metadata is required")
+	ErrInvalidMetadata         = errors.New("metadata must be a JSON object")
+	ErrMetadataTooLarge        = errors.New("metadata must be at most 16KB")
+	ErrNoCalibrationChanges    = errors.New("calibration_offset or calibration_scale is required")
+	ErrInvalidCalibration      = errors.New("calibration_scale must be non-zero and values must be finite")
+	ErrTimeRangeRequired       = errors.New("start_time and end_time are required")
+	ErrDeleteWindowTooBig      = errors.New("time window exceeds the maximum allowed for deleting readings")
+	ErrInvalidExpectedInterval = errors.New("expected_interval_seconds must be between 1 and 604800")
+	ErrInvalidIngestRate       = errors.New("ingest_rate_per_minute must be between 1 and 60000")
+	ErrAvailabilityRangeTooBig = errors.New("time range must be at most 366 days")
+	ErrDailyRangeTooBig        = errors.New("time range must be at most 366 days")
+	ErrInvalidTimezone         = errors.New("tz must be an IANA time zone name such as Asia/Jakarta")
+	ErrInvalidSmoothing        = errors.New("smooth must be window:N with N between 1 and 100")
+	ErrInvalidSigma            = errors.New("sigma must be greater than 0 and at most 10")
+	ErrTooFewReadings          = errors.New("at least 10 readings in the time range are needed to detect anomalies")
+	ErrZeroVariance            = errors.New("readings in the time range do not vary, so none stand out as anomalies")
+	ErrInvalidMinGap           = errors.New("min_gap must be a duration of at least 1s such as 15m")
+	ErrGapRangeTooBig          = errors.New("time range must be at most 366 days")
+	ErrInvalidTags             = errors.New("tags must be at most 10 non-empty tags of up to 50 characters")
+	ErrGroupNotFound           = errors.New("sensor group not found")
+	ErrGroupExists             = errors.New("sensor group already exists")
+	ErrNoMemberChanges         = errors.New("add or remove must list at least one sensor")
+	ErrSensorTypeRequired      = errors.New("sensor_type_id is required")
+	ErrSensorProvisioned       = errors.New("sensor is already provisioned")
+	ErrOrganizationNotFound    = errors.New("organization not found")
+	ErrOrganizationSuperAdmin  = errors.New("only super admins can set organization_id")
+	ErrSensorNotProvisioned    = errors.New("sensor is pending approval")
+	ErrAutoProvisionDisabled   = errors.New("auto-provisioning is disabled")
+	ErrDeviceTokenRequired     = errors.New("device token is required")
+	ErrInvalidDeviceToken      = errors.New("device token does not match the sensor")
+	ErrRateLimited             = errors.New("sensor exceeded its ingest rate limit")
+	ErrInvalidCommandType      = errors.New("command_type must be 1-50 letters, digits, underscores or hyphens")
+	ErrCommandTooLarge         = errors.New("command payload must be at most 16KB")
+	ErrCommandsUnavailable     = errors.New("MQTT broker is not connected")
+	ErrInvalidCommandAck       = errors.New("command ack must have a command_uuid and a status of acknowledged or failed")
+	ErrCommandNotFound         = errors.New("command not found or already completed")
+	ErrInvalidMaintenance      = errors.New("until must be in the future and at most 90 days away")
+	ErrInvalidReason           = errors.New("reason is required and must be at most 500 characters")
+	ErrInvalidNote             = errors.New("note body is required and must be at most 5000 characters")
+	ErrNoteNotFound            = errors.New("note not found")
+	ErrNoteNotAuthor           = errors.New("only the author or an admin can delete a note")
+	ErrNoReadings              = errors.New("no readings provided")
+	ErrTooManyReadings         = errors.New("too many readings")
+	ErrInvalidReading          = errors.New("invalid reading")
 )
 
+// ErrorCodes are the stable API error codes of the errors above
+var ErrorCodes = []response.ErrorCode{
+	response.Code("SENSOR_INVALID_DEVICE_ID", ErrInvalidDeviceID),
+	response.Code("SENSOR_DEVICE_ID_EXISTS", ErrDeviceIDExists),
+	response.Code("SENSOR_NOT_FOUND", ErrSensorNotFound),
+	response.Code("SENSOR_TYPE_NOT_FOUND", ErrSensorTypeNotFound),
+	response.Code("SENSOR_LOCATION_NOT_FOUND", ErrLocationNotFound),
+	response.Code("SENSOR_LOCATION_INACTIVE", ErrLocationInactive),
+	response.Code("SENSOR_INVALID_IDS", ErrInvalidSensorIDs),
+	response.Code("SENSOR_VALUE_OUT_OF_RANGE", ErrInvalidValue),
+	response.Code("SENSOR_INVALID_QUALITY", ErrInvalidQuality),
+	response.Code("SENSOR_INVALID_BATTERY", ErrInvalidBattery),
+	response.Code("SENSOR_INACTIVE", ErrSensorInactive),
+	response.Code("SENSOR_ACCESS_NOT_FOUND", ErrAccessNotFound),
+	response.Code("SENSOR_ACCESS_EXISTS", ErrAccessExists),
+	response.Code("SENSOR_INVALID_ACCESS_LEVEL", ErrInvalidAccessLevel),
+	response.Code("SENSOR_INVALID_ACCESS_GRANT", ErrInvalidAccessGrant),
+	response.Code("SENSOR_ACCESS_DENIED", ErrSensorAccessDenied),
+	response.Code("SENSOR_GRANTEE_NOT_FOUND", ErrGranteeNotFound),
+	response.Code("SENSOR_TYPE_EXISTS", ErrSensorTypeExists),
+	response.Code("SENSOR_TYPE_INVALID_NAME", ErrInvalidTypeName),
+	response.Code("SENSOR_INVALID_UNIT", ErrInvalidUnit),
+	response.Code("SENSOR_INVALID_VALUE_RANGE", ErrInvalidValueRange),
+	response.Code("SENSOR_INVALID_RETENTION", ErrInvalidRetention),
+	response.Code("SENSOR_INVALID_DECIMAL_PLACES", ErrInvalidDecimalPlaces),
+	response.Code("SENSOR_INVALID_LATITUDE", ErrInvalidLatitude),
+	response.Code("SENSOR_INVALID_LONGITUDE", ErrInvalidLongitude),
+	response.Code("SENSOR_LOCATION_NAME_REQUIRED", ErrEmptyLocationName),
+	response.Code("SENSOR_LOCATION_ADDRESS_TOO_LONG", ErrAddressTooLong),
+	response.Code("SENSOR_INVALID_COORDINATES", ErrInvalidCoordinates),
+	response.Code("SENSOR_INVALID_RADIUS", ErrInvalidRadius),
+	response.Code("SENSOR_INVALID_VALUE_LABELS", ErrInvalidValueLabels),
+	response.Code("SENSOR_INVALID_PURGE_CUTOFF", ErrInvalidPurgeCutoff),
+	response.Code("SENSOR_INVALID_INTERVAL", ErrInvalidInterval),
+	response.Code("SENSOR_INVALID_AGGREGATE_FN", ErrInvalidAggregateFn),
+	response.Code("SENSOR_INVALID_TIME_RANGE", ErrInvalidTimeRange),
+	response.Code("SENSOR_TOO_MANY_BUCKETS", ErrTooManyBuckets),
+	response.Code("SENSOR_READING_NOT_FOUND", ErrReadingNotFound),
+	response.Code("SENSOR_DUPLICATE_READING", ErrDuplicateReading),
+	response.Code("SENSOR_NO_READING_CHANGES", ErrNoReadingChanges),
+	response.Code("SENSOR_INVALID_METADATA", ErrInvalidMetadata),
+	response.Code("SENSOR_METADATA_TOO_LARGE", ErrMetadataTooLarge),
+	response.Code("SENSOR_NO_CALIBRATION_CHANGES", ErrNoCalibrationChanges),
+	response.Code("SENSOR_INVALID_CALIBRATION", ErrInvalidCalibration),
+	response.Code("SENSOR_TIME_RANGE_REQUIRED", ErrTimeRangeRequired),
+	response.Code("SENSOR_DELETE_WINDOW_TOO_BIG", ErrDeleteWindowTooBig),
+	response.Code("SENSOR_INVALID_EXPECTED_INTERVAL", ErrInvalidExpectedInterval),
+	response.Code("SENSOR_INVALID_INGEST_RATE", ErrInvalidIngestRate),
+	response.Code("SENSOR_AVAILABILITY_RANGE_TOO_BIG", ErrAvailabilityRangeTooBig),
+	response.Code("SENSOR_DAILY_RANGE_TOO_BIG", ErrDailyRangeTooBig),
+	response.Code("SENSOR_INVALID_TIMEZONE", ErrInvalidTimezone),
+	response.Code("SENSOR_INVALID_SMOOTHING", ErrInvalidSmoothing),
+	response.Code("SENSOR_INVALID_SIGMA", ErrInvalidSigma),
+	response.Code("SENSOR_TOO_FEW_READINGS", ErrTooFewReadings),
+	response.Code("SENSOR_ZERO_VARIANCE", ErrZeroVariance),
+	response.Code("SENSOR_INVALID_MIN_GAP", ErrInvalidMinGap),
+	response.Code("SENSOR_GAP_RANGE_TOO_BIG", ErrGapRangeTooBig),
+	response.Code("SENSOR_INVALID_TAGS", ErrInvalidTags),
+	response.Code("SENSOR_GROUP_NOT_FOUND", ErrGroupNotFound),
+	response.Code("SENSOR_GROUP_EXISTS", ErrGroupExists),
+	response.Code("SENSOR_NO_MEMBER_CHANGES", ErrNoMemberChanges),
+	response.Code("SENSOR_TYPE_REQUIRED", ErrSensorTypeRequired),
+	response.Code("SENSOR_ALREADY_PROVISIONED", ErrSensorProvisioned),
+	response.Code("SENSOR_ORGANIZATION_NOT_FOUND", ErrOrganizationNotFound),
+	response.Code("SENSOR_ORGANIZATION_SUPER_ADMIN_ONLY", ErrOrganizationSuperAdmin),
+	response.Code("SENSOR_NOT_PROVISIONED", ErrSensorNotProvisioned),
+	response.Code("SENSOR_AUTO_PROVISION_DISABLED", ErrAutoProvisionDisabled),
+	response.Code("SENSOR_DEVICE_TOKEN_REQUIRED", ErrDeviceTokenRequired),
+	response.Code("SENSOR_INVALID_DEVICE_TOKEN", ErrInvalidDeviceToken),
+	response.Code("SENSOR_RATE_LIMITED", ErrRateLimited),
+	response.Code("SENSOR_INVALID_COMMAND_TYPE", ErrInvalidCommandType),
+	response.Code("SENSOR_COMMAND_TOO_LARGE", ErrCommandTooLarge),
+	response.Code("SENSOR_COMMANDS_UNAVAILABLE", ErrCommandsUnavailable),
+	response.Code("SENSOR_INVALID_COMMAND_ACK", ErrInvalidCommandAck),
+	response.Code("SENSOR_COMMAND_NOT_FOUND", ErrCommandNotFound),
+	response.Code("SENSOR_INVALID_MAINTENANCE", ErrInvalidMaintenance),
+	response.Code("SENSOR_INVALID_REASON", ErrInvalidReason),
+	response.Code("SENSOR_INVALID_NOTE", ErrInvalidNote),
+	response.Code("SENSOR_NOTE_NOT_FOUND", ErrNoteNotFound),
+	response.Code("SENSOR_NOTE_NOT_AUTHOR", ErrNoteNotAuthor),
+	response.Code("SENSOR_NO_READINGS", ErrNoReadings),
+	response.Code("SENSOR_TOO_MANY_READINGS", ErrTooManyReadings),
+	response.Code("SENSOR_INVALID_READING", ErrInvalidReading),
+}
+
+// LocationInUseError is returned when deactivating a location that active
+// sensors still reference
+type LocationInUseError struct {
+	ActiveSensors int
+}
+
+func (e *LocationInUseError) Error() string {
+	return fmt.Sprintf("location has %d active sensors", e.ActiveSensors)
+}
+
+// RateLimitError is returned when a sensor sends readings faster than its
+// ingest rate limit; it wraps ErrRateLimited
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%v, retry after %v", ErrRateLimited, e.RetryAfter)
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return ErrRateLimited
+}
+
 // Validate validates CreateSensorRequest
 func (req *CreateSensorRequest) Validate() error {
 	// Validate device ID
@@ -171,6 +1066,47 @@ func (req *CreateSensorRequest) Validate() error {
 		return errors.New("sensor type ID is required")
 	}
 
+	if req.ExpectedIntervalSeconds != nil && !validExpectedInterval(*req.ExpectedIntervalSeconds) {
+		return ErrInvalidExpectedInterval
+	}
+
+	if req.IngestRatePerMinute != nil && !validIngestRate(*req.IngestRatePerMinute) {
+		return ErrInvalidIngestRate
+	}
+
+	tags, err := NormalizeTags(req.Tags)
+	if err != nil {
+		return err
+	}
+	req.Tags = tags
+
+	if len(req.Metadata) > 0 {
+		if err := validateSensorMetadata(req.Metadata); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Validate validates ApproveSensorRequest
+func (req *ApproveSensorRequest) Validate() error {
+	if req.Name != nil {
+		if err := validateName(*req.Name); err != nil {
+			return err
+		}
+		trimmed := strings.TrimSpace(*req.Name)
+		req.Name = &trimmed
+	}
+
+	if req.SensorTypeID <= 0 {
+		return ErrSensorTypeRequired
+	}
+
+	if req.OrganizationID != nil && *req.OrganizationID <= 0 {
+		return ErrOrganizationNotFound
+	}
+
 	return nil
 }
 
@@ -184,6 +1120,175 @@ func (req *UpdateSensorRequest) Validate() error {
 		return ErrInvalidBattery
 	}
 
+	if req.ExpectedIntervalSeconds != nil && !validExpectedInterval(*req.ExpectedIntervalSeconds) {
+		return ErrInvalidExpectedInterval
+	}
+
+	if req.IngestRatePerMinute != nil && !validIngestRate(*req.IngestRatePerMinute) {
+		return ErrInvalidIngestRate
+	}
+
+	if req.Tags != nil {
+		tags, err := NormalizeTags(req.Tags)
+		if err != nil {
+			return err
+		}
+		req.Tags = tags
+	}
+
+	if len(req.Metadata) > 0 {
+		if err := validateSensorMetadata(req.Metadata); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// maxSensorMetadataBytes caps the size of sensor metadata
+const maxSensorMetadataBytes = 16 << 10
+
+// validateSensorMetadata checks that metadata is a JSON object of at most
+// maxSensorMetadataBytes
+func validateSensorMetadata(metadata json.RawMessage) error {
+	if len(metadata) > maxSensorMetadataBytes {
+		return ErrMetadataTooLarge
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(metadata, &fields); err != nil || fields == nil {
+		return ErrInvalidMetadata
+	}
+
+	return nil
+}
+
+// Sensor tag limits
+const (
+	maxSensorTags = 10
+	maxTagLength  = 50
+)
+
+// NormalizeTags trims and lowercases tags and drops duplicates, keeping
+// their order
+func NormalizeTags(tags []string) ([]string, error) {
+	normalized := []string{}
+	seen := make(map[string]bool, len(tags))
+
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || len(tag) > maxTagLength {
+			return nil, ErrInvalidTags
+		}
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	if len(normalized) > maxSensorTags {
+		return nil, ErrInvalidTags
+	}
+
+	return normalized, nil
+}
+
+// maxExpectedIntervalSeconds is the longest reporting interval a sensor can declare
+const maxExpectedIntervalSeconds = 7 * 24 * 60 * 60
+
+// validExpectedInterval checks an expected reporting interval in seconds
+func validExpectedInterval(seconds int) bool {
+	return seconds > 0 && seconds <= maxExpectedIntervalSeconds
+}
+
+// maxIngestRatePerMinute is the highest ingest rate a sensor can be allowed
+const maxIngestRatePerMinute = 60000
+
+// validIngestRate checks a per-sensor ingest rate limit in readings per minute
+func validIngestRate(perMinute int) bool {
+	return perMinute > 0 && perMinute <= maxIngestRatePerMinute
+}
+
+// Validate validates CreateSensorAccessRequest
+func (req *CreateSensorAccessRequest) Validate() error {
+	if (req.SensorID == nil) == (req.LocationID == nil) {
+		return ErrInvalidAccessGrant
+	}
+	if (req.UserID == nil) == (req.RoleID == nil) {
+		return ErrInvalidAccessGrant
+	}
+
+	req.AccessLevel = strings.ToLower(strings.TrimSpace(req.AccessLevel))
+	if req.AccessLevel != AccessLevelRead && req.AccessLevel != AccessLevelWrite {
+		return ErrInvalidAccessLevel
+	}
+
+	return nil
+}
+
+// Validate validates and normalizes CreateSensorTypeRequest
+func (req *CreateSensorTypeRequest) Validate() error {
+	req.Name = strings.ToLower(strings.TrimSpace(req.Name))
+	if err := validateSensorTypeName(req.Name); err != nil {
+		return err
+	}
+
+	req.Unit = strings.TrimSpace(req.Unit)
+	if err := validateUnit(req.Unit); err != nil {
+		return err
+	}
+
+	if req.MinValue != nil && req.MaxValue != nil && *req.MinValue >= *req.MaxValue {
+		return ErrInvalidValueRange
+	}
+
+	if req.RetentionDays != nil && *req.RetentionDays <= 0 {
+		return ErrInvalidRetention
+	}
+
+	if req.DecimalPlaces != nil && !validDecimalPlaces(*req.DecimalPlaces) {
+		return ErrInvalidDecimalPlaces
+	}
+
+	return validateValueLabels(req.ValueLabels)
+}
+
+// Validate validates and normalizes UpdateSensorTypeRequest; the range is
+// checked against the stored bounds by the service
+func (req *UpdateSensorTypeRequest) Validate() error {
+	if req.Name != nil {
+		name := strings.ToLower(strings.TrimSpace(*req.Name))
+		if err := validateSensorTypeName(name); err != nil {
+			return err
+		}
+		req.Name = &name
+	}
+
+	if req.Unit != nil {
+		unit := strings.TrimSpace(*req.Unit)
+		if err := validateUnit(unit); err != nil {
+			return err
+		}
+		req.Unit = &unit
+	}
+
+	if req.RetentionDays != nil && *req.RetentionDays < 0 {
+		return ErrInvalidRetention
+	}
+
+	if req.DecimalPlaces != nil && !validDecimalPlaces(*req.DecimalPlaces) {
+		return ErrInvalidDecimalPlaces
+	}
+
+	return validateValueLabels(req.ValueLabels)
+}
+
+// Validate validates PurgeReadingsRequest
+func (req *PurgeReadingsRequest) Validate() error {
+	if req.Before.IsZero() || !req.Before.Before(time.Now()) {
+		return ErrInvalidPurgeCutoff
+	}
 	return nil
 }
 
@@ -200,6 +1305,89 @@ func (req *CreateSensorReadingRequest) Validate() error {
 	return nil
 }
 
+// Validate validates UpdateReadingQualityRequest
+func (r *UpdateReadingQualityRequest) Validate() error {
+	if r.Quality == nil && len(r.Metadata) == 0 {
+		return ErrNoReadingChanges
+	}
+
+	if r.Quality != nil && (*r.Quality < 0 || *r.Quality > 100) {
+		return ErrInvalidQuality
+	}
+
+	if len(r.Metadata) > 0 {
+		var fields map[string]interface{}
+		if err := json.Unmarshal(r.Metadata, &fields); err != nil || fields == nil {
+			return ErrInvalidMetadata
+		}
+	}
+
+	return nil
+}
+
+// ParseAggregateInterval parses an interval such as 5m, 1h or 1d
+func ParseAggregateInterval(value string) (time.Duration, error) {
+	var interval time.Duration
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, ErrInvalidInterval
+		}
+		interval = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, ErrInvalidInterval
+		}
+		interval = d
+	}
+
+	if interval < minAggregateInterval || interval > maxAggregateInterval || interval%time.Second != 0 {
+		return 0, ErrInvalidInterval
+	}
+
+	return interval, nil
+}
+
+// ParseSmoothing parses a smoothing option such as window:5 into the moving
+// average window size
+func ParseSmoothing(value string) (int, error) {
+	size, ok := strings.CutPrefix(value, "window:")
+	if !ok {
+		return 0, ErrInvalidSmoothing
+	}
+
+	window, err := strconv.Atoi(size)
+	if err != nil || window < 1 || window > maxSmoothWindow {
+		return 0, ErrInvalidSmoothing
+	}
+
+	return window, nil
+}
+
+// Validate validates ReadingAggregateQuery
+func (q *ReadingAggregateQuery) Validate() error {
+	if q.Interval < minAggregateInterval || q.Interval > maxAggregateInterval {
+		return ErrInvalidInterval
+	}
+
+	switch q.Fn {
+	case AggregateAvg, AggregateMin, AggregateMax, AggregateSum:
+	default:
+		return ErrInvalidAggregateFn
+	}
+
+	if !q.EndTime.After(q.StartTime) {
+		return ErrInvalidTimeRange
+	}
+
+	if q.EndTime.Sub(q.StartTime)/q.Interval > maxAggregateBuckets {
+		return ErrTooManyBuckets
+	}
+
+	return nil
+}
+
 // Validate validates CreateLocationRequest
 func (req *CreateLocationRequest) Validate() error {
 	if err := validateName(req.Name); err != nil {
@@ -207,11 +1395,62 @@ func (req *CreateLocationRequest) Validate() error {
 	}
 
 	if req.Latitude != nil && (*req.Latitude < -90 || *req.Latitude > 90) {
-		return errors.New("latitude must be between -90 and 90")
+		return ErrInvalidLatitude
 	}
 
 	if req.Longitude != nil && (*req.Longitude < -180 || *req.Longitude > 180) {
-		return errors.New("longitude must be between -180 and 180")
+		return ErrInvalidLongitude
+	}
+
+	return nil
+}
+
+// Validate validates CreateSensorGroupRequest
+func (req *CreateSensorGroupRequest) Validate() error {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Description = strings.TrimSpace(req.Description)
+
+	return validateName(req.Name)
+}
+
+// Validate validates UpdateSensorGroupRequest
+func (req *UpdateSensorGroupRequest) Validate() error {
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if err := validateName(name); err != nil {
+			return err
+		}
+		req.Name = &name
+	}
+
+	if req.Description != nil {
+		description := strings.TrimSpace(*req.Description)
+		req.Description = &description
+	}
+
+	return nil
+}
+
+// Validate validates UpdateGroupMembersRequest
+func (req *UpdateGroupMembersRequest) Validate() error {
+	if len(req.Add) == 0 && len(req.Remove) == 0 {
+		return ErrNoMemberChanges
+	}
+	return nil
+}
+
+// Validate validates UpdateCalibrationRequest
+func (req *UpdateCalibrationRequest) Validate() error {
+	if req.Offset == nil && req.Scale == nil {
+		return ErrNoCalibrationChanges
+	}
+
+	if req.Offset != nil && (math.IsNaN(*req.Offset) || math.IsInf(*req.Offset, 0)) {
+		return ErrInvalidCalibration
+	}
+
+	if req.Scale != nil && (*req.Scale == 0 || math.IsNaN(*req.Scale) || math.IsInf(*req.Scale, 0)) {
+		return ErrInvalidCalibration
 	}
 
 	return nil
@@ -220,24 +1459,126 @@ func (req *CreateLocationRequest) Validate() error {
 // Validate validates UpdateLocationRequest
 func (req *UpdateLocationRequest) Validate() error {
 	if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
-		return errors.New("name cannot be empty")
+		return ErrEmptyLocationName
 	}
 
 	if req.Latitude != nil && (*req.Latitude < -90 || *req.Latitude > 90) {
-		return errors.New("latitude must be between -90 and 90")
+		return ErrInvalidLatitude
 	}
 
 	if req.Longitude != nil && (*req.Longitude < -180 || *req.Longitude > 180) {
-		return errors.New("longitude must be between -180 and 180")
+		return ErrInvalidLongitude
 	}
 
 	if req.Address != nil && len(strings.TrimSpace(*req.Address)) > 500 {
-		return errors.New("address must be less than 500 characters")
+		return ErrAddressTooLong
 	}
 
 	return nil
 }
 
+// Validate validates AssignSensorsRequest and drops duplicate IDs
+func (req *AssignSensorsRequest) Validate() error {
+	if len(req.SensorIDs) == 0 || len(req.SensorIDs) > maxAssignSensors {
+		return ErrInvalidSensorIDs
+	}
+
+	seen := make(map[int]bool, len(req.SensorIDs))
+	sensorIDs := make([]int, 0, len(req.SensorIDs))
+	for _, id := range req.SensorIDs {
+		if id <= 0 {
+			return ErrInvalidSensorIDs
+		}
+		if !seen[id] {
+			seen[id] = true
+			sensorIDs = append(sensorIDs, id)
+		}
+	}
+	req.SensorIDs = sensorIDs
+
+	return nil
+}
+
+// commandTypeRegex matches the accepted command types
+var commandTypeRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{1,50}$`)
+
+// Validate validates SendCommandRequest; a missing payload is sent as an
+// empty object
+func (req *SendCommandRequest) Validate() error {
+	req.CommandType = strings.TrimSpace(req.CommandType)
+	if !commandTypeRegex.MatchString(req.CommandType) {
+		return ErrInvalidCommandType
+	}
+
+	if len(req.Payload) == 0 || string(req.Payload) == "null" {
+		req.Payload = json.RawMessage(`{}`)
+	}
+	if len(req.Payload) > maxCommandPayloadBytes {
+		return ErrCommandTooLarge
+	}
+
+	return nil
+}
+
+// commandUUIDRegex matches the UUIDs commands are published with
+var commandUUIDRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// Validate validates CommandAck; an empty or null response is dropped
+func (ack *CommandAck) Validate() error {
+	if !commandUUIDRegex.MatchString(ack.CommandUUID) {
+		return ErrInvalidCommandAck
+	}
+	if ack.Status != CommandStatusAcknowledged && ack.Status != CommandStatusFailed {
+		return ErrInvalidCommandAck
+	}
+
+	if string(ack.Response) == "null" {
+		ack.Response = nil
+	}
+	if len(ack.Response) > maxCommandPayloadBytes {
+		return ErrCommandTooLarge
+	}
+
+	return nil
+}
+
+// Validate validates SetMaintenanceRequest; a reason is required to start
+// maintenance
+func (req *SetMaintenanceRequest) Validate() error {
+	req.Reason = strings.TrimSpace(req.Reason)
+	if len(req.Reason) > 500 {
+		return ErrInvalidReason
+	}
+
+	if req.Until == nil {
+		return nil
+	}
+
+	now := time.Now()
+	if !req.Until.After(now) || req.Until.After(now.AddDate(0, 0, maxMaintenanceDays)) {
+		return ErrInvalidMaintenance
+	}
+	if req.Reason == "" {
+		return ErrInvalidReason
+	}
+
+	return nil
+}
+
+// Validate validates CreateSensorNoteRequest
+func (req *CreateSensorNoteRequest) Validate() error {
+	req.Body = strings.TrimSpace(req.Body)
+	if req.Body == "" || len(req.Body) > maxNoteLength {
+		return ErrInvalidNote
+	}
+	return nil
+}
+
+// Calibrate corrects a raw value with the sensor's calibration
+func (s *Sensor) Calibrate(raw float64) float64 {
+	return raw*s.CalibrationScale + s.CalibrationOffset
+}
+
 // ValidateValue validates sensor reading value against sensor type constraints
 func (s *Sensor) ValidateValue(value float64) error {
 	if s.SensorType == nil {
@@ -255,13 +1596,106 @@ func (s *Sensor) ValidateValue(value float64) error {
 	return nil
 }
 
+// DefaultOnlineThresholdMinutes is how recent the last reading of an online
+// sensor without an expected interval is, unless configured otherwise
+const DefaultOnlineThresholdMinutes = 30
+
+// OnlineIntervalMultiplier is how many expected reporting intervals may pass
+// without a reading before a sensor is considered offline
+const OnlineIntervalMultiplier = 3
+
+// HealthPolicy weights the health checks of sensors. Each failed check
+// subtracts its penalty from a score of 100; a penalty of 0 disables the
+// check. Sensors failing any check are listed as dashboard alerts, as are
+// sensors scoring below AlertScore.
+type HealthPolicy struct {
+	OfflinePenalty         int
+	CriticalBatteryLevel   int
+	CriticalBatteryPenalty int
+	LowBatteryLevel        int
+	LowBatteryPenalty      int
+	PoorQualityLevel       int
+	PoorQualityPenalty     int
+	StaleReadingAge        time.Duration
+	StaleReadingPenalty    int
+	NoReadingsPenalty      int
+	AlertScore             int
+}
+
+// DefaultHealthPolicy returns the health checks used unless configured
+// otherwise
+func DefaultHealthPolicy() HealthPolicy {
+	return HealthPolicy{
+		OfflinePenalty:         30,
+		CriticalBatteryLevel:   20,
+		CriticalBatteryPenalty: 25,
+		LowBatteryLevel:        50,
+		LowBatteryPenalty:      10,
+		PoorQualityLevel:       80,
+		PoorQualityPenalty:     15,
+		StaleReadingAge:        2 * time.Hour,
+		StaleReadingPenalty:    15,
+		NoReadingsPenalty:      20,
+		AlertScore:             80,
+	}
+}
+
+// Validate checks that levels, penalties and the alert score are between 0
+// and 100 and that the critical battery level is below the low one
+func (p HealthPolicy) Validate() error {
+	percentages := []struct {
+		name  string
+		value int
+	}{
+		{"offline_penalty", p.OfflinePenalty},
+		{"critical_battery_level", p.CriticalBatteryLevel},
+		{"critical_battery_penalty", p.CriticalBatteryPenalty},
+		{"low_battery_level", p.LowBatteryLevel},
+		{"low_battery_penalty", p.LowBatteryPenalty},
+		{"poor_quality_level", p.PoorQualityLevel},
+		{"poor_quality_penalty", p.PoorQualityPenalty},
+		{"stale_reading_penalty", p.StaleReadingPenalty},
+		{"no_readings_penalty", p.NoReadingsPenalty},
+		{"alert_score", p.AlertScore},
+	}
+	for _, percentage := range percentages {
+		if percentage.value < 0 || percentage.value > 100 {
+			return fmt.Errorf("%s must be between 0 and 100", percentage.name)
+		}
+	}
+
+	if p.CriticalBatteryLevel > p.LowBatteryLevel {
+		return errors.New("critical_battery_level must not exceed low_battery_level")
+	}
+	if p.StaleReadingAge <= 0 {
+		return errors.New("stale_reading_minutes must be positive")
+	}
+
+	return nil
+}
+
+// InMaintenance reports whether the sensor's maintenance window is open;
+// windows end on their own once MaintenanceUntil passes
+func (s *Sensor) InMaintenance(now time.Time) bool {
+	return s.MaintenanceUntil != nil && s.MaintenanceUntil.After(now)
+}
+
+// OnlineThreshold returns how recent the last reading of the sensor must be
+// for it to be online; fallback applies when it has no expected interval
+func (s *Sensor) OnlineThreshold(fallback time.Duration) time.Duration {
+	if s.ExpectedIntervalSeconds != nil {
+		return time.Duration(*s.ExpectedIntervalSeconds*OnlineIntervalMultiplier) * time.Second
+	}
+	return fallback
+}
+
 // IsOnline checks if sensor is considered online (has recent readings)
-func (s *Sensor) IsOnline(thresholdMinutes int) bool {
+func (s *Sensor) IsOnline(fallback time.Duration) bool {
 	if s.LastReadingAt == nil {
 		return false
 	}
 
-	threshold := time.Now().Add(-time.Duration(thresholdMinutes) * time.Minute)
+	threshold := time.Now().Add(-s.OnlineThreshold(fallback))
 	return s.LastReadingAt.After(threshold)
 }
 
@@ -283,6 +1717,12 @@ func (s *Sensor) GetBatteryStatus() string {
 	}
 }
 
+// NormalizeDeviceID returns the form device IDs are stored and looked up
+// in; device IDs are case-insensitive
+func NormalizeDeviceID(deviceID string) string {
+	return strings.ToUpper(strings.TrimSpace(deviceID))
+}
+
 // NewSensor creates a new sensor with validation
 func NewSensor(req *CreateSensorRequest, createdBy int) (*Sensor, error) {
 	if err := req.Validate(); err != nil {
@@ -290,14 +1730,24 @@ func NewSensor(req *CreateSensorRequest, createdBy int) (*Sensor, error) {
 	}
 
 	sensor := &Sensor{
-		DeviceID:        strings.ToUpper(strings.TrimSpace(req.DeviceID)),
-		Name:            strings.TrimSpace(req.Name),
-		Description:     strings.TrimSpace(req.Description),
-		SensorTypeID:    req.SensorTypeID,
-		LocationID:      req.LocationID,
-		IsActive:        true,
-		FirmwareVersion: strings.TrimSpace(req.FirmwareVersion),
-		CreatedBy:       createdBy,
+		DeviceID:                NormalizeDeviceID(req.DeviceID),
+		Name:                    strings.TrimSpace(req.Name),
+		Description:             strings.TrimSpace(req.Description),
+		SensorTypeID:            req.SensorTypeID,
+		LocationID:              req.LocationID,
+		IsActive:                true,
+		IsProvisioned:           true,
+		FirmwareVersion:         strings.TrimSpace(req.FirmwareVersion),
+		ExpectedIntervalSeconds: req.ExpectedIntervalSeconds,
+		IngestRatePerMinute:     req.IngestRatePerMinute,
+		Tags:                    req.Tags,
+		Metadata:                req.Metadata,
+		CalibrationScale:        1,
+		CreatedBy:               createdBy,
+	}
+
+	if len(sensor.Metadata) == 0 {
+		sensor.Metadata = json.RawMessage(`{}`)
 	}
 
 	return sensor, nil
@@ -337,6 +1787,51 @@ func validateDeviceID(deviceID string) error {
 	return nil
 }
 
+func validateSensorTypeName(name string) error {
+	if len(name) < 2 || len(name) > 100 {
+		return ErrInvalidTypeName
+	}
+	return nil
+}
+
+func validateUnit(unit string) error {
+	if unit == "" || len(unit) > 20 {
+		return ErrInvalidUnit
+	}
+	return nil
+}
+
+// Value formatting limits of sensor types
+const (
+	defaultDecimalPlaces = 2
+	maxDecimalPlaces     = 6
+	maxValueLabels       = 20
+	maxValueLabelLength  = 50
+)
+
+// validDecimalPlaces checks the precision of formatted values
+func validDecimalPlaces(places int) bool {
+	return places >= 0 && places <= maxDecimalPlaces
+}
+
+// validateValueLabels checks that labels are keyed by numbers and short
+func validateValueLabels(labels map[string]string) error {
+	if len(labels) > maxValueLabels {
+		return ErrInvalidValueLabels
+	}
+
+	for value, label := range labels {
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return ErrInvalidValueLabels
+		}
+		if strings.TrimSpace(label) == "" || len(label) > maxValueLabelLength {
+			return ErrInvalidValueLabels
+		}
+	}
+
+	return nil
+}
+
 func validateName(name string) error {
 	name = strings.TrimSpace(name)
 	if name == "" {
@@ -351,21 +1846,67 @@ func validateName(name string) error {
 	return nil
 }
 
-// FormatValue formats sensor value with appropriate precision based on type
+// FormatValue formats sensor value with the type's decimal places and unit,
+// or as its label when the type has value labels
 func (st *SensorType) FormatValue(value float64) string {
-	switch st.Name {
-	case "temperature":
-		return fmt.Sprintf("%.1f %s", value, st.Unit)
-	case "humidity":
-		return fmt.Sprintf("%.0f %s", value, st.Unit)
-	case "pressure":
-		return fmt.Sprintf("%.1f %s", value, st.Unit)
-	case "motion":
-		if value > 0 {
-			return "Motion detected"
+	if label, ok := st.valueLabel(value); ok {
+		return label
+	}
+	return fmt.Sprintf("%s %s", strconv.FormatFloat(value, 'f', st.DecimalPlaces, 64), st.Unit)
+}
+
+// valueLabel returns the label of the lowest key at or above value, or of
+// the highest key when value is above them all, so fractional values keep
+// the label of the step they fall in (any motion above 0 is "Motion
+// detected")
+func (st *SensorType) valueLabel(value float64) (string, bool) {
+	var ceiling, highest string
+	var ceilingKey, highestKey float64
+	for key, label := range st.ValueLabels {
+		k, err := strconv.ParseFloat(key, 64)
+		if err != nil {
+			continue
 		}
-		return "No motion"
-	default:
-		return fmt.Sprintf("%.2f %s", value, st.Unit)
+		if k >= value && (ceiling == "" || k < ceilingKey) {
+			ceiling, ceilingKey = label, k
+		}
+		if highest == "" || k > highestKey {
+			highest, highestKey = label, k
+		}
+	}
+
+	if ceiling != "" {
+		return ceiling, true
 	}
+	return highest, highest != ""
+}
+
+// generateDeviceToken returns a random hex encoded device token and its hash
+func generateDeviceToken() (token, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+
+	token = hex.EncodeToString(buf)
+	return token, hashDeviceToken(token), nil
+}
+
+// newCommandUUID returns a random (version 4) UUID identifying a command
+func newCommandUUID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	buf[6] = buf[6]&0x0f | 0x40
+	buf[8] = buf[8]&0x3f | 0x80
+
+	h := hex.EncodeToString(buf)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32], nil
+}
+
+// hashDeviceToken returns the hex encoded SHA-256 hash of a device token
+func hashDeviceToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
@@ -0,0 +1,152 @@
+package sensor
+
+import (
+	"net/http"
+	"user-management/shared/openapi"
+)
+
+// sensorQuery are the query parameters of sensor lists
+var sensorQuery = []string{
+	"q", "tag", "sensor_type_id", "location_id", "is_active", "include_inactive",
+	"online", "mine", "sort", "order", "page", "per_page",
+}
+
+// Operations documents the sensor routes in the OpenAPI document
+var Operations = []openapi.Operation{
+	// Device ingestion
+	{Pattern: "POST /api/sensors/readings", Tag: "readings", Summary: "Submit a sensor reading", Headers: []string{DeviceTokenHeader},
+		Request: CreateSensorReadingRequest{}, Response: &SensorReading{}, Status: http.StatusCreated},
+	{Pattern: "POST /api/sensors/readings/bulk", Tag: "readings", Summary: "Submit a batch of sensor readings; partial batches answer 207", Headers: []string{DeviceTokenHeader},
+		Request: BulkSensorReadingRequest{}, Response: &BulkReadingResult{}},
+
+	// Sensors
+	{Pattern: "GET /api/sensors/dashboard", Tag: "sensors", Summary: "Get dashboard data", Access: "sensors:read",
+		Response: &DashboardData{}},
+	{Pattern: "GET /api/sensors", Tag: "sensors", Summary: "List sensors", Access: "sensors:read",
+		Query: sensorQuery, Response: []*Sensor{}, Paginated: true},
+	{Pattern: "GET /api/sensors/tags", Tag: "sensors", Summary: "List sensor tags", Access: "sensors:read",
+		Response: []*SensorTag{}},
+	{Pattern: "GET /api/sensors/firmware-report", Tag: "sensors", Summary: "Count sensors by firmware version", Access: "sensors:read",
+		Response: []*FirmwareVersionCount{}},
+	{Pattern: "GET /api/sensors/{id}", Tag: "sensors", Summary: "Get a sensor", Access: "sensors:read",
+		Query: []string{"unit"}, Response: &Sensor{}},
+	{Pattern: "GET /api/sensors/device/{device_id}", Tag: "sensors", Summary: "Get a sensor by device ID", Access: "sensors:read",
+		Query: []string{"unit"}, Response: &Sensor{}},
+	{Pattern: "GET /api/sensors/health", Tag: "sensors", Summary: "Get the sensor health report", Access: "sensors:read",
+		Query: []string{"issue", "location_id", "min_score", "max_score", "sort", "order", "page", "per_page"}, Response: &SensorHealthReport{}, Paginated: true},
+	{Pattern: "POST /api/sensors", Tag: "sensors", Summary: "Create a sensor", Access: "sensors:write",
+		Request: CreateSensorRequest{}, Response: &Sensor{}, Status: http.StatusCreated},
+	{Pattern: "PUT /api/sensors/{id}", Tag: "sensors", Summary: "Update a sensor", Access: "sensors:write",
+		Request: UpdateSensorRequest{}, Response: &Sensor{}},
+	{Pattern: "DELETE /api/sensors/{id}", Tag: "sensors", Summary: "Delete a sensor", Access: "sensors:delete",
+		Query: []string{"purge"}},
+	{Pattern: "POST /api/sensors/{id}/activate", Tag: "sensors", Summary: "Activate a sensor", Access: "sensors:write",
+		Response: &Sensor{}},
+	{Pattern: "POST /api/sensors/{id}/clone", Tag: "sensors", Summary: "Clone a sensor", Access: "sensors:write",
+		Request: CloneSensorRequest{}, Response: &Sensor{}, Status: http.StatusCreated},
+	{Pattern: "PUT /api/sensors/{id}/calibration", Tag: "sensors", Summary: "Update the calibration of a sensor", Access: "sensors:write",
+		Request: UpdateCalibrationRequest{}, Response: &SensorCalibration{}},
+	{Pattern: "PUT /api/sensors/{id}/maintenance", Tag: "sensors", Summary: "Set the maintenance window of a sensor", Access: "sensors:write",
+		Request: SetMaintenanceRequest{}, Response: &Sensor{}},
+	{Pattern: "POST /api/sensors/{id}/notes", Tag: "sensors", Summary: "Add a note to a sensor", Access: "sensors:write",
+		Request: CreateSensorNoteRequest{}, Response: &SensorNote{}, Status: http.StatusCreated},
+	{Pattern: "DELETE /api/sensors/{id}/notes/{note_id}", Tag: "sensors", Summary: "Delete a sensor note", Access: "sensors:write"},
+	{Pattern: "POST /api/sensors/{id}/commands", Tag: "sensors", Summary: "Send a command to a sensor", Access: "sensors:write",
+		Request: SendCommandRequest{}, Response: &SensorCommand{}, Status: http.StatusCreated},
+
+	// Sensor collections, dispatched by "GET /api/sensors/{id}/{collection}"
+	{Pattern: "GET /api/sensors/{id}/status-history", Route: "GET /api/sensors/{id}/{collection}", Tag: "sensors", Summary: "List the status changes of a sensor", Access: "sensors:read",
+		Query: []string{"page", "per_page"}, Response: []*SensorStatusEvent{}, Paginated: true},
+	{Pattern: "GET /api/sensors/{id}/availability", Route: "GET /api/sensors/{id}/{collection}", Tag: "analytics", Summary: "Get the availability of a sensor", Access: "analytics:read",
+		Query: []string{"start_time", "end_time"}, Response: &SensorAvailability{}},
+	{Pattern: "GET /api/sensors/{id}/calibrations", Route: "GET /api/sensors/{id}/{collection}", Tag: "sensors", Summary: "List the calibrations of a sensor", Access: "sensors:read",
+		Response: []*SensorCalibration{}},
+	{Pattern: "GET /api/sensors/{id}/anomalies", Route: "GET /api/sensors/{id}/{collection}", Tag: "analytics", Summary: "Detect anomalous readings of a sensor", Access: "analytics:read",
+		Query: []string{"start_time", "end_time", "sigma"}, Response: &AnomalyReport{}},
+	{Pattern: "GET /api/sensors/{id}/gaps", Route: "GET /api/sensors/{id}/{collection}", Tag: "analytics", Summary: "List the reading gaps of a sensor", Access: "analytics:read",
+		Query: []string{"start_time", "end_time", "min_gap"}, Response: &GapReport{}},
+	{Pattern: "GET /api/sensors/{id}/commands", Route: "GET /api/sensors/{id}/{collection}", Tag: "sensors", Summary: "List the commands sent to a sensor", Access: "sensors:read",
+		Query: []string{"page", "per_page"}, Response: []*SensorCommand{}, Paginated: true},
+	{Pattern: "GET /api/sensors/{id}/firmware-history", Route: "GET /api/sensors/{id}/{collection}", Tag: "sensors", Summary: "List the firmware changes of a sensor", Access: "sensors:read",
+		Response: []*FirmwareChange{}},
+	{Pattern: "GET /api/sensors/{id}/notes", Route: "GET /api/sensors/{id}/{collection}", Tag: "sensors", Summary: "List the notes of a sensor", Access: "sensors:read",
+		Query: []string{"page", "per_page"}, Response: []*SensorNote{}, Paginated: true},
+
+	// Readings
+	{Pattern: "GET /api/sensors/readings", Tag: "readings", Summary: "List sensor readings", Access: "sensor_readings:read",
+		Query:    []string{"sensor_id", "start_time", "end_time", "min_value", "max_value", "min_quality", "unit", "raw", "smooth", "order", "limit", "offset"},
+		Response: []*SensorReading{}, Paginated: true},
+	{Pattern: "GET /api/sensors/readings/{id}", Tag: "readings", Summary: "Get a sensor reading", Access: "sensor_readings:read",
+		Query: []string{"unit", "raw"}, Response: &SensorReading{}},
+	{Pattern: "PATCH /api/sensors/readings/{id}", Tag: "readings", Summary: "Update the quality of a sensor reading", Access: "sensor_readings:write",
+		Request: UpdateReadingQualityRequest{}, Response: &SensorReading{}},
+	{Pattern: "DELETE /api/sensors/{id}/readings", Route: "DELETE /api/sensors/{id}/{collection}", Tag: "readings", Summary: "Delete the readings of a sensor", Access: "sensor_readings:delete",
+		Query: []string{"start_time", "end_time"}, Response: map[string]int64{}},
+	{Pattern: "GET /api/sensors/{id}/readings/aggregate", Tag: "readings", Summary: "Aggregate the readings of a sensor", Access: "sensor_readings:read",
+		Query: []string{"interval", "fn", "fill", "start_time", "end_time"}, Response: []*ReadingBucket{}},
+	{Pattern: "POST /api/sensors/readings/purge", Tag: "readings", Summary: "Purge old sensor readings; admins only", Access: "analytics:delete",
+		Request: PurgeReadingsRequest{}, Response: &PurgeReadingsResult{}},
+
+	// Analytics
+	{Pattern: "GET /api/sensors/statistics", Tag: "analytics", Summary: "Get sensor statistics", Access: "analytics:read",
+		Query: []string{"sensor_id", "start_time", "end_time", "unit", "compare"}, Response: &SensorStatistics{}},
+	{Pattern: "GET /api/sensors/{id}/statistics/daily", Tag: "analytics", Summary: "Get the daily statistics of a sensor", Access: "analytics:read",
+		Query: []string{"start", "end", "start_time", "end_time", "tz"}, Response: []*DailyStatistics{}},
+
+	// Access grants and provisioning
+	{Pattern: "GET /api/sensors/access", Tag: "sensors", Summary: "List sensor access grants", Access: openapi.AccessAdmin,
+		Query: []string{"sensor_id", "location_id"}, Response: []*SensorAccess{}},
+	{Pattern: "POST /api/sensors/access", Tag: "sensors", Summary: "Grant access to sensors", Access: openapi.AccessAdmin,
+		Request: CreateSensorAccessRequest{}, Response: &SensorAccess{}, Status: http.StatusCreated},
+	{Pattern: "DELETE /api/sensors/access/{id}", Tag: "sensors", Summary: "Revoke a sensor access grant", Access: openapi.AccessAdmin},
+	{Pattern: "GET /api/sensors/pending", Tag: "sensors", Summary: "List auto-provisioned sensors awaiting approval", Access: openapi.AccessAdmin,
+		Query: []string{"page", "per_page"}, Response: []*Sensor{}, Paginated: true},
+	{Pattern: "POST /api/sensors/{id}/approve", Tag: "sensors", Summary: "Approve an auto-provisioned sensor", Access: openapi.AccessAdmin,
+		Request: ApproveSensorRequest{}, Response: &Sensor{}},
+	{Pattern: "POST /api/sensors/{id}/rotate-token", Tag: "sensors", Summary: "Rotate the device token of a sensor", Access: openapi.AccessAdmin,
+		Response: &Sensor{}},
+
+	// Sensor types
+	{Pattern: "GET /api/sensor-types", Tag: "sensor-types", Summary: "List sensor types", Access: "sensors:read",
+		Response: []*SensorType{}},
+	{Pattern: "GET /api/sensor-types/{id}", Tag: "sensor-types", Summary: "Get a sensor type", Access: "sensors:read",
+		Response: &SensorType{}},
+	{Pattern: "POST /api/sensor-types", Tag: "sensor-types", Summary: "Create a sensor type", Access: openapi.AccessSuperAdmin,
+		Request: CreateSensorTypeRequest{}, Response: &SensorType{}, Status: http.StatusCreated},
+	{Pattern: "PUT /api/sensor-types/{id}", Tag: "sensor-types", Summary: "Update a sensor type", Access: openapi.AccessSuperAdmin,
+		Request: UpdateSensorTypeRequest{}, Response: &SensorType{}},
+	{Pattern: "DELETE /api/sensor-types/{id}", Tag: "sensor-types", Summary: "Delete a sensor type", Access: openapi.AccessSuperAdmin},
+
+	// Locations
+	{Pattern: "GET /api/locations", Tag: "locations", Summary: "List locations; format=geojson returns a GeoJSON feature collection", Access: "sensors:read",
+		Query: []string{"format"}, Response: []*Location{}},
+	{Pattern: "GET /api/locations/{id}", Tag: "locations", Summary: "Get a location", Access: "sensors:read",
+		Response: &Location{}},
+	{Pattern: "GET /api/locations/sensors", Tag: "locations", Summary: "Count sensors by location", Access: "sensors:read",
+		Query: []string{"location_id"}, Response: &LocationSummary{}},
+	{Pattern: "GET /api/locations/nearby", Tag: "locations", Summary: "List locations near a point", Access: "sensors:read",
+		Query: []string{"lat", "lng", "radius_km"}, Response: &NearbyLocations{}},
+	{Pattern: "POST /api/locations", Tag: "locations", Summary: "Create a location", Access: "sensors:write",
+		Request: CreateLocationRequest{}, Response: &Location{}, Status: http.StatusCreated},
+	{Pattern: "PUT /api/locations/{id}", Tag: "locations", Summary: "Update a location", Access: "sensors:write",
+		Request: UpdateLocationRequest{}, Response: &Location{}},
+	{Pattern: "DELETE /api/locations/{id}", Tag: "locations", Summary: "Delete a location", Access: "sensors:delete",
+		Query: []string{"force"}, Response: map[string]int{}},
+	{Pattern: "POST /api/locations/{id}/assign-sensors", Tag: "locations", Summary: "Assign sensors to a location", Access: "sensors:write",
+		Request: AssignSensorsRequest{}, Response: &LocationAssignment{}},
+
+	// Sensor groups
+	{Pattern: "GET /api/sensor-groups", Tag: "sensor-groups", Summary: "List sensor groups", Access: "sensors:read",
+		Response: []*SensorGroup{}},
+	{Pattern: "GET /api/sensor-groups/{id}", Tag: "sensor-groups", Summary: "Get a sensor group", Access: "sensors:read",
+		Response: &SensorGroup{}},
+	{Pattern: "GET /api/sensor-groups/{id}/summary", Tag: "sensor-groups", Summary: "Summarize the sensors of a group", Access: "sensors:read",
+		Response: &GroupSummary{}},
+	{Pattern: "POST /api/sensor-groups", Tag: "sensor-groups", Summary: "Create a sensor group", Access: "sensors:write",
+		Request: CreateSensorGroupRequest{}, Response: &SensorGroup{}, Status: http.StatusCreated},
+	{Pattern: "PUT /api/sensor-groups/{id}", Tag: "sensor-groups", Summary: "Update a sensor group", Access: "sensors:write",
+		Request: UpdateSensorGroupRequest{}, Response: &SensorGroup{}},
+	{Pattern: "POST /api/sensor-groups/{id}/sensors", Tag: "sensor-groups", Summary: "Update the sensors of a group", Access: "sensors:write",
+		Request: UpdateGroupMembersRequest{}, Response: &SensorGroup{}},
+	{Pattern: "DELETE /api/sensor-groups/{id}", Tag: "sensor-groups", Summary: "Delete a sensor group", Access: "sensors:delete"},
+}
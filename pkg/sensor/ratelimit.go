@@ -0,0 +1,95 @@
+package sensor
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// ingestSweepInterval is how often buckets of sensors that went quiet are dropped
+const ingestSweepInterval = 10 * time.Minute
+
+// ingestBucket is the token bucket of one sensor
+type ingestBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// ingestLimiter limits how often each sensor can send readings with an
+// in-memory token bucket per sensor. Buckets refill at the sensor's
+// IngestRatePerMinute, or the configured rate when it has no override, and
+// hold the configured burst or one second of readings, whichever is larger.
+type ingestLimiter struct {
+	perMinute int
+	burst     int
+
+	mu        sync.Mutex
+	buckets   map[int]*ingestBucket
+	lastSweep time.Time
+}
+
+// newIngestLimiter creates an ingest limiter; a perMinute of 0 only limits
+// sensors with an override
+func newIngestLimiter(perMinute, burst int) *ingestLimiter {
+	return &ingestLimiter{
+		perMinute: perMinute,
+		burst:     burst,
+		buckets:   make(map[int]*ingestBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// allow takes a token from the sensor's bucket. When the bucket is empty it
+// returns false and how long until the next token.
+func (l *ingestLimiter) allow(sensor *Sensor, now time.Time) (bool, time.Duration) {
+	perMinute := l.perMinute
+	if sensor.IngestRatePerMinute != nil {
+		perMinute = *sensor.IngestRatePerMinute
+	}
+	if perMinute <= 0 {
+		return true, 0
+	}
+
+	rate := float64(perMinute) / 60
+	capacity := l.capacity(rate)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= ingestSweepInterval {
+		l.sweep(now)
+	}
+
+	bucket, ok := l.buckets[sensor.ID]
+	if !ok {
+		bucket = &ingestBucket{tokens: capacity, last: now}
+		l.buckets[sensor.ID] = bucket
+	}
+
+	bucket.tokens = math.Min(capacity, bucket.tokens+now.Sub(bucket.last).Seconds()*rate)
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		wait := time.Duration((1 - bucket.tokens) / rate * float64(time.Second))
+		return false, wait
+	}
+
+	bucket.tokens--
+	return true, 0
+}
+
+// capacity returns the bucket size for a refill rate in tokens per second
+func (l *ingestLimiter) capacity(rate float64) float64 {
+	return math.Max(float64(l.burst), math.Max(1, math.Ceil(rate)))
+}
+
+// sweep drops the buckets that have been idle for a whole sweep interval.
+// Callers must hold l.mu.
+func (l *ingestLimiter) sweep(now time.Time) {
+	for sensorID, bucket := range l.buckets {
+		if now.Sub(bucket.last) >= ingestSweepInterval {
+			delete(l.buckets, sensorID)
+		}
+	}
+	l.lastSweep = now
+}
@@ -1,48 +1,130 @@
 package sensor
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
+	"user-management/database"
+	"user-management/shared/interfaces"
+
+	"github.com/lib/pq"
 )
 
 // Repository defines sensor repository interface
 type Repository interface {
 	// Sensor CRUD operations
-	CreateSensor(sensor *Sensor) error
-	GetSensorByID(id int) (*Sensor, error)
-	GetSensorByDeviceID(deviceID string) (*Sensor, error)
-	UpdateSensor(id int, req *UpdateSensorRequest) (*Sensor, error)
-	DeleteSensor(id int) error
-	ListSensors(limit, offset int) ([]*Sensor, int, error)
-	ListSensorsByLocation(locationID int) ([]*Sensor, error)
+	CreateSensor(ctx context.Context, sensor *Sensor) error
+	GetSensorByID(ctx context.Context, id int) (*Sensor, error)
+	GetSensorByDeviceID(ctx context.Context, deviceID string) (*Sensor, error)
+	UpdateSensor(ctx context.Context, id int, req *UpdateSensorRequest) (*Sensor, error)
+	DeleteSensor(ctx context.Context, id int) error
+	ActivateSensor(ctx context.Context, id int) error
+	ApproveSensor(ctx context.Context, id int, req *ApproveSensorRequest, tokenHash string) error
+	UpdateDeviceTokenHash(ctx context.Context, id int, hash string) error
+	CountSensorsWithDeviceToken(ctx context.Context, sensorIDs []int, hash string) (int, error)
+	PurgeSensor(ctx context.Context, id int) (int64, error)
+	ListSensors(ctx context.Context, filter *SensorFilter, limit, offset int) ([]*Sensor, int, error)
+	ListSensorsByLocation(ctx context.Context, locationID int) ([]*Sensor, error)
+	ListSensorIDs(ctx context.Context) ([]int, error)
+	GetDashboardCounts(ctx context.Context, now time.Time, fallback time.Duration) (*DashboardData, error)
+	CountSensorsByLocation(ctx context.Context, now time.Time, fallback time.Duration, policy HealthPolicy) ([]*LocationSensorCount, error)
+	ListAlertCandidates(ctx context.Context, now time.Time, fallback time.Duration, policy HealthPolicy, limit int) ([]*Sensor, error)
+	ListSensorTags(ctx context.Context) ([]*SensorTag, error)
+	GetFirmwareReport(ctx context.Context) ([]*FirmwareVersionCount, error)
+	ListFirmwareHistory(ctx context.Context, sensorID int) ([]*FirmwareChange, error)
 
 	// Sensor Type operations
-	GetSensorTypeByID(id int) (*SensorType, error)
-	GetSensorTypeByName(name string) (*SensorType, error)
-	ListSensorTypes() ([]*SensorType, error)
+	GetSensorTypeByID(ctx context.Context, id int) (*SensorType, error)
+	GetSensorTypeByName(ctx context.Context, name string) (*SensorType, error)
+	ListSensorTypes(ctx context.Context) ([]*SensorType, error)
+	CreateSensorType(ctx context.Context, sensorType *SensorType) error
+	UpdateSensorType(ctx context.Context, id int, req *UpdateSensorTypeRequest) (*SensorType, error)
+	DeactivateSensorType(ctx context.Context, id int) error
 
 	// Location operations
-	CreateLocation(location *Location) error
-	GetLocationByID(id int) (*Location, error)
-	UpdateLocation(id int, req *UpdateLocationRequest) (*Location, error)
-	ListLocations() ([]*Location, error)
+	CreateLocation(ctx context.Context, location *Location) error
+	GetLocationByID(ctx context.Context, id int) (*Location, error)
+	UpdateLocation(ctx context.Context, id int, req *UpdateLocationRequest) (*Location, error)
+	ListLocations(ctx context.Context) ([]*Location, error)
+	ListLocationsNear(ctx context.Context, lat, lng, radiusKm float64) (*NearbyLocations, error)
+	DeactivateLocation(ctx context.Context, id int, force bool) (int, error)
+	AssignSensorsToLocation(ctx context.Context, locationID int, sensorIDs []int) (*LocationAssignment, error)
+
+	// Sensor group operations
+	CreateSensorGroup(ctx context.Context, group *SensorGroup) error
+	GetSensorGroupByID(ctx context.Context, id int) (*SensorGroup, error)
+	ListSensorGroups(ctx context.Context) ([]*SensorGroup, error)
+	UpdateSensorGroup(ctx context.Context, id int, req *UpdateSensorGroupRequest) (*SensorGroup, error)
+	DeleteSensorGroup(ctx context.Context, id int) error
+	UpdateGroupMembers(ctx context.Context, groupID int, add, remove []int) error
+	ListSensorsByGroup(ctx context.Context, groupID int) ([]*Sensor, error)
+
+	// Calibration operations
+	UpdateCalibration(ctx context.Context, sensorID int, req *UpdateCalibrationRequest, changedBy int) (*SensorCalibration, error)
+	SetMaintenance(ctx context.Context, sensorID int, req *SetMaintenanceRequest, setBy int) (*SensorMaintenance, error)
+
+	// Note operations
+	CreateSensorNote(ctx context.Context, sensorID int, body string, authorID int) (*SensorNote, error)
+	GetSensorNote(ctx context.Context, sensorID int, noteID int64) (*SensorNote, error)
+	ListSensorNotes(ctx context.Context, sensorID, limit, offset int) ([]*SensorNote, int, error)
+	DeleteSensorNote(ctx context.Context, noteID int64) error
+	ListCalibrations(ctx context.Context, sensorID int) ([]*SensorCalibration, error)
+
+	// Device command operations
+	CreateSensorCommand(ctx context.Context, command *SensorCommand) (*SensorCommand, error)
+	UpdateCommandStatus(ctx context.Context, command *SensorCommand, status string) error
+	AcknowledgeCommand(ctx context.Context, deviceID string, ack *CommandAck) (*SensorCommand, error)
+	ListSensorCommands(ctx context.Context, sensorID, limit, offset int) ([]*SensorCommand, int, error)
+	TimeOutCommands(ctx context.Context, before time.Time) (int64, error)
 
 	// Sensor Reading operations
-	CreateSensorReading(reading *SensorReading) error
-	CreateBulkSensorReadings(readings []*SensorReading) error
-	GetSensorReadings(query *SensorReadingQuery) ([]*SensorReading, int, error)
-	GetLatestReading(sensorID int) (*SensorReading, error)
-	GetSensorStatistics(sensorID int, startTime, endTime time.Time) (*SensorStatistics, error)
+	CreateSensorReading(ctx context.Context, reading *SensorReading) error
+	CreateBulkSensorReadings(ctx context.Context, readings []*SensorReading) (map[int]int, error)
+	GetSensorReadings(ctx context.Context, query *SensorReadingQuery) ([]*SensorReading, int, error)
+	GetLatestReading(ctx context.Context, sensorID int) (*SensorReading, error)
+	GetReadingByID(ctx context.Context, id int64) (*SensorReading, error)
+	UpdateReadingQuality(ctx context.Context, id int64, req *UpdateReadingQualityRequest, editedBy int) (*SensorReading, error)
+	GetSensorStatistics(ctx context.Context, sensorID int, startTime, endTime time.Time) (*SensorStatistics, error)
+	GetAggregatedReadings(ctx context.Context, sensorID int, query *ReadingAggregateQuery) ([]*ReadingBucket, error)
+	GetDailyStatistics(ctx context.Context, sensorID int, startTime, endTime time.Time, timezone string) ([]*DailyStatistics, error)
+	ListReadingGaps(ctx context.Context, sensorID int, startTime, endTime time.Time, minGap time.Duration, limit int) (*GapReport, error)
+	GetValueSpread(ctx context.Context, sensorID int, startTime, endTime time.Time) (count int64, mean, stddev *float64, err error)
+	ListReadingsOutside(ctx context.Context, sensorID int, startTime, endTime time.Time, lower, upper float64, limit int) ([]*SensorReading, error)
+	GetReadingGaps(ctx context.Context, sensorID int, startTime, endTime time.Time, threshold time.Duration) (*SensorAvailability, error)
+	CountReadingsBefore(ctx context.Context, sensorID *int, cutoff time.Time) (int64, error)
+	DeleteReadingsBefore(ctx context.Context, sensorID *int, cutoff time.Time) (int64, error)
+	DeleteReadingsInRange(ctx context.Context, sensorID int, startTime, endTime time.Time) (int64, error)
+	DeleteExpiredReadings(ctx context.Context, defaultRetentionDays int) (int64, error)
 
 	// Update sensor last reading timestamp
-	UpdateSensorLastReading(sensorID int, timestamp time.Time) error
+	UpdateSensorLastReading(ctx context.Context, sensorID int, timestamp time.Time) error
+
+	// Sensor status transitions
+	RecordStatusTransitions(ctx context.Context, now time.Time, fallback time.Duration) ([]*SensorStatusEvent, error)
+	ListStatusEvents(ctx context.Context, sensorID, limit, offset int) ([]*SensorStatusEvent, int, error)
+
+	// Sensor access grants
+	CreateSensorAccess(ctx context.Context, access *SensorAccess) error
+	ListSensorAccess(ctx context.Context, sensorID, locationID *int) ([]*SensorAccess, error)
+	DeleteSensorAccess(ctx context.Context, id int) error
+
+	// WithScope returns a repository limited to the organization in scope
+	WithScope(scope interfaces.Scope) Repository
+
+	// WithAccess returns a repository limited to the sensors the user can access
+	WithAccess(access Access) Repository
 }
 
 // repository implements Repository interface
 type repository struct {
-	db *sql.DB
+	db     *sql.DB
+	scope  interfaces.Scope
+	access Access
 }
 
 // NewRepository creates a new sensor repository
@@ -50,25 +132,94 @@ func NewRepository(db *sql.DB) Repository {
 	return &repository{db: db}
 }
 
+// WithScope returns a copy of the repository limited to the scope
+func (r *repository) WithScope(scope interfaces.Scope) Repository {
+	return &repository{db: r.db, scope: scope, access: r.access}
+}
+
+// WithAccess returns a copy of the repository limited to the sensors the
+// user can access
+func (r *repository) WithAccess(access Access) Repository {
+	return &repository{db: r.db, scope: r.scope, access: access}
+}
+
+// orgFilter appends the scoped organization to args and returns an AND
+// condition on column, or "" when the repository is unrestricted
+func (r *repository) orgFilter(column string, args []interface{}) (string, []interface{}) {
+	if !r.scope.Restricted {
+		return "", args
+	}
+	args = append(args, r.scope.OrganizationID)
+	return fmt.Sprintf(" AND %s = $%d", column, len(args)), args
+}
+
+// accessFilter appends the accessing user to args and returns an AND
+// condition limiting the sensors aliased as alias to those the user can
+// access at level, or "" when the repository is unrestricted. A sensor
+// without grants on it or its location is open to everyone; otherwise only
+// its creator and the users and roles granted access can reach it.
+func (r *repository) accessFilter(alias, level string, args []interface{}) (string, []interface{}) {
+	if !r.access.Restricted {
+		return "", args
+	}
+
+	roleIDs := make([]int64, len(r.access.RoleIDs))
+	for i, id := range r.access.RoleIDs {
+		roleIDs[i] = int64(id)
+	}
+	args = append(args, r.access.UserID, pq.Array(roleIDs))
+	userArg, rolesArg := len(args)-1, len(args)
+
+	levelClause := ""
+	if level == AccessLevelWrite {
+		levelClause = fmt.Sprintf(" AND sa.access_level = '%s'", AccessLevelWrite)
+	}
+
+	return fmt.Sprintf(`
+		  AND (%[1]s.created_by = $%[3]d
+		       OR NOT EXISTS (
+		           SELECT 1 FROM %[2]s.sensor_access sa
+		           WHERE sa.sensor_id = %[1]s.id OR sa.location_id = %[1]s.location_id)
+		       OR EXISTS (
+		           SELECT 1 FROM %[2]s.sensor_access sa
+		           WHERE (sa.sensor_id = %[1]s.id OR sa.location_id = %[1]s.location_id)
+		             AND (sa.user_id = $%[3]d OR sa.role_id = ANY($%[4]d))%[5]s))`,
+		alias, schema, userArg, rolesArg, levelClause), args
+}
+
+// organizationID returns the organization new records are created in
+func (r *repository) organizationID() int {
+	if r.scope.OrganizationID == 0 {
+		return interfaces.DefaultOrganizationID
+	}
+	return r.scope.OrganizationID
+}
+
 // Schema name constant
 const schema = "sensor_data"
 
 // CreateSensor creates a new sensor
-func (r *repository) CreateSensor(sensor *Sensor) error {
+func (r *repository) CreateSensor(ctx context.Context, sensor *Sensor) error {
 	query := fmt.Sprintf(`
 		INSERT INTO %s.sensors (device_id, name, description, sensor_type_id, location_id, 
-		                       is_active, firmware_version, created_by)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		                       organization_id, is_active, is_provisioned, firmware_version, expected_interval_seconds,
+		                       ingest_rate_per_minute, tags, metadata, calibration_offset, calibration_scale,
+		                       device_token_hash, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, NULLIF($16, ''), NULLIF($17, 0))
 		RETURNING id, created_at, updated_at
 	`, schema)
 
-	err := r.db.QueryRow(query,
+	sensor.OrganizationID = r.organizationID()
+
+	err := r.db.QueryRowContext(ctx, query,
 		sensor.DeviceID, sensor.Name, sensor.Description, sensor.SensorTypeID,
-		sensor.LocationID, sensor.IsActive, sensor.FirmwareVersion, sensor.CreatedBy).
+		sensor.LocationID, sensor.OrganizationID, sensor.IsActive, sensor.IsProvisioned, sensor.FirmwareVersion,
+		sensor.ExpectedIntervalSeconds, sensor.IngestRatePerMinute, pq.Array(sensor.Tags), string(sensor.Metadata),
+		sensor.CalibrationOffset, sensor.CalibrationScale, sensor.DeviceTokenHash, sensor.CreatedBy).
 		Scan(&sensor.ID, &sensor.CreatedAt, &sensor.UpdatedAt)
 
 	if err != nil {
-		if strings.Contains(err.Error(), "duplicate key") {
+		if database.IsUniqueViolation(err) {
 			return ErrDeviceIDExists
 		}
 		return fmt.Errorf("failed to create sensor: %w", err)
@@ -77,22 +228,31 @@ func (r *repository) CreateSensor(sensor *Sensor) error {
 	return nil
 }
 
-// GetSensorByID retrieves sensor by ID with related data
-func (r *repository) GetSensorByID(id int) (*Sensor, error) {
-	query := fmt.Sprintf(`
-		SELECT s.id, s.device_id, s.name, s.description, s.sensor_type_id, s.location_id,
-		       s.is_active, s.last_reading_at, s.battery_level, s.firmware_version,
-		       s.created_by, s.created_at, s.updated_at,
-		       st.id, st.name, st.description, st.unit, st.min_value, st.max_value,
-		       st.is_active, st.created_at, st.updated_at,
-		       l.id, l.name, l.description, l.latitude, l.longitude, l.address,
-		       l.is_active, l.created_at, l.updated_at
-		FROM %s.sensors s
-		INNER JOIN %s.sensor_types st ON s.sensor_type_id = st.id
-		LEFT JOIN %s.locations l ON s.location_id = l.id
-		WHERE s.id = $1
-	`, schema, schema, schema)
+// sensorColumns are the sensor columns scanned by scanSensor, followed by
+// its sensor type and location joined in with sensorJoins
+const sensorColumns = `
+	s.id, s.device_id, s.name, s.description, s.sensor_type_id, s.location_id,
+	s.organization_id, s.is_active, s.is_provisioned, s.last_reading_at, s.battery_level, s.firmware_version,
+	s.expected_interval_seconds, s.ingest_rate_per_minute, s.tags, s.metadata, s.calibration_offset, s.calibration_scale,
+	s.maintenance_until, COALESCE(s.created_by, 0), s.created_at, s.updated_at,
+	st.id, st.name, st.description, st.unit, st.min_value, st.max_value, st.retention_days,
+	st.decimal_places, COALESCE(st.value_labels, '{}'), st.is_active, st.created_at, st.updated_at,
+	l.id, l.name, l.description, l.latitude, l.longitude, l.address,
+	l.organization_id, l.is_active, l.created_at, l.updated_at`
+
+// sensorJoins selects sensors as s with their sensor type and location
+var sensorJoins = fmt.Sprintf(`
+	FROM %[1]s.sensors s
+	INNER JOIN %[1]s.sensor_types st ON s.sensor_type_id = st.id
+	LEFT JOIN %[1]s.locations l ON s.location_id = l.id`, schema)
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
 
+// scanSensor scans a row selected with sensorColumns
+func scanSensor(row rowScanner) (*Sensor, error) {
 	sensor := &Sensor{}
 	sensorType := &SensorType{}
 	location := &Location{}
@@ -100,45 +260,42 @@ func (r *repository) GetSensorByID(id int) (*Sensor, error) {
 	var locationID sql.NullInt64
 	var lastReadingAt sql.NullTime
 	var batteryLevel sql.NullInt64
-	var locID sql.NullInt64
+	var locID, locOrgID sql.NullInt64
 	var locName, locDesc, locAddress sql.NullString
 	var locLat, locLng sql.NullFloat64
 	var locActive sql.NullBool
 	var locCreated, locUpdated sql.NullTime
+	var valueLabels []byte
 
-	err := r.db.QueryRow(query, id).Scan(
+	err := row.Scan(
 		&sensor.ID, &sensor.DeviceID, &sensor.Name, &sensor.Description,
-		&sensor.SensorTypeID, &locationID, &sensor.IsActive, &lastReadingAt,
-		&batteryLevel, &sensor.FirmwareVersion, &sensor.CreatedBy,
+		&sensor.SensorTypeID, &locationID, &sensor.OrganizationID, &sensor.IsActive, &sensor.IsProvisioned, &lastReadingAt,
+		&batteryLevel, &sensor.FirmwareVersion, &sensor.ExpectedIntervalSeconds, &sensor.IngestRatePerMinute,
+		pq.Array(&sensor.Tags), &sensor.Metadata, &sensor.CalibrationOffset, &sensor.CalibrationScale,
+		&sensor.MaintenanceUntil, &sensor.CreatedBy,
 		&sensor.CreatedAt, &sensor.UpdatedAt,
 		&sensorType.ID, &sensorType.Name, &sensorType.Description, &sensorType.Unit,
-		&sensorType.MinValue, &sensorType.MaxValue, &sensorType.IsActive,
+		&sensorType.MinValue, &sensorType.MaxValue, &sensorType.RetentionDays,
+		&sensorType.DecimalPlaces, &valueLabels, &sensorType.IsActive,
 		&sensorType.CreatedAt, &sensorType.UpdatedAt,
 		&locID, &locName, &locDesc, &locLat, &locLng, &locAddress,
-		&locActive, &locCreated, &locUpdated,
+		&locOrgID, &locActive, &locCreated, &locUpdated,
 	)
-
-	if err == sql.ErrNoRows {
-		return nil, ErrSensorNotFound
-	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get sensor by ID: %w", err)
+		return nil, err
 	}
 
 	// Set nullable fields
-	if locationID.Valid {
-		locationIDInt := int(locationID.Int64)
-		sensor.LocationID = &locationIDInt
-	}
+	sensor.LocationID = nullIntPtr(locationID)
 	if lastReadingAt.Valid {
 		sensor.LastReadingAt = &lastReadingAt.Time
 	}
-	if batteryLevel.Valid {
-		batteryLevelInt := int(batteryLevel.Int64)
-		sensor.BatteryLevel = &batteryLevelInt
-	}
+	sensor.BatteryLevel = nullIntPtr(batteryLevel)
 
 	// Set sensor type
+	if err := json.Unmarshal(valueLabels, &sensorType.ValueLabels); err != nil {
+		return nil, err
+	}
 	sensor.SensorType = sensorType
 
 	// Set location if exists
@@ -153,6 +310,7 @@ func (r *repository) GetSensorByID(id int) (*Sensor, error) {
 			location.Longitude = &locLng.Float64
 		}
 		location.Address = locAddress.String
+		location.OrganizationID = int(locOrgID.Int64)
 		location.IsActive = locActive.Bool
 		location.CreatedAt = locCreated.Time
 		location.UpdatedAt = locUpdated.Time
@@ -162,26 +320,54 @@ func (r *repository) GetSensorByID(id int) (*Sensor, error) {
 	return sensor, nil
 }
 
+// GetSensorByID retrieves sensor by ID with related data
+func (r *repository) GetSensorByID(ctx context.Context, id int) (*Sensor, error) {
+	args := []interface{}{id}
+	orgClause, args := r.orgFilter("s.organization_id", args)
+	accessClause, args := r.accessFilter("s", AccessLevelRead, args)
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		%s
+		WHERE s.id = $1%s%s
+	`, sensorColumns, sensorJoins, orgClause, accessClause)
+
+	sensor, err := scanSensor(r.db.QueryRowContext(ctx, query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSensorNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sensor by ID: %w", err)
+	}
+
+	return sensor, nil
+}
+
 // GetSensorByDeviceID retrieves sensor by device ID
-func (r *repository) GetSensorByDeviceID(deviceID string) (*Sensor, error) {
+func (r *repository) GetSensorByDeviceID(ctx context.Context, deviceID string) (*Sensor, error) {
+	args := []interface{}{NormalizeDeviceID(deviceID)}
+	orgClause, args := r.orgFilter("s.organization_id", args)
+	accessClause, args := r.accessFilter("s", AccessLevelRead, args)
+
+	// Sensors stored before device IDs were normalized may not be upper case
 	query := fmt.Sprintf(`
-		SELECT id FROM %s.sensors WHERE device_id = $1
-	`, schema)
+		SELECT s.id FROM %s.sensors s WHERE UPPER(s.device_id) = $1%s%s
+	`, schema, orgClause, accessClause)
 
 	var id int
-	err := r.db.QueryRow(query, strings.ToUpper(deviceID)).Scan(&id)
-	if err == sql.ErrNoRows {
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
 		return nil, ErrSensorNotFound
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get sensor by device ID: %w", err)
 	}
 
-	return r.GetSensorByID(id)
+	return r.GetSensorByID(ctx, id)
 }
 
 // UpdateSensor updates sensor information
-func (r *repository) UpdateSensor(id int, req *UpdateSensorRequest) (*Sensor, error) {
+func (r *repository) UpdateSensor(ctx context.Context, id int, req *UpdateSensorRequest) (*Sensor, error) {
 	// Build dynamic query
 	setParts := []string{}
 	args := []interface{}{}
@@ -223,8 +409,32 @@ func (r *repository) UpdateSensor(id int, req *UpdateSensorRequest) (*Sensor, er
 		argIndex++
 	}
 
+	if req.ExpectedIntervalSeconds != nil {
+		setParts = append(setParts, fmt.Sprintf("expected_interval_seconds = $%d", argIndex))
+		args = append(args, *req.ExpectedIntervalSeconds)
+		argIndex++
+	}
+
+	if req.IngestRatePerMinute != nil {
+		setParts = append(setParts, fmt.Sprintf("ingest_rate_per_minute = $%d", argIndex))
+		args = append(args, *req.IngestRatePerMinute)
+		argIndex++
+	}
+
+	if req.Tags != nil {
+		setParts = append(setParts, fmt.Sprintf("tags = $%d", argIndex))
+		args = append(args, pq.Array(req.Tags))
+		argIndex++
+	}
+
+	if len(req.Metadata) > 0 {
+		setParts = append(setParts, fmt.Sprintf("metadata = $%d::jsonb", argIndex))
+		args = append(args, string(req.Metadata))
+		argIndex++
+	}
+
 	if len(setParts) == 0 {
-		return r.GetSensorByID(id) // No changes, return current sensor
+		return r.GetSensorByID(ctx, id) // No changes, return current sensor
 	}
 
 	// Add updated_at
@@ -234,39 +444,63 @@ func (r *repository) UpdateSensor(id int, req *UpdateSensorRequest) (*Sensor, er
 
 	// Add ID for WHERE clause
 	args = append(args, id)
+	orgClause, args := r.orgFilter("s.organization_id", args)
+	accessClause, args := r.accessFilter("s", AccessLevelWrite, args)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
 
+	// The self join reads the firmware version as it was before the update
 	query := fmt.Sprintf(`
-		UPDATE %s.sensors 
+		UPDATE %s.sensors s
 		SET %s
-		WHERE id = $%d AND is_active = true
-	`, schema, strings.Join(setParts, ", "), argIndex)
-
-	result, err := r.db.Exec(query, args...)
+		FROM %s.sensors prev
+		WHERE s.id = $%d AND prev.id = s.id AND s.is_active = true%s%s
+		RETURNING prev.firmware_version, s.firmware_version
+	`, schema, strings.Join(setParts, ", "), schema, argIndex, orgClause, accessClause)
+
+	var previousFirmware, firmware sql.NullString
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&previousFirmware, &firmware)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSensorNotFound
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to update sensor: %w", err)
 	}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	if previousFirmware != firmware {
+		historyQuery := fmt.Sprintf(`
+			INSERT INTO %s.sensor_firmware_history (sensor_id, previous_version, firmware_version)
+			VALUES ($1, $2, $3)
+		`, schema)
+
+		if _, err := tx.ExecContext(ctx, historyQuery, id, previousFirmware, firmware); err != nil {
+			return nil, fmt.Errorf("failed to record sensor firmware change: %w", err)
+		}
 	}
 
-	if rowsAffected == 0 {
-		return nil, ErrSensorNotFound
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	return r.GetSensorByID(id)
+	return r.GetSensorByID(ctx, id)
 }
 
 // DeleteSensor soft deletes a sensor (sets is_active to false)
-func (r *repository) DeleteSensor(id int) error {
+func (r *repository) DeleteSensor(ctx context.Context, id int) error {
+	args := []interface{}{time.Now(), id}
+	orgClause, args := r.orgFilter("organization_id", args)
+
 	query := fmt.Sprintf(`
 		UPDATE %s.sensors 
 		SET is_active = false, updated_at = $1
-		WHERE id = $2
-	`, schema)
+		WHERE id = $2%s
+	`, schema, orgClause)
 
-	result, err := r.db.Exec(query, time.Now(), id)
+	result, err := r.db.ExecContext(ctx, query, args...)
 	if err != nil {
 		return fmt.Errorf("failed to delete sensor: %w", err)
 	}
@@ -283,620 +517,3112 @@ func (r *repository) DeleteSensor(id int) error {
 	return nil
 }
 
-// ListSensors retrieves paginated list of sensors
-func (r *repository) ListSensors(limit, offset int) ([]*Sensor, int, error) {
-	// Get total count
-	countQuery := fmt.Sprintf(`
-		SELECT COUNT(*) FROM %s.sensors WHERE is_active = true
-	`, schema)
-	var total int
-	err := r.db.QueryRow(countQuery).Scan(&total)
+// purgeBatchSize bounds the readings removed by each delete statement
+const purgeBatchSize = 10000
+
+// PurgeSensor permanently deletes a sensor and its readings, returning the
+// number of readings removed
+func (r *repository) PurgeSensor(ctx context.Context, id int) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to count sensors: %w", err)
+		return 0, fmt.Errorf("failed to start transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	// Get sensors with basic info (without joins for performance)
-	query := fmt.Sprintf(`
-		SELECT s.id, s.device_id, s.name, s.description, s.sensor_type_id, s.location_id,
-		       s.is_active, s.last_reading_at, s.battery_level, s.firmware_version,
-		       s.created_by, s.created_at, s.updated_at
-		FROM %s.sensors s
-		WHERE s.is_active = true
-		ORDER BY s.created_at DESC
-		LIMIT $1 OFFSET $2
-	`, schema)
+	args := []interface{}{id}
+	orgClause, args := r.orgFilter("organization_id", args)
 
-	rows, err := r.db.Query(query, limit, offset)
+	// Lock the sensor so no readings are added while it is purged
+	lockQuery := fmt.Sprintf(`
+		SELECT id FROM %s.sensors WHERE id = $1%s FOR UPDATE
+	`, schema, orgClause)
+
+	var sensorID int
+	err = tx.QueryRowContext(ctx, lockQuery, args...).Scan(&sensorID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrSensorNotFound
+	}
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to list sensors: %w", err)
+		return 0, fmt.Errorf("failed to lock sensor: %w", err)
 	}
-	defer rows.Close()
-
-	sensors := []*Sensor{}
-	for rows.Next() {
-		sensor := &Sensor{}
-		var locationID sql.NullInt64
-		var lastReadingAt sql.NullTime
-		var batteryLevel sql.NullInt64
 
-		err := rows.Scan(
-			&sensor.ID, &sensor.DeviceID, &sensor.Name, &sensor.Description,
-			&sensor.SensorTypeID, &locationID, &sensor.IsActive, &lastReadingAt,
-			&batteryLevel, &sensor.FirmwareVersion, &sensor.CreatedBy,
-			&sensor.CreatedAt, &sensor.UpdatedAt,
+	readingsQuery := fmt.Sprintf(`
+		DELETE FROM %s.sensor_readings
+		WHERE id IN (
+			SELECT id FROM %s.sensor_readings WHERE sensor_id = $1 LIMIT $2
 		)
+	`, schema, schema)
+
+	var deleted int64
+	for {
+		result, err := tx.ExecContext(ctx, readingsQuery, sensorID, purgeBatchSize)
 		if err != nil {
-			return nil, 0, fmt.Errorf("failed to scan sensor: %w", err)
+			return 0, fmt.Errorf("failed to delete sensor readings: %w", err)
 		}
 
-		// Set nullable fields
-		if locationID.Valid {
-			locationIDInt := int(locationID.Int64)
-			sensor.LocationID = &locationIDInt
-		}
-		if lastReadingAt.Valid {
-			sensor.LastReadingAt = &lastReadingAt.Time
-		}
-		if batteryLevel.Valid {
-			batteryLevelInt := int(batteryLevel.Int64)
-			sensor.BatteryLevel = &batteryLevelInt
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get rows affected: %w", err)
 		}
 
-		sensors = append(sensors, sensor)
+		deleted += rowsAffected
+		if rowsAffected < purgeBatchSize {
+			break
+		}
 	}
 
-	return sensors, total, nil
-}
-
-// ListSensorsByLocation retrieves sensors by location
-func (r *repository) ListSensorsByLocation(locationID int) ([]*Sensor, error) {
-	query := fmt.Sprintf(`
-		SELECT id FROM %s.sensors 
-		WHERE location_id = $1 AND is_active = true
-		ORDER BY name
-	`, schema)
-
-	rows, err := r.db.Query(query, locationID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to list sensors by location: %w", err)
+	deleteQuery := fmt.Sprintf(`DELETE FROM %s.sensors WHERE id = $1`, schema)
+	if _, err := tx.ExecContext(ctx, deleteQuery, sensorID); err != nil {
+		return 0, fmt.Errorf("failed to delete sensor: %w", err)
 	}
-	defer rows.Close()
-
-	sensors := []*Sensor{}
-	for rows.Next() {
-		var id int
-		if err := rows.Scan(&id); err != nil {
-			return nil, fmt.Errorf("failed to scan sensor ID: %w", err)
-		}
-
-		sensor, err := r.GetSensorByID(id)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get sensor details: %w", err)
-		}
 
-		sensors = append(sensors, sensor)
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	return sensors, nil
+	return deleted, nil
 }
 
-// GetSensorTypeByID retrieves sensor type by ID
-func (r *repository) GetSensorTypeByID(id int) (*SensorType, error) {
-	query := fmt.Sprintf(`
-		SELECT id, name, description, unit, min_value, max_value, is_active, created_at, updated_at
-		FROM %s.sensor_types
-		WHERE id = $1
-	`, schema)
+// ActivateSensor restores a soft deleted sensor
+func (r *repository) ActivateSensor(ctx context.Context, id int) error {
+	args := []interface{}{time.Now(), id}
+	orgClause, args := r.orgFilter("s.organization_id", args)
+	accessClause, args := r.accessFilter("s", AccessLevelWrite, args)
 
-	sensorType := &SensorType{}
-	err := r.db.QueryRow(query, id).Scan(
-		&sensorType.ID, &sensorType.Name, &sensorType.Description, &sensorType.Unit,
-		&sensorType.MinValue, &sensorType.MaxValue, &sensorType.IsActive,
-		&sensorType.CreatedAt, &sensorType.UpdatedAt,
-	)
+	query := fmt.Sprintf(`
+		UPDATE %s.sensors s
+		SET is_active = true, updated_at = $1
+		WHERE s.id = $2%s%s
+	`, schema, orgClause, accessClause)
 
-	if err == sql.ErrNoRows {
-		return nil, ErrSensorTypeNotFound
-	}
+	result, err := r.db.ExecContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get sensor type by ID: %w", err)
+		return fmt.Errorf("failed to activate sensor: %w", err)
 	}
 
-	return sensorType, nil
-}
-
-// GetSensorTypeByName retrieves sensor type by name
-func (r *repository) GetSensorTypeByName(name string) (*SensorType, error) {
-	query := fmt.Sprintf(`
-		SELECT id, name, description, unit, min_value, max_value, is_active, created_at, updated_at
-		FROM %s.sensor_types
-		WHERE name = $1
-	`, schema)
-
-	sensorType := &SensorType{}
-	err := r.db.QueryRow(query, name).Scan(
-		&sensorType.ID, &sensorType.Name, &sensorType.Description, &sensorType.Unit,
-		&sensorType.MinValue, &sensorType.MaxValue, &sensorType.IsActive,
-		&sensorType.CreatedAt, &sensorType.UpdatedAt,
-	)
-
-	if err == sql.ErrNoRows {
-		return nil, ErrSensorTypeNotFound
-	}
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get sensor type by name: %w", err)
+		return fmt.Errorf("failed to get rows affected: %w", err)
 	}
 
-	return sensorType, nil
+	if rowsAffected == 0 {
+		return ErrSensorNotFound
+	}
+
+	return nil
 }
 
-// ListSensorTypes retrieves all active sensor types
-func (r *repository) ListSensorTypes() ([]*SensorType, error) {
+// ApproveSensor provisions a pending sensor with its real sensor type,
+// location and organization, replacing its device token
+func (r *repository) ApproveSensor(ctx context.Context, id int, req *ApproveSensorRequest, tokenHash string) error {
+	args := []interface{}{id, req.SensorTypeID, req.LocationID, req.Name, time.Now(), req.OrganizationID, tokenHash}
+	orgClause, args := r.orgFilter("organization_id", args)
+
 	query := fmt.Sprintf(`
-		SELECT id, name, description, unit, min_value, max_value, is_active, created_at, updated_at
-		FROM %s.sensor_types
-		WHERE is_active = true
-		ORDER BY name
-	`, schema)
+		UPDATE %s.sensors
+		SET is_provisioned = true, sensor_type_id = $2, location_id = $3,
+		    name = COALESCE($4, name), updated_at = $5,
+		    organization_id = COALESCE($6, organization_id), device_token_hash = $7
+		WHERE id = $1 AND is_provisioned = false%s
+	`, schema, orgClause)
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		if database.IsForeignKeyViolation(err) {
+			return ErrOrganizationNotFound
+		}
+		return fmt.Errorf("failed to approve sensor: %w", err)
+	}
 
-	rows, err := r.db.Query(query)
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return nil, fmt.Errorf("failed to list sensor types: %w", err)
+		return fmt.Errorf("failed to get rows affected: %w", err)
 	}
-	defer rows.Close()
 
-	sensorTypes := []*SensorType{}
-	for rows.Next() {
-		sensorType := &SensorType{}
-		err := rows.Scan(
-			&sensorType.ID, &sensorType.Name, &sensorType.Description, &sensorType.Unit,
-			&sensorType.MinValue, &sensorType.MaxValue, &sensorType.IsActive,
-			&sensorType.CreatedAt, &sensorType.UpdatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan sensor type: %w", err)
-		}
-		sensorTypes = append(sensorTypes, sensorType)
+	if rowsAffected == 0 {
+		return ErrSensorNotFound
 	}
 
-	return sensorTypes, nil
+	return nil
 }
 
-// CreateLocation creates a new location
-func (r *repository) CreateLocation(location *Location) error {
+// UpdateDeviceTokenHash replaces the device token of a sensor
+func (r *repository) UpdateDeviceTokenHash(ctx context.Context, id int, hash string) error {
+	args := []interface{}{id, hash, time.Now()}
+	orgClause, args := r.orgFilter("organization_id", args)
+
 	query := fmt.Sprintf(`
-		INSERT INTO %s.locations (name, description, latitude, longitude, address, is_active)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id, created_at, updated_at
-	`, schema)
+		UPDATE %s.sensors
+		SET device_token_hash = $2, updated_at = $3
+		WHERE id = $1%s
+	`, schema, orgClause)
 
-	err := r.db.QueryRow(query,
-		location.Name, location.Description, location.Latitude, location.Longitude,
-		location.Address, location.IsActive).
-		Scan(&location.ID, &location.CreatedAt, &location.UpdatedAt)
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update device token: %w", err)
+	}
 
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to create location: %w", err)
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrSensorNotFound
 	}
 
 	return nil
 }
 
-// GetLocationByID retrieves location by ID
-func (r *repository) GetLocationByID(id int) (*Location, error) {
+// CountSensorsWithDeviceToken counts the sensors among sensorIDs whose
+// device token has the hash
+func (r *repository) CountSensorsWithDeviceToken(ctx context.Context, sensorIDs []int, hash string) (int, error) {
+	ids := make([]int64, len(sensorIDs))
+	for i, id := range sensorIDs {
+		ids[i] = int64(id)
+	}
+
 	query := fmt.Sprintf(`
-		SELECT id, name, description, latitude, longitude, address, is_active, created_at, updated_at
-		FROM %s.locations
-		WHERE id = $1
+		SELECT COUNT(*) FROM %s.sensors WHERE id = ANY($1) AND device_token_hash = $2
 	`, schema)
 
-	location := &Location{}
-	err := r.db.QueryRow(query, id).Scan(
-		&location.ID, &location.Name, &location.Description, &location.Latitude,
-		&location.Longitude, &location.Address, &location.IsActive,
-		&location.CreatedAt, &location.UpdatedAt,
-	)
-
-	if err == sql.ErrNoRows {
-		return nil, ErrLocationNotFound
-	}
-	if err != nil {
-		return nil, fmt.Errorf("failed to get location by ID: %w", err)
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, pq.Array(ids), hash).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to check device token: %w", err)
 	}
 
-	return location, nil
+	return count, nil
 }
 
-// UpdateLocation updates location information
-func (r *repository) UpdateLocation(id int, req *UpdateLocationRequest) (*Location, error) {
-	// Build dynamic query
-	setParts := []string{}
+// ListSensors retrieves paginated list of sensors matching the filter
+func (r *repository) ListSensors(ctx context.Context, filter *SensorFilter, limit, offset int) ([]*Sensor, int, error) {
+	whereParts := []string{}
 	args := []interface{}{}
 	argIndex := 1
 
-	if req.Name != nil {
-		setParts = append(setParts, fmt.Sprintf("name = $%d", argIndex))
-		args = append(args, *req.Name)
+	if filter.Query != "" {
+		whereParts = append(whereParts, fmt.Sprintf("(s.name ILIKE $%d OR s.device_id ILIKE $%d)", argIndex, argIndex))
+		args = append(args, "%"+escapeLike(filter.Query)+"%")
 		argIndex++
 	}
 
-	if req.Description != nil {
-		setParts = append(setParts, fmt.Sprintf("description = $%d", argIndex))
-		args = append(args, *req.Description)
+	if filter.SensorTypeID != nil {
+		whereParts = append(whereParts, fmt.Sprintf("s.sensor_type_id = $%d", argIndex))
+		args = append(args, *filter.SensorTypeID)
 		argIndex++
 	}
 
-	if req.Latitude != nil {
-		setParts = append(setParts, fmt.Sprintf("latitude = $%d", argIndex))
-		args = append(args, *req.Latitude)
+	if filter.LocationID != nil {
+		whereParts = append(whereParts, fmt.Sprintf("s.location_id = $%d", argIndex))
+		args = append(args, *filter.LocationID)
 		argIndex++
 	}
 
-	if req.Longitude != nil {
-		setParts = append(setParts, fmt.Sprintf("longitude = $%d", argIndex))
-		args = append(args, *req.Longitude)
+	if filter.IsActive != nil {
+		whereParts = append(whereParts, fmt.Sprintf("s.is_active = $%d", argIndex))
+		args = append(args, *filter.IsActive)
 		argIndex++
 	}
 
-	if req.Address != nil {
-		setParts = append(setParts, fmt.Sprintf("address = $%d", argIndex))
-		args = append(args, *req.Address)
+	if filter.Online != nil {
+		fallback := filter.OnlineThreshold
+		if fallback <= 0 {
+			fallback = DefaultOnlineThresholdMinutes * time.Minute
+		}
+		onlineSince := onlineSinceExpr("s", argIndex, argIndex+1)
+		if *filter.Online {
+			whereParts = append(whereParts, fmt.Sprintf("s.last_reading_at > %s", onlineSince))
+		} else {
+			whereParts = append(whereParts, fmt.Sprintf("(s.last_reading_at IS NULL OR s.last_reading_at <= %s)", onlineSince))
+		}
+		args = append(args, time.Now(), int(fallback.Seconds()))
+		argIndex += 2
+	}
+
+	if filter.CreatedBy != nil {
+		whereParts = append(whereParts, fmt.Sprintf("s.created_by = $%d", argIndex))
+		args = append(args, *filter.CreatedBy)
 		argIndex++
 	}
 
-	if req.IsActive != nil {
-		setParts = append(setParts, fmt.Sprintf("is_active = $%d", argIndex))
-		args = append(args, *req.IsActive)
+	if filter.Tag != "" {
+		whereParts = append(whereParts, fmt.Sprintf("s.tags @> ARRAY[$%d]::text[]", argIndex))
+		args = append(args, filter.Tag)
 		argIndex++
 	}
 
-	if len(setParts) == 0 {
-		return r.GetLocationByID(id) // No changes, return current location
+	// Sort the keys so equal filters build equal queries
+	metadataKeys := make([]string, 0, len(filter.Metadata))
+	for key := range filter.Metadata {
+		metadataKeys = append(metadataKeys, key)
+	}
+	sort.Strings(metadataKeys)
+	for _, key := range metadataKeys {
+		whereParts = append(whereParts, fmt.Sprintf("s.metadata->>$%d = $%d", argIndex, argIndex+1))
+		args = append(args, key, filter.Metadata[key])
+		argIndex += 2
 	}
 
-	// Add updated_at
-	setParts = append(setParts, fmt.Sprintf("updated_at = $%d", argIndex))
-	args = append(args, time.Now())
+	whereParts = append(whereParts, fmt.Sprintf("s.is_provisioned = $%d", argIndex))
+	args = append(args, !filter.Pending)
 	argIndex++
 
-	// Add ID for WHERE clause
-	args = append(args, id)
+	whereClause := strings.Join(whereParts, " AND ")
+	orgClause, args := r.orgFilter("s.organization_id", args)
+	accessClause, args := r.accessFilter("s", AccessLevelRead, args)
+	whereClause += orgClause + accessClause
 
+	sortColumn, ok := sensorSortColumns[filter.SortBy]
+	if !ok {
+		sortColumn = sensorSortColumns["created_at"]
+	}
+	// Sensors that never reported or report no battery sort as the oldest and lowest
+	direction := "ASC NULLS FIRST"
+	if filter.SortDesc {
+		direction = "DESC NULLS LAST"
+	}
+
+	// Get total count
+	countQuery := fmt.Sprintf(`
+		SELECT COUNT(*) %s WHERE %s
+	`, sensorJoins, whereClause)
+	var total int
+	err := r.db.QueryRowContext(ctx, countQuery, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count sensors: %w", err)
+	}
+
+	// Get sensors with their sensor type and location in one query
+	query := fmt.Sprintf(`
+		SELECT %s
+		%s
+		WHERE %s
+		ORDER BY %s %s, s.id
+		LIMIT $%d OFFSET $%d
+	`, sensorColumns, sensorJoins, whereClause, sortColumn, direction, len(args)+1, len(args)+2)
+
+	rows, err := r.db.QueryContext(ctx, query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list sensors: %w", err)
+	}
+	defer rows.Close()
+
+	sensors := []*Sensor{}
+	for rows.Next() {
+		sensor, err := scanSensor(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan sensor: %w", err)
+		}
+		sensors = append(sensors, sensor)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to list sensors: %w", err)
+	}
+
+	if err := r.attachLatestReadings(ctx, sensors); err != nil {
+		return nil, 0, err
+	}
+
+	return sensors, total, nil
+}
+
+// attachLatestReadings loads the latest reading of every sensor in one query
+func (r *repository) attachLatestReadings(ctx context.Context, sensors []*Sensor) error {
+	if len(sensors) == 0 {
+		return nil
+	}
+
+	sensorIDs := make([]int64, len(sensors))
+	for i, sensor := range sensors {
+		sensorIDs[i] = int64(sensor.ID)
+	}
+
+	latestQuery := fmt.Sprintf(`
+		SELECT DISTINCT ON (sensor_id) id, sensor_id, value, COALESCE(raw_value, value), timestamp, quality, metadata, created_at
+		FROM %s.sensor_readings
+		WHERE sensor_id = ANY($1)
+		ORDER BY sensor_id, timestamp DESC
+	`, schema)
+
+	readingRows, err := r.db.QueryContext(ctx, latestQuery, pq.Array(sensorIDs))
+	if err != nil {
+		return fmt.Errorf("failed to get latest readings: %w", err)
+	}
+	defer readingRows.Close()
+
+	latest := make(map[int]*SensorReading, len(sensors))
+	for readingRows.Next() {
+		reading := &SensorReading{}
+		err := readingRows.Scan(
+			&reading.ID, &reading.SensorID, &reading.Value, &reading.RawValue, &reading.Timestamp,
+			&reading.Quality, &reading.Metadata, &reading.CreatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to scan sensor reading: %w", err)
+		}
+		latest[reading.SensorID] = reading
+	}
+	if err := readingRows.Err(); err != nil {
+		return fmt.Errorf("failed to get latest readings: %w", err)
+	}
+
+	for _, sensor := range sensors {
+		sensor.LatestReading = latest[sensor.ID]
+	}
+
+	return nil
+}
+
+// sensorSortColumns maps allowed sort keys to sensors columns
+var sensorSortColumns = map[string]string{
+	"name":            "s.name",
+	"device_id":       "s.device_id",
+	"created_at":      "s.created_at",
+	"last_reading_at": "s.last_reading_at",
+	"battery_level":   "s.battery_level",
+}
+
+// ListSensorIDs returns the IDs of the sensors visible in scope, inactive
+// ones included
+func (r *repository) ListSensorIDs(ctx context.Context) ([]int, error) {
+	args := []interface{}{}
+	orgClause, args := r.orgFilter("s.organization_id", args)
+	accessClause, args := r.accessFilter("s", AccessLevelRead, args)
+
+	query := fmt.Sprintf(`
+		SELECT s.id FROM %s.sensors s WHERE true%s%s ORDER BY s.id
+	`, schema, orgClause, accessClause)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sensor IDs: %w", err)
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan sensor ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list sensor IDs: %w", err)
+	}
+
+	return ids, nil
+}
+
+// ListSensorsByLocation retrieves sensors by location
+func (r *repository) ListSensorsByLocation(ctx context.Context, locationID int) ([]*Sensor, error) {
+	args := []interface{}{locationID}
+	orgClause, args := r.orgFilter("s.organization_id", args)
+	accessClause, args := r.accessFilter("s", AccessLevelRead, args)
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		%s
+		WHERE s.location_id = $1 AND s.is_active = true AND s.is_provisioned = true%s%s
+		ORDER BY s.name
+	`, sensorColumns, sensorJoins, orgClause, accessClause)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sensors by location: %w", err)
+	}
+	defer rows.Close()
+
+	sensors := []*Sensor{}
+	for rows.Next() {
+		sensor, err := scanSensor(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan sensor: %w", err)
+		}
+		sensors = append(sensors, sensor)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list sensors by location: %w", err)
+	}
+
+	return sensors, nil
+}
+
+// GetDashboardCounts counts the active sensors, in total and by type, and
+// how many are online, offline and in maintenance in one grouped query.
+// Sensors in maintenance count as neither online nor offline; fallback is
+// the online threshold of sensors without an expected interval.
+func (r *repository) GetDashboardCounts(ctx context.Context, now time.Time, fallback time.Duration) (*DashboardData, error) {
+	args := []interface{}{now, int(fallback.Seconds())}
+	orgClause, args := r.orgFilter("s.organization_id", args)
+	accessClause, args := r.accessFilter("s", AccessLevelRead, args)
+
+	query := fmt.Sprintf(`
+		SELECT st.name, COUNT(*),
+		       COUNT(*) FILTER (WHERE s.maintenance_until > $1),
+		       COUNT(*) FILTER (WHERE (s.maintenance_until IS NULL OR s.maintenance_until <= $1) AND s.last_reading_at > %s)
+		%s
+		WHERE s.is_active = true AND s.is_provisioned = true%s%s
+		GROUP BY st.name
+	`, onlineSinceExpr("s", 1, 2), sensorJoins, orgClause, accessClause)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count dashboard sensors: %w", err)
+	}
+	defer rows.Close()
+
+	dashboard := &DashboardData{SensorsByType: make(map[string]int)}
+	for rows.Next() {
+		var typeName string
+		var total, maintenance, online int
+		if err := rows.Scan(&typeName, &total, &maintenance, &online); err != nil {
+			return nil, fmt.Errorf("failed to scan dashboard counts: %w", err)
+		}
+		dashboard.SensorsByType[typeName] = total
+		dashboard.TotalSensors += total
+		dashboard.MaintenanceSensors += maintenance
+		dashboard.OnlineSensors += online
+		dashboard.OfflineSensors += total - maintenance - online
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to count dashboard sensors: %w", err)
+	}
+	dashboard.ActiveSensors = dashboard.TotalSensors
+
+	return dashboard, nil
+}
+
+// ListAlertCandidates retrieves the active sensors outside maintenance that
+// fail a health check of the policy, newest first with their latest
+// reading. fallback is the online threshold of sensors without an expected
+// interval.
+func (r *repository) ListAlertCandidates(ctx context.Context, now time.Time, fallback time.Duration, policy HealthPolicy, limit int) ([]*Sensor, error) {
+	args := []interface{}{now, int(fallback.Seconds())}
+	orgClause, args := r.orgFilter("s.organization_id", args)
+	accessClause, args := r.accessFilter("s", AccessLevelRead, args)
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		%s
+		WHERE s.is_active = true AND s.is_provisioned = true AND %s%s%s
+		ORDER BY s.created_at DESC, s.id
+		LIMIT $%d
+	`, sensorColumns, sensorJoins, alertConditionExpr("s", 1, 2, policy), orgClause, accessClause, len(args)+1)
+
+	rows, err := r.db.QueryContext(ctx, query, append(args, limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list alert candidates: %w", err)
+	}
+	defer rows.Close()
+
+	sensors := []*Sensor{}
+	for rows.Next() {
+		sensor, err := scanSensor(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan sensor: %w", err)
+		}
+		sensors = append(sensors, sensor)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list alert candidates: %w", err)
+	}
+
+	if err := r.attachLatestReadings(ctx, sensors); err != nil {
+		return nil, err
+	}
+
+	return sensors, nil
+}
+
+// CountSensorsByLocation counts the active sensors of every location, and
+// how many are online, offline and need attention, in one grouped query.
+// Sensors without a location are counted in a bucket without location ID,
+// listed last. fallback is the online threshold of sensors without an
+// expected interval.
+func (r *repository) CountSensorsByLocation(ctx context.Context, now time.Time, fallback time.Duration, policy HealthPolicy) ([]*LocationSensorCount, error) {
+	args := []interface{}{now, int(fallback.Seconds())}
+	orgClause, args := r.orgFilter("s.organization_id", args)
+	accessClause, args := r.accessFilter("s", AccessLevelRead, args)
+
+	query := fmt.Sprintf(`
+		SELECT l.id, l.name, COUNT(*),
+		       COUNT(*) FILTER (WHERE (s.maintenance_until IS NULL OR s.maintenance_until <= $1) AND s.last_reading_at > %s),
+		       COUNT(*) FILTER (WHERE (s.maintenance_until IS NULL OR s.maintenance_until <= $1) AND (s.last_reading_at IS NULL OR s.last_reading_at <= %s)),
+		       COUNT(*) FILTER (WHERE %s)
+		FROM %s.sensors s
+		LEFT JOIN %s.locations l ON s.location_id = l.id
+		WHERE s.is_active = true AND s.is_provisioned = true%s%s
+		GROUP BY l.id, l.name
+		ORDER BY l.name NULLS LAST, l.id
+	`, onlineSinceExpr("s", 1, 2), onlineSinceExpr("s", 1, 2), alertConditionExpr("s", 1, 2, policy),
+		schema, schema, orgClause, accessClause)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count sensors by location: %w", err)
+	}
+	defer rows.Close()
+
+	counts := []*LocationSensorCount{}
+	for rows.Next() {
+		count := &LocationSensorCount{}
+		var locationID sql.NullInt64
+		var locationName sql.NullString
+		err := rows.Scan(&locationID, &locationName, &count.TotalSensors,
+			&count.OnlineSensors, &count.OfflineSensors, &count.AlertSensors)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan location sensor count: %w", err)
+		}
+		count.LocationID = nullIntPtr(locationID)
+		count.LocationName = locationName.String
+		counts = append(counts, count)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to count sensors by location: %w", err)
+	}
+
+	return counts, nil
+}
+
+// alertConditionExpr returns the SQL condition matching the sensors aliased
+// as alias that are outside maintenance and fail a health check of the
+// policy, given the positions of the current time and the fallback
+// threshold in seconds. It mirrors calculateSensorHealth.
+func alertConditionExpr(alias string, nowArg, fallbackArg int, policy HealthPolicy) string {
+	checks := []string{}
+	if policy.OfflinePenalty > 0 {
+		checks = append(checks, fmt.Sprintf("%s.last_reading_at IS NULL OR %s.last_reading_at <= %s",
+			alias, alias, onlineSinceExpr(alias, nowArg, fallbackArg)))
+	}
+	if policy.NoReadingsPenalty > 0 {
+		checks = append(checks, fmt.Sprintf("%s.last_reading_at IS NULL", alias))
+	}
+	if policy.StaleReadingPenalty > 0 {
+		checks = append(checks, fmt.Sprintf("%s.last_reading_at <= $%d::timestamp - %d * INTERVAL '1 second'",
+			alias, nowArg, int(policy.StaleReadingAge.Seconds())))
+	}
+	if policy.CriticalBatteryPenalty > 0 {
+		checks = append(checks, fmt.Sprintf("%s.battery_level < %d", alias, policy.CriticalBatteryLevel))
+	}
+	if policy.LowBatteryPenalty > 0 {
+		checks = append(checks, fmt.Sprintf("%s.battery_level < %d", alias, policy.LowBatteryLevel))
+	}
+	if policy.PoorQualityPenalty > 0 {
+		checks = append(checks, fmt.Sprintf(`(SELECT sr.quality FROM %s.sensor_readings sr
+		           WHERE sr.sensor_id = %s.id ORDER BY sr.timestamp DESC LIMIT 1) < %d`,
+			schema, alias, policy.PoorQualityLevel))
+	}
+	if len(checks) == 0 {
+		return "false"
+	}
+
+	return fmt.Sprintf("(%[1]s.maintenance_until IS NULL OR %[1]s.maintenance_until <= $%[2]d) AND (%[3]s)",
+		alias, nowArg, strings.Join(checks, " OR "))
+}
+
+// ListSensorTags retrieves the distinct tags of active sensors with the
+// number of sensors carrying each, most used first
+func (r *repository) ListSensorTags(ctx context.Context) ([]*SensorTag, error) {
+	orgClause, args := r.orgFilter("s.organization_id", nil)
+	accessClause, args := r.accessFilter("s", AccessLevelRead, args)
+
+	query := fmt.Sprintf(`
+		SELECT tag, COUNT(*)
+		FROM %s.sensors s, unnest(s.tags) AS tag
+		WHERE s.is_active = true AND s.is_provisioned = true%s%s
+		GROUP BY tag
+		ORDER BY COUNT(*) DESC, tag
+	`, schema, orgClause, accessClause)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sensor tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := []*SensorTag{}
+	for rows.Next() {
+		tag := &SensorTag{}
+		if err := rows.Scan(&tag.Tag, &tag.Sensors); err != nil {
+			return nil, fmt.Errorf("failed to scan sensor tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, nil
+}
+
+// GetFirmwareReport counts the active sensors running each firmware
+// version per sensor type
+func (r *repository) GetFirmwareReport(ctx context.Context) ([]*FirmwareVersionCount, error) {
+	orgClause, args := r.orgFilter("s.organization_id", nil)
+	accessClause, args := r.accessFilter("s", AccessLevelRead, args)
+
+	query := fmt.Sprintf(`
+		SELECT st.id, st.name, COALESCE(s.firmware_version, ''), COUNT(*)
+		FROM %s.sensors s
+		INNER JOIN %s.sensor_types st ON st.id = s.sensor_type_id
+		WHERE s.is_active = true AND s.is_provisioned = true%s%s
+		GROUP BY st.id, st.name, COALESCE(s.firmware_version, '')
+		ORDER BY st.name, COALESCE(s.firmware_version, '')
+	`, schema, schema, orgClause, accessClause)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get firmware report: %w", err)
+	}
+	defer rows.Close()
+
+	counts := []*FirmwareVersionCount{}
+	for rows.Next() {
+		count := &FirmwareVersionCount{}
+		if err := rows.Scan(&count.SensorTypeID, &count.SensorType, &count.FirmwareVersion, &count.Sensors); err != nil {
+			return nil, fmt.Errorf("failed to scan firmware version count: %w", err)
+		}
+		counts = append(counts, count)
+	}
+
+	return counts, nil
+}
+
+// ListFirmwareHistory retrieves the firmware version changes of a sensor,
+// newest first
+func (r *repository) ListFirmwareHistory(ctx context.Context, sensorID int) ([]*FirmwareChange, error) {
+	query := fmt.Sprintf(`
+		SELECT id, sensor_id, COALESCE(previous_version, ''), COALESCE(firmware_version, ''), changed_at
+		FROM %s.sensor_firmware_history
+		WHERE sensor_id = $1
+		ORDER BY changed_at DESC, id DESC
+	`, schema)
+
+	rows, err := r.db.QueryContext(ctx, query, sensorID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sensor firmware history: %w", err)
+	}
+	defer rows.Close()
+
+	changes := []*FirmwareChange{}
+	for rows.Next() {
+		change := &FirmwareChange{}
+		err := rows.Scan(&change.ID, &change.SensorID, &change.PreviousVersion, &change.FirmwareVersion, &change.ChangedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan sensor firmware change: %w", err)
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+// sensorTypeColumns are the sensor type columns scanned by scanSensorType
+const sensorTypeColumns = `
+	id, name, description, unit, min_value, max_value, retention_days,
+	decimal_places, COALESCE(value_labels, '{}'), is_active, created_at, updated_at`
+
+// scanSensorType scans a row selected with sensorTypeColumns
+func scanSensorType(row rowScanner) (*SensorType, error) {
+	sensorType := &SensorType{}
+	var valueLabels []byte
+
+	err := row.Scan(
+		&sensorType.ID, &sensorType.Name, &sensorType.Description, &sensorType.Unit,
+		&sensorType.MinValue, &sensorType.MaxValue, &sensorType.RetentionDays,
+		&sensorType.DecimalPlaces, &valueLabels, &sensorType.IsActive,
+		&sensorType.CreatedAt, &sensorType.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(valueLabels, &sensorType.ValueLabels); err != nil {
+		return nil, err
+	}
+
+	return sensorType, nil
+}
+
+// valueLabelsJSON encodes value labels for storage; no labels are stored as NULL
+func valueLabelsJSON(labels map[string]string) (interface{}, error) {
+	if len(labels) == 0 {
+		return nil, nil
+	}
+
+	encoded, err := json.Marshal(labels)
+	if err != nil {
+		return nil, err
+	}
+	return string(encoded), nil
+}
+
+// GetSensorTypeByID retrieves sensor type by ID
+func (r *repository) GetSensorTypeByID(ctx context.Context, id int) (*SensorType, error) {
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM %s.sensor_types
+		WHERE id = $1
+	`, sensorTypeColumns, schema)
+
+	sensorType, err := scanSensorType(r.db.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSensorTypeNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sensor type by ID: %w", err)
+	}
+
+	return sensorType, nil
+}
+
+// GetSensorTypeByName retrieves sensor type by name
+func (r *repository) GetSensorTypeByName(ctx context.Context, name string) (*SensorType, error) {
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM %s.sensor_types
+		WHERE name = $1
+	`, sensorTypeColumns, schema)
+
+	sensorType, err := scanSensorType(r.db.QueryRowContext(ctx, query, name))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSensorTypeNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sensor type by name: %w", err)
+	}
+
+	return sensorType, nil
+}
+
+// ListSensorTypes retrieves all active sensor types
+func (r *repository) ListSensorTypes(ctx context.Context) ([]*SensorType, error) {
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM %s.sensor_types
+		WHERE is_active = true
+		ORDER BY name
+	`, sensorTypeColumns, schema)
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sensor types: %w", err)
+	}
+	defer rows.Close()
+
+	sensorTypes := []*SensorType{}
+	for rows.Next() {
+		sensorType, err := scanSensorType(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan sensor type: %w", err)
+		}
+		sensorTypes = append(sensorTypes, sensorType)
+	}
+
+	return sensorTypes, nil
+}
+
+// CreateSensorType creates a new sensor type
+func (r *repository) CreateSensorType(ctx context.Context, sensorType *SensorType) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s.sensor_types (name, description, unit, min_value, max_value, retention_days,
+		                            decimal_places, value_labels, is_active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at, updated_at
+	`, schema)
+
+	valueLabels, err := valueLabelsJSON(sensorType.ValueLabels)
+	if err != nil {
+		return fmt.Errorf("failed to encode value labels: %w", err)
+	}
+
+	err = r.db.QueryRowContext(ctx, query,
+		sensorType.Name, sensorType.Description, sensorType.Unit,
+		sensorType.MinValue, sensorType.MaxValue, sensorType.RetentionDays,
+		sensorType.DecimalPlaces, valueLabels, sensorType.IsActive).
+		Scan(&sensorType.ID, &sensorType.CreatedAt, &sensorType.UpdatedAt)
+
+	if err != nil {
+		if database.IsUniqueViolation(err) {
+			return ErrSensorTypeExists
+		}
+		return fmt.Errorf("failed to create sensor type: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateSensorType updates sensor type information
+func (r *repository) UpdateSensorType(ctx context.Context, id int, req *UpdateSensorTypeRequest) (*SensorType, error) {
+	// Build dynamic query
+	setParts := []string{}
+	args := []interface{}{}
+	argIndex := 1
+
+	if req.Name != nil {
+		setParts = append(setParts, fmt.Sprintf("name = $%d", argIndex))
+		args = append(args, *req.Name)
+		argIndex++
+	}
+
+	if req.Description != nil {
+		setParts = append(setParts, fmt.Sprintf("description = $%d", argIndex))
+		args = append(args, *req.Description)
+		argIndex++
+	}
+
+	if req.Unit != nil {
+		setParts = append(setParts, fmt.Sprintf("unit = $%d", argIndex))
+		args = append(args, *req.Unit)
+		argIndex++
+	}
+
+	if req.MinValue != nil {
+		setParts = append(setParts, fmt.Sprintf("min_value = $%d", argIndex))
+		args = append(args, *req.MinValue)
+		argIndex++
+	}
+
+	if req.MaxValue != nil {
+		setParts = append(setParts, fmt.Sprintf("max_value = $%d", argIndex))
+		args = append(args, *req.MaxValue)
+		argIndex++
+	}
+
+	if req.RetentionDays != nil {
+		setParts = append(setParts, fmt.Sprintf("retention_days = NULLIF($%d, 0)", argIndex))
+		args = append(args, *req.RetentionDays)
+		argIndex++
+	}
+
+	if req.DecimalPlaces != nil {
+		setParts = append(setParts, fmt.Sprintf("decimal_places = $%d", argIndex))
+		args = append(args, *req.DecimalPlaces)
+		argIndex++
+	}
+
+	if req.ValueLabels != nil {
+		valueLabels, err := valueLabelsJSON(req.ValueLabels)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode value labels: %w", err)
+		}
+		setParts = append(setParts, fmt.Sprintf("value_labels = $%d", argIndex))
+		args = append(args, valueLabels)
+		argIndex++
+	}
+
+	if req.IsActive != nil {
+		setParts = append(setParts, fmt.Sprintf("is_active = $%d", argIndex))
+		args = append(args, *req.IsActive)
+		argIndex++
+	}
+
+	if len(setParts) == 0 {
+		return r.GetSensorTypeByID(ctx, id) // No changes, return current sensor type
+	}
+
+	// Add updated_at
+	setParts = append(setParts, fmt.Sprintf("updated_at = $%d", argIndex))
+	args = append(args, time.Now())
+	argIndex++
+
+	// Add ID for WHERE clause
+	args = append(args, id)
+
+	query := fmt.Sprintf(`
+		UPDATE %s.sensor_types
+		SET %s
+		WHERE id = $%d
+	`, schema, strings.Join(setParts, ", "), argIndex)
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		if database.IsUniqueViolation(err) {
+			return nil, ErrSensorTypeExists
+		}
+		return nil, fmt.Errorf("failed to update sensor type: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return nil, ErrSensorTypeNotFound
+	}
+
+	return r.GetSensorTypeByID(ctx, id)
+}
+
+// DeactivateSensorType soft deletes a sensor type; existing sensors keep it
+// but no new sensors can be created with it
+func (r *repository) DeactivateSensorType(ctx context.Context, id int) error {
+	query := fmt.Sprintf(`
+		UPDATE %s.sensor_types
+		SET is_active = false, updated_at = $1
+		WHERE id = $2
+	`, schema)
+
+	result, err := r.db.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to delete sensor type: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrSensorTypeNotFound
+	}
+
+	return nil
+}
+
+// CreateLocation creates a new location
+func (r *repository) CreateLocation(ctx context.Context, location *Location) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s.locations (name, description, latitude, longitude, address, organization_id, is_active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at, updated_at
+	`, schema)
+
+	location.OrganizationID = r.organizationID()
+
+	err := r.db.QueryRowContext(ctx, query,
+		location.Name, location.Description, location.Latitude, location.Longitude,
+		location.Address, location.OrganizationID, location.IsActive).
+		Scan(&location.ID, &location.CreatedAt, &location.UpdatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create location: %w", err)
+	}
+
+	return nil
+}
+
+// GetLocationByID retrieves location by ID
+func (r *repository) GetLocationByID(ctx context.Context, id int) (*Location, error) {
+	args := []interface{}{id}
+	orgClause, args := r.orgFilter("organization_id", args)
+
+	query := fmt.Sprintf(`
+		SELECT id, name, description, latitude, longitude, address, organization_id, is_active, created_at, updated_at
+		FROM %s.locations
+		WHERE id = $1%s
+	`, schema, orgClause)
+
+	location := &Location{}
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(
+		&location.ID, &location.Name, &location.Description, &location.Latitude,
+		&location.Longitude, &location.Address, &location.OrganizationID, &location.IsActive,
+		&location.CreatedAt, &location.UpdatedAt,
+	)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrLocationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get location by ID: %w", err)
+	}
+
+	return location, nil
+}
+
+// UpdateLocation updates location information
+func (r *repository) UpdateLocation(ctx context.Context, id int, req *UpdateLocationRequest) (*Location, error) {
+	// Build dynamic query
+	setParts := []string{}
+	args := []interface{}{}
+	argIndex := 1
+
+	if req.Name != nil {
+		setParts = append(setParts, fmt.Sprintf("name = $%d", argIndex))
+		args = append(args, *req.Name)
+		argIndex++
+	}
+
+	if req.Description != nil {
+		setParts = append(setParts, fmt.Sprintf("description = $%d", argIndex))
+		args = append(args, *req.Description)
+		argIndex++
+	}
+
+	if req.Latitude != nil {
+		setParts = append(setParts, fmt.Sprintf("latitude = $%d", argIndex))
+		args = append(args, *req.Latitude)
+		argIndex++
+	}
+
+	if req.Longitude != nil {
+		setParts = append(setParts, fmt.Sprintf("longitude = $%d", argIndex))
+		args = append(args, *req.Longitude)
+		argIndex++
+	}
+
+	if req.Address != nil {
+		setParts = append(setParts, fmt.Sprintf("address = $%d", argIndex))
+		args = append(args, *req.Address)
+		argIndex++
+	}
+
+	if req.IsActive != nil {
+		setParts = append(setParts, fmt.Sprintf("is_active = $%d", argIndex))
+		args = append(args, *req.IsActive)
+		argIndex++
+	}
+
+	if len(setParts) == 0 {
+		return r.GetLocationByID(ctx, id) // No changes, return current location
+	}
+
+	// Add updated_at
+	setParts = append(setParts, fmt.Sprintf("updated_at = $%d", argIndex))
+	args = append(args, time.Now())
+	argIndex++
+
+	// Add ID for WHERE clause
+	args = append(args, id)
+	orgClause, args := r.orgFilter("organization_id", args)
+
+	query := fmt.Sprintf(`
+		UPDATE %s.locations 
+		SET %s
+		WHERE id = $%d%s
+	`, schema, strings.Join(setParts, ", "), argIndex, orgClause)
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update location: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return nil, ErrLocationNotFound
+	}
+
+	return r.GetLocationByID(ctx, id)
+}
+
+// DeactivateLocation soft deletes a location. It fails with a
+// LocationInUseError while active sensors reference the location unless
+// force is set, in which case they are detached from it. It returns the
+// number of sensors detached.
+func (r *repository) DeactivateLocation(ctx context.Context, id int, force bool) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	args := []interface{}{id}
+	orgClause, args := r.orgFilter("organization_id", args)
+
+	lockQuery := fmt.Sprintf(`
+		SELECT id FROM %s.locations WHERE id = $1 AND is_active = true%s FOR UPDATE
+	`, schema, orgClause)
+
+	var locationID int
+	err = tx.QueryRowContext(ctx, lockQuery, args...).Scan(&locationID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrLocationNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to lock location: %w", err)
+	}
+
+	countQuery := fmt.Sprintf(`
+		SELECT COUNT(*) FROM %s.sensors WHERE location_id = $1 AND is_active = true
+	`, schema)
+
+	var activeSensors int
+	if err := tx.QueryRowContext(ctx, countQuery, locationID).Scan(&activeSensors); err != nil {
+		return 0, fmt.Errorf("failed to count location sensors: %w", err)
+	}
+
+	if activeSensors > 0 && !force {
+		return 0, &LocationInUseError{ActiveSensors: activeSensors}
+	}
+
+	now := time.Now()
+	detached := 0
+	if activeSensors > 0 {
+		detachQuery := fmt.Sprintf(`
+			UPDATE %s.sensors
+			SET location_id = NULL, updated_at = $1
+			WHERE location_id = $2 AND is_active = true
+		`, schema)
+
+		result, err := tx.ExecContext(ctx, detachQuery, now, locationID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to detach location sensors: %w", err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		detached = int(rowsAffected)
+	}
+
+	deactivateQuery := fmt.Sprintf(`
+		UPDATE %s.locations SET is_active = false, updated_at = $1 WHERE id = $2
+	`, schema)
+	if _, err := tx.ExecContext(ctx, deactivateQuery, now, locationID); err != nil {
+		return 0, fmt.Errorf("failed to deactivate location: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return detached, nil
+}
+
+// AssignSensorsToLocation sets the location of the active sensors among
+// sensorIDs the caller can update. The location is locked so it cannot be
+// deactivated while sensors move to it.
+func (r *repository) AssignSensorsToLocation(ctx context.Context, locationID int, sensorIDs []int) (*LocationAssignment, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	args := []interface{}{locationID}
+	orgClause, args := r.orgFilter("organization_id", args)
+
+	lockQuery := fmt.Sprintf(`
+		SELECT is_active FROM %s.locations WHERE id = $1%s FOR SHARE
+	`, schema, orgClause)
+
+	var isActive bool
+	err = tx.QueryRowContext(ctx, lockQuery, args...).Scan(&isActive)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrLocationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock location: %w", err)
+	}
+	if !isActive {
+		return nil, ErrLocationInactive
+	}
+
+	args = []interface{}{locationID, time.Now(), pq.Array(sensorIDs)}
+	orgClause, args = r.orgFilter("s.organization_id", args)
+	accessClause, args := r.accessFilter("s", AccessLevelWrite, args)
+
+	updateQuery := fmt.Sprintf(`
+		UPDATE %s.sensors s
+		SET location_id = $1, updated_at = $2
+		WHERE s.id = ANY($3) AND s.is_active = true%s%s
+		RETURNING s.id
+	`, schema, orgClause, accessClause)
+
+	updated, err := queryIDs(ctx, tx, updateQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to assign sensors: %w", err)
+	}
+
+	args = []interface{}{pq.Array(sensorIDs)}
+	orgClause, args = r.orgFilter("s.organization_id", args)
+	accessClause, args = r.accessFilter("s", AccessLevelWrite, args)
+
+	inactiveQuery := fmt.Sprintf(`
+		SELECT s.id FROM %s.sensors s
+		WHERE s.id = ANY($1) AND s.is_active = false%s%s
+		ORDER BY s.id
+	`, schema, orgClause, accessClause)
+
+	inactive, err := queryIDs(ctx, tx, inactiveQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list inactive sensors: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	found := make(map[int]bool, len(updated)+len(inactive))
+	for _, id := range append(updated, inactive...) {
+		found[id] = true
+	}
+	notFound := []int{}
+	for _, id := range sensorIDs {
+		if !found[id] {
+			notFound = append(notFound, id)
+		}
+	}
+
+	return &LocationAssignment{
+		UpdatedCount: len(updated),
+		NotFound:     notFound,
+		Inactive:     inactive,
+	}, nil
+}
+
+// queryIDs runs a query returning a single integer column
+func queryIDs(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]int, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// ListLocations retrieves all active locations
+func (r *repository) ListLocations(ctx context.Context) ([]*Location, error) {
+	orgClause, args := r.orgFilter("organization_id", []interface{}{})
+
+	query := fmt.Sprintf(`
+		SELECT id, name, description, latitude, longitude, address, organization_id, is_active, created_at, updated_at
+		FROM %s.locations
+		WHERE is_active = true%s
+		ORDER BY name
+	`, schema, orgClause)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list locations: %w", err)
+	}
+	defer rows.Close()
+
+	locations := []*Location{}
+	for rows.Next() {
+		location := &Location{}
+		err := rows.Scan(
+			&location.ID, &location.Name, &location.Description, &location.Latitude,
+			&location.Longitude, &location.Address, &location.OrganizationID, &location.IsActive,
+			&location.CreatedAt, &location.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan location: %w", err)
+		}
+		locations = append(locations, location)
+	}
+
+	return locations, nil
+}
+
+// ListLocationsNear retrieves the active locations within radiusKm of the
+// point, nearest first, with the number of active sensors at each. Distances
+// are great-circle distances computed with the haversine formula.
+func (r *repository) ListLocationsNear(ctx context.Context, lat, lng, radiusKm float64) (*NearbyLocations, error) {
+	args := []interface{}{lat, lng, radiusKm}
+	orgClause, args := r.orgFilter("l.organization_id", args)
+
+	// LEAST guards ASIN against rounding just above 1 for antipodal points
+	query := fmt.Sprintf(`
+		WITH distances AS (
+			SELECT l.*, %[2]d * 2 * ASIN(LEAST(1, SQRT(
+				POWER(SIN(RADIANS(l.latitude::double precision - $1) / 2), 2) +
+				COS(RADIANS($1)) * COS(RADIANS(l.latitude::double precision)) *
+				POWER(SIN(RADIANS(l.longitude::double precision - $2) / 2), 2)
+			))) AS distance_km
+			FROM %[1]s.locations l
+			WHERE l.is_active = true AND l.latitude IS NOT NULL AND l.longitude IS NOT NULL%[3]s
+		)
+		SELECT d.id, d.name, d.description, d.latitude, d.longitude, d.address, d.organization_id,
+		       d.is_active, d.created_at, d.updated_at, d.distance_km,
+		       (SELECT COUNT(*) FROM %[1]s.sensors s
+		        WHERE s.location_id = d.id AND s.is_active = true AND s.is_provisioned = true)
+		FROM distances d
+		WHERE d.distance_km <= $3
+		ORDER BY d.distance_km, d.id
+	`, schema, earthRadiusKm, orgClause)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nearby locations: %w", err)
+	}
+	defer rows.Close()
+
+	result := &NearbyLocations{Locations: []*NearbyLocation{}}
+	for rows.Next() {
+		nearby := &NearbyLocation{Location: &Location{}}
+		err := rows.Scan(
+			&nearby.ID, &nearby.Name, &nearby.Description, &nearby.Latitude,
+			&nearby.Longitude, &nearby.Address, &nearby.OrganizationID, &nearby.IsActive,
+			&nearby.CreatedAt, &nearby.UpdatedAt, &nearby.DistanceKm, &nearby.SensorCount,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan nearby location: %w", err)
+		}
+		result.Locations = append(result.Locations, nearby)
+	}
+
+	skippedOrgClause, skippedArgs := r.orgFilter("organization_id", []interface{}{})
+	skippedQuery := fmt.Sprintf(`
+		SELECT COUNT(*) FROM %s.locations
+		WHERE is_active = true AND (latitude IS NULL OR longitude IS NULL)%s
+	`, schema, skippedOrgClause)
+
+	if err := r.db.QueryRowContext(ctx, skippedQuery, skippedArgs...).Scan(&result.SkippedWithoutCoordinates); err != nil {
+		return nil, fmt.Errorf("failed to count locations without coordinates: %w", err)
+	}
+
+	return result, nil
+}
+
+// CreateSensorReading creates a new sensor reading
+func (r *repository) CreateSensorReading(ctx context.Context, reading *SensorReading) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s.sensor_readings (sensor_id, value, raw_value, timestamp, quality, metadata)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (sensor_id, timestamp) DO NOTHING
+		RETURNING id, created_at
+	`, schema)
+
+	timestamp := reading.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	quality := reading.Quality
+	if quality == 0 {
+		quality = 100 // Default quality
+	}
+
+	err := r.db.QueryRowContext(ctx, query,
+		reading.SensorID, reading.Value, reading.RawValue, timestamp, quality, reading.Metadata).
+		Scan(&reading.ID, &reading.CreatedAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrDuplicateReading
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create sensor reading: %w", err)
+	}
+
+	// Update sensor last reading timestamp
+	if err := r.UpdateSensorLastReading(ctx, reading.SensorID, timestamp); err != nil {
+		// Log warning but don't fail the reading creation
+		fmt.Printf("Warning: failed to update sensor last reading: %v\n", err)
+	}
+
+	return nil
+}
+
+// bulkInsertRows is the number of readings inserted per statement, keeping
+// the parameters of a statement well below the PostgreSQL limit of 65535
+const bulkInsertRows = 1000
+
+// CreateBulkSensorReadings creates multiple sensor readings in a transaction
+// with multi-row inserts and returns how many were inserted per sensor.
+// Duplicates of stored readings are skipped.
+func (r *repository) CreateBulkSensorReadings(ctx context.Context, readings []*SensorReading) (map[int]int, error) {
+	inserted := make(map[int]int)
+	if len(readings) == 0 {
+		return inserted, nil
+	}
+
+	// Start transaction
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	readingIDs := make([]int64, 0, len(readings))
+
+	for start := 0; start < len(readings); start += bulkInsertRows {
+		end := start + bulkInsertRows
+		if end > len(readings) {
+			end = len(readings)
+		}
+		batch := readings[start:end]
+
+		values := make([]string, len(batch))
+		args := make([]interface{}, 0, len(batch)*6)
+		for i, reading := range batch {
+			timestamp := reading.Timestamp
+			if timestamp.IsZero() {
+				timestamp = now
+			}
+
+			quality := reading.Quality
+			if quality == 0 {
+				quality = 100 // Default quality
+			}
+
+			n := len(args)
+			values[i] = fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6)
+			args = append(args, reading.SensorID, reading.Value, reading.RawValue, timestamp, quality, reading.Metadata)
+		}
+
+		query := fmt.Sprintf(`
+			INSERT INTO %s.sensor_readings (sensor_id, value, raw_value, timestamp, quality, metadata)
+			VALUES %s
+			ON CONFLICT (sensor_id, timestamp) DO NOTHING
+			RETURNING id, sensor_id
+		`, schema, strings.Join(values, ", "))
+
+		rows, err := tx.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create sensor readings: %w", err)
+		}
+		for rows.Next() {
+			var id int64
+			var sensorID int
+			if err := rows.Scan(&id, &sensorID); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan sensor reading: %w", err)
+			}
+			readingIDs = append(readingIDs, id)
+			inserted[sensorID]++
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to create sensor readings: %w", err)
+		}
+	}
+
+	// Move each sensor's last reading forward to its newest inserted reading;
+	// backfilled history leaves it unchanged
+	if len(readingIDs) > 0 {
+		updateQuery := fmt.Sprintf(`
+			UPDATE %[1]s.sensors s
+			SET last_reading_at = GREATEST(s.last_reading_at, latest.timestamp), updated_at = $2
+			FROM (
+				SELECT sensor_id, MAX(timestamp) AS timestamp
+				FROM %[1]s.sensor_readings
+				WHERE id = ANY($1)
+				GROUP BY sensor_id
+			) latest
+			WHERE s.id = latest.sensor_id
+		`, schema)
+
+		if _, err := tx.ExecContext(ctx, updateQuery, pq.Array(readingIDs), now); err != nil {
+			return nil, fmt.Errorf("failed to update sensor last reading: %w", err)
+		}
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return inserted, nil
+}
+
+// GetSensorReadings retrieves sensor readings based on query parameters
+func (r *repository) GetSensorReadings(ctx context.Context, query *SensorReadingQuery) ([]*SensorReading, int, error) {
+	// Build WHERE clause
+	whereParts := []string{}
+	args := []interface{}{}
+	argIndex := 1
+
+	if query.SensorID != nil {
+		whereParts = append(whereParts, fmt.Sprintf("sensor_id = $%d", argIndex))
+		args = append(args, *query.SensorID)
+		argIndex++
+	} else {
+		// Readings of pending sensors are only listed for the sensor itself
+		whereParts = append(whereParts, fmt.Sprintf(
+			"sensor_id NOT IN (SELECT id FROM %s.sensors WHERE is_provisioned = false)", schema))
+	}
+
+	if query.StartTime != nil {
+		whereParts = append(whereParts, fmt.Sprintf("timestamp >= $%d", argIndex))
+		args = append(args, *query.StartTime)
+		argIndex++
+	}
+
+	if query.EndTime != nil {
+		whereParts = append(whereParts, fmt.Sprintf("timestamp <= $%d", argIndex))
+		args = append(args, *query.EndTime)
+		argIndex++
+	}
+
+	if query.MinQuality != nil {
+		whereParts = append(whereParts, fmt.Sprintf("quality >= $%d", argIndex))
+		args = append(args, *query.MinQuality)
+		argIndex++
+	}
+
+	if query.MinValue != nil {
+		whereParts = append(whereParts, fmt.Sprintf("value >= $%d", argIndex))
+		args = append(args, *query.MinValue)
+		argIndex++
+	}
+
+	if query.MaxValue != nil {
+		whereParts = append(whereParts, fmt.Sprintf("value <= $%d", argIndex))
+		args = append(args, *query.MaxValue)
+		argIndex++
+	}
+
+	if r.scope.Restricted || r.access.Restricted {
+		orgClause, scopedArgs := r.orgFilter("s.organization_id", args)
+		accessClause, scopedArgs := r.accessFilter("s", AccessLevelRead, scopedArgs)
+		whereParts = append(whereParts, fmt.Sprintf(
+			"sensor_id IN (SELECT s.id FROM %s.sensors s WHERE true%s%s)", schema, orgClause, accessClause))
+		args = scopedArgs
+		argIndex = len(args) + 1
+	}
+
+	whereClause := ""
+	if len(whereParts) > 0 {
+		whereClause = "WHERE " + strings.Join(whereParts, " AND ")
+	}
+
+	// Get total count
+	countQuery := fmt.Sprintf(`
+		SELECT COUNT(*) FROM %s.sensor_readings %s
+	`, schema, whereClause)
+
+	var total int
+	err := r.db.QueryRowContext(ctx, countQuery, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count sensor readings: %w", err)
+	}
+
+	// Get readings
+	limit := query.Limit
+	if limit <= 0 {
+		limit = 100 // Default limit
+	}
+
+	offset := query.Offset
+	if offset < 0 {
+		offset = 0
+	}
+
+	// Add limit and offset to args
+	args = append(args, limit, offset)
+
+	order := "DESC"
+	if query.Ascending {
+		order = "ASC"
+	}
+
+	// The moving average runs over every reading matching the filters, so
+	// readings at the edge of a page are still averaged with their neighbours
+	smoothColumns := "NULL::double precision, NULL::double precision"
+	windowClause := ""
+	if query.SmoothWindow > 0 {
+		smoothColumns = "AVG(value) OVER smoothing, AVG(COALESCE(raw_value, value)) OVER smoothing"
+		windowClause = fmt.Sprintf(
+			"WINDOW smoothing AS (PARTITION BY sensor_id ORDER BY timestamp ROWS BETWEEN %d PRECEDING AND %d FOLLOWING)",
+			(query.SmoothWindow-1)/2, query.SmoothWindow/2)
+	}
+
+	readingsQuery := fmt.Sprintf(`
+		SELECT id, sensor_id, value, COALESCE(raw_value, value), timestamp, quality, metadata, created_at, %s
+		FROM %s.sensor_readings
+		%s
+		%s
+		ORDER BY timestamp %s, id %s
+		LIMIT $%d OFFSET $%d
+	`, smoothColumns, schema, whereClause, windowClause, order, order, argIndex, argIndex+1)
+
+	rows, err := r.db.QueryContext(ctx, readingsQuery, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get sensor readings: %w", err)
+	}
+	defer rows.Close()
+
+	readings := []*SensorReading{}
+	for rows.Next() {
+		reading := &SensorReading{}
+		err := rows.Scan(
+			&reading.ID, &reading.SensorID, &reading.Value, &reading.RawValue, &reading.Timestamp,
+			&reading.Quality, &reading.Metadata, &reading.CreatedAt, &reading.Smoothed, &reading.RawSmoothed,
+		)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan sensor reading: %w", err)
+		}
+		readings = append(readings, reading)
+	}
+
+	return readings, total, nil
+}
+
+// GetLatestReading retrieves the latest reading for a sensor
+func (r *repository) GetLatestReading(ctx context.Context, sensorID int) (*SensorReading, error) {
+	query := fmt.Sprintf(`
+		SELECT id, sensor_id, value, COALESCE(raw_value, value), timestamp, quality, metadata, created_at
+		FROM %s.sensor_readings
+		WHERE sensor_id = $1
+		ORDER BY timestamp DESC
+		LIMIT 1
+	`, schema)
+
+	reading := &SensorReading{}
+	err := r.db.QueryRowContext(ctx, query, sensorID).Scan(
+		&reading.ID, &reading.SensorID, &reading.Value, &reading.RawValue, &reading.Timestamp,
+		&reading.Quality, &reading.Metadata, &reading.CreatedAt,
+	)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil // No readings yet
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest reading: %w", err)
+	}
+
+	return reading, nil
+}
+
+// GetReadingByID retrieves a sensor reading by ID
+func (r *repository) GetReadingByID(ctx context.Context, id int64) (*SensorReading, error) {
+	args := []interface{}{id}
+	orgClause, args := r.orgFilter("s.organization_id", args)
+	accessClause, args := r.accessFilter("s", AccessLevelRead, args)
+
+	query := fmt.Sprintf(`
+		SELECT sr.id, sr.sensor_id, sr.value, COALESCE(sr.raw_value, sr.value), sr.timestamp, sr.quality, sr.metadata, sr.created_at
+		FROM %s.sensor_readings sr
+		INNER JOIN %s.sensors s ON s.id = sr.sensor_id
+		WHERE sr.id = $1%s%s
+	`, schema, schema, orgClause, accessClause)
+
+	reading := &SensorReading{}
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(
+		&reading.ID, &reading.SensorID, &reading.Value, &reading.RawValue, &reading.Timestamp,
+		&reading.Quality, &reading.Metadata, &reading.CreatedAt,
+	)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrReadingNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sensor reading: %w", err)
+	}
+
+	return reading, nil
+}
+
+// UpdateReadingQuality updates the quality of a reading and merges metadata
+// into it. Every edit is appended to the "edits" list of the metadata with
+// the editing user, the time and the previous quality.
+func (r *repository) UpdateReadingQuality(ctx context.Context, id int64, req *UpdateReadingQualityRequest, editedBy int) (*SensorReading, error) {
+	var metadata interface{}
+	if len(req.Metadata) > 0 {
+		metadata = string(req.Metadata)
+	}
+
+	args := []interface{}{id, req.Quality, metadata, editedBy}
+	orgClause, args := r.orgFilter("s.organization_id", args)
+	accessClause, args := r.accessFilter("s", AccessLevelWrite, args)
+
+	query := fmt.Sprintf(`
+		UPDATE %s.sensor_readings sr
+		SET quality = COALESCE($2, sr.quality),
+		    metadata = COALESCE(sr.metadata, '{}'::jsonb) || COALESCE($3::jsonb, '{}'::jsonb)
+		        || jsonb_build_object('edits', COALESCE(sr.metadata->'edits', '[]'::jsonb) || jsonb_build_array(
+		            jsonb_build_object('edited_by', $4::integer, 'edited_at', NOW(), 'previous_quality', sr.quality)))
+		FROM %s.sensors s
+		WHERE sr.id = $1 AND s.id = sr.sensor_id%s%s
+		RETURNING sr.id, sr.sensor_id, sr.value, COALESCE(sr.raw_value, sr.value), sr.timestamp, sr.quality, sr.metadata, sr.created_at
+	`, schema, schema, orgClause, accessClause)
+
+	reading := &SensorReading{}
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(
+		&reading.ID, &reading.SensorID, &reading.Value, &reading.RawValue, &reading.Timestamp,
+		&reading.Quality, &reading.Metadata, &reading.CreatedAt,
+	)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrReadingNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update sensor reading: %w", err)
+	}
+
+	return reading, nil
+}
+
+// GetSensorStatistics calculates statistics for a sensor within time range;
+// the last value is the latest reading in the range too
+func (r *repository) GetSensorStatistics(ctx context.Context, sensorID int, startTime, endTime time.Time) (*SensorStatistics, error) {
+	query := fmt.Sprintf(`
+		SELECT 
+			COUNT(*) as count,
+			MIN(value) as min_value,
+			MAX(value) as max_value,
+			AVG(value) as avg_value,
+			STDDEV_SAMP(value) as stddev,
+			PERCENTILE_CONT(0.5) WITHIN GROUP (ORDER BY value) as median,
+			PERCENTILE_CONT(0.95) WITHIN GROUP (ORDER BY value) as p95,
+			(SELECT value FROM %s.sensor_readings
+			 WHERE sensor_id = $1 AND timestamp >= $2 AND timestamp <= $3
+			 ORDER BY timestamp DESC LIMIT 1) as last_value,
+			(SELECT timestamp FROM %s.sensor_readings
+			 WHERE sensor_id = $1 AND timestamp >= $2 AND timestamp <= $3
+			 ORDER BY timestamp DESC LIMIT 1) as last_timestamp
+		FROM %s.sensor_readings
+		WHERE sensor_id = $1 AND timestamp >= $2 AND timestamp <= $3
+	`, schema, schema, schema)
+
+	stats := &SensorStatistics{
+		SensorID: sensorID,
+		Period:   fmt.Sprintf("%s to %s", startTime.Format("2006-01-02"), endTime.Format("2006-01-02")),
+	}
+
+	var lastTimestamp sql.NullTime
+
+	err := r.db.QueryRowContext(ctx, query, sensorID, startTime, endTime).Scan(
+		&stats.Count, &stats.MinValue, &stats.MaxValue, &stats.AvgValue,
+		&stats.StdDev, &stats.Median, &stats.P95, &stats.LastValue, &lastTimestamp,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sensor statistics: %w", err)
+	}
+
+	if lastTimestamp.Valid {
+		stats.LastTimestamp = &lastTimestamp.Time
+	}
+
+	return stats, nil
+}
+
+// GetAggregatedReadings groups a sensor's readings between the start
+// (inclusive) and end (exclusive) time into buckets of the query interval,
+// aligned to the Unix epoch in UTC. Only buckets holding readings are returned.
+func (r *repository) GetAggregatedReadings(ctx context.Context, sensorID int, query *ReadingAggregateQuery) ([]*ReadingBucket, error) {
+	sqlQuery := fmt.Sprintf(`
+		SELECT to_timestamp(floor(extract(epoch FROM timestamp)::double precision / $2) * $2)
+		           AT TIME ZONE 'UTC' AS bucket_start,
+		       AVG(value), MIN(value), MAX(value), SUM(value), COUNT(*)
+		FROM %s.sensor_readings
+		WHERE sensor_id = $1 AND timestamp >= $3 AND timestamp < $4
+		GROUP BY bucket_start
+		ORDER BY bucket_start
+	`, schema)
+
+	rows, err := r.db.QueryContext(ctx, sqlQuery, sensorID, query.Interval.Seconds(), query.StartTime, query.EndTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate sensor readings: %w", err)
+	}
+	defer rows.Close()
+
+	buckets := []*ReadingBucket{}
+	for rows.Next() {
+		bucket := &ReadingBucket{}
+		err := rows.Scan(
+			&bucket.BucketStart, &bucket.Avg, &bucket.Min, &bucket.Max, &bucket.Sum, &bucket.Count,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan reading bucket: %w", err)
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	return buckets, nil
+}
+
+// GetDailyStatistics summarizes a sensor's readings between the start
+// (inclusive) and end (exclusive) time per calendar day in the time zone.
+// Only days holding readings are returned.
+func (r *repository) GetDailyStatistics(ctx context.Context, sensorID int, startTime, endTime time.Time, timezone string) ([]*DailyStatistics, error) {
+	// Timestamps are stored in UTC, so they are converted to the time zone
+	// before being truncated to the local day
+	query := fmt.Sprintf(`
+		SELECT date_trunc('day', (timestamp AT TIME ZONE 'UTC') AT TIME ZONE $2)::date AS day,
+		       COUNT(*), MIN(value), MAX(value), AVG(value)
+		FROM %s.sensor_readings
+		WHERE sensor_id = $1 AND timestamp >= $3 AND timestamp < $4
+		GROUP BY day
+		ORDER BY day
+	`, schema)
+
+	rows, err := r.db.QueryContext(ctx, query, sensorID, timezone, startTime, endTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get daily sensor statistics: %w", err)
+	}
+	defer rows.Close()
+
+	days := []*DailyStatistics{}
+	for rows.Next() {
+		var day time.Time
+		stats := &DailyStatistics{}
+		if err := rows.Scan(&day, &stats.Count, &stats.Min, &stats.Max, &stats.Avg); err != nil {
+			return nil, fmt.Errorf("failed to scan daily sensor statistics: %w", err)
+		}
+		stats.Date = day.Format("2006-01-02")
+		days = append(days, stats)
+	}
+
+	return days, nil
+}
+
+// ListReadingGaps finds the gaps longer than minGap between consecutive
+// readings of a sensor within the time range, and between the range bounds
+// and the nearest reading, so a range without readings is one gap. It lists
+// up to limit gaps, oldest first, and counts and sums all of them.
+func (r *repository) ListReadingGaps(ctx context.Context, sensorID int, startTime, endTime time.Time, minGap time.Duration, limit int) (*GapReport, error) {
+	query := fmt.Sprintf(`
+		WITH points AS (
+			SELECT $2::timestamp AS ts
+			UNION ALL
+			SELECT timestamp FROM %s.sensor_readings
+			WHERE sensor_id = $1 AND timestamp > $2 AND timestamp < $3
+			UNION ALL
+			SELECT $3::timestamp
+		), gaps AS (
+			SELECT lag(ts) OVER (ORDER BY ts) AS gap_start, ts AS gap_end
+			FROM points
+		), long_gaps AS (
+			SELECT gap_start, gap_end, EXTRACT(EPOCH FROM gap_end - gap_start)::double precision AS seconds
+			FROM gaps
+			WHERE gap_end - gap_start > $4 * INTERVAL '1 second'
+		)
+		SELECT gap_start, gap_end, seconds, COUNT(*) OVER (), SUM(seconds) OVER ()
+		FROM long_gaps
+		ORDER BY gap_start
+		LIMIT $5
+	`, schema)
+
+	rows, err := r.db.QueryContext(ctx, query, sensorID, startTime, endTime, minGap.Seconds(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sensor reading gaps: %w", err)
+	}
+	defer rows.Close()
+
+	report := &GapReport{Gaps: []*ReadingGap{}}
+	for rows.Next() {
+		gap := &ReadingGap{}
+		err := rows.Scan(&gap.Start, &gap.End, &gap.DurationSeconds, &report.GapCount, &report.TotalGapSeconds)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan sensor reading gap: %w", err)
+		}
+		report.Gaps = append(report.Gaps, gap)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list sensor reading gaps: %w", err)
+	}
+
+	return report, nil
+}
+
+// GetValueSpread returns the count, mean and sample standard deviation of a
+// sensor's reading values between the start (inclusive) and end (exclusive)
+// time; mean and stddev are nil without enough readings
+func (r *repository) GetValueSpread(ctx context.Context, sensorID int, startTime, endTime time.Time) (int64, *float64, *float64, error) {
+	query := fmt.Sprintf(`
+		SELECT COUNT(*), AVG(value), STDDEV_SAMP(value)
+		FROM %s.sensor_readings
+		WHERE sensor_id = $1 AND timestamp >= $2 AND timestamp < $3
+	`, schema)
+
+	var count int64
+	var mean, stddev *float64
+	if err := r.db.QueryRowContext(ctx, query, sensorID, startTime, endTime).Scan(&count, &mean, &stddev); err != nil {
+		return 0, nil, nil, fmt.Errorf("failed to get sensor reading spread: %w", err)
+	}
+
+	return count, mean, stddev, nil
+}
+
+// ListReadingsOutside retrieves up to limit readings of a sensor between the
+// start (inclusive) and end (exclusive) time whose value is below lower or
+// above upper, oldest first
+func (r *repository) ListReadingsOutside(ctx context.Context, sensorID int, startTime, endTime time.Time, lower, upper float64, limit int) ([]*SensorReading, error) {
+	query := fmt.Sprintf(`
+		SELECT id, sensor_id, value, COALESCE(raw_value, value), timestamp, quality, metadata, created_at
+		FROM %s.sensor_readings
+		WHERE sensor_id = $1 AND timestamp >= $2 AND timestamp < $3
+		  AND (value < $4 OR value > $5)
+		ORDER BY timestamp, id
+		LIMIT $6
+	`, schema)
+
+	rows, err := r.db.QueryContext(ctx, query, sensorID, startTime, endTime, lower, upper, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sensor readings: %w", err)
+	}
+	defer rows.Close()
+
+	readings := []*SensorReading{}
+	for rows.Next() {
+		reading := &SensorReading{}
+		err := rows.Scan(
+			&reading.ID, &reading.SensorID, &reading.Value, &reading.RawValue, &reading.Timestamp,
+			&reading.Quality, &reading.Metadata, &reading.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan sensor reading: %w", err)
+		}
+		readings = append(readings, reading)
+	}
+
+	return readings, nil
+}
+
+// GetReadingGaps finds the gaps longer than threshold between a sensor's
+// readings within the time range, counting the range bounds as readings.
+// It fills in the gap count, total downtime and longest gap.
+func (r *repository) GetReadingGaps(ctx context.Context, sensorID int, startTime, endTime time.Time, threshold time.Duration) (*SensorAvailability, error) {
+	query := fmt.Sprintf(`
+		WITH points AS (
+			SELECT $2::timestamp AS ts
+			UNION ALL
+			SELECT timestamp FROM %s.sensor_readings
+			WHERE sensor_id = $1 AND timestamp > $2 AND timestamp < $3
+			UNION ALL
+			SELECT $3::timestamp
+		), gaps AS (
+			SELECT lag(ts) OVER (ORDER BY ts) AS gap_start, ts AS gap_end
+			FROM points
+		), long_gaps AS (
+			SELECT gap_start, gap_end, EXTRACT(EPOCH FROM gap_end - gap_start)::double precision AS seconds
+			FROM gaps
+			WHERE gap_end - gap_start > $4 * INTERVAL '1 second'
+		), longest AS (
+			SELECT gap_start, gap_end, seconds FROM long_gaps
+			ORDER BY seconds DESC, gap_start
+			LIMIT 1
+		)
+		SELECT (SELECT COUNT(*) FROM long_gaps),
+		       (SELECT COALESCE(SUM(seconds), 0) FROM long_gaps),
+		       longest.gap_start, longest.gap_end, longest.seconds
+		FROM (SELECT 1) one
+		LEFT JOIN longest ON true
+	`, schema)
+
+	availability := &SensorAvailability{}
+	var gapStart, gapEnd sql.NullTime
+	var gapSeconds sql.NullFloat64
+
+	err := r.db.QueryRowContext(ctx, query, sensorID, startTime, endTime, threshold.Seconds()).Scan(
+		&availability.Gaps, &availability.DowntimeSeconds, &gapStart, &gapEnd, &gapSeconds,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sensor reading gaps: %w", err)
+	}
+
+	if gapStart.Valid {
+		availability.LongestGap = &ReadingGap{
+			Start:           gapStart.Time,
+			End:             gapEnd.Time,
+			DurationSeconds: gapSeconds.Float64,
+		}
+	}
+
+	return availability, nil
+}
+
+// readingsBeforeClause returns the condition selecting readings older than
+// cutoff, of one sensor when sensorID is set, within the repository scope
+func (r *repository) readingsBeforeClause(sensorID *int, cutoff time.Time) (string, []interface{}) {
+	whereParts := []string{"timestamp < $1"}
+	args := []interface{}{cutoff}
+
+	if sensorID != nil {
+		args = append(args, *sensorID)
+		whereParts = append(whereParts, fmt.Sprintf("sensor_id = $%d", len(args)))
+	}
+
+	if r.scope.Restricted {
+		orgClause, scopedArgs := r.orgFilter("organization_id", args)
+		whereParts = append(whereParts, fmt.Sprintf(
+			"sensor_id IN (SELECT id FROM %s.sensors WHERE true%s)", schema, orgClause))
+		args = scopedArgs
+	}
+
+	return strings.Join(whereParts, " AND "), args
+}
+
+// CountReadingsBefore counts the readings older than cutoff
+func (r *repository) CountReadingsBefore(ctx context.Context, sensorID *int, cutoff time.Time) (int64, error) {
+	whereClause, args := r.readingsBeforeClause(sensorID, cutoff)
+
+	query := fmt.Sprintf(`
+		SELECT COUNT(*) FROM %s.sensor_readings WHERE %s
+	`, schema, whereClause)
+
+	var count int64
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count sensor readings: %w", err)
+	}
+
+	return count, nil
+}
+
+// DeleteReadingsBefore deletes the readings older than cutoff in batches
+// so no single statement holds locks on the readings table for long
+func (r *repository) DeleteReadingsBefore(ctx context.Context, sensorID *int, cutoff time.Time) (int64, error) {
+	whereClause, args := r.readingsBeforeClause(sensorID, cutoff)
+	return r.deleteReadingsInBatches(ctx, whereClause, args)
+}
+
+// DeleteReadingsInRange deletes a sensor's readings between startTime and
+// endTime inclusive and recomputes the sensor's last reading time when the
+// latest reading was inside the window
+func (r *repository) DeleteReadingsInRange(ctx context.Context, sensorID int, startTime, endTime time.Time) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	args := []interface{}{sensorID}
+	orgClause, args := r.orgFilter("s.organization_id", args)
+	accessClause, args := r.accessFilter("s", AccessLevelWrite, args)
+
+	// Lock the sensor so its last reading time is not updated concurrently
+	lockQuery := fmt.Sprintf(`
+		SELECT s.id FROM %s.sensors s WHERE s.id = $1%s%s FOR UPDATE OF s
+	`, schema, orgClause, accessClause)
+
+	var id int
+	err = tx.QueryRowContext(ctx, lockQuery, args...).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrSensorNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to lock sensor: %w", err)
+	}
+
+	deleteQuery := fmt.Sprintf(`
+		DELETE FROM %s.sensor_readings
+		WHERE sensor_id = $1 AND timestamp >= $2 AND timestamp <= $3
+	`, schema)
+
+	result, err := tx.ExecContext(ctx, deleteQuery, id, startTime, endTime)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete sensor readings: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	lastReadingQuery := fmt.Sprintf(`
+		UPDATE %s.sensors
+		SET last_reading_at = (
+			SELECT MAX(timestamp) FROM %s.sensor_readings WHERE sensor_id = $1
+		), updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND last_reading_at >= $2 AND last_reading_at <= $3
+	`, schema, schema)
+
+	if _, err := tx.ExecContext(ctx, lastReadingQuery, id, startTime, endTime); err != nil {
+		return 0, fmt.Errorf("failed to update sensor last reading: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return deleted, nil
+}
+
+// DeleteExpiredReadings deletes the readings older than the retention of
+// their sensor type, or defaultRetentionDays when the type does not
+// override it. A default of 0 keeps readings of such types forever.
+func (r *repository) DeleteExpiredReadings(ctx context.Context, defaultRetentionDays int) (int64, error) {
 	query := fmt.Sprintf(`
-		UPDATE %s.locations 
+		SELECT id, COALESCE(retention_days, $1) FROM %s.sensor_types
+	`, schema)
+
+	rows, err := r.db.QueryContext(ctx, query, defaultRetentionDays)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get sensor type retention: %w", err)
+	}
+
+	retention := make(map[int]int)
+	for rows.Next() {
+		var typeID, days int
+		if err := rows.Scan(&typeID, &days); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan sensor type retention: %w", err)
+		}
+		retention[typeID] = days
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get sensor type retention: %w", err)
+	}
+
+	now := time.Now()
+	var deleted int64
+	for typeID, days := range retention {
+		if days <= 0 {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return deleted, err
+		}
+
+		whereClause := fmt.Sprintf(
+			"timestamp < $1 AND sensor_id IN (SELECT id FROM %s.sensors WHERE sensor_type_id = $2)", schema)
+		count, err := r.deleteReadingsInBatches(ctx, whereClause, []interface{}{now.AddDate(0, 0, -days), typeID})
+		deleted += count
+		if err != nil {
+			return deleted, err
+		}
+	}
+
+	return deleted, nil
+}
+
+// deleteReadingsInBatches deletes the readings matching whereClause with
+// one statement per purgeBatchSize rows, stopping between batches once ctx
+// is done
+func (r *repository) deleteReadingsInBatches(ctx context.Context, whereClause string, args []interface{}) (int64, error) {
+	query := fmt.Sprintf(`
+		DELETE FROM %s.sensor_readings
+		WHERE id IN (
+			SELECT id FROM %s.sensor_readings WHERE %s LIMIT $%d
+		)
+	`, schema, schema, whereClause, len(args)+1)
+	args = append(args, purgeBatchSize)
+
+	var deleted int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return deleted, err
+		}
+
+		result, err := r.db.ExecContext(ctx, query, args...)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete sensor readings: %w", err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return deleted, fmt.Errorf("failed to get rows affected: %w", err)
+		}
+
+		deleted += rowsAffected
+		if rowsAffected < purgeBatchSize {
+			return deleted, nil
+		}
+	}
+}
+
+// UpdateSensorLastReading moves sensor's last reading timestamp forward to
+// timestamp; late and backfilled readings leave it unchanged
+func (r *repository) UpdateSensorLastReading(ctx context.Context, sensorID int, timestamp time.Time) error {
+	query := fmt.Sprintf(`
+		UPDATE %s.sensors
+		SET last_reading_at = GREATEST(COALESCE(last_reading_at, $1), $1), updated_at = $2
+		WHERE id = $3
+	`, schema)
+
+	_, err := r.db.ExecContext(ctx, query, timestamp, time.Now(), sensorID)
+	if err != nil {
+		return fmt.Errorf("failed to update sensor last reading: %w", err)
+	}
+
+	return nil
+}
+
+// onlineSinceExpr returns the SQL time after which the last reading of the
+// sensor aliased as alias must fall for it to be online, given the
+// positions of the current time and the fallback threshold in seconds
+func onlineSinceExpr(alias string, nowArg, fallbackArg int) string {
+	return fmt.Sprintf("($%d::timestamp - COALESCE(%s.expected_interval_seconds * %d, $%d) * INTERVAL '1 second')",
+		nowArg, alias, OnlineIntervalMultiplier, fallbackArg)
+}
+
+// RecordStatusTransitions records an event for every active sensor whose
+// status changed since its latest event: sensors without readings within
+// their online threshold go offline, offline sensors with newer readings
+// come back online. fallback is the threshold of sensors without an
+// expected interval. Sensors without events count as online; sensors that
+// never reported or are in maintenance are skipped, so a sensor still silent
+// when its maintenance ends goes offline then.
+func (r *repository) RecordStatusTransitions(ctx context.Context, now time.Time, fallback time.Duration) ([]*SensorStatusEvent, error) {
+	query := fmt.Sprintf(`
+		WITH current AS (
+			SELECT s.id, s.last_reading_at,
+			       CASE WHEN s.last_reading_at <= %s THEN '%s' ELSE '%s' END AS status,
+			       COALESCE(latest.status, '%s') AS previous_status
+			FROM %s.sensors s
+			LEFT JOIN LATERAL (
+				SELECT e.status FROM %s.sensor_status_events e
+				WHERE e.sensor_id = s.id
+				ORDER BY e.occurred_at DESC, e.id DESC
+				LIMIT 1
+			) latest ON true
+			WHERE s.is_active = true AND s.is_provisioned = true AND s.last_reading_at IS NOT NULL
+			  AND (s.maintenance_until IS NULL OR s.maintenance_until <= $1)
+		), inserted AS (
+			INSERT INTO %s.sensor_status_events (sensor_id, status, last_reading_at)
+			SELECT id, status, last_reading_at FROM current WHERE status <> previous_status
+			RETURNING id, sensor_id, status, last_reading_at, occurred_at
+		)
+		SELECT i.id, i.sensor_id, i.status, i.last_reading_at, i.occurred_at,
+		       s.device_id, s.name, s.organization_id
+		FROM inserted i
+		INNER JOIN %s.sensors s ON s.id = i.sensor_id
+		ORDER BY i.id
+	`, onlineSinceExpr("s", 1, 2), SensorStatusOffline, SensorStatusOnline, SensorStatusOnline, schema, schema, schema, schema)
+
+	rows, err := r.db.QueryContext(ctx, query, now, int(fallback.Seconds()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to record sensor status transitions: %w", err)
+	}
+	defer rows.Close()
+
+	events := []*SensorStatusEvent{}
+	for rows.Next() {
+		event := &SensorStatusEvent{}
+		err := rows.Scan(
+			&event.ID, &event.SensorID, &event.Status, &event.LastReadingAt, &event.OccurredAt,
+			&event.DeviceID, &event.SensorName, &event.OrganizationID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan sensor status event: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// ListStatusEvents retrieves the status transitions of a sensor, newest first
+func (r *repository) ListStatusEvents(ctx context.Context, sensorID, limit, offset int) ([]*SensorStatusEvent, int, error) {
+	countQuery := fmt.Sprintf(`
+		SELECT COUNT(*) FROM %s.sensor_status_events WHERE sensor_id = $1
+	`, schema)
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, countQuery, sensorID).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count sensor status events: %w", err)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, sensor_id, status, last_reading_at, occurred_at
+		FROM %s.sensor_status_events
+		WHERE sensor_id = $1
+		ORDER BY occurred_at DESC, id DESC
+		LIMIT $2 OFFSET $3
+	`, schema)
+
+	rows, err := r.db.QueryContext(ctx, query, sensorID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list sensor status events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []*SensorStatusEvent{}
+	for rows.Next() {
+		event := &SensorStatusEvent{}
+		err := rows.Scan(&event.ID, &event.SensorID, &event.Status, &event.LastReadingAt, &event.OccurredAt)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan sensor status event: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	return events, total, nil
+}
+
+// CreateSensorAccess creates a sensor access grant
+func (r *repository) CreateSensorAccess(ctx context.Context, access *SensorAccess) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s.sensor_access (sensor_id, location_id, user_id, role_id, access_level, granted_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, schema)
+
+	err := r.db.QueryRowContext(ctx, query,
+		access.SensorID, access.LocationID, access.UserID, access.RoleID,
+		access.AccessLevel, access.GrantedBy).
+		Scan(&access.ID, &access.CreatedAt)
+
+	if err != nil {
+		if database.IsUniqueViolation(err) {
+			return ErrAccessExists
+		}
+		if database.IsForeignKeyViolation(err) {
+			return ErrGranteeNotFound
+		}
+		return fmt.Errorf("failed to create sensor access: %w", err)
+	}
+
+	return nil
+}
+
+// ListSensorAccess retrieves the access grants on a sensor or a location
+func (r *repository) ListSensorAccess(ctx context.Context, sensorID, locationID *int) ([]*SensorAccess, error) {
+	whereParts := []string{}
+	args := []interface{}{}
+	argIndex := 1
+
+	if sensorID != nil {
+		whereParts = append(whereParts, fmt.Sprintf("a.sensor_id = $%d", argIndex))
+		args = append(args, *sensorID)
+		argIndex++
+	}
+
+	if locationID != nil {
+		whereParts = append(whereParts, fmt.Sprintf("a.location_id = $%d", argIndex))
+		args = append(args, *locationID)
+		argIndex++
+	}
+
+	whereClause := "true"
+	if len(whereParts) > 0 {
+		whereClause = strings.Join(whereParts, " AND ")
+	}
+	orgClause, args := r.orgFilter("COALESCE(s.organization_id, l.organization_id)", args)
+
+	query := fmt.Sprintf(`
+		SELECT a.id, a.sensor_id, a.location_id, a.user_id, a.role_id,
+		       a.access_level, a.granted_by, a.created_at
+		FROM %s.sensor_access a
+		LEFT JOIN %s.sensors s ON a.sensor_id = s.id
+		LEFT JOIN %s.locations l ON a.location_id = l.id
+		WHERE %s%s
+		ORDER BY a.created_at
+	`, schema, schema, schema, whereClause, orgClause)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sensor access: %w", err)
+	}
+	defer rows.Close()
+
+	grants := []*SensorAccess{}
+	for rows.Next() {
+		access := &SensorAccess{}
+		var sensorID, locationID, userID, roleID, grantedBy sql.NullInt64
+
+		err := rows.Scan(
+			&access.ID, &sensorID, &locationID, &userID, &roleID,
+			&access.AccessLevel, &grantedBy, &access.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan sensor access: %w", err)
+		}
+
+		access.SensorID = nullIntPtr(sensorID)
+		access.LocationID = nullIntPtr(locationID)
+		access.UserID = nullIntPtr(userID)
+		access.RoleID = nullIntPtr(roleID)
+		access.GrantedBy = nullIntPtr(grantedBy)
+
+		grants = append(grants, access)
+	}
+
+	return grants, nil
+}
+
+// DeleteSensorAccess removes a sensor access grant
+func (r *repository) DeleteSensorAccess(ctx context.Context, id int) error {
+	args := []interface{}{id}
+	orgClause, args := r.orgFilter("COALESCE(s.organization_id, l.organization_id)", args)
+
+	query := fmt.Sprintf(`
+		DELETE FROM %s.sensor_access
+		WHERE id IN (
+			SELECT a.id
+			FROM %s.sensor_access a
+			LEFT JOIN %s.sensors s ON a.sensor_id = s.id
+			LEFT JOIN %s.locations l ON a.location_id = l.id
+			WHERE a.id = $1%s
+		)
+	`, schema, schema, schema, schema, orgClause)
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to delete sensor access: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrAccessNotFound
+	}
+
+	return nil
+}
+
+// nullIntPtr converts a nullable integer column to *int
+func nullIntPtr(value sql.NullInt64) *int {
+	if !value.Valid {
+		return nil
+	}
+	v := int(value.Int64)
+	return &v
+}
+
+// escapeLike escapes LIKE wildcards in user supplied search terms
+func escapeLike(term string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+	return replacer.Replace(term)
+}
+
+// sensorGroupColumns are the sensor group columns scanned by scanSensorGroup
+var sensorGroupColumns = fmt.Sprintf(`
+	g.id, g.organization_id, g.name, g.description,
+	(SELECT COUNT(*) FROM %s.sensor_group_members m WHERE m.group_id = g.id),
+	g.created_by, g.created_at, g.updated_at`, schema)
+
+// scanSensorGroup scans a row selected with sensorGroupColumns
+func scanSensorGroup(row rowScanner) (*SensorGroup, error) {
+	group := &SensorGroup{}
+	err := row.Scan(
+		&group.ID, &group.OrganizationID, &group.Name, &group.Description,
+		&group.SensorCount, &group.CreatedBy, &group.CreatedAt, &group.UpdatedAt,
+	)
+	return group, err
+}
+
+// CreateSensorGroup creates a new sensor group
+func (r *repository) CreateSensorGroup(ctx context.Context, group *SensorGroup) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s.sensor_groups (organization_id, name, description, created_by)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at
+	`, schema)
+
+	group.OrganizationID = r.organizationID()
+
+	err := r.db.QueryRowContext(ctx, query, group.OrganizationID, group.Name, group.Description, group.CreatedBy).
+		Scan(&group.ID, &group.CreatedAt, &group.UpdatedAt)
+	if err != nil {
+		if database.IsUniqueViolation(err) {
+			return ErrGroupExists
+		}
+		return fmt.Errorf("failed to create sensor group: %w", err)
+	}
+
+	return nil
+}
+
+// GetSensorGroupByID retrieves sensor group by ID
+func (r *repository) GetSensorGroupByID(ctx context.Context, id int) (*SensorGroup, error) {
+	args := []interface{}{id}
+	orgClause, args := r.orgFilter("g.organization_id", args)
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM %s.sensor_groups g
+		WHERE g.id = $1%s
+	`, sensorGroupColumns, schema, orgClause)
+
+	group, err := scanSensorGroup(r.db.QueryRowContext(ctx, query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrGroupNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sensor group by ID: %w", err)
+	}
+
+	return group, nil
+}
+
+// ListSensorGroups retrieves all sensor groups in scope
+func (r *repository) ListSensorGroups(ctx context.Context) ([]*SensorGroup, error) {
+	orgClause, args := r.orgFilter("g.organization_id", nil)
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM %s.sensor_groups g
+		WHERE true%s
+		ORDER BY g.name
+	`, sensorGroupColumns, schema, orgClause)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sensor groups: %w", err)
+	}
+	defer rows.Close()
+
+	groups := []*SensorGroup{}
+	for rows.Next() {
+		group, err := scanSensorGroup(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan sensor group: %w", err)
+		}
+		groups = append(groups, group)
+	}
+
+	return groups, nil
+}
+
+// UpdateSensorGroup updates sensor group information
+func (r *repository) UpdateSensorGroup(ctx context.Context, id int, req *UpdateSensorGroupRequest) (*SensorGroup, error) {
+	setParts := []string{}
+	args := []interface{}{}
+	argIndex := 1
+
+	if req.Name != nil {
+		setParts = append(setParts, fmt.Sprintf("name = $%d", argIndex))
+		args = append(args, *req.Name)
+		argIndex++
+	}
+
+	if req.Description != nil {
+		setParts = append(setParts, fmt.Sprintf("description = $%d", argIndex))
+		args = append(args, *req.Description)
+		argIndex++
+	}
+
+	if len(setParts) == 0 {
+		return r.GetSensorGroupByID(ctx, id) // No changes, return current group
+	}
+
+	setParts = append(setParts, "updated_at = CURRENT_TIMESTAMP")
+	args = append(args, id)
+	orgClause, args := r.orgFilter("organization_id", args)
+
+	query := fmt.Sprintf(`
+		UPDATE %s.sensor_groups
 		SET %s
-		WHERE id = $%d
-	`, schema, strings.Join(setParts, ", "), argIndex)
+		WHERE id = $%d%s
+	`, schema, strings.Join(setParts, ", "), argIndex, orgClause)
 
-	result, err := r.db.Exec(query, args...)
+	result, err := r.db.ExecContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to update location: %w", err)
+		if database.IsUniqueViolation(err) {
+			return nil, ErrGroupExists
+		}
+		return nil, fmt.Errorf("failed to update sensor group: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return nil, ErrGroupNotFound
+	}
+
+	return r.GetSensorGroupByID(ctx, id)
+}
+
+// DeleteSensorGroup deletes a sensor group and its memberships; the
+// sensors themselves are left untouched
+func (r *repository) DeleteSensorGroup(ctx context.Context, id int) error {
+	args := []interface{}{id}
+	orgClause, args := r.orgFilter("organization_id", args)
+
+	query := fmt.Sprintf(`
+		DELETE FROM %s.sensor_groups WHERE id = $1%s
+	`, schema, orgClause)
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to delete sensor group: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrGroupNotFound
+	}
+
+	return nil
+}
+
+// UpdateGroupMembers adds and removes sensors of a group in one
+// transaction. Sensors already in the group are skipped when added.
+func (r *repository) UpdateGroupMembers(ctx context.Context, groupID int, add, remove []int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if len(add) > 0 {
+		query := fmt.Sprintf(`
+			INSERT INTO %s.sensor_group_members (group_id, sensor_id)
+			SELECT $1, unnest($2::int[])
+			ON CONFLICT (group_id, sensor_id) DO NOTHING
+		`, schema)
+
+		if _, err := tx.ExecContext(ctx, query, groupID, pq.Array(add)); err != nil {
+			return fmt.Errorf("failed to add sensor group members: %w", err)
+		}
 	}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	if len(remove) > 0 {
+		query := fmt.Sprintf(`
+			DELETE FROM %s.sensor_group_members
+			WHERE group_id = $1 AND sensor_id = ANY($2::int[])
+		`, schema)
+
+		if _, err := tx.ExecContext(ctx, query, groupID, pq.Array(remove)); err != nil {
+			return fmt.Errorf("failed to remove sensor group members: %w", err)
+		}
 	}
 
-	if rowsAffected == 0 {
-		return nil, ErrLocationNotFound
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	return r.GetLocationByID(id)
+	return nil
 }
 
-// ListLocations retrieves all active locations
-func (r *repository) ListLocations() ([]*Location, error) {
+// ListSensorsByGroup retrieves the active sensors of a group
+func (r *repository) ListSensorsByGroup(ctx context.Context, groupID int) ([]*Sensor, error) {
+	args := []interface{}{groupID}
+	orgClause, args := r.orgFilter("s.organization_id", args)
+	accessClause, args := r.accessFilter("s", AccessLevelRead, args)
+
 	query := fmt.Sprintf(`
-		SELECT id, name, description, latitude, longitude, address, is_active, created_at, updated_at
-		FROM %s.locations
-		WHERE is_active = true
-		ORDER BY name
-	`, schema)
+		SELECT %s
+		%s
+		INNER JOIN %s.sensor_group_members m ON m.sensor_id = s.id
+		WHERE m.group_id = $1 AND s.is_active = true AND s.is_provisioned = true%s%s
+		ORDER BY s.name
+	`, sensorColumns, sensorJoins, schema, orgClause, accessClause)
 
-	rows, err := r.db.Query(query)
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list locations: %w", err)
+		return nil, fmt.Errorf("failed to list sensors by group: %w", err)
 	}
 	defer rows.Close()
 
-	locations := []*Location{}
+	sensors := []*Sensor{}
 	for rows.Next() {
-		location := &Location{}
-		err := rows.Scan(
-			&location.ID, &location.Name, &location.Description, &location.Latitude,
-			&location.Longitude, &location.Address, &location.IsActive,
-			&location.CreatedAt, &location.UpdatedAt,
-		)
+		sensor, err := scanSensor(rows)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan location: %w", err)
+			return nil, fmt.Errorf("failed to scan sensor: %w", err)
 		}
-		locations = append(locations, location)
+		sensors = append(sensors, sensor)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list sensors by group: %w", err)
 	}
 
-	return locations, nil
+	return sensors, nil
 }
 
-// CreateSensorReading creates a new sensor reading
-func (r *repository) CreateSensorReading(reading *SensorReading) error {
-	query := fmt.Sprintf(`
-		INSERT INTO %s.sensor_readings (sensor_id, value, timestamp, quality, metadata)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id, created_at
+// UpdateCalibration changes the calibration of a sensor the repository can
+// write to and records the change with the previous values
+func (r *repository) UpdateCalibration(ctx context.Context, sensorID int, req *UpdateCalibrationRequest, changedBy int) (*SensorCalibration, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	args := []interface{}{sensorID, req.Offset, req.Scale}
+	orgClause, args := r.orgFilter("s.organization_id", args)
+	accessClause, args := r.accessFilter("s", AccessLevelWrite, args)
+
+	// The self join reads the calibration as it was before the update
+	updateQuery := fmt.Sprintf(`
+		UPDATE %s.sensors s
+		SET calibration_offset = COALESCE($2, s.calibration_offset),
+		    calibration_scale = COALESCE($3, s.calibration_scale),
+		    updated_at = CURRENT_TIMESTAMP
+		FROM %s.sensors prev
+		WHERE s.id = $1 AND prev.id = s.id AND s.is_active = true%s%s
+		RETURNING s.calibration_offset, s.calibration_scale, prev.calibration_offset, prev.calibration_scale
+	`, schema, schema, orgClause, accessClause)
+
+	calibration := &SensorCalibration{SensorID: sensorID, ChangedBy: &changedBy}
+	err = tx.QueryRowContext(ctx, updateQuery, args...).Scan(
+		&calibration.Offset, &calibration.Scale, &calibration.PreviousOffset, &calibration.PreviousScale,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSensorNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update sensor calibration: %w", err)
+	}
+
+	historyQuery := fmt.Sprintf(`
+		INSERT INTO %s.sensor_calibrations
+			(sensor_id, calibration_offset, calibration_scale, previous_offset, previous_scale, changed_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, changed_at
 	`, schema)
 
-	timestamp := reading.Timestamp
-	if timestamp.IsZero() {
-		timestamp = time.Now()
+	err = tx.QueryRowContext(ctx, historyQuery,
+		sensorID, calibration.Offset, calibration.Scale,
+		calibration.PreviousOffset, calibration.PreviousScale, changedBy).
+		Scan(&calibration.ID, &calibration.ChangedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record sensor calibration: %w", err)
 	}
 
-	quality := reading.Quality
-	if quality == 0 {
-		quality = 100 // Default quality
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	err := r.db.QueryRow(query,
-		reading.SensorID, reading.Value, timestamp, quality, reading.Metadata).
-		Scan(&reading.ID, &reading.CreatedAt)
+	return calibration, nil
+}
 
+// SetMaintenance sets or clears the maintenance window of an active sensor
+// the caller can update and logs the change with its reason
+func (r *repository) SetMaintenance(ctx context.Context, sensorID int, req *SetMaintenanceRequest, setBy int) (*SensorMaintenance, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to create sensor reading: %w", err)
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	// Update sensor last reading timestamp
-	if err := r.UpdateSensorLastReading(reading.SensorID, timestamp); err != nil {
-		// Log warning but don't fail the reading creation
-		fmt.Printf("Warning: failed to update sensor last reading: %v\n", err)
+	var until *time.Time
+	if req.Until != nil {
+		utc := req.Until.UTC()
+		until = &utc
 	}
 
-	return nil
-}
+	args := []interface{}{sensorID, until}
+	orgClause, args := r.orgFilter("s.organization_id", args)
+	accessClause, args := r.accessFilter("s", AccessLevelWrite, args)
 
-// CreateBulkSensorReadings creates multiple sensor readings in a transaction
-func (r *repository) CreateBulkSensorReadings(readings []*SensorReading) error {
-	if len(readings) == 0 {
-		return nil
+	updateQuery := fmt.Sprintf(`
+		UPDATE %s.sensors s
+		SET maintenance_until = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE s.id = $1 AND s.is_active = true%s%s
+	`, schema, orgClause, accessClause)
+
+	result, err := tx.ExecContext(ctx, updateQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update sensor maintenance: %w", err)
 	}
 
-	// Start transaction
-	tx, err := r.db.Begin()
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to start transaction: %w", err)
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return nil, ErrSensorNotFound
 	}
-	defer tx.Rollback()
 
-	query := fmt.Sprintf(`
-		INSERT INTO %s.sensor_readings (sensor_id, value, timestamp, quality, metadata)
-		VALUES ($1, $2, $3, $4, $5)
+	logQuery := fmt.Sprintf(`
+		INSERT INTO %s.sensor_maintenance_log (sensor_id, maintenance_until, reason, set_by)
+		VALUES ($1, $2, $3, $4)
 		RETURNING id, created_at
 	`, schema)
 
-	stmt, err := tx.Prepare(query)
+	maintenance := &SensorMaintenance{SensorID: sensorID, MaintenanceUntil: until, Reason: req.Reason, SetBy: &setBy}
+	err = tx.QueryRowContext(ctx, logQuery, sensorID, until, req.Reason, setBy).Scan(&maintenance.ID, &maintenance.CreatedAt)
 	if err != nil {
-		return fmt.Errorf("failed to prepare statement: %w", err)
+		return nil, fmt.Errorf("failed to record sensor maintenance: %w", err)
 	}
-	defer stmt.Close()
-
-	sensorLastReadings := make(map[int]time.Time)
 
-	for _, reading := range readings {
-		timestamp := reading.Timestamp
-		if timestamp.IsZero() {
-			timestamp = time.Now()
-		}
-
-		quality := reading.Quality
-		if quality == 0 {
-			quality = 100 // Default quality
-		}
-
-		err := stmt.QueryRow(
-			reading.SensorID, reading.Value, timestamp, quality, reading.Metadata,
-		).Scan(&reading.ID, &reading.CreatedAt)
-
-		if err != nil {
-			return fmt.Errorf("failed to create sensor reading: %w", err)
-		}
-
-		// Track latest timestamp per sensor
-		if lastTime, exists := sensorLastReadings[reading.SensorID]; !exists || timestamp.After(lastTime) {
-			sensorLastReadings[reading.SensorID] = timestamp
-		}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	// Update sensor last reading timestamps
-	updateQuery := fmt.Sprintf(`
-		UPDATE %s.sensors 
-		SET last_reading_at = $1, updated_at = $2
-		WHERE id = $3
+	return maintenance, nil
+}
+
+// ListCalibrations retrieves the calibration changes of a sensor, newest first
+func (r *repository) ListCalibrations(ctx context.Context, sensorID int) ([]*SensorCalibration, error) {
+	query := fmt.Sprintf(`
+		SELECT id, sensor_id, calibration_offset, calibration_scale, previous_offset, previous_scale,
+		       changed_by, changed_at
+		FROM %s.sensor_calibrations
+		WHERE sensor_id = $1
+		ORDER BY changed_at DESC, id DESC
 	`, schema)
 
-	updateStmt, err := tx.Prepare(updateQuery)
+	rows, err := r.db.QueryContext(ctx, query, sensorID)
 	if err != nil {
-		return fmt.Errorf("failed to prepare update statement: %w", err)
+		return nil, fmt.Errorf("failed to list sensor calibrations: %w", err)
 	}
-	defer updateStmt.Close()
+	defer rows.Close()
 
-	now := time.Now()
-	for sensorID, lastReading := range sensorLastReadings {
-		if _, err := updateStmt.Exec(lastReading, now, sensorID); err != nil {
-			return fmt.Errorf("failed to update sensor last reading: %w", err)
+	calibrations := []*SensorCalibration{}
+	for rows.Next() {
+		calibration := &SensorCalibration{}
+		err := rows.Scan(
+			&calibration.ID, &calibration.SensorID, &calibration.Offset, &calibration.Scale,
+			&calibration.PreviousOffset, &calibration.PreviousScale, &calibration.ChangedBy, &calibration.ChangedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan sensor calibration: %w", err)
 		}
+		calibrations = append(calibrations, calibration)
 	}
 
-	// Commit transaction
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
-
-	return nil
+	return calibrations, nil
 }
 
-// GetSensorReadings retrieves sensor readings based on query parameters
-func (r *repository) GetSensorReadings(query *SensorReadingQuery) ([]*SensorReading, int, error) {
-	// Build WHERE clause
-	whereParts := []string{}
-	args := []interface{}{}
-	argIndex := 1
+// CreateSensorCommand records a pending command for a sensor the caller can
+// update
+func (r *repository) CreateSensorCommand(ctx context.Context, command *SensorCommand) (*SensorCommand, error) {
+	args := []interface{}{command.SensorID, command.CommandType, string(command.Payload), command.SentBy, command.CommandUUID}
+	orgClause, args := r.orgFilter("s.organization_id", args)
+	accessClause, args := r.accessFilter("s", AccessLevelWrite, args)
 
-	if query.SensorID != nil {
-		whereParts = append(whereParts, fmt.Sprintf("sensor_id = $%d", argIndex))
-		args = append(args, *query.SensorID)
-		argIndex++
-	}
+	query := fmt.Sprintf(`
+		INSERT INTO %s.sensor_commands (sensor_id, command_type, payload, status, sent_by, command_uuid)
+		SELECT s.id, $2, $3::jsonb, '%s', $4, $5
+		FROM %s.sensors s
+		WHERE s.id = $1%s%s
+		RETURNING id, status, created_at
+	`, schema, CommandStatusPending, schema, orgClause, accessClause)
 
-	if query.StartTime != nil {
-		whereParts = append(whereParts, fmt.Sprintf("timestamp >= $%d", argIndex))
-		args = append(args, *query.StartTime)
-		argIndex++
+	created := *command
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(&created.ID, &created.Status, &created.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSensorNotFound
 	}
-
-	if query.EndTime != nil {
-		whereParts = append(whereParts, fmt.Sprintf("timestamp <= $%d", argIndex))
-		args = append(args, *query.EndTime)
-		argIndex++
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sensor command: %w", err)
 	}
 
-	if query.MinQuality != nil {
-		whereParts = append(whereParts, fmt.Sprintf("quality >= $%d", argIndex))
-		args = append(args, *query.MinQuality)
-		argIndex++
-	}
+	return &created, nil
+}
 
-	whereClause := ""
-	if len(whereParts) > 0 {
-		whereClause = "WHERE " + strings.Join(whereParts, " AND ")
+// UpdateCommandStatus sets the status of a pending command; sent commands
+// also get their sent time. A command the device already acknowledged keeps
+// its status.
+func (r *repository) UpdateCommandStatus(ctx context.Context, command *SensorCommand, status string) error {
+	query := fmt.Sprintf(`
+		UPDATE %[1]s.sensor_commands
+		SET status = CASE WHEN status = '%[2]s' THEN $1 ELSE status END,
+		    sent_at = CASE WHEN $1 = '%[3]s' THEN COALESCE(sent_at, CURRENT_TIMESTAMP) ELSE sent_at END
+		WHERE id = $2
+		RETURNING status, sent_at
+	`, schema, CommandStatusPending, CommandStatusSent)
+
+	err := r.db.QueryRowContext(ctx, query, status, command.ID).Scan(&command.Status, &command.SentAt)
+	if err != nil {
+		return fmt.Errorf("failed to update sensor command status: %w", err)
 	}
 
-	// Get total count
-	countQuery := fmt.Sprintf(`
-		SELECT COUNT(*) FROM %s.sensor_readings %s
-	`, schema, whereClause)
+	return nil
+}
 
-	var total int
-	err := r.db.QueryRow(countQuery, args...).Scan(&total)
+// sensorCommandColumns lists the columns scanned by scanSensorCommand
+const sensorCommandColumns = `
+	c.id, COALESCE(c.command_uuid::text, ''), c.sensor_id, c.command_type, c.payload, c.status, c.response,
+	c.sent_by, c.created_at, c.sent_at, c.acknowledged_at`
+
+// scanSensorCommand scans a row selected with sensorCommandColumns
+func scanSensorCommand(row rowScanner) (*SensorCommand, error) {
+	command := &SensorCommand{}
+	var payload, response []byte
+	err := row.Scan(
+		&command.ID, &command.CommandUUID, &command.SensorID, &command.CommandType, &payload, &command.Status,
+		&response, &command.SentBy, &command.CreatedAt, &command.SentAt, &command.AcknowledgedAt,
+	)
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to count sensor readings: %w", err)
+		return nil, err
 	}
+	command.Payload = payload
+	command.Response = response
 
-	// Get readings
-	limit := query.Limit
-	if limit <= 0 {
-		limit = 100 // Default limit
+	return command, nil
+}
+
+// AcknowledgeCommand records the reply of a device to one of its pending or
+// sent commands
+func (r *repository) AcknowledgeCommand(ctx context.Context, deviceID string, ack *CommandAck) (*SensorCommand, error) {
+	var response interface{}
+	if len(ack.Response) > 0 {
+		response = string(ack.Response)
 	}
 
-	offset := query.Offset
-	if offset < 0 {
-		offset = 0
+	query := fmt.Sprintf(`
+		UPDATE %[1]s.sensor_commands c
+		SET status = $3, response = $4::jsonb, acknowledged_at = CURRENT_TIMESTAMP,
+		    sent_at = COALESCE(c.sent_at, CURRENT_TIMESTAMP)
+		FROM %[1]s.sensors s
+		WHERE c.sensor_id = s.id AND UPPER(s.device_id) = $1 AND c.command_uuid = $2
+		  AND c.status IN ('%[2]s', '%[3]s')
+		RETURNING %[4]s
+	`, schema, CommandStatusPending, CommandStatusSent, sensorCommandColumns)
+
+	command, err := scanSensorCommand(r.db.QueryRowContext(ctx, query, NormalizeDeviceID(deviceID), ack.CommandUUID, ack.Status, response))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCommandNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to acknowledge sensor command: %w", err)
 	}
 
-	// Add limit and offset to args
-	args = append(args, limit, offset)
+	return command, nil
+}
 
-	readingsQuery := fmt.Sprintf(`
-		SELECT id, sensor_id, value, timestamp, quality, metadata, created_at
-		FROM %s.sensor_readings
-		%s
-		ORDER BY timestamp DESC
-		LIMIT $%d OFFSET $%d
-	`, schema, whereClause, argIndex, argIndex+1)
+// ListSensorCommands retrieves a page of the commands of a sensor, newest
+// first, with the total number of commands
+func (r *repository) ListSensorCommands(ctx context.Context, sensorID, limit, offset int) ([]*SensorCommand, int, error) {
+	countQuery := fmt.Sprintf(`SELECT COUNT(*) FROM %s.sensor_commands WHERE sensor_id = $1`, schema)
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, countQuery, sensorID).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count sensor commands: %w", err)
+	}
 
-	rows, err := r.db.Query(readingsQuery, args...)
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM %s.sensor_commands c
+		WHERE c.sensor_id = $1
+		ORDER BY c.created_at DESC, c.id DESC
+		LIMIT $2 OFFSET $3
+	`, sensorCommandColumns, schema)
+
+	rows, err := r.db.QueryContext(ctx, query, sensorID, limit, offset)
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to get sensor readings: %w", err)
+		return nil, 0, fmt.Errorf("failed to list sensor commands: %w", err)
 	}
 	defer rows.Close()
 
-	readings := []*SensorReading{}
+	commands := []*SensorCommand{}
 	for rows.Next() {
-		reading := &SensorReading{}
-		err := rows.Scan(
-			&reading.ID, &reading.SensorID, &reading.Value, &reading.Timestamp,
-			&reading.Quality, &reading.Metadata, &reading.CreatedAt,
-		)
+		command, err := scanSensorCommand(rows)
 		if err != nil {
-			return nil, 0, fmt.Errorf("failed to scan sensor reading: %w", err)
+			return nil, 0, fmt.Errorf("failed to scan sensor command: %w", err)
 		}
-		readings = append(readings, reading)
+		commands = append(commands, command)
 	}
 
-	return readings, total, nil
+	return commands, total, rows.Err()
 }
 
-// GetLatestReading retrieves the latest reading for a sensor
-func (r *repository) GetLatestReading(sensorID int) (*SensorReading, error) {
+// TimeOutCommands marks the commands sent before before that are still
+// unacknowledged, and the commands created before it that were never
+// published, as timed out
+func (r *repository) TimeOutCommands(ctx context.Context, before time.Time) (int64, error) {
 	query := fmt.Sprintf(`
-		SELECT id, sensor_id, value, timestamp, quality, metadata, created_at
-		FROM %s.sensor_readings
-		WHERE sensor_id = $1
-		ORDER BY timestamp DESC
-		LIMIT 1
-	`, schema)
+		UPDATE %s.sensor_commands
+		SET status = '%s'
+		WHERE (status = '%s' AND sent_at < $1)
+		   OR (status = '%s' AND created_at < $1)
+	`, schema, CommandStatusTimedOut, CommandStatusSent, CommandStatusPending)
 
-	reading := &SensorReading{}
-	err := r.db.QueryRow(query, sensorID).Scan(
-		&reading.ID, &reading.SensorID, &reading.Value, &reading.Timestamp,
-		&reading.Quality, &reading.Metadata, &reading.CreatedAt,
-	)
+	result, err := r.db.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to time out sensor commands: %w", err)
+	}
 
-	if err == sql.ErrNoRows {
-		return nil, nil // No readings yet
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
 	}
+
+	return rowsAffected, nil
+}
+
+// sensorNoteColumns lists the columns scanned by scanSensorNote; notes are
+// aliased n and joined with their author as u
+const sensorNoteColumns = `n.id, n.sensor_id, n.author_id, COALESCE(u.name, ''), n.body, n.created_at`
+
+// scanSensorNote scans a row selected with sensorNoteColumns
+func scanSensorNote(row rowScanner) (*SensorNote, error) {
+	note := &SensorNote{}
+	err := row.Scan(&note.ID, &note.SensorID, &note.AuthorID, &note.AuthorName, &note.Body, &note.CreatedAt)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get latest reading: %w", err)
+		return nil, err
 	}
+	return note, nil
+}
 
-	return reading, nil
+// CreateSensorNote adds a note to a sensor and returns it with its author's name
+func (r *repository) CreateSensorNote(ctx context.Context, sensorID int, body string, authorID int) (*SensorNote, error) {
+	query := fmt.Sprintf(`
+		WITH n AS (
+			INSERT INTO %s.sensor_notes (sensor_id, author_id, body)
+			VALUES ($1, $2, $3)
+			RETURNING id, sensor_id, author_id, body, created_at
+		)
+		SELECT %s
+		FROM n
+		LEFT JOIN user_management.users u ON u.id = n.author_id
+	`, schema, sensorNoteColumns)
+
+	note, err := scanSensorNote(r.db.QueryRowContext(ctx, query, sensorID, authorID, body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sensor note: %w", err)
+	}
+
+	return note, nil
 }
 
-// GetSensorStatistics calculates statistics for a sensor within time range
-func (r *repository) GetSensorStatistics(sensorID int, startTime, endTime time.Time) (*SensorStatistics, error) {
+// GetSensorNote retrieves a note of a sensor
+func (r *repository) GetSensorNote(ctx context.Context, sensorID int, noteID int64) (*SensorNote, error) {
 	query := fmt.Sprintf(`
-		SELECT 
-			COUNT(*) as count,
-			MIN(value) as min_value,
-			MAX(value) as max_value,
-			AVG(value) as avg_value,
-			(SELECT value FROM %s.sensor_readings WHERE sensor_id = $1 ORDER BY timestamp DESC LIMIT 1) as last_value,
-			(SELECT timestamp FROM %s.sensor_readings WHERE sensor_id = $1 ORDER BY timestamp DESC LIMIT 1) as last_timestamp
-		FROM %s.sensor_readings
-		WHERE sensor_id = $1 AND timestamp >= $2 AND timestamp <= $3
-	`, schema, schema, schema)
+		SELECT %s
+		FROM %s.sensor_notes n
+		LEFT JOIN user_management.users u ON u.id = n.author_id
+		WHERE n.id = $1 AND n.sensor_id = $2
+	`, sensorNoteColumns, schema)
 
-	stats := &SensorStatistics{
-		SensorID: sensorID,
-		Period:   fmt.Sprintf("%s to %s", startTime.Format("2006-01-02"), endTime.Format("2006-01-02")),
+	note, err := scanSensorNote(r.db.QueryRowContext(ctx, query, noteID, sensorID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoteNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sensor note: %w", err)
 	}
 
-	var lastTimestamp sql.NullTime
+	return note, nil
+}
 
-	err := r.db.QueryRow(query, sensorID, startTime, endTime).Scan(
-		&stats.Count, &stats.MinValue, &stats.MaxValue, &stats.AvgValue,
-		&stats.LastValue, &lastTimestamp,
-	)
+// ListSensorNotes retrieves a page of the notes of a sensor, newest first,
+// with the total number of notes
+func (r *repository) ListSensorNotes(ctx context.Context, sensorID, limit, offset int) ([]*SensorNote, int, error) {
+	countQuery := fmt.Sprintf(`SELECT COUNT(*) FROM %s.sensor_notes WHERE sensor_id = $1`, schema)
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, countQuery, sensorID).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count sensor notes: %w", err)
+	}
 
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM %s.sensor_notes n
+		LEFT JOIN user_management.users u ON u.id = n.author_id
+		WHERE n.sensor_id = $1
+		ORDER BY n.created_at DESC, n.id DESC
+		LIMIT $2 OFFSET $3
+	`, sensorNoteColumns, schema)
+
+	rows, err := r.db.QueryContext(ctx, query, sensorID, limit, offset)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get sensor statistics: %w", err)
+		return nil, 0, fmt.Errorf("failed to list sensor notes: %w", err)
 	}
+	defer rows.Close()
 
-	if lastTimestamp.Valid {
-		stats.LastTimestamp = &lastTimestamp.Time
+	notes := []*SensorNote{}
+	for rows.Next() {
+		note, err := scanSensorNote(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan sensor note: %w", err)
+		}
+		notes = append(notes, note)
 	}
 
-	return stats, nil
+	return notes, total, rows.Err()
 }
 
-// UpdateSensorLastReading updates sensor's last reading timestamp
-func (r *repository) UpdateSensorLastReading(sensorID int, timestamp time.Time) error {
-	query := fmt.Sprintf(`
-		UPDATE %s.sensors 
-		SET last_reading_at = $1, updated_at = $2
-		WHERE id = $3
-	`, schema)
+// DeleteSensorNote permanently deletes a note
+func (r *repository) DeleteSensorNote(ctx context.Context, noteID int64) error {
+	query := fmt.Sprintf(`DELETE FROM %s.sensor_notes WHERE id = $1`, schema)
 
-	_, err := r.db.Exec(query, timestamp, time.Now(), sensorID)
+	result, err := r.db.ExecContext(ctx, query, noteID)
 	if err != nil {
-		return fmt.Errorf("failed to update sensor last reading: %w", err)
+		return fmt.Errorf("failed to delete sensor note: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrNoteNotFound
 	}
 
 	return nil
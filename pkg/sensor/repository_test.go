@@ -0,0 +1,399 @@
+package sensor
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+	"user-management/database/dbtest"
+	"user-management/shared/interfaces"
+)
+
+// sensorTypeID returns the ID of a sensor type seeded by the migrations
+func sensorTypeID(t testing.TB, db *sql.DB, name string) int {
+	t.Helper()
+
+	var id int
+	if err := db.QueryRow("SELECT id FROM sensor_data.sensor_types WHERE name = $1", name).Scan(&id); err != nil {
+		t.Fatalf("failed to get sensor type %s: %v", name, err)
+	}
+	return id
+}
+
+// newTestSensor stores a provisioned temperature sensor with deviceID as
+// given, without NewSensor's normalization
+func newTestSensor(t testing.TB, db *sql.DB, repo Repository, deviceID string) *Sensor {
+	t.Helper()
+
+	sensor := testSensor(t, db, deviceID)
+	if err := repo.CreateSensor(context.Background(), sensor); err != nil {
+		t.Fatalf("failed to create sensor %s: %v", deviceID, err)
+	}
+	return sensor
+}
+
+// testSensor returns an unsaved provisioned temperature sensor
+func testSensor(t testing.TB, db *sql.DB, deviceID string) *Sensor {
+	t.Helper()
+
+	return &Sensor{
+		DeviceID:         deviceID,
+		Name:             "Test sensor",
+		SensorTypeID:     sensorTypeID(t, db, "temperature"),
+		IsActive:         true,
+		IsProvisioned:    true,
+		Tags:             []string{},
+		Metadata:         json.RawMessage(`{}`),
+		CalibrationScale: 1,
+	}
+}
+
+// newTestOrganization stores an organization and returns its ID
+func newTestOrganization(t testing.TB, db *sql.DB, name string) int {
+	t.Helper()
+
+	var id int
+	if err := db.QueryRow("INSERT INTO user_management.organizations (name) VALUES ($1) RETURNING id", name).Scan(&id); err != nil {
+		t.Fatalf("failed to create organization %s: %v", name, err)
+	}
+	return id
+}
+
+func TestCreateSensorDuplicateDeviceID(t *testing.T) {
+	db := dbtest.Migrated(t)
+	repo := NewRepository(db)
+	newTestSensor(t, db, repo, "DUP-001")
+
+	err := repo.CreateSensor(context.Background(), testSensor(t, db, "DUP-001"))
+	if !errors.Is(err, ErrDeviceIDExists) {
+		t.Fatalf("got %v, want ErrDeviceIDExists", err)
+	}
+}
+
+// TestCreateSensorDeviceIDTakenInOtherOrganization reports the conflict
+// even though the other organization's sensor is out of scope
+func TestCreateSensorDeviceIDTakenInOtherOrganization(t *testing.T) {
+	db := dbtest.Migrated(t)
+	newTestSensor(t, db, NewRepository(db), "SHARED-001")
+
+	orgID := newTestOrganization(t, db, "Other")
+	scoped := NewRepository(db).WithScope(interfaces.Scope{OrganizationID: orgID, Restricted: true})
+
+	err := scoped.CreateSensor(context.Background(), testSensor(t, db, "SHARED-001"))
+	if !errors.Is(err, ErrDeviceIDExists) {
+		t.Fatalf("got %v, want ErrDeviceIDExists", err)
+	}
+}
+
+func TestCreateSensorAccessUnknownUser(t *testing.T) {
+	db := dbtest.Migrated(t)
+	repo := NewRepository(db)
+	sensor := newTestSensor(t, db, repo, "GRANT-001")
+
+	unknownUser := 999999
+	err := repo.CreateSensorAccess(context.Background(), &SensorAccess{
+		SensorID:    &sensor.ID,
+		UserID:      &unknownUser,
+		AccessLevel: "read",
+	})
+	if !errors.Is(err, ErrGranteeNotFound) {
+		t.Fatalf("got %v, want ErrGranteeNotFound", err)
+	}
+}
+
+func TestApproveSensorUnknownOrganization(t *testing.T) {
+	db := dbtest.Migrated(t)
+	repo := NewRepository(db)
+
+	pending := testSensor(t, db, "PENDING-001")
+	pending.IsProvisioned = false
+	if err := repo.CreateSensor(context.Background(), pending); err != nil {
+		t.Fatal(err)
+	}
+
+	unknownOrg := 999999
+	err := repo.ApproveSensor(context.Background(), pending.ID, &ApproveSensorRequest{
+		SensorTypeID:   pending.SensorTypeID,
+		OrganizationID: &unknownOrg,
+	}, "token-hash")
+	if !errors.Is(err, ErrOrganizationNotFound) {
+		t.Fatalf("got %v, want ErrOrganizationNotFound", err)
+	}
+}
+
+// TestGetSensorByDeviceIDIgnoresCase finds sensors whatever the case of the
+// device ID, including sensors stored before device IDs were normalized
+func TestGetSensorByDeviceIDIgnoresCase(t *testing.T) {
+	db := dbtest.Migrated(t)
+	repo := NewRepository(db)
+	stored := newTestSensor(t, db, repo, "MIXED-001")
+	legacy := newTestSensor(t, db, repo, "legacy-001")
+
+	tests := []struct {
+		deviceID string
+		want     int
+	}{
+		{"MIXED-001", stored.ID},
+		{"mixed-001", stored.ID},
+		{"Mixed-001", stored.ID},
+		{" mixed-001 ", stored.ID},
+		{"legacy-001", legacy.ID},
+		{"LEGACY-001", legacy.ID},
+	}
+
+	for _, tt := range tests {
+		sensor, err := repo.GetSensorByDeviceID(context.Background(), tt.deviceID)
+		if err != nil {
+			t.Errorf("%q: %v", tt.deviceID, err)
+			continue
+		}
+		if sensor.ID != tt.want {
+			t.Errorf("%q: got sensor %d, want %d", tt.deviceID, sensor.ID, tt.want)
+		}
+	}
+}
+
+// TestCreateSensorDeviceIDDiffersInCase rejects device IDs differing from
+// an existing one only in case, even when stored without normalization
+func TestCreateSensorDeviceIDDiffersInCase(t *testing.T) {
+	db := dbtest.Migrated(t)
+	repo := NewRepository(db)
+	newTestSensor(t, db, repo, "CASE-001")
+
+	err := repo.CreateSensor(context.Background(), testSensor(t, db, "case-001"))
+	if !errors.Is(err, ErrDeviceIDExists) {
+		t.Fatalf("got %v, want ErrDeviceIDExists", err)
+	}
+
+	// The JSON payload of the create endpoint goes through NewSensor
+	_, err = NewService(repo, Config{}).CreateSensor(context.Background(), &CreateSensorRequest{
+		DeviceID:     "Case-001",
+		Name:         "Duplicate",
+		SensorTypeID: sensorTypeID(t, db, "temperature"),
+	}, 0)
+	if !errors.Is(err, ErrDeviceIDExists) {
+		t.Fatalf("got %v, want ErrDeviceIDExists", err)
+	}
+}
+
+// BenchmarkCreateBulkSensorReadings compares inserting a batch of readings
+// row by row, as bulk inserts used to, with the multi-row inserts of
+// CreateBulkSensorReadings
+func BenchmarkCreateBulkSensorReadings(b *testing.B) {
+	const batchSize = 5000
+
+	db := dbtest.Migrated(b)
+	repo := NewRepository(db)
+	sensor := newTestSensor(b, db, repo, "BENCH-001")
+
+	// Every batch continues where the last one stopped so no reading is
+	// skipped as a duplicate
+	next := time.Now().Add(-24 * 365 * time.Hour).Truncate(time.Second)
+	batch := func() []*SensorReading {
+		readings := make([]*SensorReading, batchSize)
+		for i := range readings {
+			readings[i] = &SensorReading{
+				SensorID:  sensor.ID,
+				Value:     float64(i % 50),
+				RawValue:  float64(i % 50),
+				Timestamp: next,
+				Quality:   100,
+			}
+			next = next.Add(time.Second)
+		}
+		return readings
+	}
+
+	b.Run("row-by-row", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			readings := batch()
+			b.StartTimer()
+
+			for _, reading := range readings {
+				if err := repo.CreateSensorReading(context.Background(), reading); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+		b.ReportMetric(float64(b.N*batchSize)/b.Elapsed().Seconds(), "readings/s")
+	})
+
+	b.Run("multi-row", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			readings := batch()
+			b.StartTimer()
+
+			if _, err := repo.CreateBulkSensorReadings(context.Background(), readings); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ReportMetric(float64(b.N*batchSize)/b.Elapsed().Seconds(), "readings/s")
+	})
+}
+
+// newTestLocation stores an active location and returns its ID
+func newTestLocation(t testing.TB, repo Repository, name string) int {
+	t.Helper()
+
+	location := &Location{Name: name, IsActive: true}
+	if err := repo.CreateLocation(context.Background(), location); err != nil {
+		t.Fatalf("failed to create location %s: %v", name, err)
+	}
+	return location.ID
+}
+
+// assertSameJSON fails unless got and want encode to the same JSON
+func assertSameJSON(t *testing.T, name string, got, want interface{}) {
+	t.Helper()
+
+	gotJSON, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantJSON, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(gotJSON) != string(wantJSON) {
+		t.Errorf("%s:\ngot  %s\nwant %s", name, gotJSON, wantJSON)
+	}
+}
+
+// TestListSensorsEmbedsRelations populates the sensor type, location and
+// latest reading of listed sensors as the single sensor lookups do
+func TestListSensorsEmbedsRelations(t *testing.T) {
+	db := dbtest.Migrated(t)
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	locationID := newTestLocation(t, repo, "List room")
+	located := testSensor(t, db, "LIST-001")
+	located.LocationID = &locationID
+	if err := repo.CreateSensor(ctx, located); err != nil {
+		t.Fatal(err)
+	}
+	newTestSensor(t, db, repo, "LIST-002")
+
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for i := 0; i < 3; i++ {
+		reading := &SensorReading{SensorID: located.ID, Value: float64(20 + i), RawValue: float64(20 + i), Timestamp: start.Add(time.Duration(i) * time.Minute)}
+		if err := repo.CreateSensorReading(ctx, reading); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sensors, total, err := repo.ListSensors(ctx, &SensorFilter{Query: "LIST-", SortBy: "device_id"}, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(sensors) != 2 {
+		t.Fatalf("got %d sensors of %d, want 2", len(sensors), total)
+	}
+
+	for _, sensor := range sensors {
+		want, err := repo.GetSensorByID(ctx, sensor.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sensor.SensorType == nil {
+			t.Errorf("%s: sensor type not populated", sensor.DeviceID)
+		}
+		assertSameJSON(t, sensor.DeviceID+" sensor type", sensor.SensorType, want.SensorType)
+		assertSameJSON(t, sensor.DeviceID+" location", sensor.Location, want.Location)
+
+		latest, err := repo.GetLatestReading(ctx, sensor.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertSameJSON(t, sensor.DeviceID+" latest reading", sensor.LatestReading, latest)
+	}
+
+	if sensors[0].Location == nil || sensors[0].Location.ID != locationID {
+		t.Errorf("got location %+v, want %d", sensors[0].Location, locationID)
+	}
+	if sensors[0].LatestReading == nil || sensors[0].LatestReading.Value != 22 {
+		t.Errorf("got latest reading %+v, want the value 22", sensors[0].LatestReading)
+	}
+	if sensors[1].Location != nil || sensors[1].LatestReading != nil {
+		t.Errorf("sensor without location or readings got %+v and %+v", sensors[1].Location, sensors[1].LatestReading)
+	}
+}
+
+// TestListSensorsByLocation lists the active provisioned sensors of a
+// location by name, each as GetSensorByID returns it
+func TestListSensorsByLocation(t *testing.T) {
+	db := dbtest.Migrated(t)
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	locationID := newTestLocation(t, repo, "Plant room")
+	otherID := newTestLocation(t, repo, "Office")
+	create := func(deviceID, name string, locationID int, active, provisioned bool) *Sensor {
+		sensor := testSensor(t, db, deviceID)
+		sensor.Name = name
+		sensor.LocationID = &locationID
+		sensor.IsActive = active
+		sensor.IsProvisioned = provisioned
+		if err := repo.CreateSensor(ctx, sensor); err != nil {
+			t.Fatal(err)
+		}
+		return sensor
+	}
+
+	c := create("LOC-003", "Sensor C", locationID, true, true)
+	a := create("LOC-001", "Sensor A", locationID, true, true)
+	b := create("LOC-002", "Sensor B", locationID, true, true)
+	create("LOC-004", "Sensor D", locationID, false, true)
+	create("LOC-005", "Sensor E", locationID, true, false)
+	create("LOC-006", "Sensor F", otherID, true, true)
+
+	sensors, err := repo.ListSensorsByLocation(ctx, locationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*Sensor{a, b, c}
+	if len(sensors) != len(want) {
+		t.Fatalf("got %d sensors, want %d", len(sensors), len(want))
+	}
+	for i, sensor := range sensors {
+		if sensor.ID != want[i].ID {
+			t.Errorf("sensor %d: got %s, want %s", i, sensor.Name, want[i].Name)
+			continue
+		}
+
+		stored, err := repo.GetSensorByID(ctx, sensor.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertSameJSON(t, sensor.Name, sensor, stored)
+	}
+}
+
+// TestLateReadingKeepsLastReadingAt leaves last_reading_at at the newest
+// reading when an older one arrives late
+func TestLateReadingKeepsLastReadingAt(t *testing.T) {
+	db := dbtest.Migrated(t)
+	repo := NewRepository(db)
+	ctx := context.Background()
+	sensor := newTestSensor(t, db, repo, "LATE-001")
+
+	newest := time.Now().UTC().Truncate(time.Second)
+	for _, timestamp := range []time.Time{newest, newest.Add(-time.Hour)} {
+		if err := repo.CreateSensorReading(ctx, &SensorReading{SensorID: sensor.ID, Value: 20, RawValue: 20, Timestamp: timestamp}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stored, err := repo.GetSensorByID(ctx, sensor.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.LastReadingAt == nil || !stored.LastReadingAt.Equal(newest) {
+		t.Errorf("got last reading at %v, want %v", stored.LastReadingAt, newest)
+	}
+}
@@ -0,0 +1,85 @@
+package sensor
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// retentionInterval is how often the retention worker purges old readings
+const retentionInterval = 24 * time.Hour
+
+// RetentionWorker periodically deletes sensor readings older than the
+// retention of their sensor type
+type RetentionWorker struct {
+	service       Service
+	retentionDays int
+
+	// ctx is cancelled by Stop so a running purge ends between batches
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewRetentionWorker creates a retention worker; defaultRetentionDays
+// applies to sensor types without an override, 0 keeps their readings
+func NewRetentionWorker(service Service, defaultRetentionDays int) *RetentionWorker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &RetentionWorker{
+		service:       service,
+		retentionDays: defaultRetentionDays,
+		ctx:           ctx,
+		cancel:        cancel,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+}
+
+// Start runs a purge right away and then once per retention interval
+func (w *RetentionWorker) Start() {
+	go func() {
+		defer close(w.done)
+
+		ticker := time.NewTicker(retentionInterval)
+		defer ticker.Stop()
+
+		for {
+			w.run()
+
+			select {
+			case <-ticker.C:
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the worker, cancelling a running purge after its current
+// batch, and waits for it to return
+func (w *RetentionWorker) Stop() {
+	w.stopOnce.Do(func() {
+		w.cancel()
+		close(w.stop)
+	})
+	<-w.done
+}
+
+func (w *RetentionWorker) run() {
+	deleted, err := w.service.ApplyRetention(w.ctx, w.retentionDays)
+	if errors.Is(err, context.Canceled) {
+		log.Printf("Sensor reading retention stopped after deleting %d readings", deleted)
+		return
+	}
+	if err != nil {
+		log.Printf("Warning: sensor reading retention failed after deleting %d readings: %v", deleted, err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("Sensor reading retention deleted %d readings", deleted)
+	}
+}
@@ -1,82 +1,356 @@
 package sensor
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math"
+	"sort"
+	"strings"
 	"time"
+	"user-management/pkg/webhook"
+	"user-management/shared/interfaces"
 )
 
 // Service defines sensor service interface
 type Service interface {
 	// Sensor management
-	CreateSensor(req *CreateSensorRequest, createdBy int) (*Sensor, error)
-	GetSensor(id int) (*Sensor, error)
-	GetSensorByDeviceID(deviceID string) (*Sensor, error)
-	UpdateSensor(id int, req *UpdateSensorRequest) (*Sensor, error)
-	DeleteSensor(id int) error
-	ListSensors(page, perPage int) ([]*Sensor, int, error)
-	ListSensorsByLocation(locationID int) ([]*Sensor, error)
+	CreateSensor(ctx context.Context, req *CreateSensorRequest, createdBy int) (*Sensor, error)
+	CloneSensor(ctx context.Context, id int, req *CloneSensorRequest, createdBy int) (*Sensor, error)
+	GetSensor(ctx context.Context, id int) (*Sensor, error)
+	GetSensorByDeviceID(ctx context.Context, deviceID string) (*Sensor, error)
+	UpdateSensor(ctx context.Context, id int, req *UpdateSensorRequest) (*Sensor, error)
+	DeleteSensor(ctx context.Context, id int) error
+	ActivateSensor(ctx context.Context, id int) (*Sensor, error)
+	RotateDeviceToken(ctx context.Context, id int) (*Sensor, error)
+	AuthenticateDevice(ctx context.Context, token string, sensorIDs []int) error
+	PurgeSensor(ctx context.Context, id int) (int64, error)
+	ListSensors(ctx context.Context, page, perPage int, filter *SensorFilter) ([]*Sensor, int, error)
+	ListSensorsByLocation(ctx context.Context, locationID int) ([]*Sensor, error)
+	ListSensorTags(ctx context.Context) ([]*SensorTag, error)
+	GetFirmwareReport(ctx context.Context) ([]*FirmwareVersionCount, error)
+	ListFirmwareHistory(ctx context.Context, sensorID int) ([]*FirmwareChange, error)
+
+	// Auto-provisioning
+	ProvisionSensor(ctx context.Context, deviceID string) (*Sensor, error)
+	ListPendingSensors(ctx context.Context, page, perPage int) ([]*Sensor, int, error)
+	ApproveSensor(ctx context.Context, id int, req *ApproveSensorRequest) (*Sensor, error)
 
 	// Sensor types
-	GetSensorType(id int) (*SensorType, error)
-	GetSensorTypeByName(name string) (*SensorType, error)
-	ListSensorTypes() ([]*SensorType, error)
+	GetSensorType(ctx context.Context, id int) (*SensorType, error)
+	GetSensorTypeByName(ctx context.Context, name string) (*SensorType, error)
+	ListSensorTypes(ctx context.Context) ([]*SensorType, error)
+	CreateSensorType(ctx context.Context, req *CreateSensorTypeRequest) (*SensorType, error)
+	UpdateSensorType(ctx context.Context, id int, req *UpdateSensorTypeRequest) (*SensorType, error)
+	DeleteSensorType(ctx context.Context, id int) error
 
 	// Location management
-	CreateLocation(req *CreateLocationRequest) (*Location, error)
-	GetLocation(id int) (*Location, error)
-	UpdateLocation(id int, req *UpdateLocationRequest) (*Location, error)
-	ListLocations() ([]*Location, error)
+	CreateLocation(ctx context.Context, req *CreateLocationRequest) (*Location, error)
+	GetLocation(ctx context.Context, id int) (*Location, error)
+	UpdateLocation(ctx context.Context, id int, req *UpdateLocationRequest) (*Location, error)
+	ListLocations(ctx context.Context) ([]*Location, error)
+	ListLocationsNear(ctx context.Context, lat, lng, radiusKm float64) (*NearbyLocations, error)
+	DeactivateLocation(ctx context.Context, id int, force bool) (int, error)
+	AssignSensorsToLocation(ctx context.Context, id int, req *AssignSensorsRequest) (*LocationAssignment, error)
+
+	// Sensor groups
+	CreateSensorGroup(ctx context.Context, req *CreateSensorGroupRequest, createdBy int) (*SensorGroup, error)
+	GetSensorGroup(ctx context.Context, id int) (*SensorGroup, error)
+	ListSensorGroups(ctx context.Context) ([]*SensorGroup, error)
+	UpdateSensorGroup(ctx context.Context, id int, req *UpdateSensorGroupRequest) (*SensorGroup, error)
+	DeleteSensorGroup(ctx context.Context, id int) error
+	UpdateGroupMembers(ctx context.Context, groupID int, req *UpdateGroupMembersRequest) (*SensorGroup, error)
+	GetGroupSummary(ctx context.Context, groupID int) (*GroupSummary, error)
+
+	// Calibration
+	UpdateCalibration(ctx context.Context, sensorID int, req *UpdateCalibrationRequest, changedBy int) (*SensorCalibration, error)
+	ListCalibrations(ctx context.Context, sensorID int) ([]*SensorCalibration, error)
+
+	// Maintenance
+	SetMaintenance(ctx context.Context, sensorID int, req *SetMaintenanceRequest, setBy int) (*Sensor, error)
+
+	// Notes
+	CreateSensorNote(ctx context.Context, sensorID int, req *CreateSensorNoteRequest, authorID int) (*SensorNote, error)
+	ListSensorNotes(ctx context.Context, sensorID, page, perPage int) ([]*SensorNote, int, error)
+	DeleteSensorNote(ctx context.Context, sensorID int, noteID int64, userID int, isAdmin bool) error
+
+	// Device commands
+	SetCommandPublisher(publisher CommandPublisher)
+	SendCommand(ctx context.Context, sensorID int, req *SendCommandRequest, sentBy int) (*SensorCommand, error)
+	AcknowledgeCommand(ctx context.Context, deviceID string, ack *CommandAck) (*SensorCommand, error)
+	ListSensorCommands(ctx context.Context, sensorID, page, perPage int) ([]*SensorCommand, int, error)
+	TimeOutCommands(ctx context.Context, timeout time.Duration) (int64, error)
 
 	// Sensor readings
-	CreateSensorReading(req *CreateSensorReadingRequest) (*SensorReading, error)
-	CreateBulkSensorReadings(req *BulkSensorReadingRequest) error
-	GetSensorReadings(query *SensorReadingQuery) ([]*SensorReading, int, error)
-	GetLatestReading(sensorID int) (*SensorReading, error)
-	GetSensorStatistics(sensorID int, startTime, endTime time.Time) (*SensorStatistics, error)
+	CreateSensorReading(ctx context.Context, req *CreateSensorReadingRequest) (*SensorReading, error)
+	CreateBulkSensorReadings(ctx context.Context, req *BulkSensorReadingRequest) (*BulkReadingResult, error)
+	AllowIngest(sensor *Sensor) error
+	GetSensorReadings(ctx context.Context, query *SensorReadingQuery) ([]*SensorReading, int, error)
+	GetLatestReading(ctx context.Context, sensorID int) (*SensorReading, error)
+	GetUnitConversion(ctx context.Context, sensorID int, unit string) (*UnitConversion, error)
+	GetReadingByID(ctx context.Context, id int64) (*SensorReading, error)
+	UpdateReadingQuality(ctx context.Context, id int64, req *UpdateReadingQualityRequest, editedBy int) (*SensorReading, error)
+	GetSensorStatistics(ctx context.Context, sensorID int, startTime, endTime time.Time, comparePrevious bool) (*SensorStatistics, error)
+	GetAggregatedReadings(ctx context.Context, sensorID int, query *ReadingAggregateQuery) ([]*ReadingBucket, error)
+	GetDailyStatistics(ctx context.Context, sensorID int, startTime, endTime time.Time, timezone string) ([]*DailyStatistics, error)
+	GetReadingGaps(ctx context.Context, sensorID int, startTime, endTime time.Time, minGap time.Duration) (*GapReport, error)
+	DetectAnomalies(ctx context.Context, sensorID int, startTime, endTime time.Time, sigma float64) (*AnomalyReport, error)
+	GetAvailability(ctx context.Context, sensorID int, startTime, endTime time.Time) (*SensorAvailability, error)
+	PurgeReadings(ctx context.Context, req *PurgeReadingsRequest) (*PurgeReadingsResult, error)
+	DeleteReadingsInRange(ctx context.Context, sensorID int, startTime, endTime time.Time) (int64, error)
+	ApplyRetention(ctx context.Context, defaultRetentionDays int) (int64, error)
+
+	// Sensor status
+	DetectStatusChanges(ctx context.Context) ([]*SensorStatusEvent, error)
+	GetStatusHistory(ctx context.Context, sensorID, limit, offset int) ([]*SensorStatusEvent, int, error)
 
 	// Dashboard & Analytics
-	GetSensorsDashboard() (*DashboardData, error)
-	GetSensorHealth() ([]*SensorHealthStatus, error)
-	GetLocationSummary(locationID int) (*LocationSummary, error)
+	GetSensorsDashboard(ctx context.Context) (*DashboardData, CacheStatus, error)
+	GetSensorHealth(ctx context.Context, filter *SensorHealthFilter, page, perPage int) (*SensorHealthReport, int, CacheStatus, error)
+	GetLocationSummary(ctx context.Context, locationID int) (*LocationSummary, error)
+
+	// Sensor access grants
+	CreateSensorAccess(ctx context.Context, req *CreateSensorAccessRequest, grantedBy int) (*SensorAccess, error)
+	ListSensorAccess(ctx context.Context, sensorID, locationID *int) ([]*SensorAccess, error)
+	DeleteSensorAccess(ctx context.Context, id int) error
+
+	// WithScope returns a service limited to the organization in scope
+	WithScope(scope interfaces.Scope) Service
+
+	// WithAccess returns a service limited to the sensors the user can access
+	WithAccess(access Access) Service
+
+	// ReadableSensorIDs lists the sensors in the user's scope the user can
+	// read, for packages filtering per-sensor data such as alerts
+	ReadableSensorIDs(ctx context.Context, user *interfaces.User) ([]int, bool, error)
+}
+
+// ReadingEvaluator is notified of the sensors that received new readings,
+// such as the alert rule engine
+type ReadingEvaluator interface {
+	EvaluateSensor(sensorID int) error
+}
+
+// Notifier is told about sensors going offline and coming back online,
+// such as the webhook dispatcher
+type Notifier interface {
+	Publish(eventType string, organizationID int, data interface{})
 }
 
+// CommandPublisher delivers commands to devices, such as the MQTT broker
+type CommandPublisher interface {
+	PublishCommand(deviceID string, command interface{}) error
+	GetConnectionStatus() bool
+}
+
+// Config holds sensor service configuration
+type Config struct {
+	// MaxReadingDeleteDays bounds the window of a reading deletion; 0 uses 7 days
+	MaxReadingDeleteDays int
+	// Evaluator is run after readings are stored; nil disables evaluation
+	Evaluator ReadingEvaluator
+	// Notifier receives sensor status changes; nil disables notifications
+	Notifier Notifier
+	// OnlineThresholdMinutes is how recent the last reading of an online
+	// sensor without an expected interval is; 0 uses 30 minutes
+	OnlineThresholdMinutes int
+	// AutoProvision creates pending sensors of ProvisionSensorType for
+	// devices that report before they are registered
+	AutoProvision       bool
+	ProvisionSensorType string
+	// AllowUnauthenticatedIngest accepts readings without a device token
+	AllowUnauthenticatedIngest bool
+	// MaxBulkReadings bounds the readings of a bulk batch; 0 uses 10000
+	MaxBulkReadings int
+	// IngestRatePerMinute limits the readings each sensor can send unless it
+	// overrides it; 0 only limits sensors with an override
+	IngestRatePerMinute int
+	// IngestBurst is how many readings a sensor can send at once
+	IngestBurst int
+	// DashboardCacheSeconds is how long dashboard and health results are
+	// cached; 0 uses 20 seconds and a negative value disables caching
+	DashboardCacheSeconds int
+	// Health weights the sensor health checks; nil uses DefaultHealthPolicy
+	Health *HealthPolicy
+}
+
+// defaultMaxBulkReadings is the bulk batch limit when none is configured
+const defaultMaxBulkReadings = 10000
+
+// defaultMaxReadingDeleteDays is the deletion window when none is configured
+const defaultMaxReadingDeleteDays = 7
+
+// maxDashboardAlerts is how many sensors needing attention the dashboard lists
+const maxDashboardAlerts = 1000
+
 // service implements Service interface
 type service struct {
-	repo Repository
+	repo            Repository
+	maxDeleteWindow time.Duration
+	evaluator       ReadingEvaluator
+	notifier        Notifier
+	onlineThreshold time.Duration
+	autoProvision   bool
+	provisionType   string
+	// allowAnonymous accepts readings sent without a device token
+	allowAnonymous  bool
+	maxBulkReadings int
+	ingestLimiter   *ingestLimiter
+	commands        CommandPublisher
+	health          HealthPolicy
+	// cache is shared by the scoped copies of the service
+	cache  *resultCache
+	scope  interfaces.Scope
+	access Access
 }
 
 // NewService creates a new sensor service
-func NewService(repo Repository) Service {
+func NewService(repo Repository, cfg Config) Service {
+	maxDeleteDays := cfg.MaxReadingDeleteDays
+	if maxDeleteDays <= 0 {
+		maxDeleteDays = defaultMaxReadingDeleteDays
+	}
+
+	maxBulkReadings := cfg.MaxBulkReadings
+	if maxBulkReadings <= 0 {
+		maxBulkReadings = defaultMaxBulkReadings
+	}
+
+	onlineThresholdMinutes := cfg.OnlineThresholdMinutes
+	if onlineThresholdMinutes <= 0 {
+		onlineThresholdMinutes = DefaultOnlineThresholdMinutes
+	}
+
+	health := DefaultHealthPolicy()
+	if cfg.Health != nil {
+		health = *cfg.Health
+	}
+
+	cacheSeconds := cfg.DashboardCacheSeconds
+	if cacheSeconds == 0 {
+		cacheSeconds = DefaultDashboardCacheSeconds
+	}
+
 	return &service{
-		repo: repo,
+		repo:            repo,
+		maxDeleteWindow: time.Duration(maxDeleteDays) * 24 * time.Hour,
+		evaluator:       cfg.Evaluator,
+		notifier:        cfg.Notifier,
+		onlineThreshold: time.Duration(onlineThresholdMinutes) * time.Minute,
+		autoProvision:   cfg.AutoProvision,
+		provisionType:   cfg.ProvisionSensorType,
+		allowAnonymous:  cfg.AllowUnauthenticatedIngest,
+		maxBulkReadings: maxBulkReadings,
+		ingestLimiter:   newIngestLimiter(cfg.IngestRatePerMinute, cfg.IngestBurst),
+		health:          health,
+		cache:           newResultCache(time.Duration(cacheSeconds) * time.Second),
+	}
+}
+
+// WithScope returns a copy of the service whose sensors and locations are
+// limited to the scope. Sensors and locations created through it belong to
+// the scope's organization.
+func (s *service) WithScope(scope interfaces.Scope) Service {
+	scoped := *s
+	scoped.repo = s.repo.WithScope(scope)
+	scoped.scope = scope
+	return &scoped
+}
+
+// WithAccess returns a copy of the service whose sensors are limited to
+// those the user can access; updates additionally require write access.
+func (s *service) WithAccess(access Access) Service {
+	scoped := *s
+	scoped.repo = s.repo.WithAccess(access)
+	scoped.access = access
+	return &scoped
+}
+
+// ReadableSensorIDs returns the IDs of the sensors in the user's scope the
+// user can read. Users who can read every sensor, such as admins, get
+// restricted false and no IDs.
+func (s *service) ReadableSensorIDs(ctx context.Context, user *interfaces.User) ([]int, bool, error) {
+	access := AccessFor(user)
+	if !access.Restricted {
+		return nil, false, nil
 	}
+
+	ids, err := s.repo.WithScope(user.Scope()).WithAccess(access).ListSensorIDs(ctx)
+	if err != nil {
+		return nil, true, err
+	}
+	return ids, true, nil
 }
 
 // DashboardData represents sensor dashboard data
 type DashboardData struct {
-	TotalSensors   int                   `json:"total_sensors"`
-	ActiveSensors  int                   `json:"active_sensors"`
-	OnlineSensors  int                   `json:"online_sensors"`
-	OfflineSensors int                   `json:"offline_sensors"`
-	SensorsByType  map[string]int        `json:"sensors_by_type"`
-	RecentReadings []*SensorReading      `json:"recent_readings"`
-	AlertSensors   []*SensorHealthStatus `json:"alert_sensors"`
+	TotalSensors       int                    `json:"total_sensors"`
+	ActiveSensors      int                    `json:"active_sensors"`
+	OnlineSensors      int                    `json:"online_sensors"`
+	OfflineSensors     int                    `json:"offline_sensors"`
+	MaintenanceSensors int                    `json:"in_maintenance_sensors"`
+	SensorsByType      map[string]int         `json:"sensors_by_type"`
+	SensorsByLocation  []*LocationSensorCount `json:"sensors_by_location"`
+	RecentReadings     []*SensorReading       `json:"recent_readings"`
+	AlertSensors       []*SensorHealthStatus  `json:"alert_sensors"`
+}
+
+// LocationSensorCount counts the active sensors of a location; sensors
+// without a location are counted without location ID and name
+type LocationSensorCount struct {
+	LocationID     *int   `json:"location_id"`
+	LocationName   string `json:"location_name,omitempty"`
+	TotalSensors   int    `json:"total_sensors"`
+	OnlineSensors  int    `json:"online_sensors"`
+	OfflineSensors int    `json:"offline_sensors"`
+	AlertSensors   int    `json:"alert_sensors"`
 }
 
 // SensorHealthStatus represents sensor health information
 type SensorHealthStatus struct {
 	Sensor        *Sensor        `json:"sensor"`
 	IsOnline      bool           `json:"is_online"`
+	InMaintenance bool           `json:"in_maintenance"`
 	BatteryStatus string         `json:"battery_status"`
 	LastReading   *SensorReading `json:"last_reading,omitempty"`
 	HealthScore   int            `json:"health_score"` // 0-100
 	Issues        []string       `json:"issues,omitempty"`
 }
 
-// LocationSummary represents location summary data
-type LocationSummary struct {
-	Location       *Location        `json:"location"`
+// SensorHealthFilter narrows the sensor health statuses listed
+type SensorHealthFilter struct {
+	MinScore *int
+	MaxScore *int
+	// Issue matches statuses reporting the issue, case-insensitively
+	Issue      string
+	LocationID *int
+	// SortByScore orders by health score instead of newest sensor first
+	SortByScore bool
+	SortDesc    bool
+}
+
+// HealthScoreBucket counts the sensors scoring between MinScore and
+// MaxScore inclusive
+type HealthScoreBucket struct {
+	MinScore int `json:"min_score"`
+	MaxScore int `json:"max_score"`
+	Count    int `json:"count"`
+}
+
+// SensorHealthReport is a page of sensor health statuses with the score
+// distribution of every status matching the filter
+type SensorHealthReport struct {
+	Sensors []*SensorHealthStatus `json:"sensors"`
+	Summary []*HealthScoreBucket  `json:"summary"`
+}
+
+// healthScoreBucketSize is the width of the score distribution buckets; the
+// last bucket also holds perfect scores
+const healthScoreBucketSize = 20
+
+// SensorsSummary summarizes a set of sensors and their latest readings
+type SensorsSummary struct {
 	SensorCount    int              `json:"sensor_count"`
 	ActiveSensors  int              `json:"active_sensors"`
 	OnlineSensors  int              `json:"online_sensors"`
@@ -84,24 +358,27 @@ type LocationSummary struct {
 	LatestReadings []*SensorReading `json:"latest_readings"`
 }
 
+// LocationSummary represents location summary data
+type LocationSummary struct {
+	Location *Location `json:"location"`
+	SensorsSummary
+}
+
+// GroupSummary represents sensor group summary data
+type GroupSummary struct {
+	Group *SensorGroup `json:"group"`
+	SensorsSummary
+}
+
 // CreateSensor creates a new sensor with validation
-func (s *service) CreateSensor(req *CreateSensorRequest, createdBy int) (*Sensor, error) {
+func (s *service) CreateSensor(ctx context.Context, req *CreateSensorRequest, createdBy int) (*Sensor, error) {
 	// Validate request
 	if err := req.Validate(); err != nil {
 		return nil, err
 	}
 
-	// Check if device ID already exists
-	existingSensor, err := s.repo.GetSensorByDeviceID(req.DeviceID)
-	if err != nil && err != ErrSensorNotFound {
-		return nil, fmt.Errorf("failed to check existing sensor: %w", err)
-	}
-	if existingSensor != nil {
-		return nil, ErrDeviceIDExists
-	}
-
 	// Validate sensor type exists
-	sensorType, err := s.repo.GetSensorTypeByID(req.SensorTypeID)
+	sensorType, err := s.repo.GetSensorTypeByID(ctx, req.SensorTypeID)
 	if err != nil {
 		return nil, fmt.Errorf("invalid sensor type: %w", err)
 	}
@@ -111,7 +388,7 @@ func (s *service) CreateSensor(req *CreateSensorRequest, createdBy int) (*Sensor
 
 	// Validate location if provided
 	if req.LocationID != nil {
-		location, err := s.repo.GetLocationByID(*req.LocationID)
+		location, err := s.repo.GetLocationByID(ctx, *req.LocationID)
 		if err != nil {
 			return nil, fmt.Errorf("invalid location: %w", err)
 		}
@@ -126,23 +403,42 @@ func (s *service) CreateSensor(req *CreateSensorRequest, createdBy int) (*Sensor
 		return nil, err
 	}
 
-	if err := s.repo.CreateSensor(sensor); err != nil {
+	// The device token is only stored hashed, so it is returned this once
+	token, hash, err := generateDeviceToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate device token: %w", err)
+	}
+	sensor.DeviceTokenHash = hash
+
+	// Device IDs are unique across organizations, so the unique index rather
+	// than an organization-scoped lookup detects duplicates
+	if err := s.repo.CreateSensor(ctx, sensor); err != nil {
+		if errors.Is(err, ErrDeviceIDExists) {
+			return nil, err
+		}
 		return nil, fmt.Errorf("failed to create sensor: %w", err)
 	}
+	s.cache.invalidate()
 
 	// Load with related data
-	return s.repo.GetSensorByID(sensor.ID)
+	created, err := s.repo.GetSensorByID(ctx, sensor.ID)
+	if err != nil {
+		return nil, err
+	}
+	created.DeviceToken = token
+
+	return created, nil
 }
 
 // GetSensor retrieves sensor by ID with related data
-func (s *service) GetSensor(id int) (*Sensor, error) {
-	sensor, err := s.repo.GetSensorByID(id)
+func (s *service) GetSensor(ctx context.Context, id int) (*Sensor, error) {
+	sensor, err := s.repo.GetSensorByID(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get sensor: %w", err)
 	}
 
 	// Load latest reading
-	latestReading, err := s.repo.GetLatestReading(sensor.ID)
+	latestReading, err := s.repo.GetLatestReading(ctx, sensor.ID)
 	if err != nil {
 		log.Printf("Warning: failed to get latest reading for sensor %d: %v", sensor.ID, err)
 	} else if latestReading != nil {
@@ -153,14 +449,14 @@ func (s *service) GetSensor(id int) (*Sensor, error) {
 }
 
 // GetSensorByDeviceID retrieves sensor by device ID
-func (s *service) GetSensorByDeviceID(deviceID string) (*Sensor, error) {
-	sensor, err := s.repo.GetSensorByDeviceID(deviceID)
+func (s *service) GetSensorByDeviceID(ctx context.Context, deviceID string) (*Sensor, error) {
+	sensor, err := s.repo.GetSensorByDeviceID(ctx, deviceID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get sensor by device ID: %w", err)
 	}
 
 	// Load latest reading
-	latestReading, err := s.repo.GetLatestReading(sensor.ID)
+	latestReading, err := s.repo.GetLatestReading(ctx, sensor.ID)
 	if err != nil {
 		log.Printf("Warning: failed to get latest reading for sensor %d: %v", sensor.ID, err)
 	} else if latestReading != nil {
@@ -171,21 +467,27 @@ func (s *service) GetSensorByDeviceID(deviceID string) (*Sensor, error) {
 }
 
 // UpdateSensor updates sensor information
-func (s *service) UpdateSensor(id int, req *UpdateSensorRequest) (*Sensor, error) {
+func (s *service) UpdateSensor(ctx context.Context, id int, req *UpdateSensorRequest) (*Sensor, error) {
 	// Validate request
 	if err := req.Validate(); err != nil {
 		return nil, err
 	}
 
-	// Check if sensor exists (we don't need the result, just check existence)
-	_, err := s.repo.GetSensorByID(id)
+	// Check if sensor exists and is visible to the caller
+	sensor, err := s.repo.GetSensorByID(ctx, id)
+	if errors.Is(err, ErrSensorNotFound) {
+		return nil, ErrSensorNotFound
+	}
 	if err != nil {
 		return nil, fmt.Errorf("sensor not found: %w", err)
 	}
+	if !sensor.IsActive {
+		return nil, ErrSensorNotFound
+	}
 
 	// Validate location if being updated
 	if req.LocationID != nil {
-		location, err := s.repo.GetLocationByID(*req.LocationID)
+		location, err := s.repo.GetLocationByID(ctx, *req.LocationID)
 		if err != nil {
 			return nil, fmt.Errorf("invalid location: %w", err)
 		}
@@ -194,26 +496,169 @@ func (s *service) UpdateSensor(id int, req *UpdateSensorRequest) (*Sensor, error
 		}
 	}
 
-	// Update sensor
-	updatedSensor, err := s.repo.UpdateSensor(id, req)
+	// Update sensor; a visible sensor the update cannot reach lacks write access
+	updatedSensor, err := s.repo.UpdateSensor(ctx, id, req)
+	if errors.Is(err, ErrSensorNotFound) {
+		return nil, ErrSensorAccessDenied
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to update sensor: %w", err)
 	}
+	s.cache.invalidate()
+
+	// Notify once when the battery drops into the critical range
+	if s.notifier != nil && sensor.GetBatteryStatus() != "critical" && updatedSensor.GetBatteryStatus() == "critical" {
+		s.notifier.Publish(webhook.EventSensorBatteryCritical, updatedSensor.OrganizationID, &SensorBatteryEvent{
+			SensorID:       updatedSensor.ID,
+			DeviceID:       updatedSensor.DeviceID,
+			SensorName:     updatedSensor.Name,
+			BatteryLevel:   *updatedSensor.BatteryLevel,
+			OccurredAt:     time.Now().UTC(),
+			OrganizationID: updatedSensor.OrganizationID,
+		})
+	}
 
 	return updatedSensor, nil
 }
 
 // DeleteSensor deactivates a sensor
-func (s *service) DeleteSensor(id int) error {
-	if err := s.repo.DeleteSensor(id); err != nil {
+func (s *service) DeleteSensor(ctx context.Context, id int) error {
+	if err := s.repo.DeleteSensor(ctx, id); err != nil {
 		return fmt.Errorf("failed to delete sensor: %w", err)
 	}
+	s.cache.invalidate()
+
+	return nil
+}
+
+// PurgeSensor permanently deletes a sensor and all of its readings
+func (s *service) PurgeSensor(ctx context.Context, id int) (int64, error) {
+	deleted, err := s.repo.PurgeSensor(ctx, id)
+	if errors.Is(err, ErrSensorNotFound) {
+		return 0, ErrSensorNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge sensor: %w", err)
+	}
+	s.cache.invalidate()
+
+	return deleted, nil
+}
+
+// RotateDeviceToken issues a new device token for a sensor; the previous
+// token stops working immediately
+func (s *service) RotateDeviceToken(ctx context.Context, id int) (*Sensor, error) {
+	if _, err := s.repo.GetSensorByID(ctx, id); err != nil {
+		return nil, err
+	}
+
+	token, hash, err := generateDeviceToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate device token: %w", err)
+	}
+
+	if err := s.repo.UpdateDeviceTokenHash(ctx, id, hash); err != nil {
+		return nil, err
+	}
+
+	sensor, err := s.repo.GetSensorByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	sensor.DeviceToken = token
+
+	return sensor, nil
+}
+
+// AuthenticateDevice checks that the device token belongs to every sensor
+// readings are written to. Without a token it only passes while
+// unauthenticated ingest is allowed.
+func (s *service) AuthenticateDevice(ctx context.Context, token string, sensorIDs []int) error {
+	if token == "" {
+		if s.allowAnonymous {
+			return nil
+		}
+		return ErrDeviceTokenRequired
+	}
+
+	// Count each sensor once however many readings it has in the batch
+	seen := make(map[int]bool)
+	distinct := []int{}
+	for _, sensorID := range sensorIDs {
+		if !seen[sensorID] {
+			seen[sensorID] = true
+			distinct = append(distinct, sensorID)
+		}
+	}
+
+	matched, err := s.repo.CountSensorsWithDeviceToken(ctx, distinct, hashDeviceToken(token))
+	if err != nil {
+		return err
+	}
+	if matched != len(distinct) {
+		return ErrInvalidDeviceToken
+	}
 
 	return nil
 }
 
-// ListSensors returns paginated list of sensors
-func (s *service) ListSensors(page, perPage int) ([]*Sensor, int, error) {
+// CloneSensor creates a sensor with a new device ID that copies the type,
+// description, firmware, reporting settings, tags and metadata of an existing
+// sensor. Calibration belongs to the physical device and is not copied.
+func (s *service) CloneSensor(ctx context.Context, id int, req *CloneSensorRequest, createdBy int) (*Sensor, error) {
+	source, err := s.repo.GetSensorByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !source.IsProvisioned {
+		return nil, ErrSensorNotProvisioned
+	}
+
+	createReq := &CreateSensorRequest{
+		DeviceID:                req.DeviceID,
+		Name:                    source.Name,
+		Description:             source.Description,
+		SensorTypeID:            source.SensorTypeID,
+		LocationID:              source.LocationID,
+		FirmwareVersion:         source.FirmwareVersion,
+		ExpectedIntervalSeconds: source.ExpectedIntervalSeconds,
+		IngestRatePerMinute:     source.IngestRatePerMinute,
+		Tags:                    source.Tags,
+		Metadata:                source.Metadata,
+	}
+	if req.Name != nil {
+		createReq.Name = *req.Name
+	}
+	if req.LocationID != nil {
+		createReq.LocationID = req.LocationID
+	}
+
+	return s.CreateSensor(ctx, createReq, createdBy)
+}
+
+// ActivateSensor restores a deactivated sensor
+func (s *service) ActivateSensor(ctx context.Context, id int) (*Sensor, error) {
+	if _, err := s.repo.GetSensorByID(ctx, id); err != nil {
+		if errors.Is(err, ErrSensorNotFound) {
+			return nil, ErrSensorNotFound
+		}
+		return nil, fmt.Errorf("failed to get sensor: %w", err)
+	}
+
+	// A visible sensor the update cannot reach lacks write access
+	if err := s.repo.ActivateSensor(ctx, id); err != nil {
+		if errors.Is(err, ErrSensorNotFound) {
+			return nil, ErrSensorAccessDenied
+		}
+		return nil, fmt.Errorf("failed to activate sensor: %w", err)
+	}
+	s.cache.invalidate()
+
+	return s.repo.GetSensorByID(ctx, id)
+}
+
+// ListSensors returns paginated list of sensors matching the filter
+func (s *service) ListSensors(ctx context.Context, page, perPage int, filter *SensorFilter) ([]*Sensor, int, error) {
 	if page < 1 {
 		page = 1
 	}
@@ -223,43 +668,175 @@ func (s *service) ListSensors(page, perPage int) ([]*Sensor, int, error) {
 
 	offset := (page - 1) * perPage
 
-	sensors, total, err := s.repo.ListSensors(perPage, offset)
+	if filter == nil {
+		active := true
+		filter = &SensorFilter{IsActive: &active, SortBy: "created_at", SortDesc: true}
+	}
+	filter.OnlineThreshold = s.onlineThreshold
+
+	// Sensor types, locations and latest readings are loaded with the page
+	sensors, total, err := s.repo.ListSensors(ctx, filter, perPage, offset)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to list sensors: %w", err)
 	}
 
-	// Load sensor types and latest readings for each sensor
-	for _, sensor := range sensors {
-		// Load sensor type
-		if sensorType, err := s.repo.GetSensorTypeByID(sensor.SensorTypeID); err == nil {
-			sensor.SensorType = sensorType
-		}
+	return sensors, total, nil
+}
 
-		// Load location if exists
-		if sensor.LocationID != nil {
-			if location, err := s.repo.GetLocationByID(*sensor.LocationID); err == nil {
-				sensor.Location = location
-			}
+// ProvisionSensor creates a pending sensor for a device that reported before
+// it was registered. Its readings are stored, but it stays out of lists and
+// dashboards until an admin approves it.
+func (s *service) ProvisionSensor(ctx context.Context, deviceID string) (*Sensor, error) {
+	if !s.autoProvision {
+		return nil, ErrAutoProvisionDisabled
+	}
+
+	sensorType, err := s.repo.GetSensorTypeByName(ctx, s.provisionType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get provisioning sensor type: %w", err)
+	}
+
+	sensor, err := NewSensor(&CreateSensorRequest{
+		DeviceID:     deviceID,
+		Name:         deviceID,
+		Description:  "Auto-provisioned, awaiting approval",
+		SensorTypeID: sensorType.ID,
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+	sensor.IsProvisioned = false
+
+	err = s.repo.CreateSensor(ctx, sensor)
+	if errors.Is(err, ErrDeviceIDExists) {
+		// A concurrent message provisioned the device already
+		return s.repo.GetSensorByDeviceID(ctx, deviceID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to provision sensor: %w", err)
+	}
+
+	log.Printf("Auto-provisioned pending sensor %d for device %s", sensor.ID, sensor.DeviceID)
+
+	return s.repo.GetSensorByID(ctx, sensor.ID)
+}
+
+// ListPendingSensors returns the auto-provisioned sensors awaiting approval,
+// newest first
+func (s *service) ListPendingSensors(ctx context.Context, page, perPage int) ([]*Sensor, int, error) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > 100 {
+		perPage = 20
+	}
+
+	filter := &SensorFilter{Pending: true, SortBy: "created_at", SortDesc: true}
+	sensors, total, err := s.repo.ListSensors(ctx, filter, perPage, (page-1)*perPage)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list pending sensors: %w", err)
+	}
+
+	return sensors, total, nil
+}
+
+// ApproveSensor provisions a pending sensor with its real sensor type,
+// location and, for super admins, organization. Readings stored while it
+// was pending are kept. The returned sensor carries a newly issued device
+// token, since a pending sensor never had one handed out.
+func (s *service) ApproveSensor(ctx context.Context, id int, req *ApproveSensorRequest) (*Sensor, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if req.OrganizationID != nil && s.scope.Restricted {
+		return nil, ErrOrganizationSuperAdmin
+	}
+
+	sensor, err := s.repo.GetSensorByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if sensor.IsProvisioned {
+		return nil, ErrSensorProvisioned
+	}
+
+	// Inactive sensor types and locations cannot take new sensors
+	sensorType, err := s.repo.GetSensorTypeByID(ctx, req.SensorTypeID)
+	if err != nil {
+		return nil, err
+	}
+	if !sensorType.IsActive {
+		return nil, ErrSensorTypeNotFound
+	}
+
+	if req.LocationID != nil {
+		// The location must belong to the organization the sensor ends up in
+		locations := s.repo
+		if req.OrganizationID != nil {
+			locations = s.repo.WithScope(interfaces.Scope{OrganizationID: *req.OrganizationID, Restricted: true})
+		}
+		location, err := locations.GetLocationByID(ctx, *req.LocationID)
+		if err != nil {
+			return nil, err
 		}
+		if !location.IsActive {
+			return nil, ErrLocationNotFound
+		}
+	}
+
+	token, hash, err := generateDeviceToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate device token: %w", err)
+	}
 
-		// Load latest reading
-		if latestReading, err := s.repo.GetLatestReading(sensor.ID); err == nil && latestReading != nil {
-			sensor.LatestReading = latestReading
+	// A concurrent approval leaves nothing pending to update
+	if err := s.repo.ApproveSensor(ctx, id, req, hash); err != nil {
+		if errors.Is(err, ErrSensorNotFound) {
+			return nil, ErrSensorProvisioned
 		}
+		return nil, err
 	}
+	s.cache.invalidate()
 
-	return sensors, total, nil
+	approved, err := s.repo.GetSensorByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	approved.DeviceToken = token
+
+	return approved, nil
+}
+
+// ListSensorTags returns the tags in use with their sensor counts
+func (s *service) ListSensorTags(ctx context.Context) ([]*SensorTag, error) {
+	return s.repo.ListSensorTags(ctx)
+}
+
+// GetFirmwareReport returns how many sensors of each type run each firmware
+// version
+func (s *service) GetFirmwareReport(ctx context.Context) ([]*FirmwareVersionCount, error) {
+	return s.repo.GetFirmwareReport(ctx)
+}
+
+// ListFirmwareHistory returns the firmware version changes of a sensor
+func (s *service) ListFirmwareHistory(ctx context.Context, sensorID int) ([]*FirmwareChange, error) {
+	// Check if sensor exists and is visible to the caller
+	if _, err := s.repo.GetSensorByID(ctx, sensorID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.ListFirmwareHistory(ctx, sensorID)
 }
 
 // ListSensorsByLocation returns sensors by location
-func (s *service) ListSensorsByLocation(locationID int) ([]*Sensor, error) {
+func (s *service) ListSensorsByLocation(ctx context.Context, locationID int) ([]*Sensor, error) {
 	// Validate location exists
-	_, err := s.repo.GetLocationByID(locationID)
+	_, err := s.repo.GetLocationByID(ctx, locationID)
 	if err != nil {
 		return nil, fmt.Errorf("location not found: %w", err)
 	}
 
-	sensors, err := s.repo.ListSensorsByLocation(locationID)
+	sensors, err := s.repo.ListSensorsByLocation(ctx, locationID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list sensors by location: %w", err)
 	}
@@ -268,8 +845,8 @@ func (s *service) ListSensorsByLocation(locationID int) ([]*Sensor, error) {
 }
 
 // GetSensorType retrieves sensor type by ID
-func (s *service) GetSensorType(id int) (*SensorType, error) {
-	sensorType, err := s.repo.GetSensorTypeByID(id)
+func (s *service) GetSensorType(ctx context.Context, id int) (*SensorType, error) {
+	sensorType, err := s.repo.GetSensorTypeByID(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get sensor type: %w", err)
 	}
@@ -278,8 +855,8 @@ func (s *service) GetSensorType(id int) (*SensorType, error) {
 }
 
 // GetSensorTypeByName retrieves sensor type by name
-func (s *service) GetSensorTypeByName(name string) (*SensorType, error) {
-	sensorType, err := s.repo.GetSensorTypeByName(name)
+func (s *service) GetSensorTypeByName(ctx context.Context, name string) (*SensorType, error) {
+	sensorType, err := s.repo.GetSensorTypeByName(ctx, name)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get sensor type by name: %w", err)
 	}
@@ -288,8 +865,8 @@ func (s *service) GetSensorTypeByName(name string) (*SensorType, error) {
 }
 
 // ListSensorTypes returns all active sensor types
-func (s *service) ListSensorTypes() ([]*SensorType, error) {
-	sensorTypes, err := s.repo.ListSensorTypes()
+func (s *service) ListSensorTypes(ctx context.Context) ([]*SensorType, error) {
+	sensorTypes, err := s.repo.ListSensorTypes(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list sensor types: %w", err)
 	}
@@ -297,8 +874,69 @@ func (s *service) ListSensorTypes() ([]*SensorType, error) {
 	return sensorTypes, nil
 }
 
+// CreateSensorType creates a new sensor type
+func (s *service) CreateSensorType(ctx context.Context, req *CreateSensorTypeRequest) (*SensorType, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	sensorType := &SensorType{
+		Name:          req.Name,
+		Description:   strings.TrimSpace(req.Description),
+		Unit:          req.Unit,
+		MinValue:      req.MinValue,
+		MaxValue:      req.MaxValue,
+		RetentionDays: req.RetentionDays,
+		DecimalPlaces: defaultDecimalPlaces,
+		ValueLabels:   req.ValueLabels,
+		IsActive:      true,
+	}
+
+	if req.DecimalPlaces != nil {
+		sensorType.DecimalPlaces = *req.DecimalPlaces
+	}
+
+	if err := s.repo.CreateSensorType(ctx, sensorType); err != nil {
+		return nil, err
+	}
+
+	return sensorType, nil
+}
+
+// UpdateSensorType updates a sensor type. New bounds apply to readings
+// recorded from now on; stored readings are left as they are.
+func (s *service) UpdateSensorType(ctx context.Context, id int, req *UpdateSensorTypeRequest) (*SensorType, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	current, err := s.repo.GetSensorTypeByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the range the type ends up with
+	minValue, maxValue := current.MinValue, current.MaxValue
+	if req.MinValue != nil {
+		minValue = req.MinValue
+	}
+	if req.MaxValue != nil {
+		maxValue = req.MaxValue
+	}
+	if minValue != nil && maxValue != nil && *minValue >= *maxValue {
+		return nil, ErrInvalidValueRange
+	}
+
+	return s.repo.UpdateSensorType(ctx, id, req)
+}
+
+// DeleteSensorType deactivates a sensor type
+func (s *service) DeleteSensorType(ctx context.Context, id int) error {
+	return s.repo.DeactivateSensorType(ctx, id)
+}
+
 // CreateLocation creates a new location
-func (s *service) CreateLocation(req *CreateLocationRequest) (*Location, error) {
+func (s *service) CreateLocation(ctx context.Context, req *CreateLocationRequest) (*Location, error) {
 	// Validate request
 	if err := req.Validate(); err != nil {
 		return nil, err
@@ -310,7 +948,7 @@ func (s *service) CreateLocation(req *CreateLocationRequest) (*Location, error)
 		return nil, err
 	}
 
-	if err := s.repo.CreateLocation(location); err != nil {
+	if err := s.repo.CreateLocation(ctx, location); err != nil {
 		return nil, fmt.Errorf("failed to create location: %w", err)
 	}
 
@@ -318,8 +956,8 @@ func (s *service) CreateLocation(req *CreateLocationRequest) (*Location, error)
 }
 
 // GetLocation retrieves location by ID
-func (s *service) GetLocation(id int) (*Location, error) {
-	location, err := s.repo.GetLocationByID(id)
+func (s *service) GetLocation(ctx context.Context, id int) (*Location, error) {
+	location, err := s.repo.GetLocationByID(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get location: %w", err)
 	}
@@ -328,14 +966,14 @@ func (s *service) GetLocation(id int) (*Location, error) {
 }
 
 // UpdateLocation updates location information
-func (s *service) UpdateLocation(id int, req *UpdateLocationRequest) (*Location, error) {
+func (s *service) UpdateLocation(ctx context.Context, id int, req *UpdateLocationRequest) (*Location, error) {
 	// Validate request
 	if err := req.Validate(); err != nil {
 		return nil, err
 	}
 
 	// Update location
-	updatedLocation, err := s.repo.UpdateLocation(id, req)
+	updatedLocation, err := s.repo.UpdateLocation(ctx, id, req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update location: %w", err)
 	}
@@ -344,8 +982,8 @@ func (s *service) UpdateLocation(id int, req *UpdateLocationRequest) (*Location,
 }
 
 // ListLocations returns all active locations
-func (s *service) ListLocations() ([]*Location, error) {
-	locations, err := s.repo.ListLocations()
+func (s *service) ListLocations(ctx context.Context) ([]*Location, error) {
+	locations, err := s.repo.ListLocations(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list locations: %w", err)
 	}
@@ -353,15 +991,56 @@ func (s *service) ListLocations() ([]*Location, error) {
 	return locations, nil
 }
 
+// ListLocationsNear lists the locations within radiusKm of a point
+func (s *service) ListLocationsNear(ctx context.Context, lat, lng, radiusKm float64) (*NearbyLocations, error) {
+	// Written as ranges to hold so NaN is rejected too
+	if !(lat >= -90 && lat <= 90 && lng >= -180 && lng <= 180) {
+		return nil, ErrInvalidCoordinates
+	}
+	if !(radiusKm > 0 && radiusKm <= maxNearbyRadiusKm) {
+		return nil, ErrInvalidRadius
+	}
+
+	return s.repo.ListLocationsNear(ctx, lat, lng, radiusKm)
+}
+
+// DeactivateLocation deactivates a location, detaching its active sensors
+// when force is set
+func (s *service) DeactivateLocation(ctx context.Context, id int, force bool) (int, error) {
+	return s.repo.DeactivateLocation(ctx, id, force)
+}
+
+// AssignSensorsToLocation moves sensors to an active location and returns
+// the location's summary after the move
+func (s *service) AssignSensorsToLocation(ctx context.Context, id int, req *AssignSensorsRequest) (*LocationAssignment, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	assignment, err := s.repo.AssignSensorsToLocation(ctx, id, req.SensorIDs)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.invalidate()
+
+	summary, err := s.GetLocationSummary(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	assignment.Summary = summary
+
+	return assignment, nil
+}
+
 // CreateSensorReading creates a new sensor reading with validation
-func (s *service) CreateSensorReading(req *CreateSensorReadingRequest) (*SensorReading, error) {
+func (s *service) CreateSensorReading(ctx context.Context, req *CreateSensorReadingRequest) (*SensorReading, error) {
 	// Validate request
 	if err := req.Validate(); err != nil {
 		return nil, err
 	}
 
 	// Get sensor and validate
-	sensor, err := s.repo.GetSensorByID(req.SensorID)
+	sensor, err := s.repo.GetSensorByID(ctx, req.SensorID)
 	if err != nil {
 		return nil, fmt.Errorf("sensor not found: %w", err)
 	}
@@ -370,15 +1049,24 @@ func (s *service) CreateSensorReading(req *CreateSensorReadingRequest) (*SensorR
 		return nil, ErrSensorInactive
 	}
 
-	// Validate value against sensor type constraints
-	if err := sensor.ValidateValue(req.Value); err != nil {
+	if err := s.AllowIngest(sensor); err != nil {
 		return nil, err
 	}
 
+	// Validate the calibrated value against sensor type constraints; pending
+	// sensors only have a placeholder type, so their readings are kept as is
+	value := sensor.Calibrate(req.Value)
+	if sensor.IsProvisioned {
+		if err := sensor.ValidateValue(value); err != nil {
+			return nil, err
+		}
+	}
+
 	// Create reading
 	reading := &SensorReading{
 		SensorID:  req.SensorID,
-		Value:     req.Value,
+		Value:     value,
+		RawValue:  req.Value,
 		Timestamp: time.Now(),
 		Quality:   100,
 	}
@@ -395,86 +1083,217 @@ func (s *service) CreateSensorReading(req *CreateSensorReadingRequest) (*SensorR
 		reading.Metadata = req.Metadata
 	}
 
-	if err := s.repo.CreateSensorReading(reading); err != nil {
+	// A retried reading is already stored and evaluated
+	if err := s.repo.CreateSensorReading(ctx, reading); err != nil {
+		if errors.Is(err, ErrDuplicateReading) {
+			return nil, ErrDuplicateReading
+		}
 		return nil, fmt.Errorf("failed to create sensor reading: %w", err)
 	}
 
+	if sensor.IsProvisioned {
+		s.evaluateReadings(readingSensorIDs([]*SensorReading{reading}))
+	}
+
 	return reading, nil
 }
 
+// AllowIngest takes one message from the sensor's ingest rate limit and
+// returns a *RateLimitError when the sensor is sending too fast
+func (s *service) AllowIngest(sensor *Sensor) error {
+	if allowed, wait := s.ingestLimiter.allow(sensor, time.Now()); !allowed {
+		return &RateLimitError{RetryAfter: wait}
+	}
+	return nil
+}
+
 // CreateBulkSensorReadings creates multiple sensor readings
-func (s *service) CreateBulkSensorReadings(req *BulkSensorReadingRequest) error {
+func (s *service) CreateBulkSensorReadings(ctx context.Context, req *BulkSensorReadingRequest) (*BulkReadingResult, error) {
 	if len(req.Readings) == 0 {
-		return fmt.Errorf("no readings provided")
+		return nil, ErrNoReadings
 	}
 
-	if len(req.Readings) > 1000 {
-		return fmt.Errorf("too many readings, maximum 1000 per batch")
+	if len(req.Readings) > s.maxBulkReadings {
+		return nil, fmt.Errorf("%w, maximum %d per batch", ErrTooManyReadings, s.maxBulkReadings)
 	}
 
-	// Validate all readings and convert to SensorReading
-	readings := make([]*SensorReading, len(req.Readings))
+	// Validate all readings and convert to SensorReading. In partial mode
+	// invalid readings are reported and the valid ones still stored.
+	readings := make([]*SensorReading, 0, len(req.Readings))
 	sensorCache := make(map[int]*Sensor)
+	limited := make(map[int]error)
+	rejected := []BulkReadingError{}
 
-	for i, readingReq := range req.Readings {
-		// Validate reading request
-		if err := readingReq.Validate(); err != nil {
-			return fmt.Errorf("reading %d: %w", i+1, err)
+	for i := range req.Readings {
+		reading, rejection, err := s.newBulkReading(ctx, &req.Readings[i], sensorCache, limited)
+		if err != nil {
+			return nil, fmt.Errorf("reading %d: %w", i+1, err)
 		}
-
-		// Get sensor (with caching)
-		sensor, exists := sensorCache[readingReq.SensorID]
-		if !exists {
-			var err error
-			sensor, err = s.repo.GetSensorByID(readingReq.SensorID)
-			if err != nil {
-				return fmt.Errorf("reading %d: sensor not found: %w", i+1, err)
+		if rejection != nil {
+			if !req.AllowPartial {
+				// A sensor over its rate limit is not an invalid reading
+				if errors.Is(rejection, ErrRateLimited) {
+					return nil, rejection
+				}
+				return nil, fmt.Errorf("reading %d: %w: %w", i+1, ErrInvalidReading, rejection)
 			}
-			sensorCache[readingReq.SensorID] = sensor
+			rejected = append(rejected, BulkReadingError{Index: i, Reason: rejection.Error()})
+			continue
 		}
 
-		if !sensor.IsActive {
-			return fmt.Errorf("reading %d: sensor is inactive", i+1)
-		}
+		readings = append(readings, reading)
+	}
 
-		// Validate value
-		if err := sensor.ValidateValue(readingReq.Value); err != nil {
-			return fmt.Errorf("reading %d: %w", i+1, err)
+	// Create all readings in bulk
+	insertedBySensor, err := s.repo.CreateBulkSensorReadings(ctx, readings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bulk sensor readings: %w", err)
+	}
+
+	// Skipped duplicates were evaluated when first stored, and pending
+	// sensors are evaluated once they are approved with a real type
+	inserted := 0
+	sensorIDs := []int{}
+	for _, sensorID := range readingSensorIDs(readings) {
+		inserted += insertedBySensor[sensorID]
+		if insertedBySensor[sensorID] > 0 && sensorCache[sensorID].IsProvisioned {
+			sensorIDs = append(sensorIDs, sensorID)
 		}
+	}
+	s.evaluateReadings(sensorIDs)
 
-		// Create reading
-		reading := &SensorReading{
-			SensorID:  readingReq.SensorID,
-			Value:     readingReq.Value,
-			Timestamp: time.Now(),
-			Quality:   100,
+	return &BulkReadingResult{
+		Count:      len(req.Readings),
+		Accepted:   len(readings),
+		Rejected:   len(rejected),
+		Inserted:   inserted,
+		Duplicates: len(readings) - inserted,
+		Errors:     rejected,
+	}, nil
+}
+
+// newBulkReading converts a reading of a batch, loading its sensor through
+// the cache. A batch counts once against the ingest rate limit of each of
+// its sensors, the outcome kept in limited. A rejection makes only this
+// reading invalid, while err fails the whole batch.
+func (s *service) newBulkReading(ctx context.Context, req *CreateSensorReadingRequest, sensorCache map[int]*Sensor, limited map[int]error) (reading *SensorReading, rejection error, err error) {
+	// Validate reading request
+	if err := req.Validate(); err != nil {
+		return nil, err, nil
+	}
+
+	// Get sensor (with caching)
+	sensor, exists := sensorCache[req.SensorID]
+	if !exists {
+		sensor, err = s.repo.GetSensorByID(ctx, req.SensorID)
+		if errors.Is(err, ErrSensorNotFound) {
+			return nil, fmt.Errorf("sensor not found: %w", err), nil
+		}
+		if err != nil {
+			return nil, nil, err
 		}
+		sensorCache[req.SensorID] = sensor
+		if sensor.IsActive {
+			limited[req.SensorID] = s.AllowIngest(sensor)
+		}
+	}
+
+	if !sensor.IsActive {
+		return nil, ErrSensorInactive, nil
+	}
+	if err := limited[req.SensorID]; err != nil {
+		return nil, err, nil
+	}
 
-		if readingReq.Timestamp != nil {
-			reading.Timestamp = *readingReq.Timestamp
+	// Validate the calibrated value unless the sensor is pending
+	value := sensor.Calibrate(req.Value)
+	if sensor.IsProvisioned {
+		if err := sensor.ValidateValue(value); err != nil {
+			return nil, err, nil
 		}
+	}
+
+	// Create reading
+	reading = &SensorReading{
+		SensorID:  req.SensorID,
+		Value:     value,
+		RawValue:  req.Value,
+		Timestamp: time.Now(),
+		Quality:   100,
+	}
+
+	if req.Timestamp != nil {
+		reading.Timestamp = *req.Timestamp
+	}
+
+	if req.Quality != nil {
+		reading.Quality = *req.Quality
+	}
+
+	if req.Metadata != nil {
+		reading.Metadata = req.Metadata
+	}
+
+	return reading, nil, nil
+}
 
-		if readingReq.Quality != nil {
-			reading.Quality = *readingReq.Quality
+// readingSensorIDs returns the distinct sensors of the readings in order
+func readingSensorIDs(readings []*SensorReading) []int {
+	seen := make(map[int]bool)
+	sensorIDs := []int{}
+	for _, reading := range readings {
+		if !seen[reading.SensorID] {
+			seen[reading.SensorID] = true
+			sensorIDs = append(sensorIDs, reading.SensorID)
 		}
+	}
+	return sensorIDs
+}
+
+// evaluateReadings runs the evaluator for sensors that received readings.
+// Evaluation is best-effort: failures are logged and never fail ingestion.
+func (s *service) evaluateReadings(sensorIDs []int) {
+	if s.evaluator == nil {
+		return
+	}
 
-		if readingReq.Metadata != nil {
-			reading.Metadata = readingReq.Metadata
+	for _, sensorID := range sensorIDs {
+		if err := s.evaluator.EvaluateSensor(sensorID); err != nil {
+			log.Printf("Warning: failed to evaluate readings of sensor %d: %v", sensorID, err)
 		}
+	}
+}
+
+// GetReadingByID retrieves a sensor reading by ID
+func (s *service) GetReadingByID(ctx context.Context, id int64) (*SensorReading, error) {
+	return s.repo.GetReadingByID(ctx, id)
+}
 
-		readings[i] = reading
+// UpdateReadingQuality corrects the quality and metadata of a reading
+func (s *service) UpdateReadingQuality(ctx context.Context, id int64, req *UpdateReadingQualityRequest, editedBy int) (*SensorReading, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
 	}
 
-	// Create all readings in bulk
-	if err := s.repo.CreateBulkSensorReadings(readings); err != nil {
-		return fmt.Errorf("failed to create bulk sensor readings: %w", err)
+	// Check if reading exists and is visible to the caller
+	if _, err := s.repo.GetReadingByID(ctx, id); err != nil {
+		return nil, err
 	}
 
-	return nil
+	// A visible reading the update cannot reach lacks write access to its sensor
+	reading, err := s.repo.UpdateReadingQuality(ctx, id, req, editedBy)
+	if errors.Is(err, ErrReadingNotFound) {
+		return nil, ErrSensorAccessDenied
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return reading, nil
 }
 
 // GetSensorReadings retrieves sensor readings with filters
-func (s *service) GetSensorReadings(query *SensorReadingQuery) ([]*SensorReading, int, error) {
+func (s *service) GetSensorReadings(ctx context.Context, query *SensorReadingQuery) ([]*SensorReading, int, error) {
 	// Set default limits
 	if query.Limit <= 0 {
 		query.Limit = 100
@@ -488,13 +1307,13 @@ func (s *service) GetSensorReadings(query *SensorReadingQuery) ([]*SensorReading
 
 	// Validate sensor if specified
 	if query.SensorID != nil {
-		_, err := s.repo.GetSensorByID(*query.SensorID)
+		_, err := s.repo.GetSensorByID(ctx, *query.SensorID)
 		if err != nil {
 			return nil, 0, fmt.Errorf("sensor not found: %w", err)
 		}
 	}
 
-	readings, total, err := s.repo.GetSensorReadings(query)
+	readings, total, err := s.repo.GetSensorReadings(ctx, query)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to get sensor readings: %w", err)
 	}
@@ -502,15 +1321,26 @@ func (s *service) GetSensorReadings(query *SensorReadingQuery) ([]*SensorReading
 	return readings, total, nil
 }
 
+// GetUnitConversion returns the conversion of a sensor's values to the
+// named unit, or an *UnsupportedUnitError listing the units it supports
+func (s *service) GetUnitConversion(ctx context.Context, sensorID int, unit string) (*UnitConversion, error) {
+	sensor, err := s.repo.GetSensorByID(ctx, sensorID)
+	if err != nil {
+		return nil, err
+	}
+
+	return FindUnitConversion(sensor.SensorType, unit)
+}
+
 // GetLatestReading retrieves latest reading for a sensor
-func (s *service) GetLatestReading(sensorID int) (*SensorReading, error) {
+func (s *service) GetLatestReading(ctx context.Context, sensorID int) (*SensorReading, error) {
 	// Validate sensor exists
-	_, err := s.repo.GetSensorByID(sensorID)
+	_, err := s.repo.GetSensorByID(ctx, sensorID)
 	if err != nil {
 		return nil, fmt.Errorf("sensor not found: %w", err)
 	}
 
-	reading, err := s.repo.GetLatestReading(sensorID)
+	reading, err := s.repo.GetLatestReading(ctx, sensorID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get latest reading: %w", err)
 	}
@@ -518,10 +1348,11 @@ func (s *service) GetLatestReading(sensorID int) (*SensorReading, error) {
 	return reading, nil
 }
 
-// GetSensorStatistics calculates statistics for a sensor
-func (s *service) GetSensorStatistics(sensorID int, startTime, endTime time.Time) (*SensorStatistics, error) {
+// GetSensorStatistics calculates statistics for a sensor, optionally
+// compared with the preceding window of equal length
+func (s *service) GetSensorStatistics(ctx context.Context, sensorID int, startTime, endTime time.Time, comparePrevious bool) (*SensorStatistics, error) {
 	// Validate sensor exists
-	_, err := s.repo.GetSensorByID(sensorID)
+	_, err := s.repo.GetSensorByID(ctx, sensorID)
 	if err != nil {
 		return nil, fmt.Errorf("sensor not found: %w", err)
 	}
@@ -531,52 +1362,387 @@ func (s *service) GetSensorStatistics(sensorID int, startTime, endTime time.Time
 		return nil, fmt.Errorf("end time must be after start time")
 	}
 
-	stats, err := s.repo.GetSensorStatistics(sensorID, startTime, endTime)
+	stats, err := s.repo.GetSensorStatistics(ctx, sensorID, startTime, endTime)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get sensor statistics: %w", err)
 	}
 
+	if comparePrevious {
+		// The range end is inclusive, so the previous window stops just
+		// before the current one starts
+		previousEnd := startTime.Add(-time.Microsecond)
+		previousStart := startTime.Add(-endTime.Sub(startTime))
+
+		previous, err := s.repo.GetSensorStatistics(ctx, sensorID, previousStart, previousEnd)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get previous period statistics: %w", err)
+		}
+
+		stats.Comparison = CompareStatistics(stats, previous)
+		stats.Comparison.PreviousStartTime = previousStart
+		stats.Comparison.PreviousEndTime = previousEnd
+	}
+
 	return stats, nil
 }
 
-// GetSensorsDashboard returns dashboard data with sensor overview
-func (s *service) GetSensorsDashboard() (*DashboardData, error) {
-	// Get all sensors for counting
-	sensors, _, err := s.repo.ListSensors(1000, 0) // Get up to 1000 sensors for dashboard
+// GetAggregatedReadings returns a sensor's readings grouped into buckets of
+// the query interval, with the query function selecting each bucket value
+func (s *service) GetAggregatedReadings(ctx context.Context, sensorID int, query *ReadingAggregateQuery) ([]*ReadingBucket, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.repo.GetSensorByID(ctx, sensorID); err != nil {
+		return nil, err
+	}
+
+	buckets, err := s.repo.GetAggregatedReadings(ctx, sensorID, query)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get sensors for dashboard: %w", err)
+		return nil, err
 	}
 
-	dashboard := &DashboardData{
-		TotalSensors:   len(sensors),
-		SensorsByType:  make(map[string]int),
-		RecentReadings: []*SensorReading{},
-		AlertSensors:   []*SensorHealthStatus{},
+	for _, bucket := range buckets {
+		switch query.Fn {
+		case AggregateMin:
+			bucket.Value = bucket.Min
+		case AggregateMax:
+			bucket.Value = bucket.Max
+		case AggregateSum:
+			bucket.Value = bucket.Sum
+		default:
+			bucket.Value = bucket.Avg
+		}
 	}
 
-	onlineThreshold := 30 // 30 minutes
+	if query.Fill {
+		buckets = fillBuckets(buckets, query)
+	}
 
-	// Process each sensor
-	for _, sensor := range sensors {
-		if sensor.IsActive {
-			dashboard.ActiveSensors++
+	return buckets, nil
+}
+
+// GetDailyStatistics returns the count, min, max and average of a sensor's
+// readings per calendar day in the IANA time zone
+func (s *service) GetDailyStatistics(ctx context.Context, sensorID int, startTime, endTime time.Time, timezone string) ([]*DailyStatistics, error) {
+	if !endTime.After(startTime) {
+		return nil, ErrInvalidTimeRange
+	}
+	if endTime.Sub(startTime) > maxDailyStatisticsDays*24*time.Hour {
+		return nil, ErrDailyRangeTooBig
+	}
+
+	// "Local" would be the server's zone rather than an IANA name
+	if timezone == "" || timezone == "Local" {
+		return nil, ErrInvalidTimezone
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return nil, ErrInvalidTimezone
+	}
+
+	if _, err := s.repo.GetSensorByID(ctx, sensorID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetDailyStatistics(ctx, sensorID, startTime, endTime, timezone)
+}
+
+// GetReadingGaps reports the gaps longer than minGap between consecutive
+// readings of the time range, including those at its edges. A zero minGap uses the sensor's online
+// threshold, derived from its expected interval when it declares one.
+func (s *service) GetReadingGaps(ctx context.Context, sensorID int, startTime, endTime time.Time, minGap time.Duration) (*GapReport, error) {
+	if !endTime.After(startTime) {
+		return nil, ErrInvalidTimeRange
+	}
+	if endTime.Sub(startTime) > maxGapReportDays*24*time.Hour {
+		return nil, ErrGapRangeTooBig
+	}
+	if minGap != 0 && minGap < time.Second {
+		return nil, ErrInvalidMinGap
+	}
+
+	sensor, err := s.repo.GetSensorByID(ctx, sensorID)
+	if err != nil {
+		return nil, err
+	}
+
+	if minGap == 0 {
+		minGap = sensor.OnlineThreshold(s.onlineThreshold)
+	}
+
+	report, err := s.repo.ListReadingGaps(ctx, sensorID, startTime, endTime, minGap, maxReportedGaps)
+	if err != nil {
+		return nil, err
+	}
+
+	report.SensorID = sensorID
+	report.StartTime = startTime
+	report.EndTime = endTime
+	report.MinGapSeconds = minGap.Seconds()
+	report.Truncated = report.GapCount > len(report.Gaps)
+
+	return report, nil
+}
+
+// DetectAnomalies finds the readings of the time range whose value is more
+// than sigma standard deviations from the mean of the range. Ranges with too
+// few readings or without any variance return ErrTooFewReadings and
+// ErrZeroVariance rather than an empty report.
+func (s *service) DetectAnomalies(ctx context.Context, sensorID int, startTime, endTime time.Time, sigma float64) (*AnomalyReport, error) {
+	if !endTime.After(startTime) {
+		return nil, ErrInvalidTimeRange
+	}
+	// Written so NaN, which fails every comparison, is rejected too
+	if !(sigma > 0 && sigma <= maxAnomalySigma) {
+		return nil, ErrInvalidSigma
+	}
+
+	if _, err := s.repo.GetSensorByID(ctx, sensorID); err != nil {
+		return nil, err
+	}
+
+	count, mean, stddev, err := s.repo.GetValueSpread(ctx, sensorID, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &AnomalyReport{
+		SensorID:  sensorID,
+		StartTime: startTime,
+		EndTime:   endTime,
+		Sigma:     sigma,
+		Count:     count,
+		Mean:      mean,
+		StdDev:    stddev,
+		Anomalies: []*SensorReading{},
+	}
+
+	if count < minAnomalyReadings {
+		return report, ErrTooFewReadings
+	}
+	if mean == nil || stddev == nil || *stddev == 0 {
+		return report, ErrZeroVariance
+	}
+
+	report.LowerBound = *mean - sigma*(*stddev)
+	report.UpperBound = *mean + sigma*(*stddev)
+
+	// One extra reading tells whether the list was cut off
+	anomalies, err := s.repo.ListReadingsOutside(ctx, sensorID, startTime, endTime, report.LowerBound, report.UpperBound, maxAnomalies+1)
+	if err != nil {
+		return nil, err
+	}
+	if len(anomalies) > maxAnomalies {
+		anomalies = anomalies[:maxAnomalies]
+		report.Truncated = true
+	}
+	report.Anomalies = anomalies
+
+	return report, nil
+}
+
+// GetAvailability computes the share of the time range a sensor was online.
+// Gaps between readings longer than the sensor's online threshold count as
+// downtime; the range is limited to the sensor's lifetime so far.
+func (s *service) GetAvailability(ctx context.Context, sensorID int, startTime, endTime time.Time) (*SensorAvailability, error) {
+	if !endTime.After(startTime) {
+		return nil, ErrInvalidTimeRange
+	}
+	if endTime.Sub(startTime) > maxAvailabilityDays*24*time.Hour {
+		return nil, ErrAvailabilityRangeTooBig
+	}
+
+	sensor, err := s.repo.GetSensorByID(ctx, sensorID)
+	if err != nil {
+		return nil, err
+	}
+
+	if startTime.Before(sensor.CreatedAt) {
+		startTime = sensor.CreatedAt
+	}
+	if now := time.Now().UTC(); endTime.After(now) {
+		endTime = now
+	}
+
+	threshold := sensor.OnlineThreshold(s.onlineThreshold)
+
+	availability := &SensorAvailability{UptimePercent: 100}
+	if endTime.After(startTime) {
+		availability, err = s.repo.GetReadingGaps(ctx, sensorID, startTime, endTime, threshold)
+		if err != nil {
+			return nil, err
 		}
 
-		// Check if sensor is online
-		if sensor.IsOnline(onlineThreshold) {
-			dashboard.OnlineSensors++
-		} else {
-			dashboard.OfflineSensors++
+		total := endTime.Sub(startTime).Seconds()
+		availability.UptimePercent = math.Round((total-availability.DowntimeSeconds)/total*10000) / 100
+	}
+
+	availability.SensorID = sensorID
+	availability.StartTime = startTime
+	availability.EndTime = endTime
+	availability.GapThresholdSeconds = threshold.Seconds()
+
+	return availability, nil
+}
+
+// fillBuckets adds empty buckets for the intervals without readings
+func fillBuckets(buckets []*ReadingBucket, query *ReadingAggregateQuery) []*ReadingBucket {
+	byStart := make(map[int64]*ReadingBucket, len(buckets))
+	for _, bucket := range buckets {
+		byStart[bucket.BucketStart.Unix()] = bucket
+	}
+
+	step := int64(query.Interval.Seconds())
+	first := query.StartTime.Unix() / step * step
+
+	filled := []*ReadingBucket{}
+	for start := first; start < query.EndTime.Unix(); start += step {
+		if bucket, ok := byStart[start]; ok {
+			filled = append(filled, bucket)
+			continue
 		}
+		filled = append(filled, &ReadingBucket{BucketStart: time.Unix(start, 0).UTC()})
+	}
 
-		// Count by sensor type
-		if sensor.SensorType != nil {
-			dashboard.SensorsByType[sensor.SensorType.Name]++
+	return filled
+}
+
+// PurgeReadings deletes, or with DryRun counts, the readings older than
+// req.Before, optionally of a single sensor
+func (s *service) PurgeReadings(ctx context.Context, req *PurgeReadingsRequest) (*PurgeReadingsResult, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	if req.SensorID != nil {
+		if _, err := s.repo.GetSensorByID(ctx, *req.SensorID); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &PurgeReadingsResult{DryRun: req.DryRun}
+
+	// Reading timestamps are stored in UTC without a time zone
+	before := req.Before.UTC()
+
+	var err error
+	if req.DryRun {
+		result.Readings, err = s.repo.CountReadingsBefore(ctx, req.SensorID, before)
+	} else {
+		result.Readings, err = s.repo.DeleteReadingsBefore(ctx, req.SensorID, before)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// DeleteReadingsInRange deletes a sensor's readings between startTime and
+// endTime, refusing windows longer than the configured maximum
+func (s *service) DeleteReadingsInRange(ctx context.Context, sensorID int, startTime, endTime time.Time) (int64, error) {
+	if startTime.IsZero() || endTime.IsZero() {
+		return 0, ErrTimeRangeRequired
+	}
+	if !endTime.After(startTime) {
+		return 0, ErrInvalidTimeRange
+	}
+	if endTime.Sub(startTime) > s.maxDeleteWindow {
+		return 0, ErrDeleteWindowTooBig
+	}
+
+	// Check if sensor exists and is visible to the caller
+	if _, err := s.repo.GetSensorByID(ctx, sensorID); err != nil {
+		return 0, err
+	}
+
+	// A visible sensor the deletion cannot reach lacks write access
+	deleted, err := s.repo.DeleteReadingsInRange(ctx, sensorID, startTime, endTime)
+	if errors.Is(err, ErrSensorNotFound) {
+		return 0, ErrSensorAccessDenied
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return deleted, nil
+}
+
+// DetectStatusChanges records the sensors that went offline or came back
+// online since the last check and notifies about each transition
+func (s *service) DetectStatusChanges(ctx context.Context) ([]*SensorStatusEvent, error) {
+	events, err := s.repo.RecordStatusTransitions(ctx, time.Now(), s.onlineThreshold)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.notifier != nil {
+		for _, event := range events {
+			eventType := webhook.EventSensorOnline
+			if event.Status == SensorStatusOffline {
+				eventType = webhook.EventSensorOffline
+			}
+			s.notifier.Publish(eventType, event.OrganizationID, event)
 		}
+	}
+
+	return events, nil
+}
+
+// GetStatusHistory retrieves the status transitions of a sensor
+func (s *service) GetStatusHistory(ctx context.Context, sensorID, limit, offset int) ([]*SensorStatusEvent, int, error) {
+	// Check if sensor exists and is visible to the caller
+	if _, err := s.repo.GetSensorByID(ctx, sensorID); err != nil {
+		return nil, 0, err
+	}
+
+	return s.repo.ListStatusEvents(ctx, sensorID, limit, offset)
+}
+
+// ApplyRetention deletes readings older than the retention of their sensor type
+func (s *service) ApplyRetention(ctx context.Context, defaultRetentionDays int) (int64, error) {
+	return s.repo.DeleteExpiredReadings(ctx, defaultRetentionDays)
+}
+
+// GetSensorsDashboard returns dashboard data with sensor overview, cached
+// briefly for callers seeing the same sensors
+func (s *service) GetSensorsDashboard(ctx context.Context) (*DashboardData, CacheStatus, error) {
+	value, status, err := s.cache.get(s.cacheKey("dashboard"), func() (interface{}, error) {
+		// Concurrent callers share the result, so one of them going away
+		// must not cancel it for the others
+		return s.loadDashboard(context.WithoutCancel(ctx))
+	})
+	if err != nil {
+		return nil, status, err
+	}
+	return value.(*DashboardData), status, nil
+}
+
+// loadDashboard computes the dashboard data
+func (s *service) loadDashboard(ctx context.Context) (*DashboardData, error) {
+	// Count sensors in SQL; sensors in maintenance are expected to be silent
+	// and count as neither online nor offline
+	now := time.Now()
+	dashboard, err := s.repo.GetDashboardCounts(ctx, now, s.onlineThreshold)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count sensors for dashboard: %w", err)
+	}
+	dashboard.RecentReadings = []*SensorReading{}
+	dashboard.AlertSensors = []*SensorHealthStatus{}
+
+	dashboard.SensorsByLocation, err = s.repo.CountSensorsByLocation(ctx, now, s.onlineThreshold, s.health)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count sensors by location: %w", err)
+	}
 
-		// Check for alerts
+	// Only load the sensors that may need attention
+	candidates, err := s.repo.ListAlertCandidates(ctx, now, s.onlineThreshold, s.health, maxDashboardAlerts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sensors for dashboard: %w", err)
+	}
+	for _, sensor := range candidates {
 		healthStatus := s.calculateSensorHealth(sensor)
-		if healthStatus.HealthScore < 80 || len(healthStatus.Issues) > 0 {
+		if healthStatus.HealthScore < s.health.AlertScore || len(healthStatus.Issues) > 0 {
 			dashboard.AlertSensors = append(dashboard.AlertSensors, healthStatus)
 		}
 	}
@@ -586,7 +1752,7 @@ func (s *service) GetSensorsDashboard() (*DashboardData, error) {
 		Limit:  50,
 		Offset: 0,
 	}
-	recentReadings, _, err := s.repo.GetSensorReadings(recentQuery)
+	recentReadings, _, err := s.repo.GetSensorReadings(ctx, recentQuery)
 	if err != nil {
 		log.Printf("Warning: failed to get recent readings for dashboard: %v", err)
 	} else {
@@ -596,117 +1762,566 @@ func (s *service) GetSensorsDashboard() (*DashboardData, error) {
 	return dashboard, nil
 }
 
-// GetSensorHealth returns health status for all sensors
-func (s *service) GetSensorHealth() ([]*SensorHealthStatus, error) {
-	sensors, _, err := s.repo.ListSensors(1000, 0)
+// GetSensorHealth returns a page of the health statuses matching the filter
+// with their total and score distribution. Statuses of all sensors are
+// cached briefly for callers seeing the same sensors.
+func (s *service) GetSensorHealth(ctx context.Context, filter *SensorHealthFilter, page, perPage int) (*SensorHealthReport, int, CacheStatus, error) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > 100 {
+		perPage = 20
+	}
+
+	value, status, err := s.cache.get(s.cacheKey("health"), func() (interface{}, error) {
+		return s.loadSensorHealth(context.WithoutCancel(ctx))
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get sensors for health check: %w", err)
+		return nil, 0, status, err
 	}
 
-	healthStatuses := make([]*SensorHealthStatus, len(sensors))
+	// The cached statuses are shared, so matches are collected in a new slice
+	matched := []*SensorHealthStatus{}
+	for _, health := range value.([]*SensorHealthStatus) {
+		if filter.matches(health) {
+			matched = append(matched, health)
+		}
+	}
 
-	for i, sensor := range sensors {
-		healthStatuses[i] = s.calculateSensorHealth(sensor)
+	if filter.SortByScore {
+		sort.SliceStable(matched, func(i, j int) bool {
+			if filter.SortDesc {
+				return matched[i].HealthScore > matched[j].HealthScore
+			}
+			return matched[i].HealthScore < matched[j].HealthScore
+		})
+	}
+
+	report := &SensorHealthReport{
+		Sensors: []*SensorHealthStatus{},
+		Summary: summarizeHealthScores(matched),
+	}
+	if offset := (page - 1) * perPage; offset < len(matched) {
+		end := offset + perPage
+		if end > len(matched) {
+			end = len(matched)
+		}
+		report.Sensors = matched[offset:end]
+	}
+
+	return report, len(matched), status, nil
+}
+
+// matches reports whether a health status passes the filter
+func (f *SensorHealthFilter) matches(health *SensorHealthStatus) bool {
+	if f.MinScore != nil && health.HealthScore < *f.MinScore {
+		return false
+	}
+	if f.MaxScore != nil && health.HealthScore > *f.MaxScore {
+		return false
+	}
+	if f.LocationID != nil && (health.Sensor.LocationID == nil || *health.Sensor.LocationID != *f.LocationID) {
+		return false
+	}
+	if f.Issue != "" {
+		for _, issue := range health.Issues {
+			if strings.EqualFold(issue, f.Issue) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// summarizeHealthScores counts the statuses in every score bucket
+func summarizeHealthScores(statuses []*SensorHealthStatus) []*HealthScoreBucket {
+	buckets := []*HealthScoreBucket{}
+	for minScore := 0; minScore < 100; minScore += healthScoreBucketSize {
+		maxScore := minScore + healthScoreBucketSize - 1
+		if minScore+healthScoreBucketSize >= 100 {
+			maxScore = 100
+		}
+		buckets = append(buckets, &HealthScoreBucket{MinScore: minScore, MaxScore: maxScore})
+	}
+
+	for _, health := range statuses {
+		index := health.HealthScore / healthScoreBucketSize
+		if index >= len(buckets) {
+			index = len(buckets) - 1
+		}
+		buckets[index].Count++
+	}
+
+	return buckets
+}
+
+// healthPageSize is how many sensors loadSensorHealth loads per query
+const healthPageSize = 1000
+
+// loadSensorHealth computes the health status of all active sensors,
+// loading them a page at a time so totals and summaries cover every sensor
+func (s *service) loadSensorHealth(ctx context.Context) ([]*SensorHealthStatus, error) {
+	active := true
+	filter := &SensorFilter{IsActive: &active, SortDesc: true}
+
+	healthStatuses := []*SensorHealthStatus{}
+	for offset := 0; ; offset += healthPageSize {
+		sensors, total, err := s.repo.ListSensors(ctx, filter, healthPageSize, offset)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get sensors for health check: %w", err)
+		}
+
+		for _, sensor := range sensors {
+			healthStatuses = append(healthStatuses, s.calculateSensorHealth(sensor))
+		}
+
+		if len(sensors) < healthPageSize || offset+len(sensors) >= total {
+			break
+		}
 	}
 
 	return healthStatuses, nil
 }
 
 // GetLocationSummary returns summary data for a location
-func (s *service) GetLocationSummary(locationID int) (*LocationSummary, error) {
+func (s *service) GetLocationSummary(ctx context.Context, locationID int) (*LocationSummary, error) {
 	// Get location
-	location, err := s.repo.GetLocationByID(locationID)
+	location, err := s.repo.GetLocationByID(ctx, locationID)
 	if err != nil {
 		return nil, fmt.Errorf("location not found: %w", err)
 	}
 
 	// Get sensors in this location
-	sensors, err := s.repo.ListSensorsByLocation(locationID)
+	sensors, err := s.repo.ListSensorsByLocation(ctx, locationID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get sensors for location: %w", err)
 	}
 
-	summary := &LocationSummary{
+	return &LocationSummary{
 		Location:       location,
+		SensorsSummary: s.summarizeSensors(ctx, sensors),
+	}, nil
+}
+
+// summarizeSensors counts the active and online sensors and loads their
+// latest readings
+func (s *service) summarizeSensors(ctx context.Context, sensors []*Sensor) SensorsSummary {
+	summary := SensorsSummary{
 		SensorCount:    len(sensors),
 		Sensors:        sensors,
 		LatestReadings: []*SensorReading{},
 	}
 
-	onlineThreshold := 30 // 30 minutes
-
 	// Process sensors
 	for _, sensor := range sensors {
 		if sensor.IsActive {
 			summary.ActiveSensors++
 		}
 
-		if sensor.IsOnline(onlineThreshold) {
+		if sensor.IsOnline(s.onlineThreshold) {
 			summary.OnlineSensors++
 		}
 
 		// Get latest reading for each sensor
-		if latestReading, err := s.repo.GetLatestReading(sensor.ID); err == nil && latestReading != nil {
+		if latestReading, err := s.repo.GetLatestReading(ctx, sensor.ID); err == nil && latestReading != nil {
 			summary.LatestReadings = append(summary.LatestReadings, latestReading)
 		}
 	}
 
-	return summary, nil
+	return summary
+}
+
+// CreateSensorGroup creates a new sensor group
+func (s *service) CreateSensorGroup(ctx context.Context, req *CreateSensorGroupRequest, createdBy int) (*SensorGroup, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	group := &SensorGroup{
+		Name:        req.Name,
+		Description: req.Description,
+		CreatedBy:   &createdBy,
+	}
+
+	if err := s.repo.CreateSensorGroup(ctx, group); err != nil {
+		return nil, err
+	}
+
+	return group, nil
+}
+
+// GetSensorGroup retrieves sensor group by ID
+func (s *service) GetSensorGroup(ctx context.Context, id int) (*SensorGroup, error) {
+	return s.repo.GetSensorGroupByID(ctx, id)
+}
+
+// ListSensorGroups returns all sensor groups
+func (s *service) ListSensorGroups(ctx context.Context) ([]*SensorGroup, error) {
+	return s.repo.ListSensorGroups(ctx)
+}
+
+// UpdateSensorGroup updates sensor group information
+func (s *service) UpdateSensorGroup(ctx context.Context, id int, req *UpdateSensorGroupRequest) (*SensorGroup, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	return s.repo.UpdateSensorGroup(ctx, id, req)
+}
+
+// DeleteSensorGroup deletes a sensor group without touching its sensors
+func (s *service) DeleteSensorGroup(ctx context.Context, id int) error {
+	return s.repo.DeleteSensorGroup(ctx, id)
+}
+
+// UpdateGroupMembers adds sensors to and removes sensors from a group.
+// Added sensors must be visible to the caller and belong to the group's
+// organization.
+func (s *service) UpdateGroupMembers(ctx context.Context, groupID int, req *UpdateGroupMembersRequest) (*SensorGroup, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	group, err := s.repo.GetSensorGroupByID(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sensorID := range req.Add {
+		sensor, err := s.repo.GetSensorByID(ctx, sensorID)
+		if err != nil {
+			return nil, err
+		}
+		if sensor.OrganizationID != group.OrganizationID {
+			return nil, ErrSensorNotFound
+		}
+	}
+
+	if err := s.repo.UpdateGroupMembers(ctx, groupID, req.Add, req.Remove); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetSensorGroupByID(ctx, groupID)
+}
+
+// UpdateCalibration changes the offset and scale applied to the sensor's
+// incoming values; stored readings keep their values
+func (s *service) UpdateCalibration(ctx context.Context, sensorID int, req *UpdateCalibrationRequest, changedBy int) (*SensorCalibration, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Check if sensor exists and is visible to the caller
+	if _, err := s.repo.GetSensorByID(ctx, sensorID); err != nil {
+		return nil, err
+	}
+
+	// A visible sensor the update cannot reach lacks write access
+	calibration, err := s.repo.UpdateCalibration(ctx, sensorID, req, changedBy)
+	if errors.Is(err, ErrSensorNotFound) {
+		return nil, ErrSensorAccessDenied
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return calibration, nil
+}
+
+// SetMaintenance puts a sensor in maintenance until req.Until, or ends its
+// maintenance when Until is not set
+func (s *service) SetMaintenance(ctx context.Context, sensorID int, req *SetMaintenanceRequest, setBy int) (*Sensor, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Check if sensor exists and is visible to the caller
+	if _, err := s.repo.GetSensorByID(ctx, sensorID); err != nil {
+		return nil, err
+	}
+
+	// A visible sensor the update cannot reach lacks write access
+	if _, err := s.repo.SetMaintenance(ctx, sensorID, req, setBy); err != nil {
+		if errors.Is(err, ErrSensorNotFound) {
+			return nil, ErrSensorAccessDenied
+		}
+		return nil, err
+	}
+	s.cache.invalidate()
+
+	return s.repo.GetSensorByID(ctx, sensorID)
+}
+
+// CreateSensorNote leaves a note on a sensor
+func (s *service) CreateSensorNote(ctx context.Context, sensorID int, req *CreateSensorNoteRequest, authorID int) (*SensorNote, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Check if sensor exists and is visible to the caller
+	if _, err := s.repo.GetSensorByID(ctx, sensorID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.CreateSensorNote(ctx, sensorID, req.Body, authorID)
+}
+
+// ListSensorNotes returns the notes of a sensor, newest first
+func (s *service) ListSensorNotes(ctx context.Context, sensorID, page, perPage int) ([]*SensorNote, int, error) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > 100 {
+		perPage = 20
+	}
+
+	// Check if sensor exists and is visible to the caller
+	if _, err := s.repo.GetSensorByID(ctx, sensorID); err != nil {
+		return nil, 0, err
+	}
+
+	return s.repo.ListSensorNotes(ctx, sensorID, perPage, (page-1)*perPage)
+}
+
+// DeleteSensorNote deletes a note of a sensor; only its author and admins
+// can delete it
+func (s *service) DeleteSensorNote(ctx context.Context, sensorID int, noteID int64, userID int, isAdmin bool) error {
+	// Check if sensor exists and is visible to the caller
+	if _, err := s.repo.GetSensorByID(ctx, sensorID); err != nil {
+		return err
+	}
+
+	note, err := s.repo.GetSensorNote(ctx, sensorID, noteID)
+	if err != nil {
+		return err
+	}
+
+	if !isAdmin && (note.AuthorID == nil || *note.AuthorID != userID) {
+		return ErrNoteNotAuthor
+	}
+
+	return s.repo.DeleteSensorNote(ctx, noteID)
+}
+
+// ListCalibrations returns the calibration history of a sensor
+func (s *service) ListCalibrations(ctx context.Context, sensorID int) ([]*SensorCalibration, error) {
+	// Check if sensor exists and is visible to the caller
+	if _, err := s.repo.GetSensorByID(ctx, sensorID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.ListCalibrations(ctx, sensorID)
+}
+
+// SetCommandPublisher sets how commands reach devices. The MQTT broker
+// needs the service to be created first, so it is set afterwards; services
+// scoped before the call do not see it.
+func (s *service) SetCommandPublisher(publisher CommandPublisher) {
+	s.commands = publisher
+}
+
+// SendCommand publishes a command to the sensor's device and records it
+func (s *service) SendCommand(ctx context.Context, sensorID int, req *SendCommandRequest, sentBy int) (*SensorCommand, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Check if sensor exists and is visible to the caller
+	sensor, err := s.repo.GetSensorByID(ctx, sensorID)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.commands == nil || !s.commands.GetConnectionStatus() {
+		return nil, ErrCommandsUnavailable
+	}
+
+	commandUUID, err := newCommandUUID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate command UUID: %w", err)
+	}
+
+	// A visible sensor the insert cannot reach lacks write access
+	command, err := s.repo.CreateSensorCommand(ctx, &SensorCommand{
+		CommandUUID: commandUUID,
+		SensorID:    sensorID,
+		CommandType: req.CommandType,
+		Payload:     req.Payload,
+		SentBy:      &sentBy,
+	})
+	if errors.Is(err, ErrSensorNotFound) {
+		return nil, ErrSensorAccessDenied
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.commands.PublishCommand(sensor.DeviceID, command); err != nil {
+		if statusErr := s.repo.UpdateCommandStatus(ctx, command, CommandStatusFailed); statusErr != nil {
+			log.Printf("Failed to mark command %d as failed: %v", command.ID, statusErr)
+		}
+		return nil, err
+	}
+
+	if err := s.repo.UpdateCommandStatus(ctx, command, CommandStatusSent); err != nil {
+		return nil, err
+	}
+
+	return command, nil
+}
+
+// AcknowledgeCommand records a device's reply to one of its commands
+func (s *service) AcknowledgeCommand(ctx context.Context, deviceID string, ack *CommandAck) (*SensorCommand, error) {
+	if err := ack.Validate(); err != nil {
+		return nil, err
+	}
+
+	return s.repo.AcknowledgeCommand(ctx, deviceID, ack)
+}
+
+// ListSensorCommands returns the commands sent to a sensor, newest first
+func (s *service) ListSensorCommands(ctx context.Context, sensorID, page, perPage int) ([]*SensorCommand, int, error) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > 100 {
+		perPage = 20
+	}
+
+	// Check if sensor exists and is visible to the caller
+	if _, err := s.repo.GetSensorByID(ctx, sensorID); err != nil {
+		return nil, 0, err
+	}
+
+	return s.repo.ListSensorCommands(ctx, sensorID, perPage, (page-1)*perPage)
+}
+
+// TimeOutCommands marks the commands sent longer than timeout ago without
+// an acknowledgement, and those still pending after timeout, as timed out
+func (s *service) TimeOutCommands(ctx context.Context, timeout time.Duration) (int64, error) {
+	return s.repo.TimeOutCommands(ctx, time.Now().Add(-timeout))
+}
+
+// GetGroupSummary returns summary data for a sensor group
+func (s *service) GetGroupSummary(ctx context.Context, groupID int) (*GroupSummary, error) {
+	group, err := s.repo.GetSensorGroupByID(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	sensors, err := s.repo.ListSensorsByGroup(ctx, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sensors for group: %w", err)
+	}
+
+	return &GroupSummary{
+		Group:          group,
+		SensorsSummary: s.summarizeSensors(ctx, sensors),
+	}, nil
+}
+
+// CreateSensorAccess grants a user or a role access to a sensor or location
+func (s *service) CreateSensorAccess(ctx context.Context, req *CreateSensorAccessRequest, grantedBy int) (*SensorAccess, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	// The sensor or location must be in the caller's organization
+	if req.SensorID != nil {
+		if _, err := s.repo.GetSensorByID(ctx, *req.SensorID); err != nil {
+			return nil, err
+		}
+	}
+	if req.LocationID != nil {
+		if _, err := s.repo.GetLocationByID(ctx, *req.LocationID); err != nil {
+			return nil, err
+		}
+	}
+
+	access := &SensorAccess{
+		SensorID:    req.SensorID,
+		LocationID:  req.LocationID,
+		UserID:      req.UserID,
+		RoleID:      req.RoleID,
+		AccessLevel: req.AccessLevel,
+		GrantedBy:   &grantedBy,
+	}
+
+	if err := s.repo.CreateSensorAccess(ctx, access); err != nil {
+		return nil, err
+	}
+
+	return access, nil
+}
+
+// ListSensorAccess returns the access grants on a sensor or location
+func (s *service) ListSensorAccess(ctx context.Context, sensorID, locationID *int) ([]*SensorAccess, error) {
+	return s.repo.ListSensorAccess(ctx, sensorID, locationID)
+}
+
+// DeleteSensorAccess revokes a sensor access grant
+func (s *service) DeleteSensorAccess(ctx context.Context, id int) error {
+	return s.repo.DeleteSensorAccess(ctx, id)
 }
 
 // calculateSensorHealth calculates health score and issues for a sensor
 func (s *service) calculateSensorHealth(sensor *Sensor) *SensorHealthStatus {
 	status := &SensorHealthStatus{
 		Sensor:        sensor,
-		IsOnline:      sensor.IsOnline(30), // 30 minutes threshold
+		IsOnline:      sensor.IsOnline(s.onlineThreshold),
+		InMaintenance: sensor.InMaintenance(time.Now()),
 		BatteryStatus: sensor.GetBatteryStatus(),
 		HealthScore:   100,
 		Issues:        []string{},
 	}
 
-	// Get latest reading
-	if latestReading, err := s.repo.GetLatestReading(sensor.ID); err == nil && latestReading != nil {
-		status.LastReading = latestReading
-	}
+	// The latest reading is loaded along with the sensors
+	status.LastReading = sensor.LatestReading
 
-	// Check various health factors
+	// Check various health factors; checks without a penalty are disabled
+	policy := s.health
 
-	// 1. Online status
-	if !status.IsOnline {
-		status.HealthScore -= 30
+	// 1. Online status; sensors in maintenance are expected to be silent
+	if !status.IsOnline && !status.InMaintenance && policy.OfflinePenalty > 0 {
+		status.HealthScore -= policy.OfflinePenalty
 		status.Issues = append(status.Issues, "Sensor offline")
 	}
 
 	// 2. Battery level
 	if sensor.BatteryLevel != nil {
 		switch {
-		case *sensor.BatteryLevel < 20:
-			status.HealthScore -= 25
+		case *sensor.BatteryLevel < policy.CriticalBatteryLevel && policy.CriticalBatteryPenalty > 0:
+			status.HealthScore -= policy.CriticalBatteryPenalty
 			status.Issues = append(status.Issues, "Critical battery level")
-		case *sensor.BatteryLevel < 50:
-			status.HealthScore -= 10
+		case *sensor.BatteryLevel < policy.LowBatteryLevel && policy.LowBatteryPenalty > 0:
+			status.HealthScore -= policy.LowBatteryPenalty
 			status.Issues = append(status.Issues, "Low battery level")
 		}
 	}
 
 	// 3. Reading quality
-	if status.LastReading != nil {
-		if status.LastReading.Quality < 80 {
-			status.HealthScore -= 15
+	if status.LastReading != nil && policy.PoorQualityPenalty > 0 {
+		if status.LastReading.Quality < policy.PoorQualityLevel {
+			status.HealthScore -= policy.PoorQualityPenalty
 			status.Issues = append(status.Issues, "Poor reading quality")
 		}
 	}
 
-	// 4. No recent readings
-	if sensor.LastReadingAt == nil {
-		status.HealthScore -= 20
-		status.Issues = append(status.Issues, "No readings recorded")
-	} else {
-		// Check if reading is too old
-		lastReadingAge := time.Since(*sensor.LastReadingAt)
-		if lastReadingAge > 2*time.Hour {
-			status.HealthScore -= 15
-			status.Issues = append(status.Issues, "Readings too old")
+	// 4. No recent readings, unless the sensor is in maintenance
+	if !status.InMaintenance {
+		if sensor.LastReadingAt == nil {
+			if policy.NoReadingsPenalty > 0 {
+				status.HealthScore -= policy.NoReadingsPenalty
+				status.Issues = append(status.Issues, "No readings recorded")
+			}
+		} else if policy.StaleReadingPenalty > 0 {
+			// Check if reading is too old
+			lastReadingAge := time.Since(*sensor.LastReadingAt)
+			if lastReadingAge > policy.StaleReadingAge {
+				status.HealthScore -= policy.StaleReadingPenalty
+				status.Issues = append(status.Issues, "Readings too old")
+			}
 		}
 	}
 
@@ -0,0 +1,71 @@
+package sensor
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// statusCheckInterval is how often the status worker looks for sensors
+// going offline or coming back online
+const statusCheckInterval = time.Minute
+
+// StatusWorker periodically records sensors whose last reading is older
+// than the online threshold as offline, and offline sensors reporting
+// again as online
+type StatusWorker struct {
+	service Service
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewStatusWorker creates a sensor status worker
+func NewStatusWorker(service Service) *StatusWorker {
+	return &StatusWorker{
+		service: service,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// Start runs a check right away and then once per check interval
+func (w *StatusWorker) Start() {
+	go func() {
+		defer close(w.done)
+
+		ticker := time.NewTicker(statusCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			w.run()
+
+			select {
+			case <-ticker.C:
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the worker and waits for a running check to finish
+func (w *StatusWorker) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+	<-w.done
+}
+
+func (w *StatusWorker) run() {
+	events, err := w.service.DetectStatusChanges(context.Background())
+	if err != nil {
+		log.Printf("Warning: sensor status check failed: %v", err)
+		return
+	}
+	if len(events) > 0 {
+		log.Printf("Sensor status check recorded %d status changes", len(events))
+	}
+}
@@ -0,0 +1,115 @@
+package sensor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// UnitConversion converts values from the unit a sensor type stores to
+// another unit as value*Scale + Offset
+type UnitConversion struct {
+	Name   string  `json:"name"`
+	Unit   string  `json:"unit"`
+	Scale  float64 `json:"-"`
+	Offset float64 `json:"-"`
+}
+
+// unitConversions lists, per stored sensor type unit, the units values can
+// be converted to on read, keyed by the name used in the unit parameter
+var unitConversions = map[string][]UnitConversion{
+	"°C": {
+		{Name: "celsius", Unit: "°C", Scale: 1},
+		{Name: "fahrenheit", Unit: "°F", Scale: 1.8, Offset: 32},
+		{Name: "kelvin", Unit: "K", Scale: 1, Offset: 273.15},
+	},
+	"hPa": {
+		{Name: "hpa", Unit: "hPa", Scale: 1},
+		{Name: "kpa", Unit: "kPa", Scale: 0.1},
+		{Name: "inhg", Unit: "inHg", Scale: 0.029529983071445},
+		{Name: "mmhg", Unit: "mmHg", Scale: 0.750061683},
+	},
+}
+
+// UnsupportedUnitError is returned when a sensor type has no conversion to
+// the requested unit
+type UnsupportedUnitError struct {
+	Unit      string
+	Supported []string
+}
+
+func (e *UnsupportedUnitError) Error() string {
+	if len(e.Supported) == 0 {
+		return fmt.Sprintf("unit %q is not supported: values of this sensor type cannot be converted", e.Unit)
+	}
+	return fmt.Sprintf("unit %q is not supported, use one of %s", e.Unit, strings.Join(e.Supported, ", "))
+}
+
+// FindUnitConversion returns the conversion of the sensor type's values to
+// the named unit
+func FindUnitConversion(sensorType *SensorType, name string) (*UnitConversion, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	supported := []string{}
+	if sensorType != nil {
+		for _, conversion := range unitConversions[sensorType.Unit] {
+			if conversion.Name == name {
+				conversion := conversion
+				return &conversion, nil
+			}
+			supported = append(supported, conversion.Name)
+		}
+	}
+	sort.Strings(supported)
+
+	return nil, &UnsupportedUnitError{Unit: name, Supported: supported}
+}
+
+// Convert converts a value to the conversion's unit
+func (c *UnitConversion) Convert(value float64) float64 {
+	return value*c.Scale + c.Offset
+}
+
+// convertPtr converts an optional value
+func (c *UnitConversion) convertPtr(value *float64) *float64 {
+	if value == nil {
+		return nil
+	}
+	converted := c.Convert(*value)
+	return &converted
+}
+
+// ApplyToReading converts the values of a reading and sets its unit
+func (c *UnitConversion) ApplyToReading(reading *SensorReading) {
+	reading.Value = c.Convert(reading.Value)
+	reading.RawValue = c.Convert(reading.RawValue)
+	reading.Smoothed = c.convertPtr(reading.Smoothed)
+	reading.RawSmoothed = c.convertPtr(reading.RawSmoothed)
+	reading.Unit = c.Unit
+}
+
+// ApplyToStatistics converts statistics and their comparison with the
+// previous period and sets their unit. The standard deviation only scales,
+// and deltas are computed again from the converted values.
+func (c *UnitConversion) ApplyToStatistics(stats *SensorStatistics) {
+	stats.MinValue = c.convertPtr(stats.MinValue)
+	stats.MaxValue = c.convertPtr(stats.MaxValue)
+	stats.AvgValue = c.convertPtr(stats.AvgValue)
+	stats.Median = c.convertPtr(stats.Median)
+	stats.P95 = c.convertPtr(stats.P95)
+	stats.LastValue = c.convertPtr(stats.LastValue)
+	if stats.StdDev != nil {
+		stddev := *stats.StdDev * c.Scale
+		stats.StdDev = &stddev
+	}
+	stats.Unit = c.Unit
+
+	if stats.Comparison != nil {
+		previous := stats.Comparison
+		c.ApplyToStatistics(previous.Previous)
+
+		stats.Comparison = CompareStatistics(stats, previous.Previous)
+		stats.Comparison.PreviousStartTime = previous.PreviousStartTime
+		stats.Comparison.PreviousEndTime = previous.PreviousEndTime
+	}
+}
@@ -1,36 +1,98 @@
 package user
 
 import (
+	"context"
+	"time"
+
 	"user-management/shared/interfaces"
 )
 
 // AuthServiceAdapter adapts user.Service to interfaces.AuthService
 type AuthServiceAdapter struct {
 	userService Service
+	cache       *AuthCache
 }
 
-// NewAuthServiceAdapter creates a new auth service adapter
-func NewAuthServiceAdapter(userService Service) interfaces.AuthService {
+// NewAuthServiceAdapter creates a new auth service adapter. Lookups are
+// cached in cache when it is not nil; pass the same cache to the user
+// service so it can invalidate entries.
+func NewAuthServiceAdapter(userService Service, cache *AuthCache) interfaces.AuthService {
 	return &AuthServiceAdapter{
 		userService: userService,
+		cache:       cache,
 	}
 }
 
 // GetUserFromToken adapts the method to return interfaces.User
-func (a *AuthServiceAdapter) GetUserFromToken(tokenString string) (*interfaces.User, error) {
-	// Get user from user service
-	user, err := a.userService.GetUserFromToken(tokenString)
+func (a *AuthServiceAdapter) GetUserFromToken(ctx context.Context, tokenString string) (*interfaces.User, error) {
+	user, ok := a.cache.getUser(tokenString)
+	if !ok {
+		// Get user from user service
+		var err error
+		user, err = a.userService.GetUserFromToken(ctx, tokenString)
+		if err != nil {
+			return nil, err
+		}
+
+		if a.cache != nil {
+			a.cache.setUser(tokenString, user, a.tokenExpiry(tokenString))
+		}
+	}
+
+	return toInterfaceUser(user), nil
+}
+
+// GetUserFromAPIKey resolves an API key, restricting the user to the key's scopes
+func (a *AuthServiceAdapter) GetUserFromAPIKey(ctx context.Context, key string) (*interfaces.User, error) {
+	user, apiKey, err := a.userService.GetUserFromAPIKey(ctx, key)
 	if err != nil {
 		return nil, err
 	}
 
+	interfaceUser := toInterfaceUser(user)
+	if len(apiKey.Scopes) > 0 {
+		interfaceUser.KeyScopes = make([]interfaces.Permission, 0, len(apiKey.Scopes))
+		for _, scope := range apiKey.Scopes {
+			resource, action, ok := parseScope(scope)
+			if !ok {
+				continue
+			}
+			interfaceUser.KeyScopes = append(interfaceUser.KeyScopes, interfaces.Permission{
+				Resource: resource,
+				Action:   action,
+			})
+		}
+	}
+
+	return interfaceUser, nil
+}
+
+// HasPermission delegates to user service, caching the result
+func (a *AuthServiceAdapter) HasPermission(ctx context.Context, userID int, resource, action string) (bool, error) {
+	if allowed, ok := a.cache.getPermission(userID, resource, action); ok {
+		return allowed, nil
+	}
+
+	allowed, err := a.userService.HasPermission(ctx, userID, resource, action)
+	if err != nil {
+		return false, err
+	}
+
+	a.cache.setPermission(userID, resource, action, allowed)
+	return allowed, nil
+}
+
+// toInterfaceUser converts a user with roles to interfaces.User
+func toInterfaceUser(user *User) *interfaces.User {
 	// Convert to interfaces.User
 	interfaceUser := &interfaces.User{
-		ID:       user.ID,
-		Email:    user.Email,
-		Name:     user.Name,
-		IsActive: user.IsActive,
-		Roles:    make([]interfaces.Role, len(user.Roles)),
+		ID:             user.ID,
+		Email:          user.Email,
+		Name:           user.Name,
+		OrganizationID: user.OrganizationID,
+		IsActive:       user.IsActive,
+		Timezone:       user.Timezone,
+		Roles:          make([]interfaces.Role, len(user.Roles)),
 	}
 
 	// Convert roles
@@ -58,10 +120,20 @@ func (a *AuthServiceAdapter) GetUserFromToken(tokenString string) (*interfaces.U
 		interfaceUser.Roles[i] = interfaceRole
 	}
 
-	return interfaceUser, nil
+	return interfaceUser
 }
 
-// HasPermission delegates to user service
-func (a *AuthServiceAdapter) HasPermission(userID int, resource, action string) (bool, error) {
-	return a.userService.HasPermission(userID, resource, action)
+// tokenExpiry returns when an already validated token expires
+func (a *AuthServiceAdapter) tokenExpiry(tokenString string) time.Time {
+	token, err := a.userService.ValidateToken(tokenString)
+	if err != nil {
+		return time.Now()
+	}
+
+	claims, ok := token.Claims.(*JWTClaims)
+	if !ok || claims.ExpiresAt == nil {
+		return time.Time{}
+	}
+
+	return claims.ExpiresAt.Time
 }
@@ -0,0 +1,187 @@
+package user
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultAuthCacheTTL applies when the cache is created without a TTL
+const defaultAuthCacheTTL = 30 * time.Second
+
+// maxAuthCacheEntries bounds each cache map; expired entries are swept
+// when it is reached and the map is reset if that is not enough
+const maxAuthCacheEntries = 10000
+
+// AuthCache caches token lookups and permission checks made by the auth
+// middleware. The user service invalidates entries when a user's roles,
+// permissions or status change. A nil *AuthCache disables caching.
+type AuthCache struct {
+	ttl time.Duration
+
+	mu          sync.Mutex
+	users       map[string]cachedUser
+	permissions map[permissionKey]cachedPermission
+}
+
+type cachedUser struct {
+	user      *User
+	expiresAt time.Time
+}
+
+type permissionKey struct {
+	userID   int
+	resource string
+	action   string
+}
+
+type cachedPermission struct {
+	allowed   bool
+	expiresAt time.Time
+}
+
+// NewAuthCache creates an auth cache whose entries live for ttl
+func NewAuthCache(ttl time.Duration) *AuthCache {
+	if ttl <= 0 {
+		ttl = defaultAuthCacheTTL
+	}
+
+	return &AuthCache{
+		ttl:         ttl,
+		users:       make(map[string]cachedUser),
+		permissions: make(map[permissionKey]cachedPermission),
+	}
+}
+
+// getUser returns the cached user for an access token
+func (c *AuthCache) getUser(tokenString string) (*User, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	key := hashToken(tokenString)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.users[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(c.users, key)
+		return nil, false
+	}
+
+	return entry.user, true
+}
+
+// setUser caches the user for an access token, never beyond the token expiry
+func (c *AuthCache) setUser(tokenString string, user *User, tokenExpiresAt time.Time) {
+	if c == nil {
+		return
+	}
+
+	expiresAt := time.Now().Add(c.ttl)
+	if !tokenExpiresAt.IsZero() && tokenExpiresAt.Before(expiresAt) {
+		expiresAt = tokenExpiresAt
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.users) >= maxAuthCacheEntries {
+		now := time.Now()
+		for key, entry := range c.users {
+			if now.After(entry.expiresAt) {
+				delete(c.users, key)
+			}
+		}
+		if len(c.users) >= maxAuthCacheEntries {
+			c.users = make(map[string]cachedUser)
+		}
+	}
+
+	c.users[hashToken(tokenString)] = cachedUser{user: user, expiresAt: expiresAt}
+}
+
+// getPermission returns a cached permission check result
+func (c *AuthCache) getPermission(userID int, resource, action string) (allowed, ok bool) {
+	if c == nil {
+		return false, false
+	}
+
+	key := permissionKey{userID: userID, resource: resource, action: action}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.permissions[key]
+	if !ok {
+		return false, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(c.permissions, key)
+		return false, false
+	}
+
+	return entry.allowed, true
+}
+
+// setPermission caches a permission check result
+func (c *AuthCache) setPermission(userID int, resource, action string, allowed bool) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.permissions) >= maxAuthCacheEntries {
+		now := time.Now()
+		for key, entry := range c.permissions {
+			if now.After(entry.expiresAt) {
+				delete(c.permissions, key)
+			}
+		}
+		if len(c.permissions) >= maxAuthCacheEntries {
+			c.permissions = make(map[permissionKey]cachedPermission)
+		}
+	}
+
+	key := permissionKey{userID: userID, resource: resource, action: action}
+	c.permissions[key] = cachedPermission{allowed: allowed, expiresAt: time.Now().Add(c.ttl)}
+}
+
+// invalidateUser drops all cached entries for a user
+func (c *AuthCache) invalidateUser(userID int) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, entry := range c.users {
+		if entry.user.ID == userID {
+			delete(c.users, key)
+		}
+	}
+	for key := range c.permissions {
+		if key.userID == userID {
+			delete(c.permissions, key)
+		}
+	}
+}
+
+// invalidateAll drops every cached entry, used when a role changes
+func (c *AuthCache) invalidateAll() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.users = make(map[string]cachedUser)
+	c.permissions = make(map[permissionKey]cachedPermission)
+}
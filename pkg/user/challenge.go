@@ -0,0 +1,88 @@
+package user
+
+import (
+	"sync"
+	"time"
+)
+
+// maxChallengeAttempts is how many invalid codes a two-factor challenge
+// accepts before it is invalidated
+const maxChallengeAttempts = 5
+
+// maxTrackedChallenges bounds the attempt map; expired challenges are swept
+// when it is reached
+const maxTrackedChallenges = 10000
+
+// challengeAttempts tracks two-factor challenge tokens until they expire,
+// so a challenge cannot be used to guess codes or be completed twice
+type challengeAttempts struct {
+	mu         sync.Mutex
+	challenges map[string]challengeState
+}
+
+type challengeState struct {
+	failures  int
+	completed bool
+	expiresAt time.Time
+}
+
+func newChallengeAttempts() *challengeAttempts {
+	return &challengeAttempts{challenges: make(map[string]challengeState)}
+}
+
+// check returns ErrInvalidToken for completed challenges and
+// ErrTooManyTwoFactorCodes for challenges that used up their attempts
+func (c *challengeAttempts) check(id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state := c.challenges[id]
+	switch {
+	case state.completed:
+		return ErrInvalidToken
+	case state.failures >= maxChallengeAttempts:
+		return ErrTooManyTwoFactorCodes
+	}
+	return nil
+}
+
+// fail records an invalid code for the challenge, which is tracked until
+// expiresAt, and reports whether the challenge is now exhausted
+func (c *challengeAttempts) fail(id string, expiresAt time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state := c.get(id, expiresAt)
+	state.failures++
+	c.challenges[id] = state
+	return state.failures >= maxChallengeAttempts
+}
+
+// complete marks the challenge as used, which is tracked until expiresAt
+func (c *challengeAttempts) complete(id string, expiresAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state := c.get(id, expiresAt)
+	state.completed = true
+	c.challenges[id] = state
+}
+
+// get returns the state of a challenge, sweeping expired challenges before
+// a new one is tracked at the cap; callers hold the lock
+func (c *challengeAttempts) get(id string, expiresAt time.Time) challengeState {
+	state, ok := c.challenges[id]
+	if ok {
+		return state
+	}
+
+	if len(c.challenges) >= maxTrackedChallenges {
+		now := time.Now()
+		for key, tracked := range c.challenges {
+			if now.After(tracked.expiresAt) {
+				delete(c.challenges, key)
+			}
+		}
+	}
+	return challengeState{expiresAt: expiresAt}
+}
@@ -2,9 +2,13 @@ package user
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
+	"user-management/pkg/audit"
+	"user-management/shared/interfaces"
 	"user-management/shared/middleware"
 	"user-management/shared/response"
 )
@@ -13,43 +17,91 @@ import (
 type Handler struct {
 	service Service
 	authMW  *middleware.AuthMiddleware
+	audit   audit.Recorder
 }
 
 // NewHandler creates a new user handler
-func NewHandler(service Service) *Handler {
-	authService := NewAuthServiceAdapter(service)
+func NewHandler(service Service, authMW *middleware.AuthMiddleware, recorder audit.Recorder) *Handler {
 	return &Handler{
 		service: service,
-		authMW:  middleware.NewAuthMiddleware(authService),
+		authMW:  authMW,
+		audit:   recorder,
 	}
 }
 
 // RegisterRoutes registers all user routes
-func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
+func (h *Handler) RegisterRoutes(mux interfaces.Router) {
 	// Public routes (no authentication required)
 	mux.HandleFunc("POST /api/auth/register", h.Register)
+	mux.HandleFunc("POST /api/auth/register/invite", h.AcceptInvitation)
+	mux.HandleFunc("POST /api/auth/verify-email", h.VerifyEmail)
+	mux.HandleFunc("POST /api/auth/verify-email/resend", h.ResendEmailVerification)
 	mux.HandleFunc("POST /api/auth/login", h.Login)
+	mux.HandleFunc("POST /api/auth/refresh", h.RefreshToken)
+	mux.HandleFunc("POST /api/auth/2fa/login", h.TwoFactorLogin)
+	mux.HandleFunc("POST /api/auth/password/forced-change", h.ForcedPasswordChange)
+	mux.HandleFunc("GET /.well-known/jwks.json", h.JWKS)
+	mux.HandleFunc("POST /api/auth/password-reset/request", h.RequestPasswordReset)
+	mux.HandleFunc("POST /api/auth/password-reset/confirm", h.ConfirmPasswordReset)
 
 	// Protected routes (authentication required)
 	mux.Handle("GET /api/auth/profile", h.authMW.Authenticate(http.HandlerFunc(h.GetProfile)))
 	mux.Handle("PUT /api/auth/profile", h.authMW.Authenticate(http.HandlerFunc(h.UpdateProfile)))
+	mux.Handle("PUT /api/auth/password", h.authMW.Authenticate(http.HandlerFunc(h.ChangePassword)))
+	mux.Handle("GET /api/auth/sessions", h.authMW.Authenticate(http.HandlerFunc(h.ListSessions)))
+	mux.Handle("DELETE /api/auth/sessions/{id}", h.authMW.Authenticate(http.HandlerFunc(h.RevokeSession)))
+	mux.Handle("POST /api/auth/api-keys", h.authMW.Authenticate(http.HandlerFunc(h.CreateAPIKey)))
+	mux.Handle("GET /api/auth/api-keys", h.authMW.Authenticate(http.HandlerFunc(h.ListAPIKeys)))
+	mux.Handle("DELETE /api/auth/api-keys/{id}", h.authMW.Authenticate(http.HandlerFunc(h.RevokeAPIKey)))
+	mux.Handle("POST /api/auth/2fa/setup", h.authMW.Authenticate(http.HandlerFunc(h.SetupTwoFactor)))
+	mux.Handle("POST /api/auth/2fa/verify", h.authMW.Authenticate(http.HandlerFunc(h.VerifyTwoFactor)))
 
 	// Admin routes (admin role required)
-	mux.Handle("GET /api/users", h.authMW.RequireAdmin(http.HandlerFunc(h.ListUsers)))
-	mux.Handle("GET /api/users/{id}", h.authMW.RequireAdmin(http.HandlerFunc(h.GetUser)))
-	mux.Handle("PUT /api/users/{id}", h.authMW.RequireAdmin(http.HandlerFunc(h.UpdateUser)))
-	mux.Handle("DELETE /api/users/{id}", h.authMW.RequireAdmin(http.HandlerFunc(h.DeactivateUser)))
-
-	// Role management (admin only)
-	mux.Handle("GET /api/roles", h.authMW.RequireAdmin(http.HandlerFunc(h.ListRoles)))
-	mux.Handle("POST /api/users/roles", h.authMW.RequireAdmin(http.HandlerFunc(h.AssignRole)))
-	mux.Handle("DELETE /api/users/roles", h.authMW.RequireAdmin(http.HandlerFunc(h.RemoveRole)))
-	mux.Handle("GET /api/users/{id}/roles", h.authMW.RequireAdmin(http.HandlerFunc(h.GetUserRoles)))
+	mux.Handle("GET /api/users", h.authMW.Authenticate(h.authMW.RequireAdmin(http.HandlerFunc(h.ListUsers))))
+	mux.Handle("POST /api/users/invitations", h.authMW.Authenticate(h.authMW.RequireAdmin(http.HandlerFunc(h.CreateInvitation))))
+	mux.Handle("GET /api/users/stats", h.authMW.Authenticate(h.authMW.RequireAdmin(http.HandlerFunc(h.GetUserStats))))
+	mux.Handle("GET /api/users/by-email", h.authMW.Authenticate(h.authMW.RequireAdmin(http.HandlerFunc(h.GetUserByEmail))))
+	mux.Handle("GET /api/users/{id}", h.authMW.Authenticate(h.authMW.RequireAdmin(http.HandlerFunc(h.GetUser))))
+	mux.Handle("PUT /api/users/{id}", h.authMW.Authenticate(h.authMW.RequireAdmin(http.HandlerFunc(h.UpdateUser))))
+	mux.Handle("DELETE /api/users/{id}", h.authMW.Authenticate(h.authMW.RequireAdmin(http.HandlerFunc(h.DeactivateUser))))
+	mux.Handle("POST /api/users/{id}/activate", h.authMW.Authenticate(h.authMW.RequireAdmin(http.HandlerFunc(h.ActivateUser))))
+	mux.Handle("POST /api/users/{id}/erase", h.authMW.Authenticate(h.authMW.RequireAdmin(http.HandlerFunc(h.EraseUser))))
+	mux.Handle("DELETE /api/users/{id}/2fa", h.authMW.Authenticate(h.authMW.RequireAdmin(http.HandlerFunc(h.DisableTwoFactor))))
+
+	// Role management (admin only; roles are global, so only super admins change them)
+	mux.Handle("GET /api/roles", h.authMW.Authenticate(h.authMW.RequireAdmin(http.HandlerFunc(h.ListRoles))))
+	mux.Handle("POST /api/roles", h.authMW.Authenticate(h.authMW.RequireAdmin(http.HandlerFunc(h.CreateRole))))
+	mux.Handle("PUT /api/roles/{id}", h.authMW.Authenticate(h.authMW.RequireAdmin(http.HandlerFunc(h.UpdateRole))))
+	mux.Handle("DELETE /api/roles/{id}", h.authMW.Authenticate(h.authMW.RequireAdmin(http.HandlerFunc(h.DeleteRole))))
+	mux.Handle("GET /api/permissions", h.authMW.Authenticate(h.authMW.RequireAdmin(http.HandlerFunc(h.ListPermissions))))
+	mux.Handle("POST /api/roles/{id}/permissions", h.authMW.Authenticate(h.authMW.RequireAdmin(http.HandlerFunc(h.AddRolePermission))))
+	mux.Handle("DELETE /api/roles/{id}/permissions/{permission_id}", h.authMW.Authenticate(h.authMW.RequireAdmin(http.HandlerFunc(h.RemoveRolePermission))))
+	mux.Handle("POST /api/roles/{id}/users", h.authMW.Authenticate(h.authMW.RequireAdmin(http.HandlerFunc(h.BulkAssignRole))))
+	mux.Handle("POST /api/users/roles", h.authMW.Authenticate(h.authMW.RequireAdmin(http.HandlerFunc(h.AssignRole))))
+	mux.Handle("DELETE /api/users/roles", h.authMW.Authenticate(h.authMW.RequireAdmin(http.HandlerFunc(h.RemoveRole))))
+	mux.Handle("GET /api/users/{id}/roles", h.authMW.Authenticate(h.authMW.RequireAdmin(http.HandlerFunc(h.GetUserRoles))))
+	mux.Handle("GET /api/users/{id}/logins", h.authMW.Authenticate(h.authMW.RequireAdmin(http.HandlerFunc(h.GetLoginHistory))))
+
+	// Organization management (super admin only)
+	mux.Handle("GET /api/organizations", h.authMW.Authenticate(h.authMW.RequireSuperAdmin(http.HandlerFunc(h.ListOrganizations))))
+	mux.Handle("POST /api/organizations", h.authMW.Authenticate(h.authMW.RequireSuperAdmin(http.HandlerFunc(h.CreateOrganization))))
+	mux.Handle("PUT /api/users/{id}/organization", h.authMW.Authenticate(h.authMW.RequireSuperAdmin(http.HandlerFunc(h.MoveUserToOrganization))))
 
 	// Permission checking (authenticated users)
 	mux.Handle("GET /api/auth/permissions", h.authMW.Authenticate(http.HandlerFunc(h.GetMyPermissions)))
 }
 
+// scopedService returns the service limited to the organization of the
+// authenticated user; super admins are not limited
+func (h *Handler) scopedService(r *http.Request) Service {
+	scope, ok := middleware.GetScopeFromContext(r.Context())
+	if !ok {
+		// Fail closed: a restricted scope without organization matches nothing
+		scope = interfaces.Scope{Restricted: true}
+	}
+	return h.service.WithScope(scope)
+}
+
 // Register handles user registration
 func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 	var req CreateUserRequest
@@ -58,248 +110,1175 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := h.service.Register(&req)
-	if err != nil {
-		switch err {
-		case ErrInvalidEmail, ErrPasswordTooWeak, ErrNameRequired:
-			response.BadRequest(w, "Validation failed", err)
-		case ErrEmailExists:
-			response.Conflict(w, "Email already exists", err)
+	user, err := h.service.Register(r.Context(), &req)
+	if err != nil {
+		if writePasswordPolicyError(w, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, ErrRegistrationClosed):
+			response.ErrorFor(w, http.StatusForbidden, err.Error(), err)
+		case errors.Is(err, ErrInvalidEmail), errors.Is(err, ErrPasswordMissing), errors.Is(err, ErrNameRequired):
+			response.BadRequest(w, "Validation failed", err)
+		case errors.Is(err, ErrEmailExists):
+			response.Conflict(w, "Email already exists", err)
+		default:
+			response.InternalServerError(w, "Failed to register user", err)
+		}
+		return
+	}
+
+	// Remove sensitive data
+	user.PasswordHash = ""
+
+	response.Created(w, "User registered successfully", user)
+}
+
+// AcceptInvitation handles registration with an invitation token
+func (h *Handler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	var req AcceptInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	user, err := h.service.AcceptInvitation(r.Context(), &req)
+	if err != nil {
+		if writePasswordPolicyError(w, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, ErrInvalidInvitation), errors.Is(err, ErrPasswordMissing), errors.Is(err, ErrNameRequired):
+			response.BadRequest(w, "Validation failed", err)
+		case errors.Is(err, ErrEmailExists):
+			response.Conflict(w, "Email already exists", err)
+		default:
+			response.InternalServerError(w, "Failed to register user", err)
+		}
+		return
+	}
+
+	// Remove sensitive data
+	user.PasswordHash = ""
+
+	response.Created(w, "User registered successfully", user)
+}
+
+// CreateInvitation invites a user into the caller's organization (admin only)
+func (h *Handler) CreateInvitation(w http.ResponseWriter, r *http.Request) {
+	currentUser, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		response.Unauthorized(w, "User not found in context")
+		return
+	}
+
+	var req CreateInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	inv, err := h.scopedService(r).CreateInvitation(r.Context(), &req, currentUser.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidEmail):
+			response.BadRequest(w, "Validation failed", err)
+		case errors.Is(err, ErrEmailExists):
+			response.Conflict(w, "Email already exists", err)
+		case errors.Is(err, ErrRoleNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Role not found", err)
+		case errors.Is(err, ErrSuperAdminOnly):
+			response.ErrorFor(w, http.StatusForbidden, err.Error(), err)
+		default:
+			response.InternalServerError(w, "Failed to create invitation", err)
+		}
+		return
+	}
+
+	h.audit.Record(r, audit.ActionUserInvite, audit.ResourceInvitation, strconv.Itoa(inv.ID),
+		map[string]interface{}{"email": inv.Email, "role_ids": inv.RoleIDs})
+
+	response.Created(w, "Invitation created successfully", inv)
+}
+
+// Login handles user authentication
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
+	var req LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	client := ClientInfo{
+		IPAddress: middleware.ClientIP(r),
+		UserAgent: r.UserAgent(),
+	}
+
+	loginResp, err := h.service.Login(r.Context(), &req, client)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidEmail):
+			response.BadRequest(w, "Invalid email format", err)
+		case errors.Is(err, ErrInvalidPassword), errors.Is(err, ErrUserNotFound):
+			response.ErrorFor(w, http.StatusUnauthorized, "Invalid email or password", err)
+		case errors.Is(err, ErrInactiveUser):
+			response.ErrorFor(w, http.StatusForbidden, "Account is inactive", err)
+		case errors.Is(err, ErrEmailNotVerified):
+			response.ErrorFor(w, http.StatusForbidden, "Email address has not been verified", err)
+		default:
+			response.InternalServerError(w, "Login failed", err)
+		}
+		return
+	}
+
+	if loginResp.TwoFactorRequired {
+		response.Success(w, "Two-factor authentication required", loginResp)
+		return
+	}
+
+	if loginResp.PasswordChangeRequired {
+		response.Success(w, "Password change required", loginResp)
+		return
+	}
+
+	// Remove sensitive data
+	loginResp.User.PasswordHash = ""
+
+	response.Success(w, "Login successful", loginResp)
+}
+
+// TwoFactorLogin completes a login for accounts with 2FA enabled
+func (h *Handler) TwoFactorLogin(w http.ResponseWriter, r *http.Request) {
+	var req TwoFactorLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	client := ClientInfo{
+		IPAddress: middleware.ClientIP(r),
+		UserAgent: r.UserAgent(),
+	}
+
+	loginResp, err := h.service.CompleteTwoFactorLogin(r.Context(), &req, client)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidToken):
+			response.ErrorFor(w, http.StatusUnauthorized, "Invalid or expired two-factor token", err)
+		case errors.Is(err, ErrInvalidTwoFactorCode):
+			response.ErrorFor(w, http.StatusUnauthorized, "Invalid two-factor code", err)
+		case errors.Is(err, ErrTooManyTwoFactorCodes):
+			response.ErrorFor(w, http.StatusUnauthorized, "Too many invalid two-factor codes, log in again", err)
+		case errors.Is(err, ErrInactiveUser):
+			response.ErrorFor(w, http.StatusForbidden, "Account is inactive", err)
+		default:
+			response.InternalServerError(w, "Login failed", err)
+		}
+		return
+	}
+
+	if loginResp.PasswordChangeRequired {
+		response.Success(w, "Password change required", loginResp)
+		return
+	}
+
+	// Remove sensitive data
+	loginResp.User.PasswordHash = ""
+
+	response.Success(w, "Login successful", loginResp)
+}
+
+// ForcedPasswordChange sets a new password with the token returned by a
+// login that requires a password change, then completes the login
+func (h *Handler) ForcedPasswordChange(w http.ResponseWriter, r *http.Request) {
+	var req ForcedPasswordChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	client := ClientInfo{
+		IPAddress: middleware.ClientIP(r),
+		UserAgent: r.UserAgent(),
+	}
+
+	loginResp, err := h.service.CompleteForcedPasswordChange(r.Context(), &req, client)
+	if err != nil {
+		if writePasswordPolicyError(w, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, ErrInvalidToken):
+			response.ErrorFor(w, http.StatusUnauthorized, "Invalid or expired password change token", err)
+		case errors.Is(err, ErrPasswordMissing), errors.Is(err, ErrPasswordReused):
+			response.BadRequest(w, "Validation failed", err)
+		case errors.Is(err, ErrInactiveUser):
+			response.ErrorFor(w, http.StatusForbidden, "Account is inactive", err)
+		default:
+			response.InternalServerError(w, "Failed to change password", err)
+		}
+		return
+	}
+
+	// Remove sensitive data
+	loginResp.User.PasswordHash = ""
+
+	response.Success(w, "Password changed successfully", loginResp)
+}
+
+// RefreshToken exchanges a refresh token for a new token pair
+func (h *Handler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req RefreshTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		response.BadRequest(w, "Validation failed", err)
+		return
+	}
+
+	client := ClientInfo{
+		IPAddress: middleware.ClientIP(r),
+		UserAgent: r.UserAgent(),
+	}
+
+	loginResp, err := h.service.RefreshTokens(r.Context(), &req, client)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidToken):
+			response.ErrorFor(w, http.StatusUnauthorized, "Invalid or expired refresh token", err)
+		case errors.Is(err, ErrInactiveUser):
+			response.ErrorFor(w, http.StatusForbidden, "Account is inactive", err)
+		case errors.Is(err, ErrPasswordChangeRequired):
+			response.ErrorFor(w, http.StatusForbidden, "Password change required; log in again", err)
+		default:
+			response.InternalServerError(w, "Failed to refresh token", err)
+		}
+		return
+	}
+
+	// Remove sensitive data
+	loginResp.User.PasswordHash = ""
+
+	response.Success(w, "Token refreshed successfully", loginResp)
+}
+
+// RequestPasswordReset starts the forgot-password flow
+func (h *Handler) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var req PasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	if err := h.service.RequestPasswordReset(r.Context(), &req); err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidEmail):
+			response.BadRequest(w, "Validation failed", err)
+		default:
+			response.InternalServerError(w, "Failed to request password reset", err)
+		}
+		return
+	}
+
+	response.Success(w, "If the email is registered, a password reset link has been sent", nil)
+}
+
+// ConfirmPasswordReset completes the forgot-password flow
+func (h *Handler) ConfirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var req PasswordResetConfirmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	if err := h.service.ConfirmPasswordReset(r.Context(), &req); err != nil {
+		if writePasswordPolicyError(w, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, ErrPasswordMissing), errors.Is(err, ErrInvalidReset):
+			response.BadRequest(w, "Validation failed", err)
+		case errors.Is(err, ErrUserNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "User not found", err)
+		default:
+			response.InternalServerError(w, "Failed to reset password", err)
+		}
+		return
+	}
+
+	response.Success(w, "Password reset successfully", nil)
+}
+
+// VerifyEmail confirms an email address with a verification token
+func (h *Handler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req VerifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	if err := h.service.VerifyEmail(r.Context(), &req); err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidVerification):
+			response.BadRequest(w, "Validation failed", err)
+		default:
+			response.InternalServerError(w, "Failed to verify email", err)
+		}
+		return
+	}
+
+	response.Success(w, "Email verified successfully", nil)
+}
+
+// ResendEmailVerification sends a new verification email
+func (h *Handler) ResendEmailVerification(w http.ResponseWriter, r *http.Request) {
+	var req ResendVerificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	if err := h.service.ResendEmailVerification(r.Context(), &req); err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidEmail):
+			response.BadRequest(w, "Validation failed", err)
+		default:
+			response.InternalServerError(w, "Failed to resend verification email", err)
+		}
+		return
+	}
+
+	response.Success(w, "If the email is registered and unverified, a verification email has been sent", nil)
+}
+
+// GetProfile returns current user profile
+func (h *Handler) GetProfile(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		response.Unauthorized(w, "User not found in context")
+		return
+	}
+
+	profile, err := h.service.GetProfile(r.Context(), user.ID)
+	if err != nil {
+		response.InternalServerError(w, "Failed to get profile", err)
+		return
+	}
+
+	// Remove sensitive data
+	profile.PasswordHash = ""
+
+	response.Success(w, "Profile retrieved successfully", profile)
+}
+
+// UpdateProfile updates current user profile
+func (h *Handler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		response.Unauthorized(w, "User not found in context")
+		return
+	}
+
+	var req UpdateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	// Users cannot lift a password change an admin forced on them
+	req.MustChangePassword = nil
+
+	updatedUser, err := h.service.UpdateProfile(r.Context(), user.ID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrNameRequired), errors.Is(err, ErrInvalidPhone), errors.Is(err, ErrInvalidTimezone),
+			errors.Is(err, ErrInvalidLocale), errors.Is(err, ErrInvalidAvatarURL):
+			response.BadRequest(w, "Validation failed", err)
+		case errors.Is(err, ErrUserNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "User not found", err)
+		default:
+			response.InternalServerError(w, "Failed to update profile", err)
+		}
+		return
+	}
+
+	// Remove sensitive data
+	updatedUser.PasswordHash = ""
+
+	response.Success(w, "Profile updated successfully", updatedUser)
+}
+
+// ChangePassword changes current user password
+func (h *Handler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		response.Unauthorized(w, "User not found in context")
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	if err := h.service.ChangePassword(r.Context(), user.ID, &req); err != nil {
+		if writePasswordPolicyError(w, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, ErrPasswordMissing):
+			response.BadRequest(w, "Validation failed", err)
+		case errors.Is(err, ErrInvalidPassword):
+			response.ErrorFor(w, http.StatusUnauthorized, "Current password is incorrect", err)
+		case errors.Is(err, ErrUserNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "User not found", err)
+		default:
+			response.InternalServerError(w, "Failed to change password", err)
+		}
+		return
+	}
+
+	response.Success(w, "Password changed successfully", nil)
+}
+
+// ListSessions returns current user's active sessions
+func (h *Handler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		response.Unauthorized(w, "User not found in context")
+		return
+	}
+
+	sessions, err := h.service.ListSessions(r.Context(), user.ID)
+	if err != nil {
+		response.InternalServerError(w, "Failed to list sessions", err)
+		return
+	}
+
+	response.Success(w, "Sessions retrieved successfully", sessions)
+}
+
+// RevokeSession revokes one of current user's sessions
+func (h *Handler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		response.Unauthorized(w, "User not found in context")
+		return
+	}
+
+	sessionID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(w, "Invalid session ID", err)
+		return
+	}
+
+	if err := h.service.RevokeSession(r.Context(), user.ID, sessionID); err != nil {
+		switch {
+		case errors.Is(err, ErrSessionNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Session not found", err)
+		default:
+			response.InternalServerError(w, "Failed to revoke session", err)
+		}
+		return
+	}
+
+	response.Success(w, "Session revoked successfully", nil)
+}
+
+// CreateAPIKey issues an API key for current user; the key is only returned here
+func (h *Handler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		response.Unauthorized(w, "User not found in context")
+		return
+	}
+
+	// A scoped key must not be able to mint a broader one
+	if user.IsKeyScoped() {
+		response.Forbidden(w, "API key scope does not allow this action")
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	resp, err := h.service.CreateAPIKey(r.Context(), user.ID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidAPIKeyName), errors.Is(err, ErrInvalidAPIKeyScope),
+			errors.Is(err, ErrAPIKeyExpiryPast):
+			response.BadRequest(w, "Validation failed", err)
+		case errors.Is(err, ErrAPIKeyScopeDenied):
+			response.ErrorFor(w, http.StatusForbidden, err.Error(), err)
+		default:
+			response.InternalServerError(w, "Failed to create API key", err)
+		}
+		return
+	}
+
+	response.Created(w, "API key created successfully; store it now, it will not be shown again", resp)
+}
+
+// ListAPIKeys lists current user's API keys
+func (h *Handler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		response.Unauthorized(w, "User not found in context")
+		return
+	}
+
+	keys, err := h.service.ListAPIKeys(r.Context(), user.ID)
+	if err != nil {
+		response.InternalServerError(w, "Failed to list API keys", err)
+		return
+	}
+
+	response.Success(w, "API keys retrieved successfully", keys)
+}
+
+// RevokeAPIKey revokes one of current user's API keys
+func (h *Handler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		response.Unauthorized(w, "User not found in context")
+		return
+	}
+
+	keyID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid API key ID", err)
+		return
+	}
+
+	if err := h.service.RevokeAPIKey(r.Context(), user.ID, keyID); err != nil {
+		switch {
+		case errors.Is(err, ErrAPIKeyNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "API key not found", err)
+		default:
+			response.InternalServerError(w, "Failed to revoke API key", err)
+		}
+		return
+	}
+
+	response.Success(w, "API key revoked successfully", nil)
+}
+
+// JWKS publishes the public keys used to sign tokens
+func (h *Handler) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	response.JSON(w, http.StatusOK, h.service.JWKS())
+}
+
+// SetupTwoFactor starts TOTP enrollment for current user
+func (h *Handler) SetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		response.Unauthorized(w, "User not found in context")
+		return
+	}
+
+	setup, err := h.service.SetupTwoFactor(r.Context(), user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrTwoFactorEnabled):
+			response.Conflict(w, "Two-factor authentication is already enabled", err)
+		case errors.Is(err, ErrUserNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "User not found", err)
+		default:
+			response.InternalServerError(w, "Failed to set up two-factor authentication", err)
+		}
+		return
+	}
+
+	response.Success(w, "Scan the secret with an authenticator app, then verify a code", setup)
+}
+
+// VerifyTwoFactor confirms TOTP enrollment for current user
+func (h *Handler) VerifyTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		response.Unauthorized(w, "User not found in context")
+		return
+	}
+
+	var req TwoFactorVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	result, err := h.service.EnableTwoFactor(r.Context(), user.ID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidTwoFactorCode), errors.Is(err, ErrTwoFactorNotSetup):
+			response.BadRequest(w, "Verification failed", err)
+		case errors.Is(err, ErrTwoFactorEnabled):
+			response.Conflict(w, "Two-factor authentication is already enabled", err)
+		case errors.Is(err, ErrUserNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "User not found", err)
+		default:
+			response.InternalServerError(w, "Failed to enable two-factor authentication", err)
+		}
+		return
+	}
+
+	response.Success(w, "Two-factor authentication enabled; store the recovery codes safely", result)
+}
+
+// writePasswordPolicyError responds with one validation error per failed
+// password rule and reports whether err was a password policy failure
+func writePasswordPolicyError(w http.ResponseWriter, err error) bool {
+	var policyErr *PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+
+	details := make([]response.ValidationError, len(policyErr.Violations))
+	for i, v := range policyErr.Violations {
+		details[i] = response.ValidationError{
+			Field:   "password",
+			Message: "Password " + v.Message,
+		}
+	}
+
+	response.ValidationErrors(w, "Password does not meet policy", err, details)
+	return true
+}
+
+// ListUsers returns paginated list of users (admin only)
+func (h *Handler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	// Parse query parameters
+	page := 1
+	perPage := 20
+
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if perPageStr := r.URL.Query().Get("per_page"); perPageStr != "" {
+		if pp, err := strconv.Atoi(perPageStr); err == nil && pp > 0 && pp <= 100 {
+			perPage = pp
+		}
+	}
+
+	filter, err := parseUserFilter(r)
+	if err != nil {
+		response.BadRequest(w, "Invalid query parameters", err)
+		return
+	}
+
+	users, total, err := h.scopedService(r).ListUsers(r.Context(), filter, page, perPage)
+	if err != nil {
+		response.InternalServerError(w, "Failed to list users", err)
+		return
+	}
+
+	// Remove sensitive data
+	for _, user := range users {
+		user.PasswordHash = ""
+	}
+
+	// Calculate pagination meta
+	totalPages := (total + perPage - 1) / perPage
+	meta := &response.Meta{
+		Page:       page,
+		PerPage:    perPage,
+		Total:      total,
+		TotalPages: totalPages,
+	}
+
+	response.PaginatedSuccess(w, "Users retrieved successfully", users, meta)
+}
+
+// GetUserStats returns user counts for the admin dashboard (admin only).
+// The signup histogram covers from..to (YYYY-MM-DD), the last 30 days by default.
+func (h *Handler) GetUserStats(w http.ResponseWriter, r *http.Request) {
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	from := to.AddDate(0, 0, -29)
+
+	if toStr := r.URL.Query().Get("to"); toStr != "" {
+		parsed, err := time.Parse("2006-01-02", toStr)
+		if err != nil {
+			response.BadRequest(w, "Invalid to date, expected YYYY-MM-DD", err)
+			return
+		}
+		to = parsed
+		from = to.AddDate(0, 0, -29)
+	}
+
+	if fromStr := r.URL.Query().Get("from"); fromStr != "" {
+		parsed, err := time.Parse("2006-01-02", fromStr)
+		if err != nil {
+			response.BadRequest(w, "Invalid from date, expected YYYY-MM-DD", err)
+			return
+		}
+		from = parsed
+	}
+
+	stats, err := h.scopedService(r).GetUserStats(r.Context(), from, to)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidStatsRange):
+			response.BadRequest(w, "Invalid date range", err)
+		default:
+			response.InternalServerError(w, "Failed to get user stats", err)
+		}
+		return
+	}
+
+	response.Success(w, "User stats retrieved successfully", stats)
+}
+
+// parseUserFilter builds a UserFilter from q, is_active, role, organization_id and sort query parameters.
+// Sort accepts created_at, name or email, prefixed with "-" for descending order.
+func parseUserFilter(r *http.Request) (*UserFilter, error) {
+	params := r.URL.Query()
+	active := true
+	filter := &UserFilter{
+		Query:    strings.TrimSpace(params.Get("q")),
+		IsActive: &active,
+		Role:     strings.TrimSpace(params.Get("role")),
+		SortBy:   "created_at",
+		SortDesc: true,
+	}
+
+	if orgStr := params.Get("organization_id"); orgStr != "" {
+		orgID, err := strconv.Atoi(orgStr)
+		if err != nil || orgID <= 0 {
+			return nil, errors.New("organization_id must be a positive integer")
+		}
+		filter.OrganizationID = &orgID
+	}
+
+	switch params.Get("is_active") {
+	case "", "true":
+	case "false":
+		active = false
+	case "all":
+		filter.IsActive = nil
+	default:
+		return nil, errors.New("is_active must be true, false or all")
+	}
+
+	if sort := params.Get("sort"); sort != "" {
+		filter.SortDesc = strings.HasPrefix(sort, "-")
+		filter.SortBy = strings.TrimPrefix(sort, "-")
+		if _, ok := userSortColumns[filter.SortBy]; !ok {
+			return nil, errors.New("sort must be one of created_at, name, email")
+		}
+	}
+
+	return filter, nil
+}
+
+// GetUser returns specific user by ID (admin only)
+func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid user ID", err)
+		return
+	}
+
+	user, err := h.scopedService(r).GetUser(r.Context(), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrUserNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "User not found", err)
+		default:
+			response.InternalServerError(w, "Failed to get user", err)
+		}
+		return
+	}
+
+	// Remove sensitive data
+	user.PasswordHash = ""
+
+	response.Success(w, "User retrieved successfully", user)
+}
+
+// GetUserByEmail returns specific user by email (admin only)
+func (h *Handler) GetUserByEmail(w http.ResponseWriter, r *http.Request) {
+	email := r.URL.Query().Get("email")
+	if email == "" {
+		response.BadRequest(w, "email parameter is required", nil)
+		return
+	}
+
+	user, err := h.scopedService(r).GetUserByEmail(r.Context(), email)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidEmail):
+			response.BadRequest(w, "Invalid email format", err)
+		case errors.Is(err, ErrUserNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "User not found", err)
+		default:
+			response.InternalServerError(w, "Failed to get user", err)
+		}
+		return
+	}
+
+	// Remove sensitive data
+	user.PasswordHash = ""
+
+	response.Success(w, "User retrieved successfully", user)
+}
+
+// UpdateUser updates specific user (admin only)
+func (h *Handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid user ID", err)
+		return
+	}
+
+	var req UpdateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	updatedUser, err := h.scopedService(r).UpdateProfile(r.Context(), userID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrNameRequired), errors.Is(err, ErrInvalidPhone), errors.Is(err, ErrInvalidTimezone),
+			errors.Is(err, ErrInvalidLocale), errors.Is(err, ErrInvalidAvatarURL):
+			response.BadRequest(w, "Validation failed", err)
+		case errors.Is(err, ErrUserNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "User not found", err)
+		default:
+			response.InternalServerError(w, "Failed to update user", err)
+		}
+		return
+	}
+
+	h.audit.Record(r, audit.ActionUserUpdate, audit.ResourceUser, strconv.Itoa(userID), req)
+
+	// Remove sensitive data
+	updatedUser.PasswordHash = ""
+
+	response.Success(w, "User updated successfully", updatedUser)
+}
+
+// DeactivateUser deactivates specific user (admin only)
+func (h *Handler) DeactivateUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid user ID", err)
+		return
+	}
+
+	// Prevent admin from deactivating themselves
+	currentUser, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		response.Unauthorized(w, "User not found in context")
+		return
+	}
+
+	if currentUser.ID == userID {
+		response.BadRequest(w, "Cannot deactivate your own account", nil)
+		return
+	}
+
+	if err := h.scopedService(r).DeactivateUser(r.Context(), userID); err != nil {
+		switch {
+		case errors.Is(err, ErrUserNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "User not found", err)
+		default:
+			response.InternalServerError(w, "Failed to deactivate user", err)
+		}
+		return
+	}
+
+	h.audit.Record(r, audit.ActionUserDeactivate, audit.ResourceUser, strconv.Itoa(userID),
+		map[string]interface{}{"is_active": false})
+
+	response.Success(w, "User deactivated successfully", nil)
+}
+
+// ActivateUser reactivates specific user (admin only)
+func (h *Handler) ActivateUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid user ID", err)
+		return
+	}
+
+	if err := h.scopedService(r).ActivateUser(r.Context(), userID); err != nil {
+		switch {
+		case errors.Is(err, ErrUserNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "User not found", err)
+		default:
+			response.InternalServerError(w, "Failed to activate user", err)
+		}
+		return
+	}
+
+	h.audit.Record(r, audit.ActionUserActivate, audit.ResourceUser, strconv.Itoa(userID),
+		map[string]interface{}{"is_active": true})
+
+	response.Success(w, "User activated successfully", nil)
+}
+
+// EraseUser permanently deletes specific user and their personal data (admin only)
+func (h *Handler) EraseUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid user ID", err)
+		return
+	}
+
+	currentUser, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		response.Unauthorized(w, "User not found in context")
+		return
+	}
+
+	if currentUser.ID == userID {
+		response.BadRequest(w, "Cannot erase your own account", nil)
+		return
+	}
+
+	var req EraseUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	if err := h.scopedService(r).EraseUser(r.Context(), userID, &req); err != nil {
+		switch {
+		case errors.Is(err, ErrEraseNotConfirm):
+			response.BadRequest(w, "Erasure not confirmed", err)
+		case errors.Is(err, ErrUserNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "User not found", err)
 		default:
-			response.InternalServerError(w, "Failed to register user", err)
+			response.InternalServerError(w, "Failed to erase user", err)
 		}
 		return
 	}
 
-	// Remove sensitive data
-	user.PasswordHash = ""
+	// Personal data is intentionally not recorded
+	h.audit.Record(r, audit.ActionUserErase, audit.ResourceUser, strconv.Itoa(userID), nil)
 
-	response.Created(w, "User registered successfully", user)
+	response.Success(w, "User erased successfully", nil)
 }
 
-// Login handles user authentication
-func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
-	var req LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		response.BadRequest(w, "Invalid request body", err)
+// DisableTwoFactor turns off 2FA for specific user (admin only)
+func (h *Handler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid user ID", err)
 		return
 	}
 
-	loginResp, err := h.service.Login(&req)
-	if err != nil {
-		switch err {
-		case ErrInvalidEmail:
-			response.BadRequest(w, "Invalid email format", err)
-		case ErrInvalidPassword, ErrUserNotFound:
-			response.Unauthorized(w, "Invalid email or password")
-		case ErrInactiveUser:
-			response.Forbidden(w, "Account is inactive")
+	if err := h.scopedService(r).DisableTwoFactor(r.Context(), userID); err != nil {
+		switch {
+		case errors.Is(err, ErrUserNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "User not found", err)
 		default:
-			response.InternalServerError(w, "Login failed", err)
+			response.InternalServerError(w, "Failed to disable two-factor authentication", err)
 		}
 		return
 	}
 
-	// Remove sensitive data
-	loginResp.User.PasswordHash = ""
+	h.audit.Record(r, audit.ActionUserDisable2FA, audit.ResourceUser, strconv.Itoa(userID),
+		map[string]interface{}{"is_2fa_enabled": false})
 
-	response.Success(w, "Login successful", loginResp)
+	response.Success(w, "Two-factor authentication disabled successfully", nil)
 }
 
-// GetProfile returns current user profile
-func (h *Handler) GetProfile(w http.ResponseWriter, r *http.Request) {
-	user, ok := middleware.GetUserFromContext(r.Context())
-	if !ok {
-		response.Unauthorized(w, "User not found in context")
-		return
-	}
-
-	profile, err := h.service.GetProfile(user.ID)
+// ListRoles returns all available roles (admin only)
+func (h *Handler) ListRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.service.ListRoles(r.Context())
 	if err != nil {
-		response.InternalServerError(w, "Failed to get profile", err)
+		response.InternalServerError(w, "Failed to list roles", err)
 		return
 	}
 
-	// Remove sensitive data
-	profile.PasswordHash = ""
-
-	response.Success(w, "Profile retrieved successfully", profile)
+	response.Success(w, "Roles retrieved successfully", roles)
 }
 
-// UpdateProfile updates current user profile
-func (h *Handler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
-	user, ok := middleware.GetUserFromContext(r.Context())
-	if !ok {
-		response.Unauthorized(w, "User not found in context")
-		return
-	}
-
-	var req UpdateUserRequest
+// CreateRole creates a new role (admin only)
+func (h *Handler) CreateRole(w http.ResponseWriter, r *http.Request) {
+	var req CreateRoleRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		response.BadRequest(w, "Invalid request body", err)
 		return
 	}
 
-	updatedUser, err := h.service.UpdateProfile(user.ID, &req)
+	role, err := h.scopedService(r).CreateRole(r.Context(), &req)
 	if err != nil {
-		switch err {
-		case ErrNameRequired:
+		switch {
+		case errors.Is(err, ErrInvalidRoleName):
 			response.BadRequest(w, "Validation failed", err)
-		case ErrUserNotFound:
-			response.NotFound(w, "User not found")
+		case errors.Is(err, ErrRoleExists):
+			response.Conflict(w, "Role name already exists", err)
+		case errors.Is(err, ErrSuperAdminOnly):
+			response.ErrorFor(w, http.StatusForbidden, err.Error(), err)
 		default:
-			response.InternalServerError(w, "Failed to update profile", err)
+			response.InternalServerError(w, "Failed to create role", err)
 		}
 		return
 	}
 
-	// Remove sensitive data
-	updatedUser.PasswordHash = ""
+	h.audit.Record(r, audit.ActionRoleCreate, audit.ResourceRole, strconv.Itoa(role.ID), req)
 
-	response.Success(w, "Profile updated successfully", updatedUser)
+	response.Created(w, "Role created successfully", role)
 }
 
-// ListUsers returns paginated list of users (admin only)
-func (h *Handler) ListUsers(w http.ResponseWriter, r *http.Request) {
-	// Parse query parameters
-	page := 1
-	perPage := 20
-
-	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
-		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
-			page = p
-		}
+// UpdateRole updates specific role (admin only)
+func (h *Handler) UpdateRole(w http.ResponseWriter, r *http.Request) {
+	roleID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid role ID", err)
+		return
 	}
 
-	if perPageStr := r.URL.Query().Get("per_page"); perPageStr != "" {
-		if pp, err := strconv.Atoi(perPageStr); err == nil && pp > 0 && pp <= 100 {
-			perPage = pp
-		}
+	var req UpdateRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
 	}
 
-	users, total, err := h.service.ListUsers(page, perPage)
+	role, err := h.scopedService(r).UpdateRole(r.Context(), roleID, &req)
 	if err != nil {
-		response.InternalServerError(w, "Failed to list users", err)
+		switch {
+		case errors.Is(err, ErrInvalidRoleName):
+			response.BadRequest(w, "Validation failed", err)
+		case errors.Is(err, ErrRoleNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Role not found", err)
+		case errors.Is(err, ErrRoleExists):
+			response.Conflict(w, "Role name already exists", err)
+		case errors.Is(err, ErrRoleProtected):
+			response.ErrorFor(w, http.StatusForbidden, "Built-in roles cannot be renamed", err)
+		case errors.Is(err, ErrSuperAdminOnly):
+			response.ErrorFor(w, http.StatusForbidden, err.Error(), err)
+		default:
+			response.InternalServerError(w, "Failed to update role", err)
+		}
 		return
 	}
 
-	// Remove sensitive data
-	for _, user := range users {
-		user.PasswordHash = ""
-	}
-
-	// Calculate pagination meta
-	totalPages := (total + perPage - 1) / perPage
-	meta := &response.Meta{
-		Page:       page,
-		PerPage:    perPage,
-		Total:      total,
-		TotalPages: totalPages,
-	}
+	h.audit.Record(r, audit.ActionRoleUpdate, audit.ResourceRole, strconv.Itoa(roleID), req)
 
-	response.PaginatedSuccess(w, "Users retrieved successfully", users, meta)
+	response.Success(w, "Role updated successfully", role)
 }
 
-// GetUser returns specific user by ID (admin only)
-func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) {
-	userID, err := strconv.Atoi(r.PathValue("id"))
+// DeleteRole deactivates specific role (admin only)
+func (h *Handler) DeleteRole(w http.ResponseWriter, r *http.Request) {
+	roleID, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		response.BadRequest(w, "Invalid user ID", err)
+		response.BadRequest(w, "Invalid role ID", err)
 		return
 	}
 
-	user, err := h.service.GetUser(userID)
-	if err != nil {
-		switch err {
-		case ErrUserNotFound:
-			response.NotFound(w, "User not found")
+	if err := h.scopedService(r).DeleteRole(r.Context(), roleID); err != nil {
+		switch {
+		case errors.Is(err, ErrRoleNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Role not found", err)
+		case errors.Is(err, ErrRoleInUse):
+			response.Conflict(w, "Role is still assigned to users; remove the assignments before deleting it", err)
+		case errors.Is(err, ErrRoleProtected):
+			response.ErrorFor(w, http.StatusForbidden, "Built-in roles cannot be deleted", err)
+		case errors.Is(err, ErrSuperAdminOnly):
+			response.ErrorFor(w, http.StatusForbidden, err.Error(), err)
 		default:
-			response.InternalServerError(w, "Failed to get user", err)
+			response.InternalServerError(w, "Failed to delete role", err)
 		}
 		return
 	}
 
-	// Remove sensitive data
-	user.PasswordHash = ""
+	h.audit.Record(r, audit.ActionRoleDelete, audit.ResourceRole, strconv.Itoa(roleID),
+		map[string]interface{}{"is_active": false})
 
-	response.Success(w, "User retrieved successfully", user)
+	response.Success(w, "Role deleted successfully", nil)
 }
 
-// UpdateUser updates specific user (admin only)
-func (h *Handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
-	userID, err := strconv.Atoi(r.PathValue("id"))
+// ListPermissions returns all available permissions (admin only)
+func (h *Handler) ListPermissions(w http.ResponseWriter, r *http.Request) {
+	permissions, err := h.service.ListPermissions(r.Context())
 	if err != nil {
-		response.BadRequest(w, "Invalid user ID", err)
+		response.InternalServerError(w, "Failed to list permissions", err)
 		return
 	}
 
-	var req UpdateUserRequest
+	response.Success(w, "Permissions retrieved successfully", permissions)
+}
+
+// AddRolePermission grants a permission to a role (admin only)
+func (h *Handler) AddRolePermission(w http.ResponseWriter, r *http.Request) {
+	roleID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid role ID", err)
+		return
+	}
+
+	var req RolePermissionRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		response.BadRequest(w, "Invalid request body", err)
 		return
 	}
 
-	updatedUser, err := h.service.UpdateProfile(userID, &req)
-	if err != nil {
-		switch err {
-		case ErrNameRequired:
+	if err := h.scopedService(r).AddRolePermission(r.Context(), roleID, &req); err != nil {
+		switch {
+		case errors.Is(err, ErrPermissionRequired):
 			response.BadRequest(w, "Validation failed", err)
-		case ErrUserNotFound:
-			response.NotFound(w, "User not found")
+		case errors.Is(err, ErrRoleNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Role not found", err)
+		case errors.Is(err, ErrPermissionNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Permission not found", err)
+		case errors.Is(err, ErrSuperAdminOnly):
+			response.ErrorFor(w, http.StatusForbidden, err.Error(), err)
 		default:
-			response.InternalServerError(w, "Failed to update user", err)
+			response.InternalServerError(w, "Failed to add permission to role", err)
 		}
 		return
 	}
 
-	// Remove sensitive data
-	updatedUser.PasswordHash = ""
+	h.audit.Record(r, audit.ActionRolePermissionAdd, audit.ResourceRole, strconv.Itoa(roleID),
+		map[string]interface{}{"permission_id": req.PermissionID})
 
-	response.Success(w, "User updated successfully", updatedUser)
+	response.Success(w, "Permission added to role successfully", nil)
 }
 
-// DeactivateUser deactivates specific user (admin only)
-func (h *Handler) DeactivateUser(w http.ResponseWriter, r *http.Request) {
-	userID, err := strconv.Atoi(r.PathValue("id"))
+// RemoveRolePermission revokes a permission from a role (admin only)
+func (h *Handler) RemoveRolePermission(w http.ResponseWriter, r *http.Request) {
+	roleID, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		response.BadRequest(w, "Invalid user ID", err)
-		return
-	}
-
-	// Prevent admin from deactivating themselves
-	currentUser, ok := middleware.GetUserFromContext(r.Context())
-	if !ok {
-		response.Unauthorized(w, "User not found in context")
+		response.BadRequest(w, "Invalid role ID", err)
 		return
 	}
 
-	if currentUser.ID == userID {
-		response.BadRequest(w, "Cannot deactivate your own account", nil)
+	permissionID, err := strconv.Atoi(r.PathValue("permission_id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid permission ID", err)
 		return
 	}
 
-	if err := h.service.DeactivateUser(userID); err != nil {
-		switch err {
-		case ErrUserNotFound:
-			response.NotFound(w, "User not found")
+	if err := h.scopedService(r).RemoveRolePermission(r.Context(), roleID, permissionID); err != nil {
+		switch {
+		case errors.Is(err, ErrRolePermissionNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Role permission not found", err)
+		case errors.Is(err, ErrSuperAdminOnly):
+			response.ErrorFor(w, http.StatusForbidden, err.Error(), err)
 		default:
-			response.InternalServerError(w, "Failed to deactivate user", err)
+			response.InternalServerError(w, "Failed to remove permission from role", err)
 		}
 		return
 	}
 
-	response.Success(w, "User deactivated successfully", nil)
-}
-
-// ListRoles returns all available roles (admin only)
-func (h *Handler) ListRoles(w http.ResponseWriter, r *http.Request) {
-	roles, err := h.service.ListRoles()
-	if err != nil {
-		response.InternalServerError(w, "Failed to list roles", err)
-		return
-	}
+	h.audit.Record(r, audit.ActionRolePermissionRemove, audit.ResourceRole, strconv.Itoa(roleID),
+		map[string]interface{}{"permission_id": permissionID})
 
-	response.Success(w, "Roles retrieved successfully", roles)
+	response.Success(w, "Permission removed from role successfully", nil)
 }
 
 // AssignRole assigns role to user (admin only)
@@ -318,8 +1297,12 @@ func (h *Handler) AssignRole(w http.ResponseWriter, r *http.Request) {
 
 	req.AssignedBy = currentUser.ID
 
-	if err := h.service.AssignUserRole(req.UserID, req.RoleID, req.AssignedBy); err != nil {
-		if strings.Contains(err.Error(), "not found") {
+	if err := h.scopedService(r).AssignUserRole(r.Context(), req.UserID, req.RoleID, req.AssignedBy, req.ExpiresAt); err != nil {
+		if errors.Is(err, ErrSuperAdminOnly) {
+			response.Forbidden(w, err.Error())
+		} else if errors.Is(err, ErrRoleExpiryPast) {
+			response.BadRequest(w, "Validation failed", err)
+		} else if errors.Is(err, ErrUserNotFound) || errors.Is(err, ErrRoleNotFound) {
 			response.NotFound(w, "User or role not found")
 		} else {
 			response.InternalServerError(w, "Failed to assign role", err)
@@ -327,23 +1310,71 @@ func (h *Handler) AssignRole(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.audit.Record(r, audit.ActionRoleAssign, audit.ResourceUser, strconv.Itoa(req.UserID),
+		map[string]interface{}{"role_id": req.RoleID, "expires_at": req.ExpiresAt})
+
 	response.Success(w, "Role assigned successfully", nil)
 }
 
-// RemoveRole removes role from user (admin only)
-func (h *Handler) RemoveRole(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		UserID int `json:"user_id"`
-		RoleID int `json:"role_id"`
+// BulkAssignRole assigns a role to many users (admin only)
+func (h *Handler) BulkAssignRole(w http.ResponseWriter, r *http.Request) {
+	roleID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid role ID", err)
+		return
+	}
+
+	currentUser, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		response.Unauthorized(w, "User not found in context")
+		return
+	}
+
+	var req BulkAssignRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	results, err := h.scopedService(r).BulkAssignRole(r.Context(), roleID, &req, currentUser.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrUserIDsRequired), errors.Is(err, ErrTooManyUserIDs):
+			response.BadRequest(w, "Validation failed", err)
+		case errors.Is(err, ErrRoleNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Role not found", err)
+		case errors.Is(err, ErrSuperAdminOnly):
+			response.ErrorFor(w, http.StatusForbidden, err.Error(), err)
+		default:
+			response.InternalServerError(w, "Failed to assign role", err)
+		}
+		return
+	}
+
+	assigned := []int{}
+	for _, result := range results {
+		if result.Status == AssignStatusAssigned {
+			assigned = append(assigned, result.UserID)
+		}
+	}
+	if len(assigned) > 0 {
+		h.audit.Record(r, audit.ActionRoleAssign, audit.ResourceRole, strconv.Itoa(roleID),
+			map[string]interface{}{"user_ids": assigned})
 	}
 
+	response.Success(w, "Role assignment processed", results)
+}
+
+// RemoveRole removes role from user (admin only)
+func (h *Handler) RemoveRole(w http.ResponseWriter, r *http.Request) {
+	var req RemoveRoleRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		response.BadRequest(w, "Invalid request body", err)
 		return
 	}
 
-	if err := h.service.RemoveUserRole(req.UserID, req.RoleID); err != nil {
-		if strings.Contains(err.Error(), "not found") {
+	if err := h.scopedService(r).RemoveUserRole(r.Context(), req.UserID, req.RoleID); err != nil {
+		if errors.Is(err, ErrUserNotFound) || errors.Is(err, ErrUserRoleMissing) {
 			response.NotFound(w, "User role not found")
 		} else {
 			response.InternalServerError(w, "Failed to remove role", err)
@@ -351,10 +1382,13 @@ func (h *Handler) RemoveRole(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.audit.Record(r, audit.ActionRoleRemove, audit.ResourceUser, strconv.Itoa(req.UserID),
+		map[string]interface{}{"role_id": req.RoleID})
+
 	response.Success(w, "Role removed successfully", nil)
 }
 
-// GetUserRoles returns roles for specific user (admin only)
+// GetUserRoles returns role assignments for specific user, or bare roles with plain=true (admin only)
 func (h *Handler) GetUserRoles(w http.ResponseWriter, r *http.Request) {
 	userID, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
@@ -362,15 +1396,73 @@ func (h *Handler) GetUserRoles(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	roles, err := h.service.GetUserRoles(userID)
+	// plain=true keeps the bare role list older clients expect
+	var roles interface{}
+	if r.URL.Query().Get("plain") == "true" {
+		roles, err = h.scopedService(r).GetUserRoles(r.Context(), userID)
+	} else {
+		roles, err = h.scopedService(r).GetUserRoleDetails(r.Context(), userID)
+	}
 	if err != nil {
-		response.InternalServerError(w, "Failed to get user roles", err)
+		switch {
+		case errors.Is(err, ErrUserNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "User not found", err)
+		default:
+			response.InternalServerError(w, "Failed to get user roles", err)
+		}
 		return
 	}
 
 	response.Success(w, "User roles retrieved successfully", roles)
 }
 
+// GetLoginHistory returns login attempts for specific user (admin only)
+func (h *Handler) GetLoginHistory(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid user ID", err)
+		return
+	}
+
+	// Parse query parameters
+	page := 1
+	perPage := 20
+
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if perPageStr := r.URL.Query().Get("per_page"); perPageStr != "" {
+		if pp, err := strconv.Atoi(perPageStr); err == nil && pp > 0 && pp <= 100 {
+			perPage = pp
+		}
+	}
+
+	entries, total, err := h.scopedService(r).GetLoginHistory(r.Context(), userID, page, perPage)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrUserNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "User not found", err)
+		default:
+			response.InternalServerError(w, "Failed to get login history", err)
+		}
+		return
+	}
+
+	// Calculate pagination meta
+	totalPages := (total + perPage - 1) / perPage
+	meta := &response.Meta{
+		Page:       page,
+		PerPage:    perPage,
+		Total:      total,
+		TotalPages: totalPages,
+	}
+
+	response.PaginatedSuccess(w, "Login history retrieved successfully", entries, meta)
+}
+
 // GetMyPermissions returns current user's permissions
 func (h *Handler) GetMyPermissions(w http.ResponseWriter, r *http.Request) {
 	user, ok := middleware.GetUserFromContext(r.Context())
@@ -379,7 +1471,7 @@ func (h *Handler) GetMyPermissions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	permissions, err := h.service.GetUserPermissions(user.ID)
+	permissions, err := h.service.GetUserPermissions(r.Context(), user.ID)
 	if err != nil {
 		response.InternalServerError(w, "Failed to get permissions", err)
 		return
@@ -408,3 +1500,73 @@ func extractIDFromPath(path, prefix string) (int, error) {
 
 	return id, nil
 }
+
+// ListOrganizations returns all active organizations (super admin only)
+func (h *Handler) ListOrganizations(w http.ResponseWriter, r *http.Request) {
+	orgs, err := h.service.ListOrganizations(r.Context())
+	if err != nil {
+		response.InternalServerError(w, "Failed to list organizations", err)
+		return
+	}
+
+	response.Success(w, "Organizations retrieved successfully", orgs)
+}
+
+// CreateOrganization creates a new organization (super admin only)
+func (h *Handler) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	var req CreateOrganizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	org, err := h.service.CreateOrganization(r.Context(), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidOrganization):
+			response.BadRequest(w, "Validation failed", err)
+		case errors.Is(err, ErrOrganizationExists):
+			response.Conflict(w, "Organization name already exists", err)
+		default:
+			response.InternalServerError(w, "Failed to create organization", err)
+		}
+		return
+	}
+
+	h.audit.Record(r, audit.ActionOrganizationCreate, audit.ResourceOrganization, strconv.Itoa(org.ID), req)
+
+	response.Created(w, "Organization created successfully", org)
+}
+
+// MoveUserToOrganization moves specific user to another organization (super admin only)
+func (h *Handler) MoveUserToOrganization(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid user ID", err)
+		return
+	}
+
+	var req MoveUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	if err := h.service.MoveUserToOrganization(r.Context(), userID, &req); err != nil {
+		switch {
+		case errors.Is(err, ErrOrganizationRequired):
+			response.BadRequest(w, "Validation failed", err)
+		case errors.Is(err, ErrOrganizationNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Organization not found", err)
+		case errors.Is(err, ErrUserNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "User not found", err)
+		default:
+			response.InternalServerError(w, "Failed to move user", err)
+		}
+		return
+	}
+
+	h.audit.Record(r, audit.ActionUserMoveOrganization, audit.ResourceUser, strconv.Itoa(userID), req)
+
+	response.Success(w, "User moved successfully", nil)
+}
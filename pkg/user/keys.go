@@ -0,0 +1,154 @@
+package user
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWK represents a public JSON Web Key
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// JWKSet represents a JSON Web Key Set
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// signingKeys signs tokens with the current key and verifies tokens signed
+// by the current or a previous key. Without an RSA key it falls back to HS256.
+type signingKeys struct {
+	method     jwt.SigningMethod
+	kid        string
+	signKey    interface{}
+	verifyKeys map[string]*rsa.PublicKey
+	hmacSecret []byte
+}
+
+// newSigningKeys loads RS256 keys when a private key path is configured
+func newSigningKeys(secret, privateKeyPath string, previousKeyPaths []string) (*signingKeys, error) {
+	if privateKeyPath == "" {
+		return &signingKeys{
+			method:     jwt.SigningMethodHS256,
+			hmacSecret: []byte(secret),
+		}, nil
+	}
+
+	pemData, err := os.ReadFile(privateKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWT private key: %w", err)
+	}
+
+	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(pemData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JWT private key: %w", err)
+	}
+
+	kid, err := keyID(&privateKey.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := &signingKeys{
+		method:     jwt.SigningMethodRS256,
+		kid:        kid,
+		signKey:    privateKey,
+		verifyKeys: map[string]*rsa.PublicKey{kid: &privateKey.PublicKey},
+	}
+
+	// Previous public keys keep already issued tokens valid during rotation
+	for _, path := range previousKeyPaths {
+		pemData, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read previous JWT public key %s: %w", path, err)
+		}
+
+		publicKey, err := jwt.ParseRSAPublicKeyFromPEM(pemData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse previous JWT public key %s: %w", path, err)
+		}
+
+		previousKid, err := keyID(publicKey)
+		if err != nil {
+			return nil, err
+		}
+		keys.verifyKeys[previousKid] = publicKey
+	}
+
+	return keys, nil
+}
+
+// keyID derives a stable key ID from the public key
+func keyID(publicKey *rsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode public key: %w", err)
+	}
+
+	sum := sha256.Sum256(der)
+	return base64.RawURLEncoding.EncodeToString(sum[:12]), nil
+}
+
+// sign signs claims with the current key
+func (k *signingKeys) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.method, claims)
+	if k.kid != "" {
+		token.Header["kid"] = k.kid
+	}
+
+	if k.hmacSecret != nil {
+		return token.SignedString(k.hmacSecret)
+	}
+	return token.SignedString(k.signKey)
+}
+
+// keyFunc resolves the verification key for a parsed token
+func (k *signingKeys) keyFunc(token *jwt.Token) (interface{}, error) {
+	if token.Method.Alg() != k.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	if k.hmacSecret != nil {
+		return k.hmacSecret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		kid = k.kid
+	}
+
+	publicKey, ok := k.verifyKeys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key ID: %s", kid)
+	}
+
+	return publicKey, nil
+}
+
+// jwks returns the public keys in JWKS format; empty for HS256
+func (k *signingKeys) jwks() *JWKSet {
+	set := &JWKSet{Keys: []JWK{}}
+	for kid, publicKey := range k.verifyKeys {
+		set.Keys = append(set.Keys, JWK{
+			Kty: "RSA",
+			Use: "sig",
+			Alg: jwt.SigningMethodRS256.Alg(),
+			Kid: kid,
+			N:   base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+		})
+	}
+	return set
+}
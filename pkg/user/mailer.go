@@ -0,0 +1,24 @@
+package user
+
+import (
+	"log"
+)
+
+// Mailer delivers outgoing email messages
+type Mailer interface {
+	SendMail(to, subject, body string) error
+}
+
+// LogMailer writes messages to the application log instead of sending them
+type LogMailer struct{}
+
+// NewLogMailer creates a mailer suitable for development environments
+func NewLogMailer() Mailer {
+	return &LogMailer{}
+}
+
+// SendMail logs the message
+func (m *LogMailer) SendMail(to, subject, body string) error {
+	log.Printf("Mail to %s: %s\n%s", to, subject, body)
+	return nil
+}
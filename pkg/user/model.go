@@ -1,24 +1,50 @@
 package user
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"net/url"
 	"regexp"
 	"strings"
 	"time"
+	"user-management/shared/interfaces"
+	"user-management/shared/response"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
 // User represents a user entity
 type User struct {
-	ID           int       `json:"id"`
-	Email        string    `json:"email"`
-	PasswordHash string    `json:"-"` // Hidden from JSON
-	Name         string    `json:"name"`
-	IsActive     bool      `json:"is_active"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
-	Roles        []Role    `json:"roles,omitempty"`
+	ID             int    `json:"id"`
+	Email          string `json:"email"`
+	PasswordHash   string `json:"-"` // Hidden from JSON
+	Name           string `json:"name"`
+	OrganizationID int    `json:"organization_id"`
+	IsActive       bool   `json:"is_active"`
+	Is2FAEnabled   bool   `json:"is_2fa_enabled"`
+	// MustChangePassword blocks token use until the user sets a new password
+	MustChangePassword bool       `json:"must_change_password"`
+	EmailVerifiedAt    *time.Time `json:"email_verified_at,omitempty"`
+	Phone              string     `json:"phone,omitempty"`
+	Timezone           string     `json:"timezone,omitempty"`
+	Locale             string     `json:"locale,omitempty"`
+	AvatarURL          string     `json:"avatar_url,omitempty"`
+	LastLoginAt        *time.Time `json:"last_login_at,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+	Roles              []Role     `json:"roles,omitempty"`
+}
+
+// Organization represents a tenant owning users, sensors and locations
+type Organization struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	IsActive  bool      `json:"is_active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 // Role represents a user role
@@ -30,6 +56,8 @@ type Role struct {
 	CreatedAt   time.Time    `json:"created_at"`
 	UpdatedAt   time.Time    `json:"updated_at"`
 	Permissions []Permission `json:"permissions,omitempty"`
+	// ExpiresAt is set on roles assigned to a user until a given time
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
 }
 
 // Permission represents a system permission
@@ -42,6 +70,16 @@ type Permission struct {
 	CreatedAt   time.Time `json:"created_at"`
 }
 
+// PermissionWildcard matches any resource or action
+const PermissionWildcard = "*"
+
+// Matches checks if permission grants the action on the resource,
+// treating a wildcard resource or action as matching anything
+func (p Permission) Matches(resource, action string) bool {
+	return (p.Resource == PermissionWildcard || p.Resource == resource) &&
+		(p.Action == PermissionWildcard || p.Action == action)
+}
+
 // UserRole represents user-role mapping
 type UserRole struct {
 	UserID     int       `json:"user_id"`
@@ -61,6 +99,13 @@ type CreateUserRequest struct {
 type UpdateUserRequest struct {
 	Name     *string `json:"name,omitempty"`
 	IsActive *bool   `json:"is_active,omitempty"`
+	// MustChangePassword may only be set by admins
+	MustChangePassword *bool `json:"must_change_password,omitempty"`
+	// Profile fields; an empty string clears the field
+	Phone     *string `json:"phone,omitempty"`
+	Timezone  *string `json:"timezone,omitempty"`
+	Locale    *string `json:"locale,omitempty"`
+	AvatarURL *string `json:"avatar_url,omitempty"`
 }
 
 // LoginRequest represents login request
@@ -69,33 +114,413 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+// ClientInfo describes the client making an authentication request
+type ClientInfo struct {
+	IPAddress string `json:"ip_address"`
+	UserAgent string `json:"user_agent"`
+}
+
+// LoginHistory represents a single login attempt
+type LoginHistory struct {
+	ID            int64     `json:"id"`
+	UserID        *int      `json:"user_id,omitempty"`
+	Email         string    `json:"email"`
+	IPAddress     string    `json:"ip_address"`
+	UserAgent     string    `json:"user_agent"`
+	Success       bool      `json:"success"`
+	FailureReason string    `json:"failure_reason,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
 // LoginResponse represents login response
+// When TwoFactorRequired is set, no tokens are issued and the client must
+// complete the login with TwoFactorToken and a code.
 type LoginResponse struct {
-	User         *User  `json:"user"`
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token,omitempty"`
-	ExpiresIn    int    `json:"expires_in"`
+	User              *User  `json:"user,omitempty"`
+	AccessToken       string `json:"access_token,omitempty"`
+	RefreshToken      string `json:"refresh_token,omitempty"`
+	ExpiresIn         int    `json:"expires_in,omitempty"`
+	TwoFactorRequired bool   `json:"two_factor_required,omitempty"`
+	TwoFactorToken    string `json:"two_factor_token,omitempty"`
+	// PasswordChangeRequired replaces the tokens with a one-time
+	// PasswordChangeToken when an admin forced a password change
+	PasswordChangeRequired bool   `json:"password_change_required,omitempty"`
+	PasswordChangeToken    string `json:"password_change_token,omitempty"`
+}
+
+// TwoFactorSetupResponse represents a pending TOTP enrollment
+type TwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURL string `json:"otpauth_url"`
+}
+
+// TwoFactorVerifyRequest represents request to confirm TOTP enrollment
+type TwoFactorVerifyRequest struct {
+	Code string `json:"code"`
+}
+
+// TwoFactorVerifyResponse returns recovery codes once 2FA is enabled
+type TwoFactorVerifyResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// TwoFactorLoginRequest represents the second login step.
+// Code may be a TOTP code or an unused recovery code.
+type TwoFactorLoginRequest struct {
+	TwoFactorToken string `json:"two_factor_token"`
+	Code           string `json:"code"`
+}
+
+// Session represents an active refresh token issued to a device
+type Session struct {
+	ID         int64      `json:"id"`
+	UserID     int        `json:"user_id"`
+	IPAddress  string     `json:"ip_address"`
+	UserAgent  string     `json:"user_agent"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// APIKey represents a long-lived key a user issued for machine access
+type APIKey struct {
+	ID     int    `json:"id"`
+	UserID int    `json:"user_id"`
+	Name   string `json:"name"`
+	Prefix string `json:"prefix"`
+	// Scopes are "resource:action" pairs; empty means all of the user's permissions
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CreateAPIKeyRequest represents request to issue an API key
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// CreateAPIKeyResponse returns the plaintext key, which is only shown once
+type CreateAPIKeyResponse struct {
+	Key    string  `json:"key"`
+	APIKey *APIKey `json:"api_key"`
+}
+
+// ChangePasswordRequest represents request to change own password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// ForcedPasswordChangeRequest sets a new password with the token returned
+// by a login that requires a password change
+type ForcedPasswordChangeRequest struct {
+	PasswordChangeToken string `json:"password_change_token"`
+	NewPassword         string `json:"new_password"`
+}
+
+// PasswordReset represents a single-use password reset token
+type PasswordReset struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"user_id"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// PasswordResetRequest represents request to start a password reset
+type PasswordResetRequest struct {
+	Email string `json:"email"`
+}
+
+// VerifyEmailRequest represents request to confirm an email address
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+// ResendVerificationRequest represents request to resend the verification email
+type ResendVerificationRequest struct {
+	Email string `json:"email"`
+}
+
+// PasswordResetConfirmRequest represents request to complete a password reset
+type PasswordResetConfirmRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// Invitation represents a pending invitation to register
+type Invitation struct {
+	ID             int        `json:"id"`
+	Email          string     `json:"email"`
+	OrganizationID int        `json:"organization_id"`
+	RoleIDs        []int      `json:"role_ids"`
+	InvitedBy      *int       `json:"invited_by,omitempty"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	AcceptedAt     *time.Time `json:"accepted_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// CreateInvitationRequest represents request to invite a user
+type CreateInvitationRequest struct {
+	Email   string `json:"email"`
+	RoleIDs []int  `json:"role_ids"`
+}
+
+// AcceptInvitationRequest represents request to register with an invitation
+type AcceptInvitationRequest struct {
+	Token    string `json:"token"`
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+// Registration modes
+const (
+	RegistrationOpen       = "open"
+	RegistrationInviteOnly = "invite_only"
+)
+
+// RefreshTokenRequest represents request to exchange a refresh token
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// UserRoleDetail describes a role assignment with who made it and when
+type UserRoleDetail struct {
+	Role           *Role     `json:"role"`
+	AssignedAt     time.Time `json:"assigned_at"`
+	AssignedBy     *int      `json:"assigned_by,omitempty"`
+	AssignedByName string    `json:"assigned_by_name,omitempty"`
 }
 
 // AssignRoleRequest represents request to assign role to user
 type AssignRoleRequest struct {
-	UserID     int `json:"user_id"`
-	RoleID     int `json:"role_id"`
-	AssignedBy int `json:"assigned_by"`
+	UserID     int        `json:"user_id"`
+	RoleID     int        `json:"role_id"`
+	AssignedBy int        `json:"assigned_by"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+// RemoveRoleRequest represents request to remove role from user
+type RemoveRoleRequest struct {
+	UserID int `json:"user_id"`
+	RoleID int `json:"role_id"`
+}
+
+// CreateRoleRequest represents request to create a role
+type CreateRoleRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// UpdateRoleRequest represents request to update a role
+type UpdateRoleRequest struct {
+	Name        *string `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+}
+
+// BulkAssignRoleRequest represents request to assign a role to many users
+type BulkAssignRoleRequest struct {
+	UserIDs []int `json:"user_ids"`
+}
+
+// BulkAssignResult represents the outcome of assigning a role to one user
+type BulkAssignResult struct {
+	UserID int    `json:"user_id"`
+	Status string `json:"status"`
+}
+
+// Bulk role assignment statuses
+const (
+	AssignStatusAssigned        = "assigned"
+	AssignStatusAlreadyAssigned = "already_assigned"
+	AssignStatusUserNotFound    = "not_found"
+)
+
+// maxBulkAssign limits the number of users in one bulk role assignment
+const maxBulkAssign = 500
+
+// EraseUserRequest represents request to permanently erase a user.
+// ConfirmEmail must match the user's email address.
+type EraseUserRequest struct {
+	ConfirmEmail string `json:"confirm_email"`
+}
+
+// UserFilter represents filters for listing users
+type UserFilter struct {
+	Query    string `json:"q,omitempty"`
+	IsActive *bool  `json:"is_active,omitempty"`
+	Role     string `json:"role,omitempty"`
+	// OrganizationID limits results to one organization when set
+	OrganizationID *int   `json:"organization_id,omitempty"`
+	SortBy         string `json:"sort_by"`
+	SortDesc       bool   `json:"sort_desc"`
+	Limit          int    `json:"limit"`
+	Offset         int    `json:"offset"`
+}
+
+// UserStats summarizes user counts for the admin dashboard
+type UserStats struct {
+	TotalUsers    int                `json:"total_users"`
+	ActiveUsers   int                `json:"active_users"`
+	RecentSignups int                `json:"signups_last_30_days"`
+	UsersByRole   []RoleUserCount    `json:"users_by_role"`
+	DailySignups  []DailySignupCount `json:"daily_signups"`
+}
+
+// RoleUserCount is the number of users holding a role
+type RoleUserCount struct {
+	Role  string `json:"role"`
+	Count int    `json:"count"`
+}
+
+// DailySignupCount is the number of users created on a day
+type DailySignupCount struct {
+	Date  string `json:"date"`
+	Count int    `json:"count"`
+}
+
+// CreateOrganizationRequest represents request to create an organization
+type CreateOrganizationRequest struct {
+	Name string `json:"name"`
+}
+
+// MoveUserRequest represents request to move a user to another organization
+type MoveUserRequest struct {
+	OrganizationID int `json:"organization_id"`
+}
+
+// RolePermissionRequest represents request to grant a permission to a role
+type RolePermissionRequest struct {
+	PermissionID int `json:"permission_id"`
 }
 
 // Domain validation errors
 var (
 	ErrInvalidEmail    = errors.New("invalid email format")
-	ErrPasswordTooWeak = errors.New("password must be at least 8 characters long")
+	ErrPasswordTooWeak = errors.New("password does not meet the password policy")
 	ErrNameRequired    = errors.New("name is required")
 	ErrUserNotFound    = errors.New("user not found")
 	ErrEmailExists     = errors.New("email already exists")
 	ErrInvalidPassword = errors.New("invalid password")
 	ErrInactiveUser    = errors.New("user account is inactive")
 	ErrUnauthorized    = errors.New("unauthorized access")
+	ErrInvalidToken    = errors.New("invalid or expired token")
+	ErrTokenExpired    = errors.New("token has expired")
+	ErrPasswordMissing = errors.New("password is required")
+	ErrInvalidReset    = errors.New("invalid or expired reset token")
+	ErrEraseNotConfirm = errors.New("confirm_email must match the user's email")
+	ErrSessionNotFound = errors.New("session not found")
+
+	ErrPasswordChangeRequired = errors.New("password change required")
+	ErrPasswordReused         = errors.New("new password must differ from the current password")
+
+	ErrInvalidPhone     = errors.New("phone must be in E.164 format, e.g. +14155550123")
+	ErrInvalidTimezone  = errors.New("timezone must be an IANA time zone name")
+	ErrInvalidLocale    = errors.New("locale must be a language tag such as en or en-US")
+	ErrInvalidAvatarURL = errors.New("avatar_url must be an http or https URL")
+
+	ErrEmailNotVerified    = errors.New("email address has not been verified")
+	ErrInvalidVerification = errors.New("invalid or expired verification token")
+
+	ErrAPIKeyNotFound     = errors.New("api key not found")
+	ErrInvalidAPIKey      = errors.New("invalid or expired api key")
+	ErrInvalidAPIKeyName  = errors.New("api key name must be 1-100 characters")
+	ErrInvalidAPIKeyScope = errors.New("api key scopes must be resource:action pairs")
+	ErrAPIKeyScopeDenied  = errors.New("api key scopes must be a subset of your permissions")
+	ErrAPIKeyExpiryPast   = errors.New("api key expiry must be in the future")
+
+	ErrInvalidInvitation  = errors.New("invalid or expired invitation")
+	ErrRegistrationClosed = errors.New("registration requires an invitation")
+
+	ErrRoleNotFound    = errors.New("role not found")
+	ErrRoleExists      = errors.New("role name already exists")
+	ErrRoleInUse       = errors.New("role is still assigned to users")
+	ErrRoleProtected   = errors.New("role cannot be modified")
+	ErrSuperAdminOnly  = errors.New("only super admins can perform this operation")
+	ErrRoleExpiryPast  = errors.New("role expiry must be in the future")
+	ErrUserRoleMissing = errors.New("user role not found")
+	ErrInvalidRoleName = errors.New("role name must be 2-100 lowercase letters, digits or underscores")
+
+	ErrOrganizationNotFound = errors.New("organization not found")
+	ErrOrganizationExists   = errors.New("organization name already exists")
+	ErrOrganizationRequired = errors.New("organization ID is required")
+	ErrInvalidOrganization  = errors.New("organization name must be 2-255 characters")
+
+	ErrInvalidStatsRange = errors.New("stats range must end after it starts and span at most 366 days")
+
+	ErrUserIDsRequired = errors.New("user_ids is required")
+	ErrTooManyUserIDs  = errors.New("at most 500 user_ids allowed per request")
+
+	ErrPermissionRequired     = errors.New("permission ID is required")
+	ErrPermissionNotFound     = errors.New("permission not found")
+	ErrRolePermissionNotFound = errors.New("role does not have this permission")
+
+	ErrTwoFactorEnabled      = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotSetup     = errors.New("two-factor authentication has not been set up")
+	ErrInvalidTwoFactorCode  = errors.New("invalid two-factor code")
+	ErrTooManyTwoFactorCodes = errors.New("too many invalid two-factor codes, log in again")
 )
 
+// ErrorCodes are the stable API error codes of the errors above
+var ErrorCodes = []response.ErrorCode{
+	response.Code("USER_INVALID_EMAIL", ErrInvalidEmail),
+	response.Code("USER_PASSWORD_TOO_WEAK", ErrPasswordTooWeak),
+	response.Code("USER_NAME_REQUIRED", ErrNameRequired),
+	response.Code("USER_NOT_FOUND", ErrUserNotFound),
+	response.Code("USER_EMAIL_EXISTS", ErrEmailExists),
+	response.Code("AUTH_INVALID_CREDENTIALS", ErrInvalidPassword),
+	response.Code("USER_INACTIVE", ErrInactiveUser),
+	response.Code("AUTH_UNAUTHORIZED", ErrUnauthorized),
+	response.Code("AUTH_TOKEN_EXPIRED", ErrTokenExpired),
+	response.Code("AUTH_TOKEN_INVALID", ErrInvalidToken),
+	response.Code("USER_PASSWORD_REQUIRED", ErrPasswordMissing),
+	response.Code("AUTH_RESET_TOKEN_INVALID", ErrInvalidReset),
+	response.Code("USER_ERASE_NOT_CONFIRMED", ErrEraseNotConfirm),
+	response.Code("AUTH_SESSION_NOT_FOUND", ErrSessionNotFound),
+	response.Code("AUTH_PASSWORD_CHANGE_REQUIRED", ErrPasswordChangeRequired),
+	response.Code("USER_PASSWORD_REUSED", ErrPasswordReused),
+	response.Code("USER_INVALID_PHONE", ErrInvalidPhone),
+	response.Code("USER_INVALID_TIMEZONE", ErrInvalidTimezone),
+	response.Code("USER_INVALID_LOCALE", ErrInvalidLocale),
+	response.Code("USER_INVALID_AVATAR_URL", ErrInvalidAvatarURL),
+	response.Code("AUTH_EMAIL_NOT_VERIFIED", ErrEmailNotVerified),
+	response.Code("AUTH_VERIFICATION_TOKEN_INVALID", ErrInvalidVerification),
+	response.Code("API_KEY_NOT_FOUND", ErrAPIKeyNotFound),
+	response.Code("API_KEY_INVALID", ErrInvalidAPIKey),
+	response.Code("API_KEY_INVALID_NAME", ErrInvalidAPIKeyName),
+	response.Code("API_KEY_INVALID_SCOPE", ErrInvalidAPIKeyScope),
+	response.Code("API_KEY_SCOPE_DENIED", ErrAPIKeyScopeDenied),
+	response.Code("API_KEY_EXPIRY_PAST", ErrAPIKeyExpiryPast),
+	response.Code("AUTH_INVITATION_INVALID", ErrInvalidInvitation),
+	response.Code("AUTH_REGISTRATION_CLOSED", ErrRegistrationClosed),
+	response.Code("ROLE_NOT_FOUND", ErrRoleNotFound),
+	response.Code("ROLE_EXISTS", ErrRoleExists),
+	response.Code("ROLE_IN_USE", ErrRoleInUse),
+	response.Code("ROLE_PROTECTED", ErrRoleProtected),
+	response.Code("ROLE_SUPER_ADMIN_ONLY", ErrSuperAdminOnly),
+	response.Code("ROLE_EXPIRY_PAST", ErrRoleExpiryPast),
+	response.Code("USER_ROLE_NOT_FOUND", ErrUserRoleMissing),
+	response.Code("ROLE_INVALID_NAME", ErrInvalidRoleName),
+	response.Code("ORGANIZATION_NOT_FOUND", ErrOrganizationNotFound),
+	response.Code("ORGANIZATION_EXISTS", ErrOrganizationExists),
+	response.Code("ORGANIZATION_REQUIRED", ErrOrganizationRequired),
+	response.Code("ORGANIZATION_INVALID_NAME", ErrInvalidOrganization),
+	response.Code("USER_INVALID_STATS_RANGE", ErrInvalidStatsRange),
+	response.Code("USER_IDS_REQUIRED", ErrUserIDsRequired),
+	response.Code("USER_TOO_MANY_IDS", ErrTooManyUserIDs),
+	response.Code("PERMISSION_REQUIRED", ErrPermissionRequired),
+	response.Code("PERMISSION_NOT_FOUND", ErrPermissionNotFound),
+	response.Code("ROLE_PERMISSION_NOT_FOUND", ErrRolePermissionNotFound),
+	response.Code("AUTH_TWO_FACTOR_ENABLED", ErrTwoFactorEnabled),
+	response.Code("AUTH_TWO_FACTOR_NOT_SETUP", ErrTwoFactorNotSetup),
+	response.Code("AUTH_TWO_FACTOR_CODE_INVALID", ErrInvalidTwoFactorCode),
+	response.Code("AUTH_TWO_FACTOR_ATTEMPTS_EXCEEDED", ErrTooManyTwoFactorCodes),
+}
+
 // Validate validates CreateUserRequest
 func (req *CreateUserRequest) Validate() error {
 	// Validate email
@@ -123,23 +548,224 @@ func (req *LoginRequest) Validate() error {
 	}
 
 	if strings.TrimSpace(req.Password) == "" {
-		return errors.New("password is required")
+		return ErrPasswordMissing
 	}
 
 	return nil
 }
 
+// Validate validates ChangePasswordRequest
+func (req *ChangePasswordRequest) Validate() error {
+	if strings.TrimSpace(req.CurrentPassword) == "" {
+		return ErrPasswordMissing
+	}
+
+	return validatePassword(req.NewPassword)
+}
+
+// Validate validates ForcedPasswordChangeRequest
+func (req *ForcedPasswordChangeRequest) Validate() error {
+	if strings.TrimSpace(req.PasswordChangeToken) == "" {
+		return ErrInvalidToken
+	}
+
+	return validatePassword(req.NewPassword)
+}
+
+// Validate validates PasswordResetRequest
+func (req *PasswordResetRequest) Validate() error {
+	return validateEmail(req.Email)
+}
+
+// Validate validates VerifyEmailRequest
+func (req *VerifyEmailRequest) Validate() error {
+	if strings.TrimSpace(req.Token) == "" {
+		return ErrInvalidVerification
+	}
+	return nil
+}
+
+// Validate validates ResendVerificationRequest
+func (req *ResendVerificationRequest) Validate() error {
+	return validateEmail(req.Email)
+}
+
+// Validate validates PasswordResetConfirmRequest
+func (req *PasswordResetConfirmRequest) Validate() error {
+	if strings.TrimSpace(req.Token) == "" {
+		return ErrInvalidReset
+	}
+
+	return validatePassword(req.NewPassword)
+}
+
+// Validate validates CreateInvitationRequest
+func (req *CreateInvitationRequest) Validate() error {
+	return validateEmail(req.Email)
+}
+
+// Validate validates AcceptInvitationRequest
+func (req *AcceptInvitationRequest) Validate() error {
+	if strings.TrimSpace(req.Token) == "" {
+		return ErrInvalidInvitation
+	}
+
+	if err := validatePassword(req.Password); err != nil {
+		return err
+	}
+
+	return validateName(req.Name)
+}
+
+// Validate validates CreateAPIKeyRequest
+func (req *CreateAPIKeyRequest) Validate() error {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > 100 {
+		return ErrInvalidAPIKeyName
+	}
+
+	for _, scope := range req.Scopes {
+		if _, _, ok := parseScope(scope); !ok {
+			return ErrInvalidAPIKeyScope
+		}
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return ErrAPIKeyExpiryPast
+	}
+
+	return nil
+}
+
+// parseScope splits a "resource:action" API key scope
+func parseScope(scope string) (resource, action string, ok bool) {
+	resource, action, found := strings.Cut(scope, ":")
+	if !found || resource == "" || action == "" {
+		return "", "", false
+	}
+	return resource, action, true
+}
+
+// Validate validates TwoFactorVerifyRequest
+func (req *TwoFactorVerifyRequest) Validate() error {
+	if strings.TrimSpace(req.Code) == "" {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// Validate validates TwoFactorLoginRequest
+func (req *TwoFactorLoginRequest) Validate() error {
+	if strings.TrimSpace(req.TwoFactorToken) == "" {
+		return ErrInvalidToken
+	}
+	if strings.TrimSpace(req.Code) == "" {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// Validate validates RefreshTokenRequest
+func (req *RefreshTokenRequest) Validate() error {
+	if strings.TrimSpace(req.RefreshToken) == "" {
+		return errors.New("refresh token is required")
+	}
+	return nil
+}
+
+// Validate validates CreateRoleRequest
+func (req *CreateRoleRequest) Validate() error {
+	return validateRoleName(req.Name)
+}
+
+// Validate validates UpdateRoleRequest
+func (req *UpdateRoleRequest) Validate() error {
+	if req.Name != nil {
+		return validateRoleName(*req.Name)
+	}
+	return nil
+}
+
+// Validate validates BulkAssignRoleRequest
+func (req *BulkAssignRoleRequest) Validate() error {
+	if len(req.UserIDs) == 0 {
+		return ErrUserIDsRequired
+	}
+	if len(req.UserIDs) > maxBulkAssign {
+		return ErrTooManyUserIDs
+	}
+	return nil
+}
+
+// Validate validates CreateOrganizationRequest
+func (req *CreateOrganizationRequest) Validate() error {
+	name := strings.TrimSpace(req.Name)
+	if len(name) < 2 || len(name) > 255 {
+		return ErrInvalidOrganization
+	}
+	return nil
+}
+
+// Validate validates MoveUserRequest
+func (req *MoveUserRequest) Validate() error {
+	if req.OrganizationID <= 0 {
+		return ErrOrganizationRequired
+	}
+	return nil
+}
+
+// Validate validates RolePermissionRequest
+func (req *RolePermissionRequest) Validate() error {
+	if req.PermissionID <= 0 {
+		return ErrPermissionRequired
+	}
+	return nil
+}
+
 // Validate validates UpdateUserRequest
 func (req *UpdateUserRequest) Validate() error {
 	if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
 		return ErrNameRequired
 	}
+
+	if req.Phone != nil && *req.Phone != "" && !phoneRegex.MatchString(*req.Phone) {
+		return ErrInvalidPhone
+	}
+
+	if req.Timezone != nil && *req.Timezone != "" {
+		if *req.Timezone == "Local" || len(*req.Timezone) > 64 {
+			return ErrInvalidTimezone
+		}
+		if _, err := time.LoadLocation(*req.Timezone); err != nil {
+			return ErrInvalidTimezone
+		}
+	}
+
+	if req.Locale != nil && *req.Locale != "" && !localeRegex.MatchString(*req.Locale) {
+		return ErrInvalidLocale
+	}
+
+	if req.AvatarURL != nil && *req.AvatarURL != "" {
+		u, err := url.Parse(*req.AvatarURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || len(*req.AvatarURL) > 2048 {
+			return ErrInvalidAvatarURL
+		}
+	}
+
 	return nil
 }
 
-// HashPassword hashes a plain password
-func (u *User) HashPassword(password string) error {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+// phoneRegex matches E.164 phone numbers
+var phoneRegex = regexp.MustCompile(`^\+[1-9][0-9]{1,14}$`)
+
+// localeRegex matches simple BCP 47 language tags like en, pt-BR or zh-Hant-TW
+var localeRegex = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8}){0,2}$`)
+
+// HashPassword hashes a plain password with the given bcrypt cost.
+// Existing hashes keep verifying after the cost changes since bcrypt
+// stores the cost inside each hash.
+func (u *User) HashPassword(password string, cost int) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
 	if err != nil {
 		return err
 	}
@@ -159,7 +785,7 @@ func (u *User) HasPermission(resource, action string) bool {
 			continue
 		}
 		for _, perm := range role.Permissions {
-			if perm.Resource == resource && perm.Action == action {
+			if perm.Matches(resource, action) {
 				return true
 			}
 		}
@@ -177,9 +803,9 @@ func (u *User) HasRole(roleName string) bool {
 	return false
 }
 
-// IsAdmin checks if user is admin
+// IsAdmin checks if user is admin; super admins are admins too
 func (u *User) IsAdmin() bool {
-	return u.HasRole("admin")
+	return u.HasRole("admin") || u.HasRole(interfaces.SuperAdminRole)
 }
 
 // GetPermissions returns all user permissions
@@ -203,8 +829,8 @@ func (u *User) GetPermissions() []Permission {
 	return permissions
 }
 
-// NewUser creates a new User with hashed password
-func NewUser(email, password, name string) (*User, error) {
+// NewUser creates a new User with password hashed at the given bcrypt cost
+func NewUser(email, password, name string, cost int) (*User, error) {
 	req := &CreateUserRequest{
 		Email:    email,
 		Password: password,
@@ -216,18 +842,48 @@ func NewUser(email, password, name string) (*User, error) {
 	}
 
 	user := &User{
-		Email:    strings.ToLower(strings.TrimSpace(email)),
-		Name:     strings.TrimSpace(name),
-		IsActive: true,
+		Email:          strings.ToLower(strings.TrimSpace(email)),
+		Name:           strings.TrimSpace(name),
+		OrganizationID: interfaces.DefaultOrganizationID,
+		IsActive:       true,
 	}
 
-	if err := user.HashPassword(password); err != nil {
+	if err := user.HashPassword(password, cost); err != nil {
 		return nil, err
 	}
 
 	return user, nil
 }
 
+// ResolveBCryptCost returns the bcrypt cost to use for a configured value.
+// Zero selects bcrypt.DefaultCost; values outside bcrypt's range are rejected.
+func ResolveBCryptCost(cost int) (int, error) {
+	if cost == 0 {
+		return bcrypt.DefaultCost, nil
+	}
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return 0, fmt.Errorf("bcrypt cost %d out of range [%d, %d]", cost, bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	return cost, nil
+}
+
+// generateToken returns a random URL-safe token and its SHA-256 hash
+func generateToken() (token, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+
+	token = hex.EncodeToString(buf)
+	return token, hashToken(token), nil
+}
+
+// hashToken returns the hex encoded SHA-256 hash of a token
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 // Helper validation functions
 func validateEmail(email string) error {
 	email = strings.TrimSpace(email)
@@ -243,9 +899,18 @@ func validateEmail(email string) error {
 	return nil
 }
 
+// validatePassword only checks presence; strength rules are enforced by PasswordPolicy
 func validatePassword(password string) error {
-	if len(password) < 8 {
-		return ErrPasswordTooWeak
+	if password == "" {
+		return ErrPasswordMissing
+	}
+	return nil
+}
+
+func validateRoleName(name string) error {
+	roleNameRegex := regexp.MustCompile(`^[a-z0-9_]{2,100}$`)
+	if !roleNameRegex.MatchString(strings.TrimSpace(name)) {
+		return ErrInvalidRoleName
 	}
 	return nil
 }
@@ -0,0 +1,92 @@
+package user
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestResolveBCryptCost(t *testing.T) {
+	tests := []struct {
+		cost    int
+		want    int
+		wantErr bool
+	}{
+		{0, bcrypt.DefaultCost, false},
+		{bcrypt.MinCost, bcrypt.MinCost, false},
+		{12, 12, false},
+		{bcrypt.MaxCost, bcrypt.MaxCost, false},
+		{bcrypt.MinCost - 1, 0, true},
+		{bcrypt.MaxCost + 1, 0, true},
+		{-1, 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ResolveBCryptCost(tt.cost)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("cost %d: got error %v, want error %v", tt.cost, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("cost %d: got %d, want %d", tt.cost, got, tt.want)
+		}
+	}
+}
+
+// TestNewServiceRejectsBCryptCost fails at startup on a cost bcrypt does
+// not support instead of on the first password hashed
+func TestNewServiceRejectsBCryptCost(t *testing.T) {
+	cfg := Config{JWTSecret: "test-secret", EncryptionKey: "test-encryption-key", BCryptCost: bcrypt.MaxCost + 1}
+	if _, err := NewService(nil, nil, cfg); err == nil || !strings.Contains(err.Error(), "bcrypt cost") {
+		t.Fatalf("got %v, want an error for the bcrypt cost", err)
+	}
+}
+
+// TestHashPasswordUsesCost hashes new passwords with the configured cost
+func TestHashPasswordUsesCost(t *testing.T) {
+	user, err := NewUser("cost@example.com", "Str0ng!Passw0rd", "Cost", bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cost, err := bcrypt.Cost([]byte(user.PasswordHash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost != bcrypt.MinCost {
+		t.Errorf("got cost %d, want %d", cost, bcrypt.MinCost)
+	}
+
+	if err := user.HashPassword("An0ther!Passw0rd", bcrypt.MinCost+1); err != nil {
+		t.Fatal(err)
+	}
+	if cost, _ := bcrypt.Cost([]byte(user.PasswordHash)); cost != bcrypt.MinCost+1 {
+		t.Errorf("got cost %d, want %d", cost, bcrypt.MinCost+1)
+	}
+}
+
+// TestCheckPasswordAcceptsOtherCosts keeps verifying hashes made with
+// bcrypt.DefaultCost, as every hash was before the cost was configurable
+func TestCheckPasswordAcceptsOtherCosts(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("Old!Passw0rd"), bcrypt.DefaultCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &User{PasswordHash: string(hash)}
+
+	if err := user.CheckPassword("Old!Passw0rd"); err != nil {
+		t.Errorf("existing hash no longer verifies: %v", err)
+	}
+	if err := user.CheckPassword("Wrong!Passw0rd"); err == nil {
+		t.Error("wrong password verified against existing hash")
+	}
+
+	// Changing the password moves the account to the configured cost
+	if err := user.HashPassword("New!Passw0rd", bcrypt.MinCost); err != nil {
+		t.Fatal(err)
+	}
+	if err := user.CheckPassword("New!Passw0rd"); err != nil {
+		t.Errorf("new hash does not verify: %v", err)
+	}
+}
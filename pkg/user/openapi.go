@@ -0,0 +1,106 @@
+package user
+
+import (
+	"net/http"
+	"user-management/shared/openapi"
+)
+
+// Operations documents the user routes in the OpenAPI document
+var Operations = []openapi.Operation{
+	// Authentication
+	{Pattern: "POST /api/auth/register", Tag: "auth", Summary: "Register an account",
+		Request: CreateUserRequest{}, Response: &User{}, Status: http.StatusCreated},
+	{Pattern: "POST /api/auth/register/invite", Tag: "auth", Summary: "Register with an invitation",
+		Request: AcceptInvitationRequest{}, Response: &User{}, Status: http.StatusCreated},
+	{Pattern: "POST /api/auth/verify-email", Tag: "auth", Summary: "Verify an email address",
+		Request: VerifyEmailRequest{}},
+	{Pattern: "POST /api/auth/verify-email/resend", Tag: "auth", Summary: "Resend the verification email",
+		Request: ResendVerificationRequest{}},
+	{Pattern: "POST /api/auth/login", Tag: "auth", Summary: "Log in",
+		Request: LoginRequest{}, Response: &LoginResponse{}},
+	{Pattern: "POST /api/auth/refresh", Tag: "auth", Summary: "Refresh the access token",
+		Request: RefreshTokenRequest{}, Response: &LoginResponse{}},
+	{Pattern: "POST /api/auth/2fa/login", Tag: "auth", Summary: "Complete a login with a two-factor code",
+		Request: TwoFactorLoginRequest{}, Response: &LoginResponse{}},
+	{Pattern: "POST /api/auth/password/forced-change", Tag: "auth", Summary: "Change an expired password and log in",
+		Request: ForcedPasswordChangeRequest{}, Response: &LoginResponse{}},
+	{Pattern: "GET /.well-known/jwks.json", Tag: "auth", Summary: "Get the token signing keys",
+		Response: &JWKSet{}, Raw: true},
+	{Pattern: "POST /api/auth/password-reset/request", Tag: "auth", Summary: "Request a password reset",
+		Request: PasswordResetRequest{}},
+	{Pattern: "POST /api/auth/password-reset/confirm", Tag: "auth", Summary: "Reset a password",
+		Request: PasswordResetConfirmRequest{}},
+
+	// Own account
+	{Pattern: "GET /api/auth/profile", Tag: "account", Summary: "Get the profile", Access: openapi.AccessAuthenticated,
+		Response: &User{}},
+	{Pattern: "PUT /api/auth/profile", Tag: "account", Summary: "Update the profile", Access: openapi.AccessAuthenticated,
+		Request: UpdateUserRequest{}, Response: &User{}},
+	{Pattern: "PUT /api/auth/password", Tag: "account", Summary: "Change the password", Access: openapi.AccessAuthenticated,
+		Request: ChangePasswordRequest{}},
+	{Pattern: "GET /api/auth/sessions", Tag: "account", Summary: "List sessions", Access: openapi.AccessAuthenticated,
+		Response: []*Session{}},
+	{Pattern: "DELETE /api/auth/sessions/{id}", Tag: "account", Summary: "Revoke a session", Access: openapi.AccessAuthenticated},
+	{Pattern: "POST /api/auth/api-keys", Tag: "account", Summary: "Create an API key", Access: openapi.AccessAuthenticated,
+		Request: CreateAPIKeyRequest{}, Response: &CreateAPIKeyResponse{}, Status: http.StatusCreated},
+	{Pattern: "GET /api/auth/api-keys", Tag: "account", Summary: "List API keys", Access: openapi.AccessAuthenticated,
+		Response: []*APIKey{}},
+	{Pattern: "DELETE /api/auth/api-keys/{id}", Tag: "account", Summary: "Revoke an API key", Access: openapi.AccessAuthenticated},
+	{Pattern: "POST /api/auth/2fa/setup", Tag: "account", Summary: "Start two-factor setup", Access: openapi.AccessAuthenticated,
+		Response: &TwoFactorSetupResponse{}},
+	{Pattern: "POST /api/auth/2fa/verify", Tag: "account", Summary: "Enable two-factor authentication", Access: openapi.AccessAuthenticated,
+		Request: TwoFactorVerifyRequest{}, Response: &TwoFactorVerifyResponse{}},
+	{Pattern: "GET /api/auth/permissions", Tag: "account", Summary: "List own permissions", Access: openapi.AccessAuthenticated,
+		Response: []*Permission{}},
+
+	// Users
+	{Pattern: "GET /api/users", Tag: "users", Summary: "List users", Access: openapi.AccessAdmin,
+		Query: []string{"q", "role", "is_active", "organization_id", "sort", "page", "per_page"}, Response: []*User{}, Paginated: true},
+	{Pattern: "POST /api/users/invitations", Tag: "users", Summary: "Invite a user", Access: openapi.AccessAdmin,
+		Request: CreateInvitationRequest{}, Response: &Invitation{}, Status: http.StatusCreated},
+	{Pattern: "GET /api/users/stats", Tag: "users", Summary: "Get user statistics", Access: openapi.AccessAdmin,
+		Query: []string{"from", "to"}, Response: &UserStats{}},
+	{Pattern: "GET /api/users/by-email", Tag: "users", Summary: "Get a user by email", Access: openapi.AccessAdmin,
+		Query: []string{"email"}, Response: &User{}},
+	{Pattern: "GET /api/users/{id}", Tag: "users", Summary: "Get a user", Access: openapi.AccessAdmin,
+		Response: &User{}},
+	{Pattern: "PUT /api/users/{id}", Tag: "users", Summary: "Update a user", Access: openapi.AccessAdmin,
+		Request: UpdateUserRequest{}, Response: &User{}},
+	{Pattern: "DELETE /api/users/{id}", Tag: "users", Summary: "Deactivate a user", Access: openapi.AccessAdmin},
+	{Pattern: "POST /api/users/{id}/activate", Tag: "users", Summary: "Activate a user", Access: openapi.AccessAdmin},
+	{Pattern: "POST /api/users/{id}/erase", Tag: "users", Summary: "Erase the personal data of a user", Access: openapi.AccessAdmin,
+		Request: EraseUserRequest{}},
+	{Pattern: "DELETE /api/users/{id}/2fa", Tag: "users", Summary: "Disable two-factor authentication of a user", Access: openapi.AccessAdmin},
+	{Pattern: "GET /api/users/{id}/roles", Tag: "users", Summary: "List the roles of a user", Access: openapi.AccessAdmin,
+		Query: []string{"plain"}, Response: []*Role{}},
+	{Pattern: "GET /api/users/{id}/logins", Tag: "users", Summary: "List the logins of a user", Access: openapi.AccessAdmin,
+		Query: []string{"page", "per_page"}, Response: []*LoginHistory{}, Paginated: true},
+	{Pattern: "POST /api/users/roles", Tag: "users", Summary: "Assign a role to a user", Access: openapi.AccessAdmin,
+		Request: AssignRoleRequest{}},
+	{Pattern: "DELETE /api/users/roles", Tag: "users", Summary: "Remove a role from a user", Access: openapi.AccessAdmin,
+		Request: RemoveRoleRequest{}},
+
+	// Roles and permissions
+	{Pattern: "GET /api/roles", Tag: "roles", Summary: "List roles", Access: openapi.AccessAdmin,
+		Response: []*Role{}},
+	{Pattern: "POST /api/roles", Tag: "roles", Summary: "Create a role", Access: openapi.AccessSuperAdmin,
+		Request: CreateRoleRequest{}, Response: &Role{}, Status: http.StatusCreated},
+	{Pattern: "PUT /api/roles/{id}", Tag: "roles", Summary: "Update a role", Access: openapi.AccessSuperAdmin,
+		Request: UpdateRoleRequest{}, Response: &Role{}},
+	{Pattern: "DELETE /api/roles/{id}", Tag: "roles", Summary: "Delete a role", Access: openapi.AccessSuperAdmin},
+	{Pattern: "GET /api/permissions", Tag: "roles", Summary: "List permissions", Access: openapi.AccessAdmin,
+		Response: []*Permission{}},
+	{Pattern: "POST /api/roles/{id}/permissions", Tag: "roles", Summary: "Grant a permission to a role", Access: openapi.AccessSuperAdmin,
+		Request: RolePermissionRequest{}},
+	{Pattern: "DELETE /api/roles/{id}/permissions/{permission_id}", Tag: "roles", Summary: "Revoke a permission from a role", Access: openapi.AccessSuperAdmin},
+	{Pattern: "POST /api/roles/{id}/users", Tag: "roles", Summary: "Assign a role to several users", Access: openapi.AccessAdmin,
+		Request: BulkAssignRoleRequest{}, Response: []*BulkAssignResult{}},
+
+	// Organizations
+	{Pattern: "GET /api/organizations", Tag: "organizations", Summary: "List organizations", Access: openapi.AccessSuperAdmin,
+		Response: []*Organization{}},
+	{Pattern: "POST /api/organizations", Tag: "organizations", Summary: "Create an organization", Access: openapi.AccessSuperAdmin,
+		Request: CreateOrganizationRequest{}, Response: &Organization{}, Status: http.StatusCreated},
+	{Pattern: "PUT /api/users/{id}/organization", Tag: "organizations", Summary: "Move a user to an organization", Access: openapi.AccessSuperAdmin,
+		Request: MoveUserRequest{}},
+}
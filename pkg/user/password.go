@@ -0,0 +1,118 @@
+package user
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// PasswordPolicy describes the rules new passwords must satisfy
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// RejectEmail rejects passwords containing the email local-part
+	RejectEmail bool
+	// DenyList holds common passwords that are always rejected
+	DenyList []string
+}
+
+// defaultMinPasswordLength applies when the policy leaves MinLength unset
+const defaultMinPasswordLength = 8
+
+// PasswordRuleViolation describes a single failed password rule
+type PasswordRuleViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicyError is returned when a password fails one or more rules
+type PasswordPolicyError struct {
+	Violations []PasswordRuleViolation
+}
+
+// Error implements the error interface
+func (e *PasswordPolicyError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Message
+	}
+	return "password does not meet policy: " + strings.Join(messages, "; ")
+}
+
+// Unwrap lets callers match policy failures with errors.Is(err, ErrPasswordTooWeak)
+func (e *PasswordPolicyError) Unwrap() error {
+	return ErrPasswordTooWeak
+}
+
+// Check validates a password for the account with the given email.
+// It returns a *PasswordPolicyError listing every failed rule, or nil.
+func (p PasswordPolicy) Check(password, email string) error {
+	minLength := p.MinLength
+	if minLength <= 0 {
+		minLength = defaultMinPasswordLength
+	}
+
+	var violations []PasswordRuleViolation
+	add := func(rule, message string) {
+		violations = append(violations, PasswordRuleViolation{Rule: rule, Message: message})
+	}
+
+	if len([]rune(password)) < minLength {
+		add("min_length", fmt.Sprintf("must be at least %d characters long", minLength))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			hasSymbol = true
+		}
+	}
+
+	if p.RequireUpper && !hasUpper {
+		add("require_upper", "must contain an uppercase letter")
+	}
+	if p.RequireLower && !hasLower {
+		add("require_lower", "must contain a lowercase letter")
+	}
+	if p.RequireDigit && !hasDigit {
+		add("require_digit", "must contain a digit")
+	}
+	if p.RequireSymbol && !hasSymbol {
+		add("require_symbol", "must contain a symbol")
+	}
+
+	lowered := strings.ToLower(password)
+
+	if p.RejectEmail && email != "" {
+		local := strings.ToLower(strings.TrimSpace(email))
+		if at := strings.Index(local, "@"); at >= 0 {
+			local = local[:at]
+		}
+		if len(local) >= 3 && strings.Contains(lowered, local) {
+			add("email", "must not contain your email address")
+		}
+	}
+
+	for _, denied := range p.DenyList {
+		if lowered == strings.ToLower(strings.TrimSpace(denied)) {
+			add("common_password", "is too common")
+			break
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+
+	return nil
+}
@@ -1,41 +1,117 @@
 package user
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
+	"user-management/database"
+	"user-management/pkg/audit"
+	"user-management/shared/interfaces"
+
+	"github.com/lib/pq"
 )
 
 // Repository defines user repository interface
 type Repository interface {
 	// User CRUD operations
-	Create(user *User) error
-	GetByID(id int) (*User, error)
-	GetByEmail(email string) (*User, error)
-	Update(id int, req *UpdateUserRequest) (*User, error)
-	Delete(id int) error
-	List(limit, offset int) ([]*User, int, error)
+	Create(ctx context.Context, user *User) error
+	CreateWithRole(ctx context.Context, user *User, roleID int) error
+	GetByID(ctx context.Context, id int) (*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	Update(ctx context.Context, id int, req *UpdateUserRequest) (*User, error)
+	UpdatePassword(ctx context.Context, id int, hash string) error
+	Delete(ctx context.Context, id int) error
+	Activate(ctx context.Context, id int) error
+	Erase(ctx context.Context, id int) error
+	List(ctx context.Context, filter *UserFilter) ([]*User, int, error)
+	GetUserStats(ctx context.Context, from, to time.Time) (*UserStats, error)
+	SetOrganization(ctx context.Context, userID, organizationID int) error
+
+	// Organization operations
+	CreateOrganization(ctx context.Context, org *Organization) error
+	GetOrganizationByID(ctx context.Context, id int) (*Organization, error)
+	ListOrganizations(ctx context.Context) ([]*Organization, error)
+
+	// Login tracking operations
+	UpdateLastLogin(ctx context.Context, userID int, at time.Time) error
+	RecordLogin(ctx context.Context, entry *LoginHistory) error
+	ListLoginHistory(ctx context.Context, userID, limit, offset int) ([]*LoginHistory, int, error)
+
+	// Session (refresh token) operations
+	CreateSession(ctx context.Context, session *Session, tokenHash string) error
+	RotateRefreshToken(ctx context.Context, oldHash, newHash string, expiresAt time.Time, client ClientInfo) (*Session, error)
+	ListSessions(ctx context.Context, userID int) ([]*Session, error)
+	RevokeSession(ctx context.Context, userID int, sessionID int64) error
+	RevokeUserSessions(ctx context.Context, userID int) error
+
+	// API key operations
+	CreateAPIKey(ctx context.Context, key *APIKey, keyHash string) error
+	ListAPIKeys(ctx context.Context, userID int) ([]*APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID, keyID int) error
+	UseAPIKey(ctx context.Context, keyHash string) (*APIKey, error)
+
+	// Two-factor operations
+	GetTwoFactorSecret(ctx context.Context, userID int) (string, error)
+	SetTwoFactorSecret(ctx context.Context, userID int, encryptedSecret string) error
+	UseTOTPStep(ctx context.Context, userID int, step int64) error
+	EnableTwoFactor(ctx context.Context, userID int, recoveryCodeHashes []string) error
+	DisableTwoFactor(ctx context.Context, userID int) error
+	ConsumeRecoveryCode(ctx context.Context, userID int, codeHash string) error
+
+	// Password reset operations
+	CreatePasswordReset(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) error
+	FindPasswordReset(ctx context.Context, tokenHash string) (int, error)
+	ResetPassword(ctx context.Context, tokenHash, passwordHash string) (int, error)
+
+	// Email verification operations
+	CreateEmailVerification(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) error
+	HasRecentEmailVerification(ctx context.Context, userID int, since time.Time) (bool, error)
+	VerifyEmail(ctx context.Context, tokenHash string) (int, error)
+
+	// Invitation operations
+	CreateInvitation(ctx context.Context, inv *Invitation, tokenHash string) error
+	FindInvitation(ctx context.Context, tokenHash string) (*Invitation, error)
+	AcceptInvitation(ctx context.Context, tokenHash string, user *User) (*Invitation, error)
 
 	// Role operations
-	GetRoleByID(id int) (*Role, error)
-	GetRoleByName(name string) (*Role, error)
-	ListRoles() ([]*Role, error)
+	GetRoleByID(ctx context.Context, id int) (*Role, error)
+	GetRoleByName(ctx context.Context, name string) (*Role, error)
+	ListRoles(ctx context.Context) ([]*Role, error)
+	CreateRole(ctx context.Context, role *Role) error
+	UpdateRole(ctx context.Context, id int, req *UpdateRoleRequest) (*Role, error)
+	DeactivateRole(ctx context.Context, id int) error
+	CountRoleAssignments(ctx context.Context, roleID int) (int, error)
 
 	// User-Role operations
-	AssignRole(userID, roleID, assignedBy int) error
-	RemoveRole(userID, roleID int) error
-	GetUserRoles(userID int) ([]*Role, error)
-	GetUserWithRoles(userID int) (*User, error)
+	AssignRole(ctx context.Context, userID, roleID, assignedBy int, expiresAt *time.Time) error
+	AssignRoleToUsers(ctx context.Context, roleID int, userIDs []int, assignedBy int) ([]*BulkAssignResult, error)
+	RemoveRole(ctx context.Context, userID, roleID int) error
+	GetUserRoles(ctx context.Context, userID int) ([]*Role, error)
+	GetUserRoleDetails(ctx context.Context, userID int) ([]*UserRoleDetail, error)
+	GetRolesForUsers(ctx context.Context, userIDs []int) (map[int][]*Role, error)
+	GetUserWithRoles(ctx context.Context, userID int) (*User, error)
 
 	// Permission operations
-	GetUserPermissions(userID int) ([]*Permission, error)
-	HasPermission(userID int, resource, action string) (bool, error)
+	ListPermissions(ctx context.Context) ([]*Permission, error)
+	GetPermissionByID(ctx context.Context, id int) (*Permission, error)
+	AddPermissionToRole(ctx context.Context, roleID, permissionID int) error
+	RemovePermissionFromRole(ctx context.Context, roleID, permissionID int) error
+	GetUserPermissions(ctx context.Context, userID int) ([]*Permission, error)
+	HasPermission(ctx context.Context, userID int, resource, action string) (bool, error)
+
+	// WithScope returns a repository whose user lookups are limited to the scope
+	WithScope(scope interfaces.Scope) Repository
 }
 
 // repository implements Repository interface
 type repository struct {
-	db *sql.DB
+	db    *sql.DB
+	scope interfaces.Scope
 }
 
 // NewRepository creates a new user repository
@@ -43,45 +119,134 @@ func NewRepository(db *sql.DB) Repository {
 	return &repository{db: db}
 }
 
+// WithScope returns a copy of the repository limited to the scope
+func (r *repository) WithScope(scope interfaces.Scope) Repository {
+	return &repository{db: r.db, scope: scope}
+}
+
+// orgFilter appends the scoped organization to args and returns an AND
+// condition on column, or "" when the repository is unrestricted
+func (r *repository) orgFilter(column string, args []interface{}) (string, []interface{}) {
+	if !r.scope.Restricted {
+		return "", args
+	}
+	args = append(args, r.scope.OrganizationID)
+	return fmt.Sprintf(" AND %s = $%d", column, len(args)), args
+}
+
 // Schema name constant
 const schema = "user_management"
 
+// userColumns lists the users columns read by scanUser, in scan order
+const userColumns = `id, email, password_hash, name, organization_id, is_active, is_2fa_enabled, must_change_password, email_verified_at,
+	phone, timezone, locale, avatar_url, last_login_at, created_at, updated_at`
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanUser scans a row selected with userColumns
+func scanUser(row rowScanner) (*User, error) {
+	user := &User{}
+	err := row.Scan(
+		&user.ID, &user.Email, &user.PasswordHash, &user.Name, &user.OrganizationID,
+		&user.IsActive, &user.Is2FAEnabled, &user.MustChangePassword, &user.EmailVerifiedAt,
+		&user.Phone, &user.Timezone, &user.Locale, &user.AvatarURL, &user.LastLoginAt, &user.CreatedAt, &user.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
 // Create creates a new user
-func (r *repository) Create(user *User) error {
+func (r *repository) Create(ctx context.Context, user *User) error {
 	query := fmt.Sprintf(`
-		INSERT INTO %s.users (email, password_hash, name, is_active)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO %s.users (email, password_hash, name, organization_id, is_active, email_verified_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
 		RETURNING id, created_at, updated_at
 	`, schema)
 
-	err := r.db.QueryRow(query, user.Email, user.PasswordHash, user.Name, user.IsActive).
+	if user.OrganizationID == 0 {
+		user.OrganizationID = interfaces.DefaultOrganizationID
+	}
+
+	err := r.db.QueryRowContext(ctx, query, user.Email, user.PasswordHash, user.Name, user.OrganizationID, user.IsActive, user.EmailVerifiedAt).
 		Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
-		if strings.Contains(err.Error(), "duplicate key") {
+		if database.IsUniqueViolation(err) {
+			return ErrEmailExists
+		}
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	return nil
+}
+
+// CreateWithRole creates a user and assigns the role in one transaction, so
+// no user is left without a role
+func (r *repository) CreateWithRole(ctx context.Context, user *User, roleID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	userQuery := fmt.Sprintf(`
+		INSERT INTO %s.users (email, password_hash, name, organization_id, is_active, email_verified_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at
+	`, schema)
+
+	if user.OrganizationID == 0 {
+		user.OrganizationID = interfaces.DefaultOrganizationID
+	}
+
+	err = tx.QueryRowContext(ctx, userQuery, user.Email, user.PasswordHash, user.Name, user.OrganizationID, user.IsActive, user.EmailVerifiedAt).
+		Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		if database.IsUniqueViolation(err) {
 			return ErrEmailExists
 		}
 		return fmt.Errorf("failed to create user: %w", err)
 	}
 
+	// Self-registered users assign their own default role
+	roleQuery := fmt.Sprintf(`
+		INSERT INTO %s.user_roles (user_id, role_id, assigned_by)
+		VALUES ($1, $2, $1)
+	`, schema)
+
+	if _, err := tx.ExecContext(ctx, roleQuery, user.ID, roleID); err != nil {
+		if database.IsForeignKeyViolation(err) {
+			return ErrRoleNotFound
+		}
+		return fmt.Errorf("failed to assign role: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return nil
 }
 
 // GetByID retrieves user by ID
-func (r *repository) GetByID(id int) (*User, error) {
+func (r *repository) GetByID(ctx context.Context, id int) (*User, error) {
+	args := []interface{}{id}
+	orgClause, args := r.orgFilter("organization_id", args)
+
 	query := fmt.Sprintf(`
-		SELECT id, email, password_hash, name, is_active, created_at, updated_at
+		SELECT %s
 		FROM %s.users
-		WHERE id = $1
-	`, schema)
+		WHERE id = $1%s
+	`, userColumns, schema, orgClause)
 
-	user := &User{}
-	err := r.db.QueryRow(query, id).Scan(
-		&user.ID, &user.Email, &user.PasswordHash, &user.Name,
-		&user.IsActive, &user.CreatedAt, &user.UpdatedAt,
-	)
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, args...))
 
-	if err == sql.ErrNoRows {
+	if errors.Is(err, sql.ErrNoRows) {
 		return nil, ErrUserNotFound
 	}
 	if err != nil {
@@ -92,20 +257,19 @@ func (r *repository) GetByID(id int) (*User, error) {
 }
 
 // GetByEmail retrieves user by email
-func (r *repository) GetByEmail(email string) (*User, error) {
+func (r *repository) GetByEmail(ctx context.Context, email string) (*User, error) {
+	args := []interface{}{strings.ToLower(email)}
+	orgClause, args := r.orgFilter("organization_id", args)
+
 	query := fmt.Sprintf(`
-		SELECT id, email, password_hash, name, is_active, created_at, updated_at
+		SELECT %s
 		FROM %s.users
-		WHERE email = $1
-	`, schema)
+		WHERE email = $1%s
+	`, userColumns, schema, orgClause)
 
-	user := &User{}
-	err := r.db.QueryRow(query, strings.ToLower(email)).Scan(
-		&user.ID, &user.Email, &user.PasswordHash, &user.Name,
-		&user.IsActive, &user.CreatedAt, &user.UpdatedAt,
-	)
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, args...))
 
-	if err == sql.ErrNoRows {
+	if errors.Is(err, sql.ErrNoRows) {
 		return nil, ErrUserNotFound
 	}
 	if err != nil {
@@ -116,7 +280,7 @@ func (r *repository) GetByEmail(email string) (*User, error) {
 }
 
 // Update updates user information
-func (r *repository) Update(id int, req *UpdateUserRequest) (*User, error) {
+func (r *repository) Update(ctx context.Context, id int, req *UpdateUserRequest) (*User, error) {
 	// Build dynamic query
 	setParts := []string{}
 	args := []interface{}{}
@@ -134,8 +298,38 @@ func (r *repository) Update(id int, req *UpdateUserRequest) (*User, error) {
 		argIndex++
 	}
 
+	if req.MustChangePassword != nil {
+		setParts = append(setParts, fmt.Sprintf("must_change_password = $%d", argIndex))
+		args = append(args, *req.MustChangePassword)
+		argIndex++
+	}
+
+	if req.Phone != nil {
+		setParts = append(setParts, fmt.Sprintf("phone = $%d", argIndex))
+		args = append(args, *req.Phone)
+		argIndex++
+	}
+
+	if req.Timezone != nil {
+		setParts = append(setParts, fmt.Sprintf("timezone = $%d", argIndex))
+		args = append(args, *req.Timezone)
+		argIndex++
+	}
+
+	if req.Locale != nil {
+		setParts = append(setParts, fmt.Sprintf("locale = $%d", argIndex))
+		args = append(args, *req.Locale)
+		argIndex++
+	}
+
+	if req.AvatarURL != nil {
+		setParts = append(setParts, fmt.Sprintf("avatar_url = $%d", argIndex))
+		args = append(args, *req.AvatarURL)
+		argIndex++
+	}
+
 	if len(setParts) == 0 {
-		return r.GetByID(id) // No changes, return current user
+		return r.GetByID(ctx, id) // No changes, return current user
 	}
 
 	// Add updated_at
@@ -150,16 +344,12 @@ func (r *repository) Update(id int, req *UpdateUserRequest) (*User, error) {
 		UPDATE %s.users 
 		SET %s
 		WHERE id = $%d
-		RETURNING id, email, password_hash, name, is_active, created_at, updated_at
-	`, schema, strings.Join(setParts, ", "), argIndex)
+		RETURNING %s
+	`, schema, strings.Join(setParts, ", "), argIndex, userColumns)
 
-	user := &User{}
-	err := r.db.QueryRow(query, args...).Scan(
-		&user.ID, &user.Email, &user.PasswordHash, &user.Name,
-		&user.IsActive, &user.CreatedAt, &user.UpdatedAt,
-	)
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, args...))
 
-	if err == sql.ErrNoRows {
+	if errors.Is(err, sql.ErrNoRows) {
 		return nil, ErrUserNotFound
 	}
 	if err != nil {
@@ -169,74 +359,1190 @@ func (r *repository) Update(id int, req *UpdateUserRequest) (*User, error) {
 	return user, nil
 }
 
+// UpdatePassword replaces the stored password hash for a user and clears
+// any forced password change
+func (r *repository) UpdatePassword(ctx context.Context, id int, hash string) error {
+	query := fmt.Sprintf(`
+		UPDATE %s.users 
+		SET password_hash = $1, must_change_password = false, updated_at = $2
+		WHERE id = $3
+	`, schema)
+
+	result, err := r.db.ExecContext(ctx, query, hash, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
 // Delete soft deletes a user (sets is_active to false)
-func (r *repository) Delete(id int) error {
+func (r *repository) Delete(ctx context.Context, id int) error {
 	query := fmt.Sprintf(`
 		UPDATE %s.users 
 		SET is_active = false, updated_at = $1
 		WHERE id = $2
 	`, schema)
 
-	result, err := r.db.Exec(query, time.Now(), id)
-	if err != nil {
-		return fmt.Errorf("failed to delete user: %w", err)
-	}
+	result, err := r.db.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// Activate reactivates a soft deleted user (sets is_active to true)
+func (r *repository) Activate(ctx context.Context, id int) error {
+	query := fmt.Sprintf(`
+		UPDATE %s.users 
+		SET is_active = true, updated_at = $1
+		WHERE id = $2
+	`, schema)
+
+	result, err := r.db.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to activate user: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// Erase permanently deletes a user. Role assignments, login history and
+// password resets cascade; sensors and assignments made by the user are
+// kept with their reference set to NULL. Audit entries about the user keep
+// only the user ID, and failed logins with the user's email are deleted.
+func (r *repository) Erase(ctx context.Context, id int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var email string
+	emailQuery := fmt.Sprintf("SELECT email FROM %s.users WHERE id = $1 FOR UPDATE", schema)
+	err = tx.QueryRowContext(ctx, emailQuery, id).Scan(&email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to erase user: %w", err)
+	}
+
+	// Strip the personal fields audit entries recorded as changes
+	auditQuery := fmt.Sprintf(`
+		UPDATE %s.audit_logs
+		SET changes = changes - 'name' - 'email' - 'phone' - 'avatar_url'
+		WHERE changes IS NOT NULL
+		  AND ((resource_type = $1 AND resource_id = $2)
+		    OR (resource_type = $3 AND lower(changes->>'email') = $4))
+	`, schema)
+
+	_, err = tx.ExecContext(ctx, auditQuery, audit.ResourceUser, strconv.Itoa(id), audit.ResourceInvitation, strings.ToLower(email))
+	if err != nil {
+		return fmt.Errorf("failed to anonymize audit entries: %w", err)
+	}
+
+	historyQuery := fmt.Sprintf("DELETE FROM %s.login_history WHERE user_id IS NULL AND lower(email) = $1", schema)
+	if _, err := tx.ExecContext(ctx, historyQuery, strings.ToLower(email)); err != nil {
+		return fmt.Errorf("failed to delete login history: %w", err)
+	}
+
+	deleteQuery := fmt.Sprintf("DELETE FROM %s.users WHERE id = $1", schema)
+	if _, err := tx.ExecContext(ctx, deleteQuery, id); err != nil {
+		return fmt.Errorf("failed to erase user: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// GetUserStats aggregates user totals, role counts and a daily signup
+// histogram for the days from..to in one query; the role counts and the
+// histogram are returned as JSON arrays
+func (r *repository) GetUserStats(ctx context.Context, from, to time.Time) (*UserStats, error) {
+	now := time.Now()
+	args := []interface{}{now.AddDate(0, 0, -30), now, from.Format("2006-01-02"), to.Format("2006-01-02")}
+	orgClause, args := r.orgFilter("u.organization_id", args)
+
+	// generate_series fills days without signups with zero
+	query := fmt.Sprintf(`
+		WITH scoped AS (
+			SELECT u.id, u.is_active, u.created_at
+			FROM %s.users u
+			WHERE true%s
+		), roles AS (
+			SELECT r.name, COUNT(DISTINCT ur.user_id) AS count
+			FROM %s.roles r
+			INNER JOIN %s.user_roles ur ON r.id = ur.role_id
+			INNER JOIN scoped s ON s.id = ur.user_id
+			WHERE r.is_active = true
+			  AND (ur.expires_at IS NULL OR ur.expires_at > $2)
+			GROUP BY r.name
+		), signups AS (
+			SELECT d::date AS day, COUNT(s.id) AS count
+			FROM generate_series($3::date, $4::date, interval '1 day') d
+			LEFT JOIN scoped s ON s.created_at::date = d::date
+			GROUP BY d
+		)
+		SELECT COUNT(*),
+		       COUNT(*) FILTER (WHERE is_active),
+		       COUNT(*) FILTER (WHERE created_at >= $1),
+		       (SELECT COALESCE(json_agg(json_build_object('role', name, 'count', count) ORDER BY name), '[]')
+		        FROM roles),
+		       (SELECT COALESCE(json_agg(json_build_object('date', to_char(day, 'YYYY-MM-DD'), 'count', count) ORDER BY day), '[]')
+		        FROM signups)
+		FROM scoped
+	`, schema, orgClause, schema, schema)
+
+	stats := &UserStats{}
+	var roles, signups []byte
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(
+		&stats.TotalUsers, &stats.ActiveUsers, &stats.RecentSignups, &roles, &signups,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user statistics: %w", err)
+	}
+
+	if err := json.Unmarshal(roles, &stats.UsersByRole); err != nil {
+		return nil, fmt.Errorf("failed to decode role counts: %w", err)
+	}
+	if err := json.Unmarshal(signups, &stats.DailySignups); err != nil {
+		return nil, fmt.Errorf("failed to decode daily signups: %w", err)
+	}
+
+	return stats, nil
+}
+
+// userSortColumns maps allowed sort keys to users columns
+var userSortColumns = map[string]string{
+	"created_at": "u.created_at",
+	"name":       "u.name",
+	"email":      "u.email",
+}
+
+// SetOrganization moves a user to another organization
+func (r *repository) SetOrganization(ctx context.Context, userID, organizationID int) error {
+	query := fmt.Sprintf(`
+		UPDATE %s.users
+		SET organization_id = $1, updated_at = $2
+		WHERE id = $3
+	`, schema)
+
+	result, err := r.db.ExecContext(ctx, query, organizationID, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to set user organization: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// List retrieves paginated list of users matching the filter
+func (r *repository) List(ctx context.Context, filter *UserFilter) ([]*User, int, error) {
+	// Build WHERE clause
+	whereParts := []string{}
+	args := []interface{}{}
+	argIndex := 1
+
+	if filter.Query != "" {
+		whereParts = append(whereParts, fmt.Sprintf("(u.email ILIKE $%d OR u.name ILIKE $%d)", argIndex, argIndex))
+		args = append(args, "%"+escapeLike(filter.Query)+"%")
+		argIndex++
+	}
+
+	if filter.IsActive != nil {
+		whereParts = append(whereParts, fmt.Sprintf("u.is_active = $%d", argIndex))
+		args = append(args, *filter.IsActive)
+		argIndex++
+	}
+
+	if filter.Role != "" {
+		whereParts = append(whereParts, fmt.Sprintf(`EXISTS (
+			SELECT 1 FROM %s.user_roles ur
+			INNER JOIN %s.roles r ON r.id = ur.role_id
+			WHERE ur.user_id = u.id AND r.name = $%d
+			  AND (ur.expires_at IS NULL OR ur.expires_at > $%d)
+		)`, schema, schema, argIndex, argIndex+1))
+		args = append(args, filter.Role, time.Now())
+		argIndex += 2
+	}
+
+	if filter.OrganizationID != nil {
+		whereParts = append(whereParts, fmt.Sprintf("u.organization_id = $%d", argIndex))
+		args = append(args, *filter.OrganizationID)
+		argIndex++
+	}
+
+	if r.scope.Restricted {
+		whereParts = append(whereParts, fmt.Sprintf("u.organization_id = $%d", argIndex))
+		args = append(args, r.scope.OrganizationID)
+		argIndex++
+	}
+
+	whereClause := ""
+	if len(whereParts) > 0 {
+		whereClause = "WHERE " + strings.Join(whereParts, " AND ")
+	}
+
+	// Get total count
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s.users u %s", schema, whereClause)
+	var total int
+	err := r.db.QueryRowContext(ctx, countQuery, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	sortColumn, ok := userSortColumns[filter.SortBy]
+	if !ok {
+		sortColumn = userSortColumns["created_at"]
+	}
+	direction := "ASC"
+	if filter.SortDesc {
+		direction = "DESC"
+	}
+
+	// Add limit and offset to args
+	args = append(args, filter.Limit, filter.Offset)
+
+	// Get users
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM %s.users u
+		%s
+		ORDER BY %s %s, u.id %s
+		LIMIT $%d OFFSET $%d
+	`, prefixColumns(userColumns, "u"), schema, whereClause, sortColumn, direction, direction, argIndex, argIndex+1)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []*User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	return users, total, nil
+}
+
+// prefixColumns qualifies a comma separated column list with a table alias
+func prefixColumns(columns, alias string) string {
+	parts := strings.Split(columns, ",")
+	for i, part := range parts {
+		parts[i] = alias + "." + strings.TrimSpace(part)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// escapeLike escapes LIKE wildcards in user supplied search terms
+func escapeLike(term string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+	return replacer.Replace(term)
+}
+
+// CreateOrganization creates a new organization
+func (r *repository) CreateOrganization(ctx context.Context, org *Organization) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s.organizations (name, is_active)
+		VALUES ($1, $2)
+		RETURNING id, created_at, updated_at
+	`, schema)
+
+	err := r.db.QueryRowContext(ctx, query, org.Name, org.IsActive).
+		Scan(&org.ID, &org.CreatedAt, &org.UpdatedAt)
+	if err != nil {
+		if database.IsUniqueViolation(err) {
+			return ErrOrganizationExists
+		}
+		return fmt.Errorf("failed to create organization: %w", err)
+	}
+
+	return nil
+}
+
+// GetOrganizationByID retrieves organization by ID
+func (r *repository) GetOrganizationByID(ctx context.Context, id int) (*Organization, error) {
+	query := fmt.Sprintf(`
+		SELECT id, name, is_active, created_at, updated_at
+		FROM %s.organizations
+		WHERE id = $1
+	`, schema)
+
+	org := &Organization{}
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&org.ID, &org.Name, &org.IsActive, &org.CreatedAt, &org.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrOrganizationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization by ID: %w", err)
+	}
+
+	return org, nil
+}
+
+// ListOrganizations retrieves all active organizations
+func (r *repository) ListOrganizations(ctx context.Context) ([]*Organization, error) {
+	query := fmt.Sprintf(`
+		SELECT id, name, is_active, created_at, updated_at
+		FROM %s.organizations
+		WHERE is_active = true
+		ORDER BY name
+	`, schema)
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+	defer rows.Close()
+
+	orgs := []*Organization{}
+	for rows.Next() {
+		org := &Organization{}
+		err := rows.Scan(&org.ID, &org.Name, &org.IsActive, &org.CreatedAt, &org.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan organization: %w", err)
+		}
+		orgs = append(orgs, org)
+	}
+
+	return orgs, nil
+}
+
+// UpdateLastLogin sets the user's last successful login timestamp
+func (r *repository) UpdateLastLogin(ctx context.Context, userID int, at time.Time) error {
+	query := fmt.Sprintf(`
+		UPDATE %s.users 
+		SET last_login_at = $1
+		WHERE id = $2
+	`, schema)
+
+	if _, err := r.db.ExecContext(ctx, query, at, userID); err != nil {
+		return fmt.Errorf("failed to update last login: %w", err)
+	}
+
+	return nil
+}
+
+// RecordLogin stores a login attempt
+func (r *repository) RecordLogin(ctx context.Context, entry *LoginHistory) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s.login_history (user_id, email, ip_address, user_agent, success, failure_reason)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, schema)
+
+	err := r.db.QueryRowContext(ctx, query,
+		entry.UserID, entry.Email, entry.IPAddress, entry.UserAgent, entry.Success, entry.FailureReason).
+		Scan(&entry.ID, &entry.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record login: %w", err)
+	}
+
+	return nil
+}
+
+// ListLoginHistory retrieves paginated login attempts for a user, newest first
+func (r *repository) ListLoginHistory(ctx context.Context, userID, limit, offset int) ([]*LoginHistory, int, error) {
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s.login_history WHERE user_id = $1", schema)
+	var total int
+	if err := r.db.QueryRowContext(ctx, countQuery, userID).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count login history: %w", err)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, user_id, email, COALESCE(ip_address, ''), COALESCE(user_agent, ''),
+		       success, COALESCE(failure_reason, ''), created_at
+		FROM %s.login_history
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`, schema)
+
+	rows, err := r.db.QueryContext(ctx, query, userID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list login history: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []*LoginHistory{}
+	for rows.Next() {
+		entry := &LoginHistory{}
+		err := rows.Scan(
+			&entry.ID, &entry.UserID, &entry.Email, &entry.IPAddress, &entry.UserAgent,
+			&entry.Success, &entry.FailureReason, &entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan login history: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, total, nil
+}
+
+// CreateSession stores a new refresh token session
+func (r *repository) CreateSession(ctx context.Context, session *Session, tokenHash string) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s.refresh_tokens (user_id, token_hash, ip_address, user_agent, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, schema)
+
+	err := r.db.QueryRowContext(ctx, query,
+		session.UserID, tokenHash, session.IPAddress, session.UserAgent, session.ExpiresAt).
+		Scan(&session.ID, &session.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return nil
+}
+
+// RotateRefreshToken atomically replaces an active refresh token with a new one,
+// so the old token can never be used again
+func (r *repository) RotateRefreshToken(ctx context.Context, oldHash, newHash string, expiresAt time.Time, client ClientInfo) (*Session, error) {
+	query := fmt.Sprintf(`
+		UPDATE %s.refresh_tokens
+		SET token_hash = $1, expires_at = $2, last_used_at = $3, ip_address = $4, user_agent = $5
+		WHERE token_hash = $6 AND revoked_at IS NULL AND expires_at > $3
+		RETURNING id, user_id, COALESCE(ip_address, ''), COALESCE(user_agent, ''),
+		          expires_at, last_used_at, created_at
+	`, schema)
+
+	session := &Session{}
+	err := r.db.QueryRowContext(ctx, query, newHash, expiresAt, time.Now(), client.IPAddress, client.UserAgent, oldHash).Scan(
+		&session.ID, &session.UserID, &session.IPAddress, &session.UserAgent,
+		&session.ExpiresAt, &session.LastUsedAt, &session.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	return session, nil
+}
+
+// ListSessions retrieves active sessions for a user
+func (r *repository) ListSessions(ctx context.Context, userID int) ([]*Session, error) {
+	query := fmt.Sprintf(`
+		SELECT id, user_id, COALESCE(ip_address, ''), COALESCE(user_agent, ''),
+		       expires_at, last_used_at, created_at
+		FROM %s.refresh_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2
+		ORDER BY COALESCE(last_used_at, created_at) DESC
+	`, schema)
+
+	rows, err := r.db.QueryContext(ctx, query, userID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []*Session{}
+	for rows.Next() {
+		session := &Session{}
+		err := rows.Scan(
+			&session.ID, &session.UserID, &session.IPAddress, &session.UserAgent,
+			&session.ExpiresAt, &session.LastUsedAt, &session.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession revokes one of the user's active sessions
+func (r *repository) RevokeSession(ctx context.Context, userID int, sessionID int64) error {
+	query := fmt.Sprintf(`
+		UPDATE %s.refresh_tokens
+		SET revoked_at = $1
+		WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL
+	`, schema)
+
+	result, err := r.db.ExecContext(ctx, query, time.Now(), sessionID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+
+	return nil
+}
+
+// RevokeUserSessions revokes all active sessions of a user
+func (r *repository) RevokeUserSessions(ctx context.Context, userID int) error {
+	query := fmt.Sprintf(`
+		UPDATE %s.refresh_tokens
+		SET revoked_at = $1
+		WHERE user_id = $2 AND revoked_at IS NULL
+	`, schema)
+
+	if _, err := r.db.ExecContext(ctx, query, time.Now(), userID); err != nil {
+		return fmt.Errorf("failed to revoke user sessions: %w", err)
+	}
+
+	return nil
+}
+
+// apiKeyColumns lists the api_keys columns read by scanAPIKey, in scan order
+const apiKeyColumns = `id, user_id, name, key_prefix, scopes, expires_at, last_used_at, created_at`
+
+// scanAPIKey scans a row selected with apiKeyColumns
+func scanAPIKey(row rowScanner) (*APIKey, error) {
+	key := &APIKey{}
+	var scopes []string
+
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, pq.Array(&scopes),
+		&key.ExpiresAt, &key.LastUsedAt, &key.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	key.Scopes = scopes
+	if key.Scopes == nil {
+		key.Scopes = []string{}
+	}
+
+	return key, nil
+}
+
+// CreateAPIKey stores a new API key hash
+func (r *repository) CreateAPIKey(ctx context.Context, key *APIKey, keyHash string) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s.api_keys (user_id, name, key_prefix, key_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, schema)
+
+	err := r.db.QueryRowContext(ctx, query, key.UserID, key.Name, key.Prefix, keyHash, pq.Array(key.Scopes), key.ExpiresAt).
+		Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create api key: %w", err)
+	}
+
+	return nil
+}
+
+// ListAPIKeys returns the user's unrevoked API keys, including expired ones
+func (r *repository) ListAPIKeys(ctx context.Context, userID int) ([]*APIKey, error) {
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM %s.api_keys
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC
+	`, apiKeyColumns, schema)
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []*APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan api key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey revokes one of the user's API keys
+func (r *repository) RevokeAPIKey(ctx context.Context, userID, keyID int) error {
+	query := fmt.Sprintf(`
+		UPDATE %s.api_keys
+		SET revoked_at = $1
+		WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL
+	`, schema)
+
+	result, err := r.db.ExecContext(ctx, query, time.Now(), keyID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+// UseAPIKey records use of an unrevoked, unexpired key and returns it
+func (r *repository) UseAPIKey(ctx context.Context, keyHash string) (*APIKey, error) {
+	query := fmt.Sprintf(`
+		UPDATE %s.api_keys
+		SET last_used_at = $1
+		WHERE key_hash = $2 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > $1)
+		RETURNING %s
+	`, schema, apiKeyColumns)
+
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, query, time.Now(), keyHash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to use api key: %w", err)
+	}
+
+	return key, nil
+}
+
+// GetTwoFactorSecret retrieves the encrypted TOTP secret for a user
+func (r *repository) GetTwoFactorSecret(ctx context.Context, userID int) (string, error) {
+	query := fmt.Sprintf(`
+		SELECT COALESCE(totp_secret, '') FROM %s.users WHERE id = $1
+	`, schema)
+
+	var secret string
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(&secret)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrUserNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get two-factor secret: %w", err)
+	}
+
+	return secret, nil
+}
+
+// SetTwoFactorSecret stores a pending TOTP secret; 2FA stays disabled until verified
+func (r *repository) SetTwoFactorSecret(ctx context.Context, userID int, encryptedSecret string) error {
+	query := fmt.Sprintf(`
+		UPDATE %s.users 
+		SET totp_secret = $1, totp_last_step = NULL, is_2fa_enabled = false, updated_at = $2
+		WHERE id = $3
+	`, schema)
+
+	result, err := r.db.ExecContext(ctx, query, encryptedSecret, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to set two-factor secret: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// UseTOTPStep records step as the last accepted TOTP time step. Steps at or
+// before the last accepted one fail with ErrInvalidTwoFactorCode, so a code
+// cannot be used twice.
+func (r *repository) UseTOTPStep(ctx context.Context, userID int, step int64) error {
+	query := fmt.Sprintf(`
+		UPDATE %s.users
+		SET totp_last_step = $1
+		WHERE id = $2 AND (totp_last_step IS NULL OR totp_last_step < $1)
+	`, schema)
+
+	result, err := r.db.ExecContext(ctx, query, step, userID)
+	if err != nil {
+		return fmt.Errorf("failed to record TOTP step: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrInvalidTwoFactorCode
+	}
+
+	return nil
+}
+
+// EnableTwoFactor enables 2FA and replaces the user's recovery codes
+func (r *repository) EnableTwoFactor(ctx context.Context, userID int, recoveryCodeHashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(`
+		UPDATE %s.users 
+		SET is_2fa_enabled = true, updated_at = $1
+		WHERE id = $2 AND totp_secret IS NOT NULL
+	`, schema)
+
+	result, err := tx.ExecContext(ctx, query, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to enable two-factor: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrTwoFactorNotSetup
+	}
+
+	deleteQuery := fmt.Sprintf("DELETE FROM %s.recovery_codes WHERE user_id = $1", schema)
+	if _, err := tx.ExecContext(ctx, deleteQuery, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	insertQuery := fmt.Sprintf(`
+		INSERT INTO %s.recovery_codes (user_id, code_hash) VALUES ($1, $2)
+	`, schema)
+
+	stmt, err := tx.PrepareContext(ctx, insertQuery)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, hash := range recoveryCodeHashes {
+		if _, err := stmt.ExecContext(ctx, userID, hash); err != nil {
+			return fmt.Errorf("failed to store recovery code: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// DisableTwoFactor disables 2FA and removes the secret and recovery codes
+func (r *repository) DisableTwoFactor(ctx context.Context, userID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(`
+		UPDATE %s.users 
+		SET totp_secret = NULL, totp_last_step = NULL, is_2fa_enabled = false, updated_at = $1
+		WHERE id = $2
+	`, schema)
+
+	result, err := tx.ExecContext(ctx, query, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to disable two-factor: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	deleteQuery := fmt.Sprintf("DELETE FROM %s.recovery_codes WHERE user_id = $1", schema)
+	if _, err := tx.ExecContext(ctx, deleteQuery, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeRecoveryCode marks an unused recovery code as used
+func (r *repository) ConsumeRecoveryCode(ctx context.Context, userID int, codeHash string) error {
+	query := fmt.Sprintf(`
+		UPDATE %s.recovery_codes
+		SET used_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`, schema)
+
+	result, err := r.db.ExecContext(ctx, query, userID, codeHash)
+	if err != nil {
+		return fmt.Errorf("failed to consume recovery code: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrInvalidTwoFactorCode
+	}
+
+	return nil
+}
+
+// CreatePasswordReset stores a new password reset token hash
+func (r *repository) CreatePasswordReset(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s.password_resets (user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)
+	`, schema)
+
+	if _, err := r.db.ExecContext(ctx, query, userID, tokenHash, expiresAt); err != nil {
+		return fmt.Errorf("failed to create password reset: %w", err)
+	}
+
+	return nil
+}
+
+// FindPasswordReset returns the user of a valid reset token without consuming it
+func (r *repository) FindPasswordReset(ctx context.Context, tokenHash string) (int, error) {
+	query := fmt.Sprintf(`
+		SELECT user_id FROM %s.password_resets
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2
+	`, schema)
+
+	var userID int
+	err := r.db.QueryRowContext(ctx, query, tokenHash, time.Now()).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrInvalidReset
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to find password reset: %w", err)
+	}
+
+	return userID, nil
+}
+
+// ResetPassword consumes an unexpired, unused token and sets its user's
+// password hash in one transaction, returning the user ID
+func (r *repository) ResetPassword(ctx context.Context, tokenHash, passwordHash string) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+
+	consumeQuery := fmt.Sprintf(`
+		UPDATE %s.password_resets
+		SET used_at = $1
+		WHERE token_hash = $2 AND used_at IS NULL AND expires_at > $1
+		RETURNING user_id
+	`, schema)
+
+	var userID int
+	err = tx.QueryRowContext(ctx, consumeQuery, now, tokenHash).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrInvalidReset
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to consume password reset: %w", err)
+	}
+
+	passwordQuery := fmt.Sprintf(`
+		UPDATE %s.users
+		SET password_hash = $1, must_change_password = false, updated_at = $2
+		WHERE id = $3
+	`, schema)
+
+	result, err := tx.ExecContext(ctx, passwordQuery, passwordHash, now, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update password: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return 0, ErrUserNotFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return userID, nil
+}
+
+// CreateEmailVerification stores a new email verification token hash
+func (r *repository) CreateEmailVerification(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s.email_verifications (user_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4)
+	`, schema)
+
+	if _, err := r.db.ExecContext(ctx, query, userID, tokenHash, expiresAt, time.Now()); err != nil {
+		return fmt.Errorf("failed to create email verification: %w", err)
+	}
+
+	return nil
+}
+
+// HasRecentEmailVerification checks if a verification token was issued to the user since a time
+func (r *repository) HasRecentEmailVerification(ctx context.Context, userID int, since time.Time) (bool, error) {
+	query := fmt.Sprintf(`
+		SELECT EXISTS (
+			SELECT 1 FROM %s.email_verifications WHERE user_id = $1 AND created_at > $2
+		)
+	`, schema)
+
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, query, userID, since).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check email verifications: %w", err)
+	}
+
+	return exists, nil
+}
+
+// VerifyEmail consumes an unexpired, unused token and marks its user's
+// email as verified in one transaction, returning the user ID
+func (r *repository) VerifyEmail(ctx context.Context, tokenHash string) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+
+	consumeQuery := fmt.Sprintf(`
+		UPDATE %s.email_verifications
+		SET used_at = $1
+		WHERE token_hash = $2 AND used_at IS NULL AND expires_at > $1
+		RETURNING user_id
+	`, schema)
+
+	var userID int
+	err = tx.QueryRowContext(ctx, consumeQuery, now, tokenHash).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrInvalidVerification
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to consume email verification: %w", err)
+	}
+
+	verifyQuery := fmt.Sprintf(`
+		UPDATE %s.users
+		SET email_verified_at = COALESCE(email_verified_at, $1), updated_at = $1
+		WHERE id = $2
+	`, schema)
+
+	if _, err := tx.ExecContext(ctx, verifyQuery, now, userID); err != nil {
+		return 0, fmt.Errorf("failed to mark email verified: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return userID, nil
+}
+
+// CreateInvitation stores a new invitation with its token hash
+func (r *repository) CreateInvitation(ctx context.Context, inv *Invitation, tokenHash string) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s.invitations (email, organization_id, role_ids, token_hash, invited_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, schema)
+
+	err := r.db.QueryRowContext(ctx, query, inv.Email, inv.OrganizationID, pq.Array(toInt64s(inv.RoleIDs)),
+		tokenHash, inv.InvitedBy, inv.ExpiresAt).Scan(&inv.ID, &inv.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create invitation: %w", err)
+	}
+
+	return nil
+}
+
+// FindInvitation returns a pending, unexpired invitation without accepting it
+func (r *repository) FindInvitation(ctx context.Context, tokenHash string) (*Invitation, error) {
+	query := fmt.Sprintf(`
+		SELECT id, email, organization_id, role_ids, invited_by, expires_at, accepted_at, created_at
+		FROM %s.invitations
+		WHERE token_hash = $1 AND accepted_at IS NULL AND expires_at > $2
+	`, schema)
+
+	inv, err := scanInvitation(r.db.QueryRowContext(ctx, query, tokenHash, time.Now()))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidInvitation
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find invitation: %w", err)
+	}
+
+	return inv, nil
+}
+
+// AcceptInvitation marks a pending invitation as accepted and creates the
+// invited user with the invitation's organization and roles in one transaction
+func (r *repository) AcceptInvitation(ctx context.Context, tokenHash string, user *User) (*Invitation, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	acceptQuery := fmt.Sprintf(`
+		UPDATE %s.invitations
+		SET accepted_at = $1
+		WHERE token_hash = $2 AND accepted_at IS NULL AND expires_at > $1
+		RETURNING id, email, organization_id, role_ids, invited_by, expires_at, accepted_at, created_at
+	`, schema)
+
+	inv, err := scanInvitation(tx.QueryRowContext(ctx, acceptQuery, time.Now(), tokenHash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidInvitation
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to accept invitation: %w", err)
+	}
+
+	userQuery := fmt.Sprintf(`
+		INSERT INTO %s.users (email, password_hash, name, organization_id, is_active, email_verified_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at
+	`, schema)
+
+	user.Email = inv.Email
+	user.OrganizationID = inv.OrganizationID
 
-	rowsAffected, err := result.RowsAffected()
+	err = tx.QueryRowContext(ctx, userQuery, user.Email, user.PasswordHash, user.Name, user.OrganizationID, user.IsActive, user.EmailVerifiedAt).
+		Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		if database.IsUniqueViolation(err) {
+			return nil, ErrEmailExists
+		}
+		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
-	if rowsAffected == 0 {
-		return ErrUserNotFound
+	// Invitations without roles fall back to the default user role
+	var roleQuery string
+	args := []interface{}{user.ID, inv.InvitedBy}
+	if len(inv.RoleIDs) > 0 {
+		roleQuery = fmt.Sprintf(`
+			INSERT INTO %s.user_roles (user_id, role_id, assigned_by)
+			SELECT $1, id, $2 FROM %s.roles WHERE id = ANY($3)
+			ON CONFLICT (user_id, role_id) DO NOTHING
+		`, schema, schema)
+		args = append(args, pq.Array(toInt64s(inv.RoleIDs)))
+	} else {
+		roleQuery = fmt.Sprintf(`
+			INSERT INTO %s.user_roles (user_id, role_id, assigned_by)
+			SELECT $1, id, $2 FROM %s.roles WHERE name = 'user'
+			ON CONFLICT (user_id, role_id) DO NOTHING
+		`, schema, schema)
 	}
 
-	return nil
-}
+	if _, err := tx.ExecContext(ctx, roleQuery, args...); err != nil {
+		return nil, fmt.Errorf("failed to assign invitation roles: %w", err)
+	}
 
-// List retrieves paginated list of users
-func (r *repository) List(limit, offset int) ([]*User, int, error) {
-	// Get total count
-	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s.users WHERE is_active = true", schema)
-	var total int
-	err := r.db.QueryRow(countQuery).Scan(&total)
-	if err != nil {
-		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	// Get users
-	query := fmt.Sprintf(`
-		SELECT id, email, password_hash, name, is_active, created_at, updated_at
-		FROM %s.users
-		WHERE is_active = true
-		ORDER BY created_at DESC
-		LIMIT $1 OFFSET $2
-	`, schema)
+	return inv, nil
+}
+
+// scanInvitation scans an invitation row
+func scanInvitation(row rowScanner) (*Invitation, error) {
+	inv := &Invitation{}
+	var roleIDs []int64
 
-	rows, err := r.db.Query(query, limit, offset)
+	err := row.Scan(&inv.ID, &inv.Email, &inv.OrganizationID, pq.Array(&roleIDs),
+		&inv.InvitedBy, &inv.ExpiresAt, &inv.AcceptedAt, &inv.CreatedAt)
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to list users: %w", err)
+		return nil, err
 	}
-	defer rows.Close()
 
-	users := []*User{}
-	for rows.Next() {
-		user := &User{}
-		err := rows.Scan(
-			&user.ID, &user.Email, &user.PasswordHash, &user.Name,
-			&user.IsActive, &user.CreatedAt, &user.UpdatedAt,
-		)
-		if err != nil {
-			return nil, 0, fmt.Errorf("failed to scan user: %w", err)
-		}
-		users = append(users, user)
+	inv.RoleIDs = make([]int, len(roleIDs))
+	for i, id := range roleIDs {
+		inv.RoleIDs[i] = int(id)
 	}
 
-	return users, total, nil
+	return inv, nil
+}
+
+// toInt64s converts IDs for use with pq.Array
+func toInt64s(ids []int) []int64 {
+	out := make([]int64, len(ids))
+	for i, id := range ids {
+		out[i] = int64(id)
+	}
+	return out
 }
 
 // GetRoleByID retrieves role by ID
-func (r *repository) GetRoleByID(id int) (*Role, error) {
+func (r *repository) GetRoleByID(ctx context.Context, id int) (*Role, error) {
 	query := fmt.Sprintf(`
 		SELECT id, name, description, is_active, created_at, updated_at
 		FROM %s.roles
@@ -244,13 +1550,13 @@ func (r *repository) GetRoleByID(id int) (*Role, error) {
 	`, schema)
 
 	role := &Role{}
-	err := r.db.QueryRow(query, id).Scan(
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
 		&role.ID, &role.Name, &role.Description,
 		&role.IsActive, &role.CreatedAt, &role.UpdatedAt,
 	)
 
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("role not found")
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRoleNotFound
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get role by ID: %w", err)
@@ -260,7 +1566,7 @@ func (r *repository) GetRoleByID(id int) (*Role, error) {
 }
 
 // GetRoleByName retrieves role by name
-func (r *repository) GetRoleByName(name string) (*Role, error) {
+func (r *repository) GetRoleByName(ctx context.Context, name string) (*Role, error) {
 	query := fmt.Sprintf(`
 		SELECT id, name, description, is_active, created_at, updated_at
 		FROM %s.roles
@@ -268,13 +1574,13 @@ func (r *repository) GetRoleByName(name string) (*Role, error) {
 	`, schema)
 
 	role := &Role{}
-	err := r.db.QueryRow(query, name).Scan(
+	err := r.db.QueryRowContext(ctx, query, name).Scan(
 		&role.ID, &role.Name, &role.Description,
 		&role.IsActive, &role.CreatedAt, &role.UpdatedAt,
 	)
 
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("role not found")
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRoleNotFound
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get role by name: %w", err)
@@ -284,7 +1590,7 @@ func (r *repository) GetRoleByName(name string) (*Role, error) {
 }
 
 // ListRoles retrieves all active roles
-func (r *repository) ListRoles() ([]*Role, error) {
+func (r *repository) ListRoles(ctx context.Context) ([]*Role, error) {
 	query := fmt.Sprintf(`
 		SELECT id, name, description, is_active, created_at, updated_at
 		FROM %s.roles
@@ -292,7 +1598,7 @@ func (r *repository) ListRoles() ([]*Role, error) {
 		ORDER BY name
 	`, schema)
 
-	rows, err := r.db.Query(query)
+	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list roles: %w", err)
 	}
@@ -314,15 +1620,126 @@ func (r *repository) ListRoles() ([]*Role, error) {
 	return roles, nil
 }
 
-// AssignRole assigns a role to user
-func (r *repository) AssignRole(userID, roleID, assignedBy int) error {
+// CreateRole creates a new role
+func (r *repository) CreateRole(ctx context.Context, role *Role) error {
 	query := fmt.Sprintf(`
-		INSERT INTO %s.user_roles (user_id, role_id, assigned_by)
+		INSERT INTO %s.roles (name, description, is_active)
 		VALUES ($1, $2, $3)
-		ON CONFLICT (user_id, role_id) DO NOTHING
+		RETURNING id, created_at, updated_at
+	`, schema)
+
+	err := r.db.QueryRowContext(ctx, query, role.Name, role.Description, role.IsActive).
+		Scan(&role.ID, &role.CreatedAt, &role.UpdatedAt)
+	if err != nil {
+		if database.IsUniqueViolation(err) {
+			return ErrRoleExists
+		}
+		return fmt.Errorf("failed to create role: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateRole updates role name and description
+func (r *repository) UpdateRole(ctx context.Context, id int, req *UpdateRoleRequest) (*Role, error) {
+	setParts := []string{}
+	args := []interface{}{}
+	argIndex := 1
+
+	if req.Name != nil {
+		setParts = append(setParts, fmt.Sprintf("name = $%d", argIndex))
+		args = append(args, strings.TrimSpace(*req.Name))
+		argIndex++
+	}
+
+	if req.Description != nil {
+		setParts = append(setParts, fmt.Sprintf("description = $%d", argIndex))
+		args = append(args, strings.TrimSpace(*req.Description))
+		argIndex++
+	}
+
+	if len(setParts) == 0 {
+		return r.GetRoleByID(ctx, id)
+	}
+
+	setParts = append(setParts, "updated_at = CURRENT_TIMESTAMP")
+	args = append(args, id)
+
+	query := fmt.Sprintf(`
+		UPDATE %s.roles
+		SET %s
+		WHERE id = $%d AND is_active = true
+		RETURNING id, name, description, is_active, created_at, updated_at
+	`, schema, strings.Join(setParts, ", "), argIndex)
+
+	role := &Role{}
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(
+		&role.ID, &role.Name, &role.Description,
+		&role.IsActive, &role.CreatedAt, &role.UpdatedAt,
+	)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRoleNotFound
+	}
+	if err != nil {
+		if database.IsUniqueViolation(err) {
+			return nil, ErrRoleExists
+		}
+		return nil, fmt.Errorf("failed to update role: %w", err)
+	}
+
+	return role, nil
+}
+
+// DeactivateRole soft deletes a role
+func (r *repository) DeactivateRole(ctx context.Context, id int) error {
+	query := fmt.Sprintf(`
+		UPDATE %s.roles
+		SET is_active = false, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND is_active = true
+	`, schema)
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to deactivate role: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrRoleNotFound
+	}
+
+	return nil
+}
+
+// CountRoleAssignments returns the number of users holding a role
+func (r *repository) CountRoleAssignments(ctx context.Context, roleID int) (int, error) {
+	query := fmt.Sprintf(`
+		SELECT COUNT(*) FROM %s.user_roles WHERE role_id = $1
+	`, schema)
+
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, roleID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count role assignments: %w", err)
+	}
+
+	return count, nil
+}
+
+// AssignRole assigns a role to user until expiresAt, or indefinitely when
+// nil. Reassigning an existing role replaces its expiry.
+func (r *repository) AssignRole(ctx context.Context, userID, roleID, assignedBy int, expiresAt *time.Time) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s.user_roles (user_id, role_id, assigned_by, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, role_id) DO UPDATE SET expires_at = EXCLUDED.expires_at
 	`, schema)
 
-	_, err := r.db.Exec(query, userID, roleID, assignedBy)
+	_, err := r.db.ExecContext(ctx, query, userID, roleID, assignedBy, expiresAt)
 	if err != nil {
 		return fmt.Errorf("failed to assign role: %w", err)
 	}
@@ -330,14 +1747,99 @@ func (r *repository) AssignRole(userID, roleID, assignedBy int) error {
 	return nil
 }
 
+// AssignRoleToUsers assigns a role to many users in a single transaction.
+// Missing users are reported per user instead of failing the batch.
+func (r *repository) AssignRoleToUsers(ctx context.Context, roleID int, userIDs []int, assignedBy int) ([]*BulkAssignResult, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	ids := make([]int64, len(userIDs))
+	for i, id := range userIDs {
+		ids[i] = int64(id)
+	}
+
+	// Resolve which users exist up front
+	args := []interface{}{pq.Array(ids)}
+	orgClause, args := r.orgFilter("organization_id", args)
+
+	existsQuery := fmt.Sprintf(`
+		SELECT id FROM %s.users WHERE id = ANY($1)%s
+	`, schema, orgClause)
+
+	rows, err := tx.QueryContext(ctx, existsQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check users: %w", err)
+	}
+
+	existing := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan user ID: %w", err)
+		}
+		existing[id] = true
+	}
+	rows.Close()
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s.user_roles (user_id, role_id, assigned_by)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, role_id) DO NOTHING
+	`, schema)
+
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	results := make([]*BulkAssignResult, 0, len(userIDs))
+	seen := make(map[int]bool)
+	for _, userID := range userIDs {
+		if seen[userID] {
+			continue
+		}
+		seen[userID] = true
+
+		result := &BulkAssignResult{UserID: userID, Status: AssignStatusUserNotFound}
+		if existing[userID] {
+			res, err := stmt.ExecContext(ctx, userID, roleID, assignedBy)
+			if err != nil {
+				return nil, fmt.Errorf("failed to assign role: %w", err)
+			}
+
+			rowsAffected, err := res.RowsAffected()
+			if err != nil {
+				return nil, fmt.Errorf("failed to get rows affected: %w", err)
+			}
+
+			result.Status = AssignStatusAlreadyAssigned
+			if rowsAffected > 0 {
+				result.Status = AssignStatusAssigned
+			}
+		}
+		results = append(results, result)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return results, nil
+}
+
 // RemoveRole removes a role from user
-func (r *repository) RemoveRole(userID, roleID int) error {
+func (r *repository) RemoveRole(ctx context.Context, userID, roleID int) error {
 	query := fmt.Sprintf(`
 		DELETE FROM %s.user_roles
 		WHERE user_id = $1 AND role_id = $2
 	`, schema)
 
-	result, err := r.db.Exec(query, userID, roleID)
+	result, err := r.db.ExecContext(ctx, query, userID, roleID)
 	if err != nil {
 		return fmt.Errorf("failed to remove role: %w", err)
 	}
@@ -348,23 +1850,24 @@ func (r *repository) RemoveRole(userID, roleID int) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("user role not found")
+		return ErrUserRoleMissing
 	}
 
 	return nil
 }
 
 // GetUserRoles retrieves all roles for a user
-func (r *repository) GetUserRoles(userID int) ([]*Role, error) {
+func (r *repository) GetUserRoles(ctx context.Context, userID int) ([]*Role, error) {
 	query := fmt.Sprintf(`
-		SELECT r.id, r.name, r.description, r.is_active, r.created_at, r.updated_at
+		SELECT r.id, r.name, r.description, r.is_active, r.created_at, r.updated_at, ur.expires_at
 		FROM %s.roles r
 		INNER JOIN %s.user_roles ur ON r.id = ur.role_id
 		WHERE ur.user_id = $1 AND r.is_active = true
+		  AND (ur.expires_at IS NULL OR ur.expires_at > $2)
 		ORDER BY r.name
 	`, schema, schema)
 
-	rows, err := r.db.Query(query, userID)
+	rows, err := r.db.QueryContext(ctx, query, userID, time.Now())
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user roles: %w", err)
 	}
@@ -375,7 +1878,7 @@ func (r *repository) GetUserRoles(userID int) ([]*Role, error) {
 		role := &Role{}
 		err := rows.Scan(
 			&role.ID, &role.Name, &role.Description,
-			&role.IsActive, &role.CreatedAt, &role.UpdatedAt,
+			&role.IsActive, &role.CreatedAt, &role.UpdatedAt, &role.ExpiresAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan role: %w", err)
@@ -386,27 +1889,112 @@ func (r *repository) GetUserRoles(userID int) ([]*Role, error) {
 	return roles, nil
 }
 
+// GetUserRoleDetails retrieves a user's active role assignments along
+// with the name of the user who assigned each role
+func (r *repository) GetUserRoleDetails(ctx context.Context, userID int) ([]*UserRoleDetail, error) {
+	query := fmt.Sprintf(`
+		SELECT r.id, r.name, r.description, r.is_active, r.created_at, r.updated_at, ur.expires_at,
+		       ur.assigned_at, ur.assigned_by, COALESCE(a.name, '')
+		FROM %s.roles r
+		INNER JOIN %s.user_roles ur ON r.id = ur.role_id
+		LEFT JOIN %s.users a ON a.id = ur.assigned_by
+		WHERE ur.user_id = $1 AND r.is_active = true
+		  AND (ur.expires_at IS NULL OR ur.expires_at > $2)
+		ORDER BY r.name
+	`, schema, schema, schema)
+
+	rows, err := r.db.QueryContext(ctx, query, userID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user role details: %w", err)
+	}
+	defer rows.Close()
+
+	details := []*UserRoleDetail{}
+	for rows.Next() {
+		detail := &UserRoleDetail{Role: &Role{}}
+		err := rows.Scan(
+			&detail.Role.ID, &detail.Role.Name, &detail.Role.Description,
+			&detail.Role.IsActive, &detail.Role.CreatedAt, &detail.Role.UpdatedAt, &detail.Role.ExpiresAt,
+			&detail.AssignedAt, &detail.AssignedBy, &detail.AssignedByName,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan role assignment: %w", err)
+		}
+		details = append(details, detail)
+	}
+
+	return details, nil
+}
+
+// GetRolesForUsers retrieves active roles for many users in a single query,
+// keyed by user ID and ordered by role name like GetUserRoles
+func (r *repository) GetRolesForUsers(ctx context.Context, userIDs []int) (map[int][]*Role, error) {
+	rolesByUser := make(map[int][]*Role, len(userIDs))
+	if len(userIDs) == 0 {
+		return rolesByUser, nil
+	}
+
+	query := fmt.Sprintf(`
+		SELECT ur.user_id, r.id, r.name, r.description, r.is_active, r.created_at, r.updated_at, ur.expires_at
+		FROM %s.roles r
+		INNER JOIN %s.user_roles ur ON r.id = ur.role_id
+		WHERE ur.user_id = ANY($1) AND r.is_active = true
+		  AND (ur.expires_at IS NULL OR ur.expires_at > $2)
+		ORDER BY ur.user_id, r.name
+	`, schema, schema)
+
+	ids := make([]int64, len(userIDs))
+	for i, id := range userIDs {
+		ids[i] = int64(id)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(ids), time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get roles for users: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID int
+		role := &Role{}
+		err := rows.Scan(
+			&userID, &role.ID, &role.Name, &role.Description,
+			&role.IsActive, &role.CreatedAt, &role.UpdatedAt, &role.ExpiresAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan role: %w", err)
+		}
+		rolesByUser[userID] = append(rolesByUser[userID], role)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get roles for users: %w", err)
+	}
+
+	return rolesByUser, nil
+}
+
 // GetUserWithRoles retrieves user with their roles and permissions
-func (r *repository) GetUserWithRoles(userID int) (*User, error) {
+func (r *repository) GetUserWithRoles(ctx context.Context, userID int) (*User, error) {
 	// Get user
-	user, err := r.GetByID(userID)
+	user, err := r.GetByID(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
 
 	// Get user roles with permissions
 	query := fmt.Sprintf(`
-		SELECT DISTINCT r.id, r.name, r.description, r.is_active, r.created_at, r.updated_at,
+		SELECT DISTINCT r.id, r.name, r.description, r.is_active, r.created_at, r.updated_at, ur.expires_at,
 		       p.id, p.name, p.description, p.resource, p.action, p.created_at
 		FROM %s.roles r
 		INNER JOIN %s.user_roles ur ON r.id = ur.role_id
 		LEFT JOIN %s.role_permissions rp ON r.id = rp.role_id
 		LEFT JOIN %s.permissions p ON rp.permission_id = p.id
 		WHERE ur.user_id = $1 AND r.is_active = true
+		  AND (ur.expires_at IS NULL OR ur.expires_at > $2)
 		ORDER BY r.name, p.name
 	`, schema, schema, schema, schema)
 
-	rows, err := r.db.Query(query, userID)
+	rows, err := r.db.QueryContext(ctx, query, userID, time.Now())
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user with roles: %w", err)
 	}
@@ -418,12 +2006,12 @@ func (r *repository) GetUserWithRoles(userID int) (*User, error) {
 		var roleID, permID sql.NullInt64
 		var roleName, roleDesc sql.NullString
 		var roleActive sql.NullBool
-		var roleCreated, roleUpdated sql.NullTime
+		var roleCreated, roleUpdated, roleExpires sql.NullTime
 		var permName, permDesc, permResource, permAction sql.NullString
 		var permCreated sql.NullTime
 
 		err := rows.Scan(
-			&roleID, &roleName, &roleDesc, &roleActive, &roleCreated, &roleUpdated,
+			&roleID, &roleName, &roleDesc, &roleActive, &roleCreated, &roleUpdated, &roleExpires,
 			&permID, &permName, &permDesc, &permResource, &permAction, &permCreated,
 		)
 		if err != nil {
@@ -442,6 +2030,9 @@ func (r *repository) GetUserWithRoles(userID int) (*User, error) {
 					UpdatedAt:   roleUpdated.Time,
 					Permissions: []Permission{},
 				}
+				if roleExpires.Valid {
+					role.ExpiresAt = &roleExpires.Time
+				}
 				roleMap[int(roleID.Int64)] = role
 			}
 
@@ -469,8 +2060,102 @@ func (r *repository) GetUserWithRoles(userID int) (*User, error) {
 	return user, nil
 }
 
+// ListPermissions retrieves all permissions
+func (r *repository) ListPermissions(ctx context.Context) ([]*Permission, error) {
+	query := fmt.Sprintf(`
+		SELECT id, name, COALESCE(description, ''), resource, action, created_at
+		FROM %s.permissions
+		ORDER BY resource, action
+	`, schema)
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list permissions: %w", err)
+	}
+	defer rows.Close()
+
+	permissions := []*Permission{}
+	for rows.Next() {
+		perm := &Permission{}
+		err := rows.Scan(
+			&perm.ID, &perm.Name, &perm.Description,
+			&perm.Resource, &perm.Action, &perm.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan permission: %w", err)
+		}
+		permissions = append(permissions, perm)
+	}
+
+	return permissions, nil
+}
+
+// GetPermissionByID retrieves permission by ID
+func (r *repository) GetPermissionByID(ctx context.Context, id int) (*Permission, error) {
+	query := fmt.Sprintf(`
+		SELECT id, name, COALESCE(description, ''), resource, action, created_at
+		FROM %s.permissions
+		WHERE id = $1
+	`, schema)
+
+	perm := &Permission{}
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&perm.ID, &perm.Name, &perm.Description,
+		&perm.Resource, &perm.Action, &perm.CreatedAt,
+	)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPermissionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get permission by ID: %w", err)
+	}
+
+	return perm, nil
+}
+
+// AddPermissionToRole grants a permission to a role
+func (r *repository) AddPermissionToRole(ctx context.Context, roleID, permissionID int) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s.role_permissions (role_id, permission_id)
+		VALUES ($1, $2)
+		ON CONFLICT (role_id, permission_id) DO NOTHING
+	`, schema)
+
+	_, err := r.db.ExecContext(ctx, query, roleID, permissionID)
+	if err != nil {
+		return fmt.Errorf("failed to add permission to role: %w", err)
+	}
+
+	return nil
+}
+
+// RemovePermissionFromRole revokes a permission from a role
+func (r *repository) RemovePermissionFromRole(ctx context.Context, roleID, permissionID int) error {
+	query := fmt.Sprintf(`
+		DELETE FROM %s.role_permissions
+		WHERE role_id = $1 AND permission_id = $2
+	`, schema)
+
+	result, err := r.db.ExecContext(ctx, query, roleID, permissionID)
+	if err != nil {
+		return fmt.Errorf("failed to remove permission from role: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrRolePermissionNotFound
+	}
+
+	return nil
+}
+
 // GetUserPermissions retrieves all permissions for a user
-func (r *repository) GetUserPermissions(userID int) ([]*Permission, error) {
+func (r *repository) GetUserPermissions(ctx context.Context, userID int) ([]*Permission, error) {
 	query := fmt.Sprintf(`
 		SELECT DISTINCT p.id, p.name, p.description, p.resource, p.action, p.created_at
 		FROM %s.permissions p
@@ -478,10 +2163,11 @@ func (r *repository) GetUserPermissions(userID int) ([]*Permission, error) {
 		INNER JOIN %s.roles r ON rp.role_id = r.id
 		INNER JOIN %s.user_roles ur ON r.id = ur.role_id
 		WHERE ur.user_id = $1 AND r.is_active = true
+		  AND (ur.expires_at IS NULL OR ur.expires_at > $2)
 		ORDER BY p.resource, p.action
 	`, schema, schema, schema, schema)
 
-	rows, err := r.db.Query(query, userID)
+	rows, err := r.db.QueryContext(ctx, query, userID, time.Now())
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user permissions: %w", err)
 	}
@@ -503,19 +2189,24 @@ func (r *repository) GetUserPermissions(userID int) ([]*Permission, error) {
 	return permissions, nil
 }
 
-// HasPermission checks if user has specific permission
-func (r *repository) HasPermission(userID int, resource, action string) (bool, error) {
+// HasPermission checks if user has specific permission; a permission
+// with a '*' resource or action matches any resource or action
+func (r *repository) HasPermission(ctx context.Context, userID int, resource, action string) (bool, error) {
 	query := fmt.Sprintf(`
 		SELECT COUNT(*)
 		FROM %s.permissions p
 		INNER JOIN %s.role_permissions rp ON p.id = rp.permission_id
 		INNER JOIN %s.roles r ON rp.role_id = r.id
 		INNER JOIN %s.user_roles ur ON r.id = ur.role_id
-		WHERE ur.user_id = $1 AND p.resource = $2 AND p.action = $3 AND r.is_active = true
+		WHERE ur.user_id = $1
+		  AND (p.resource = $2 OR p.resource = '*')
+		  AND (p.action = $3 OR p.action = '*')
+		  AND r.is_active = true
+		  AND (ur.expires_at IS NULL OR ur.expires_at > $4)
 	`, schema, schema, schema, schema)
 
 	var count int
-	err := r.db.QueryRow(query, userID, resource, action).Scan(&count)
+	err := r.db.QueryRowContext(ctx, query, userID, resource, action, time.Now()).Scan(&count)
 	if err != nil {
 		return false, fmt.Errorf("failed to check permission: %w", err)
 	}
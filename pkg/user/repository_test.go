@@ -0,0 +1,193 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+	"user-management/database/dbtest"
+	"user-management/pkg/sensor"
+)
+
+// newTestUser stores an active user with email
+func newTestUser(t *testing.T, repo Repository, email string) *User {
+	t.Helper()
+
+	user := &User{Email: email, PasswordHash: "hash", Name: "Test User", IsActive: true}
+	if err := repo.Create(context.Background(), user); err != nil {
+		t.Fatalf("failed to create user %s: %v", email, err)
+	}
+	return user
+}
+
+func TestCreateDuplicateEmail(t *testing.T) {
+	repo := NewRepository(dbtest.Migrated(t))
+	newTestUser(t, repo, "duplicate@example.com")
+
+	err := repo.Create(context.Background(), &User{Email: "duplicate@example.com", PasswordHash: "hash", Name: "Other"})
+	if !errors.Is(err, ErrEmailExists) {
+		t.Fatalf("got %v, want ErrEmailExists", err)
+	}
+}
+
+func TestCreateWithRoleDuplicateEmail(t *testing.T) {
+	repo := NewRepository(dbtest.Migrated(t))
+	newTestUser(t, repo, "taken@example.com")
+
+	err := repo.CreateWithRole(context.Background(), &User{Email: "taken@example.com", PasswordHash: "hash", Name: "Other"}, 1)
+	if !errors.Is(err, ErrEmailExists) {
+		t.Fatalf("got %v, want ErrEmailExists", err)
+	}
+}
+
+// TestCreateWithUnknownRole maps the foreign key violation of the role
+// assignment to ErrRoleNotFound and rolls the user back
+func TestCreateWithUnknownRole(t *testing.T) {
+	repo := NewRepository(dbtest.Migrated(t))
+
+	err := repo.CreateWithRole(context.Background(), &User{Email: "norole@example.com", PasswordHash: "hash", Name: "No Role"}, 999999)
+	if !errors.Is(err, ErrRoleNotFound) {
+		t.Fatalf("got %v, want ErrRoleNotFound", err)
+	}
+
+	if _, err := repo.GetByEmail(context.Background(), "norole@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("user was not rolled back: %v", err)
+	}
+}
+
+// newTestService creates a service on repo with the settings NewService
+// requires
+func newTestService(t *testing.T, repo Repository) Service {
+	t.Helper()
+
+	service, err := NewService(repo, nil, Config{JWTSecret: "test-secret", EncryptionKey: "test-encryption-key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service
+}
+
+// roleID returns the ID of a role seeded by the migrations
+func roleID(t *testing.T, db *sql.DB, name string) int {
+	t.Helper()
+
+	var id int
+	if err := db.QueryRow("SELECT id FROM user_management.roles WHERE name = $1", name).Scan(&id); err != nil {
+		t.Fatalf("failed to get role %s: %v", name, err)
+	}
+	return id
+}
+
+// TestEraseKeepsCreatedSensors erases a user while the sensors they
+// created and the roles they assigned stay in place without them
+func TestEraseKeepsCreatedSensors(t *testing.T) {
+	db := dbtest.Migrated(t)
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	erased := newTestUser(t, repo, "erased@example.com")
+	other := newTestUser(t, repo, "other@example.com")
+	if err := repo.AssignRole(ctx, other.ID, roleID(t, db, "user"), erased.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var sensorTypeID int
+	if err := db.QueryRow("SELECT id FROM sensor_data.sensor_types WHERE name = 'temperature'").Scan(&sensorTypeID); err != nil {
+		t.Fatal(err)
+	}
+	sensors := sensor.NewService(sensor.NewRepository(db), sensor.Config{})
+	created, err := sensors.CreateSensor(ctx, &sensor.CreateSensorRequest{
+		DeviceID:     "ERASED-001",
+		Name:         "Erased user's sensor",
+		SensorTypeID: sensorTypeID,
+	}, erased.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service := newTestService(t, repo)
+	if err := service.EraseUser(ctx, erased.ID, &EraseUserRequest{ConfirmEmail: "other@example.com"}); !errors.Is(err, ErrEraseNotConfirm) {
+		t.Fatalf("got %v, want ErrEraseNotConfirm", err)
+	}
+	if err := service.EraseUser(ctx, erased.ID, &EraseUserRequest{ConfirmEmail: " Erased@Example.com "}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.GetByID(ctx, erased.ID); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("got %v, want ErrUserNotFound", err)
+	}
+
+	kept, err := sensors.GetSensor(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("sensor of the erased user: %v", err)
+	}
+	if kept.CreatedBy != 0 {
+		t.Errorf("got created_by %d, want none", kept.CreatedBy)
+	}
+	if _, err := sensors.GetSensorByDeviceID(ctx, "ERASED-001"); err != nil {
+		t.Errorf("sensor of the erased user by device ID: %v", err)
+	}
+
+	roles, err := repo.GetUserRoles(ctx, other.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roles) != 1 {
+		t.Errorf("got %d roles, want the role the erased user assigned", len(roles))
+	}
+}
+
+// TestListUsersRoles loads the same roles for listed users as
+// GetUserRoles does for each of them
+func TestListUsersRoles(t *testing.T) {
+	db := dbtest.Migrated(t)
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	adminRole, userRole := roleID(t, db, "admin"), roleID(t, db, "user")
+	expired := time.Now().Add(-time.Hour)
+
+	both := newTestUser(t, repo, "listroles-both@example.com")
+	member := newTestUser(t, repo, "listroles-member@example.com")
+	lapsed := newTestUser(t, repo, "listroles-lapsed@example.com")
+	newTestUser(t, repo, "listroles-none@example.com")
+	for _, assignment := range []struct {
+		userID, roleID int
+		expiresAt      *time.Time
+	}{
+		{both.ID, adminRole, nil},
+		{both.ID, userRole, nil},
+		{member.ID, userRole, nil},
+		{lapsed.ID, adminRole, &expired},
+		{lapsed.ID, userRole, nil},
+	} {
+		if err := repo.AssignRole(ctx, assignment.userID, assignment.roleID, both.ID, assignment.expiresAt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	users, total, err := newTestService(t, repo).ListUsers(ctx, &UserFilter{Query: "listroles-"}, 1, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 4 {
+		t.Fatalf("got %d users, want 4", total)
+	}
+
+	for _, user := range users {
+		want, err := repo.GetUserRoles(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.Roles == nil || len(user.Roles) != len(want) {
+			t.Errorf("%s: got roles %v, want %d roles", user.Email, user.Roles, len(want))
+			continue
+		}
+		for i, role := range user.Roles {
+			if role.ID != want[i].ID || role.Name != want[i].Name {
+				t.Errorf("%s: got role %d %s, want %s", user.Email, i, role.Name, want[i].Name)
+			}
+		}
+	}
+}
@@ -1,9 +1,13 @@
 package user
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
+	"user-management/shared/interfaces"
 
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -11,379 +15,1805 @@ import (
 // Service defines user service interface
 type Service interface {
 	// Authentication
-	Register(req *CreateUserRequest) (*User, error)
-	Login(req *LoginRequest) (*LoginResponse, error)
+	Register(ctx context.Context, req *CreateUserRequest) (*User, error)
+	CreateInvitation(ctx context.Context, req *CreateInvitationRequest, invitedBy int) (*Invitation, error)
+	AcceptInvitation(ctx context.Context, req *AcceptInvitationRequest) (*User, error)
+	VerifyEmail(ctx context.Context, req *VerifyEmailRequest) error
+	ResendEmailVerification(ctx context.Context, req *ResendVerificationRequest) error
+	Login(ctx context.Context, req *LoginRequest, client ClientInfo) (*LoginResponse, error)
+	RefreshTokens(ctx context.Context, req *RefreshTokenRequest, client ClientInfo) (*LoginResponse, error)
+
+	// Session management
+	ListSessions(ctx context.Context, userID int) ([]*Session, error)
+	RevokeSession(ctx context.Context, userID int, sessionID int64) error
+	CompleteTwoFactorLogin(ctx context.Context, req *TwoFactorLoginRequest, client ClientInfo) (*LoginResponse, error)
+	CompleteForcedPasswordChange(ctx context.Context, req *ForcedPasswordChangeRequest, client ClientInfo) (*LoginResponse, error)
+
+	// API keys
+	CreateAPIKey(ctx context.Context, userID int, req *CreateAPIKeyRequest) (*CreateAPIKeyResponse, error)
+	ListAPIKeys(ctx context.Context, userID int) ([]*APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID, keyID int) error
+	GetUserFromAPIKey(ctx context.Context, key string) (*User, *APIKey, error)
+
+	// Two-factor authentication
+	SetupTwoFactor(ctx context.Context, userID int) (*TwoFactorSetupResponse, error)
+	EnableTwoFactor(ctx context.Context, userID int, req *TwoFactorVerifyRequest) (*TwoFactorVerifyResponse, error)
+	DisableTwoFactor(ctx context.Context, userID int) error
 
 	// User management
-	GetProfile(userID int) (*User, error)
-	UpdateProfile(userID int, req *UpdateUserRequest) (*User, error)
-	GetUser(userID int) (*User, error)
-	ListUsers(page, perPage int) ([]*User, int, error)
-	DeactivateUser(userID int) error
+	GetProfile(ctx context.Context, userID int) (*User, error)
+	UpdateProfile(ctx context.Context, userID int, req *UpdateUserRequest) (*User, error)
+	ChangePassword(ctx context.Context, userID int, req *ChangePasswordRequest) error
+	RequestPasswordReset(ctx context.Context, req *PasswordResetRequest) error
+	ConfirmPasswordReset(ctx context.Context, req *PasswordResetConfirmRequest) error
+	GetUser(ctx context.Context, userID int) (*User, error)
+	GetUserByEmail(ctx context.Context, email string) (*User, error)
+	ListUsers(ctx context.Context, filter *UserFilter, page, perPage int) ([]*User, int, error)
+	GetUserStats(ctx context.Context, from, to time.Time) (*UserStats, error)
+	DeactivateUser(ctx context.Context, userID int) error
+	ActivateUser(ctx context.Context, userID int) error
+	EraseUser(ctx context.Context, userID int, req *EraseUserRequest) error
+	GetLoginHistory(ctx context.Context, userID, page, perPage int) ([]*LoginHistory, int, error)
 
 	// Role management
-	AssignUserRole(userID, roleID, assignedBy int) error
-	RemoveUserRole(userID, roleID int) error
-	GetUserRoles(userID int) ([]*Role, error)
-	ListRoles() ([]*Role, error)
+	AssignUserRole(ctx context.Context, userID, roleID, assignedBy int, expiresAt *time.Time) error
+	BulkAssignRole(ctx context.Context, roleID int, req *BulkAssignRoleRequest, assignedBy int) ([]*BulkAssignResult, error)
+	RemoveUserRole(ctx context.Context, userID, roleID int) error
+	GetUserRoles(ctx context.Context, userID int) ([]*Role, error)
+	GetUserRoleDetails(ctx context.Context, userID int) ([]*UserRoleDetail, error)
+	ListRoles(ctx context.Context) ([]*Role, error)
+	CreateRole(ctx context.Context, req *CreateRoleRequest) (*Role, error)
+	UpdateRole(ctx context.Context, id int, req *UpdateRoleRequest) (*Role, error)
+	DeleteRole(ctx context.Context, id int) error
 
 	// Permission checking
-	HasPermission(userID int, resource, action string) (bool, error)
-	GetUserPermissions(userID int) ([]*Permission, error)
+	HasPermission(ctx context.Context, userID int, resource, action string) (bool, error)
+	GetUserPermissions(ctx context.Context, userID int) ([]*Permission, error)
+
+	// Permission management
+	ListPermissions(ctx context.Context) ([]*Permission, error)
+	AddRolePermission(ctx context.Context, roleID int, req *RolePermissionRequest) error
+	RemoveRolePermission(ctx context.Context, roleID, permissionID int) error
+
+	// Organization management
+	CreateOrganization(ctx context.Context, req *CreateOrganizationRequest) (*Organization, error)
+	ListOrganizations(ctx context.Context) ([]*Organization, error)
+	MoveUserToOrganization(ctx context.Context, userID int, req *MoveUserRequest) error
+
+	// WithScope returns a service whose user management is limited to the scope
+	WithScope(scope interfaces.Scope) Service
 
 	// JWT operations
-	GenerateTokens(user *User) (accessToken, refreshToken string, err error)
+	GenerateTokens(ctx context.Context, user *User, client ClientInfo) (accessToken, refreshToken string, err error)
 	ValidateToken(tokenString string) (*jwt.Token, error)
-	GetUserFromToken(tokenString string) (*User, error)
+	GetUserFromToken(ctx context.Context, tokenString string) (*User, error)
+	JWKS() *JWKSet
+}
+
+// Config holds user service settings
+type Config struct {
+	JWTSecret      string
+	JWTExpiryHours int
+	// RefreshExpiryHours is the refresh token lifetime; 0 uses 7 days
+	RefreshExpiryHours int
+	// JWTPrivateKeyPath enables RS256 signing; HS256 with JWTSecret is used when empty
+	JWTPrivateKeyPath string
+	// JWTPreviousKeyPaths are public keys still accepted during key rotation
+	JWTPreviousKeyPaths []string
+	// EncryptionKey protects TOTP secrets at rest and is required
+	EncryptionKey string
+	// BCryptCost is the cost for new password hashes; 0 uses bcrypt.DefaultCost
+	BCryptCost int
+	// PasswordPolicy is applied on registration, password change and reset
+	PasswordPolicy PasswordPolicy
+	// AuthCache is invalidated when roles, permissions or user status change; nil disables caching
+	AuthCache *AuthCache
+	// RegistrationMode is RegistrationOpen (default) or RegistrationInviteOnly
+	RegistrationMode string
+	// SkipEmailVerification lets new accounts log in without confirming their email
+	SkipEmailVerification bool
 }
 
 // service implements Service interface
 type service struct {
-	repo      Repository
-	jwtSecret string
-	jwtExpiry time.Duration
+	repo       Repository
+	mailer     Mailer
+	keys       *signingKeys
+	jwtExpiry  time.Duration
+	refreshTTL time.Duration
+	secrets    *secretBox
+	bcryptCost int
+	policy     PasswordPolicy
+	authCache  *AuthCache
+	inviteOnly bool
+	skipVerify bool
+	scope      interfaces.Scope
+
+	// challenges counts invalid codes per two-factor login challenge
+	challenges *challengeAttempts
+
+	// defaultRoleID is the role assigned to self-registered users
+	defaultRoleID int
+}
+
+// defaultRoleName is the role every self-registered user receives
+const defaultRoleName = "user"
+
+// passwordResetTTL is how long a password reset token stays valid
+const passwordResetTTL = time.Hour
+
+// invitationTTL is how long an invitation token stays valid
+const invitationTTL = 7 * 24 * time.Hour
+
+// emailVerificationTTL is how long an email verification token stays valid
+const emailVerificationTTL = 48 * time.Hour
+
+// verificationResendInterval is the minimum time between verification emails
+const verificationResendInterval = 2 * time.Minute
+
+// maxStatsRangeDays bounds the signup histogram requested from GetUserStats
+const maxStatsRangeDays = 366
+
+// defaultRefreshTTL is the refresh token lifetime when none is configured
+const defaultRefreshTTL = 7 * 24 * time.Hour
+
+// apiKeyPrefix marks API keys so they are recognizable in configs and logs
+const apiKeyPrefix = "umk_"
+
+// twoFactorTokenTTL is how long a client has to complete a 2FA login
+const twoFactorTokenTTL = 5 * time.Minute
+
+// passwordChangeTokenTTL is how long a client has to complete a forced password change
+const passwordChangeTokenTTL = 10 * time.Minute
+
+// NewService creates a new user service
+func NewService(repo Repository, mailer Mailer, cfg Config) (Service, error) {
+	if mailer == nil {
+		mailer = NewLogMailer()
+	}
+
+	secrets, err := newSecretBox(cfg.EncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize secret encryption: %w", err)
+	}
+
+	keys, err := newSigningKeys(cfg.JWTSecret, cfg.JWTPrivateKeyPath, cfg.JWTPreviousKeyPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshTTL := time.Duration(cfg.RefreshExpiryHours) * time.Hour
+	if refreshTTL <= 0 {
+		refreshTTL = defaultRefreshTTL
+	}
+
+	bcryptCost, err := ResolveBCryptCost(cfg.BCryptCost)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.RegistrationMode {
+	case "", RegistrationOpen, RegistrationInviteOnly:
+	default:
+		return nil, fmt.Errorf("invalid registration mode %q", cfg.RegistrationMode)
+	}
+
+	// Users without a role pass no permission check, so registration
+	// cannot work without the default role
+	defaultRole, err := repo.GetRoleByName(context.Background(), defaultRoleName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load default %q role: %w", defaultRoleName, err)
+	}
+
+	return &service{
+		repo:       repo,
+		mailer:     mailer,
+		keys:       keys,
+		jwtExpiry:  time.Duration(cfg.JWTExpiryHours) * time.Hour,
+		refreshTTL: refreshTTL,
+		secrets:    secrets,
+		bcryptCost: bcryptCost,
+		policy:     cfg.PasswordPolicy,
+		authCache:  cfg.AuthCache,
+		inviteOnly: cfg.RegistrationMode == RegistrationInviteOnly,
+		skipVerify: cfg.SkipEmailVerification,
+		challenges: newChallengeAttempts(),
+
+		defaultRoleID: defaultRole.ID,
+	}, nil
+}
+
+// WithScope returns a copy of the service limited to the scope. Lookups of
+// users outside the scope fail with ErrUserNotFound.
+func (s *service) WithScope(scope interfaces.Scope) Service {
+	scoped := *s
+	scoped.scope = scope
+	scoped.repo = s.repo.WithScope(scope)
+	return &scoped
+}
+
+// checkUserScope returns ErrUserNotFound for users outside the scope
+func (s *service) checkUserScope(ctx context.Context, userID int) error {
+	if !s.scope.Restricted {
+		return nil
+	}
+
+	if _, err := s.repo.GetByID(ctx, userID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// checkGlobalChange prevents scoped admins from changing rows shared by every
+// organization, such as roles and their permissions
+func (s *service) checkGlobalChange() error {
+	if s.scope.Restricted {
+		return ErrSuperAdminOnly
+	}
+	return nil
+}
+
+// checkRoleAssignable prevents scoped admins from granting cross-organization access
+func (s *service) checkRoleAssignable(role *Role) error {
+	if s.scope.Restricted && role.Name == interfaces.SuperAdminRole {
+		return ErrSuperAdminOnly
+	}
+	return nil
+}
+
+// JWTClaims represents JWT claims
+type JWTClaims struct {
+	UserID int    `json:"user_id"`
+	Email  string `json:"email"`
+	Name   string `json:"name"`
+	jwt.RegisteredClaims
+}
+
+// Register creates a new user account
+func (s *service) Register(ctx context.Context, req *CreateUserRequest) (*User, error) {
+	if s.inviteOnly {
+		return nil, ErrRegistrationClosed
+	}
+
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Check if email already exists
+	existingUser, err := s.repo.GetByEmail(ctx, req.Email)
+	if err != nil && !errors.Is(err, ErrUserNotFound) {
+		return nil, fmt.Errorf("failed to check existing user: %w", err)
+	}
+	if existingUser != nil {
+		return nil, ErrEmailExists
+	}
+
+	// Enforce password policy
+	if err := s.policy.Check(req.Password, req.Email); err != nil {
+		return nil, err
+	}
+
+	// Create new user
+	user, err := NewUser(req.Email, req.Password, req.Name, s.bcryptCost)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.skipVerify {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+
+	// Save the user together with the default role
+	if err := s.repo.CreateWithRole(ctx, user, s.defaultRoleID); err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	if !s.skipVerify {
+		s.sendEmailVerification(ctx, user)
+	}
+
+	// Load user with roles for response
+	userWithRoles, err := s.repo.GetUserWithRoles(ctx, user.ID)
+	if err != nil {
+		log.Printf("Warning: failed to load user roles: %v", err)
+		return user, nil
+	}
+
+	return userWithRoles, nil
+}
+
+// sendEmailVerification issues a verification token and mails it to the user.
+// Failures are logged; the user can request another email.
+func (s *service) sendEmailVerification(ctx context.Context, user *User) {
+	token, tokenHash, err := generateToken()
+	if err != nil {
+		log.Printf("Warning: failed to generate verification token for user %d: %v", user.ID, err)
+		return
+	}
+
+	expiresAt := time.Now().Add(emailVerificationTTL)
+	if err := s.repo.CreateEmailVerification(ctx, user.ID, tokenHash, expiresAt); err != nil {
+		log.Printf("Warning: failed to store verification token for user %d: %v", user.ID, err)
+		return
+	}
+
+	body := fmt.Sprintf("Use this token to verify your email address: %s\nIt expires at %s.",
+		token, expiresAt.Format(time.RFC3339))
+	if err := s.mailer.SendMail(user.Email, "Verify your email", body); err != nil {
+		log.Printf("Warning: failed to send verification email to user %d: %v", user.ID, err)
+	}
+}
+
+// VerifyEmail consumes a verification token and marks the email verified
+func (s *service) VerifyEmail(ctx context.Context, req *VerifyEmailRequest) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	if _, err := s.repo.VerifyEmail(ctx, hashToken(strings.TrimSpace(req.Token))); err != nil {
+		if errors.Is(err, ErrInvalidVerification) {
+			return err
+		}
+		return fmt.Errorf("failed to verify email: %w", err)
+	}
+
+	return nil
+}
+
+// ResendEmailVerification mails a new verification token to an unverified
+// account. Unknown, verified and recently mailed accounts are silently
+// skipped so the endpoint reveals nothing about registered emails.
+func (s *service) ResendEmailVerification(ctx context.Context, req *ResendVerificationRequest) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	user, err := s.repo.GetByEmail(ctx, strings.TrimSpace(req.Email))
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if !user.IsActive || user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	recent, err := s.repo.HasRecentEmailVerification(ctx, user.ID, time.Now().Add(-verificationResendInterval))
+	if err != nil {
+		return fmt.Errorf("failed to check recent verifications: %w", err)
+	}
+	if recent {
+		return nil
+	}
+
+	s.sendEmailVerification(ctx, user)
+	return nil
+}
+
+// CreateInvitation stores an invitation into the caller's organization and
+// mails the token to the invitee
+func (s *service) CreateInvitation(ctx context.Context, req *CreateInvitationRequest, invitedBy int) (*Invitation, error) {
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Stored emails are lower case, so the invitation matches the account
+	// NewUser creates on acceptance
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+
+	// Emails are unique across organizations
+	existingUser, err := s.repo.WithScope(interfaces.Scope{}).GetByEmail(ctx, email)
+	if err != nil && !errors.Is(err, ErrUserNotFound) {
+		return nil, fmt.Errorf("failed to check existing user: %w", err)
+	}
+	if existingUser != nil {
+		return nil, ErrEmailExists
+	}
+
+	// Verify roles exist and may be granted by the caller
+	for _, roleID := range req.RoleIDs {
+		role, err := s.repo.GetRoleByID(ctx, roleID)
+		if err != nil {
+			return nil, err
+		}
+		if !role.IsActive {
+			return nil, ErrRoleNotFound
+		}
+		if err := s.checkRoleAssignable(role); err != nil {
+			return nil, err
+		}
+	}
+
+	orgID := interfaces.DefaultOrganizationID
+	if s.scope.Restricted {
+		orgID = s.scope.OrganizationID
+	}
+
+	token, tokenHash, err := generateToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate invitation token: %w", err)
+	}
+
+	inv := &Invitation{
+		Email:          email,
+		OrganizationID: orgID,
+		RoleIDs:        req.RoleIDs,
+		InvitedBy:      &invitedBy,
+		ExpiresAt:      time.Now().Add(invitationTTL),
+	}
+	if inv.RoleIDs == nil {
+		inv.RoleIDs = []int{}
+	}
+
+	if err := s.repo.CreateInvitation(ctx, inv, tokenHash); err != nil {
+		return nil, fmt.Errorf("failed to store invitation: %w", err)
+	}
+
+	body := fmt.Sprintf("You have been invited to create an account. Use this token to register: %s\nIt expires at %s.",
+		token, inv.ExpiresAt.Format(time.RFC3339))
+	if err := s.mailer.SendMail(inv.Email, "Invitation", body); err != nil {
+		log.Printf("Warning: failed to send invitation email for invitation %d: %v", inv.ID, err)
+	}
+
+	return inv, nil
+}
+
+// AcceptInvitation consumes an invitation token and creates the invited user
+func (s *service) AcceptInvitation(ctx context.Context, req *AcceptInvitationRequest) (*User, error) {
+	// Validate request before consuming the token
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	tokenHash := hashToken(strings.TrimSpace(req.Token))
+
+	inv, err := s.repo.FindInvitation(ctx, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+
+	// Enforce password policy
+	if err := s.policy.Check(req.Password, inv.Email); err != nil {
+		return nil, err
+	}
+
+	user, err := NewUser(inv.Email, req.Password, req.Name, s.bcryptCost)
+	if err != nil {
+		return nil, err
+	}
+
+	// Receiving the invitation token proves ownership of the address
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+
+	if _, err := s.repo.AcceptInvitation(ctx, tokenHash, user); err != nil {
+		if errors.Is(err, ErrInvalidInvitation) || errors.Is(err, ErrEmailExists) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to accept invitation: %w", err)
+	}
+
+	// Load user with roles for response
+	userWithRoles, err := s.repo.GetUserWithRoles(ctx, user.ID)
+	if err != nil {
+		log.Printf("Warning: failed to load user roles: %v", err)
+		return user, nil
+	}
+
+	return userWithRoles, nil
+}
+
+// Login authenticates user and returns tokens
+func (s *service) Login(ctx context.Context, req *LoginRequest, client ClientInfo) (*LoginResponse, error) {
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Get user by email
+	user, err := s.repo.GetByEmail(ctx, req.Email)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			s.recordLogin(ctx, nil, req.Email, client, "unknown_email")
+			return nil, ErrInvalidPassword
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	// Check if user is active
+	if !user.IsActive {
+		s.recordLogin(ctx, &user.ID, user.Email, client, "inactive")
+		return nil, ErrInactiveUser
+	}
+
+	// Verify password
+	if err := user.CheckPassword(req.Password); err != nil {
+		s.recordLogin(ctx, &user.ID, user.Email, client, "invalid_password")
+		return nil, ErrInvalidPassword
+	}
+
+	// Unverified accounts are rejected only after the password checks out
+	if !s.skipVerify && user.EmailVerifiedAt == nil {
+		s.recordLogin(ctx, &user.ID, user.Email, client, "email_not_verified")
+		return nil, ErrEmailNotVerified
+	}
+
+	// Accounts with 2FA get a short-lived challenge token instead of tokens
+	if user.Is2FAEnabled {
+		challenge, err := s.generateChallengeToken(user, "2fa", twoFactorTokenTTL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate two-factor token: %w", err)
+		}
+
+		return &LoginResponse{
+			TwoFactorRequired: true,
+			TwoFactorToken:    challenge,
+		}, nil
+	}
+
+	return s.completeLogin(ctx, user, client)
+}
+
+// completeLogin records a successful login and issues a token pair, or a
+// password change token when an admin forced a password change
+func (s *service) completeLogin(ctx context.Context, user *User, client ClientInfo) (*LoginResponse, error) {
+	if user.MustChangePassword {
+		challenge, err := s.generateChallengeToken(user, "password_change", passwordChangeTokenTTL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate password change token: %w", err)
+		}
+		s.recordLogin(ctx, &user.ID, user.Email, client, "password_change_required")
+
+		return &LoginResponse{
+			PasswordChangeRequired: true,
+			PasswordChangeToken:    challenge,
+		}, nil
+	}
+
+	// Track successful login
+	now := time.Now()
+	if err := s.repo.UpdateLastLogin(ctx, user.ID, now); err != nil {
+		log.Printf("Warning: failed to update last login for user %d: %v", user.ID, err)
+	}
+	s.recordLogin(ctx, &user.ID, user.Email, client, "")
+
+	// Load user with roles
+	userWithRoles, err := s.repo.GetUserWithRoles(ctx, user.ID)
+	if err != nil {
+		log.Printf("Warning: failed to load user roles: %v", err)
+		userWithRoles = user
+	}
+	userWithRoles.LastLoginAt = &now
+
+	// Generate tokens
+	accessToken, refreshToken, err := s.GenerateTokens(ctx, userWithRoles, client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate tokens: %w", err)
+	}
+
+	response := &LoginResponse{
+		User:         userWithRoles,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(s.jwtExpiry.Seconds()),
+	}
+
+	return response, nil
+}
+
+// CompleteTwoFactorLogin finishes a login started by Login for a 2FA account
+func (s *service) CompleteTwoFactorLogin(ctx context.Context, req *TwoFactorLoginRequest, client ClientInfo) (*LoginResponse, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	token, err := s.ValidateToken(req.TwoFactorToken)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	claims, ok := token.Claims.(*JWTClaims)
+	if !ok || !strings.HasPrefix(claims.Subject, "2fa:") || claims.ID == "" || claims.ExpiresAt == nil {
+		return nil, ErrInvalidToken
+	}
+	if err := s.challenges.check(claims.ID); err != nil {
+		return nil, err
+	}
+
+	user, err := s.repo.GetByID(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if !user.IsActive {
+		s.recordLogin(ctx, &user.ID, user.Email, client, "inactive")
+		return nil, ErrInactiveUser
+	}
+
+	if !user.Is2FAEnabled {
+		return nil, ErrInvalidToken
+	}
+
+	if err := s.checkTwoFactorCode(ctx, user.ID, req.Code); err != nil {
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			s.recordLogin(ctx, &user.ID, user.Email, client, "invalid_2fa_code")
+			if s.challenges.fail(claims.ID, claims.ExpiresAt.Time) {
+				return nil, ErrTooManyTwoFactorCodes
+			}
+		}
+		return nil, err
+	}
+	s.challenges.complete(claims.ID, claims.ExpiresAt.Time)
+
+	return s.completeLogin(ctx, user, client)
+}
+
+// CompleteForcedPasswordChange sets a new password for a user whose login
+// required a password change and then completes the login
+func (s *service) CompleteForcedPasswordChange(ctx context.Context, req *ForcedPasswordChangeRequest, client ClientInfo) (*LoginResponse, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	token, err := s.ValidateToken(req.PasswordChangeToken)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	claims, ok := token.Claims.(*JWTClaims)
+	if !ok || !strings.HasPrefix(claims.Subject, "password_change:") {
+		return nil, ErrInvalidToken
+	}
+
+	user, err := s.repo.GetByID(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if !user.IsActive {
+		return nil, ErrInactiveUser
+	}
+
+	// The flag is cleared once the password changes, so each token works once
+	if !user.MustChangePassword {
+		return nil, ErrInvalidToken
+	}
+
+	if user.CheckPassword(req.NewPassword) == nil {
+		return nil, ErrPasswordReused
+	}
+
+	if err := s.policy.Check(req.NewPassword, user.Email); err != nil {
+		return nil, err
+	}
+
+	if err := user.HashPassword(req.NewPassword, s.bcryptCost); err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	if err := s.repo.UpdatePassword(ctx, user.ID, user.PasswordHash); err != nil {
+		return nil, fmt.Errorf("failed to change password: %w", err)
+	}
+	user.MustChangePassword = false
+	s.authCache.invalidateUser(user.ID)
+
+	return s.completeLogin(ctx, user, client)
+}
+
+// checkTwoFactorCode accepts a current TOTP code that was not used before
+// or an unused recovery code
+func (s *service) checkTwoFactorCode(ctx context.Context, userID int, code string) error {
+	secret, err := s.loadTwoFactorSecret(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if step, ok := validateTOTP(secret, code, time.Now()); ok {
+		if err := s.repo.UseTOTPStep(ctx, userID, step); err != nil {
+			if errors.Is(err, ErrInvalidTwoFactorCode) {
+				return err
+			}
+			return fmt.Errorf("failed to record two-factor code: %w", err)
+		}
+		return nil
+	}
+
+	if err := s.repo.ConsumeRecoveryCode(ctx, userID, hashRecoveryCode(code)); err != nil {
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			return err
+		}
+		return fmt.Errorf("failed to check recovery code: %w", err)
+	}
+
+	return nil
+}
+
+// loadTwoFactorSecret returns the decrypted TOTP secret for a user
+func (s *service) loadTwoFactorSecret(ctx context.Context, userID int) (string, error) {
+	encrypted, err := s.repo.GetTwoFactorSecret(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	if encrypted == "" {
+		return "", ErrTwoFactorNotSetup
+	}
+
+	secret, err := s.secrets.Open(encrypted)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt two-factor secret: %w", err)
+	}
+
+	return secret, nil
+}
+
+// SetupTwoFactor generates a new pending TOTP secret for the user
+func (s *service) SetupTwoFactor(ctx context.Context, userID int) (*TwoFactorSetupResponse, error) {
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Is2FAEnabled {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate two-factor secret: %w", err)
+	}
+
+	encrypted, err := s.secrets.Seal(secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt two-factor secret: %w", err)
+	}
+
+	if err := s.repo.SetTwoFactorSecret(ctx, userID, encrypted); err != nil {
+		return nil, fmt.Errorf("failed to store two-factor secret: %w", err)
+	}
+
+	return &TwoFactorSetupResponse{
+		Secret:     secret,
+		OTPAuthURL: totpURL(user.Email, secret),
+	}, nil
+}
+
+// EnableTwoFactor confirms enrollment with a first code and returns recovery codes
+func (s *service) EnableTwoFactor(ctx context.Context, userID int, req *TwoFactorVerifyRequest) (*TwoFactorVerifyResponse, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Is2FAEnabled {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	secret, err := s.loadTwoFactorSecret(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	step, ok := validateTOTP(secret, req.Code, time.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+	if err := s.repo.UseTOTPStep(ctx, userID, step); err != nil {
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to record two-factor code: %w", err)
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate recovery codes: %w", err)
+	}
+
+	if err := s.repo.EnableTwoFactor(ctx, userID, hashes); err != nil {
+		if errors.Is(err, ErrTwoFactorNotSetup) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to enable two-factor: %w", err)
+	}
+
+	return &TwoFactorVerifyResponse{RecoveryCodes: codes}, nil
+}
+
+// DisableTwoFactor turns off 2FA for a user, e.g. when they are locked out
+func (s *service) DisableTwoFactor(ctx context.Context, userID int) error {
+	if err := s.checkUserScope(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := s.repo.DisableTwoFactor(ctx, userID); err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to disable two-factor: %w", err)
+	}
+
+	return nil
+}
+
+// generateChallengeToken issues a short-lived token for a follow-up login
+// step. The subject purpose keeps it from being used as an access token and
+// the random ID identifies the challenge for attempt counting.
+func (s *service) generateChallengeToken(user *User, purpose string, ttl time.Duration) (string, error) {
+	challengeID, _, err := generateToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate challenge ID: %w", err)
+	}
+
+	claims := &JWTClaims{
+		UserID: user.ID,
+		Email:  user.Email,
+		Name:   user.Name,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+			Issuer:    "user-management-api",
+			Subject:   fmt.Sprintf("%s:%d", purpose, user.ID),
+			ID:        challengeID,
+		},
+	}
+
+	return s.keys.sign(claims)
+}
+
+// recordLogin stores a login attempt; an empty failure reason marks success.
+// Failures to record are logged and never fail the login itself.
+func (s *service) recordLogin(ctx context.Context, userID *int, email string, client ClientInfo, failureReason string) {
+	entry := &LoginHistory{
+		UserID:        userID,
+		Email:         strings.ToLower(strings.TrimSpace(email)),
+		IPAddress:     client.IPAddress,
+		UserAgent:     client.UserAgent,
+		Success:       failureReason == "",
+		FailureReason: failureReason,
+	}
+
+	if err := s.repo.RecordLogin(ctx, entry); err != nil {
+		log.Printf("Warning: failed to record login attempt for %s: %v", entry.Email, err)
+	}
+}
+
+// RefreshTokens exchanges a valid refresh token for a new token pair.
+// Every use rotates the refresh token; the old one stops working.
+func (s *service) RefreshTokens(ctx context.Context, req *RefreshTokenRequest, client ClientInfo) (*LoginResponse, error) {
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	newToken, newHash, err := generateToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	session, err := s.repo.RotateRefreshToken(ctx, hashToken(strings.TrimSpace(req.RefreshToken)), newHash,
+		time.Now().Add(s.refreshTTL), client)
+	if err != nil {
+		return nil, err
+	}
+
+	// Load current user state
+	user, err := s.repo.GetUserWithRoles(ctx, session.UserID)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	// Check if user is still active
+	if !user.IsActive {
+		if err := s.repo.RevokeUserSessions(ctx, user.ID); err != nil {
+			log.Printf("Warning: failed to revoke sessions for user %d: %v", user.ID, err)
+		}
+		return nil, ErrInactiveUser
+	}
+
+	if user.MustChangePassword {
+		return nil, ErrPasswordChangeRequired
+	}
+
+	accessToken, err := s.signAccessToken(user)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate tokens: %w", err)
+	}
+
+	response := &LoginResponse{
+		User:         user,
+		AccessToken:  accessToken,
+		RefreshToken: newToken,
+		ExpiresIn:    int(s.jwtExpiry.Seconds()),
+	}
+
+	return response, nil
+}
+
+// ListSessions returns the user's active sessions
+func (s *service) ListSessions(ctx context.Context, userID int) ([]*Session, error) {
+	sessions, err := s.repo.ListSessions(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession revokes one of the user's sessions
+func (s *service) RevokeSession(ctx context.Context, userID int, sessionID int64) error {
+	if err := s.repo.RevokeSession(ctx, userID, sessionID); err != nil {
+		if errors.Is(err, ErrSessionNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	return nil
+}
+
+// CreateAPIKey issues an API key limited to a subset of the user's permissions
+func (s *service) CreateAPIKey(ctx context.Context, userID int, req *CreateAPIKeyRequest) (*CreateAPIKeyResponse, error) {
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	// A key can never grant more than its owner has
+	for _, scope := range req.Scopes {
+		resource, action, _ := parseScope(scope)
+		allowed, err := s.repo.HasPermission(ctx, userID, resource, action)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check permission: %w", err)
+		}
+		if !allowed {
+			return nil, ErrAPIKeyScopeDenied
+		}
+	}
+
+	token, _, err := generateToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate api key: %w", err)
+	}
+	plainKey := apiKeyPrefix + token
+
+	key := &APIKey{
+		UserID:    userID,
+		Name:      strings.TrimSpace(req.Name),
+		Prefix:    plainKey[:len(apiKeyPrefix)+8],
+		Scopes:    req.Scopes,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if key.Scopes == nil {
+		key.Scopes = []string{}
+	}
+
+	if err := s.repo.CreateAPIKey(ctx, key, hashToken(plainKey)); err != nil {
+		return nil, fmt.Errorf("failed to store api key: %w", err)
+	}
+
+	return &CreateAPIKeyResponse{Key: plainKey, APIKey: key}, nil
+}
+
+// ListAPIKeys returns the user's API keys without their secrets
+func (s *service) ListAPIKeys(ctx context.Context, userID int) ([]*APIKey, error) {
+	keys, err := s.repo.ListAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey revokes one of the user's API keys
+func (s *service) RevokeAPIKey(ctx context.Context, userID, keyID int) error {
+	if err := s.repo.RevokeAPIKey(ctx, userID, keyID); err != nil {
+		if errors.Is(err, ErrAPIKeyNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+
+	return nil
+}
+
+// GetUserFromAPIKey resolves an API key to its active owner
+func (s *service) GetUserFromAPIKey(ctx context.Context, plainKey string) (*User, *APIKey, error) {
+	if !strings.HasPrefix(plainKey, apiKeyPrefix) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	key, err := s.repo.UseAPIKey(ctx, hashToken(plainKey))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := s.repo.GetUserWithRoles(ctx, key.UserID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get user from api key: %w", err)
+	}
+
+	if !user.IsActive {
+		return nil, nil, ErrInactiveUser
+	}
+
+	// API keys stop working with the user's tokens until the forced
+	// password change is made
+	if user.MustChangePassword {
+		return nil, nil, ErrPasswordChangeRequired
+	}
+
+	return user, key, nil
+}
+
+// GetProfile returns user profile with roles and permissions
+func (s *service) GetProfile(ctx context.Context, userID int) (*User, error) {
+	user, err := s.repo.GetUserWithRoles(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user profile: %w", err)
+	}
+
+	return user, nil
+}
+
+// UpdateProfile updates user profile
+func (s *service) UpdateProfile(ctx context.Context, userID int, req *UpdateUserRequest) (*User, error) {
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkUserScope(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	// Update user
+	user, err := s.repo.Update(ctx, userID, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update profile: %w", err)
+	}
+	s.authCache.invalidateUser(userID)
+
+	// Existing sessions must not outlive a forced password change
+	if req.MustChangePassword != nil && *req.MustChangePassword {
+		if err := s.repo.RevokeUserSessions(ctx, userID); err != nil {
+			log.Printf("Warning: failed to revoke sessions for user %d: %v", userID, err)
+		}
+	}
+
+	// Load with roles
+	userWithRoles, err := s.repo.GetUserWithRoles(ctx, user.ID)
+	if err != nil {
+		log.Printf("Warning: failed to load user roles: %v", err)
+		return user, nil
+	}
+
+	return userWithRoles, nil
+}
+
+// ChangePassword verifies the current password and stores a new one
+func (s *service) ChangePassword(ctx context.Context, userID int, req *ChangePasswordRequest) error {
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	// Verify current password
+	if err := user.CheckPassword(req.CurrentPassword); err != nil {
+		return ErrInvalidPassword
+	}
+
+	if err := s.policy.Check(req.NewPassword, user.Email); err != nil {
+		return err
+	}
+
+	// Hash and persist new password
+	if err := user.HashPassword(req.NewPassword, s.bcryptCost); err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	if err := s.repo.UpdatePassword(ctx, user.ID, user.PasswordHash); err != nil {
+		return fmt.Errorf("failed to change password: %w", err)
+	}
+
+	return nil
+}
+
+// RequestPasswordReset issues a reset token if the email belongs to an active user.
+// It never reveals whether the email exists.
+func (s *service) RequestPasswordReset(ctx context.Context, req *PasswordResetRequest) error {
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	user, err := s.repo.GetByEmail(ctx, strings.TrimSpace(req.Email))
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if !user.IsActive {
+		return nil
+	}
+
+	token, tokenHash, err := generateToken()
+	if err != nil {
+		return fmt.Errorf("failed to generate reset token: %w", err)
+	}
+
+	expiresAt := time.Now().Add(passwordResetTTL)
+	if err := s.repo.CreatePasswordReset(ctx, user.ID, tokenHash, expiresAt); err != nil {
+		return fmt.Errorf("failed to store reset token: %w", err)
+	}
+
+	body := fmt.Sprintf("Use this token to reset your password: %s\nIt expires at %s.",
+		token, expiresAt.Format(time.RFC3339))
+	if err := s.mailer.SendMail(user.Email, "Password reset", body); err != nil {
+		log.Printf("Warning: failed to send password reset email to user %d: %v", user.ID, err)
+	}
+
+	return nil
+}
+
+// ConfirmPasswordReset consumes a reset token and sets a new password
+func (s *service) ConfirmPasswordReset(ctx context.Context, req *PasswordResetConfirmRequest) error {
+	// Validate request before consuming the token
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	tokenHash := hashToken(strings.TrimSpace(req.Token))
+
+	// Check the new password before the token is consumed so a rejected
+	// password does not burn the reset link
+	userID, err := s.repo.FindPasswordReset(ctx, tokenHash)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.policy.Check(req.NewPassword, user.Email); err != nil {
+		return err
+	}
+
+	if err := user.HashPassword(req.NewPassword, s.bcryptCost); err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	// The token is consumed with the password update so a failed update
+	// leaves the reset link usable, and a concurrent reset with the same
+	// token fails
+	if _, err := s.repo.ResetPassword(ctx, tokenHash, user.PasswordHash); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetUser returns user by ID (admin function)
+func (s *service) GetUser(ctx context.Context, userID int) (*User, error) {
+	user, err := s.repo.GetUserWithRoles(ctx, userID)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return user, nil
+}
+
+// GetUserByEmail returns user with roles by email (admin function)
+func (s *service) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if err := validateEmail(email); err != nil {
+		return nil, err
+	}
+
+	user, err := s.repo.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	userWithRoles, err := s.repo.GetUserWithRoles(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return userWithRoles, nil
+}
+
+// ListUsers returns paginated list of users matching the filter
+func (s *service) ListUsers(ctx context.Context, filter *UserFilter, page, perPage int) ([]*User, int, error) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > 100 {
+		perPage = 20
+	}
+
+	filter.Limit = perPage
+	filter.Offset = (page - 1) * perPage
+
+	users, total, err := s.repo.List(ctx, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	// Load roles for all listed users in one query
+	userIDs := make([]int, len(users))
+	for i, user := range users {
+		userIDs[i] = user.ID
+	}
+
+	rolesByUser, err := s.repo.GetRolesForUsers(ctx, userIDs)
+	if err != nil {
+		log.Printf("Warning: failed to load roles for users: %v", err)
+		return users, total, nil
+	}
+
+	for _, user := range users {
+		roles := rolesByUser[user.ID]
+
+		// Convert []*Role to []Role
+		user.Roles = make([]Role, len(roles))
+		for i, role := range roles {
+			user.Roles[i] = *role
+		}
+	}
+
+	return users, total, nil
+}
+
+// GetUserStats returns user counts and a daily signup histogram for from..to
+func (s *service) GetUserStats(ctx context.Context, from, to time.Time) (*UserStats, error) {
+	if to.Before(from) || to.Sub(from) > maxStatsRangeDays*24*time.Hour {
+		return nil, ErrInvalidStatsRange
+	}
+
+	stats, err := s.repo.GetUserStats(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+// DeactivateUser deactivates a user account
+func (s *service) DeactivateUser(ctx context.Context, userID int) error {
+	if err := s.checkUserScope(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := s.repo.Delete(ctx, userID); err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to deactivate user: %w", err)
+	}
+	s.authCache.invalidateUser(userID)
+
+	// Deactivated users must not be able to refresh their way back in
+	if err := s.repo.RevokeUserSessions(ctx, userID); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	return nil
+}
+
+// ActivateUser reactivates a user. Activating an active user is a no-op.
+func (s *service) ActivateUser(ctx context.Context, userID int) error {
+	if err := s.checkUserScope(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := s.repo.Activate(ctx, userID); err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to activate user: %w", err)
+	}
+	s.authCache.invalidateUser(userID)
+
+	return nil
 }
 
-// NewService creates a new user service
-func NewService(repo Repository, jwtSecret string, jwtExpiryHours int) Service {
-	return &service{
-		repo:      repo,
-		jwtSecret: jwtSecret,
-		jwtExpiry: time.Duration(jwtExpiryHours) * time.Hour,
+// EraseUser permanently deletes a user after the request confirms their email
+func (s *service) EraseUser(ctx context.Context, userID int, req *EraseUserRequest) error {
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return err
 	}
-}
 
-// JWTClaims represents JWT claims
-type JWTClaims struct {
-	UserID int    `json:"user_id"`
-	Email  string `json:"email"`
-	Name   string `json:"name"`
-	jwt.RegisteredClaims
-}
+	if !strings.EqualFold(strings.TrimSpace(req.ConfirmEmail), user.Email) {
+		return ErrEraseNotConfirm
+	}
 
-// Register creates a new user account
-func (s *service) Register(req *CreateUserRequest) (*User, error) {
-	// Validate request
-	if err := req.Validate(); err != nil {
-		return nil, err
+	if err := s.repo.Erase(ctx, userID); err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to erase user: %w", err)
 	}
+	s.authCache.invalidateUser(userID)
 
-	// Check if email already exists
-	existingUser, err := s.repo.GetByEmail(req.Email)
-	if err != nil && err != ErrUserNotFound {
-		return nil, fmt.Errorf("failed to check existing user: %w", err)
+	return nil
+}
+
+// GetLoginHistory returns paginated login attempts for a user
+func (s *service) GetLoginHistory(ctx context.Context, userID, page, perPage int) ([]*LoginHistory, int, error) {
+	if page < 1 {
+		page = 1
 	}
-	if existingUser != nil {
-		return nil, ErrEmailExists
+	if perPage < 1 || perPage > 100 {
+		perPage = 20
 	}
 
-	// Create new user
-	user, err := NewUser(req.Email, req.Password, req.Name)
+	// Verify user exists
+	if _, err := s.repo.GetByID(ctx, userID); err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * perPage
+
+	entries, total, err := s.repo.ListLoginHistory(ctx, userID, perPage, offset)
 	if err != nil {
-		return nil, err
+		return nil, 0, fmt.Errorf("failed to get login history: %w", err)
 	}
 
-	// Save to database
-	if err := s.repo.Create(user); err != nil {
-		return nil, fmt.Errorf("failed to create user: %w", err)
+	return entries, total, nil
+}
+
+// AssignUserRole assigns a role to user, optionally until expiresAt
+func (s *service) AssignUserRole(ctx context.Context, userID, roleID, assignedBy int, expiresAt *time.Time) error {
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return ErrRoleExpiryPast
 	}
 
-	// Assign default "user" role
-	userRole, err := s.repo.GetRoleByName("user")
+	// Verify user exists
+	_, err := s.repo.GetByID(ctx, userID)
 	if err != nil {
-		log.Printf("Warning: failed to get default user role: %v", err)
-	} else {
-		if err := s.repo.AssignRole(user.ID, userRole.ID, user.ID); err != nil {
-			log.Printf("Warning: failed to assign default role: %v", err)
-		}
+		return fmt.Errorf("user not found: %w", err)
 	}
 
-	// Load user with roles for response
-	userWithRoles, err := s.repo.GetUserWithRoles(user.ID)
+	// Verify role exists
+	role, err := s.repo.GetRoleByID(ctx, roleID)
 	if err != nil {
-		log.Printf("Warning: failed to load user roles: %v", err)
-		return user, nil
+		return fmt.Errorf("role not found: %w", err)
 	}
 
-	return userWithRoles, nil
+	if err := s.checkRoleAssignable(role); err != nil {
+		return err
+	}
+
+	// Assign role
+	if err := s.repo.AssignRole(ctx, userID, roleID, assignedBy, expiresAt); err != nil {
+		return fmt.Errorf("failed to assign role: %w", err)
+	}
+	s.authCache.invalidateUser(userID)
+
+	return nil
 }
 
-// Login authenticates user and returns tokens
-func (s *service) Login(req *LoginRequest) (*LoginResponse, error) {
-	// Validate request
+// BulkAssignRole assigns a role to many users and reports the outcome per user
+func (s *service) BulkAssignRole(ctx context.Context, roleID int, req *BulkAssignRoleRequest, assignedBy int) ([]*BulkAssignResult, error) {
 	if err := req.Validate(); err != nil {
 		return nil, err
 	}
 
-	// Get user by email
-	user, err := s.repo.GetByEmail(req.Email)
+	role, err := s.repo.GetRoleByID(ctx, roleID)
 	if err != nil {
-		if err == ErrUserNotFound {
-			return nil, ErrInvalidPassword
+		return nil, err
+	}
+	if !role.IsActive {
+		return nil, ErrRoleNotFound
+	}
+
+	if err := s.checkRoleAssignable(role); err != nil {
+		return nil, err
+	}
+
+	results, err := s.repo.AssignRoleToUsers(ctx, roleID, req.UserIDs, assignedBy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to assign role: %w", err)
+	}
+
+	for _, result := range results {
+		if result.Status == AssignStatusAssigned {
+			s.authCache.invalidateUser(result.UserID)
 		}
-		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
-	// Check if user is active
-	if !user.IsActive {
-		return nil, ErrInactiveUser
+	return results, nil
+}
+
+// RemoveUserRole removes a role from user
+func (s *service) RemoveUserRole(ctx context.Context, userID, roleID int) error {
+	if err := s.checkUserScope(ctx, userID); err != nil {
+		return err
 	}
 
-	// Verify password
-	if err := user.CheckPassword(req.Password); err != nil {
-		return nil, ErrInvalidPassword
+	if err := s.repo.RemoveRole(ctx, userID, roleID); err != nil {
+		return fmt.Errorf("failed to remove role: %w", err)
 	}
+	s.authCache.invalidateUser(userID)
 
-	// Load user with roles
-	userWithRoles, err := s.repo.GetUserWithRoles(user.ID)
-	if err != nil {
-		log.Printf("Warning: failed to load user roles: %v", err)
-		userWithRoles = user
+	return nil
+}
+
+// GetUserRoles returns all roles for a user
+func (s *service) GetUserRoles(ctx context.Context, userID int) ([]*Role, error) {
+	if err := s.checkUserScope(ctx, userID); err != nil {
+		return nil, err
 	}
 
-	// Generate tokens
-	accessToken, refreshToken, err := s.GenerateTokens(userWithRoles)
+	roles, err := s.repo.GetUserRoles(ctx, userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate tokens: %w", err)
+		return nil, fmt.Errorf("failed to get user roles: %w", err)
 	}
 
-	response := &LoginResponse{
-		User:         userWithRoles,
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		ExpiresIn:    int(s.jwtExpiry.Seconds()),
+	return roles, nil
+}
+
+// GetUserRoleDetails returns the user's role assignments with assigner details
+func (s *service) GetUserRoleDetails(ctx context.Context, userID int) ([]*UserRoleDetail, error) {
+	if err := s.checkUserScope(ctx, userID); err != nil {
+		return nil, err
 	}
 
-	return response, nil
+	details, err := s.repo.GetUserRoleDetails(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user roles: %w", err)
+	}
+
+	return details, nil
 }
 
-// GetProfile returns user profile with roles and permissions
-func (s *service) GetProfile(userID int) (*User, error) {
-	user, err := s.repo.GetUserWithRoles(userID)
+// ListRoles returns all available roles
+func (s *service) ListRoles(ctx context.Context) ([]*Role, error) {
+	roles, err := s.repo.ListRoles(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user profile: %w", err)
+		return nil, fmt.Errorf("failed to list roles: %w", err)
 	}
 
-	return user, nil
+	return roles, nil
 }
 
-// UpdateProfile updates user profile
-func (s *service) UpdateProfile(userID int, req *UpdateUserRequest) (*User, error) {
-	// Validate request
+// CreateRole creates a new active role
+func (s *service) CreateRole(ctx context.Context, req *CreateRoleRequest) (*Role, error) {
+	if err := s.checkGlobalChange(); err != nil {
+		return nil, err
+	}
 	if err := req.Validate(); err != nil {
 		return nil, err
 	}
 
-	// Update user
-	user, err := s.repo.Update(userID, req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to update profile: %w", err)
+	role := &Role{
+		Name:        strings.TrimSpace(req.Name),
+		Description: strings.TrimSpace(req.Description),
+		IsActive:    true,
 	}
 
-	// Load with roles
-	userWithRoles, err := s.repo.GetUserWithRoles(user.ID)
-	if err != nil {
-		log.Printf("Warning: failed to load user roles: %v", err)
-		return user, nil
+	if err := s.repo.CreateRole(ctx, role); err != nil {
+		if errors.Is(err, ErrRoleExists) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to create role: %w", err)
 	}
 
-	return userWithRoles, nil
+	return role, nil
 }
 
-// GetUser returns user by ID (admin function)
-func (s *service) GetUser(userID int) (*User, error) {
-	user, err := s.repo.GetUserWithRoles(userID)
+// UpdateRole updates role name and description
+func (s *service) UpdateRole(ctx context.Context, id int, req *UpdateRoleRequest) (*Role, error) {
+	if err := s.checkGlobalChange(); err != nil {
+		return nil, err
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	role, err := s.repo.GetRoleByID(ctx, id)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user: %w", err)
+		return nil, err
 	}
 
-	return user, nil
+	// Built-in role names are referenced by code and must stay stable
+	if req.Name != nil && isProtectedRole(role.Name) && strings.TrimSpace(*req.Name) != role.Name {
+		return nil, ErrRoleProtected
+	}
+
+	updatedRole, err := s.repo.UpdateRole(ctx, id, req)
+	if err != nil {
+		if errors.Is(err, ErrRoleNotFound) || errors.Is(err, ErrRoleExists) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to update role: %w", err)
+	}
+	s.authCache.invalidateAll()
+
+	return updatedRole, nil
 }
 
-// ListUsers returns paginated list of users
-func (s *service) ListUsers(page, perPage int) ([]*User, int, error) {
-	if page < 1 {
-		page = 1
+// DeleteRole deactivates a role. Roles still assigned to users cannot be
+// deleted; their assignments must be removed first.
+func (s *service) DeleteRole(ctx context.Context, id int) error {
+	if err := s.checkGlobalChange(); err != nil {
+		return err
 	}
-	if perPage < 1 || perPage > 100 {
-		perPage = 20
+
+	role, err := s.repo.GetRoleByID(ctx, id)
+	if err != nil {
+		return err
 	}
 
-	offset := (page - 1) * perPage
+	if isProtectedRole(role.Name) {
+		return ErrRoleProtected
+	}
 
-	users, total, err := s.repo.List(perPage, offset)
+	count, err := s.repo.CountRoleAssignments(ctx, id)
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to list users: %w", err)
+		return fmt.Errorf("failed to delete role: %w", err)
+	}
+	if count > 0 {
+		return ErrRoleInUse
 	}
 
-	// Load roles for each user (could be optimized with batch loading)
-	for _, user := range users {
-		roles, err := s.repo.GetUserRoles(user.ID)
-		if err != nil {
-			log.Printf("Warning: failed to load roles for user %d: %v", user.ID, err)
-			continue
-		}
-
-		// Convert []*Role to []Role
-		user.Roles = make([]Role, len(roles))
-		for i, role := range roles {
-			user.Roles[i] = *role
+	if err := s.repo.DeactivateRole(ctx, id); err != nil {
+		if errors.Is(err, ErrRoleNotFound) {
+			return err
 		}
+		return fmt.Errorf("failed to delete role: %w", err)
 	}
+	s.authCache.invalidateAll()
 
-	return users, total, nil
+	return nil
 }
 
-// DeactivateUser deactivates a user account
-func (s *service) DeactivateUser(userID int) error {
-	if err := s.repo.Delete(userID); err != nil {
-		return fmt.Errorf("failed to deactivate user: %w", err)
+// isProtectedRole reports whether a role is built in and required by the system
+func isProtectedRole(name string) bool {
+	return name == "admin" || name == defaultRoleName || name == interfaces.SuperAdminRole
+}
+
+// HasPermission checks if user has specific permission
+func (s *service) HasPermission(ctx context.Context, userID int, resource, action string) (bool, error) {
+	hasPermission, err := s.repo.HasPermission(ctx, userID, resource, action)
+	if err != nil {
+		return false, fmt.Errorf("failed to check permission: %w", err)
 	}
 
-	return nil
+	return hasPermission, nil
 }
 
-// AssignUserRole assigns a role to user
-func (s *service) AssignUserRole(userID, roleID, assignedBy int) error {
-	// Verify user exists
-	_, err := s.repo.GetByID(userID)
+// GetUserPermissions returns all permissions for a user
+func (s *service) GetUserPermissions(ctx context.Context, userID int) ([]*Permission, error) {
+	permissions, err := s.repo.GetUserPermissions(ctx, userID)
 	if err != nil {
-		return fmt.Errorf("user not found: %w", err)
+		return nil, fmt.Errorf("failed to get user permissions: %w", err)
 	}
 
-	// Verify role exists
-	_, err = s.repo.GetRoleByID(roleID)
+	return permissions, nil
+}
+
+// ListPermissions returns all available permissions
+func (s *service) ListPermissions(ctx context.Context) ([]*Permission, error) {
+	permissions, err := s.repo.ListPermissions(ctx)
 	if err != nil {
-		return fmt.Errorf("role not found: %w", err)
+		return nil, fmt.Errorf("failed to list permissions: %w", err)
 	}
 
-	// Assign role
-	if err := s.repo.AssignRole(userID, roleID, assignedBy); err != nil {
-		return fmt.Errorf("failed to assign role: %w", err)
+	return permissions, nil
+}
+
+// AddRolePermission grants a permission to a role. Granting a permission
+// the role already has is a no-op.
+func (s *service) AddRolePermission(ctx context.Context, roleID int, req *RolePermissionRequest) error {
+	if err := s.checkGlobalChange(); err != nil {
+		return err
+	}
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	if _, err := s.repo.GetRoleByID(ctx, roleID); err != nil {
+		return err
+	}
+
+	if _, err := s.repo.GetPermissionByID(ctx, req.PermissionID); err != nil {
+		return err
+	}
+
+	if err := s.repo.AddPermissionToRole(ctx, roleID, req.PermissionID); err != nil {
+		return fmt.Errorf("failed to add role permission: %w", err)
 	}
+	s.authCache.invalidateAll()
 
 	return nil
 }
 
-// RemoveUserRole removes a role from user
-func (s *service) RemoveUserRole(userID, roleID int) error {
-	if err := s.repo.RemoveRole(userID, roleID); err != nil {
-		return fmt.Errorf("failed to remove role: %w", err)
+// RemoveRolePermission revokes a permission from a role
+func (s *service) RemoveRolePermission(ctx context.Context, roleID, permissionID int) error {
+	if err := s.checkGlobalChange(); err != nil {
+		return err
+	}
+
+	if err := s.repo.RemovePermissionFromRole(ctx, roleID, permissionID); err != nil {
+		if errors.Is(err, ErrRolePermissionNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to remove role permission: %w", err)
 	}
+	s.authCache.invalidateAll()
 
 	return nil
 }
 
-// GetUserRoles returns all roles for a user
-func (s *service) GetUserRoles(userID int) ([]*Role, error) {
-	roles, err := s.repo.GetUserRoles(userID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get user roles: %w", err)
+// CreateOrganization creates a new active organization
+func (s *service) CreateOrganization(ctx context.Context, req *CreateOrganizationRequest) (*Organization, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
 	}
 
-	return roles, nil
-}
+	org := &Organization{
+		Name:     strings.TrimSpace(req.Name),
+		IsActive: true,
+	}
 
-// ListRoles returns all available roles
-func (s *service) ListRoles() ([]*Role, error) {
-	roles, err := s.repo.ListRoles()
-	if err != nil {
-		return nil, fmt.Errorf("failed to list roles: %w", err)
+	if err := s.repo.CreateOrganization(ctx, org); err != nil {
+		if errors.Is(err, ErrOrganizationExists) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to create organization: %w", err)
 	}
 
-	return roles, nil
+	return org, nil
 }
 
-// HasPermission checks if user has specific permission
-func (s *service) HasPermission(userID int, resource, action string) (bool, error) {
-	hasPermission, err := s.repo.HasPermission(userID, resource, action)
+// ListOrganizations returns all active organizations
+func (s *service) ListOrganizations(ctx context.Context) ([]*Organization, error) {
+	orgs, err := s.repo.ListOrganizations(ctx)
 	if err != nil {
-		return false, fmt.Errorf("failed to check permission: %w", err)
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
 	}
 
-	return hasPermission, nil
+	return orgs, nil
 }
 
-// GetUserPermissions returns all permissions for a user
-func (s *service) GetUserPermissions(userID int) ([]*Permission, error) {
-	permissions, err := s.repo.GetUserPermissions(userID)
+// MoveUserToOrganization moves a user to another active organization
+func (s *service) MoveUserToOrganization(ctx context.Context, userID int, req *MoveUserRequest) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	org, err := s.repo.GetOrganizationByID(ctx, req.OrganizationID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user permissions: %w", err)
+		return err
+	}
+	if !org.IsActive {
+		return ErrOrganizationNotFound
 	}
 
-	return permissions, nil
+	if err := s.repo.SetOrganization(ctx, userID, org.ID); err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to move user: %w", err)
+	}
+	s.authCache.invalidateUser(userID)
+
+	return nil
 }
 
-// GenerateTokens generates access and refresh tokens
-func (s *service) GenerateTokens(user *User) (accessToken, refreshToken string, err error) {
-	// Create access token claims
-	accessClaims := &JWTClaims{
-		UserID: user.ID,
-		Email:  user.Email,
-		Name:   user.Name,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.jwtExpiry)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    "user-management-api",
-			Subject:   fmt.Sprintf("user:%d", user.ID),
-		},
+// GenerateTokens generates an access token and starts a refresh token session
+func (s *service) GenerateTokens(ctx context.Context, user *User, client ClientInfo) (accessToken, refreshToken string, err error) {
+	// Generate access token
+	accessToken, err = s.signAccessToken(user)
+	if err != nil {
+		return "", "", err
 	}
 
-	// Generate access token
-	accessTokenObj := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	accessToken, err = accessTokenObj.SignedString([]byte(s.jwtSecret))
+	// Generate opaque refresh token; only its hash is stored
+	refreshToken, tokenHash, err := generateToken()
 	if err != nil {
-		return "", "", fmt.Errorf("failed to sign access token: %w", err)
+		return "", "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	session := &Session{
+		UserID:    user.ID,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+		ExpiresAt: time.Now().Add(s.refreshTTL),
+	}
+
+	if err := s.repo.CreateSession(ctx, session, tokenHash); err != nil {
+		return "", "", fmt.Errorf("failed to store refresh token: %w", err)
 	}
 
-	// Create refresh token claims (longer expiry)
-	refreshClaims := &JWTClaims{
+	return accessToken, refreshToken, nil
+}
+
+// signAccessToken issues a short-lived access token
+func (s *service) signAccessToken(user *User) (string, error) {
+	claims := &JWTClaims{
 		UserID: user.ID,
 		Email:  user.Email,
 		Name:   user.Name,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(7 * 24 * time.Hour)), // 7 days
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.jwtExpiry)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 			Issuer:    "user-management-api",
-			Subject:   fmt.Sprintf("refresh:%d", user.ID),
+			Subject:   fmt.Sprintf("user:%d", user.ID),
 		},
 	}
 
-	// Generate refresh token
-	refreshTokenObj := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	refreshToken, err = refreshTokenObj.SignedString([]byte(s.jwtSecret))
+	token, err := s.keys.sign(claims)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to sign refresh token: %w", err)
+		return "", fmt.Errorf("failed to sign access token: %w", err)
 	}
 
-	return accessToken, refreshToken, nil
+	return token, nil
 }
 
 // ValidateToken validates JWT token and returns parsed token
 func (s *service) ValidateToken(tokenString string) (*jwt.Token, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		// Verify signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(s.jwtSecret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, s.keys.keyFunc)
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse token: %w", err)
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, fmt.Errorf("%w: %w", ErrTokenExpired, err)
+		}
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
 	}
 
 	if !token.Valid {
-		return nil, fmt.Errorf("invalid token")
+		return nil, ErrInvalidToken
 	}
 
 	return token, nil
 }
 
+// JWKS returns the public signing keys for offline token verification
+func (s *service) JWKS() *JWKSet {
+	return s.keys.jwks()
+}
+
 // GetUserFromToken extracts user information from JWT token
-func (s *service) GetUserFromToken(tokenString string) (*User, error) {
+func (s *service) GetUserFromToken(ctx context.Context, tokenString string) (*User, error) {
 	token, err := s.ValidateToken(tokenString)
 	if err != nil {
 		return nil, err
@@ -394,8 +1824,14 @@ func (s *service) GetUserFromToken(tokenString string) (*User, error) {
 		return nil, fmt.Errorf("invalid token claims")
 	}
 
+	// Two-factor and password change tokens share the signing key but are
+	// not access tokens
+	if !strings.HasPrefix(claims.Subject, "user:") {
+		return nil, ErrInvalidToken
+	}
+
 	// Get user with current data from database
-	user, err := s.repo.GetUserWithRoles(claims.UserID)
+	user, err := s.repo.GetUserWithRoles(ctx, claims.UserID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user from token: %w", err)
 	}
@@ -405,5 +1841,10 @@ func (s *service) GetUserFromToken(tokenString string) (*User, error) {
 		return nil, ErrInactiveUser
 	}
 
+	// Tokens issued before an admin forced a password change stop working
+	if user.MustChangePassword {
+		return nil, ErrPasswordChangeRequired
+	}
+
 	return user, nil
 }
@@ -0,0 +1,129 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"user-management/database/dbtest"
+	"user-management/shared/middleware"
+	"user-management/shared/response"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// TestForcedPasswordChangeRejectsOldCredentials stops tokens, refresh
+// tokens and API keys issued before an admin forced a password change,
+// including lookups the auth cache already holds
+func TestForcedPasswordChangeRejectsOldCredentials(t *testing.T) {
+	db := dbtest.Migrated(t)
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	cache := NewAuthCache(time.Minute)
+	service, err := NewService(repo, nil, Config{
+		JWTSecret:             "test-secret",
+		EncryptionKey:         "test-encryption-key",
+		AuthCache:             cache,
+		SkipEmailVerification: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := NewAuthServiceAdapter(service, cache)
+
+	user, err := NewUser("forced@example.com", "Old!Passw0rd1", "Forced", bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+
+	login, err := service.Login(ctx, &LoginRequest{Email: user.Email, Password: "Old!Passw0rd1"}, ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := service.CreateAPIKey(ctx, user.ID, &CreateAPIKeyRequest{Name: "old key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response.RegisterErrorCodes(ErrorCodes...)
+	mux := http.NewServeMux()
+	NewHandler(service, middleware.NewAuthMiddleware(auth), nil).RegisterRoutes(mux)
+	getProfile := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/auth/profile", nil)
+		req.Header.Set(header, value)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The profile lookup puts the token's user in the auth cache
+	if rec := getProfile("Authorization", "Bearer "+login.AccessToken); rec.Code != http.StatusOK {
+		t.Fatalf("got status %d before the flag was set: %s", rec.Code, rec.Body)
+	}
+
+	mustChange := true
+	if _, err := service.UpdateProfile(ctx, user.ID, &UpdateUserRequest{MustChangePassword: &mustChange}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, credential := range []struct{ header, value string }{
+		{"Authorization", "Bearer " + login.AccessToken},
+		{middleware.APIKeyHeader, key.Key},
+	} {
+		rec := getProfile(credential.header, credential.value)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s: got status %d, want %d", credential.header, rec.Code, http.StatusUnauthorized)
+			continue
+		}
+
+		var body response.ErrorResponse
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Code != "AUTH_PASSWORD_CHANGE_REQUIRED" {
+			t.Errorf("%s: got code %q, want AUTH_PASSWORD_CHANGE_REQUIRED", credential.header, body.Code)
+		}
+	}
+
+	if _, err := service.RefreshTokens(ctx, &RefreshTokenRequest{RefreshToken: login.RefreshToken}, ClientInfo{}); err == nil {
+		t.Error("refresh token issued before the flag was set still works")
+	}
+
+	// Logging in again only yields a password change token
+	login, err = service.Login(ctx, &LoginRequest{Email: user.Email, Password: "Old!Passw0rd1"}, ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !login.PasswordChangeRequired || login.PasswordChangeToken == "" || login.AccessToken != "" || login.RefreshToken != "" {
+		t.Fatalf("got login response %+v, want only a password change token", login)
+	}
+	if _, err := auth.GetUserFromToken(ctx, login.PasswordChangeToken); err == nil {
+		t.Error("password change token authenticated an API call")
+	}
+
+	changed, err := service.CompleteForcedPasswordChange(ctx, &ForcedPasswordChangeRequest{
+		PasswordChangeToken: login.PasswordChangeToken,
+		NewPassword:         "New!Passw0rd2",
+	}, ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec := getProfile("Authorization", "Bearer "+changed.AccessToken); rec.Code != http.StatusOK {
+		t.Errorf("got status %d after the password change: %s", rec.Code, rec.Body)
+	}
+
+	_, err = service.CompleteForcedPasswordChange(ctx, &ForcedPasswordChangeRequest{
+		PasswordChangeToken: login.PasswordChangeToken,
+		NewPassword:         "Other!Passw0rd3",
+	}, ClientInfo{})
+	if !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("got %v reusing the password change token, want ErrInvalidToken", err)
+	}
+}
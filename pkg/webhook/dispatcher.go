@@ -0,0 +1,292 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Dispatcher tuning
+const (
+	dispatchWorkers     = 4
+	dispatchQueueSize   = 1000
+	maxDeliveryAttempts = 5
+	initialBackoff      = time.Second
+	deliveryTimeout     = 10 * time.Second
+)
+
+// errAddressNotAllowed is returned when a webhook host resolves to an
+// address deliveries must not reach
+var errAddressNotAllowed = errors.New("webhook address not allowed")
+
+// metadataAddresses are cloud instance metadata endpoints outside the
+// link-local range
+var metadataAddresses = []netip.Addr{
+	netip.MustParseAddr("fd00:ec2::254"),
+}
+
+// allowedAddress reports whether deliveries may connect to ip. Loopback,
+// private, link-local (including 169.254.169.254), multicast, unspecified
+// and metadata addresses are rejected so webhooks cannot reach internal
+// services.
+func allowedAddress(ip net.IP) bool {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return false
+	}
+	addr = addr.Unmap()
+
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+	for _, metadata := range metadataAddresses {
+		if addr == metadata {
+			return false
+		}
+	}
+	return true
+}
+
+// dialControl rejects connections to disallowed addresses. It runs on the
+// resolved address of every connection, so host names that resolve or
+// rebind to internal addresses are caught as well.
+func dialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return errAddressNotAllowed
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !allowedAddress(ip) {
+		return errAddressNotAllowed
+	}
+	return nil
+}
+
+// newDeliveryClient creates the HTTP client for deliveries. Proxies from
+// the environment are not used, since the dial check would then see the
+// proxy rather than the webhook host.
+func newDeliveryClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: deliveryTimeout,
+		Control: dialControl,
+	}
+	return &http.Client{
+		Timeout: deliveryTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: deliveryTimeout,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
+
+// deliveryError returns the error recorded for a failed delivery. Dial and
+// transport errors can reveal internal addresses and are not shown as is.
+func deliveryError(err error) string {
+	var statusErr *statusError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &statusErr):
+		return statusErr.Error()
+	case errors.Is(err, errAddressNotAllowed):
+		return "webhook address not allowed"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "request timed out"
+	default:
+		return "delivery failed"
+	}
+}
+
+// statusError is returned when a webhook answers with a non-2xx status
+type statusError struct {
+	status int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected response status %d", e.status)
+}
+
+// job is a delivery waiting to be sent to a webhook
+type job struct {
+	webhook  *Webhook
+	delivery *Delivery
+}
+
+// Dispatcher posts signed event payloads to the webhooks subscribed to
+// them. Deliveries are recorded before they are queued and retried with
+// exponential backoff until a 2xx response or maxDeliveryAttempts.
+type Dispatcher struct {
+	repo   Repository
+	client *http.Client
+
+	jobs     chan *job
+	stop     chan struct{}
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+// NewDispatcher creates a webhook dispatcher
+func NewDispatcher(repo Repository) *Dispatcher {
+	return &Dispatcher{
+		repo:   repo,
+		client: newDeliveryClient(),
+		jobs:   make(chan *job, dispatchQueueSize),
+		stop:   make(chan struct{}),
+	}
+}
+
+// Start starts the delivery workers
+func (d *Dispatcher) Start() {
+	for i := 0; i < dispatchWorkers; i++ {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			for {
+				select {
+				case j := <-d.jobs:
+					d.deliver(j)
+				case <-d.stop:
+					return
+				}
+			}
+		}()
+	}
+}
+
+// Stop stops the workers and waits for running deliveries. Queued
+// deliveries stay pending in the delivery log.
+func (d *Dispatcher) Stop() {
+	d.stopOnce.Do(func() {
+		close(d.stop)
+	})
+	d.wg.Wait()
+}
+
+// Publish queues the event for every enabled webhook of the organization
+// subscribed to it. Publishing is best-effort: failures are logged and
+// never returned to the caller.
+func (d *Dispatcher) Publish(eventType string, organizationID int, data interface{}) {
+	webhooks, err := d.repo.ListSubscribedWebhooks(organizationID, eventType)
+	if err != nil {
+		log.Printf("Warning: failed to list webhooks for %s: %v", eventType, err)
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(&Payload{
+		Event:     eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		log.Printf("Warning: failed to encode %s payload: %v", eventType, err)
+		return
+	}
+
+	for _, webhook := range webhooks {
+		delivery := &Delivery{
+			WebhookID: webhook.ID,
+			EventType: eventType,
+			Payload:   payload,
+			Status:    DeliveryPending,
+		}
+		if err := d.repo.CreateDelivery(delivery); err != nil {
+			log.Printf("Warning: failed to record %s delivery for webhook %d: %v", eventType, webhook.ID, err)
+			continue
+		}
+
+		select {
+		case d.jobs <- &job{webhook: webhook, delivery: delivery}:
+		default:
+			delivery.Status = DeliveryFailed
+			delivery.Error = "delivery queue full"
+			d.saveDelivery(delivery)
+		}
+	}
+}
+
+// deliver sends a delivery, retrying with exponential backoff
+func (d *Dispatcher) deliver(j *job) {
+	backoff := initialBackoff
+
+	for {
+		statusCode, err := d.send(j.webhook, j.delivery)
+		j.delivery.Attempts++
+		j.delivery.ResponseStatus = nil
+		if statusCode != 0 {
+			j.delivery.ResponseStatus = &statusCode
+		}
+
+		if err == nil {
+			j.delivery.Status = DeliverySucceeded
+			j.delivery.Error = ""
+			d.saveDelivery(j.delivery)
+			return
+		}
+
+		j.delivery.Error = deliveryError(err)
+		if j.delivery.Attempts >= maxDeliveryAttempts {
+			j.delivery.Status = DeliveryFailed
+			d.saveDelivery(j.delivery)
+			return
+		}
+		d.saveDelivery(j.delivery)
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+// send posts the delivery payload once and returns the response status
+func (d *Dispatcher) send(webhook *Webhook, delivery *Delivery) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, delivery.Payload))
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryIDHeader, strconv.FormatInt(delivery.ID, 10))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// Drain a bounded amount of the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, &statusError{status: resp.StatusCode}
+	}
+
+	return resp.StatusCode, nil
+}
+
+// saveDelivery stores the delivery outcome, logging failures
+func (d *Dispatcher) saveDelivery(delivery *Delivery) {
+	if err := d.repo.UpdateDelivery(delivery); err != nil {
+		log.Printf("Warning: failed to update webhook delivery %d: %v", delivery.ID, err)
+	}
+}
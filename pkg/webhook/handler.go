@@ -0,0 +1,218 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"user-management/pkg/audit"
+	"user-management/shared/interfaces"
+	"user-management/shared/middleware"
+	"user-management/shared/response"
+)
+
+// Handler handles HTTP requests for webhook operations
+type Handler struct {
+	service Service
+	authMW  *middleware.AuthMiddleware
+	audit   audit.Recorder
+}
+
+// NewHandler creates a new webhook handler
+func NewHandler(service Service, authMW *middleware.AuthMiddleware, recorder audit.Recorder) *Handler {
+	return &Handler{
+		service: service,
+		authMW:  authMW,
+		audit:   recorder,
+	}
+}
+
+// RegisterRoutes registers all webhook routes
+func (h *Handler) RegisterRoutes(mux interfaces.Router) {
+	// Admin routes
+	mux.Handle("GET /api/webhooks", h.authMW.Authenticate(h.authMW.RequireAdmin(http.HandlerFunc(h.ListWebhooks))))
+	mux.Handle("GET /api/webhooks/{id}", h.authMW.Authenticate(h.authMW.RequireAdmin(http.HandlerFunc(h.GetWebhook))))
+	mux.Handle("POST /api/webhooks", h.authMW.Authenticate(h.authMW.RequireAdmin(http.HandlerFunc(h.CreateWebhook))))
+	mux.Handle("PUT /api/webhooks/{id}", h.authMW.Authenticate(h.authMW.RequireAdmin(http.HandlerFunc(h.UpdateWebhook))))
+	mux.Handle("DELETE /api/webhooks/{id}", h.authMW.Authenticate(h.authMW.RequireAdmin(http.HandlerFunc(h.DeleteWebhook))))
+	mux.Handle("GET /api/webhooks/{id}/deliveries", h.authMW.Authenticate(h.authMW.RequireAdmin(http.HandlerFunc(h.ListDeliveries))))
+}
+
+// scopedService returns the service limited to the organization of the
+// authenticated user; super admins are not limited
+func (h *Handler) scopedService(r *http.Request) Service {
+	scope, ok := middleware.GetScopeFromContext(r.Context())
+	if !ok {
+		// Fail closed: a restricted scope without organization matches nothing
+		scope = interfaces.Scope{Restricted: true}
+	}
+	return h.service.WithScope(scope)
+}
+
+// CreateWebhook handles webhook creation
+func (h *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		response.Unauthorized(w, "User not found in context")
+		return
+	}
+
+	var req CreateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	created, err := h.scopedService(r).CreateWebhook(&req, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidURL), errors.Is(err, ErrInvalidEventTypes), errors.Is(err, ErrSecretTooShort):
+			response.BadRequest(w, "Validation failed", err)
+		default:
+			response.InternalServerError(w, "Failed to create webhook", err)
+		}
+		return
+	}
+
+	h.audit.Record(r, audit.ActionWebhookCreate, audit.ResourceWebhook, strconv.Itoa(created.Webhook.ID), map[string]interface{}{
+		"url":         created.Webhook.URL,
+		"event_types": created.Webhook.EventTypes,
+	})
+
+	response.Created(w, "Webhook created successfully", created)
+}
+
+// GetWebhook handles getting a webhook by ID
+func (h *Handler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid webhook ID", err)
+		return
+	}
+
+	webhook, err := h.scopedService(r).GetWebhook(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrWebhookNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Webhook not found", err)
+		default:
+			response.InternalServerError(w, "Failed to get webhook", err)
+		}
+		return
+	}
+
+	response.Success(w, "Webhook retrieved successfully", webhook)
+}
+
+// ListWebhooks handles listing webhooks
+func (h *Handler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := h.scopedService(r).ListWebhooks()
+	if err != nil {
+		response.InternalServerError(w, "Failed to list webhooks", err)
+		return
+	}
+
+	response.Success(w, "Webhooks retrieved successfully", webhooks)
+}
+
+// UpdateWebhook handles webhook updates
+func (h *Handler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid webhook ID", err)
+		return
+	}
+
+	var req UpdateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body", err)
+		return
+	}
+
+	webhook, err := h.scopedService(r).UpdateWebhook(id, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidURL), errors.Is(err, ErrInvalidEventTypes):
+			response.BadRequest(w, "Validation failed", err)
+		case errors.Is(err, ErrWebhookNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Webhook not found", err)
+		default:
+			response.InternalServerError(w, "Failed to update webhook", err)
+		}
+		return
+	}
+
+	h.audit.Record(r, audit.ActionWebhookUpdate, audit.ResourceWebhook, strconv.Itoa(id), req)
+
+	response.Success(w, "Webhook updated successfully", webhook)
+}
+
+// DeleteWebhook handles webhook deletion
+func (h *Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid webhook ID", err)
+		return
+	}
+
+	if err := h.scopedService(r).DeleteWebhook(id); err != nil {
+		switch {
+		case errors.Is(err, ErrWebhookNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Webhook not found", err)
+		default:
+			response.InternalServerError(w, "Failed to delete webhook", err)
+		}
+		return
+	}
+
+	h.audit.Record(r, audit.ActionWebhookDelete, audit.ResourceWebhook, strconv.Itoa(id), nil)
+
+	response.Success(w, "Webhook deleted successfully", nil)
+}
+
+// ListDeliveries handles listing the delivery log of a webhook
+func (h *Handler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid webhook ID", err)
+		return
+	}
+
+	// Parse query parameters
+	page := 1
+	perPage := 20
+
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if perPageStr := r.URL.Query().Get("per_page"); perPageStr != "" {
+		if pp, err := strconv.Atoi(perPageStr); err == nil && pp > 0 && pp <= 100 {
+			perPage = pp
+		}
+	}
+
+	deliveries, total, err := h.scopedService(r).ListDeliveries(id, perPage, (page-1)*perPage)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrWebhookNotFound):
+			response.ErrorFor(w, http.StatusNotFound, "Webhook not found", err)
+		default:
+			response.InternalServerError(w, "Failed to list webhook deliveries", err)
+		}
+		return
+	}
+
+	// Calculate pagination meta
+	totalPages := (total + perPage - 1) / perPage
+	meta := &response.Meta{
+		Page:       page,
+		PerPage:    perPage,
+		Total:      total,
+		TotalPages: totalPages,
+	}
+
+	response.PaginatedSuccess(w, "Webhook deliveries retrieved successfully", deliveries, meta)
+}
@@ -0,0 +1,214 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+	"user-management/shared/response"
+)
+
+// Event types webhooks can subscribe to
+const (
+	EventAlertTriggered = "alert.triggered"
+	EventAlertResolved  = "alert.resolved"
+	EventSensorOffline  = "sensor.offline"
+	EventSensorOnline   = "sensor.online"
+
+	// EventSensorBatteryCritical is published when a sensor's battery
+	// drops into the critical range
+	EventSensorBatteryCritical = "sensor.battery_critical"
+)
+
+// EventTypes lists every supported event type
+var EventTypes = []string{EventAlertTriggered, EventAlertResolved, EventSensorOffline, EventSensorOnline, EventSensorBatteryCritical}
+
+// Delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// Headers set on webhook requests
+const (
+	// SignatureHeader carries the hex encoded HMAC-SHA256 of the request body
+	SignatureHeader  = "X-Signature"
+	EventHeader      = "X-Event-Type"
+	DeliveryIDHeader = "X-Delivery-ID"
+)
+
+// minSecretLength is the shortest secret accepted from clients
+const minSecretLength = 16
+
+// Webhook represents an endpoint notified of events in an organization
+type Webhook struct {
+	ID             int       `json:"id"`
+	OrganizationID int       `json:"organization_id"`
+	URL            string    `json:"url"`
+	Secret         string    `json:"-"`
+	EventTypes     []string  `json:"event_types"`
+	Enabled        bool      `json:"enabled"`
+	CreatedBy      *int      `json:"created_by,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Delivery represents one event sent, or being sent, to a webhook
+type Delivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      int             `json:"webhook_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus *int            `json:"response_status,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// Payload is the JSON body posted to webhooks
+type Payload struct {
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// CreateWebhookRequest represents request to create a webhook. A secret is
+// generated when none is given.
+type CreateWebhookRequest struct {
+	URL        string   `json:"url"`
+	Secret     string   `json:"secret,omitempty"`
+	EventTypes []string `json:"event_types"`
+	Enabled    *bool    `json:"enabled,omitempty"`
+}
+
+// CreateWebhookResponse returns the signing secret, which is only shown once
+type CreateWebhookResponse struct {
+	Secret  string   `json:"secret"`
+	Webhook *Webhook `json:"webhook"`
+}
+
+// UpdateWebhookRequest represents request to update a webhook
+type UpdateWebhookRequest struct {
+	URL        *string  `json:"url,omitempty"`
+	EventTypes []string `json:"event_types,omitempty"`
+	Enabled    *bool    `json:"enabled,omitempty"`
+}
+
+// Validation errors
+var (
+	ErrWebhookNotFound   = errors.New("webhook not found")
+	ErrInvalidURL        = errors.New("url must be an absolute http or https URL of a public host")
+	ErrInvalidEventTypes = errors.New("event_types must list at least one of alert.triggered, alert.resolved, sensor.offline, sensor.online, sensor.battery_critical")
+	ErrSecretTooShort    = errors.New("secret must be at least 16 characters")
+)
+
+// ErrorCodes are the stable API error codes of the errors above
+var ErrorCodes = []response.ErrorCode{
+	response.Code("WEBHOOK_NOT_FOUND", ErrWebhookNotFound),
+	response.Code("WEBHOOK_INVALID_URL", ErrInvalidURL),
+	response.Code("WEBHOOK_INVALID_EVENT_TYPES", ErrInvalidEventTypes),
+	response.Code("WEBHOOK_SECRET_TOO_SHORT", ErrSecretTooShort),
+}
+
+// Validate validates CreateWebhookRequest
+func (r *CreateWebhookRequest) Validate() error {
+	r.URL = strings.TrimSpace(r.URL)
+	if err := validateURL(r.URL); err != nil {
+		return err
+	}
+
+	if err := validateEventTypes(r.EventTypes); err != nil {
+		return err
+	}
+
+	if r.Secret != "" && len(r.Secret) < minSecretLength {
+		return ErrSecretTooShort
+	}
+
+	return nil
+}
+
+// Validate validates UpdateWebhookRequest
+func (r *UpdateWebhookRequest) Validate() error {
+	if r.URL != nil {
+		trimmed := strings.TrimSpace(*r.URL)
+		r.URL = &trimmed
+		if err := validateURL(trimmed); err != nil {
+			return err
+		}
+	}
+
+	if r.EventTypes != nil {
+		if err := validateEventTypes(r.EventTypes); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateURL checks that raw is an absolute http or https URL. Hosts
+// given as disallowed addresses are rejected up front; host names are
+// checked again when the dispatcher dials them.
+func validateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || len(raw) > 2048 {
+		return ErrInvalidURL
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrInvalidURL
+	}
+	if ip := net.ParseIP(host); ip != nil && !allowedAddress(ip) {
+		return ErrInvalidURL
+	}
+	return nil
+}
+
+// validateEventTypes checks that types is a non-empty list of supported events
+func validateEventTypes(types []string) error {
+	if len(types) == 0 {
+		return ErrInvalidEventTypes
+	}
+
+	for _, t := range types {
+		supported := false
+		for _, known := range EventTypes {
+			if t == known {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			return ErrInvalidEventTypes
+		}
+	}
+
+	return nil
+}
+
+// generateSecret returns a random hex encoded signing secret
+func generateSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of body keyed with secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
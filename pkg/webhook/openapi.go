@@ -0,0 +1,21 @@
+package webhook
+
+import (
+	"net/http"
+	"user-management/shared/openapi"
+)
+
+// Operations documents the webhook routes in the OpenAPI document
+var Operations = []openapi.Operation{
+	{Pattern: "GET /api/webhooks", Tag: "webhooks", Summary: "List webhooks", Access: openapi.AccessAdmin,
+		Response: []*Webhook{}},
+	{Pattern: "GET /api/webhooks/{id}", Tag: "webhooks", Summary: "Get a webhook", Access: openapi.AccessAdmin,
+		Response: &Webhook{}},
+	{Pattern: "POST /api/webhooks", Tag: "webhooks", Summary: "Create a webhook", Access: openapi.AccessAdmin,
+		Request: CreateWebhookRequest{}, Response: &CreateWebhookResponse{}, Status: http.StatusCreated},
+	{Pattern: "PUT /api/webhooks/{id}", Tag: "webhooks", Summary: "Update a webhook", Access: openapi.AccessAdmin,
+		Request: UpdateWebhookRequest{}, Response: &Webhook{}},
+	{Pattern: "DELETE /api/webhooks/{id}", Tag: "webhooks", Summary: "Delete a webhook", Access: openapi.AccessAdmin},
+	{Pattern: "GET /api/webhooks/{id}/deliveries", Tag: "webhooks", Summary: "List the deliveries of a webhook", Access: openapi.AccessAdmin,
+		Query: []string{"page", "per_page"}, Response: []*Delivery{}, Paginated: true},
+}
@@ -0,0 +1,318 @@
+package webhook
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"user-management/shared/interfaces"
+
+	"github.com/lib/pq"
+)
+
+// Repository defines webhook repository interface
+type Repository interface {
+	// Webhook operations
+	CreateWebhook(webhook *Webhook) error
+	GetWebhookByID(id int) (*Webhook, error)
+	ListWebhooks() ([]*Webhook, error)
+	UpdateWebhook(id int, req *UpdateWebhookRequest) (*Webhook, error)
+	DeleteWebhook(id int) error
+	ListSubscribedWebhooks(organizationID int, eventType string) ([]*Webhook, error)
+
+	// Delivery operations
+	CreateDelivery(delivery *Delivery) error
+	UpdateDelivery(delivery *Delivery) error
+	ListDeliveries(webhookID, limit, offset int) ([]*Delivery, int, error)
+
+	// WithScope returns a repository limited to the organization in scope
+	WithScope(scope interfaces.Scope) Repository
+}
+
+// repository implements Repository interface
+type repository struct {
+	db    *sql.DB
+	scope interfaces.Scope
+}
+
+// NewRepository creates a new webhook repository
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
+}
+
+// WithScope returns a copy of the repository limited to the scope
+func (r *repository) WithScope(scope interfaces.Scope) Repository {
+	return &repository{db: r.db, scope: scope}
+}
+
+// orgFilter appends the scope's organization to args and returns an AND
+// condition on column, or "" when the repository is unrestricted
+func (r *repository) orgFilter(column string, args []interface{}) (string, []interface{}) {
+	if !r.scope.Restricted {
+		return "", args
+	}
+	args = append(args, r.scope.OrganizationID)
+	return fmt.Sprintf(" AND %s = $%d", column, len(args)), args
+}
+
+// Schema name constant
+const schema = "sensor_data"
+
+const webhookColumns = `
+	id, organization_id, url, secret, event_types, enabled, created_by, created_at, updated_at
+`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanWebhook scans a row selected with webhookColumns
+func scanWebhook(row rowScanner) (*Webhook, error) {
+	webhook := &Webhook{}
+	err := row.Scan(
+		&webhook.ID, &webhook.OrganizationID, &webhook.URL, &webhook.Secret, pq.Array(&webhook.EventTypes),
+		&webhook.Enabled, &webhook.CreatedBy, &webhook.CreatedAt, &webhook.UpdatedAt,
+	)
+	return webhook, err
+}
+
+// CreateWebhook creates a new webhook
+func (r *repository) CreateWebhook(webhook *Webhook) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s.webhooks (organization_id, url, secret, event_types, enabled, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at
+	`, schema)
+
+	err := r.db.QueryRow(query,
+		webhook.OrganizationID, webhook.URL, webhook.Secret, pq.Array(webhook.EventTypes),
+		webhook.Enabled, webhook.CreatedBy).
+		Scan(&webhook.ID, &webhook.CreatedAt, &webhook.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	return nil
+}
+
+// GetWebhookByID retrieves a webhook by ID
+func (r *repository) GetWebhookByID(id int) (*Webhook, error) {
+	args := []interface{}{id}
+	orgClause, args := r.orgFilter("organization_id", args)
+
+	query := fmt.Sprintf(`
+		SELECT %s FROM %s.webhooks WHERE id = $1%s
+	`, webhookColumns, schema, orgClause)
+
+	webhook, err := scanWebhook(r.db.QueryRow(query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+
+	return webhook, nil
+}
+
+// ListWebhooks retrieves all webhooks in scope
+func (r *repository) ListWebhooks() ([]*Webhook, error) {
+	orgClause, args := r.orgFilter("organization_id", nil)
+
+	query := fmt.Sprintf(`
+		SELECT %s FROM %s.webhooks
+		WHERE true%s
+		ORDER BY id
+	`, webhookColumns, schema, orgClause)
+
+	return r.queryWebhooks(query, args...)
+}
+
+// UpdateWebhook updates the fields set in the request
+func (r *repository) UpdateWebhook(id int, req *UpdateWebhookRequest) (*Webhook, error) {
+	setParts := []string{}
+	args := []interface{}{}
+	argIndex := 1
+
+	if req.URL != nil {
+		setParts = append(setParts, fmt.Sprintf("url = $%d", argIndex))
+		args = append(args, *req.URL)
+		argIndex++
+	}
+
+	if req.EventTypes != nil {
+		setParts = append(setParts, fmt.Sprintf("event_types = $%d", argIndex))
+		args = append(args, pq.Array(req.EventTypes))
+		argIndex++
+	}
+
+	if req.Enabled != nil {
+		setParts = append(setParts, fmt.Sprintf("enabled = $%d", argIndex))
+		args = append(args, *req.Enabled)
+		argIndex++
+	}
+
+	if len(setParts) == 0 {
+		return r.GetWebhookByID(id)
+	}
+
+	setParts = append(setParts, "updated_at = CURRENT_TIMESTAMP")
+	args = append(args, id)
+	orgClause, args := r.orgFilter("organization_id", args)
+
+	query := fmt.Sprintf(`
+		UPDATE %s.webhooks
+		SET %s
+		WHERE id = $%d%s
+		RETURNING %s
+	`, schema, strings.Join(setParts, ", "), argIndex, orgClause, webhookColumns)
+
+	webhook, err := scanWebhook(r.db.QueryRow(query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update webhook: %w", err)
+	}
+
+	return webhook, nil
+}
+
+// DeleteWebhook deletes a webhook together with its delivery log
+func (r *repository) DeleteWebhook(id int) error {
+	args := []interface{}{id}
+	orgClause, args := r.orgFilter("organization_id", args)
+
+	query := fmt.Sprintf(`
+		DELETE FROM %s.webhooks WHERE id = $1%s
+	`, schema, orgClause)
+
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+// ListSubscribedWebhooks retrieves the enabled webhooks of an organization
+// subscribed to the event type
+func (r *repository) ListSubscribedWebhooks(organizationID int, eventType string) ([]*Webhook, error) {
+	query := fmt.Sprintf(`
+		SELECT %s FROM %s.webhooks
+		WHERE organization_id = $1 AND enabled = true AND $2 = ANY(event_types)
+		ORDER BY id
+	`, webhookColumns, schema)
+
+	return r.queryWebhooks(query, organizationID, eventType)
+}
+
+// queryWebhooks runs a query selecting webhookColumns
+func (r *repository) queryWebhooks(query string, args ...interface{}) ([]*Webhook, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := []*Webhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, nil
+}
+
+// CreateDelivery records a pending delivery
+func (r *repository) CreateDelivery(delivery *Delivery) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s.webhook_deliveries (webhook_id, event_type, payload, status)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at
+	`, schema)
+
+	err := r.db.QueryRow(query,
+		delivery.WebhookID, delivery.EventType, []byte(delivery.Payload), delivery.Status).
+		Scan(&delivery.ID, &delivery.CreatedAt, &delivery.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook delivery: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateDelivery stores the status and outcome of the latest attempt
+func (r *repository) UpdateDelivery(delivery *Delivery) error {
+	query := fmt.Sprintf(`
+		UPDATE %s.webhook_deliveries
+		SET status = $2, attempts = $3, response_status = $4, error = NULLIF($5, ''),
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING updated_at
+	`, schema)
+
+	err := r.db.QueryRow(query,
+		delivery.ID, delivery.Status, delivery.Attempts, delivery.ResponseStatus, delivery.Error).
+		Scan(&delivery.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+
+	return nil
+}
+
+// ListDeliveries retrieves the deliveries of a webhook, newest first
+func (r *repository) ListDeliveries(webhookID, limit, offset int) ([]*Delivery, int, error) {
+	countQuery := fmt.Sprintf(`
+		SELECT COUNT(*) FROM %s.webhook_deliveries WHERE webhook_id = $1
+	`, schema)
+
+	var total int
+	if err := r.db.QueryRow(countQuery, webhookID).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count webhook deliveries: %w", err)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, webhook_id, event_type, payload, status, attempts, response_status,
+		       COALESCE(error, ''), created_at, updated_at
+		FROM %s.webhook_deliveries
+		WHERE webhook_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3
+	`, schema)
+
+	rows, err := r.db.Query(query, webhookID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []*Delivery{}
+	for rows.Next() {
+		delivery := &Delivery{}
+		err := rows.Scan(
+			&delivery.ID, &delivery.WebhookID, &delivery.EventType, &delivery.Payload, &delivery.Status,
+			&delivery.Attempts, &delivery.ResponseStatus, &delivery.Error, &delivery.CreatedAt, &delivery.UpdatedAt,
+		)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, total, nil
+}
@@ -0,0 +1,108 @@
+package webhook
+
+import (
+	"fmt"
+	"user-management/shared/interfaces"
+)
+
+// Service defines webhook service interface
+type Service interface {
+	CreateWebhook(req *CreateWebhookRequest, createdBy int) (*CreateWebhookResponse, error)
+	GetWebhook(id int) (*Webhook, error)
+	ListWebhooks() ([]*Webhook, error)
+	UpdateWebhook(id int, req *UpdateWebhookRequest) (*Webhook, error)
+	DeleteWebhook(id int) error
+	ListDeliveries(webhookID, limit, offset int) ([]*Delivery, int, error)
+
+	// WithScope returns a service limited to the organization in scope
+	WithScope(scope interfaces.Scope) Service
+}
+
+// service implements Service interface
+type service struct {
+	repo  Repository
+	scope interfaces.Scope
+}
+
+// NewService creates a new webhook service
+func NewService(repo Repository) Service {
+	return &service{repo: repo}
+}
+
+// WithScope returns a copy of the service whose webhooks are limited to
+// the scope. Webhooks created through it belong to the scope's organization.
+func (s *service) WithScope(scope interfaces.Scope) Service {
+	return &service{
+		repo:  s.repo.WithScope(scope),
+		scope: scope,
+	}
+}
+
+// CreateWebhook creates a webhook and returns its signing secret
+func (s *service) CreateWebhook(req *CreateWebhookRequest, createdBy int) (*CreateWebhookResponse, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		generated, err := generateSecret()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate webhook secret: %w", err)
+		}
+		secret = generated
+	}
+
+	webhook := &Webhook{
+		OrganizationID: s.scope.OrganizationID,
+		URL:            req.URL,
+		Secret:         secret,
+		EventTypes:     req.EventTypes,
+		Enabled:        true,
+		CreatedBy:      &createdBy,
+	}
+
+	if req.Enabled != nil {
+		webhook.Enabled = *req.Enabled
+	}
+
+	if err := s.repo.CreateWebhook(webhook); err != nil {
+		return nil, err
+	}
+
+	return &CreateWebhookResponse{Secret: secret, Webhook: webhook}, nil
+}
+
+// GetWebhook retrieves a webhook by ID
+func (s *service) GetWebhook(id int) (*Webhook, error) {
+	return s.repo.GetWebhookByID(id)
+}
+
+// ListWebhooks retrieves all webhooks
+func (s *service) ListWebhooks() ([]*Webhook, error) {
+	return s.repo.ListWebhooks()
+}
+
+// UpdateWebhook updates a webhook
+func (s *service) UpdateWebhook(id int, req *UpdateWebhookRequest) (*Webhook, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	return s.repo.UpdateWebhook(id, req)
+}
+
+// DeleteWebhook deletes a webhook and its delivery log
+func (s *service) DeleteWebhook(id int) error {
+	return s.repo.DeleteWebhook(id)
+}
+
+// ListDeliveries retrieves the delivery log of a webhook
+func (s *service) ListDeliveries(webhookID, limit, offset int) ([]*Delivery, int, error) {
+	// Check if webhook exists and is visible to the caller
+	if _, err := s.repo.GetWebhookByID(webhookID); err != nil {
+		return nil, 0, err
+	}
+
+	return s.repo.ListDeliveries(webhookID, limit, offset)
+}
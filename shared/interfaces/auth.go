@@ -1,14 +1,38 @@
 package interfaces
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 // User represents a user entity for authentication
 type User struct {
-	ID       int    `json:"id"`
-	Email    string `json:"email"`
-	Name     string `json:"name"`
-	IsActive bool   `json:"is_active"`
-	Roles    []Role `json:"roles,omitempty"`
+	ID             int    `json:"id"`
+	Email          string `json:"email"`
+	Name           string `json:"name"`
+	OrganizationID int    `json:"organization_id"`
+	IsActive       bool   `json:"is_active"`
+	Timezone       string `json:"timezone,omitempty"`
+	Roles          []Role `json:"roles,omitempty"`
+	// KeyScopes limits a user authenticated with a scoped API key;
+	// nil means the user's own permissions apply unrestricted
+	KeyScopes []Permission `json:"-"`
+}
+
+// SuperAdminRole is the role allowed to work across organizations
+const SuperAdminRole = "super_admin"
+
+// DefaultOrganizationID is the organization created by the migrations
+// that self-registered users join
+const DefaultOrganizationID = 1
+
+// Scope limits data access to a single organization. The zero Scope is
+// unrestricted and is meant for internal callers such as device ingestion.
+type Scope struct {
+	// OrganizationID is the organization new records are created in
+	OrganizationID int
+	// Restricted limits reads and writes to OrganizationID
+	Restricted bool
 }
 
 // Role represents a user role
@@ -30,6 +54,16 @@ type Permission struct {
 	CreatedAt   time.Time `json:"created_at"`
 }
 
+// PermissionWildcard matches any resource or action
+const PermissionWildcard = "*"
+
+// Matches checks if permission grants the action on the resource,
+// treating a wildcard resource or action as matching anything
+func (p Permission) Matches(resource, action string) bool {
+	return (p.Resource == PermissionWildcard || p.Resource == resource) &&
+		(p.Action == PermissionWildcard || p.Action == action)
+}
+
 // HasRole checks if user has specific role
 func (u *User) HasRole(roleName string) bool {
 	for _, role := range u.Roles {
@@ -42,12 +76,16 @@ func (u *User) HasRole(roleName string) bool {
 
 // HasPermission checks if user has specific permission
 func (u *User) HasPermission(resource, action string) bool {
+	if !u.KeyAllows(resource, action) {
+		return false
+	}
+
 	for _, role := range u.Roles {
 		if !role.IsActive {
 			continue
 		}
 		for _, perm := range role.Permissions {
-			if perm.Resource == resource && perm.Action == action {
+			if perm.Matches(resource, action) {
 				return true
 			}
 		}
@@ -55,13 +93,57 @@ func (u *User) HasPermission(resource, action string) bool {
 	return false
 }
 
-// IsAdmin checks if user is admin
+// IsKeyScoped checks if the user authenticated with a scoped API key
+func (u *User) IsKeyScoped() bool {
+	return u.KeyScopes != nil
+}
+
+// KeyAllows checks if the user's API key scope, if any, covers the action
+func (u *User) KeyAllows(resource, action string) bool {
+	if !u.IsKeyScoped() {
+		return true
+	}
+	for _, scope := range u.KeyScopes {
+		if scope.Matches(resource, action) {
+			return true
+		}
+	}
+	return false
+}
+
+// Location returns the user's time zone, falling back to UTC when unset
+func (u *User) Location() *time.Location {
+	if u.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(u.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// IsAdmin checks if user is admin; super admins are admins too
 func (u *User) IsAdmin() bool {
-	return u.HasRole("admin")
+	return u.HasRole("admin") || u.IsSuperAdmin()
+}
+
+// IsSuperAdmin checks if user can access every organization
+func (u *User) IsSuperAdmin() bool {
+	return u.HasRole(SuperAdminRole)
+}
+
+// Scope returns the data access scope of the user
+func (u *User) Scope() Scope {
+	return Scope{
+		OrganizationID: u.OrganizationID,
+		Restricted:     !u.IsSuperAdmin(),
+	}
 }
 
 // AuthService interface for authentication operations
 type AuthService interface {
-	GetUserFromToken(tokenString string) (*User, error)
-	HasPermission(userID int, resource, action string) (bool, error)
+	GetUserFromToken(ctx context.Context, tokenString string) (*User, error)
+	GetUserFromAPIKey(ctx context.Context, key string) (*User, error)
+	HasPermission(ctx context.Context, userID int, resource, action string) (bool, error)
 }
@@ -0,0 +1,10 @@
+package interfaces
+
+import "net/http"
+
+// Router registers HTTP handlers by pattern. *http.ServeMux implements it;
+// wrappers use it to see which routes handlers register.
+type Router interface {
+	Handle(pattern string, handler http.Handler)
+	HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request))
+}
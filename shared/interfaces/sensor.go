@@ -0,0 +1,12 @@
+package interfaces
+
+import "context"
+
+// SensorAccessResolver lists the sensors a user can read, so packages that
+// hold per-sensor data can honour sensor access grants without depending on
+// the sensor package
+type SensorAccessResolver interface {
+	// ReadableSensorIDs returns the sensors in the user's scope the user can
+	// read; restricted is false when the user can read all of them
+	ReadableSensorIDs(ctx context.Context, user *User) (ids []int, restricted bool, err error)
+}
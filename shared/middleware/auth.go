@@ -14,6 +14,8 @@ type ContextKey string
 const (
 	// UserContextKey is the key for user in context
 	UserContextKey ContextKey = "user"
+	// credentialContextKey holds the credential resolved for the request
+	credentialContextKey ContextKey = "credential"
 )
 
 // AuthMiddleware provides JWT authentication middleware
@@ -28,9 +30,25 @@ func NewAuthMiddleware(authService interfaces.AuthService) *AuthMiddleware {
 	}
 }
 
-// Authenticate middleware validates JWT token and sets user in context
+// APIKeyHeader carries an API key as an alternative to a Bearer token
+const APIKeyHeader = "X-API-Key"
+
+// Authenticate middleware validates the JWT token or API key and sets
+// user in context
 func (am *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// API keys take precedence over the Authorization header
+		if r.Header.Get(APIKeyHeader) != "" {
+			cred, r := am.resolve(r)
+			if cred.err != nil {
+				response.ErrorFor(w, http.StatusUnauthorized, "Invalid or expired API key", cred.err)
+				return
+			}
+
+			next.ServeHTTP(w, withUser(r, cred.user))
+			return
+		}
+
 		// Get token from Authorization header
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
@@ -45,26 +63,63 @@ func (am *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
 			return
 		}
 
-		tokenString := parts[1]
-		if tokenString == "" {
+		if parts[1] == "" {
 			response.Unauthorized(w, "Token required")
 			return
 		}
 
 		// Validate token and get user
-		user, err := am.authService.GetUserFromToken(tokenString)
-		if err != nil {
-			response.Unauthorized(w, "Invalid or expired token")
+		cred, r := am.resolve(r)
+		if cred.err != nil {
+			response.ErrorFor(w, http.StatusUnauthorized, "Invalid or expired token", cred.err)
 			return
 		}
 
 		// Set user in context
-		ctx := context.WithValue(r.Context(), UserContextKey, user)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		next.ServeHTTP(w, withUser(r, cred.user))
 	})
 }
 
-// RequirePermission middleware checks if user has specific permission
+// credential is the outcome of authenticating the API key or Bearer token
+// of a request
+type credential struct {
+	user *interfaces.User
+	err  error
+}
+
+// bearerToken returns the token of a well-formed Bearer Authorization
+// header, or "" when there is none
+func bearerToken(r *http.Request) string {
+	parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return ""
+	}
+	return parts[1]
+}
+
+// resolve authenticates the API key or Bearer token of the request once.
+// The outcome is kept in the returned request's context, so the rate
+// limiter and Authenticate share one lookup. It returns nil when the
+// request carries no credential.
+func (am *AuthMiddleware) resolve(r *http.Request) (*credential, *http.Request) {
+	if cred, ok := r.Context().Value(credentialContextKey).(*credential); ok {
+		return cred, r
+	}
+
+	cred := &credential{}
+	if apiKey := r.Header.Get(APIKeyHeader); apiKey != "" {
+		cred.user, cred.err = am.authService.GetUserFromAPIKey(r.Context(), apiKey)
+	} else if token := bearerToken(r); token != "" {
+		cred.user, cred.err = am.authService.GetUserFromToken(r.Context(), token)
+	} else {
+		return nil, r
+	}
+
+	return cred, r.WithContext(context.WithValue(r.Context(), credentialContextKey, cred))
+}
+
+// RequirePermission middleware checks if user has specific permission. It
+// runs after Authenticate, which puts the user in context.
 func (am *AuthMiddleware) RequirePermission(resource, action string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -75,8 +130,14 @@ func (am *AuthMiddleware) RequirePermission(resource, action string) func(http.H
 				return
 			}
 
+			// Scoped API keys only reach what the key allows
+			if !user.KeyAllows(resource, action) {
+				response.Forbidden(w, "API key scope does not allow this action")
+				return
+			}
+
 			// Check permission
-			hasPermission, err := am.authService.HasPermission(user.ID, resource, action)
+			hasPermission, err := am.authService.HasPermission(r.Context(), user.ID, resource, action)
 			if err != nil {
 				response.InternalServerError(w, "Failed to check permission", err)
 				return
@@ -92,7 +153,8 @@ func (am *AuthMiddleware) RequirePermission(resource, action string) func(http.H
 	}
 }
 
-// RequireRole middleware checks if user has specific role
+// RequireRole middleware checks if user has specific role. It runs after
+// Authenticate, which puts the user in context.
 func (am *AuthMiddleware) RequireRole(roleName string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -103,8 +165,8 @@ func (am *AuthMiddleware) RequireRole(roleName string) func(http.Handler) http.H
 				return
 			}
 
-			// Check role
-			if !user.HasRole(roleName) {
+			// Role checks guard whole areas, which scoped API keys never cover
+			if user.IsKeyScoped() || !user.HasRole(roleName) {
 				response.Forbidden(w, "Insufficient role")
 				return
 			}
@@ -114,9 +176,29 @@ func (am *AuthMiddleware) RequireRole(roleName string) func(http.Handler) http.H
 	}
 }
 
-// RequireAdmin middleware checks if user is admin
+// RequireAdmin middleware checks if user is admin or super admin. It runs
+// after Authenticate, which puts the user in context.
 func (am *AuthMiddleware) RequireAdmin(next http.Handler) http.Handler {
-	return am.RequireRole("admin")(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := GetUserFromContext(r.Context())
+		if !ok {
+			response.Unauthorized(w, "User not found in context")
+			return
+		}
+
+		if user.IsKeyScoped() || !user.IsAdmin() {
+			response.Forbidden(w, "Insufficient role")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireSuperAdmin middleware checks if user is super admin. It runs after
+// Authenticate, which puts the user in context.
+func (am *AuthMiddleware) RequireSuperAdmin(next http.Handler) http.Handler {
+	return am.RequireRole(interfaces.SuperAdminRole)(next)
 }
 
 // OptionalAuth middleware validates token if present but doesn't require it
@@ -146,7 +228,7 @@ func (am *AuthMiddleware) OptionalAuth(next http.Handler) http.Handler {
 		}
 
 		// Try to validate token and get user
-		user, err := am.authService.GetUserFromToken(tokenString)
+		user, err := am.authService.GetUserFromToken(r.Context(), tokenString)
 		if err != nil {
 			// Invalid token, continue without user
 			next.ServeHTTP(w, r)
@@ -154,40 +236,29 @@ func (am *AuthMiddleware) OptionalAuth(next http.Handler) http.Handler {
 		}
 
 		// Set user in context
-		ctx := context.WithValue(r.Context(), UserContextKey, user)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		next.ServeHTTP(w, withUser(r, user))
 	})
 }
 
+// withUser returns the request with the authenticated user in its context
+func withUser(r *http.Request, user *interfaces.User) *http.Request {
+	setLogUser(r.Context(), user.ID)
+	return r.WithContext(context.WithValue(r.Context(), UserContextKey, user))
+}
+
 // GetUserFromContext retrieves user from request context
 func GetUserFromContext(ctx context.Context) (*interfaces.User, bool) {
 	user, ok := ctx.Value(UserContextKey).(*interfaces.User)
 	return user, ok
 }
 
-// CORS middleware
-func CORS(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-
-		next.ServeHTTP(w, r)
-	})
-}
-
-// Logging middleware
-func Logging(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Simple request logging
-		// In production, use proper logging library
-		next.ServeHTTP(w, r)
-	})
+// GetScopeFromContext returns the organization scope of the user in context
+func GetScopeFromContext(ctx context.Context) (interfaces.Scope, bool) {
+	user, ok := GetUserFromContext(ctx)
+	if !ok {
+		return interfaces.Scope{}, false
+	}
+	return user.Scope(), true
 }
 
 // ContentTypeJSON middleware sets JSON content type
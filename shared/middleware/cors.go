@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Defaults used when the CORS options leave them empty
+var (
+	defaultCORSMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	defaultCORSHeaders = []string{"Content-Type", "Authorization", APIKeyHeader, "X-Request-ID"}
+)
+
+// CORSOptions configures cross-origin requests. Empty lists keep the
+// permissive defaults: any origin and the methods and headers the API uses.
+type CORSOptions struct {
+	// AllowedOrigins are exact origins, "*" for any origin, or patterns with
+	// one "*" such as "https://*.example.com"
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is how many seconds browsers cache preflight results; 0 omits it
+	MaxAge int
+}
+
+// CORS middleware answers preflight requests and sets the CORS headers for
+// allowed origins only. When mux is set, preflights only allow the methods
+// routed for the requested path.
+func CORS(opts CORSOptions, mux *http.ServeMux) func(http.Handler) http.Handler {
+	origins := opts.AllowedOrigins
+	if len(origins) == 0 {
+		origins = []string{"*"}
+	}
+	methods := opts.AllowedMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	headers := strings.Join(defaultCORSHeaders, ", ")
+	if len(opts.AllowedHeaders) > 0 {
+		headers = strings.Join(opts.AllowedHeaders, ", ")
+	}
+
+	anyOrigin := false
+	for _, origin := range origins {
+		if origin == "*" {
+			anyOrigin = true
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			w.Header().Add("Vary", "Origin")
+			if origin == "" || !originAllowed(origins, origin) {
+				if preflight {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// A wildcard cannot be combined with credentials, so echo the origin
+			if anyOrigin && !opts.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+			if opts.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+			w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
+
+			if !preflight {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(routeMethods(mux, r, methods), ", "))
+			w.Header().Set("Access-Control-Allow-Headers", headers)
+			if opts.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(opts.MaxAge))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+// originAllowed matches the origin against exact origins and patterns with
+// one "*"
+func originAllowed(allowed []string, origin string) bool {
+	for _, pattern := range allowed {
+		if pattern == "*" || strings.EqualFold(pattern, origin) {
+			return true
+		}
+		prefix, suffix, ok := strings.Cut(pattern, "*")
+		if ok && len(origin) > len(prefix)+len(suffix) &&
+			strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// routeMethods returns the methods among methods that the mux routes for the
+// request's path, or all of them without a mux. OPTIONS is always kept.
+func routeMethods(mux *http.ServeMux, r *http.Request, methods []string) []string {
+	if mux == nil {
+		return methods
+	}
+
+	var routed []string
+	for _, method := range methods {
+		if method == http.MethodOptions {
+			routed = append(routed, method)
+			continue
+		}
+		probe := r.Clone(r.Context())
+		probe.Method = method
+		if _, pattern := mux.Handler(probe); pattern != "" {
+			routed = append(routed, method)
+		}
+	}
+	return routed
+}
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// requestLogContextKey is the key for the request log entry in context
+const requestLogContextKey ContextKey = "request_log"
+
+// requestLogEntry collects details set by inner handlers, which the logging
+// middleware cannot read from their derived request contexts
+type requestLogEntry struct {
+	userID int
+}
+
+// setLogUser records the authenticated user on the request log entry
+func setLogUser(ctx context.Context, userID int) {
+	if entry, ok := ctx.Value(requestLogContextKey).(*requestLogEntry); ok {
+		entry.userID = userID
+	}
+}
+
+// statusRecorder captures the status code and size of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, which
+// event streams need to flush
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// Logging middleware logs one line per request with its status, size and
+// duration. Requests to skipPaths, such as health probes, are not logged.
+func Logging(logger *slog.Logger, skipPaths []string) func(http.Handler) http.Handler {
+	skip := make(map[string]bool, len(skipPaths))
+	for _, path := range skipPaths {
+		skip[path] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if skip[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			entry := &requestLogEntry{}
+			rec := &statusRecorder{ResponseWriter: w}
+			ctx := context.WithValue(r.Context(), requestLogContextKey, entry)
+
+			next.ServeHTTP(rec, r.WithContext(ctx))
+
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", status),
+				slog.Int("bytes", rec.bytes),
+				slog.Duration("duration", time.Since(start)),
+				slog.String("remote_ip", ClientIP(r)),
+			}
+			if entry.userID != 0 {
+				attrs = append(attrs, slog.Int("user_id", entry.userID))
+			}
+			if requestID := GetRequestID(r.Context()); requestID != "" {
+				attrs = append(attrs, slog.String("request_id", requestID))
+			}
+
+			level := slog.LevelInfo
+			switch {
+			case status >= http.StatusInternalServerError:
+				level = slog.LevelError
+			case status >= http.StatusBadRequest:
+				level = slog.LevelWarn
+			}
+			logger.LogAttrs(r.Context(), level, "request", attrs...)
+		})
+	}
+}
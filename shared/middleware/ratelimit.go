@@ -0,0 +1,161 @@
+package middleware
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"user-management/shared/response"
+)
+
+// rateSweepInterval is how often refilled buckets are dropped
+const rateSweepInterval = 10 * time.Minute
+
+// maxRateBuckets caps the buckets kept in memory. New clients beyond it are
+// limited until buckets refill and can be dropped.
+const maxRateBuckets = 100000
+
+// RateLimit is a request rate with the burst allowed on top of it
+type RateLimit struct {
+	RequestsPerMinute int
+	Burst             int
+}
+
+// rateBucket is the token bucket of one client on one limit
+type rateBucket struct {
+	tokens float64
+	last   time.Time
+	// full is when the bucket has refilled, after which dropping it loses
+	// nothing
+	full time.Time
+}
+
+// RateLimiter limits how often each client can call the API with an
+// in-memory token bucket per client. Authenticated clients are keyed by user
+// and others by IP. Routes with an override have buckets of their own.
+type RateLimiter struct {
+	limit  RateLimit
+	routes map[string]RateLimit
+	authMW *AuthMiddleware
+
+	mu        sync.Mutex
+	buckets   map[string]*rateBucket
+	lastSweep time.Time
+}
+
+// NewRateLimiter creates a rate limiter. Routes are overridden by their mux
+// pattern, such as "POST /api/auth/login"; a rate of 0 does not limit.
+func NewRateLimiter(limit RateLimit, routes map[string]RateLimit, authMW *AuthMiddleware) *RateLimiter {
+	return &RateLimiter{
+		limit:     limit,
+		routes:    routes,
+		authMW:    authMW,
+		buckets:   make(map[string]*rateBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Limit middleware answers 429 with Retry-After to clients over their limit
+// on the route they call, which is looked up in mux
+func (rl *RateLimiter) Limit(mux *http.ServeMux) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			limit, scope := rl.limit, ""
+			if _, pattern := mux.Handler(r); pattern != "" {
+				if override, ok := rl.routes[pattern]; ok {
+					limit, scope = override, pattern
+				}
+			}
+
+			if limit.RequestsPerMinute > 0 {
+				var client string
+				client, r = rl.clientKey(r)
+				key := scope + "|" + client
+				if ok, wait := rl.allow(key, limit, time.Now()); !ok {
+					retryAfter := int(math.Ceil(wait.Seconds()))
+					w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+					response.Error(w, http.StatusTooManyRequests, "Too many requests", nil)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientKey identifies the caller by user when the request carries valid
+// credentials and by IP otherwise. IPv6 clients are keyed by their /64,
+// since a single host usually controls the whole prefix. The returned
+// request carries the resolved credential for Authenticate to reuse.
+func (rl *RateLimiter) clientKey(r *http.Request) (string, *http.Request) {
+	if rl.authMW != nil {
+		var cred *credential
+		cred, r = rl.authMW.resolve(r)
+		if cred != nil && cred.err == nil {
+			return "user:" + strconv.Itoa(cred.user.ID), r
+		}
+	}
+
+	ip := ClientIP(r)
+	if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+		ip = parsed.Mask(net.CIDRMask(64, 128)).String() + "/64"
+	}
+	return "ip:" + ip, r
+}
+
+// allow takes a token from the key's bucket. When the bucket is empty it
+// returns false and how long until the next token.
+func (rl *RateLimiter) allow(key string, limit RateLimit, now time.Time) (bool, time.Duration) {
+	rate := float64(limit.RequestsPerMinute) / 60
+	capacity := math.Max(float64(limit.Burst), math.Max(1, math.Ceil(rate)))
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if now.Sub(rl.lastSweep) >= rateSweepInterval {
+		rl.sweep(now)
+	}
+
+	bucket, ok := rl.buckets[key]
+	if !ok {
+		if len(rl.buckets) >= maxRateBuckets {
+			rl.sweep(now)
+			if len(rl.buckets) >= maxRateBuckets {
+				return false, time.Second
+			}
+		}
+		bucket = &rateBucket{tokens: capacity, last: now}
+		rl.buckets[key] = bucket
+	}
+
+	bucket.tokens = math.Min(capacity, bucket.tokens+now.Sub(bucket.last).Seconds()*rate)
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		wait := time.Duration((1 - bucket.tokens) / rate * float64(time.Second))
+		return false, wait
+	}
+
+	bucket.tokens--
+	bucket.full = now.Add(time.Duration((capacity - bucket.tokens) / rate * float64(time.Second)))
+	return true, 0
+}
+
+// sweep drops the buckets that have refilled, which behave as new ones would.
+// Callers must hold rl.mu.
+func (rl *RateLimiter) sweep(now time.Time) {
+	for key, bucket := range rl.buckets {
+		if !now.Before(bucket.full) {
+			delete(rl.buckets, key)
+		}
+	}
+	rl.lastSweep = now
+}
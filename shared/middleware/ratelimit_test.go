@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"user-management/shared/interfaces"
+)
+
+// countingAuth authenticates every credential as the same user and counts
+// the lookups
+type countingAuth struct {
+	keyLookups, tokenLookups int
+}
+
+func (a *countingAuth) GetUserFromToken(ctx context.Context, tokenString string) (*interfaces.User, error) {
+	a.tokenLookups++
+	return &interfaces.User{ID: 1}, nil
+}
+
+func (a *countingAuth) GetUserFromAPIKey(ctx context.Context, key string) (*interfaces.User, error) {
+	a.keyLookups++
+	return &interfaces.User{ID: 1}, nil
+}
+
+func (a *countingAuth) HasPermission(ctx context.Context, userID int, resource, action string) (bool, error) {
+	return true, nil
+}
+
+// TestLimitAuthenticatesOnce shares the credential lookup of the rate
+// limiter with Authenticate, so API key use is recorded once per request
+func TestLimitAuthenticatesOnce(t *testing.T) {
+	auth := &countingAuth{}
+	authMW := NewAuthMiddleware(auth)
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /api/profile", authMW.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+	limiter := NewRateLimiter(RateLimit{RequestsPerMinute: 60, Burst: 10}, nil, authMW)
+	handler := limiter.Limit(mux)(mux)
+
+	for _, credential := range []struct{ header, value string }{
+		{APIKeyHeader, "key"},
+		{"Authorization", "Bearer token"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/profile", nil)
+		req.Header.Set(credential.header, credential.value)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("%s: got status %d", credential.header, rec.Code)
+		}
+	}
+
+	if auth.keyLookups != 1 || auth.tokenLookups != 1 {
+		t.Errorf("got %d API key and %d token lookups, want 1 each", auth.keyLookups, auth.tokenLookups)
+	}
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"user-management/shared/response"
+)
+
+// RequestIDContextKey is the key for the request ID in context
+const RequestIDContextKey ContextKey = "request_id"
+
+// maxRequestIDLength bounds client supplied request IDs, which end up in
+// every log line of the request
+const maxRequestIDLength = 128
+
+// RequestID middleware reuses the X-Request-ID header of the request or
+// generates a UUID, stores it in the request context and returns it in the
+// response header
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(response.RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+
+		w.Header().Set(response.RequestIDHeader, requestID)
+		ctx := context.WithValue(r.Context(), RequestIDContextKey, requestID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// GetRequestID returns the ID of the request in context, or "" outside a
+// request
+func GetRequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(RequestIDContextKey).(string)
+	return requestID
+}
+
+// validRequestID accepts non-empty IDs of printable ASCII, so clients cannot
+// inject line breaks into logs
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random version 4 UUID
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
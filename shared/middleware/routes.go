@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"user-management/shared/response"
+)
+
+// routableMethods are probed to find the methods a path is routed for
+var routableMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+
+// RouteErrors serves the mux, answering requests it has no route for with
+// the JSON error envelope instead of its plain text: 405 with an Allow header
+// when other methods are routed for the path, and 404 otherwise
+func RouteErrors(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := mux.Handler(r); pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		allowed := routeMethods(mux, r, routableMethods)
+		if len(allowed) == 0 {
+			response.NotFound(w, "Route not found")
+			return
+		}
+
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		response.MethodNotAllowed(w, "Method "+r.Method+" not allowed on this route")
+	})
+}
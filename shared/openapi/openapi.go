@@ -0,0 +1,266 @@
+package openapi
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"user-management/shared/interfaces"
+	"user-management/shared/middleware"
+	"user-management/shared/response"
+)
+
+// Access levels of operations; any other non-empty access is a
+// "resource:action" permission
+const (
+	AccessPublic        = ""
+	AccessAuthenticated = "authenticated"
+	AccessAdmin         = "admin"
+	AccessSuperAdmin    = "super_admin"
+)
+
+// Info describes the API in the document
+type Info struct {
+	Title       string
+	Version     string
+	Description string
+}
+
+// Operation documents one route. Packages list their operations next to
+// the handlers so the document changes with them.
+type Operation struct {
+	// Pattern is the documented method and path, such as "GET /api/users/{id}"
+	Pattern string
+	// Route is the mux pattern serving the operation when it differs from
+	// Pattern, for paths dispatched by a wildcard
+	Route   string
+	Tag     string
+	Summary string
+	// Access is one of the Access levels or a "resource:action" permission
+	Access string
+	// Query lists the query parameters
+	Query []string
+	// Headers lists request headers other than credentials
+	Headers []string
+	// Request is a value of the JSON request body type; nil has no body
+	Request interface{}
+	// Response is a value of the type in the data field of the response
+	Response interface{}
+	// Status is the success status; 0 is 200
+	Status int
+	// Paginated responses carry pagination meta
+	Paginated bool
+	// Raw responses are written without the API response envelope
+	Raw bool
+	// ContentType of the response; empty is application/json
+	ContentType string
+}
+
+// Recorder registers routes on a router and remembers their patterns, so the
+// document can be checked against what is actually served
+type Recorder struct {
+	router   interfaces.Router
+	patterns []string
+}
+
+// NewRecorder creates a recorder registering routes on router
+func NewRecorder(router interfaces.Router) *Recorder {
+	return &Recorder{router: router}
+}
+
+// Handle registers the handler for pattern on the router
+func (rec *Recorder) Handle(pattern string, handler http.Handler) {
+	rec.patterns = append(rec.patterns, pattern)
+	rec.router.Handle(pattern, handler)
+}
+
+// HandleFunc registers the handler function for pattern on the router
+func (rec *Recorder) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	rec.patterns = append(rec.patterns, pattern)
+	rec.router.HandleFunc(pattern, handler)
+}
+
+// Patterns returns the registered patterns in registration order
+func (rec *Recorder) Patterns() []string {
+	return append([]string(nil), rec.patterns...)
+}
+
+// Build creates the OpenAPI 3 document of ops. Registered patterns that no
+// operation documents are added with a generic operation and returned, so
+// callers can report them.
+func Build(info Info, ops []Operation, patterns []string) (map[string]interface{}, []string) {
+	schemas := newSchemaBuilder()
+	paths := make(map[string]map[string]interface{})
+
+	documented := make(map[string]bool, len(ops))
+	for _, op := range ops {
+		route := op.Route
+		if route == "" {
+			route = op.Pattern
+		}
+		documented[route] = true
+		addOperation(paths, op, schemas)
+	}
+
+	var undocumented []string
+	for _, pattern := range patterns {
+		if documented[pattern] {
+			continue
+		}
+		documented[pattern] = true
+		undocumented = append(undocumented, pattern)
+		addOperation(paths, Operation{Pattern: pattern, Tag: "undocumented"}, schemas)
+	}
+
+	errorSchema := schemas.of(response.ErrorResponse{})
+	schemas.of(response.Meta{})
+
+	doc := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       info.Title,
+			"version":     info.Version,
+			"description": info.Description,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas.components,
+			"responses": map[string]interface{}{
+				"Error": map[string]interface{}{
+					"description": "Error response",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{"schema": errorSchema},
+					},
+				},
+			},
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{
+					"type":         "http",
+					"scheme":       "bearer",
+					"bearerFormat": "JWT",
+				},
+				"apiKeyAuth": map[string]interface{}{
+					"type": "apiKey",
+					"in":   "header",
+					"name": middleware.APIKeyHeader,
+				},
+			},
+		},
+	}
+	return doc, undocumented
+}
+
+// addOperation adds op to the paths of the document
+func addOperation(paths map[string]map[string]interface{}, op Operation, schemas *schemaBuilder) {
+	method, path, ok := strings.Cut(op.Pattern, " ")
+	if !ok {
+		method, path = http.MethodGet, op.Pattern
+	}
+	path = strings.TrimSuffix(path, "{$}")
+
+	var params []interface{}
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			name := strings.TrimSuffix(strings.Trim(segment, "{}"), "...")
+			params = append(params, parameter(name, "path", true))
+			path = strings.Replace(path, segment, "{"+name+"}", 1)
+		}
+	}
+	for _, name := range op.Query {
+		params = append(params, parameter(name, "query", false))
+	}
+	for _, name := range op.Headers {
+		params = append(params, parameter(name, "header", false))
+	}
+
+	operation := map[string]interface{}{
+		"responses": responses(op, schemas),
+	}
+	if op.Tag != "" {
+		operation["tags"] = []string{op.Tag}
+	}
+	if op.Summary != "" {
+		operation["summary"] = op.Summary
+	}
+	if len(params) > 0 {
+		operation["parameters"] = params
+	}
+	if op.Request != nil {
+		operation["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": schemas.of(op.Request)},
+			},
+		}
+	}
+	if op.Access != AccessPublic {
+		operation["security"] = []interface{}{
+			map[string]interface{}{"bearerAuth": []string{}},
+			map[string]interface{}{"apiKeyAuth": []string{}},
+		}
+		if op.Access != AccessAuthenticated {
+			operation["description"] = "Requires " + strings.ReplaceAll(op.Access, "_", " ") + " access."
+		}
+	}
+
+	if paths[path] == nil {
+		paths[path] = make(map[string]interface{})
+	}
+	paths[path][strings.ToLower(method)] = operation
+}
+
+// responses documents the success response of op and its error responses
+func responses(op Operation, schemas *schemaBuilder) map[string]interface{} {
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	contentType := op.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+
+	var schema map[string]interface{}
+	switch {
+	case op.Raw || contentType != "application/json":
+		schema = schemas.of(op.Response)
+	default:
+		properties := map[string]interface{}{
+			"success": map[string]interface{}{"type": "boolean"},
+			"message": map[string]interface{}{"type": "string"},
+		}
+		if op.Response != nil {
+			properties["data"] = schemas.of(op.Response)
+		}
+		if op.Paginated {
+			properties["meta"] = schemas.of(response.Meta{})
+		}
+		schema = map[string]interface{}{"type": "object", "properties": properties}
+	}
+
+	result := map[string]interface{}{
+		strconv.Itoa(status): map[string]interface{}{
+			"description": http.StatusText(status),
+			"content": map[string]interface{}{
+				contentType: map[string]interface{}{"schema": schema},
+			},
+		},
+		"default": map[string]interface{}{"$ref": "#/components/responses/Error"},
+	}
+	if op.Access != AccessPublic {
+		result[strconv.Itoa(http.StatusUnauthorized)] = map[string]interface{}{"$ref": "#/components/responses/Error"}
+		if op.Access != AccessAuthenticated {
+			result[strconv.Itoa(http.StatusForbidden)] = map[string]interface{}{"$ref": "#/components/responses/Error"}
+		}
+	}
+	return result
+}
+
+// parameter documents a string parameter
+func parameter(name, in string, required bool) map[string]interface{} {
+	return map[string]interface{}{
+		"name":     name,
+		"in":       in,
+		"required": required,
+		"schema":   map[string]interface{}{"type": "string"},
+	}
+}
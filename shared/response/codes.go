@@ -0,0 +1,97 @@
+package response
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// ErrorCode maps a sentinel error to the stable code clients match on
+// instead of the message
+type ErrorCode struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Err     error  `json:"-"`
+}
+
+// Code returns the error code of a sentinel error
+func Code(code string, err error) ErrorCode {
+	return ErrorCode{Code: code, Message: err.Error(), Err: err}
+}
+
+// statusCodes are the codes of errors without a registered code
+var statusCodes = map[int]string{
+	http.StatusBadRequest:          "BAD_REQUEST",
+	http.StatusUnauthorized:        "UNAUTHORIZED",
+	http.StatusForbidden:           "FORBIDDEN",
+	http.StatusNotFound:            "NOT_FOUND",
+	http.StatusMethodNotAllowed:    "METHOD_NOT_ALLOWED",
+	http.StatusConflict:            "CONFLICT",
+	http.StatusTooManyRequests:     "RATE_LIMITED",
+	http.StatusInternalServerError: "INTERNAL_ERROR",
+	http.StatusServiceUnavailable:  "SERVICE_UNAVAILABLE",
+}
+
+// validationFailedCode is the code of ValidationErrors responses
+const validationFailedCode = "VALIDATION_FAILED"
+
+var (
+	codesMu sync.RWMutex
+	codes   []ErrorCode
+)
+
+// RegisterErrorCodes adds codes to the registry. Errors wrapping several
+// registered errors get the code registered first, so list specific errors
+// before the general ones they wrap.
+func RegisterErrorCodes(errorCodes ...ErrorCode) {
+	codesMu.Lock()
+	defer codesMu.Unlock()
+	codes = append(codes, errorCodes...)
+}
+
+// ErrorCodeFor returns the registered code of err, or "" when none matches
+func ErrorCodeFor(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	codesMu.RLock()
+	defer codesMu.RUnlock()
+	for _, code := range codes {
+		if errors.Is(err, code.Err) {
+			return code.Code
+		}
+	}
+	return ""
+}
+
+// ErrorCodes lists the registered codes followed by the codes of errors
+// without one
+func ErrorCodes() []ErrorCode {
+	codesMu.RLock()
+	list := make([]ErrorCode, len(codes), len(codes)+len(statusCodes)+1)
+	copy(list, codes)
+	codesMu.RUnlock()
+
+	for _, status := range []int{
+		http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden,
+		http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusConflict,
+		http.StatusTooManyRequests, http.StatusInternalServerError,
+		http.StatusServiceUnavailable,
+	} {
+		list = append(list, ErrorCode{Code: statusCodes[status], Message: http.StatusText(status)})
+	}
+	return append(list, ErrorCode{Code: validationFailedCode, Message: "Request fields failed validation"})
+}
+
+// errorCode returns the code of an error response: the registered code of
+// err, or the code of the status
+func errorCode(statusCode int, err error) string {
+	if code := ErrorCodeFor(err); code != "" {
+		return code
+	}
+	if code, ok := statusCodes[statusCode]; ok {
+		return code
+	}
+	return "ERROR"
+}
@@ -30,13 +30,22 @@ type ValidationError struct {
 
 // ErrorResponse represents error response with details
 type ErrorResponse struct {
-	Success    bool              `json:"success"`
-	Message    string            `json:"message"`
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+	// Code identifies the error for clients; see ErrorCodes
+	Code       string            `json:"code"`
 	Error      string            `json:"error"`
 	Errors     []ValidationError `json:"errors,omitempty"`
+	Data       interface{}       `json:"data,omitempty"`
 	StatusCode int               `json:"status_code"`
+	// RequestID identifies the request in the server logs
+	RequestID string `json:"request_id,omitempty"`
 }
 
+// RequestIDHeader carries the ID of a request; the request ID middleware
+// sets it on every response before handlers run
+const RequestIDHeader = "X-Request-ID"
+
 // JSON sends JSON response
 func JSON(w http.ResponseWriter, statusCode int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
@@ -74,8 +83,42 @@ func Error(w http.ResponseWriter, statusCode int, message string, err error) {
 	response := ErrorResponse{
 		Success:    false,
 		Message:    message,
+		Code:       errorCode(statusCode, err),
+		Error:      errorMsg,
+		StatusCode: statusCode,
+		RequestID:  w.Header().Get(RequestIDHeader),
+	}
+	JSON(w, statusCode, response)
+}
+
+// ErrorFor sends error response with the code of err but without its
+// message, for statuses such as not found whose message says it all
+func ErrorFor(w http.ResponseWriter, statusCode int, message string, err error) {
+	response := ErrorResponse{
+		Success:    false,
+		Message:    message,
+		Code:       errorCode(statusCode, err),
+		StatusCode: statusCode,
+		RequestID:  w.Header().Get(RequestIDHeader),
+	}
+	JSON(w, statusCode, response)
+}
+
+// ErrorWithData sends error response carrying details about the failure
+func ErrorWithData(w http.ResponseWriter, statusCode int, message string, err error, data interface{}) {
+	errorMsg := ""
+	if err != nil {
+		errorMsg = err.Error()
+	}
+
+	response := ErrorResponse{
+		Success:    false,
+		Message:    message,
+		Code:       errorCode(statusCode, err),
 		Error:      errorMsg,
+		Data:       data,
 		StatusCode: statusCode,
+		RequestID:  w.Header().Get(RequestIDHeader),
 	}
 	JSON(w, statusCode, response)
 }
@@ -100,6 +143,11 @@ func NotFound(w http.ResponseWriter, message string) {
 	Error(w, http.StatusNotFound, message, nil)
 }
 
+// MethodNotAllowed sends method not allowed error
+func MethodNotAllowed(w http.ResponseWriter, message string) {
+	Error(w, http.StatusMethodNotAllowed, message, nil)
+}
+
 // Conflict sends conflict error
 func Conflict(w http.ResponseWriter, message string, err error) {
 	Error(w, http.StatusConflict, message, err)
@@ -110,13 +158,21 @@ func InternalServerError(w http.ResponseWriter, message string, err error) {
 	Error(w, http.StatusInternalServerError, message, err)
 }
 
-// ValidationErrors sends validation error response
-func ValidationErrors(w http.ResponseWriter, message string, errors []ValidationError) {
+// ValidationErrors sends validation error response with the code of err,
+// or VALIDATION_FAILED when it has none
+func ValidationErrors(w http.ResponseWriter, message string, err error, errors []ValidationError) {
+	code := ErrorCodeFor(err)
+	if code == "" {
+		code = validationFailedCode
+	}
+
 	response := ErrorResponse{
 		Success:    false,
 		Message:    message,
+		Code:       code,
 		Errors:     errors,
 		StatusCode: http.StatusBadRequest,
+		RequestID:  w.Header().Get(RequestIDHeader),
 	}
 	JSON(w, http.StatusBadRequest, response)
 }